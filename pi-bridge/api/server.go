@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+var logger = log.New(os.Stdout, "[API] ", log.LstdFlags|log.Lshortfile)
+
+// Config представляет конфигурацию REST API моста
+type Config struct {
+	Listen string `yaml:"listen"` // Адрес для входящих подключений, например ":8080" (пусто - API выключен)
+}
+
+// DefaultConfig возвращает конфигурацию по умолчанию (API выключен)
+func DefaultConfig() Config {
+	return Config{}
+}
+
+// Server представляет HTTP сервер REST API
+type Server struct {
+	config Config
+	mux    *http.ServeMux
+	server *http.Server
+}
+
+// NewServer создает сервер REST API
+func NewServer(config Config) *Server {
+	return &Server{
+		config: config,
+		mux:    http.NewServeMux(),
+	}
+}
+
+// Handle регистрирует обработчик для пути (вызывать до Start)
+func (s *Server) Handle(path string, handler http.Handler) {
+	s.mux.Handle(path, handler)
+}
+
+// Handler возвращает маршрутизатор запросов
+func (s *Server) Handler() http.Handler {
+	return s.mux
+}
+
+// Start запускает сервер, если задан адрес. Порт открывается до возврата, поэтому
+// ошибка привязки (адрес занят) возвращается сразу; ctx ограничивает только запуск
+func (s *Server) Start(ctx context.Context) error {
+	if s.config.Listen == "" {
+		logger.Println("REST API: DISABLED")
+		return nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var listenConfig net.ListenConfig
+	listener, err := listenConfig.Listen(ctx, "tcp", s.config.Listen)
+	if err != nil {
+		return err
+	}
+
+	s.server = &http.Server{
+		Addr:              s.config.Listen,
+		Handler:           s.mux,
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	go func() {
+		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Printf("REST API server error: %v", err)
+		}
+	}()
+
+	logger.Printf("REST API listening on %s", s.config.Listen)
+	return nil
+}
+
+// Stop останавливает сервер
+func (s *Server) Stop() error {
+	if s.server == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.server.Shutdown(ctx)
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServerHandle(t *testing.T) {
+	server := NewServer(DefaultConfig())
+	server.Handle("/api/ping", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("pong"))
+	}))
+
+	recorder := httptest.NewRecorder()
+	server.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/ping", nil))
+
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "pong" {
+		t.Errorf("Unexpected response: %d %q", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestServerDisabled(t *testing.T) {
+	server := NewServer(DefaultConfig())
+	if err := server.Start(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := server.Stop(); err != nil {
+		t.Errorf("Unexpected error on stop: %v", err)
+	}
+}
+
+func TestServerStartErrors(t *testing.T) {
+	// Занятый адрес сообщается при запуске, а не только в журнале
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	server := NewServer(Config{Listen: listener.Addr().String()})
+	if err := server.Start(context.Background()); err == nil {
+		server.Stop()
+		t.Error("Expected error for address in use")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	server = NewServer(Config{Listen: "127.0.0.1:0"})
+	if err := server.Start(ctx); err == nil {
+		server.Stop()
+		t.Error("Expected error for canceled context")
+	}
+}
@@ -1,37 +1,100 @@
 package bluetooth
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"golang.org/x/sys/unix"
+	"elm327-bridge/common"
 )
 
+// defaultInitAttempts - попыток на каждую команду инициализации: дешевые клоны ELM327
+// часто не отвечают на первый ATZ после подключения
+const defaultInitAttempts = 3
+
+// initRetryDelay - пауза перед повтором команды инициализации, чтобы опоздавший ответ
+// не был принят за ответ на повтор
+const initRetryDelay = 500 * time.Millisecond
+
+// searchTimeout - время ожидания приглашения, пока адаптер определяет протокол:
+// перебор протоколов при ATSP0 и медленная инициализация ISO 9141/KWP занимают секунды
+const searchTimeout = 15 * time.Second
+
 var logger = log.New(os.Stdout, "[Bluetooth-Adapter] ", log.LstdFlags|log.Lshortfile)
 
+// SetLogOutput перенаправляет журнал адаптера, например в io.Discard при нагрузочных тестах
+func SetLogOutput(w io.Writer) {
+	logger.SetOutput(w)
+}
+
 // Config представляет конфигурацию для Bluetooth адаптера
 type Config struct {
-	DevicePath        string        `yaml:"device_path"`        // Путь к устройству, например "/dev/rfcomm0"
-	ReconnectInterval time.Duration `yaml:"reconnect_interval"` // Интервал переподключения при ошибках
-	ConnectTimeout    time.Duration `yaml:"connect_timeout"`    // Таймаут на подключение
-	ReadTimeout       time.Duration `yaml:"read_timeout"`       // Таймаут на чтение
-	WriteTimeout      time.Duration `yaml:"write_timeout"`      // Таймаут на запись
-	InitCommands      []string      `yaml:"init_commands"`      // Команды для инициализации ELM327
+	Transport              string                 `yaml:"transport"`                // serial (по умолчанию), rfcomm, tcp или ble
+	DevicePath             string                 `yaml:"device_path"`              // Путь к устройству, например "/dev/rfcomm0" (serial)
+	HotPlug                bool                   `yaml:"hotplug"`                  // Подключаться при появлении device_path, не дожидаясь таймера (serial)
+	Address                string                 `yaml:"address"`                  // MAC адаптера (rfcomm, ble, сопряжение) или host:port (tcp)
+	Channel                int                    `yaml:"channel"`                  // Канал RFCOMM (0 - 1)
+	BLENotifyUUID          string                 `yaml:"ble_notify_uuid"`          // Характеристика GATT для ответов адаптера (пусто - FFF1)
+	BLEWriteUUID           string                 `yaml:"ble_write_uuid"`           // Характеристика GATT для команд (пусто - FFF2)
+	Discovery              DiscoveryConfig        `yaml:"discovery"`                // Поиск адаптера по имени или префиксу MAC (rfcomm)
+	Pairing                PairingConfig          `yaml:"pairing"`                  // Сопряжение с адаптером через BlueZ (rfcomm, serial)
+	RecordPath             string                 `yaml:"record_path"`              // Журнал сырого обмена с адаптером (пусто - не записывать)
+	RecordMaxSize          int                    `yaml:"record_max_size"`          // Размер журнала в МБ, после которого он ротируется (0 - без ротации)
+	RecordMaxFiles         int                    `yaml:"record_max_files"`         // Число сохраняемых прежних журналов (0 - 3)
+	ReplayPath             string                 `yaml:"replay_path"`              // Журнал, воспроизводимый транспортом replay
+	ReplaySpeed            float64                `yaml:"replay_speed"`             // Ускорение воспроизведения (0 - исходный темп)
+	ReconnectInterval      time.Duration          `yaml:"reconnect_interval"`       // Интервал переподключения при ошибках
+	ConnectTimeout         time.Duration          `yaml:"connect_timeout"`          // Таймаут на подключение
+	ReadTimeout            time.Duration          `yaml:"read_timeout"`             // Таймаут на чтение
+	WriteTimeout           time.Duration          `yaml:"write_timeout"`            // Таймаут на запись
+	HeartbeatInterval      time.Duration          `yaml:"heartbeat_interval"`       // Проверка простаивающей связи (0 - отключена)
+	HeartbeatCommand       string                 `yaml:"heartbeat_command"`        // Команда проверки связи (пусто - ATI)
+	LineTerminator         string                 `yaml:"line_terminator"`          // Завершение команд: cr, lf или crlf (пусто - cr с автоопределением)
+	Prompt                 string                 `yaml:"prompt"`                   // Символ приглашения или none (пусто - ">" с автоопределением)
+	MaxResponseSize        int                    `yaml:"max_response_size"`        // Предел ответа без приглашения в байтах (0 - 64 КБ)
+	PartialResponseTimeout time.Duration          `yaml:"partial_response_timeout"` // Пауза, завершающая ответ, после которого не пришло приглашение (0 - ждать read_timeout)
+	BaudRate               int                    `yaml:"baud_rate"`                // Скорость порта для USB/UART адаптеров (0 - не менять)
+	DataBits               int                    `yaml:"data_bits"`                // Биты данных: 5-8 (0 - 8)
+	Parity                 string                 `yaml:"parity"`                   // Четность: none, even или odd (пусто - none)
+	StopBits               int                    `yaml:"stop_bits"`                // Стоп-биты: 1 или 2 (0 - 1)
+	FlowControl            string                 `yaml:"flow_control"`             // Управление потоком: none, rtscts или xonxoff (пусто - none)
+	RFCOMMBind             bool                   `yaml:"rfcomm_bind"`              // Привязывать device_path к address при запуске, как rfcomm bind (serial)
+	InitCommands           []string               `yaml:"init_commands"`            // Команды для инициализации ELM327
+	InitProfiles           map[string]InitProfile `yaml:"init_profiles"`            // Именованные наборы команд инициализации
+	InitProfile            string                 `yaml:"init_profile"`             // Профиль вместо init_commands (пусто - по префиксу VIN)
+	InitAttempts           int                    `yaml:"init_attempts"`            // Попыток на каждую команду инициализации (0 - 3)
+	ResetAfterErrors       int                    `yaml:"reset_after_errors"`       // Таймаутов или ошибок адаптера подряд до сброса ATZ (0 - 3)
+	CloneQuirks            bool                   `yaml:"clone_quirks"`             // Совместимость с клонами ELM327 v1.5: эхо, "?" на AT команды, лишние приглашения
+	AdaptiveTiming         string                 `yaml:"adaptive_timing"`          // Адаптивный таймаут ELM327: off, normal, aggressive (пусто - не менять)
+	ResponseTimeout        time.Duration          `yaml:"response_timeout"`         // Таймаут ответа ЭБУ, ATST (0 - не менять, до 1044 мс)
+	KeepAliveInterval      time.Duration          `yaml:"keep_alive_interval"`      // Период сообщений поддержания сессии K-line, ATSW (0 - не менять, до 5,1 с)
+	KeepAliveMessage       string                 `yaml:"keep_alive_message"`       // Сообщение поддержания сессии K-line, ATWM (пусто - не менять)
+	Protocol               string                 `yaml:"protocol"`                 // Протокол шины ELM327: auto, 1-9 или A-C (пусто - из init_commands)
+	ProtocolFallback       []string               `yaml:"protocol_fallback"`        // Протоколы, пробуемые через ATTP, если закрепленный не отвечает
+	Retry                  RetryConfig            `yaml:"retry"`                    // Повтор запросов OBD при таймауте и NO DATA
+	StatsInterval          time.Duration          `yaml:"stats_interval"`           // Период публикации статистики обмена (0 - не публиковать)
+	LinkQualityInterval    time.Duration          `yaml:"link_quality_interval"`    // Период публикации RSSI и качества канала Bluetooth (0 - не публиковать)
+	ReadOnly               bool                   `yaml:"-"`                        // Режим только чтения (задается глобальным read_only)
 }
 
 // DefaultConfig возвращает конфигурацию по умолчанию
 func DefaultConfig() Config {
 	return Config{
-		DevicePath:        "/dev/rfcomm0",
-		ReconnectInterval: 5 * time.Second,
-		ConnectTimeout:    10 * time.Second,
-		ReadTimeout:       3 * time.Second,
-		WriteTimeout:      1 * time.Second,
+		DevicePath:             "/dev/rfcomm0",
+		ReconnectInterval:      5 * time.Second,
+		ConnectTimeout:         10 * time.Second,
+		ReadTimeout:            3 * time.Second,
+		WriteTimeout:           1 * time.Second,
+		HeartbeatInterval:      30 * time.Second,
+		StatsInterval:          time.Minute,
+		LinkQualityInterval:    30 * time.Second,
+		PartialResponseTimeout: time.Second,
 		InitCommands: []string{
 			"ATZ",   // Полный сброс
 			"ATE0",  // Отключить эхо
@@ -45,27 +108,156 @@ func DefaultConfig() Config {
 // Adapter представляет Bluetooth адаптер для работы с ELM327
 type Adapter struct {
 	config        Config
-	conn          io.ReadWriteCloser
-	connMutex     sync.RWMutex
-	responsesChan chan<- string  // Канал для отправки ответов (только для записи)
-	commandsChan  <-chan string  // Канал для получения команд (только для чтения)
-	stopChan      chan struct{}  // Канал для graceful shutdown
-	wg            sync.WaitGroup // WaitGroup для синхронизации горутин
+	transport     Transport                    // Канал связи с адаптером
+	connections   atomic.Uint64                // Номер текущего соединения (сбрасывает сборку ответа)
+	responsesChan chan<- string                // Канал для отправки ответов (только для записи)
+	commandsChan  <-chan string                // Канал для получения команд (только для чтения)
+	priorityChan  <-chan string                // Команды клиентов, выполняемые раньше команд опроса (nil - нет)
+	ctx           context.Context              // Время работы адаптера: отменяется при остановке
+	cancel        context.CancelFunc           // Отменяет ctx
+	wg            sync.WaitGroup               // WaitGroup для синхронизации горутин
+	active        atomic.Bool                  // Разрешено ли подключение к адаптеру (false в резервном режиме)
+	pending       pendingQueue                 // Отправленные команды, ожидающие ответа
+	requests      *common.PendingRequests      // Запросы клиентов MQTT (nil - сопоставление выключено)
+	promptChan    chan struct{}                // Сигнал о получении приглашения ELM327
+	connectChan   chan struct{}                // Сигнал об установленном соединении для цикла чтения
+	readyChan     chan struct{}                // Сигнал о завершении инициализации ELM327 или закрытии соединения
+	deviceEvents  <-chan bool                  // Появление и исчезновение device_path (nil - не отслеживается)
+	searchChan    chan struct{}                // Сигнал о начале определения протокола
+	overflowChan  chan struct{}                // Сигнал о переполнении буфера ответа
+	reinitChan    chan struct{}                // Запрос повторной инициализации ELM327
+	monitoring    atomic.Bool                  // Адаптер в режиме мониторинга шины (ATMA)
+	lastActivity  atomic.Int64                 // Время последних полученных данных (UnixNano)
+	initialized   atomic.Bool                  // Инициализация ELM327 на текущем соединении завершена
+	sleeping      atomic.Bool                  // Адаптер переведен в режим пониженного энергопотребления (ATLP)
+	noData        atomic.Bool                  // Последняя попытка с повтором получила NO DATA
+	errorStreak   atomic.Int32                 // Таймаутов и ошибок адаптера подряд
+	resetNeeded   atomic.Bool                  // Адаптер нужно сбросить и инициализировать заново
+	framing       atomic.Pointer[framing]      // Завершение команд и признак конца ответа
+	probe         atomic.Pointer[framingProbe] // Незавершенный ответ при инициализации (автоопределение)
+	statusChan    chan<- common.StatusEvent    // Служебные события моста (nil - не публикуются)
+	stateMu       sync.Mutex                   // Защищает connState
+	connState     string                       // Последнее опубликованное состояние связи
+	stats         adapterStats                 // Счетчики обмена с адаптером
+	rfcommBound   bool                         // Привязка device_path создана мостом и снимается при остановке
+
+	readLinkQuality func(address string) (LinkQuality, error) // Запрос качества канала у контроллера
+
+	atHandler  func(command, response string) // Обработчик ответов на AT команды
+	rawHandler func(command, response string) // Обработчик всех сырых ответов адаптера
+	onConnect  func()                         // Вызывается после инициализации ELM327
+	onMonitor  func(line string)              // Обработчик строк режима мониторинга шины
+	onTimeout  func(command string)           // Вызывается для команды, оставшейся без ответа
 }
 
 // NewAdapter создает новый Bluetooth адаптер
 func NewAdapter(config Config, responsesChan chan<- string, commandsChan <-chan string) *Adapter {
-	return &Adapter{
+	a := &Adapter{
 		config:        config,
 		responsesChan: responsesChan,
 		commandsChan:  commandsChan,
-		stopChan:      make(chan struct{}),
+		promptChan:    make(chan struct{}, 1),
+		connectChan:   make(chan struct{}, 1),
+		readyChan:     make(chan struct{}, 1),
+		searchChan:    make(chan struct{}, 1),
+		overflowChan:  make(chan struct{}, 1),
+		reinitChan:    make(chan struct{}, 1),
+
+		readLinkQuality: readLinkQuality,
+	}
+	a.ctx, a.cancel = context.WithCancel(context.Background())
+	a.transport = &statsTransport{Transport: NewTransport(config), stats: &a.stats}
+	a.active.Store(true)
+	a.framing.Store(newFraming(config))
+	return a
+}
+
+// SetATResponseHandler задает обработчик ответов на AT команды (вызывать до Start).
+// Ответы на AT команды не попадают в канал ответов OBD
+func (a *Adapter) SetATResponseHandler(handler func(command, response string)) {
+	a.atHandler = handler
+}
+
+// SetRawResponseHandler задает обработчик, получающий каждый ответ адаптера без разбора,
+// например для публикации в устаревшем формате (вызывать до Start, не должен блокироваться)
+func (a *Adapter) SetRawResponseHandler(handler func(command, response string)) {
+	a.rawHandler = handler
+}
+
+// SetConnectHandler задает обработчик, вызываемый после каждого успешного подключения
+// и инициализации ELM327 (вызывать до Start, не должен блокироваться)
+func (a *Adapter) SetConnectHandler(handler func()) {
+	a.onConnect = handler
+}
+
+// SetMonitorHandler задает обработчик строк, которые адаптер выводит в режиме мониторинга
+// шины (ATMA). Строки передаются по одной по мере получения, а не одним ответом
+// (вызывать до Start, не должен блокироваться)
+func (a *Adapter) SetMonitorHandler(handler func(line string)) {
+	a.onMonitor = handler
+}
+
+// SetTimeoutHandler задает обработчик команд, на которые адаптер не ответил за read_timeout
+// (вызывать до Start, не должен блокироваться)
+func (a *Adapter) SetTimeoutHandler(handler func(command string)) {
+	a.onTimeout = handler
+}
+
+// SetPendingRequests задает реестр запросов, по которому ответы сопоставляются
+// с командами клиентов MQTT (вызывать до Start)
+func (a *Adapter) SetPendingRequests(requests *common.PendingRequests) {
+	a.requests = requests
+}
+
+// SetPriorityCommands задает канал команд с высоким приоритетом, например команд клиентов
+// MQTT: они выполняются раньше накопившихся в основном канале команд опроса (вызывать до Start)
+func (a *Adapter) SetPriorityCommands(priorityChan <-chan string) {
+	a.priorityChan = priorityChan
+}
+
+// SetTransport задает транспорт вместо созданного по конфигурации, например
+// симулятор ELM327 в нагрузочных тестах (вызывать до Start)
+func (a *Adapter) SetTransport(transport Transport) {
+	a.transport = &statsTransport{Transport: transport, stats: &a.stats}
+}
+
+// SetActive разрешает или запрещает подключение к адаптеру.
+// В резервном режиме соединение закрывается, чтобы адаптер мог занять другой мост
+func (a *Adapter) SetActive(active bool) {
+	if a.active.Swap(active) == active {
+		return
+	}
+
+	if active {
+		logger.Println("Bridge is active, connecting to adapter")
+		return
 	}
+
+	logger.Println("Bridge is in standby, releasing adapter")
+	if a.isConnected() {
+		a.closeConnection("bridge is in standby")
+	}
+}
+
+// Reinitialize запрашивает повторную инициализацию ELM327 на текущем соединении
+// (например, после запуска двигателя). Выполняется циклом записи между командами
+func (a *Adapter) Reinitialize() {
+	notify(a.reinitChan)
 }
 
-// Start запускает работу адаптера
-func (a *Adapter) Start() error {
-	logger.Printf("Starting Bluetooth adapter with device: %s", a.config.DevicePath)
+// Start запускает работу адаптера. ctx ограничивает только запуск (ожидание узла
+// device_path после привязки rfcomm): подключение и обмен продолжаются до Stop,
+// который прерывает и незавершенное подключение
+func (a *Adapter) Start(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	logger.Printf("Starting Bluetooth adapter with %s transport", transportType(a.config))
+	if err := a.bindRFCOMM(ctx); err != nil {
+		a.releaseRFCOMM()
+		return err
+	}
+	a.startDeviceWatch()
 
 	// Запускаем горутину для чтения данных
 	a.wg.Add(1)
@@ -79,21 +271,34 @@ func (a *Adapter) Start() error {
 	a.wg.Add(1)
 	go a.reconnectLoop()
 
+	// Статистика обмена публикуется, если задан канал служебных событий
+	if a.statusChan != nil && a.config.StatsInterval > 0 {
+		a.wg.Add(1)
+		go a.statsLoop()
+	}
+
+	// Качество канала доступно только для соединений Bluetooth Classic с известным адресом
+	if address := linkQualityAddress(a.config); a.statusChan != nil && a.config.LinkQualityInterval > 0 && address != "" {
+		a.wg.Add(1)
+		go a.linkQualityLoop(address)
+	}
+
 	return nil
 }
 
-// Stop останавливает работу адаптера
+// Stop останавливает работу адаптера. Соединение закрывается до ожидания горутин:
+// чтение из порта или сокета без данных блокируется (неблокирующий дескриптор ждет
+// готовности в поллере Go, и VTIME не действует), а закрытие его прерывает.
+// Повторное закрытие после остановки освобождает соединение, открытое циклом
+// переподключения во время остановки
 func (a *Adapter) Stop() error {
 	logger.Println("Stopping Bluetooth adapter...")
-	close(a.stopChan)
+	a.cancel()
+	a.transport.Close()
 	a.wg.Wait()
 
-	a.connMutex.Lock()
-	if a.conn != nil {
-		a.conn.Close()
-		a.conn = nil
-	}
-	a.connMutex.Unlock()
+	a.transport.Close()
+	a.releaseRFCOMM()
 
 	logger.Println("Bluetooth adapter stopped")
 	return nil
@@ -101,203 +306,730 @@ func (a *Adapter) Stop() error {
 
 // isConnected проверяет, подключен ли адаптер
 func (a *Adapter) isConnected() bool {
-	a.connMutex.RLock()
-	defer a.connMutex.RUnlock()
-	return a.conn != nil
+	return a.transport.Connected()
 }
 
-// setConnection устанавливает соединение
-func (a *Adapter) setConnection(conn io.ReadWriteCloser) {
-	a.connMutex.Lock()
-	a.conn = conn
-	a.connMutex.Unlock()
-	logger.Println("Bluetooth connection established")
-}
-
-// getConnection получает соединение
-func (a *Adapter) getConnection() io.ReadWriteCloser {
-	a.connMutex.RLock()
-	defer a.connMutex.RUnlock()
-	return a.conn
-}
-
-// closeConnection закрывает текущее соединение
-func (a *Adapter) closeConnection() {
-	a.connMutex.Lock()
-	if a.conn != nil {
-		a.conn.Close()
-		a.conn = nil
-	}
-	a.connMutex.Unlock()
+// closeConnection закрывает текущее соединение, reason публикуется в состоянии связи
+func (a *Adapter) closeConnection(reason string) {
+	a.transport.Close()
+	a.pending.reset()
+	a.monitoring.Store(false)
+	a.initialized.Store(false)
+	a.sleeping.Store(false)
+	notify(a.readyChan)
 	logger.Println("Bluetooth connection closed")
+	a.setConnectionState(ConnectionDisconnected, reason)
 }
 
 // connect устанавливает соединение с устройством
 func (a *Adapter) connect() error {
-	logger.Printf("Attempting to connect to %s", a.config.DevicePath)
-
-	// Проверяем, существует ли устройство
-	if _, err := os.Stat(a.config.DevicePath); os.IsNotExist(err) {
-		return fmt.Errorf("device %s does not exist. Please run 'sudo rfcomm bind' first", a.config.DevicePath)
+	a.setConnectionState(ConnectionReconnecting, "")
+	if err := a.transport.Connect(a.ctx); err != nil {
+		a.setConnectionState(ConnectionDisconnected, err.Error())
+		return err
 	}
-
-	// Открываем устройство
-	file, err := os.OpenFile(a.config.DevicePath, os.O_RDWR|unix.O_NOCTTY|os.O_SYNC, 0666)
-	if err != nil {
-		return fmt.Errorf("failed to open %s: %v", a.config.DevicePath, err)
-	}
-
-	// Устанавливаем соединение
-	a.setConnection(file)
+	a.connections.Add(1)
+	a.touch()
+	notify(a.connectChan)
+	logger.Println("Bluetooth connection established")
 
 	// Выполняем инициализацию ELM327
 	if err := a.initializeELM327(); err != nil {
-		a.closeConnection()
-		return fmt.Errorf("failed to initialize ELM327: %v", err)
+		err = fmt.Errorf("failed to initialize ELM327: %v", err)
+		a.closeConnection(err.Error())
+		return err
 	}
+	a.initialized.Store(true)
+	notify(a.readyChan)
+	a.setConnectionState(ConnectionConnected, "")
 
+	if a.onConnect != nil {
+		a.onConnect()
+	}
 	return nil
 }
 
 // initializeELM327 выполняет инициализацию ELM327 после подключения
 func (a *Adapter) initializeELM327() error {
-	conn := a.getConnection()
-	if conn == nil {
+	if !a.isConnected() {
 		return fmt.Errorf("no connection available for initialization")
 	}
 
 	logger.Println("Initializing ELM327...")
+	a.errorStreak.Store(0)
+	a.resetNeeded.Store(false)
 
 	// Небольшая пауза после подключения
 	time.Sleep(500 * time.Millisecond)
 
-	// Отправляем команды инициализации последовательно
-	for i, cmd := range a.config.InitCommands {
-		logger.Printf("Sending init command %d/%d: %s", i+1, len(a.config.InitCommands), cmd)
-
-		cmdBytes := []byte(cmd + "\r")
-		if _, err := conn.Write(cmdBytes); err != nil {
-			return fmt.Errorf("failed to send command %s: %v", cmd, err)
+	// Отправляем команды инициализации последовательно, проверяя ответ на каждую
+	commands := append(append([]string{}, a.config.InitCommands...), timingCommands(a.config)...)
+	commands = append(commands, protocolCommands(a.config)...)
+	for i, cmd := range commands {
+		if err := a.checkReadOnly(cmd); err != nil {
+			logger.Printf("Skipping init command: %v", err)
+			continue
 		}
 
-		// Ждем ответ на команду инициализации
-		time.Sleep(200 * time.Millisecond)
+		logger.Printf("Sending init command %d/%d: %s", i+1, len(commands), cmd)
 
-		// Читаем ответ
-		response := make([]byte, 128)
-		n, err := conn.Read(response)
+		response, err := a.initStep(cmd)
 		if err != nil {
-			logger.Printf("Warning: No response to %s (err: %v). Continuing...", cmd, err)
-		} else if n > 0 {
-			respStr := string(response[:n])
-			logger.Printf("Response to %s: %q", cmd, respStr)
+			return err
 		}
+		logger.Printf("Response to %s: %q", cmd, response)
 	}
 
 	logger.Println("ELM327 initialization completed")
 	return nil
 }
 
+// initStep отправляет команду инициализации и проверяет ответ, повторяя ее при таймауте
+// или неожиданном ответе. Адаптер, так и не ответивший на сброс, считается неисправным:
+// ошибка прерывает подключение
+func (a *Adapter) initStep(command string) (string, error) {
+	attempts := a.config.InitAttempts
+	if attempts <= 0 {
+		attempts = defaultInitAttempts
+	}
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			logger.Printf("Retrying init command %s (attempt %d/%d) after: %v", command, attempt, attempts, lastErr)
+			select {
+			case <-time.After(initRetryDelay):
+			case <-a.ctx.Done():
+				return "", fmt.Errorf("adapter stopped while initializing")
+			}
+			a.pending.reset()
+		}
+
+		response, err := a.sendAndWait(a.transport, command, a.config.resetTimeout(command))
+		answered := err == nil
+		if err == nil {
+			err = validateInitResponse(command, response)
+		}
+		if a.config.tolerateInitError(command, err) {
+			logger.Printf("Warning: clone adapter rejected %s, continuing initialization", command)
+			return response, nil
+		}
+		if err == nil {
+			if a.config.CloneQuirks && isResetCommand(command) {
+				// Клон после ответа на сброс еще не готов принимать команды
+				select {
+				case <-time.After(cloneResetSettle):
+				case <-a.ctx.Done():
+					return "", fmt.Errorf("adapter stopped while initializing")
+				}
+			}
+			return response, nil
+		}
+		lastErr = err
+
+		// Соединение потеряно: повтор не поможет, переподключение выполнит цикл
+		if !a.isConnected() {
+			break
+		}
+
+		// Ответ не завершился: возможно, адаптер ждет другое завершение команды
+		// или использует другое приглашение
+		if !answered {
+			a.detectFraming(command)
+		}
+	}
+
+	if isResetCommand(command) {
+		return "", fmt.Errorf("ELM327 did not answer %s: %v", command, lastErr)
+	}
+	return "", lastErr
+}
+
+// isResetCommand проверяет, является ли команда сбросом адаптера
+func isResetCommand(command string) bool {
+	cmd := strings.ToUpper(strings.Join(strings.Fields(command), ""))
+	return cmd == "ATZ" || cmd == "ATWS"
+}
+
+// sendAndWait отправляет команду и ожидает ответ на нее, минуя маршрутизацию ответов
+func (a *Adapter) sendAndWait(conn io.Writer, command string, timeout time.Duration) (string, error) {
+	reply := make(chan string, 1)
+	a.pending.push(command, reply, nil)
+
+	if _, err := conn.Write([]byte(command + a.currentFraming().terminator)); err != nil {
+		a.pending.remove(reply)
+		return "", fmt.Errorf("failed to send command %s: %v", command, err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case response := <-reply:
+			return response, nil
+		case <-a.searchChan:
+			// Запрос к шине вызвал определение протокола, ответ задерживается
+			timer.Reset(searchTimeout)
+			timeout = searchTimeout
+		case <-timer.C:
+			a.pending.remove(reply)
+			a.stats.timeouts.Add(1)
+			return "", fmt.Errorf("no response to %s within %v", command, timeout)
+		case <-a.ctx.Done():
+			a.pending.remove(reply)
+			return "", fmt.Errorf("adapter stopped while waiting for %s", command)
+		}
+	}
+}
+
+// informationalATCommands содержит AT команды, возвращающие данные вместо "OK"
+var informationalATCommands = map[string]bool{
+	"AT@1":  true, // Описание устройства
+	"AT@2":  true, // Идентификатор устройства
+	"ATRV":  true, // Напряжение
+	"ATDP":  true, // Текущий протокол
+	"ATDPN": true, // Номер текущего протокола
+	"ATCS":  true, // Статистика CAN
+	"ATIGN": true, // Состояние зажигания
+	"ATPPS": true, // Программируемые параметры
+}
+
+// validateInitResponse проверяет ответ адаптера на команду инициализации
+func validateInitResponse(command, response string) error {
+	if strings.Contains(response, "?") {
+		return fmt.Errorf("%w %s: %q", errInitRejected, command, response)
+	}
+
+	cmd := strings.ToUpper(strings.Join(strings.Fields(command), ""))
+	switch {
+	case isResetCommand(cmd) || cmd == "ATI":
+		// Сброс возвращает строку идентификации вида "ELM327 v1.5"
+		if !strings.Contains(strings.ToUpper(response), "ELM") {
+			return fmt.Errorf("unexpected identification after %s: %q", command, response)
+		}
+	case informationalATCommands[cmd] || !strings.HasPrefix(cmd, "AT"):
+		// Информационные запросы и команды STN возвращают данные, а не "OK"
+	default:
+		if !strings.Contains(strings.ToUpper(response), "OK") {
+			return fmt.Errorf("unexpected response to %s: %q", command, response)
+		}
+	}
+	return nil
+}
+
+// dispatchResponse направляет ответ ожидающему отправителю, обработчику AT команд или парсеру OBD
+func (a *Adapter) dispatchResponse(response string) {
+	pending, ok := a.pending.pop()
+	if ok {
+		a.stats.observeRoundTrip(time.Since(pending.sent))
+		if a.config.CloneQuirks {
+			response = stripEcho(pending.command, response)
+		}
+	}
+
+	if a.rawHandler != nil {
+		a.rawHandler(pending.command, response)
+	}
+
+	if ok && pending.reply != nil {
+		pending.reply <- response
+		return
+	}
+	if ok && !isATCommand(pending.command) {
+		a.recordResult(isAdapterError(response))
+	}
+
+	// NO DATA на попытку с последующим повтором не передается парсеру и клиенту
+	if ok && pending.retry && isNoDataResponse(response) {
+		logger.Printf("NO DATA for %s, command will be retried", pending.command)
+		a.noData.Store(true)
+		return
+	}
+
+	if ok && isATCommand(pending.command) {
+		logger.Printf("AT response to %s: %q", pending.command, response)
+		if a.atHandler != nil {
+			a.atHandler(pending.command, response)
+		}
+		// Ответ на AT команду не разбирается парсером и сразу возвращается клиенту
+		a.requests.Finish(pending.request, response, nil)
+		return
+	}
+
+	// Связываем ответ с запросом клиента до передачи парсеру
+	a.requests.Bind(pending.request, response)
+
+	// Отправляем ответ в канал (неблокирующе)
+	select {
+	case a.responsesChan <- response:
+		// Ответ отправлен успешно
+	default:
+		logger.Printf("Warning: responses channel is full, dropping response: %q", response)
+		a.requests.Finish(pending.request, nil, fmt.Errorf("response to %s dropped: responses channel is full", pending.command))
+	}
+}
+
 // readLoop читает данные из Bluetooth соединения
 func (a *Adapter) readLoop() {
 	defer a.wg.Done()
 	logger.Println("Starting Bluetooth read loop")
 
+	// Буфер и сборщик ответа живут все время соединения: байты, пришедшие после
+	// приглашения, относятся к следующему ответу и не должны теряться
+	var assembler responseAssembler
+	var monitor monitorSplitter
+	var current uint64
+	var searching bool
+	var applied *framing
+	buf := make([]byte, 256)
+
+	// Ответ адаптера без приглашения завершает пауза: таймер работает со сборщиком
+	// из своей горутины, поэтому сборщик защищен мьютексом. Та же пауза, но длиннее,
+	// завершает ответ адаптера, потерявшего приглашение после части данных
+	var mu sync.Mutex
+	idle := time.AfterFunc(time.Hour, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		response, ok := assembler.Flush()
+		if !ok {
+			return
+		}
+		if !applied.noPrompt {
+			logger.Printf("No prompt from ELM327 after %v of silence, finalizing partial response", a.config.partialResponseTimeout())
+			a.stats.partialResponses.Add(1)
+		}
+		a.deliverResponse(response)
+	})
+	idle.Stop()
+	defer idle.Stop()
+
 	for {
 		select {
-		case <-a.stopChan:
+		case <-a.ctx.Done():
 			logger.Println("Read loop stopped")
 			return
 		default:
 		}
 
-		conn := a.getConnection()
-		if conn == nil {
-			time.Sleep(a.config.ReconnectInterval)
+		if !a.isConnected() {
+			a.waitForConnection()
 			continue
 		}
+		if connection := a.connections.Load(); connection != current {
+			current = connection
+			mu.Lock()
+			assembler.Reset()
+			monitor.Reset()
+			mu.Unlock()
+			searching = false
+		}
 
-		// Создаем reader с таймаутом
-		reader := bufio.NewReader(conn)
-
-		// Читаем до символа '>' (конец ответа ELM327)
-		data, err := reader.ReadBytes('>')
+		n, err := a.transport.Read(buf)
 		if err != nil {
+			// Ошибка чтения соединения, закрытого при остановке, - штатное завершение
+			select {
+			case <-a.ctx.Done():
+				logger.Println("Read loop stopped")
+				return
+			default:
+			}
 			logger.Printf("Read error: %v", err)
-			a.closeConnection()
-			time.Sleep(a.config.ReconnectInterval)
+			a.closeConnection(fmt.Sprintf("read error: %v", err))
+			a.waitForConnection()
+			continue
+		}
+		if n == 0 {
+			// Таймаут чтения линии без данных
 			continue
 		}
 
-		// Удаляем trailing '>' если есть
-		response := string(data)
-		if len(response) > 0 && response[len(response)-1] == '>' {
-			response = response[:len(response)-1]
+		a.touch()
+		data := buf[:n]
+
+		mu.Lock()
+		// Автоопределение сменило завершение ответа: данные прежнего цикла отбрасываются
+		if f := a.currentFraming(); f != applied {
+			applied = f
+			assembler.Reset()
+			monitor.Reset()
+			assembler.prompt, assembler.noPrompt = f.prompt, f.noPrompt
+			monitor.prompt, monitor.noPrompt = f.prompt, f.noPrompt
 		}
 
-		// Убираем лишние пробелы
-		response = fmt.Sprintf("%s", response)
+		if a.monitoring.Load() {
+			lines, rest, stopped := monitor.Feed(data)
+			for _, line := range lines {
+				if a.onMonitor != nil {
+					a.onMonitor(line)
+				}
+			}
+			if !stopped {
+				mu.Unlock()
+				continue
+			}
+			// Приглашение завершает мониторинг и ответ на команду мониторинга
+			a.monitoring.Store(false)
+			logger.Println("ELM327 monitor mode stopped")
+			data = rest
+		}
 
-		logger.Printf("Received from ELM327: %q", response)
+		for _, response := range assembler.Feed(data) {
+			// Клон выводит лишнее приглашение: пустой ответ завершил бы следующую команду
+			if response == "" && a.config.CloneQuirks {
+				continue
+			}
+			a.deliverResponse(response)
+		}
+		// Ответ без приглашения не растет бесконечно: накопленное отбрасывается
+		if size := assembler.Size() + monitor.Size(); size > a.config.maxResponseSize() {
+			assembler.Reset()
+			monitor.Reset()
+			searching = false
+			idle.Stop()
+			a.onOverflow(size)
+		}
+		if assembler.Pending() && !assembler.Searching() {
+			switch partial := a.config.partialResponseTimeout(); {
+			case applied.noPrompt:
+				idle.Reset(promptIdleTimeout)
+			case partial > 0 && a.initialized.Load():
+				// При инициализации незавершенный ответ нужен автоопределению приглашения
+				idle.Reset(partial)
+			}
+		} else if !applied.noPrompt {
+			idle.Stop()
+		}
+		if !a.initialized.Load() {
+			a.probe.Store(assembler.probe())
+		}
 
-		// Отправляем ответ в канал (неблокирующе)
-		select {
-		case a.responsesChan <- response:
-			// Ответ отправлен успешно
-		default:
-			logger.Printf("Warning: responses channel is full, dropping response: %q", response)
+		// Ответ после "SEARCHING..." придет в том же цикле: продлеваем ожидание отправителя
+		if assembler.Searching() && !searching {
+			logger.Println("ELM327 is searching for protocol, waiting for the answer")
+			notify(a.searchChan)
 		}
+		searching = assembler.Searching()
+		mu.Unlock()
 	}
 }
 
+// deliverResponse передает собранный ответ и сообщает отправителю о приглашении
+func (a *Adapter) deliverResponse(response string) {
+	logger.Printf("Received from ELM327: %q", response)
+	a.dispatchResponse(response)
+	notify(a.promptChan)
+}
+
 // writeLoop отправляет команды в Bluetooth соединение
 func (a *Adapter) writeLoop() {
 	defer a.wg.Done()
 	logger.Println("Starting Bluetooth write loop")
 
+	var heartbeat <-chan time.Time
+	if a.config.HeartbeatInterval > 0 {
+		ticker := time.NewTicker(a.config.HeartbeatInterval)
+		defer ticker.Stop()
+		heartbeat = ticker.C
+	}
+
+	priority := a.priorityChan
 	for {
+		// Команда клиента не ждет в очереди за командами опроса
+		select {
+		case command, ok := <-priority:
+			if !ok {
+				logger.Println("Priority commands channel closed")
+				priority = nil
+				continue
+			}
+			a.execute(command)
+			continue
+		default:
+		}
+
 		select {
-		case <-a.stopChan:
+		case <-a.ctx.Done():
 			logger.Println("Write loop stopped")
 			return
+		case command, ok := <-priority:
+			if !ok {
+				logger.Println("Priority commands channel closed")
+				priority = nil
+				continue
+			}
+			a.execute(command)
+		case <-heartbeat:
+			a.checkHeartbeat()
+		case <-a.overflowChan:
+			// Буфер переполнился без ожидающих команд: адаптер вещает сам по себе
+			logger.Println("ELM327 is flooding without prompt, reinitializing")
+			a.reinitialize()
+		case <-a.reinitChan:
+			logger.Println("Reinitializing ELM327 on request")
+			a.reinitialize()
 		case command, ok := <-a.commandsChan:
 			if !ok {
 				logger.Println("Commands channel closed")
 				return
 			}
+			a.execute(command)
+		}
+	}
+}
 
-			conn := a.getConnection()
-			if conn == nil {
-				logger.Printf("Cannot send command %q: no connection", command)
-				continue
-			}
+// execute отправляет команду и ждет приглашения на нее. Запрос OBD, оставшийся без ответа
+// или получивший NO DATA, повторяется по политике retry с растущей паузой; клиент MQTT
+// получает результат только последней попытки
+func (a *Adapter) execute(command string) {
+	// Последний рубеж защиты: команды управления не доходят до адаптера
+	if err := a.checkReadOnly(command); err != nil {
+		logger.Printf("Dropping command: %v", err)
+		a.requests.Finish(a.requests.Claim(command), nil, err)
+		return
+	}
 
-			logger.Printf("Sending command to ELM327: %q", command)
+	// Инициализация после подключения выполняется циклом переподключения: команда,
+	// отправленная посреди нее, прервала бы ее и получила бы чужой ответ
+	a.waitInitialized()
 
-			// Добавляем символ возврата каретки
-			cmdBytes := []byte(command + "\r")
+	// Режим мониторинга шины длится до получения любого символа.
+	// Приглашение после прерывания завершает команду мониторинга
+	a.interruptMonitor()
+	a.waitForPrompt()
+	if a.takeReset() {
+		a.reinitialize()
+	}
 
-			// TODO: Установить таймаут на запись при использовании net.Conn вместо io.ReadWriteCloser
-			_, err := conn.Write(cmdBytes)
-			if err != nil {
-				logger.Printf("Write error: %v", err)
-				a.closeConnection()
-				continue
+	// Уснувший после ATLP адаптер будится перед любой командой
+	if a.sleeping.Load() && !isLowPowerCommand(command) {
+		a.wake()
+	}
+
+	request := a.requests.Claim(command)
+	attempts := a.config.Retry.attempts(command)
+
+	for attempt := 1; ; attempt++ {
+		retry := attempt < attempts
+		if !a.send(command, request, retry) {
+			return
+		}
+
+		// Мониторинг шины завершается приглашением только после прерывания
+		if isMonitorCommand(command) {
+			return
+		}
+
+		// ELM327 прерывает текущий запрос при получении любого символа, поэтому
+		// следующая команда отправляется только после приглашения на эту. Адаптер,
+		// раз за разом не отвечающий без закрытия соединения, сбрасывается
+		answered := a.waitForPrompt()
+		if a.takeReset() {
+			a.reinitialize()
+		}
+		noData := a.noData.Swap(false)
+		if !retry || (answered && !noData) {
+			return
+		}
+
+		delay := a.config.Retry.delay(attempt)
+		logger.Printf("Retrying %s in %v (attempt %d/%d)", command, delay, attempt+1, attempts)
+		select {
+		case <-time.After(delay):
+		case <-a.ctx.Done():
+			a.requests.Finish(request, nil, fmt.Errorf("adapter stopped before retrying %s", command))
+			return
+		}
+	}
+}
+
+// send записывает команду в соединение. retry отмечает попытку, после которой будет
+// повтор: ее таймаут и ответ NO DATA не завершают запрос клиента.
+// Возвращает false, если команду не удалось отправить
+func (a *Adapter) send(command string, request *common.PendingRequest, retry bool) bool {
+	if !a.isConnected() {
+		logger.Printf("Cannot send command %q: no connection", command)
+		a.requests.Finish(request, nil, fmt.Errorf("cannot send command %s: adapter is not connected", command))
+		return false
+	}
+
+	logger.Printf("Sending command to ELM327: %q", command)
+
+	// Добавляем завершение команды (обычно возврат каретки)
+	cmdBytes := []byte(command + a.currentFraming().terminator)
+	a.pending.pushRetry(command, request, retry)
+
+	// Кадры мониторинга начнут приходить сразу после команды
+	if isMonitorCommand(command) {
+		a.monitoring.Store(true)
+	}
+
+	// Зависшая запись прерывается по write_timeout, соединение восстанавливается
+	if _, err := a.transport.Write(cmdBytes); err != nil {
+		logger.Printf("Write error: %v", err)
+		a.closeConnection(fmt.Sprintf("write error: %v", err))
+		a.requests.Finish(request, nil, fmt.Errorf("failed to send command %s: %v", command, err))
+		return false
+	}
+
+	// После ATLP адаптер засыпает и не отвечает на проверку связи
+	if isLowPowerCommand(command) {
+		a.sleeping.Store(true)
+	}
+
+	logger.Printf("Command sent successfully: %q", command)
+	return true
+}
+
+// waitForPrompt ждет приглашения на отправленные команды. Пока адаптер определяет
+// протокол, ожидание продлевается до searchTimeout: новая команда прервала бы поиск,
+// и первые ответы после включения зажигания были бы потеряны. Команды, не получившие
+// ответа за read_timeout, завершаются ошибкой у клиента и передаются обработчику
+// таймаутов. Возвращает false при таймауте или переполнении буфера ответа
+func (a *Adapter) waitForPrompt() bool {
+	if a.pending.len() == 0 {
+		return true
+	}
+
+	timeout := time.NewTimer(a.config.ReadTimeout)
+	defer timeout.Stop()
+	wait := a.config.ReadTimeout
+
+	for a.pending.len() > 0 {
+		select {
+		case <-a.promptChan:
+		case <-a.searchChan:
+			if wait != searchTimeout {
+				wait = searchTimeout
+				timeout.Reset(searchTimeout)
 			}
+		case <-timeout.C:
+			a.expirePending(wait)
+			return false
+		case <-a.overflowChan:
+			a.abortPending()
+			return false
+		case <-a.ctx.Done():
+			return true
+		}
+	}
+	return true
+}
+
+// expirePending сбрасывает команды, оставшиеся без ответа за wait, и сообщает о таймауте.
+// Команды синхронных отправителей остаются в очереди: их таймаут (например, сброса
+// адаптера при инициализации) длиннее read_timeout и отсчитывается самим отправителем
+func (a *Adapter) expirePending(wait time.Duration) {
+	dropped := a.pending.drainRouted()
+	a.stats.timeouts.Add(uint64(len(dropped)))
+	logger.Printf("No prompt from ELM327 within %v, dropping %d pending command(s)", wait, len(dropped))
+	a.recordResult(true)
 
-			logger.Printf("Command sent successfully: %q", command)
+	for _, item := range dropped {
+		if a.onTimeout != nil {
+			a.onTimeout(item.command)
+		}
+		// Запрос клиента завершается после последней попытки
+		if !item.retry {
+			a.requests.Finish(item.request, nil, fmt.Errorf("no response to %s within %v", item.command, wait))
 		}
 	}
 }
 
+// reinitialize повторяет инициализацию ELM327 на текущем соединении. Если адаптер не
+// отвечает и на нее, соединение закрывается и восстанавливается циклом переподключения
+func (a *Adapter) reinitialize() {
+	// Инициализация после подключения еще идет: повторная выполняется после нее
+	a.waitInitialized()
+	if !a.isConnected() {
+		return
+	}
+
+	a.monitoring.Store(false)
+	a.initialized.Store(false)
+	// Сигнал о переполнении до повторной инициализации устарел
+	select {
+	case <-a.overflowChan:
+	default:
+	}
+	if err := a.initializeELM327(); err != nil {
+		logger.Printf("Reinitialization failed: %v", err)
+		a.closeConnection(fmt.Sprintf("reinitialization failed: %v", err))
+		return
+	}
+	a.initialized.Store(true)
+	notify(a.readyChan)
+
+	if a.onConnect != nil {
+		a.onConnect()
+	}
+}
+
+// waitInitialized ждет завершения инициализации ELM327 на установленном соединении.
+// Без соединения возвращается сразу: команда завершится ошибкой при отправке
+func (a *Adapter) waitInitialized() {
+	for a.isConnected() && !a.initialized.Load() {
+		select {
+		case <-a.readyChan:
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
+// interruptMonitor прерывает режим мониторинга шины перед отправкой следующей команды.
+// Приглашение после прерывания завершает ожидающую команду мониторинга
+func (a *Adapter) interruptMonitor() {
+	if !a.monitoring.Load() || !a.isConnected() {
+		return
+	}
+
+	logger.Println("Interrupting ELM327 monitor mode")
+	if _, err := a.transport.Write([]byte(monitorInterrupt)); err != nil {
+		logger.Printf("Write error: %v", err)
+		a.closeConnection(fmt.Sprintf("write error: %v", err))
+	}
+}
+
+// notify отправляет сигнал без блокировки (сигналы не накапливаются)
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// checkReadOnly проверяет команду в режиме только чтения.
+// Сброс адаптера разрешен: он используется при инициализации
+func (a *Adapter) checkReadOnly(command string) error {
+	if !a.config.ReadOnly {
+		return nil
+	}
+	return common.CheckReadOnly(command, false)
+}
+
+// waitForConnection ждет нового соединения, но не дольше интервала переподключения
+// (вызывается из цикла чтения): ответы на инициализацию читаются сразу после подключения
+func (a *Adapter) waitForConnection() {
+	select {
+	case <-a.connectChan:
+	case <-a.ctx.Done():
+	case <-time.After(a.config.ReconnectInterval):
+	}
+}
+
 // reconnectLoop управляет переподключением при ошибках
 func (a *Adapter) reconnectLoop() {
 	defer a.wg.Done()
 	logger.Println("Starting Bluetooth reconnect loop")
 
 	// Первая попытка подключения
-	if err := a.connect(); err != nil {
-		logger.Printf("Initial connection failed: %v", err)
+	if a.active.Load() {
+		if !a.devicePresent() {
+			logger.Printf("Device %s not present, waiting for it to appear", a.config.DevicePath)
+		} else if err := a.connect(); err != nil {
+			logger.Printf("Initial connection failed: %v", err)
+		}
 	}
 
 	ticker := time.NewTicker(a.config.ReconnectInterval)
@@ -305,11 +1037,13 @@ func (a *Adapter) reconnectLoop() {
 
 	for {
 		select {
-		case <-a.stopChan:
+		case <-a.ctx.Done():
 			logger.Println("Reconnect loop stopped")
 			return
+		case present := <-a.deviceEvents:
+			a.onDeviceEvent(present)
 		case <-ticker.C:
-			if !a.isConnected() {
+			if a.active.Load() && !a.isConnected() && a.devicePresent() {
 				logger.Println("Attempting to reconnect...")
 				if err := a.connect(); err != nil {
 					logger.Printf("Reconnection failed: %v", err)
@@ -0,0 +1,75 @@
+package bluetooth
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+	"time"
+
+	"elm327-bridge/simulator"
+)
+
+// startSimulatedAdapter запускает адаптер поверх симулятора ELM327 и ждет окончания инициализации
+func startSimulatedAdapter(b *testing.B, responsesChan chan string, commandsChan chan string) *simulator.ELM327 {
+	b.Helper()
+	SetLogOutput(io.Discard)
+
+	sim := simulator.New(nil)
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+
+	adapter := NewAdapter(config, responsesChan, commandsChan)
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return sim, nil }))
+	if err := adapter.Start(context.Background()); err != nil {
+		b.Fatalf("Failed to start adapter: %v", err)
+	}
+
+	b.Cleanup(func() {
+		// Симулятор закрывается первым: иначе цикл чтения заблокирован в Read
+		sim.Close()
+		adapter.Stop()
+		SetLogOutput(os.Stdout)
+	})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for sim.Requests() < uint64(len(config.InitCommands)) {
+		if time.Now().After(deadline) {
+			b.Fatal("Adapter initialization did not complete")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return sim
+}
+
+// BenchmarkReadLoop измеряет полный цикл запрос-ответ транспорта:
+// запись команды, сборку ответа по приглашению и маршрутизацию в канал ответов
+func BenchmarkReadLoop(b *testing.B) {
+	responsesChan := make(chan string, 1)
+	commandsChan := make(chan string, 1)
+	startSimulatedAdapter(b, responsesChan, commandsChan)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		commandsChan <- "010C"
+		select {
+		case <-responsesChan:
+		case <-time.After(time.Second):
+			b.Fatal("No response from simulator")
+		}
+	}
+}
+
+func BenchmarkResponseAssembler(b *testing.B) {
+	chunks := [][]byte{[]byte("7E8 04 41 0C"), []byte(" 1A F8\r7E9 04 41 0C 1A F0\r"), []byte("\r>")}
+	var assembler responseAssembler
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, chunk := range chunks {
+			assembler.Feed(chunk)
+		}
+	}
+}
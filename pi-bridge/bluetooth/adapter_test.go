@@ -1,10 +1,16 @@
 package bluetooth
 
 import (
+	"context"
 	"io"
+	"net"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"elm327-bridge/common"
+	"elm327-bridge/simulator"
 )
 
 // MockReadWriteCloser для тестирования
@@ -86,7 +92,7 @@ func TestAdapterStartStop(t *testing.T) {
 	adapter := NewAdapter(config, responsesChan, commandsChan)
 
 	// Запускаем адаптер
-	err := adapter.Start()
+	err := adapter.Start(context.Background())
 	if err != nil {
 		t.Fatalf("Failed to start adapter: %v", err)
 	}
@@ -101,6 +107,29 @@ func TestAdapterStartStop(t *testing.T) {
 	}
 }
 
+func TestAdapterStopInterruptsRead(t *testing.T) {
+	// Поток без дедлайнов: чтение блокируется, пока соединение не закрыто
+	client, server := net.Pipe()
+	defer server.Close()
+
+	adapter := NewAdapter(DefaultConfig(), make(chan string, 10), make(chan string, 10))
+	useConnection(t, adapter, client)
+	adapter.wg.Add(1)
+	go adapter.readLoop()
+	time.Sleep(50 * time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		adapter.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop blocked on a pending read")
+	}
+}
+
 func TestMockReadWriteCloser(t *testing.T) {
 	mock := &MockReadWriteCloser{
 		readData: []byte("test data"),
@@ -142,10 +171,20 @@ func TestMockReadWriteCloser(t *testing.T) {
 	}
 }
 
+// useConnection подключает адаптер к соединению conn в обход инициализации ELM327
+func useConnection(t *testing.T, adapter *Adapter, conn io.ReadWriteCloser) {
+	t.Helper()
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return conn, nil }))
+	if err := adapter.transport.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	adapter.initialized.Store(true)
+}
+
 func TestAdapterWithMockConnection(t *testing.T) {
 	// Создаем мок-соединение с тестовыми данными
 	mockConn := &MockReadWriteCloser{
-		readData: []byte("OK>"),
+		readData: []byte("41 0C 1A F0\r\r>"),
 	}
 
 	responsesChan := make(chan string, 10)
@@ -154,19 +193,20 @@ func TestAdapterWithMockConnection(t *testing.T) {
 	config := DefaultConfig()
 	adapter := NewAdapter(config, responsesChan, commandsChan)
 
-	// Устанавливаем мок-соединение напрямую для тестирования
-	adapter.setConnection(mockConn)
+	// Подключаем мок-соединение напрямую, без инициализации ELM327
+	useConnection(t, adapter, mockConn)
 
 	// Запускаем только writeLoop для тестирования записи
+	adapter.wg.Add(1)
 	go adapter.writeLoop()
 
 	// Тестируем отправку команды
-	commandsChan <- "ATZ"
+	commandsChan <- "010C"
 	time.Sleep(50 * time.Millisecond)
 
 	// Проверяем, что команда была записана
-	if !strings.Contains(string(mockConn.writeData), "ATZ") {
-		t.Error("Expected ATZ command to be written")
+	if !strings.Contains(string(mockConn.writeData), "010C") {
+		t.Error("Expected 010C command to be written")
 	}
 
 	// Тестируем чтение ответа
@@ -176,13 +216,14 @@ func TestAdapterWithMockConnection(t *testing.T) {
 	}()
 
 	// Запускаем только readLoop для тестирования чтения
+	adapter.wg.Add(1)
 	go adapter.readLoop()
 
 	// Ждем получения данных
 	select {
 	case response := <-responsesChan:
-		if !strings.Contains(response, "OK") {
-			t.Errorf("Expected response to contain 'OK', got %s", response)
+		if !strings.Contains(response, "41 0C") {
+			t.Errorf("Expected response to contain '41 0C', got %s", response)
 		}
 	case <-time.After(1 * time.Second):
 		t.Error("Timeout waiting for response")
@@ -190,3 +231,1066 @@ func TestAdapterWithMockConnection(t *testing.T) {
 
 	adapter.Stop()
 }
+
+func TestWriteLoopReadOnly(t *testing.T) {
+	mockConn := &MockReadWriteCloser{}
+
+	responsesChan := make(chan string, 10)
+	commandsChan := make(chan string, 10)
+
+	config := DefaultConfig()
+	config.ReadOnly = true
+	adapter := NewAdapter(config, responsesChan, commandsChan)
+	useConnection(t, adapter, mockConn)
+
+	adapter.wg.Add(1)
+	go adapter.writeLoop()
+
+	// Команда сброса DTC должна быть отброшена, чтение PID - отправлено
+	commandsChan <- "04"
+	commandsChan <- "010C"
+	time.Sleep(50 * time.Millisecond)
+
+	adapter.cancel()
+	adapter.wg.Wait()
+
+	written := string(mockConn.writeData)
+	if strings.Contains(written, "04\r") {
+		t.Errorf("Expected mode 04 to be blocked in read-only mode, written: %q", written)
+	}
+	if !strings.Contains(written, "010C\r") {
+		t.Errorf("Expected 010C to be written, got %q", written)
+	}
+}
+
+func TestWriteLoopCarriageReturn(t *testing.T) {
+	mockConn := &MockReadWriteCloser{}
+	commandsChan := make(chan string, 1)
+
+	adapter := NewAdapter(DefaultConfig(), make(chan string, 1), commandsChan)
+	useConnection(t, adapter, mockConn)
+
+	adapter.wg.Add(1)
+	go adapter.writeLoop()
+
+	commandsChan <- "ATZ"
+	time.Sleep(50 * time.Millisecond)
+
+	adapter.cancel()
+	adapter.wg.Wait()
+
+	if written := string(mockConn.writeData); written != "ATZ\r" {
+		t.Errorf("Expected command terminated by carriage return, got %q", written)
+	}
+}
+
+func TestWriteLoopNotConnected(t *testing.T) {
+	commandResponses := make(chan common.CommandResponse, 1)
+	requests := common.NewPendingRequests(time.Second, commandResponses)
+	commandsChan := make(chan string, 1)
+
+	adapter := NewAdapter(DefaultConfig(), make(chan string, 1), commandsChan)
+	adapter.SetPendingRequests(requests)
+
+	adapter.wg.Add(1)
+	go adapter.writeLoop()
+
+	requests.Register("reset", []string{"ATZ"})
+	commandsChan <- "ATZ"
+
+	select {
+	case response := <-commandResponses:
+		if response.CorrelationID != "reset" || !strings.Contains(response.Error, "not connected") {
+			t.Errorf("Expected not connected error, got %+v", response)
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected command response without connection")
+	}
+
+	adapter.cancel()
+	adapter.wg.Wait()
+}
+
+func TestWriteLoopTimeout(t *testing.T) {
+	config := DefaultConfig()
+	config.WriteTimeout = 50 * time.Millisecond
+	commandsChan := make(chan string, 1)
+	adapter := NewAdapter(config, make(chan string, 1), commandsChan)
+
+	conn := &hangingConn{closed: make(chan struct{})}
+	adapter.SetTransport(newStreamTransport(func() (io.ReadWriteCloser, error) { return conn, nil }, 0, config.WriteTimeout))
+	if err := adapter.transport.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	adapter.initialized.Store(true)
+
+	adapter.wg.Add(1)
+	go adapter.writeLoop()
+	defer func() {
+		adapter.cancel()
+		adapter.wg.Wait()
+	}()
+
+	// Зависшая запись закрывает соединение, дальше его восстанавливает цикл переподключения
+	commandsChan <- "010C"
+	deadline := time.Now().Add(time.Second)
+	for adapter.isConnected() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected connection to be closed after write timeout")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestAdapterSetActive(t *testing.T) {
+	adapter := NewAdapter(DefaultConfig(), make(chan string, 1), make(chan string, 1))
+	mock := &MockReadWriteCloser{}
+	useConnection(t, adapter, mock)
+
+	// Переход в резервный режим освобождает адаптер
+	adapter.SetActive(false)
+	if adapter.isConnected() {
+		t.Error("Expected connection to be closed in standby")
+	}
+	if !mock.closed {
+		t.Error("Expected mock connection to be closed")
+	}
+
+	adapter.SetActive(true)
+	if !adapter.active.Load() {
+		t.Error("Expected adapter to be active")
+	}
+}
+
+func TestDispatchResponseRouting(t *testing.T) {
+	responsesChan := make(chan string, 10)
+	adapter := NewAdapter(DefaultConfig(), responsesChan, make(chan string, 1))
+
+	var atResponses []string
+	adapter.SetATResponseHandler(func(command, response string) {
+		atResponses = append(atResponses, command+"="+response)
+	})
+
+	adapter.pending.push("ATRV", nil, nil)
+	adapter.pending.push("010C", nil, nil)
+	adapter.pending.push("", nil, nil) // Повтор 010C в потоковом режиме
+	adapter.pending.push("ATSH 7E0", nil, nil)
+
+	adapter.dispatchResponse("12.6V")
+	adapter.dispatchResponse("41 0C 1A F0")
+	adapter.dispatchResponse("41 0C 1A F8")
+	adapter.dispatchResponse("OK")
+
+	if len(atResponses) != 2 || atResponses[0] != "ATRV=12.6V" || atResponses[1] != "ATSH 7E0=OK" {
+		t.Errorf("Unexpected AT responses: %v", atResponses)
+	}
+	if len(responsesChan) != 2 {
+		t.Fatalf("Expected 2 OBD responses, got %d", len(responsesChan))
+	}
+	if response := <-responsesChan; response != "41 0C 1A F0" {
+		t.Errorf("Unexpected OBD response: %q", response)
+	}
+
+	// Ответ без ожидающей команды передается парсеру
+	adapter.dispatchResponse("41 0D 32")
+	if len(responsesChan) != 2 {
+		t.Errorf("Expected unmatched response to reach the parser")
+	}
+}
+
+func TestDispatchResponseCorrelation(t *testing.T) {
+	responsesChan := make(chan string, 10)
+	commandResponses := make(chan common.CommandResponse, 10)
+	requests := common.NewPendingRequests(time.Second, commandResponses)
+
+	adapter := NewAdapter(DefaultConfig(), responsesChan, make(chan string, 1))
+	adapter.SetPendingRequests(requests)
+
+	requests.Register("voltage", []string{"ATRV"})
+	requests.Register("rpm", []string{"010C"})
+
+	adapter.pending.push("ATRV", nil, requests.Claim("ATRV"))
+	adapter.pending.push("010C", nil, requests.Claim("010C"))
+
+	// Ответ на AT команду сразу возвращается клиенту
+	adapter.dispatchResponse("12.6V")
+	select {
+	case response := <-commandResponses:
+		if response.CorrelationID != "voltage" || response.Result != "12.6V" {
+			t.Errorf("Unexpected AT command response: %+v", response)
+		}
+	default:
+		t.Fatal("Expected AT command response")
+	}
+
+	// Ответ OBD связывается с запросом и передается парсеру
+	adapter.dispatchResponse("41 0C 1A F0")
+	if response := <-responsesChan; response != "41 0C 1A F0" {
+		t.Fatalf("Unexpected OBD response: %q", response)
+	}
+	request := requests.Resolve("41 0C 1A F0")
+	if request == nil || request.CorrelationID != "rpm" {
+		t.Errorf("Expected OBD response to resolve to rpm request, got %+v", request)
+	}
+}
+
+func TestSendAndWait(t *testing.T) {
+	adapter := NewAdapter(DefaultConfig(), make(chan string, 1), make(chan string, 1))
+	mock := &MockReadWriteCloser{}
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		adapter.dispatchResponse("ELM327 v1.5")
+	}()
+
+	response, err := adapter.sendAndWait(mock, "ATZ", time.Second)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if response != "ELM327 v1.5" || string(mock.writeData) != "ATZ\r" {
+		t.Errorf("Unexpected exchange: wrote %q, got %q", mock.writeData, response)
+	}
+
+	// По таймауту ожидание снимается с очереди
+	if _, err := adapter.sendAndWait(mock, "ATE0", 10*time.Millisecond); err == nil {
+		t.Error("Expected timeout error")
+	}
+	if _, ok := adapter.pending.pop(); ok {
+		t.Error("Expected pending queue to be empty after timeout")
+	}
+}
+
+func TestWaitForPromptDuringSearch(t *testing.T) {
+	config := DefaultConfig()
+	config.ReadTimeout = 50 * time.Millisecond
+	adapter := NewAdapter(config, make(chan string, 1), make(chan string, 1))
+	adapter.pending.push("010C", nil, nil)
+
+	// Адаптер определяет протокол дольше обычного таймаута чтения
+	go func() {
+		notify(adapter.searchChan)
+		time.Sleep(150 * time.Millisecond)
+		adapter.dispatchResponse("41 0C 1A F8")
+		notify(adapter.promptChan)
+	}()
+
+	start := time.Now()
+	adapter.waitForPrompt()
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Errorf("Expected wait to be extended while searching, returned after %v", elapsed)
+	}
+	if adapter.pending.len() != 0 {
+		t.Errorf("Expected answered command to leave the queue, got %d pending", adapter.pending.len())
+	}
+}
+
+func TestWaitForPromptTimeout(t *testing.T) {
+	config := DefaultConfig()
+	config.ReadTimeout = 20 * time.Millisecond
+	adapter := NewAdapter(config, make(chan string, 1), make(chan string, 1))
+
+	commandResponses := make(chan common.CommandResponse, 1)
+	requests := common.NewPendingRequests(time.Second, commandResponses)
+	requests.Register("req-1", []string{"010C"})
+	adapter.SetPendingRequests(requests)
+
+	var timedOut []string
+	adapter.SetTimeoutHandler(func(command string) { timedOut = append(timedOut, command) })
+	// Синхронный отправитель (сброс при инициализации) ждет дольше read_timeout
+	reply := make(chan string, 1)
+	adapter.pending.push("ATZ", reply, nil)
+	adapter.pending.push("010C", nil, requests.Claim("010C"))
+
+	if adapter.waitForPrompt() {
+		t.Error("Expected timeout to be reported")
+	}
+	if item, ok := adapter.pending.pop(); !ok || item.reply != reply {
+		t.Error("Expected synchronous sender to keep waiting after timeout")
+	}
+	if adapter.pending.len() != 0 {
+		t.Error("Expected pending queue to be dropped after timeout")
+	}
+	if len(timedOut) != 1 || timedOut[0] != "010C" {
+		t.Errorf("Expected timeout handler to receive 010C, got %v", timedOut)
+	}
+
+	select {
+	case response := <-commandResponses:
+		if response.CorrelationID != "req-1" || response.Status != "error" || !strings.Contains(response.Error, "no response to 010C") {
+			t.Errorf("Unexpected command response: %+v", response)
+		}
+	default:
+		t.Error("Expected timed out request to be reported to the client")
+	}
+}
+
+// slowConn отвечает на каждую команду с задержкой и отмечает команды, отправленные
+// до приглашения на предыдущую
+type slowConn struct {
+	reader      *io.PipeReader
+	writer      *io.PipeWriter
+	delay       time.Duration
+	mu          sync.Mutex
+	outstanding bool
+	written     []string
+	overlapped  []string
+}
+
+func newSlowConn(delay time.Duration) *slowConn {
+	reader, writer := io.Pipe()
+	return &slowConn{reader: reader, writer: writer, delay: delay}
+}
+
+func (c *slowConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *slowConn) Write(p []byte) (int, error) {
+	command := strings.TrimSpace(string(p))
+	c.mu.Lock()
+	if c.outstanding {
+		c.overlapped = append(c.overlapped, command)
+	}
+	c.outstanding = true
+	c.written = append(c.written, command)
+	c.mu.Unlock()
+
+	go func() {
+		time.Sleep(c.delay)
+		c.mu.Lock()
+		c.outstanding = false
+		c.mu.Unlock()
+		c.writer.Write([]byte("OK\r\r>"))
+	}()
+	return len(p), nil
+}
+
+func (c *slowConn) Close() error {
+	c.writer.Close()
+	return c.reader.Close()
+}
+
+func TestWriteLoopSerializesCommands(t *testing.T) {
+	conn := newSlowConn(30 * time.Millisecond)
+	commandsChan := make(chan string, 10)
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+	adapter := NewAdapter(config, make(chan string, 10), commandsChan)
+	useConnection(t, adapter, conn)
+
+	adapter.wg.Add(2)
+	go adapter.readLoop()
+	go adapter.writeLoop()
+	defer func() {
+		conn.Close()
+		adapter.Stop()
+	}()
+
+	commands := []string{"ATE0", "ATL0", "ATH1", "ATS0"}
+	for _, command := range commands {
+		commandsChan <- command
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		conn.mu.Lock()
+		written := len(conn.written)
+		conn.mu.Unlock()
+		if written == len(commands) && adapter.pending.len() == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d commands to be answered, written %d", len(commands), written)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	conn.mu.Lock()
+	defer conn.mu.Unlock()
+	if len(conn.overlapped) != 0 {
+		t.Errorf("Commands sent before the previous prompt: %v", conn.overlapped)
+	}
+}
+
+// swallowingConn передает команды симулятору, кроме команды swallow, на которую
+// адаптер не отвечает (зависший адаптер без закрытия соединения). limit ограничивает
+// число пропущенных команд (0 - без ограничения)
+type swallowingConn struct {
+	*simulator.ELM327
+	swallow   string
+	limit     int
+	mu        sync.Mutex
+	written   []string
+	swallowed int
+}
+
+func (c *swallowingConn) Write(p []byte) (int, error) {
+	command := strings.TrimSpace(string(p))
+	c.mu.Lock()
+	c.written = append(c.written, command)
+	swallow := command == c.swallow && (c.limit == 0 || c.swallowed < c.limit)
+	if swallow {
+		c.swallowed++
+	}
+	c.mu.Unlock()
+	if swallow {
+		return len(p), nil
+	}
+	return c.ELM327.Write(p)
+}
+
+func (c *swallowingConn) count(command string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	count := 0
+	for _, written := range c.written {
+		if written == command {
+			count++
+		}
+	}
+	return count
+}
+
+func TestAdapterReinitializesSilentAdapter(t *testing.T) {
+	conn := &swallowingConn{ELM327: simulator.New(nil), swallow: "0105"}
+
+	responsesChan := make(chan string, 10)
+	commandsChan := make(chan string, 10)
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+	config.ReadTimeout = 100 * time.Millisecond
+	config.ResetAfterErrors = 1
+	adapter := NewAdapter(config, responsesChan, commandsChan)
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return conn, nil }))
+
+	connected := make(chan struct{}, 2)
+	adapter.SetConnectHandler(func() { connected <- struct{}{} })
+	adapter.Start(context.Background())
+	defer func() {
+		conn.Close()
+		adapter.Stop()
+	}()
+
+	select {
+	case <-connected:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Adapter initialization did not complete")
+	}
+
+	// Ответа на 0105 нет: перед следующей командой адаптер инициализируется заново
+	commandsChan <- "0105"
+	commandsChan <- "010C"
+
+	select {
+	case <-connected:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Adapter was not reinitialized after timeout")
+	}
+	if conn.count("ATZ") != 2 {
+		t.Errorf("Expected ATZ to be sent twice, got %d", conn.count("ATZ"))
+	}
+
+	select {
+	case response := <-responsesChan:
+		if response != "7E8 04 41 0C 1A F8" {
+			t.Errorf("Unexpected response %q", response)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected answer to 010C after reinitialization")
+	}
+}
+
+func TestAdapterReinitializeOnRequest(t *testing.T) {
+	conn := &swallowingConn{ELM327: simulator.New(nil)}
+
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+	adapter := NewAdapter(config, make(chan string, 10), make(chan string, 10))
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return conn, nil }))
+
+	connected := make(chan struct{}, 2)
+	adapter.SetConnectHandler(func() { connected <- struct{}{} })
+	adapter.Start(context.Background())
+	defer func() {
+		conn.Close()
+		adapter.Stop()
+	}()
+
+	select {
+	case <-connected:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Adapter initialization did not complete")
+	}
+
+	// Запуск двигателя: инициализация повторяется без переподключения
+	adapter.Reinitialize()
+	select {
+	case <-connected:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Adapter was not reinitialized on request")
+	}
+	if conn.count("ATZ") != 2 {
+		t.Errorf("Expected ATZ to be sent twice, got %d", conn.count("ATZ"))
+	}
+}
+
+func TestAdapterPriorityCommands(t *testing.T) {
+	elm := simulator.New(nil)
+	elm.Latency = 20 * time.Millisecond
+
+	responsesChan := make(chan string, 20)
+	commandsChan := make(chan string, 20)
+	priorityChan := make(chan string, 5)
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+	adapter := NewAdapter(config, responsesChan, commandsChan)
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return elm, nil }))
+	adapter.SetPriorityCommands(priorityChan)
+
+	connected := make(chan struct{}, 1)
+	adapter.SetConnectHandler(func() { connected <- struct{}{} })
+	adapter.Start(context.Background())
+	defer func() {
+		elm.Close()
+		adapter.Stop()
+	}()
+
+	select {
+	case <-connected:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Adapter initialization did not complete")
+	}
+
+	// Очередь опроса накопилась, команда клиента выполняется сразу после текущей
+	for i := 0; i < 10; i++ {
+		commandsChan <- "0105"
+	}
+	priorityChan <- "010C"
+
+	for i := 0; i < 11; i++ {
+		select {
+		case response := <-responsesChan:
+			if response == "7E8 04 41 0C 1A F8" {
+				if i > 1 {
+					t.Errorf("Priority command answered after %d poll commands", i)
+				}
+				return
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatal("Expected response to priority command")
+		}
+	}
+	t.Fatal("Priority command was not answered")
+}
+
+// promptDroppingConn теряет приглашение после ответа на команду drop
+type promptDroppingConn struct {
+	*simulator.ELM327
+	drop     string
+	mu       sync.Mutex
+	dropping bool
+}
+
+func (c *promptDroppingConn) Write(p []byte) (int, error) {
+	if strings.TrimSpace(string(p)) == c.drop {
+		c.mu.Lock()
+		c.dropping = true
+		c.mu.Unlock()
+	}
+	return c.ELM327.Write(p)
+}
+
+func (c *promptDroppingConn) Read(p []byte) (int, error) {
+	n, err := c.ELM327.Read(p)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.dropping {
+		return n, err
+	}
+	kept := p[:0]
+	for _, b := range p[:n] {
+		if b == '>' {
+			c.dropping = false
+			continue
+		}
+		kept = append(kept, b)
+	}
+	return len(kept), err
+}
+
+func TestAdapterFinalizesResponseWithoutPrompt(t *testing.T) {
+	conn := &promptDroppingConn{ELM327: simulator.New(nil), drop: "0105"}
+
+	responsesChan := make(chan string, 10)
+	commandsChan := make(chan string, 10)
+	config := DefaultConfig()
+	config.ReadTimeout = 2 * time.Second
+	config.PartialResponseTimeout = 100 * time.Millisecond
+	config.HeartbeatInterval = 0
+	adapter := NewAdapter(config, responsesChan, commandsChan)
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return conn, nil }))
+
+	connected := make(chan struct{}, 2)
+	adapter.SetConnectHandler(func() { connected <- struct{}{} })
+	adapter.Start(context.Background())
+	defer func() {
+		conn.Close()
+		adapter.Stop()
+	}()
+
+	select {
+	case <-connected:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Adapter initialization did not complete")
+	}
+
+	// Ответ без приглашения завершается паузой, и следующая команда не ждет read_timeout
+	start := time.Now()
+	commandsChan <- "0105"
+	commandsChan <- "010C"
+	for _, want := range []string{"41 05", "41 0C"} {
+		select {
+		case response := <-responsesChan:
+			if !strings.Contains(response, want) {
+				t.Errorf("Expected response with %s, got %q", want, response)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Expected response with %s", want)
+		}
+	}
+	if elapsed := time.Since(start); elapsed >= config.ReadTimeout {
+		t.Errorf("Expected partial response to be finalized before read timeout, took %v", elapsed)
+	}
+
+	select {
+	case <-connected:
+		t.Error("Adapter should not be reinitialized after a missing prompt")
+	default:
+	}
+	if stats := adapter.Stats(); stats.PartialResponses != 1 || stats.CommandsTimedOut != 0 {
+		t.Errorf("Expected one partial response and no timeouts, got %+v", stats)
+	}
+}
+
+func TestAdapterWakesFromLowPower(t *testing.T) {
+	conn := &swallowingConn{ELM327: simulator.New(nil)}
+
+	responsesChan := make(chan string, 10)
+	commandsChan := make(chan string, 10)
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+	config.ReadTimeout = 100 * time.Millisecond
+	adapter := NewAdapter(config, responsesChan, commandsChan)
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return conn, nil }))
+
+	connected := make(chan struct{}, 2)
+	adapter.SetConnectHandler(func() { connected <- struct{}{} })
+	adapter.Start(context.Background())
+	defer func() {
+		conn.Close()
+		adapter.Stop()
+	}()
+
+	select {
+	case <-connected:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Adapter initialization did not complete")
+	}
+
+	commandsChan <- "AT LP"
+	deadline := time.Now().Add(3 * time.Second)
+	for !adapter.sleeping.Load() {
+		if time.Now().After(deadline) {
+			t.Fatal("Adapter was not marked as sleeping after ATLP")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Следующая команда будит адаптер и повторяет инициализацию
+	commandsChan <- "010C"
+
+	select {
+	case <-connected:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Adapter was not reinitialized after wake-up")
+	}
+	if conn.count("ATZ") != 2 {
+		t.Errorf("Expected ATZ to be sent twice, got %d", conn.count("ATZ"))
+	}
+	if adapter.sleeping.Load() {
+		t.Error("Expected adapter to be awake")
+	}
+
+	select {
+	case response := <-responsesChan:
+		if response != "7E8 04 41 0C 1A F8" {
+			t.Errorf("Unexpected response %q", response)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected answer to 010C after wake-up")
+	}
+}
+
+func TestAdapterKeepsAnswersDuringProtocolSearch(t *testing.T) {
+	sim := simulator.New(nil)
+	sim.SearchDelay = 200 * time.Millisecond
+
+	responsesChan := make(chan string, 10)
+	commandsChan := make(chan string, 10)
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+	config.ReadTimeout = 100 * time.Millisecond
+	adapter := NewAdapter(config, responsesChan, commandsChan)
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return sim, nil }))
+	adapter.Start(context.Background())
+	defer func() {
+		sim.Close()
+		adapter.Stop()
+	}()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for sim.Requests() < uint64(len(config.InitCommands)) {
+		if time.Now().After(deadline) {
+			t.Fatal("Adapter initialization did not complete")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Первые запросы после включения зажигания отправляются без пауз
+	commandsChan <- "010C"
+	commandsChan <- "010D"
+
+	var responses []string
+	for len(responses) < 2 {
+		select {
+		case response := <-responsesChan:
+			responses = append(responses, response)
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Expected two answers, got %q", responses)
+		}
+	}
+
+	expected := []string{"7E8 04 41 0C 1A F8", "7E8 03 41 0D 3C"}
+	for i, response := range responses {
+		if response != expected[i] {
+			t.Errorf("Expected answer %q, got %q", expected[i], response)
+		}
+	}
+}
+
+func TestAdapterConnectHandler(t *testing.T) {
+	sim := simulator.New(nil)
+
+	responsesChan := make(chan string, 10)
+	commandsChan := make(chan string, 10)
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+	config.ReadTimeout = 100 * time.Millisecond
+	adapter := NewAdapter(config, responsesChan, commandsChan)
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return sim, nil }))
+
+	connected := make(chan uint64, 1)
+	adapter.SetConnectHandler(func() { connected <- sim.Requests() })
+	adapter.Start(context.Background())
+	defer func() {
+		sim.Close()
+		adapter.Stop()
+	}()
+
+	select {
+	case requests := <-connected:
+		if requests != uint64(len(config.InitCommands)) {
+			t.Errorf("Expected handler after %d init commands, got %d", len(config.InitCommands), requests)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Connect handler was not called")
+	}
+}
+
+func TestAdapterRetriesInitCommands(t *testing.T) {
+	tests := []struct {
+		name      string
+		limit     int
+		connected bool
+		resets    int
+	}{
+		{"clone answers second ATZ", 1, true, 2},
+		{"adapter never answers ATZ", 0, false, 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &swallowingConn{ELM327: simulator.New(nil), swallow: "ATZ", limit: tt.limit}
+
+			config := DefaultConfig()
+			config.ReconnectInterval = 10 * time.Millisecond
+			config.ReadTimeout = 100 * time.Millisecond
+			config.InitAttempts = 2
+			adapter := NewAdapter(config, make(chan string, 10), make(chan string, 10))
+			adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return conn, nil }))
+
+			// Подключение выполняется напрямую, без цикла переподключения
+			adapter.wg.Add(1)
+			go adapter.readLoop()
+			defer func() {
+				conn.Close()
+				adapter.Stop()
+			}()
+
+			err := adapter.connect()
+
+			if (err == nil) != tt.connected {
+				t.Fatalf("connect() error = %v, expected connected=%v", err, tt.connected)
+			}
+			if conn.count("ATZ") != tt.resets {
+				t.Errorf("Expected ATZ to be sent %d times, got %d", tt.resets, conn.count("ATZ"))
+			}
+			if adapter.isConnected() != tt.connected {
+				t.Errorf("Expected isConnected=%v", tt.connected)
+			}
+		})
+	}
+}
+
+// delayedConn передает команды симулятору, отвечающему на команду delay с задержкой
+// (клон, долго выполняющий сброс), и записывает порядок команд
+type delayedConn struct {
+	*simulator.ELM327
+	delay   string
+	after   time.Duration
+	mu      sync.Mutex
+	written []string
+}
+
+func (c *delayedConn) Write(p []byte) (int, error) {
+	command := strings.TrimSpace(string(p))
+	c.mu.Lock()
+	c.written = append(c.written, command)
+	c.mu.Unlock()
+	if command == c.delay {
+		go func() {
+			time.Sleep(c.after)
+			c.ELM327.Write(p)
+		}()
+		return len(p), nil
+	}
+	return c.ELM327.Write(p)
+}
+
+func (c *delayedConn) commands() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string{}, c.written...)
+}
+
+func TestAdapterHoldsCommandsDuringInit(t *testing.T) {
+	conn := &delayedConn{ELM327: simulator.New(nil), delay: "ATZ", after: 300 * time.Millisecond}
+
+	commandsChan := make(chan string, 10)
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+	config.ReadTimeout = 100 * time.Millisecond
+	config.CloneQuirks = true
+	adapter := NewAdapter(config, make(chan string, 10), commandsChan)
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return conn, nil }))
+
+	connected := make(chan struct{}, 1)
+	adapter.SetConnectHandler(func() { notify(connected) })
+
+	// Команды опроса уже ждут в канале, когда начинается инициализация
+	for i := 0; i < 3; i++ {
+		commandsChan <- "010C"
+	}
+	adapter.Start(context.Background())
+	defer func() {
+		conn.Close()
+		adapter.Stop()
+	}()
+
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("ELM327 was not initialized, commands sent: %v", conn.commands())
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for len(commandsChan) > 0 || adapter.pending.len() > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Poll commands were not sent after initialization")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	written := conn.commands()
+	expected := append(append([]string{}, config.InitCommands...), "010C", "010C", "010C")
+	if strings.Join(written, " ") != strings.Join(expected, " ") {
+		t.Errorf("Expected commands %v, got %v", expected, written)
+	}
+}
+
+func TestValidateInitResponse(t *testing.T) {
+	tests := []struct {
+		command  string
+		response string
+		valid    bool
+	}{
+		{"ATZ", "ELM327 v1.5", true},
+		{"ATZ", "ATZ\rELM327 v2.1", true},
+		{"ATZ", "OK", false},
+		{"ATE0", "ATE0\rOK", true},
+		{"ATSP0", "OK", true},
+		{"ATSP0", "?", false},
+		{"ATH1", "NO DATA", false},
+		{"ATRV", "12.4V", true},
+		{"ATDPN", "A6", true},
+		{"STFAC", "OK", true},
+	}
+
+	for _, tt := range tests {
+		err := validateInitResponse(tt.command, tt.response)
+		if (err == nil) != tt.valid {
+			t.Errorf("%s -> %q: expected valid=%v, got error %v", tt.command, tt.response, tt.valid, err)
+		}
+	}
+}
+
+func TestAdapterMonitorMode(t *testing.T) {
+	sim := simulator.New(nil)
+	sim.MonitorFrames = []string{"3B4 01 02 03"}
+	sim.MonitorInterval = 5 * time.Millisecond
+
+	responsesChan := make(chan string, 10)
+	commandsChan := make(chan string, 10)
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+	config.ReadTimeout = 100 * time.Millisecond
+	adapter := NewAdapter(config, responsesChan, commandsChan)
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return sim, nil }))
+
+	connected := make(chan struct{}, 1)
+	lines := make(chan string, 100)
+	atResponses := make(chan [2]string, 10)
+	adapter.SetConnectHandler(func() { connected <- struct{}{} })
+	adapter.SetMonitorHandler(func(line string) {
+		select {
+		case lines <- line:
+		default:
+		}
+	})
+	adapter.SetATResponseHandler(func(command, response string) { atResponses <- [2]string{command, response} })
+	adapter.Start(context.Background())
+	defer func() {
+		sim.Close()
+		adapter.Stop()
+	}()
+
+	select {
+	case <-connected:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Adapter initialization did not complete")
+	}
+
+	commandsChan <- "ATMA"
+	for i := 0; i < 3; i++ {
+		select {
+		case line := <-lines:
+			if line != "3B4 01 02 03" {
+				t.Errorf("Unexpected monitor line %q", line)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("Expected monitor lines")
+		}
+	}
+
+	// Следующая команда прерывает мониторинг и получает свой ответ
+	commandsChan <- "ATRV"
+	expected := [][2]string{{"ATMA", ""}, {"ATRV", "12.6V"}}
+	for _, want := range expected {
+		select {
+		case got := <-atResponses:
+			if got != want {
+				t.Errorf("Expected AT response %q, got %q", want, got)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected AT response %q", want)
+		}
+	}
+	if len(responsesChan) != 0 {
+		t.Errorf("Monitor frames must not reach the OBD parser, got %q", <-responsesChan)
+	}
+}
+
+func TestIsLowPowerCommand(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected bool
+	}{
+		{"ATLP", true},
+		{"at lp", true},
+		{"ATL1", false},
+		{"ATRV", false},
+	}
+
+	for _, tt := range tests {
+		if got := isLowPowerCommand(tt.command); got != tt.expected {
+			t.Errorf("isLowPowerCommand(%q) = %v, expected %v", tt.command, got, tt.expected)
+		}
+	}
+}
+
+func TestIsMonitorCommand(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected bool
+	}{
+		{"ATMA", true},
+		{"at ma", true},
+		{"ATMR 12", true},
+		{"ATMT10", true},
+		{"ATM0", false},
+		{"010C", false},
+	}
+
+	for _, tt := range tests {
+		if result := isMonitorCommand(tt.command); result != tt.expected {
+			t.Errorf("isMonitorCommand(%q) = %v, expected %v", tt.command, result, tt.expected)
+		}
+	}
+}
+
+func TestAdapterStartContext(t *testing.T) {
+	// Отмененный ctx не запускает адаптер
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	adapter := NewAdapter(DefaultConfig(), make(chan string, 1), make(chan string))
+	if err := adapter.Start(ctx); err == nil {
+		adapter.Stop()
+		t.Fatal("Expected error for canceled context")
+	}
+
+	// Stop прерывает подключение к адаптеру, которое не завершается
+	release := make(chan struct{})
+	defer close(release)
+	config := DefaultConfig()
+	config.HeartbeatInterval = 0
+	adapter = NewAdapter(config, make(chan string, 1), make(chan string))
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) {
+		<-release
+		return nil, io.EOF
+	}))
+	if err := adapter.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	time.Sleep(50 * time.Millisecond)
+
+	stopped := make(chan struct{})
+	go func() {
+		adapter.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Stop did not interrupt pending connection")
+	}
+}
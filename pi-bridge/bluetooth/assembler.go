@@ -0,0 +1,169 @@
+package bluetooth
+
+import "strings"
+
+// promptChar - приглашение ELM327, завершающее ответ на команду
+const promptChar = '>'
+
+// ignoredLines содержит служебные строки ELM327, не относящиеся к данным ответа
+var ignoredLines = map[string]bool{
+	"SEARCHING...": true,
+}
+
+// transientPrefixes - начала строк, которые ELM327 выводит при определении протокола.
+// Пока идет поиск, ответ на команду еще впереди в том же цикле, и он может занять секунды
+var transientPrefixes = []string{"SEARCHING", "BUS INIT"}
+
+// responseAssembler собирает все строки одного цикла "команда - приглашение" в единый ответ.
+// Многострочные ответы (Mode 03, Mode 09, ответы нескольких ЭБУ) передаются парсеру целиком,
+// строки разделяются символом "\r"
+type responseAssembler struct {
+	lines     []string
+	current   strings.Builder
+	size      int  // Байт в завершенных строках текущего ответа
+	searching bool // В текущем цикле адаптер определяет протокол
+	prompt    byte // Символ приглашения (0 - '>')
+	noPrompt  bool // Адаптер не выводит приглашение: ответ завершает Flush
+	flushed   bool // Ответ завершен Flush, приглашение на него может прийти с опозданием
+}
+
+// Feed добавляет прочитанные байты и возвращает ответы, завершенные приглашением
+func (r *responseAssembler) Feed(data []byte) []string {
+	var responses []string
+
+	prompt := promptByte(r.prompt)
+	for _, b := range data {
+		switch {
+		case b == '\r' || b == '\n':
+			r.flushLine()
+		case b == prompt && !r.noPrompt:
+			r.flushLine()
+			// Опоздавшее приглашение после Flush не образует пустой ответ на следующую команду
+			if r.flushed && len(r.lines) == 0 {
+				r.flushed = false
+				r.searching = false
+				continue
+			}
+			r.flushed = false
+			responses = append(responses, strings.Join(r.lines, "\r"))
+			r.lines = nil
+			r.size = 0
+			r.searching = false
+		case b == 0:
+			// Некоторые клоны ELM327 дополняют ответ нулевыми байтами
+		default:
+			r.current.WriteByte(b)
+		}
+	}
+
+	// Строка поиска протокола может прийти частично: точки выводятся по мере поиска
+	if isTransientLine(r.current.String()) {
+		r.searching = true
+	}
+
+	return responses
+}
+
+// Flush завершает ответ без приглашения (адаптер замолчал после ответа).
+// Возвращает false, если после предыдущего ответа ничего не получено
+func (r *responseAssembler) Flush() (string, bool) {
+	r.flushLine()
+	if len(r.lines) == 0 {
+		return "", false
+	}
+	response := strings.Join(r.lines, "\r")
+	r.lines = nil
+	r.size = 0
+	r.searching = false
+	r.flushed = true
+	return response, true
+}
+
+// Pending сообщает, получены ли данные незавершенного ответа
+func (r *responseAssembler) Pending() bool {
+	return len(r.lines) > 0 || strings.TrimSpace(r.current.String()) != ""
+}
+
+// Size возвращает объем данных незавершенного ответа в байтах
+func (r *responseAssembler) Size() int {
+	return r.size + r.current.Len()
+}
+
+// probe возвращает данные незавершенного ответа для автоопределения приглашения
+func (r *responseAssembler) probe() *framingProbe {
+	return &framingProbe{
+		lines:   append([]string(nil), r.lines...),
+		partial: strings.TrimSpace(r.current.String()),
+	}
+}
+
+// Searching сообщает, что адаптер определяет протокол и ответ текущего цикла задерживается
+func (r *responseAssembler) Searching() bool {
+	return r.searching
+}
+
+// Reset отбрасывает незавершенный ответ (при смене соединения)
+func (r *responseAssembler) Reset() {
+	r.lines = nil
+	r.size = 0
+	r.current.Reset()
+	r.searching = false
+	r.flushed = false
+}
+
+// promptByte возвращает символ приглашения с учетом значения по умолчанию
+func promptByte(prompt byte) byte {
+	if prompt == 0 {
+		return promptChar
+	}
+	return prompt
+}
+
+// isBusInitOK проверяет строку успешной инициализации шины ISO 9141/KWP, например "BUS INIT: ...OK".
+// Неудачная инициализация ("BUS INIT: ...ERROR") остается в ответе
+func isBusInitOK(line string) bool {
+	line = strings.ToUpper(line)
+	return strings.HasPrefix(line, "BUS INIT") && strings.HasSuffix(line, "OK")
+}
+
+// isTransientLine проверяет, является ли строка выводом поиска протокола
+func isTransientLine(line string) bool {
+	line = strings.ToUpper(strings.TrimSpace(line))
+	for _, prefix := range transientPrefixes {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeLine приводит строку ответа к виду, ожидаемому парсером: управляющие символы
+// и байты вне ASCII (нулевые байты клонов, ESC, DEL, помехи линии) удаляются, а пробелы
+// и табуляции между байтами схлопываются в один пробел. "41  0C\t1A " становится "41 0C 1A"
+func normalizeLine(line string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if c := line[i]; c == '\t' || c >= ' ' && c < 0x7F {
+			b.WriteByte(c)
+		}
+	}
+	return strings.Join(strings.Fields(b.String()), " ")
+}
+
+// flushLine завершает текущую строку, пропуская пустые и служебные
+func (r *responseAssembler) flushLine() {
+	line := normalizeLine(r.current.String())
+	r.current.Reset()
+	if isTransientLine(line) {
+		r.searching = true
+	} else if r.noPrompt && line != "" {
+		// Без приглашения поиск протокола заканчивается первой строкой ответа
+		r.searching = false
+	}
+	if line == "" || ignoredLines[line] || isBusInitOK(line) {
+		return
+	}
+	r.lines = append(r.lines, line)
+	r.size += len(line)
+	r.flushed = false
+}
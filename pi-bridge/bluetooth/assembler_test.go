@@ -0,0 +1,151 @@
+package bluetooth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestResponseAssembler(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunks   []string
+		expected []string
+	}{
+		{
+			name:     "Single line",
+			chunks:   []string{"41 0C 1A F0\r\r>"},
+			expected: []string{"41 0C 1A F0"},
+		},
+		{
+			name:     "Prompt right after data",
+			chunks:   []string{"41 00 BE 7F>"},
+			expected: []string{"41 00 BE 7F"},
+		},
+		{
+			name:     "Multi-line Mode 09 reply",
+			chunks:   []string{"014\r0: 49 02 01 31 44 34\r1: 47 50 30 30 52 35 35\r2: 42 31 32 33 34 35 36\r\r>"},
+			expected: []string{"014\r0: 49 02 01 31 44 34\r1: 47 50 30 30 52 35 35\r2: 42 31 32 33 34 35 36"},
+		},
+		{
+			name:     "Split across reads",
+			chunks:   []string{"7E8 03 41 0D", " 32\r7E9 03 4", "1 0D 33\r\r", ">"},
+			expected: []string{"7E8 03 41 0D 32\r7E9 03 41 0D 33"},
+		},
+		{
+			name:     "Searching line and two prompts in one read",
+			chunks:   []string{"SEARCHING...\r41 0D 32\r\r>OK\r\r>"},
+			expected: []string{"41 0D 32", "OK"},
+		},
+		{
+			name:     "Bus init before answer",
+			chunks:   []string{"BUS INIT: ", "...", "OK\r41 0C 1A F8\r\r>"},
+			expected: []string{"41 0C 1A F8"},
+		},
+		{
+			name:     "Bus init error is kept",
+			chunks:   []string{"BUS INIT: ...ERROR\r\r>"},
+			expected: []string{"BUS INIT: ...ERROR"},
+		},
+		{
+			name:     "Clone padding and irregular whitespace",
+			chunks:   []string{"\x0041  0C\t1A F0 \x00\r\r\x00>"},
+			expected: []string{"41 0C 1A F0"},
+		},
+		{
+			name:     "Control characters between bytes",
+			chunks:   []string{"7E8\x1b 03 41\x7f 0D 32\r\n\r\n>"},
+			expected: []string{"7E8 03 41 0D 32"},
+		},
+		{
+			name:     "Incomplete response",
+			chunks:   []string{"41 0C 1A"},
+			expected: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var assembler responseAssembler
+			var responses []string
+			for _, chunk := range tt.chunks {
+				responses = append(responses, assembler.Feed([]byte(chunk))...)
+			}
+			if !reflect.DeepEqual(responses, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, responses)
+			}
+		})
+	}
+}
+
+func TestNormalizeLine(t *testing.T) {
+	tests := map[string]string{
+		"41 0C 1A F0":         "41 0C 1A F0",
+		"  41   0C\t1A  ":     "41 0C 1A",
+		"41\x000C\x00":        "410C",
+		"BUS INIT: ...OK":     "BUS INIT: ...OK",
+		"\x00\x00":            "",
+		"41 \xff0C":           "41 0C",
+		"0:  49 02 01 31 44 ": "0: 49 02 01 31 44",
+	}
+	for input, want := range tests {
+		if got := normalizeLine(input); got != want {
+			t.Errorf("normalizeLine(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestResponseAssemblerReset(t *testing.T) {
+	var assembler responseAssembler
+	assembler.Feed([]byte("41 0C 1A\r41"))
+	assembler.Reset()
+
+	responses := assembler.Feed([]byte("OK\r>"))
+	if len(responses) != 1 || responses[0] != "OK" {
+		t.Errorf("Expected only the response after reset, got %q", responses)
+	}
+}
+
+func TestResponseAssemblerLatePrompt(t *testing.T) {
+	var assembler responseAssembler
+	assembler.Feed([]byte("7E8 03 41 0D 32\r"))
+	response, ok := assembler.Flush()
+	if !ok || response != "7E8 03 41 0D 32" {
+		t.Fatalf("Expected partial response to be flushed, got %q", response)
+	}
+
+	// Приглашение, опоздавшее после паузы, не становится пустым ответом
+	if responses := assembler.Feed([]byte("\r>")); len(responses) != 0 {
+		t.Errorf("Expected late prompt to be dropped, got %q", responses)
+	}
+	responses := assembler.Feed([]byte("7E8 04 41 0C 1A F8\r\r>"))
+	if len(responses) != 1 || responses[0] != "7E8 04 41 0C 1A F8" {
+		t.Errorf("Expected next response, got %q", responses)
+	}
+
+	// Прерывание без данных по-прежнему дает пустой ответ
+	if responses := assembler.Feed([]byte(">")); len(responses) != 1 || responses[0] != "" {
+		t.Errorf("Expected empty response to a bare prompt, got %q", responses)
+	}
+}
+
+func TestResponseAssemblerSearching(t *testing.T) {
+	var assembler responseAssembler
+
+	// Точки поиска выводятся постепенно, состояние определяется по неполной строке
+	assembler.Feed([]byte("SEARCH"))
+	if assembler.Searching() {
+		t.Error("Expected no search state on ambiguous prefix")
+	}
+	assembler.Feed([]byte("ING..."))
+	if !assembler.Searching() {
+		t.Error("Expected search state while protocol is negotiated")
+	}
+
+	responses := assembler.Feed([]byte("\r41 0C 1A F8\r\r>"))
+	if len(responses) != 1 || responses[0] != "41 0C 1A F8" {
+		t.Errorf("Expected answer after search, got %q", responses)
+	}
+	if assembler.Searching() {
+		t.Error("Expected search state to end at prompt")
+	}
+}
@@ -0,0 +1,66 @@
+package bluetooth
+
+import (
+	"time"
+
+	"elm327-bridge/common"
+)
+
+// Состояния связи с адаптером, публикуемые в топике connection
+const (
+	ConnectionConnected    = "connected"    // Соединение установлено, ELM327 инициализирован
+	ConnectionDisconnected = "disconnected" // Соединение потеряно или не установлено
+	ConnectionReconnecting = "reconnecting" // Выполняются попытки подключения
+)
+
+// ConnectionState описывает состояние связи с адаптером для публикации
+type ConnectionState struct {
+	State     string `json:"state"`            // connected, disconnected или reconnecting
+	Transport string `json:"transport"`        // serial, rfcomm, tcp, ble или replay
+	Reason    string `json:"reason,omitempty"` // Причина отключения
+}
+
+// SetStatusChannel задает канал служебных событий, в который публикуются изменения
+// состояния связи с адаптером (вызывать до Start)
+func (a *Adapter) SetStatusChannel(statusChan chan<- common.StatusEvent) {
+	a.statusChan = statusChan
+}
+
+// setConnectionState сообщает об изменении состояния связи. Публикуются только переходы:
+// отключение - из установленного соединения (или неудача первой попытки), попытки
+// подключения - после отключения, поэтому повторные неудачные попытки не засоряют топик
+func (a *Adapter) setConnectionState(state, reason string) {
+	a.stateMu.Lock()
+	defer a.stateMu.Unlock()
+
+	switch state {
+	case ConnectionConnected:
+		if a.connState == ConnectionConnected {
+			return
+		}
+	case ConnectionDisconnected:
+		if a.connState != ConnectionConnected && a.connState != "" {
+			return
+		}
+	case ConnectionReconnecting:
+		if a.connState != ConnectionDisconnected {
+			return
+		}
+	}
+	a.connState = state
+
+	if a.statusChan == nil {
+		return
+	}
+	event := common.StatusEvent{
+		Kind:      "connection",
+		Data:      ConnectionState{State: state, Transport: transportType(a.config), Reason: reason},
+		Retained:  true,
+		Timestamp: time.Now(),
+	}
+	select {
+	case a.statusChan <- event:
+	default:
+		logger.Printf("Warning: status channel is full, dropping connection state %s", state)
+	}
+}
@@ -0,0 +1,92 @@
+package bluetooth
+
+import (
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+	"elm327-bridge/simulator"
+)
+
+func TestSetConnectionState(t *testing.T) {
+	statusChan := make(chan common.StatusEvent, 10)
+	adapter := NewAdapter(Config{Transport: "tcp"}, nil, nil)
+	adapter.SetStatusChannel(statusChan)
+
+	// Повторные попытки и неудачи после отключения не публикуются
+	steps := []struct {
+		state  string
+		reason string
+	}{
+		{ConnectionReconnecting, ""},
+		{ConnectionDisconnected, "connection refused"},
+		{ConnectionReconnecting, ""},
+		{ConnectionDisconnected, "connection refused"},
+		{ConnectionReconnecting, ""},
+		{ConnectionConnected, ""},
+		{ConnectionConnected, ""},
+		{ConnectionDisconnected, "read error: EOF"},
+	}
+	for _, step := range steps {
+		adapter.setConnectionState(step.state, step.reason)
+	}
+
+	expected := []ConnectionState{
+		{State: ConnectionDisconnected, Transport: "tcp", Reason: "connection refused"},
+		{State: ConnectionReconnecting, Transport: "tcp"},
+		{State: ConnectionConnected, Transport: "tcp"},
+		{State: ConnectionDisconnected, Transport: "tcp", Reason: "read error: EOF"},
+	}
+	if len(statusChan) != len(expected) {
+		t.Fatalf("Expected %d events, got %d", len(expected), len(statusChan))
+	}
+	for _, want := range expected {
+		event := <-statusChan
+		if event.Kind != "connection" || !event.Retained {
+			t.Errorf("Expected retained connection event, got %+v", event)
+		}
+		if got := event.Data.(ConnectionState); got != want {
+			t.Errorf("Expected %+v, got %+v", want, got)
+		}
+	}
+}
+
+func TestAdapterPublishesConnectionState(t *testing.T) {
+	sim := simulator.New(nil)
+	statusChan := make(chan common.StatusEvent, 10)
+
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+	config.ReadTimeout = 100 * time.Millisecond
+	adapter := NewAdapter(config, make(chan string, 10), make(chan string, 10))
+	adapter.SetStatusChannel(statusChan)
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return sim, nil }))
+	adapter.Start(context.Background())
+	defer adapter.Stop()
+
+	expect := func(state string) ConnectionState {
+		t.Helper()
+		select {
+		case event := <-statusChan:
+			got := event.Data.(ConnectionState)
+			if got.State != state {
+				t.Fatalf("Expected %s, got %+v", state, got)
+			}
+			return got
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected %s event", state)
+		}
+		return ConnectionState{}
+	}
+
+	expect(ConnectionConnected)
+
+	// Соединение обрывается: чтение завершается ошибкой, адаптер переподключается
+	sim.Close()
+	if got := expect(ConnectionDisconnected); got.Reason == "" {
+		t.Error("Expected disconnect reason")
+	}
+	expect(ConnectionReconnecting)
+}
@@ -0,0 +1,213 @@
+package bluetooth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// Виды устройств, через которые можно подключиться к адаптеру ELM327
+const (
+	DeviceBluetooth = "bluetooth" // Сопряженное устройство Bluetooth
+	DeviceRFCOMM    = "rfcomm"    // Устройство /dev/rfcommN, привязанное rfcomm bind
+	DeviceSerial    = "serial"    // USB адаптер /dev/ttyUSBN или /dev/ttyACMN
+)
+
+// sppUUID - профиль последовательного порта (SPP) адаптеров Bluetooth Classic
+const sppUUID = "00001101-0000-1000-8000-00805f9b34fb"
+
+// bleServiceUUIDs - сервисы GATT, через которые работают BLE клоны ELM327
+var bleServiceUUIDs = []string{"0000fff0-0000-1000-8000-00805f9b34fb", "0000ffe0-0000-1000-8000-00805f9b34fb"}
+
+// usbSerialVendors - производители микросхем USB-UART, на которых собраны USB адаптеры ELM327
+var usbSerialVendors = map[string]string{
+	"0403": "FTDI",
+	"1a86": "WCH CH340",
+	"10c4": "Silicon Labs CP210x",
+	"067b": "Prolific PL2303",
+}
+
+// Device - устройство, через которое можно подключиться к адаптеру (для начальной настройки)
+type Device struct {
+	Kind      string `json:"kind"`              // bluetooth, rfcomm или serial
+	Path      string `json:"path,omitempty"`    // Узел устройства (rfcomm, serial)
+	Address   string `json:"address,omitempty"` // MAC адрес (bluetooth, привязанный rfcomm)
+	Name      string `json:"name,omitempty"`    // Имя Bluetooth устройства или описание USB
+	Transport string `json:"transport"`         // Транспорт для секции bluetooth (пусто - неизвестен)
+	Likely    bool   `json:"likely"`            // Похоже на адаптер ELM327
+}
+
+// ConfigHint возвращает ключи секции bluetooth для подключения через устройство
+func (d Device) ConfigHint() string {
+	switch {
+	case d.Kind == DeviceRFCOMM || d.Kind == DeviceSerial:
+		return fmt.Sprintf("transport: %s, device_path: %s", TransportSerial, d.Path)
+	case d.Transport != "":
+		return fmt.Sprintf("transport: %s, address: %s", d.Transport, d.Address)
+	}
+	return ""
+}
+
+// ListDevices перечисляет сопряженные устройства Bluetooth (BlueZ), устройства /dev/rfcomm*
+// и USB адаптеры /dev/ttyUSB*, /dev/ttyACM*. Недоступный источник (нет BlueZ или D-Bus)
+// не мешает остальным: найденные устройства возвращаются вместе с ошибкой
+func ListDevices() ([]Device, error) {
+	var errs []error
+	paired, err := listPairedDevices()
+	if err != nil {
+		errs = append(errs, fmt.Errorf("paired Bluetooth devices: %v", err))
+	}
+
+	names := make(map[string]string, len(paired))
+	for _, device := range paired {
+		names[device.Address] = device.Name
+	}
+	devices := append(paired, listRFCOMMDevices("/dev", names)...)
+	devices = append(devices, listSerialDevices("/dev", "/sys/class/tty")...)
+	return devices, errors.Join(errs...)
+}
+
+// listPairedDevices запрашивает у BlueZ сопряженные устройства
+func listPairedDevices() ([]Device, error) {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to system D-Bus: %v", err)
+	}
+	defer conn.Close()
+
+	var objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant
+	manager := conn.Object(bluezService, "/")
+	if err := manager.Call("org.freedesktop.DBus.ObjectManager.GetManagedObjects", 0).Store(&objects); err != nil {
+		return nil, err
+	}
+	return pairedDevices(objects), nil
+}
+
+// pairedDevices выбирает сопряженные устройства из объектов BlueZ. Транспорт определяется
+// по сервисам: SPP - rfcomm, сервис FFF0 или FFE0 - ble
+func pairedDevices(objects map[dbus.ObjectPath]map[string]map[string]dbus.Variant) []Device {
+	var devices []Device
+	for _, interfaces := range objects {
+		properties, ok := interfaces[bluezDevice]
+		if !ok {
+			continue
+		}
+		if paired, _ := properties["Paired"].Value().(bool); !paired {
+			continue
+		}
+
+		device := Device{Kind: DeviceBluetooth}
+		device.Address, _ = properties["Address"].Value().(string)
+		device.Address = strings.ToUpper(device.Address)
+		if device.Name, _ = properties["Name"].Value().(string); device.Name == "" {
+			device.Name, _ = properties["Alias"].Value().(string)
+		}
+		uuids, _ := properties["UUIDs"].Value().([]string)
+		for _, uuid := range uuids {
+			uuid = strings.ToLower(uuid)
+			switch {
+			case uuid == sppUUID:
+				device.Transport = TransportRFCOMM
+			case device.Transport == "" && containsString(bleServiceUUIDs, uuid):
+				device.Transport = TransportBLE
+			}
+		}
+		device.Likely = DiscoveryConfig{}.Match(DiscoveredDevice{Address: device.Address, Name: device.Name})
+		devices = append(devices, device)
+	}
+
+	sort.Slice(devices, func(i, j int) bool { return devices[i].Address < devices[j].Address })
+	return devices
+}
+
+// listRFCOMMDevices перечисляет устройства rfcomm в каталоге dev. Адрес привязки известен
+// ядру, имя - из сопряженных устройств names
+func listRFCOMMDevices(dev string, names map[string]string) []Device {
+	paths, _ := filepath.Glob(filepath.Join(dev, "rfcomm*"))
+	sort.Strings(paths)
+
+	var devices []Device
+	for _, path := range paths {
+		id, err := rfcommDeviceID(path)
+		if err != nil {
+			continue
+		}
+		device := Device{Kind: DeviceRFCOMM, Path: path, Transport: TransportSerial, Likely: true}
+		if address, err := rfcommDeviceAddress(id); err == nil {
+			device.Address = address
+			device.Name = names[address]
+		}
+		devices = append(devices, device)
+	}
+	return devices
+}
+
+// listSerialDevices перечисляет USB адаптеры в каталоге dev. Производитель и модель
+// берутся из sysfs (каталог sysTTY, обычно /sys/class/tty)
+func listSerialDevices(dev, sysTTY string) []Device {
+	var paths []string
+	for _, pattern := range []string{"ttyUSB*", "ttyACM*"} {
+		matches, _ := filepath.Glob(filepath.Join(dev, pattern))
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+
+	devices := make([]Device, 0, len(paths))
+	for _, path := range paths {
+		device := Device{Kind: DeviceSerial, Path: path, Transport: TransportSerial}
+		vendor, product, description := usbDeviceInfo(filepath.Join(sysTTY, filepath.Base(path), "device"))
+		if vendor != "" {
+			device.Name = strings.TrimSpace(fmt.Sprintf("%s (%s:%s)", description, vendor, product))
+			_, known := usbSerialVendors[vendor]
+			device.Likely = known || DiscoveryConfig{}.Match(DiscoveredDevice{Name: description})
+		}
+		devices = append(devices, device)
+	}
+	return devices
+}
+
+// usbDeviceInfo поднимается от устройства tty в sysfs до устройства USB и возвращает
+// его идентификаторы и описание (производитель и модель, для известных микросхем - ее имя)
+func usbDeviceInfo(path string) (vendor, product, description string) {
+	dir, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", "", ""
+	}
+	// tty -> интерфейс USB -> устройство USB
+	for i := 0; i < 4 && dir != "/"; i++ {
+		if vendor = readSysfs(dir, "idVendor"); vendor != "" {
+			product = readSysfs(dir, "idProduct")
+			description = strings.TrimSpace(readSysfs(dir, "manufacturer") + " " + readSysfs(dir, "product"))
+			if description == "" {
+				description = usbSerialVendors[vendor]
+			}
+			return vendor, product, description
+		}
+		dir = filepath.Dir(dir)
+	}
+	return "", "", ""
+}
+
+// readSysfs читает атрибут sysfs без перевода строки (пусто - атрибута нет)
+func readSysfs(dir, name string) string {
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// containsString проверяет наличие строки в списке
+func containsString(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
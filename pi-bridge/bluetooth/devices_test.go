@@ -0,0 +1,120 @@
+package bluetooth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+)
+
+func TestPairedDevices(t *testing.T) {
+	device := func(properties map[string]any) map[string]map[string]dbus.Variant {
+		variants := make(map[string]dbus.Variant)
+		for name, value := range properties {
+			variants[name] = dbus.MakeVariant(value)
+		}
+		return map[string]map[string]dbus.Variant{bluezDevice: variants}
+	}
+	objects := map[dbus.ObjectPath]map[string]map[string]dbus.Variant{
+		"/org/bluez/hci0": {bluezAdapter: {}},
+		"/org/bluez/hci0/dev_00_1D_A5_68_98_8B": device(map[string]any{
+			"Address": "00:1D:A5:68:98:8B", "Name": "OBDII", "Paired": true,
+			"UUIDs": []string{"00001101-0000-1000-8000-00805F9B34FB"},
+		}),
+		"/org/bluez/hci0/dev_11_22_33_44_55_66": device(map[string]any{
+			"Address": "11:22:33:44:55:66", "Alias": "IOS-Vlink", "Paired": true,
+			"UUIDs": []string{"0000fff0-0000-1000-8000-00805f9b34fb"},
+		}),
+		"/org/bluez/hci0/dev_AA_BB_CC_DD_EE_FF": device(map[string]any{
+			"Address": "aa:bb:cc:dd:ee:ff", "Name": "Headphones", "Paired": true,
+		}),
+		"/org/bluez/hci0/dev_01_02_03_04_05_06": device(map[string]any{
+			"Address": "01:02:03:04:05:06", "Name": "ELM327", "Paired": false,
+		}),
+	}
+
+	devices := pairedDevices(objects)
+	want := []Device{
+		{Kind: DeviceBluetooth, Address: "00:1D:A5:68:98:8B", Name: "OBDII", Transport: TransportRFCOMM, Likely: true},
+		{Kind: DeviceBluetooth, Address: "11:22:33:44:55:66", Name: "IOS-Vlink", Transport: TransportBLE},
+		{Kind: DeviceBluetooth, Address: "AA:BB:CC:DD:EE:FF", Name: "Headphones"},
+	}
+	if len(devices) != len(want) {
+		t.Fatalf("Expected %d paired devices, got %+v", len(want), devices)
+	}
+	for i := range want {
+		if devices[i] != want[i] {
+			t.Errorf("Device %d = %+v, want %+v", i, devices[i], want[i])
+		}
+	}
+}
+
+func TestListSerialDevices(t *testing.T) {
+	dev := t.TempDir()
+	sys := t.TempDir()
+
+	// Устройство USB с интерфейсом и портом tty, как в /sys/devices
+	usb := filepath.Join(sys, "devices", "1-1.2")
+	port := filepath.Join(usb, "1-1.2:1.0", "ttyUSB0")
+	if err := os.MkdirAll(port, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range map[string]string{"idVendor": "1a86\n", "idProduct": "7523\n", "product": "USB Serial\n"} {
+		if err := os.WriteFile(filepath.Join(usb, name), []byte(value), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	class := filepath.Join(sys, "class")
+	if err := os.MkdirAll(filepath.Join(class, "ttyUSB0"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(port, filepath.Join(class, "ttyUSB0", "device")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"ttyUSB0", "ttyACM0", "ttyS0", "rfcomm0", "rfcommX"} {
+		if err := os.WriteFile(filepath.Join(dev, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	serial := listSerialDevices(dev, class)
+	want := []Device{
+		{Kind: DeviceSerial, Path: filepath.Join(dev, "ttyACM0"), Transport: TransportSerial},
+		{Kind: DeviceSerial, Path: filepath.Join(dev, "ttyUSB0"), Name: "USB Serial (1a86:7523)", Transport: TransportSerial, Likely: true},
+	}
+	if len(serial) != len(want) {
+		t.Fatalf("Expected %d serial devices, got %+v", len(want), serial)
+	}
+	for i := range want {
+		if serial[i] != want[i] {
+			t.Errorf("Device %d = %+v, want %+v", i, serial[i], want[i])
+		}
+	}
+
+	// Узел с неверным именем пропускается; без привязки адрес неизвестен
+	rfcomm := listRFCOMMDevices(dev, nil)
+	if len(rfcomm) != 1 || rfcomm[0].Path != filepath.Join(dev, "rfcomm0") || rfcomm[0].Transport != TransportSerial {
+		t.Errorf("Unexpected rfcomm devices %+v", rfcomm)
+	}
+}
+
+func TestDeviceConfigHint(t *testing.T) {
+	tests := []struct {
+		device Device
+		want   string
+	}{
+		{Device{Kind: DeviceSerial, Path: "/dev/ttyUSB0", Transport: TransportSerial}, "transport: serial, device_path: /dev/ttyUSB0"},
+		{Device{Kind: DeviceRFCOMM, Path: "/dev/rfcomm0", Transport: TransportSerial}, "transport: serial, device_path: /dev/rfcomm0"},
+		{Device{Kind: DeviceBluetooth, Address: "00:1D:A5:68:98:8B", Transport: TransportRFCOMM}, "transport: rfcomm, address: 00:1D:A5:68:98:8B"},
+		{Device{Kind: DeviceBluetooth, Address: "11:22:33:44:55:66", Transport: TransportBLE}, "transport: ble, address: 11:22:33:44:55:66"},
+		{Device{Kind: DeviceBluetooth, Address: "AA:BB:CC:DD:EE:FF"}, ""},
+	}
+
+	for _, tt := range tests {
+		if got := tt.device.ConfigHint(); got != tt.want {
+			t.Errorf("ConfigHint(%+v) = %q, want %q", tt.device, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package bluetooth
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultDiscoveryDuration - длительность поиска устройств по умолчанию
+const defaultDiscoveryDuration = 10 * time.Second
+
+// defaultDiscoveryNames - имена, под которыми обычно видны адаптеры ELM327
+var defaultDiscoveryNames = []string{"OBDII", "OBD2", "ELM327"}
+
+// DiscoveryConfig задает поиск адаптера среди устройств поблизости: адрес не нужно знать
+// заранее, а замененный адаптер находится без правки конфигурации
+type DiscoveryConfig struct {
+	Enabled   bool          `yaml:"enabled"`    // Искать адаптер перед подключением (транспорт rfcomm)
+	Names     []string      `yaml:"names"`      // Подстроки имени устройства (пусто - OBDII, OBD2, ELM327)
+	MACPrefix string        `yaml:"mac_prefix"` // Префикс MAC адреса, например "00:1D:A5"
+	Duration  time.Duration `yaml:"duration"`   // Длительность поиска (0 - 10 с)
+}
+
+// DiscoveredDevice - устройство, найденное при поиске
+type DiscoveredDevice struct {
+	Address string
+	Name    string
+}
+
+// Validate проверяет параметры поиска
+func (c DiscoveryConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Duration < 0 {
+		return fmt.Errorf("invalid discovery duration %v", c.Duration)
+	}
+	if c.MACPrefix != "" {
+		if _, err := parseMACPrefix(c.MACPrefix); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Match проверяет, похоже ли устройство на адаптер ELM327: совпадает префикс MAC
+// или имя содержит одну из подстрок (без учета регистра, пробелов и дефисов)
+func (c DiscoveryConfig) Match(device DiscoveredDevice) bool {
+	if c.MACPrefix != "" {
+		prefix, _ := parseMACPrefix(c.MACPrefix)
+		if strings.HasPrefix(strings.ToUpper(device.Address), prefix) {
+			return true
+		}
+	}
+
+	name := normalizeDeviceName(device.Name)
+	if name == "" {
+		return false
+	}
+	names := c.Names
+	if len(names) == 0 {
+		names = defaultDiscoveryNames
+	}
+	for _, pattern := range names {
+		if pattern := normalizeDeviceName(pattern); pattern != "" && strings.Contains(name, pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// duration возвращает длительность поиска с учетом значения по умолчанию
+func (c DiscoveryConfig) duration() time.Duration {
+	if c.Duration <= 0 {
+		return defaultDiscoveryDuration
+	}
+	return c.Duration
+}
+
+// discoverer запоминает найденный адрес и повторяет поиск, если подключиться по нему
+// не удалось (адаптер выключен или заменен)
+type discoverer struct {
+	config   DiscoveryConfig
+	fallback string // Адрес из конфигурации, если поиск ничего не нашел
+	scan     func(duration time.Duration) ([]DiscoveredDevice, error)
+	dial     func(address string) (io.ReadWriteCloser, error)
+
+	mu      sync.Mutex
+	address string
+}
+
+// newDiscoverer создает поиск адаптера, подключающийся к найденному адресу функцией dial
+func newDiscoverer(config Config, dial func(address string) (io.ReadWriteCloser, error)) *discoverer {
+	return &discoverer{
+		config:   config.Discovery,
+		fallback: config.Address,
+		scan:     scanDevices,
+		dial:     dial,
+	}
+}
+
+// open подключается к последнему найденному адаптеру или ищет его заново
+func (d *discoverer) open() (io.ReadWriteCloser, error) {
+	d.mu.Lock()
+	address := d.address
+	d.mu.Unlock()
+
+	if address == "" {
+		found, err := d.discover()
+		if err != nil {
+			return nil, err
+		}
+		address = found
+	}
+
+	conn, err := d.dial(address)
+	d.mu.Lock()
+	if err != nil {
+		d.address = ""
+	} else {
+		d.address = address
+	}
+	d.mu.Unlock()
+	return conn, err
+}
+
+// discover ищет устройства и выбирает первое подходящее
+func (d *discoverer) discover() (string, error) {
+	logger.Printf("Discovering Bluetooth devices for %v", d.config.duration())
+
+	devices, err := d.scan(d.config.duration())
+	if err != nil {
+		if d.fallback != "" {
+			logger.Printf("Discovery failed: %v, using configured address %s", err, d.fallback)
+			return d.fallback, nil
+		}
+		return "", fmt.Errorf("bluetooth discovery failed: %v", err)
+	}
+
+	var selected *DiscoveredDevice
+	for i, device := range devices {
+		matched := d.config.Match(device)
+		logger.Printf("Discovered %s %q (matches: %t)", device.Address, device.Name, matched)
+		if matched && selected == nil {
+			selected = &devices[i]
+		}
+	}
+
+	if selected == nil {
+		if d.fallback != "" {
+			logger.Printf("No ELM327 adapter discovered among %d devices, using configured address %s", len(devices), d.fallback)
+			return d.fallback, nil
+		}
+		return "", fmt.Errorf("no ELM327 adapter discovered among %d devices", len(devices))
+	}
+
+	logger.Printf("Selected adapter %s %q", selected.Address, selected.Name)
+	return selected.Address, nil
+}
+
+// parseMACPrefix разбирает начало MAC адреса ("00:1D:A5") и возвращает его в верхнем регистре
+func parseMACPrefix(prefix string) (string, error) {
+	parts := strings.Split(strings.TrimSpace(prefix), ":")
+	if len(parts) > 6 {
+		return "", fmt.Errorf("invalid MAC prefix %q: expected up to 6 octets", prefix)
+	}
+	for _, part := range parts {
+		if _, err := hex.DecodeString(part); err != nil || len(part) != 2 {
+			return "", fmt.Errorf("invalid MAC prefix %q: expected XX:XX:XX", prefix)
+		}
+	}
+	return strings.ToUpper(strings.Join(parts, ":")), nil
+}
+
+// normalizeDeviceName приводит имя к виду для сравнения: "OBD-II" и "obd ii" -> "OBDII"
+func normalizeDeviceName(name string) string {
+	return strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "_", "").Replace(name))
+}
+
+// formatBDAddr форматирует адрес из порядка байтов HCI (little-endian)
+func formatBDAddr(data []byte) string {
+	parts := make([]string, 6)
+	for i := range parts {
+		parts[i] = fmt.Sprintf("%02X", data[5-i])
+	}
+	return strings.Join(parts, ":")
+}
+
+// Коды событий HCI, используемые при поиске устройств
+const (
+	hciEvtInquiryComplete       = 0x01
+	hciEvtInquiryResult         = 0x02
+	hciEvtRemoteNameComplete    = 0x07
+	hciEvtCommandStatus         = 0x0F
+	hciEvtInquiryResultRSSI     = 0x22
+	hciEvtExtendedInquiryResult = 0x2F
+)
+
+// parseInquiryResult извлекает устройства из событий результата поиска. Имя известно
+// только из расширенного ответа (EIR), для остальных его запрашивают отдельно
+func parseInquiryResult(event byte, params []byte) []DiscoveredDevice {
+	if len(params) < 1 {
+		return nil
+	}
+
+	size := 0
+	switch event {
+	case hciEvtInquiryResult:
+		size = 14
+	case hciEvtInquiryResultRSSI:
+		size = 15
+	case hciEvtExtendedInquiryResult:
+		if len(params) < 15 {
+			return nil
+		}
+		return []DiscoveredDevice{{Address: formatBDAddr(params[1:7]), Name: parseEIRName(params[15:])}}
+	default:
+		return nil
+	}
+
+	var devices []DiscoveredDevice
+	count := int(params[0])
+	data := params[1:]
+	for i := 0; i < count && len(data) >= size; i++ {
+		devices = append(devices, DiscoveredDevice{Address: formatBDAddr(data[:6])})
+		data = data[size:]
+	}
+	return devices
+}
+
+// parseEIRName извлекает полное (0x09) или сокращенное (0x08) имя из данных EIR
+func parseEIRName(eir []byte) string {
+	var short string
+	for len(eir) > 1 {
+		length := int(eir[0])
+		if length == 0 || length+1 > len(eir) {
+			break
+		}
+		field, value := eir[1], eir[2:length+1]
+		switch field {
+		case 0x09:
+			return string(value)
+		case 0x08:
+			short = string(value)
+		}
+		eir = eir[length+1:]
+	}
+	return short
+}
+
+// parseRemoteName извлекает адрес и имя из события Remote Name Request Complete
+func parseRemoteName(params []byte) (string, string, bool) {
+	if len(params) < 7 || params[0] != 0 {
+		return "", "", false
+	}
+	name := params[7:]
+	if end := strings.IndexByte(string(name), 0); end >= 0 {
+		name = name[:end]
+	}
+	return formatBDAddr(params[1:7]), string(name), true
+}
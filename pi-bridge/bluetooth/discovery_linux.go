@@ -0,0 +1,195 @@
+package bluetooth
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Параметры поиска через сокет HCI (как hcitool scan)
+const (
+	hciDevice         = 0 // Контроллер hci0
+	hciFilter         = 2 // Опция сокета HCI_FILTER
+	hciCommandPacket  = 0x01
+	hciEventPacket    = 0x04
+	hciOpInquiry      = 0x0401 // Link Control: Inquiry
+	hciOpRemoteName   = 0x0419 // Link Control: Remote Name Request
+	hciInquiryUnit    = 1280 * time.Millisecond
+	hciMaxInquiry     = 0x30
+	remoteNameTimeout = 5 * time.Second
+)
+
+// hciGIAC - общий код доступа поиска (General Inquiry Access Code, 0x9E8B33)
+var hciGIAC = []byte{0x33, 0x8B, 0x9E}
+
+// hciSocket - сырой сокет HCI контроллера для команд поиска
+type hciSocket struct {
+	file *os.File
+	buf  []byte
+}
+
+// scanDevices ищет устройства Bluetooth Classic поблизости и запрашивает их имена.
+// Требуются права CAP_NET_RAW (мост обычно запущен от root)
+func scanDevices(duration time.Duration) ([]DiscoveredDevice, error) {
+	hci, err := openHCI()
+	if err != nil {
+		return nil, err
+	}
+	defer hci.file.Close()
+
+	length := int((duration + hciInquiryUnit - 1) / hciInquiryUnit)
+	if length < 1 {
+		length = 1
+	}
+	if length > hciMaxInquiry {
+		length = hciMaxInquiry
+	}
+	if err := hci.command(hciOpInquiry, append(append([]byte{}, hciGIAC...), byte(length), 0)); err != nil {
+		return nil, err
+	}
+
+	var devices []DiscoveredDevice
+	seen := make(map[string]int)
+	deadline := time.Now().Add(time.Duration(length)*hciInquiryUnit + remoteNameTimeout)
+
+inquiry:
+	for {
+		event, params, err := hci.event(deadline)
+		if err != nil {
+			return nil, fmt.Errorf("inquiry failed: %v", err)
+		}
+
+		switch event {
+		case hciEvtCommandStatus:
+			if err := commandStatusError(params, hciOpInquiry); err != nil {
+				return nil, err
+			}
+		case hciEvtInquiryComplete:
+			break inquiry
+		default:
+			for _, device := range parseInquiryResult(event, params) {
+				if i, ok := seen[device.Address]; ok {
+					if device.Name != "" {
+						devices[i].Name = device.Name
+					}
+					continue
+				}
+				seen[device.Address] = len(devices)
+				devices = append(devices, device)
+			}
+		}
+	}
+
+	// Имя из обычного результата поиска не приходит, его запрашивают у каждого устройства
+	for i := range devices {
+		if devices[i].Name == "" {
+			devices[i].Name = hci.remoteName(devices[i].Address)
+		}
+	}
+	return devices, nil
+}
+
+// openHCI открывает сырой сокет контроллера и подписывается на все события
+func openHCI() (*hciSocket, error) {
+	fd, err := unix.Socket(unix.AF_BLUETOOTH, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.BTPROTO_HCI)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HCI socket: %v", err)
+	}
+
+	if err := unix.Bind(fd, &unix.SockaddrHCI{Dev: hciDevice, Channel: unix.HCI_CHANNEL_RAW}); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to bind HCI socket to hci%d: %v", hciDevice, err)
+	}
+
+	// struct hci_filter: маска типов пакетов, маска событий, код команды
+	filter := make([]byte, 16)
+	binary.LittleEndian.PutUint32(filter[0:], 1<<hciEventPacket)
+	binary.LittleEndian.PutUint32(filter[4:], 0xFFFFFFFF)
+	binary.LittleEndian.PutUint32(filter[8:], 0xFFFFFFFF)
+	if err := unix.SetsockoptString(fd, unix.SOL_HCI, hciFilter, string(filter)); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to set HCI filter: %v", err)
+	}
+
+	if err := unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to set non-blocking mode: %v", err)
+	}
+
+	return &hciSocket{file: os.NewFile(uintptr(fd), fmt.Sprintf("hci%d", hciDevice)), buf: make([]byte, 260)}, nil
+}
+
+// command отправляет команду контроллеру
+func (h *hciSocket) command(opcode uint16, params []byte) error {
+	packet := []byte{hciCommandPacket, byte(opcode), byte(opcode >> 8), byte(len(params))}
+	if _, err := h.file.Write(append(packet, params...)); err != nil {
+		return fmt.Errorf("failed to send HCI command 0x%04X: %v", opcode, err)
+	}
+	return nil
+}
+
+// event читает следующее событие контроллера до deadline
+func (h *hciSocket) event(deadline time.Time) (byte, []byte, error) {
+	h.file.SetReadDeadline(deadline)
+	for {
+		n, err := h.file.Read(h.buf)
+		if err != nil {
+			return 0, nil, err
+		}
+		if n < 3 || h.buf[0] != hciEventPacket {
+			continue
+		}
+		end := 3 + int(h.buf[2])
+		if end > n {
+			end = n
+		}
+		return h.buf[1], append([]byte(nil), h.buf[3:end]...), nil
+	}
+}
+
+// remoteName запрашивает имя устройства; пустая строка, если устройство не ответило
+func (h *hciSocket) remoteName(address string) string {
+	mac, err := parseMAC(address)
+	if err != nil {
+		return ""
+	}
+
+	params := make([]byte, 10)
+	for i := range mac {
+		params[i] = mac[len(mac)-1-i]
+	}
+	params[6] = 0x02 // Page Scan Repetition Mode R2
+	if err := h.command(hciOpRemoteName, params); err != nil {
+		return ""
+	}
+
+	deadline := time.Now().Add(remoteNameTimeout)
+	for {
+		event, data, err := h.event(deadline)
+		if err != nil {
+			return ""
+		}
+		switch event {
+		case hciEvtCommandStatus:
+			if commandStatusError(data, hciOpRemoteName) != nil {
+				return ""
+			}
+		case hciEvtRemoteNameComplete:
+			if len(data) >= 7 && formatBDAddr(data[1:7]) == address {
+				_, name, _ := parseRemoteName(data)
+				return name
+			}
+		}
+	}
+}
+
+// commandStatusError возвращает ошибку, если контроллер отклонил команду opcode
+func commandStatusError(params []byte, opcode uint16) error {
+	if len(params) < 4 || binary.LittleEndian.Uint16(params[2:]) != opcode || params[0] == 0 {
+		return nil
+	}
+	return fmt.Errorf("HCI command 0x%04X rejected with status 0x%02X", opcode, params[0])
+}
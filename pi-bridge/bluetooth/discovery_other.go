@@ -0,0 +1,13 @@
+//go:build !linux
+
+package bluetooth
+
+import (
+	"fmt"
+	"time"
+)
+
+// scanDevices на платформах кроме Linux недоступен
+func scanDevices(duration time.Duration) ([]DiscoveredDevice, error) {
+	return nil, fmt.Errorf("bluetooth discovery is only supported on Linux")
+}
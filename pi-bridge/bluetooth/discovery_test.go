@@ -0,0 +1,185 @@
+package bluetooth
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestDiscoveryMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		config DiscoveryConfig
+		device DiscoveredDevice
+		want   bool
+	}{
+		{"default name", DiscoveryConfig{}, DiscoveredDevice{"11:22:33:44:55:66", "OBDII"}, true},
+		{"name with separators", DiscoveryConfig{}, DiscoveredDevice{"11:22:33:44:55:66", "obd-ii"}, true},
+		{"name substring", DiscoveryConfig{}, DiscoveredDevice{"11:22:33:44:55:66", "Vgate ELM327 v2.1"}, true},
+		{"other device", DiscoveryConfig{}, DiscoveredDevice{"11:22:33:44:55:66", "Pixel 8"}, false},
+		{"unknown name", DiscoveryConfig{}, DiscoveredDevice{"11:22:33:44:55:66", ""}, false},
+		{"custom names", DiscoveryConfig{Names: []string{"V-LINK"}}, DiscoveredDevice{"11:22:33:44:55:66", "vLink"}, true},
+		{"custom names replace defaults", DiscoveryConfig{Names: []string{"V-LINK"}}, DiscoveredDevice{"11:22:33:44:55:66", "OBDII"}, false},
+		{"mac prefix", DiscoveryConfig{MACPrefix: "00:1d:a5"}, DiscoveredDevice{"00:1D:A5:68:98:8B", ""}, true},
+		{"mac prefix mismatch", DiscoveryConfig{MACPrefix: "00:1D:A5"}, DiscoveredDevice{"00:1D:A6:68:98:8B", "Pixel 8"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.Match(tt.device); got != tt.want {
+				t.Errorf("Match(%+v) = %t, want %t", tt.device, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoveryValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"disabled", Config{Transport: "rfcomm", Address: "00:1D:A5:68:98:8B"}, false},
+		{"without address", Config{Transport: "rfcomm", Discovery: DiscoveryConfig{Enabled: true}}, false},
+		{"with fallback address", Config{Transport: "rfcomm", Address: "00:1D:A5:68:98:8B", Discovery: DiscoveryConfig{Enabled: true}}, false},
+		{"invalid fallback address", Config{Transport: "rfcomm", Address: "OBDII", Discovery: DiscoveryConfig{Enabled: true}}, true},
+		{"mac prefix", Config{Transport: "rfcomm", Discovery: DiscoveryConfig{Enabled: true, MACPrefix: "00:1D:A5"}}, false},
+		{"invalid mac prefix", Config{Transport: "rfcomm", Discovery: DiscoveryConfig{Enabled: true, MACPrefix: "001DA5"}}, true},
+		{"negative duration", Config{Transport: "rfcomm", Discovery: DiscoveryConfig{Enabled: true, Duration: -time.Second}}, true},
+		{"serial transport", Config{DevicePath: "/dev/rfcomm0", Discovery: DiscoveryConfig{Enabled: true}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.ValidateTransport(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTransport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiscovererRediscoversAfterFailure(t *testing.T) {
+	scans := 0
+	results := [][]DiscoveredDevice{
+		{{"11:22:33:44:55:66", "Pixel 8"}, {"00:1D:A5:68:98:8B", "OBDII"}},
+		{{"00:1D:A5:00:00:01", "OBDII"}},
+	}
+	var dialed []string
+	failing := map[string]bool{}
+
+	d := newDiscoverer(Config{Discovery: DiscoveryConfig{Enabled: true}}, func(address string) (io.ReadWriteCloser, error) {
+		dialed = append(dialed, address)
+		if failing[address] {
+			return nil, errors.New("host is down")
+		}
+		return &MockReadWriteCloser{}, nil
+	})
+	d.scan = func(time.Duration) ([]DiscoveredDevice, error) {
+		result := results[scans]
+		scans++
+		return result, nil
+	}
+
+	// Найденный адрес запоминается и используется без повторного поиска
+	for i := 0; i < 2; i++ {
+		if _, err := d.open(); err != nil {
+			t.Fatalf("open failed: %v", err)
+		}
+	}
+	if scans != 1 {
+		t.Errorf("Expected 1 scan, got %d", scans)
+	}
+
+	// Адаптер заменен: после ошибки подключения поиск повторяется
+	failing["00:1D:A5:68:98:8B"] = true
+	if _, err := d.open(); err == nil {
+		t.Fatal("Expected dial error")
+	}
+	if _, err := d.open(); err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+
+	want := []string{"00:1D:A5:68:98:8B", "00:1D:A5:68:98:8B", "00:1D:A5:68:98:8B", "00:1D:A5:00:00:01"}
+	if len(dialed) != len(want) {
+		t.Fatalf("Dialed %v, want %v", dialed, want)
+	}
+	for i := range want {
+		if dialed[i] != want[i] {
+			t.Errorf("Dialed %v, want %v", dialed, want)
+			break
+		}
+	}
+}
+
+func TestDiscovererFallback(t *testing.T) {
+	var dialed string
+	dial := func(address string) (io.ReadWriteCloser, error) {
+		dialed = address
+		return &MockReadWriteCloser{}, nil
+	}
+
+	d := newDiscoverer(Config{Address: "00:1D:A5:68:98:8B", Discovery: DiscoveryConfig{Enabled: true}}, dial)
+	d.scan = func(time.Duration) ([]DiscoveredDevice, error) {
+		return []DiscoveredDevice{{"11:22:33:44:55:66", "Pixel 8"}}, nil
+	}
+	if _, err := d.open(); err != nil || dialed != "00:1D:A5:68:98:8B" {
+		t.Errorf("Expected configured address to be used, dialed %q, err %v", dialed, err)
+	}
+
+	// Без адреса в конфигурации отсутствие адаптера - ошибка подключения
+	d = newDiscoverer(Config{Discovery: DiscoveryConfig{Enabled: true}}, dial)
+	d.scan = func(time.Duration) ([]DiscoveredDevice, error) { return nil, errors.New("permission denied") }
+	if _, err := d.open(); err == nil {
+		t.Error("Expected discovery error")
+	}
+}
+
+func TestParseInquiryResult(t *testing.T) {
+	// Inquiry Result with RSSI: два устройства по 15 байт
+	params := []byte{2,
+		0x8B, 0x98, 0x68, 0xA5, 0x1D, 0x00, 0x01, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00, 0x00, 0xC4,
+		0x66, 0x55, 0x44, 0x33, 0x22, 0x11, 0x01, 0x00, 0x0C, 0x02, 0x5A, 0x00, 0x00, 0x00, 0xB0,
+	}
+	devices := parseInquiryResult(hciEvtInquiryResultRSSI, params)
+	if len(devices) != 2 || devices[0].Address != "00:1D:A5:68:98:8B" || devices[1].Address != "11:22:33:44:55:66" {
+		t.Errorf("Unexpected devices %+v", devices)
+	}
+
+	// Extended Inquiry Result: имя из EIR
+	extended := []byte{1, 0x8B, 0x98, 0x68, 0xA5, 0x1D, 0x00, 0x01, 0x00, 0x00, 0x1F, 0x00, 0x00, 0x00, 0xC4,
+		0x03, 0x03, 0xF0, 0xFF, 0x06, 0x09, 'O', 'B', 'D', 'I', 'I', 0x00}
+	devices = parseInquiryResult(hciEvtExtendedInquiryResult, extended)
+	if len(devices) != 1 || devices[0].Name != "OBDII" {
+		t.Errorf("Unexpected devices %+v", devices)
+	}
+
+	if devices := parseInquiryResult(hciEvtInquiryResult, []byte{1, 0x00}); len(devices) != 0 {
+		t.Errorf("Expected truncated result to be skipped, got %+v", devices)
+	}
+}
+
+func TestParseEIRName(t *testing.T) {
+	if name := parseEIRName([]byte{0x04, 0x08, 'O', 'B', 'D', 0x00}); name != "OBD" {
+		t.Errorf("Expected short name, got %q", name)
+	}
+	if name := parseEIRName([]byte{0x04, 0x08, 'O', 'B', 'D', 0x07, 0x09, 'E', 'L', 'M', '3', '2', '7'}); name != "ELM327" {
+		t.Errorf("Expected complete name, got %q", name)
+	}
+	if name := parseEIRName([]byte{0x10, 0x09, 'O'}); name != "" {
+		t.Errorf("Expected truncated field to be ignored, got %q", name)
+	}
+}
+
+func TestParseRemoteName(t *testing.T) {
+	params := append([]byte{0x00, 0x8B, 0x98, 0x68, 0xA5, 0x1D, 0x00}, "OBDII\x00\x00\x00"...)
+	address, name, ok := parseRemoteName(params)
+	if !ok || address != "00:1D:A5:68:98:8B" || name != "OBDII" {
+		t.Errorf("parseRemoteName = %q, %q, %t", address, name, ok)
+	}
+
+	// Ненулевой статус: устройство не ответило (Page Timeout)
+	if _, _, ok := parseRemoteName([]byte{0x04, 0x8B, 0x98, 0x68, 0xA5, 0x1D, 0x00}); ok {
+		t.Error("Expected failed request to be rejected")
+	}
+}
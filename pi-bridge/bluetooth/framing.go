@@ -0,0 +1,155 @@
+package bluetooth
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// lineTerminators - завершения команд, допустимые в line_terminator
+var lineTerminators = map[string]string{
+	"cr":   "\r",
+	"lf":   "\n",
+	"crlf": "\r\n",
+}
+
+// autoTerminators - завершения команд, перебираемые при автоопределении: адаптер,
+// не ответивший на сброс, пробуется со следующим завершением
+var autoTerminators = []string{"\r", "\r\n", "\n"}
+
+// promptNone в параметре prompt означает адаптер, не выводящий приглашение
+const promptNone = "none"
+
+// promptIdleTimeout - пауза после последних данных, завершающая ответ адаптера без приглашения
+const promptIdleTimeout = 300 * time.Millisecond
+
+// framing описывает, как команды завершаются при отправке и как определяется конец ответа
+type framing struct {
+	terminator string // Завершение команды
+	prompt     byte   // Символ приглашения, завершающий ответ
+	noPrompt   bool   // Приглашения нет: ответ завершается паузой promptIdleTimeout
+}
+
+// newFraming возвращает начальные параметры обмена. Незаданные параметры начинаются
+// со стандартных для ELM327 "\r" и ">" и уточняются автоопределением при инициализации
+func newFraming(c Config) *framing {
+	f := &framing{terminator: "\r", prompt: promptChar}
+	if terminator, ok := lineTerminators[strings.ToLower(c.LineTerminator)]; ok {
+		f.terminator = terminator
+	}
+	switch {
+	case strings.EqualFold(c.Prompt, promptNone):
+		f.noPrompt = true
+	case len(c.Prompt) == 1:
+		f.prompt = c.Prompt[0]
+	}
+	return f
+}
+
+// describe возвращает параметры обмена для журнала
+func (f *framing) describe() string {
+	if f.noPrompt {
+		return fmt.Sprintf("terminator %q, no prompt", f.terminator)
+	}
+	return fmt.Sprintf("terminator %q, prompt %q", f.terminator, f.prompt)
+}
+
+// ValidateFraming проверяет завершение команд, символ приглашения, предел размера ответа
+// и паузу, завершающую ответ без приглашения
+func (c Config) ValidateFraming() error {
+	if c.MaxResponseSize < 0 {
+		return fmt.Errorf("invalid max response size %d", c.MaxResponseSize)
+	}
+	if c.PartialResponseTimeout < 0 {
+		return fmt.Errorf("invalid partial response timeout %v", c.PartialResponseTimeout)
+	}
+	if c.LineTerminator != "" {
+		if _, ok := lineTerminators[strings.ToLower(c.LineTerminator)]; !ok {
+			return fmt.Errorf("invalid line terminator %q: expected cr, lf or crlf", c.LineTerminator)
+		}
+	}
+	if c.Prompt == "" || strings.EqualFold(c.Prompt, promptNone) {
+		return nil
+	}
+	if !isPromptCandidate(c.Prompt) {
+		return fmt.Errorf("invalid prompt %q: expected a single symbol character or none", c.Prompt)
+	}
+	return nil
+}
+
+// partialResponseTimeout возвращает паузу, завершающую ответ без приглашения. Пауза не
+// короче read_timeout не сработала бы (команду раньше сбросит таймаут ожидания),
+// поэтому вместо нее используется половина read_timeout
+func (c Config) partialResponseTimeout() time.Duration {
+	if c.ReadTimeout > 0 && c.PartialResponseTimeout >= c.ReadTimeout {
+		return c.ReadTimeout / 2
+	}
+	return c.PartialResponseTimeout
+}
+
+// isPromptCandidate проверяет, может ли строка быть символом приглашения: буквы, цифры
+// и пробелы встречаются в данных ответа, а "?" - ответ ELM327 на неизвестную команду
+func isPromptCandidate(s string) bool {
+	if len(s) != 1 {
+		return false
+	}
+	c := rune(s[0])
+	return c > ' ' && c < unicode.MaxASCII && c != '?' && !unicode.IsLetter(c) && !unicode.IsDigit(c)
+}
+
+// framingProbe - данные, полученные после последнего приглашения. По ним при
+// инициализации определяется, почему адаптер не завершил ответ
+type framingProbe struct {
+	lines   []string // Завершенные строки
+	partial string   // Незавершенная строка
+}
+
+// currentFraming возвращает текущие параметры обмена
+func (a *Adapter) currentFraming() *framing {
+	return a.framing.Load()
+}
+
+// detectFraming уточняет параметры обмена после команды инициализации, оставшейся без
+// ответа. Незавершенная строка из одного знака - это приглашение другого вида; строки
+// без приглашения - адаптер без приглашения; тишина или только эхо команды - адаптер
+// не распознал завершение команды. Возвращает true, если параметры изменились
+func (a *Adapter) detectFraming(command string) bool {
+	current := a.currentFraming()
+	next := *current
+
+	var probe framingProbe
+	if p := a.probe.Load(); p != nil {
+		probe = *p
+	}
+	var answered bool
+	for _, line := range probe.lines {
+		if !strings.EqualFold(strings.Join(strings.Fields(line), ""), strings.Join(strings.Fields(command), "")) {
+			answered = true
+		}
+	}
+
+	autoPrompt := a.config.Prompt == ""
+	autoTerminator := a.config.LineTerminator == ""
+	switch {
+	case autoPrompt && answered && isPromptCandidate(probe.partial):
+		next.prompt = probe.partial[0]
+		next.noPrompt = false
+	case autoPrompt && answered && probe.partial == "" && !current.noPrompt:
+		next.noPrompt = true
+	case autoTerminator && !answered:
+		next.terminator = autoTerminators[0]
+		for i, terminator := range autoTerminators {
+			if terminator == current.terminator {
+				next.terminator = autoTerminators[(i+1)%len(autoTerminators)]
+			}
+		}
+	default:
+		return false
+	}
+
+	logger.Printf("No complete answer to %s, switching to %s", command, next.describe())
+	a.probe.Store(nil)
+	a.framing.Store(&next)
+	return true
+}
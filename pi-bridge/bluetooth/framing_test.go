@@ -0,0 +1,233 @@
+package bluetooth
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"elm327-bridge/simulator"
+)
+
+func TestValidateFraming(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"defaults", Config{}, false},
+		{"lf", Config{LineTerminator: "LF"}, false},
+		{"crlf and hash prompt", Config{LineTerminator: "crlf", Prompt: "#"}, false},
+		{"no prompt", Config{Prompt: "none"}, false},
+		{"unknown terminator", Config{LineTerminator: "nul"}, true},
+		{"letter prompt", Config{Prompt: "E"}, true},
+		{"long prompt", Config{Prompt: ">>"}, true},
+		{"question mark prompt", Config{Prompt: "?"}, true},
+		{"response size limit", Config{MaxResponseSize: 4096}, false},
+		{"negative response size limit", Config{MaxResponseSize: -1}, true},
+		{"partial response timeout", Config{PartialResponseTimeout: time.Second, ReadTimeout: 3 * time.Second}, false},
+		{"negative partial response timeout", Config{PartialResponseTimeout: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.ValidateFraming(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateFraming() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPartialResponseTimeout(t *testing.T) {
+	tests := []struct {
+		partial, read, want time.Duration
+	}{
+		{time.Second, 3 * time.Second, time.Second},
+		{0, 3 * time.Second, 0},
+		{3 * time.Second, 3 * time.Second, 1500 * time.Millisecond},
+		{time.Second, 0, time.Second},
+	}
+	for _, tt := range tests {
+		config := Config{PartialResponseTimeout: tt.partial, ReadTimeout: tt.read}
+		if got := config.partialResponseTimeout(); got != tt.want {
+			t.Errorf("partialResponseTimeout(%v, %v) = %v, want %v", tt.partial, tt.read, got, tt.want)
+		}
+	}
+}
+
+func TestNewFraming(t *testing.T) {
+	tests := []struct {
+		config   Config
+		expected framing
+	}{
+		{Config{}, framing{terminator: "\r", prompt: '>'}},
+		{Config{LineTerminator: "crlf", Prompt: "#"}, framing{terminator: "\r\n", prompt: '#'}},
+		{Config{LineTerminator: "lf", Prompt: "NONE"}, framing{terminator: "\n", prompt: '>', noPrompt: true}},
+	}
+
+	for _, tt := range tests {
+		if f := newFraming(tt.config); *f != tt.expected {
+			t.Errorf("newFraming(%+v) = %+v, expected %+v", tt.config, *f, tt.expected)
+		}
+	}
+}
+
+func TestResponseAssemblerCustomPrompt(t *testing.T) {
+	assembler := responseAssembler{prompt: '#'}
+	responses := assembler.Feed([]byte("ELM327 v1.5\n\n#41 0C 1A F8\n\n#"))
+	expected := []string{"ELM327 v1.5", "41 0C 1A F8"}
+	if !reflect.DeepEqual(responses, expected) {
+		t.Errorf("Expected %q, got %q", expected, responses)
+	}
+}
+
+func TestResponseAssemblerWithoutPrompt(t *testing.T) {
+	assembler := responseAssembler{noPrompt: true}
+	if responses := assembler.Feed([]byte("SEARCHING...\r41 0C 1A F8\r\r>")); responses != nil {
+		t.Errorf("Expected no responses before flush, got %q", responses)
+	}
+	if assembler.Searching() {
+		t.Error("Expected search to end with the first answer line")
+	}
+
+	// Символ ">" без приглашения - часть данных
+	response, ok := assembler.Flush()
+	if !ok || response != "41 0C 1A F8\r>" {
+		t.Errorf("Expected flushed response, got %q (%v)", response, ok)
+	}
+	if _, ok := assembler.Flush(); ok {
+		t.Error("Expected empty flush after response")
+	}
+}
+
+func TestMonitorSplitterWithoutPrompt(t *testing.T) {
+	monitor := monitorSplitter{noPrompt: true}
+	lines, rest, stopped := monitor.Feed([]byte("7E8 03 41 0D 32\rSTOPPED\r\r"))
+	if !stopped {
+		t.Fatal("Expected STOPPED line to end monitoring")
+	}
+	if !reflect.DeepEqual(lines, []string{"7E8 03 41 0D 32"}) {
+		t.Errorf("Unexpected frames %q", lines)
+	}
+	if string(rest) != "STOPPED\r\r" {
+		t.Errorf("Expected STOPPED to be passed to the assembler, got %q", rest)
+	}
+}
+
+func TestDetectFraming(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		probe    *framingProbe
+		changed  bool
+		expected framing
+	}{
+		{
+			name:     "silence switches terminator",
+			probe:    nil,
+			changed:  true,
+			expected: framing{terminator: "\r\n", prompt: '>'},
+		},
+		{
+			name:     "echo only switches terminator",
+			probe:    &framingProbe{lines: []string{"AT Z"}},
+			changed:  true,
+			expected: framing{terminator: "\r\n", prompt: '>'},
+		},
+		{
+			name:     "other prompt",
+			probe:    &framingProbe{lines: []string{"ELM327 v1.5"}, partial: "#"},
+			changed:  true,
+			expected: framing{terminator: "\r", prompt: '#'},
+		},
+		{
+			name:     "no prompt",
+			probe:    &framingProbe{lines: []string{"ELM327 v1.5"}},
+			changed:  true,
+			expected: framing{terminator: "\r", prompt: '>', noPrompt: true},
+		},
+		{
+			name:     "fixed terminator",
+			config:   Config{LineTerminator: "cr"},
+			changed:  false,
+			expected: framing{terminator: "\r", prompt: '>'},
+		},
+		{
+			name:     "fixed prompt",
+			config:   Config{Prompt: ">"},
+			probe:    &framingProbe{lines: []string{"ELM327 v1.5"}},
+			changed:  false,
+			expected: framing{terminator: "\r", prompt: '>'},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			adapter := NewAdapter(tt.config, nil, nil)
+			adapter.probe.Store(tt.probe)
+			if changed := adapter.detectFraming("ATZ"); changed != tt.changed {
+				t.Errorf("Expected changed=%v, got %v", tt.changed, changed)
+			}
+			if f := adapter.currentFraming(); *f != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, *f)
+			}
+		})
+	}
+}
+
+func TestAdapterFraming(t *testing.T) {
+	tests := []struct {
+		name       string
+		terminator byte
+		prompt     byte
+		noPrompt   bool
+		config     Config
+	}{
+		{name: "auto-detected LF terminator and prompt", terminator: '\n', prompt: '#'},
+		{name: "configured no prompt", noPrompt: true, config: Config{Prompt: "none"}},
+		{name: "auto-detected no prompt", noPrompt: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sim := simulator.New(nil)
+			sim.Terminator = tt.terminator
+			sim.Prompt = tt.prompt
+			sim.NoPrompt = tt.noPrompt
+
+			responsesChan := make(chan string, 10)
+			commandsChan := make(chan string, 10)
+			config := tt.config
+			config.InitCommands = []string{"ATZ", "ATE0"}
+			config.ReconnectInterval = 10 * time.Millisecond
+			config.ReadTimeout = 500 * time.Millisecond
+			adapter := NewAdapter(config, responsesChan, commandsChan)
+			adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return sim, nil }))
+
+			connected := make(chan struct{}, 1)
+			adapter.SetConnectHandler(func() { notify(connected) })
+			adapter.Start(context.Background())
+			defer func() {
+				sim.Close()
+				adapter.Stop()
+			}()
+
+			select {
+			case <-connected:
+			case <-time.After(10 * time.Second):
+				t.Fatal("Adapter initialization did not complete")
+			}
+
+			commandsChan <- "0105"
+			select {
+			case response := <-responsesChan:
+				if response != "41 05 82" {
+					t.Errorf("Expected answer to 0105, got %q", response)
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatal("Expected answer to 0105")
+			}
+		})
+	}
+}
@@ -0,0 +1,408 @@
+package bluetooth
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UUID характеристик BLE адаптеров ELM327 по умолчанию (сервис FFF0 большинства клонов)
+const (
+	defaultBLENotifyUUID = "FFF1"
+	defaultBLEWriteUUID  = "FFF2"
+)
+
+// Коды операций протокола атрибутов (ATT), используемые мостом
+const (
+	attErrorResponse    = 0x01
+	attFindInfoRequest  = 0x04
+	attFindInfoResponse = 0x05
+	attReadByTypeReq    = 0x08
+	attReadByTypeResp   = 0x09
+	attWriteRequest     = 0x12
+	attWriteResponse    = 0x13
+	attNotification     = 0x1B
+	attIndication       = 0x1D
+	attConfirmation     = 0x1E
+	attWriteCommand     = 0x52
+)
+
+// UUID атрибутов GATT: объявление характеристики и дескриптор подписки (CCCD)
+const (
+	gattCharacteristicUUID = 0x2803
+	gattClientConfigUUID   = 0x2902
+)
+
+// Свойства характеристики, нужные мосту
+const (
+	gattPropNotify   = 0x10
+	gattPropIndicate = 0x20
+)
+
+// attDefaultMTU - MTU ATT без согласования; полезная нагрузка Write Command на 3 байта меньше
+const attDefaultMTU = 23
+
+// attMaxPDU - максимальный размер PDU, который мост принимает от адаптера
+const attMaxPDU = 517
+
+// bleBaseUUID - базовый UUID Bluetooth, в который подставляются 16-битные UUID
+var bleBaseUUID = [16]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x10, 0x00, 0x80, 0x00, 0x00, 0x80, 0x5F, 0x9B, 0x34, 0xFB}
+
+// gattCharacteristic - найденная характеристика: дескрипторы лежат после value до end
+type gattCharacteristic struct {
+	handle     uint16
+	properties byte
+	value      uint16
+	end        uint16
+	uuid       [16]byte
+}
+
+// gattConn - поток данных ELM327 поверх GATT: запись в характеристику записи,
+// чтение из уведомлений характеристики ответа
+type gattConn struct {
+	conn    io.ReadWriteCloser
+	notify  uint16
+	write   uint16
+	writeMu sync.Mutex
+	buffer  []byte
+	pending []byte
+}
+
+// dialBLE подключается к BLE адаптеру и подписывается на уведомления
+func dialBLE(config Config) (io.ReadWriteCloser, error) {
+	logger.Printf("Attempting to connect to %s (BLE)", config.Address)
+
+	notifyUUID, err := parseUUID(bleNotifyUUID(config))
+	if err != nil {
+		return nil, err
+	}
+	writeUUID, err := parseUUID(bleWriteUUID(config))
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := dialATT(config.Address, config.ConnectTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	gatt, err := newGATTConn(conn, notifyUUID, writeUUID, connectDeadline(config.ConnectTimeout))
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return gatt, nil
+}
+
+// newGATTConn находит характеристики и включает уведомления до deadline
+func newGATTConn(conn io.ReadWriteCloser, notifyUUID, writeUUID [16]byte, deadline time.Time) (*gattConn, error) {
+	g := &gattConn{conn: conn, buffer: make([]byte, attMaxPDU)}
+
+	// Без дедлайна молчащий адаптер заблокировал бы подключение навсегда
+	if d, ok := conn.(readDeadliner); ok {
+		d.SetReadDeadline(deadline)
+		defer d.SetReadDeadline(time.Time{})
+	}
+
+	chars, err := g.discoverCharacteristics()
+	if err != nil {
+		return nil, err
+	}
+
+	var notify, write *gattCharacteristic
+	for i := range chars {
+		if chars[i].uuid == notifyUUID && notify == nil {
+			notify = &chars[i]
+		}
+		if chars[i].uuid == writeUUID && write == nil {
+			write = &chars[i]
+		}
+	}
+	if notify == nil {
+		return nil, fmt.Errorf("BLE notify characteristic %s not found", formatUUID(notifyUUID))
+	}
+	if write == nil {
+		return nil, fmt.Errorf("BLE write characteristic %s not found", formatUUID(writeUUID))
+	}
+
+	if err := g.subscribe(notify); err != nil {
+		return nil, err
+	}
+
+	g.notify = notify.value
+	g.write = write.value
+	logger.Printf("BLE characteristics: notify 0x%04X, write 0x%04X", g.notify, g.write)
+	return g, nil
+}
+
+// discoverCharacteristics читает объявления всех характеристик (Read By Type 0x2803)
+func (g *gattConn) discoverCharacteristics() ([]gattCharacteristic, error) {
+	var chars []gattCharacteristic
+	start := uint16(0x0001)
+
+	for {
+		request := []byte{attReadByTypeReq, 0, 0, 0xFF, 0xFF, 0, 0}
+		binary.LittleEndian.PutUint16(request[1:], start)
+		binary.LittleEndian.PutUint16(request[5:], gattCharacteristicUUID)
+
+		response, err := g.request(request, attReadByTypeResp)
+		if err != nil {
+			return nil, err
+		}
+		if response == nil {
+			break // Attribute Not Found: объявлений больше нет
+		}
+		if len(response) < 2 || response[1] < 7 {
+			return nil, fmt.Errorf("malformed ATT Read By Type response")
+		}
+
+		size := int(response[1])
+		last := start
+		for data := response[2:]; len(data) >= size; data = data[size:] {
+			handle := binary.LittleEndian.Uint16(data)
+			chars = append(chars, gattCharacteristic{
+				handle:     handle,
+				properties: data[2],
+				value:      binary.LittleEndian.Uint16(data[3:]),
+				end:        0xFFFF,
+				uuid:       uuidFromWire(data[5:size]),
+			})
+			last = handle
+		}
+
+		if last == 0xFFFF || last < start {
+			break
+		}
+		start = last + 1
+	}
+
+	// Дескрипторы характеристики заканчиваются перед объявлением следующей
+	for i := 0; i+1 < len(chars); i++ {
+		chars[i].end = chars[i+1].handle - 1
+	}
+	return chars, nil
+}
+
+// subscribe находит дескриптор CCCD характеристики и включает уведомления или индикации
+func (g *gattConn) subscribe(char *gattCharacteristic) error {
+	if char.properties&(gattPropNotify|gattPropIndicate) == 0 {
+		return fmt.Errorf("BLE characteristic 0x%04X does not support notifications", char.value)
+	}
+
+	request := []byte{attFindInfoRequest, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint16(request[1:], char.value+1)
+	binary.LittleEndian.PutUint16(request[3:], char.end)
+	response, err := g.request(request, attFindInfoResponse)
+	if err != nil {
+		return err
+	}
+
+	var cccd uint16
+	if len(response) >= 2 && response[1] == 0x01 {
+		for data := response[2:]; len(data) >= 4; data = data[4:] {
+			if binary.LittleEndian.Uint16(data[2:]) == gattClientConfigUUID {
+				cccd = binary.LittleEndian.Uint16(data)
+				break
+			}
+		}
+	}
+	if cccd == 0 {
+		return fmt.Errorf("BLE characteristic 0x%04X has no client configuration descriptor", char.value)
+	}
+
+	value := uint16(0x0001)
+	if char.properties&gattPropNotify == 0 {
+		value = 0x0002
+	}
+	write := []byte{attWriteRequest, 0, 0, 0, 0}
+	binary.LittleEndian.PutUint16(write[1:], cccd)
+	binary.LittleEndian.PutUint16(write[3:], value)
+	response, err = g.request(write, attWriteResponse)
+	if err != nil {
+		return err
+	}
+	if response == nil {
+		return fmt.Errorf("failed to enable BLE notifications on 0x%04X", char.value)
+	}
+	return nil
+}
+
+// request отправляет запрос ATT и ждет ответа с кодом expect. Ответ Attribute Not Found
+// возвращается как nil без ошибки, остальные ошибки ATT - как ошибка
+func (g *gattConn) request(pdu []byte, expect byte) ([]byte, error) {
+	if _, err := g.conn.Write(pdu); err != nil {
+		return nil, fmt.Errorf("failed to send ATT request: %v", err)
+	}
+
+	for {
+		response, err := g.readPDU()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ATT response: %v", err)
+		}
+		switch {
+		case response[0] == expect:
+			return response, nil
+		case response[0] == attErrorResponse && len(response) >= 5 && response[1] == pdu[0]:
+			if response[4] == 0x0A {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("ATT request 0x%02X failed with error 0x%02X", pdu[0], response[4])
+		case response[0] == attIndication:
+			g.confirm()
+		}
+	}
+}
+
+// readPDU читает один PDU (сокет L2CAP сохраняет границы сообщений)
+func (g *gattConn) readPDU() ([]byte, error) {
+	for {
+		n, err := g.conn.Read(g.buffer)
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			return g.buffer[:n], nil
+		}
+	}
+}
+
+// confirm подтверждает индикацию, иначе адаптер перестанет их присылать
+func (g *gattConn) confirm() error {
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+	_, err := g.conn.Write([]byte{attConfirmation})
+	return err
+}
+
+// Read возвращает данные уведомлений характеристики ответа
+func (g *gattConn) Read(p []byte) (int, error) {
+	for len(g.pending) == 0 {
+		pdu, err := g.readPDU()
+		if err != nil {
+			return 0, err
+		}
+		if len(pdu) < 3 || (pdu[0] != attNotification && pdu[0] != attIndication) {
+			continue
+		}
+		if pdu[0] == attIndication {
+			if err := g.confirm(); err != nil {
+				return 0, err
+			}
+		}
+		if binary.LittleEndian.Uint16(pdu[1:]) != g.notify {
+			continue
+		}
+		g.pending = append(g.pending[:0], pdu[3:]...)
+	}
+
+	n := copy(p, g.pending)
+	g.pending = g.pending[n:]
+	return n, nil
+}
+
+// Write отправляет данные в характеристику записи командами без подтверждения,
+// частями по размеру MTU
+func (g *gattConn) Write(p []byte) (int, error) {
+	g.writeMu.Lock()
+	defer g.writeMu.Unlock()
+
+	chunk := attDefaultMTU - 3
+	for offset := 0; offset < len(p); offset += chunk {
+		end := offset + chunk
+		if end > len(p) {
+			end = len(p)
+		}
+		pdu := make([]byte, 3, 3+end-offset)
+		pdu[0] = attWriteCommand
+		binary.LittleEndian.PutUint16(pdu[1:], g.write)
+		pdu = append(pdu, p[offset:end]...)
+		if _, err := g.conn.Write(pdu); err != nil {
+			return offset, err
+		}
+	}
+	return len(p), nil
+}
+
+// SetReadDeadline задает дедлайн чтения уведомлений
+func (g *gattConn) SetReadDeadline(t time.Time) error {
+	if d, ok := g.conn.(readDeadliner); ok {
+		return d.SetReadDeadline(t)
+	}
+	return nil
+}
+
+// Close закрывает канал ATT
+func (g *gattConn) Close() error {
+	return g.conn.Close()
+}
+
+// bleNotifyUUID возвращает UUID характеристики, из которой приходят ответы
+func bleNotifyUUID(c Config) string {
+	if c.BLENotifyUUID == "" {
+		return defaultBLENotifyUUID
+	}
+	return c.BLENotifyUUID
+}
+
+// bleWriteUUID возвращает UUID характеристики, в которую пишутся команды
+func bleWriteUUID(c Config) string {
+	if c.BLEWriteUUID == "" {
+		return defaultBLEWriteUUID
+	}
+	return c.BLEWriteUUID
+}
+
+// parseUUID разбирает 16-битный ("FFF1") или полный 128-битный UUID
+func parseUUID(value string) ([16]byte, error) {
+	var uuid [16]byte
+	text := strings.ReplaceAll(strings.TrimSpace(value), "-", "")
+
+	switch len(text) {
+	case 4:
+		short, err := hex.DecodeString(text)
+		if err != nil {
+			break
+		}
+		uuid = bleBaseUUID
+		copy(uuid[2:4], short)
+		return uuid, nil
+	case 32:
+		full, err := hex.DecodeString(text)
+		if err != nil {
+			break
+		}
+		copy(uuid[:], full)
+		return uuid, nil
+	}
+	return uuid, fmt.Errorf("invalid BLE UUID %q: expected 16-bit (FFF1) or 128-bit UUID", value)
+}
+
+// uuidFromWire переводит UUID из порядка байтов ATT (little-endian) в полный вид
+func uuidFromWire(data []byte) [16]byte {
+	var uuid [16]byte
+	switch len(data) {
+	case 2:
+		uuid = bleBaseUUID
+		uuid[2], uuid[3] = data[1], data[0]
+	case 16:
+		for i := range data {
+			uuid[i] = data[15-i]
+		}
+	}
+	return uuid
+}
+
+// formatUUID форматирует UUID для сообщений: 16-битные UUID выводятся коротко
+func formatUUID(uuid [16]byte) string {
+	short := uuid
+	short[2], short[3] = 0, 0
+	if short == bleBaseUUID {
+		return fmt.Sprintf("%02X%02X", uuid[2], uuid[3])
+	}
+	h := strings.ToUpper(hex.EncodeToString(uuid[:]))
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
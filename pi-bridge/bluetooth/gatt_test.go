@@ -0,0 +1,147 @@
+package bluetooth
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeATTServer эмулирует GATT сервер BLE адаптера: характеристика FFF1 (уведомления,
+// CCCD 0x0012) и FFF2 (запись без ответа)
+type fakeATTServer struct {
+	conn   net.Conn
+	cccd   chan []byte
+	writes chan []byte
+}
+
+func newFakeATTServer(conn net.Conn) *fakeATTServer {
+	s := &fakeATTServer{conn: conn, cccd: make(chan []byte, 1), writes: make(chan []byte, 10)}
+	go s.serve()
+	return s
+}
+
+func (s *fakeATTServer) serve() {
+	buf := make([]byte, attMaxPDU)
+	for {
+		n, err := s.conn.Read(buf)
+		if err != nil {
+			return
+		}
+		pdu := append([]byte(nil), buf[:n]...)
+
+		switch pdu[0] {
+		case attReadByTypeReq:
+			if pdu[1] > 0x10 {
+				s.conn.Write([]byte{attErrorResponse, attReadByTypeReq, pdu[1], pdu[2], 0x0A})
+				continue
+			}
+			s.conn.Write([]byte{attReadByTypeResp, 7,
+				0x10, 0x00, 0x12, 0x11, 0x00, 0xF1, 0xFF,
+				0x13, 0x00, 0x04, 0x14, 0x00, 0xF2, 0xFF})
+		case attFindInfoRequest:
+			s.conn.Write([]byte{attFindInfoResponse, 0x01, 0x12, 0x00, 0x02, 0x29})
+		case attWriteRequest:
+			s.cccd <- pdu
+			s.conn.Write([]byte{attWriteResponse})
+		case attWriteCommand:
+			s.writes <- pdu
+		}
+	}
+}
+
+func TestGATTConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	fake := newFakeATTServer(server)
+
+	notifyUUID, _ := parseUUID("FFF1")
+	writeUUID, _ := parseUUID("fff2")
+	gatt, err := newGATTConn(client, notifyUUID, writeUUID, time.Now().Add(time.Second))
+	if err != nil {
+		t.Fatalf("newGATTConn failed: %v", err)
+	}
+	if gatt.notify != 0x0011 || gatt.write != 0x0014 {
+		t.Errorf("Unexpected handles: notify 0x%04X, write 0x%04X", gatt.notify, gatt.write)
+	}
+
+	// Уведомления включаются записью 0x0001 в CCCD
+	if cccd := <-fake.cccd; !bytes.Equal(cccd, []byte{attWriteRequest, 0x12, 0x00, 0x01, 0x00}) {
+		t.Errorf("Unexpected CCCD write % X", cccd)
+	}
+
+	// Команда длиннее MTU разбивается на Write Command по 20 байт
+	command := []byte("ATSH7E0\rATCRA7E8\r0100\r01")
+	go gatt.Write(command)
+	var written []byte
+	for len(written) < len(command) {
+		pdu := <-fake.writes
+		if pdu[1] != 0x14 || len(pdu) > attDefaultMTU {
+			t.Fatalf("Unexpected write PDU % X", pdu)
+		}
+		written = append(written, pdu[3:]...)
+	}
+	if !bytes.Equal(written, command) {
+		t.Errorf("Written %q, want %q", written, command)
+	}
+
+	// Уведомления других характеристик пропускаются, индикации подтверждаются
+	go func() {
+		server.Write([]byte{attNotification, 0x20, 0x00, 'X'})
+		server.Write(append([]byte{attNotification, 0x11, 0x00}, "41 0C"...))
+		server.Write(append([]byte{attIndication, 0x11, 0x00}, " 1A F0\r>"...))
+	}()
+
+	var received []byte
+	buf := make([]byte, 4)
+	for !bytes.HasSuffix(received, []byte(">")) {
+		n, err := gatt.Read(buf)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		received = append(received, buf[:n]...)
+	}
+	if string(received) != "41 0C 1A F0\r>" {
+		t.Errorf("Received %q", received)
+	}
+}
+
+func TestGATTConnMissingCharacteristic(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	newFakeATTServer(server)
+
+	notifyUUID, _ := parseUUID("FFE1")
+	writeUUID, _ := parseUUID("FFF2")
+	if _, err := newGATTConn(client, notifyUUID, writeUUID, time.Now().Add(time.Second)); err == nil {
+		t.Fatal("Expected error for missing characteristic")
+	}
+}
+
+func TestParseUUID(t *testing.T) {
+	short, err := parseUUID("FFF1")
+	if err != nil {
+		t.Fatalf("parseUUID failed: %v", err)
+	}
+	full, err := parseUUID("0000fff1-0000-1000-8000-00805F9B34FB")
+	if err != nil {
+		t.Fatalf("parseUUID failed: %v", err)
+	}
+	if short != full {
+		t.Errorf("Expected 16-bit and full UUID to match: %s != %s", formatUUID(short), formatUUID(full))
+	}
+	if formatUUID(short) != "FFF1" {
+		t.Errorf("formatUUID = %s", formatUUID(short))
+	}
+	if uuidFromWire([]byte{0xF1, 0xFF}) != short {
+		t.Error("Expected wire UUID to match")
+	}
+
+	for _, value := range []string{"", "FFF", "GGGG", "0000fff1-0000-1000-8000"} {
+		if _, err := parseUUID(value); err == nil {
+			t.Errorf("Expected error for %q", value)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package bluetooth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// hotplugSettleDelay - пауза после появления узла устройства: udev выставляет права
+// доступа уже после создания узла, и слишком раннее открытие завершается ошибкой
+const hotplugSettleDelay = 500 * time.Millisecond
+
+// watchDevice следит за появлением и исчезновением узла устройства path. Отслеживается
+// каталог устройства, так как самого узла до подключения адаптера нет. В канал передается
+// true при появлении узла и false при его удалении; наблюдение завершается по stop
+func watchDevice(path string, stop <-chan struct{}) (<-chan bool, error) {
+	path = filepath.Clean(path)
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create device watcher: %v", err)
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("failed to watch %s: %v", filepath.Dir(path), err)
+	}
+
+	events := make(chan bool)
+	go func() {
+		defer watcher.Close()
+		for {
+			select {
+			case <-stop:
+				return
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) != path {
+					continue
+				}
+
+				var present bool
+				switch {
+				case event.Has(fsnotify.Create):
+					present = true
+				case event.Has(fsnotify.Remove), event.Has(fsnotify.Rename):
+					present = false
+				default:
+					continue
+				}
+				select {
+				case events <- present:
+				case <-stop:
+					return
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Printf("Device watcher error: %v", err)
+			}
+		}
+	}()
+	return events, nil
+}
+
+// startDeviceWatch включает отслеживание device_path, если оно задано конфигурацией.
+// Без наблюдения адаптер подключается только по таймеру переподключения
+func (a *Adapter) startDeviceWatch() {
+	if !a.config.HotPlug {
+		return
+	}
+	events, err := watchDevice(a.config.DevicePath, a.ctx.Done())
+	if err != nil {
+		logger.Printf("Device hot-plug detection disabled: %v", err)
+		return
+	}
+	a.deviceEvents = events
+	logger.Printf("Watching %s for hot-plug", a.config.DevicePath)
+}
+
+// devicePresent проверяет наличие узла устройства. Без наблюдения за устройством
+// подключение не откладывается: ошибку открытия сообщит транспорт
+func (a *Adapter) devicePresent() bool {
+	if a.deviceEvents == nil {
+		return true
+	}
+	_, err := os.Stat(a.config.DevicePath)
+	return err == nil
+}
+
+// onDeviceEvent подключается сразу после появления устройства и закрывает соединение
+// при его исчезновении (вызывается из цикла переподключения)
+func (a *Adapter) onDeviceEvent(present bool) {
+	if !present {
+		a.deviceRemoved()
+		return
+	}
+	if !a.active.Load() || a.isConnected() {
+		return
+	}
+
+	logger.Printf("Device %s appeared, connecting", a.config.DevicePath)
+	select {
+	case <-time.After(hotplugSettleDelay):
+	case <-a.ctx.Done():
+		return
+	}
+	if err := a.connect(); err != nil {
+		logger.Printf("Connection after hot-plug failed: %v", err)
+	}
+}
+
+// deviceRemoved закрывает соединение с исчезнувшим устройством. Запросы клиентов,
+// ожидающие ответа, завершаются ошибкой сразу, не дожидаясь таймаута команды
+func (a *Adapter) deviceRemoved() {
+	logger.Printf("Device %s removed, waiting for it to reappear", a.config.DevicePath)
+
+	err := fmt.Errorf("adapter device %s removed", a.config.DevicePath)
+	for _, item := range a.pending.drain() {
+		a.requests.Finish(item.request, nil, err)
+	}
+	if a.isConnected() {
+		a.closeConnection(err.Error())
+	}
+}
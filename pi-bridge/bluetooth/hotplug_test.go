@@ -0,0 +1,107 @@
+package bluetooth
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"elm327-bridge/simulator"
+)
+
+func TestWatchDevice(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rfcomm0")
+	stop := make(chan struct{})
+	defer close(stop)
+
+	events, err := watchDevice(path, stop)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expect := func(want bool) {
+		t.Helper()
+		select {
+		case present := <-events:
+			if present != want {
+				t.Errorf("Expected present=%v, got %v", want, present)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Expected device event present=%v", want)
+		}
+	}
+
+	// Другие узлы каталога не отслеживаются
+	if err := os.WriteFile(filepath.Join(dir, "ttyUSB0"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	expect(true)
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	expect(false)
+}
+
+func TestWatchDeviceMissingDirectory(t *testing.T) {
+	stop := make(chan struct{})
+	defer close(stop)
+	if _, err := watchDevice(filepath.Join(t.TempDir(), "missing", "rfcomm0"), stop); err == nil {
+		t.Error("Expected error for missing device directory")
+	}
+}
+
+func TestAdapterHotPlug(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rfcomm0")
+	sim := simulator.New(nil)
+
+	config := DefaultConfig()
+	config.DevicePath = path
+	config.HotPlug = true
+	config.ReconnectInterval = time.Hour // Подключение только по событию устройства
+	config.ReadTimeout = 100 * time.Millisecond
+	config.HeartbeatInterval = 0
+	adapter := NewAdapter(config, make(chan string, 10), make(chan string, 10))
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return sim, nil }))
+
+	connected := make(chan struct{}, 1)
+	adapter.SetConnectHandler(func() { notify(connected) })
+	adapter.Start(context.Background())
+	defer func() {
+		sim.Close()
+		adapter.Stop()
+	}()
+
+	// Пока устройства нет, адаптер не подключается
+	select {
+	case <-connected:
+		t.Fatal("Adapter connected before the device appeared")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := os.WriteFile(path, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Adapter did not connect after the device appeared")
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for adapter.isConnected() {
+		if time.Now().After(deadline) {
+			t.Fatal("Adapter stayed connected after the device was removed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,111 @@
+package bluetooth
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// InitProfile - именованный набор команд инициализации ELM327 для марки или протокола.
+// Одна общая последовательность init_commands не подходит сразу для K-Line Toyota и CAN VAG
+type InitProfile struct {
+	VINPrefixes  []string `yaml:"vin_prefixes"`  // Префиксы VIN, для которых профиль выбирается автоматически
+	InitCommands []string `yaml:"init_commands"` // Команды вместо init_commands
+}
+
+// ValidateInitProfiles проверяет профили инициализации и выбранный профиль
+func (c Config) ValidateInitProfiles() error {
+	prefixes := make(map[string]string)
+	for _, name := range c.initProfileNames() {
+		profile := c.InitProfiles[name]
+		if len(profile.InitCommands) == 0 {
+			return fmt.Errorf("init profile %s: init_commands must be set", name)
+		}
+		for _, command := range profile.InitCommands {
+			if strings.TrimSpace(command) == "" {
+				return fmt.Errorf("init profile %s: empty init command", name)
+			}
+		}
+		for _, prefix := range profile.VINPrefixes {
+			normalized := normalizeVINPrefix(prefix)
+			if normalized == "" || len(normalized) > 17 {
+				return fmt.Errorf("init profile %s: invalid VIN prefix %q", name, prefix)
+			}
+			if other, exists := prefixes[normalized]; exists {
+				return fmt.Errorf("init profiles %s and %s share VIN prefix %s", other, name, normalized)
+			}
+			prefixes[normalized] = name
+		}
+	}
+
+	if c.InitProfile != "" {
+		if _, ok := c.lookupInitProfile(c.InitProfile); !ok {
+			return fmt.Errorf("unknown init profile %q", c.InitProfile)
+		}
+	}
+	return nil
+}
+
+// ApplyInitProfile заменяет init_commands командами профиля: заданного init_profile, а без
+// него - профиля с самым длинным префиксом, совпавшим с vin. Возвращает имя примененного
+// профиля (пусто - используются init_commands)
+func (c *Config) ApplyInitProfile(vin string) string {
+	name := strings.ToLower(strings.TrimSpace(c.InitProfile))
+	if name == "" {
+		name = c.matchInitProfile(vin)
+	}
+	profile, ok := c.lookupInitProfile(name)
+	if !ok {
+		return ""
+	}
+	c.InitCommands = append([]string(nil), profile.InitCommands...)
+	return name
+}
+
+// matchInitProfile ищет профиль по префиксу VIN; более длинный префикс точнее
+func (c Config) matchInitProfile(vin string) string {
+	vin = strings.ToUpper(strings.TrimSpace(vin))
+	if vin == "" {
+		return ""
+	}
+
+	var match string
+	longest := 0
+	for _, name := range c.initProfileNames() {
+		for _, prefix := range c.InitProfiles[name].VINPrefixes {
+			prefix = normalizeVINPrefix(prefix)
+			if len(prefix) > longest && strings.HasPrefix(vin, prefix) {
+				match, longest = name, len(prefix)
+			}
+		}
+	}
+	return match
+}
+
+// lookupInitProfile возвращает профиль по имени без учета регистра: viper приводит
+// ключи секций к нижнему регистру
+func (c Config) lookupInitProfile(name string) (InitProfile, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for key, profile := range c.InitProfiles {
+		if strings.ToLower(key) == name {
+			return profile, true
+		}
+	}
+	return InitProfile{}, false
+}
+
+// initProfileNames возвращает имена профилей по порядку, чтобы ошибки и выбор не
+// зависели от порядка обхода map
+func (c Config) initProfileNames() []string {
+	names := make([]string, 0, len(c.InitProfiles))
+	for name := range c.InitProfiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// normalizeVINPrefix приводит префикс VIN к верхнему регистру без пробелов
+func normalizeVINPrefix(prefix string) string {
+	return strings.ToUpper(strings.TrimSpace(prefix))
+}
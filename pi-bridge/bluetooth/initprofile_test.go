@@ -0,0 +1,77 @@
+package bluetooth
+
+import (
+	"reflect"
+	"testing"
+)
+
+func testInitProfiles() map[string]InitProfile {
+	return map[string]InitProfile{
+		"toyota_kline": {VINPrefixes: []string{"JT"}, InitCommands: []string{"ATZ", "ATSP5"}},
+		"lexus":        {VINPrefixes: []string{"jthb"}, InitCommands: []string{"ATZ", "ATSP6"}},
+		"vag_can":      {VINPrefixes: []string{"WVW", "WAU"}, InitCommands: []string{"ATZ", "ATSP6", "ATSH7E0"}},
+	}
+}
+
+func TestValidateInitProfiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		profiles map[string]InitProfile
+		profile  string
+		wantErr  bool
+	}{
+		{"no profiles", nil, "", false},
+		{"profiles", testInitProfiles(), "", false},
+		{"selected profile", testInitProfiles(), "VAG_CAN", false},
+		{"unknown profile", testInitProfiles(), "bmw", true},
+		{"empty commands", map[string]InitProfile{"empty": {VINPrefixes: []string{"WVW"}}}, "", true},
+		{"blank command", map[string]InitProfile{"blank": {InitCommands: []string{"ATZ", " "}}}, "", true},
+		{"empty prefix", map[string]InitProfile{"vag": {VINPrefixes: []string{""}, InitCommands: []string{"ATZ"}}}, "", true},
+		{"duplicate prefix", map[string]InitProfile{
+			"vw":   {VINPrefixes: []string{"WVW"}, InitCommands: []string{"ATZ"}},
+			"audi": {VINPrefixes: []string{"wvw"}, InitCommands: []string{"ATZ"}},
+		}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{InitProfiles: tt.profiles, InitProfile: tt.profile}
+			if err := config.ValidateInitProfiles(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateInitProfiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestApplyInitProfile(t *testing.T) {
+	defaults := DefaultConfig().InitCommands
+	tests := []struct {
+		name         string
+		profile      string
+		vin          string
+		wantProfile  string
+		wantCommands []string
+	}{
+		{"no match", "", "1FTFW1ET5DFC10312", "", defaults},
+		{"no VIN", "", "", "", defaults},
+		{"VIN prefix", "", "WVWZZZ1JZXW000001", "vag_can", []string{"ATZ", "ATSP6", "ATSH7E0"}},
+		{"longest prefix", "", "JTHBK1GG0E2000001", "lexus", []string{"ATZ", "ATSP6"}},
+		{"lowercase VIN", "", "jtdkb20u093000001", "toyota_kline", []string{"ATZ", "ATSP5"}},
+		{"explicit profile wins", "toyota_kline", "WVWZZZ1JZXW000001", "toyota_kline", []string{"ATZ", "ATSP5"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.InitProfiles = testInitProfiles()
+			config.InitProfile = tt.profile
+
+			if profile := config.ApplyInitProfile(tt.vin); profile != tt.wantProfile {
+				t.Errorf("ApplyInitProfile() = %q, want %q", profile, tt.wantProfile)
+			}
+			if !reflect.DeepEqual(config.InitCommands, tt.wantCommands) {
+				t.Errorf("Expected init commands %v, got %v", tt.wantCommands, config.InitCommands)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package bluetooth
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+// Команды HCI группы Status Parameters для соединения с адаптером
+const (
+	hciOpReadLinkQuality  = 0x1403 // Read Link Quality
+	hciOpReadRSSI         = 0x1405 // Read RSSI
+	hciEvtCommandComplete = 0x0E
+)
+
+// LinkQuality - качество радиоканала до адаптера по данным контроллера Bluetooth
+type LinkQuality struct {
+	Address     string `json:"address"`      // MAC адаптера
+	RSSI        int8   `json:"rssi"`         // Отклонение уровня сигнала от оптимального диапазона (дБ, 0 - в диапазоне)
+	LinkQuality uint8  `json:"link_quality"` // Качество канала по оценке контроллера (0-255, больше - лучше)
+}
+
+// linkQualityAddress возвращает адрес адаптера, до которого установлено соединение
+// Bluetooth Classic: сокет RFCOMM или устройство /dev/rfcommN с известным адресом.
+// Пустая строка - качество канала не запрашивается (tcp, ble, USB, поиск по имени)
+func linkQualityAddress(c Config) string {
+	switch transportType(c) {
+	case TransportRFCOMM:
+	case TransportSerial:
+		if _, err := rfcommDeviceID(c.DevicePath); err != nil {
+			return ""
+		}
+	default:
+		return ""
+	}
+	if _, err := parseMAC(c.Address); err != nil {
+		return ""
+	}
+	return c.Address
+}
+
+// commandCompleteParams возвращает параметры результата команды opcode из события
+// Command Complete после проверки статуса. ok = false - событие относится к другой команде
+func commandCompleteParams(params []byte, opcode uint16) ([]byte, bool, error) {
+	if len(params) < 4 || binary.LittleEndian.Uint16(params[1:]) != opcode {
+		return nil, false, nil
+	}
+	if params[3] != 0 {
+		return nil, true, fmt.Errorf("HCI command 0x%04X failed with status 0x%02X", opcode, params[3])
+	}
+	return params[4:], true, nil
+}
+
+// linkQualityLoop периодически публикует качество канала до адаптера в канал служебных
+// событий. Ошибка запроса (нет прав CAP_NET_RAW, соединение еще не установлено
+// контроллером) выводится один раз до следующего успешного запроса
+func (a *Adapter) linkQualityLoop(address string) {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(a.config.LinkQualityInterval)
+	defer ticker.Stop()
+
+	failing := false
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			if !a.isConnected() {
+				continue
+			}
+			quality, err := a.readLinkQuality(address)
+			if err != nil {
+				if !failing {
+					logger.Printf("Warning: failed to read link quality for %s: %v", address, err)
+				}
+				failing = true
+				continue
+			}
+			failing = false
+
+			event := common.StatusEvent{
+				Kind:      "link_quality",
+				Data:      quality,
+				Retained:  true,
+				Timestamp: time.Now(),
+			}
+			select {
+			case a.statusChan <- event:
+			default:
+				logger.Println("Warning: status channel is full, dropping link quality")
+			}
+		}
+	}
+}
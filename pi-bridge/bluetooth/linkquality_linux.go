@@ -0,0 +1,111 @@
+package bluetooth
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// Параметры запроса качества канала через сокет HCI (как hcitool rssi и lq)
+const (
+	hciGetConnInfo     = 0x800448d5 // HCIGETCONNINFO: _IOR('H', 213, int)
+	hciACLLink         = 0x01       // Тип соединения Bluetooth Classic
+	linkQualityTimeout = 2 * time.Second
+)
+
+// readLinkQuality запрашивает у контроллера RSSI и качество канала соединения с address.
+// Требуются права CAP_NET_RAW (мост обычно запущен от root)
+func readLinkQuality(address string) (LinkQuality, error) {
+	mac, err := parseMAC(address)
+	if err != nil {
+		return LinkQuality{}, err
+	}
+
+	hci, err := openHCI()
+	if err != nil {
+		return LinkQuality{}, err
+	}
+	defer hci.file.Close()
+
+	handle, err := hci.connHandle(mac)
+	if err != nil {
+		return LinkQuality{}, err
+	}
+
+	quality := LinkQuality{Address: address}
+	params, err := hci.request(hciOpReadRSSI, handle)
+	if err != nil {
+		return LinkQuality{}, err
+	}
+	quality.RSSI = int8(params[0])
+
+	params, err = hci.request(hciOpReadLinkQuality, handle)
+	if err != nil {
+		return LinkQuality{}, err
+	}
+	quality.LinkQuality = params[0]
+	return quality, nil
+}
+
+// connHandle возвращает дескриптор соединения контроллера с устройством mac
+func (h *hciSocket) connHandle(mac [6]byte) (uint16, error) {
+	// struct hci_conn_info_req: адрес, тип соединения и struct hci_conn_info со смещения 8,
+	// в которой дескриптор соединения - первое поле
+	req := make([]byte, 24)
+	for i := range mac {
+		req[i] = mac[len(mac)-1-i]
+	}
+	req[6] = hciACLLink
+
+	conn, err := h.file.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var errno unix.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = unix.Syscall(unix.SYS_IOCTL, fd, hciGetConnInfo, uintptr(unsafe.Pointer(&req[0])))
+	}); err != nil {
+		return 0, err
+	}
+	if errno != 0 {
+		return 0, fmt.Errorf("no connection to %s: %v", formatBDAddr(req[:6]), errno)
+	}
+	return binary.LittleEndian.Uint16(req[8:]), nil
+}
+
+// request отправляет команду с дескриптором соединения и ждет ее результата.
+// Результат начинается с дескриптора, за которым следует запрошенное значение
+func (h *hciSocket) request(opcode, handle uint16) ([]byte, error) {
+	if err := h.command(opcode, binary.LittleEndian.AppendUint16(nil, handle)); err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(linkQualityTimeout)
+	for {
+		event, params, err := h.event(deadline)
+		if err != nil {
+			return nil, fmt.Errorf("HCI command 0x%04X failed: %v", opcode, err)
+		}
+		switch event {
+		case hciEvtCommandStatus:
+			if err := commandStatusError(params, opcode); err != nil {
+				return nil, err
+			}
+		case hciEvtCommandComplete:
+			result, ok, err := commandCompleteParams(params, opcode)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+			if len(result) < 3 || binary.LittleEndian.Uint16(result) != handle {
+				return nil, fmt.Errorf("unexpected result of HCI command 0x%04X", opcode)
+			}
+			return result[2:], nil
+		}
+	}
+}
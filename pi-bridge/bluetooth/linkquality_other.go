@@ -0,0 +1,10 @@
+//go:build !linux
+
+package bluetooth
+
+import "fmt"
+
+// readLinkQuality на платформах кроме Linux недоступен
+func readLinkQuality(address string) (LinkQuality, error) {
+	return LinkQuality{}, fmt.Errorf("link quality is only supported on Linux")
+}
@@ -0,0 +1,105 @@
+package bluetooth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+	"elm327-bridge/simulator"
+)
+
+func TestLinkQualityAddress(t *testing.T) {
+	const mac = "00:1D:A5:68:98:8B"
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{"rfcomm", Config{Transport: "rfcomm", Address: mac}, mac},
+		{"rfcomm discovery", Config{Transport: "rfcomm"}, ""},
+		{"serial rfcomm device", Config{DevicePath: "/dev/rfcomm0", Address: mac}, mac},
+		{"serial without address", Config{DevicePath: "/dev/rfcomm0"}, ""},
+		{"usb cable", Config{DevicePath: "/dev/ttyUSB0", Address: mac}, ""},
+		{"tcp", Config{Transport: "tcp", Address: "192.168.0.10:35000"}, ""},
+		{"ble", Config{Transport: "ble", Address: mac}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := linkQualityAddress(tt.config); got != tt.want {
+				t.Errorf("linkQualityAddress() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandCompleteParams(t *testing.T) {
+	// Command Complete для Read RSSI: число команд, код команды, статус, дескриптор, RSSI
+	params, ok, err := commandCompleteParams([]byte{0x01, 0x05, 0x14, 0x00, 0x0B, 0x00, 0xF6}, hciOpReadRSSI)
+	if err != nil || !ok {
+		t.Fatalf("Expected result, got ok=%v err=%v", ok, err)
+	}
+	if len(params) != 3 || int8(params[2]) != -10 {
+		t.Errorf("Unexpected result % X", params)
+	}
+
+	if _, ok, _ := commandCompleteParams([]byte{0x01, 0x03, 0x14, 0x00, 0x0B, 0x00, 0xFF}, hciOpReadRSSI); ok {
+		t.Error("Expected result of another command to be skipped")
+	}
+	if _, ok, err := commandCompleteParams([]byte{0x01, 0x05, 0x14, 0x02}, hciOpReadRSSI); !ok || err == nil {
+		t.Error("Expected error for failed command")
+	}
+}
+
+func TestAdapterLinkQuality(t *testing.T) {
+	sim := simulator.New(nil)
+	defer sim.Close()
+
+	statusChan := make(chan common.StatusEvent, 100)
+	config := DefaultConfig()
+	config.Transport = TransportRFCOMM
+	config.Address = "00:1D:A5:68:98:8B"
+	config.HeartbeatInterval = 0
+	config.StatsInterval = 0
+	config.LinkQualityInterval = 20 * time.Millisecond
+	adapter := NewAdapter(config, make(chan string, 10), make(chan string, 10))
+	adapter.SetStatusChannel(statusChan)
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return sim, nil }))
+
+	// Канал ухудшается, затем соединение пропадает у контроллера: ошибки не публикуются
+	readings := []int8{-2, -12}
+	adapter.readLinkQuality = func(address string) (LinkQuality, error) {
+		if len(readings) == 0 {
+			return LinkQuality{}, errors.New("no connection")
+		}
+		rssi := readings[0]
+		readings = readings[1:]
+		return LinkQuality{Address: address, RSSI: rssi, LinkQuality: 200}, nil
+	}
+	adapter.Start(context.Background())
+	defer adapter.Stop()
+
+	var got []int8
+	deadline := time.After(3 * time.Second)
+	for len(got) < 2 {
+		select {
+		case event := <-statusChan:
+			if event.Kind != "link_quality" {
+				continue
+			}
+			quality := event.Data.(LinkQuality)
+			if !event.Retained || quality.Address != config.Address || quality.LinkQuality != 200 {
+				t.Errorf("Unexpected link quality event %+v", event)
+			}
+			got = append(got, quality.RSSI)
+		case <-deadline:
+			t.Fatalf("Expected two link quality events, got %v", got)
+		}
+	}
+	if got[0] != -2 || got[1] != -12 {
+		t.Errorf("Expected RSSI readings in order, got %v", got)
+	}
+}
@@ -0,0 +1,19 @@
+package bluetooth
+
+import "strings"
+
+// isLowPowerCommand проверяет, переводит ли команда ELM327 в режим пониженного
+// энергопотребления (ATLP). Спящий адаптер не отвечает до пробуждения
+func isLowPowerCommand(command string) bool {
+	return strings.ToUpper(strings.Join(strings.Fields(command), "")) == "ATLP"
+}
+
+// wake будит адаптер перед отправкой команды. ELM327 просыпается от любого символа и
+// перезапускается, как после ATWS, теряя настройки, поэтому инициализация повторяется:
+// первая команда инициализации будит адаптер, пропавшие при пробуждении символы
+// компенсируются повторами init_attempts
+func (a *Adapter) wake() {
+	a.sleeping.Store(false)
+	logger.Println("Waking ELM327 from low power mode")
+	a.reinitialize()
+}
@@ -0,0 +1,71 @@
+package bluetooth
+
+import "strings"
+
+// monitorInterrupt прерывает режим мониторинга: ELM327 останавливается на любом символе,
+// а пробел игнорируется и в начале следующей команды, если мониторинг уже завершился сам
+const monitorInterrupt = " "
+
+// isMonitorCommand проверяет, переводит ли команда ELM327 в режим мониторинга шины
+// (ATMA - все кадры, ATMR/ATMT - кадры приемника или передатчика)
+func isMonitorCommand(command string) bool {
+	cmd := strings.ToUpper(strings.Join(strings.Fields(command), ""))
+	return cmd == "ATMA" || strings.HasPrefix(cmd, "ATMR") || strings.HasPrefix(cmd, "ATMT")
+}
+
+// monitorSplitter делит непрерывный вывод режима мониторинга на строки кадров.
+// Приглашение в этом режиме приходит только после прерывания
+type monitorSplitter struct {
+	current  strings.Builder
+	prompt   byte // Символ приглашения (0 - '>')
+	noPrompt bool // Адаптер не выводит приглашение: мониторинг завершает строка STOPPED
+}
+
+// monitorStopped - строка, которой ELM327 подтверждает прерывание мониторинга
+const monitorStopped = "STOPPED"
+
+// Feed возвращает завершенные строки. Если получено приглашение, мониторинг закончен:
+// возвращаются данные начиная с приглашения для обычного сборщика ответов и true
+func (m *monitorSplitter) Feed(data []byte) ([]string, []byte, bool) {
+	var lines []string
+
+	prompt := promptByte(m.prompt)
+	for i, b := range data {
+		switch {
+		case b == '\r' || b == '\n':
+			lines = m.flushLine(lines)
+			// Без приглашения конец мониторинга - строка STOPPED, она же ответ на команду
+			if m.noPrompt && len(lines) > 0 && lines[len(lines)-1] == monitorStopped {
+				rest := append([]byte(monitorStopped+"\r"), data[i+1:]...)
+				return lines[:len(lines)-1], rest, true
+			}
+		case b == prompt && !m.noPrompt:
+			return m.flushLine(lines), data[i:], true
+		case b == 0:
+			// Некоторые клоны ELM327 дополняют вывод нулевыми байтами
+		default:
+			m.current.WriteByte(b)
+		}
+	}
+	return lines, nil, false
+}
+
+// Size возвращает длину незавершенной строки в байтах
+func (m *monitorSplitter) Size() int {
+	return m.current.Len()
+}
+
+// Reset отбрасывает незавершенную строку (при смене соединения)
+func (m *monitorSplitter) Reset() {
+	m.current.Reset()
+}
+
+// flushLine завершает текущую строку, пропуская пустые
+func (m *monitorSplitter) flushLine(lines []string) []string {
+	line := normalizeLine(m.current.String())
+	m.current.Reset()
+	if line == "" {
+		return lines
+	}
+	return append(lines, line)
+}
@@ -0,0 +1,44 @@
+package bluetooth
+
+import "fmt"
+
+// defaultMaxResponseSize - предел ответа по умолчанию. Самый длинный ответ ELM327 -
+// составное сообщение ISO-TP до 4095 байт - в текстовом виде с заголовками занимает
+// около 16 КБ, поэтому предел срабатывает только на зависшем адаптере
+const defaultMaxResponseSize = 64 * 1024
+
+// maxResponseSize возвращает предел размера ответа с учетом значения по умолчанию
+func (c Config) maxResponseSize() int {
+	if c.MaxResponseSize <= 0 {
+		return defaultMaxResponseSize
+	}
+	return c.MaxResponseSize
+}
+
+// onOverflow вызывается циклом чтения, когда ответ без приглашения превысил предел:
+// адаптер, застрявший в режиме мониторинга или выводящий мусор, иначе заполнил бы
+// память. Накопленные данные уже отброшены; после инициализации цикл записи прерывает
+// ожидание и инициализирует адаптер заново. Во время инициализации данные просто
+// отбрасываются: ее повторы выполнит initStep
+func (a *Adapter) onOverflow(size int) {
+	logger.Printf("Response exceeded %d bytes without prompt (%d bytes), discarding", a.config.maxResponseSize(), size)
+	if a.initialized.Load() {
+		notify(a.overflowChan)
+	}
+}
+
+// abortPending завершает ошибкой команды, ответ на которые переполнил буфер
+// и требует сброса адаптера (вызывается из цикла записи)
+func (a *Adapter) abortPending() {
+	dropped := a.pending.drain()
+	logger.Printf("Aborting %d pending command(s) after response overflow", len(dropped))
+	// Адаптер, выводящий данные без приглашения, сбрасывается сразу
+	a.resetNeeded.Store(true)
+
+	for _, item := range dropped {
+		if item.reply != nil {
+			continue
+		}
+		a.requests.Finish(item.request, nil, fmt.Errorf("response to %s exceeded %d bytes", item.command, a.config.maxResponseSize()))
+	}
+}
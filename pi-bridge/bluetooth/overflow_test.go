@@ -0,0 +1,115 @@
+package bluetooth
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+	"elm327-bridge/simulator"
+)
+
+func TestResponseAssemblerSize(t *testing.T) {
+	var assembler responseAssembler
+	assembler.Feed([]byte("SEARCHING...\r7E8 03 41 0D 32\r7E8"))
+	if size := assembler.Size(); size != len("7E8 03 41 0D 32")+len("7E8") {
+		t.Errorf("Expected size of pending data only, got %d", size)
+	}
+
+	assembler.Feed([]byte(" 03\r>"))
+	if size := assembler.Size(); size != 0 {
+		t.Errorf("Expected size to reset at prompt, got %d", size)
+	}
+
+	var monitor monitorSplitter
+	monitor.Feed([]byte("3B4 01\r3B4"))
+	if size := monitor.Size(); size != len("3B4") {
+		t.Errorf("Expected size of the incomplete monitor line, got %d", size)
+	}
+}
+
+// floodingConn подменяет команду flood на ATMA: адаптер начинает непрерывно выводить
+// кадры шины, хотя мост не переводил его в режим мониторинга
+type floodingConn struct {
+	*simulator.ELM327
+	flood string
+}
+
+func (c *floodingConn) Write(p []byte) (int, error) {
+	if strings.TrimSpace(string(p)) == c.flood {
+		if _, err := c.ELM327.Write([]byte("ATMA\r")); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	return c.ELM327.Write(p)
+}
+
+func TestAdapterResponseOverflow(t *testing.T) {
+	sim := simulator.New(nil)
+	sim.MonitorFrames = []string{"3B4 01 02 03 04 05 06 07 08"}
+	sim.MonitorInterval = time.Millisecond
+	conn := &floodingConn{ELM327: sim, flood: "0105"}
+
+	responsesChan := make(chan string, 10)
+	commandsChan := make(chan string, 10)
+	commandResponses := make(chan common.CommandResponse, 10)
+	requests := common.NewPendingRequests(time.Minute, commandResponses)
+
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+	config.ReadTimeout = time.Minute // Ожидание прерывает переполнение, а не таймаут
+	config.HeartbeatInterval = 0
+	config.MaxResponseSize = 512
+	adapter := NewAdapter(config, responsesChan, commandsChan)
+	adapter.SetPendingRequests(requests)
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return conn, nil }))
+
+	connected := make(chan struct{}, 2)
+	adapter.SetConnectHandler(func() { notify(connected) })
+	adapter.Start(context.Background())
+	defer func() {
+		sim.Close()
+		adapter.Stop()
+	}()
+
+	select {
+	case <-connected:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Adapter initialization did not complete")
+	}
+
+	requests.Register("speed", []string{"0105"})
+	commandsChan <- "0105"
+
+	select {
+	case response := <-commandResponses:
+		if !strings.Contains(response.Error, "exceeded 512 bytes") {
+			t.Errorf("Expected overflow error, got %+v", response)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected request to fail on response overflow")
+	}
+
+	select {
+	case <-connected:
+	case <-time.After(10 * time.Second):
+		t.Fatal("Adapter was not reinitialized after overflow")
+	}
+
+	// После повторной инициализации адаптер снова отвечает на запросы
+	commandsChan <- "010C"
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case response := <-responsesChan:
+			if response == "7E8 04 41 0C 1A F8" {
+				return
+			}
+		case <-deadline:
+			t.Fatal("Expected answer to 010C after reinitialization")
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package bluetooth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// defaultPairingPINs - стандартные PIN адаптеров ELM327, перебираемые по порядку
+var defaultPairingPINs = []string{"1234", "0000"}
+
+// defaultPairingTimeout - время на поиск устройства и одну попытку сопряжения
+const defaultPairingTimeout = 30 * time.Second
+
+// Объекты и интерфейсы BlueZ на системной шине D-Bus
+const (
+	bluezService      = "org.bluez"
+	bluezAdapterPath  = dbus.ObjectPath("/org/bluez/hci0")
+	bluezAgentManager = "org.bluez.AgentManager1"
+	bluezAdapter      = "org.bluez.Adapter1"
+	bluezDevice       = "org.bluez.Device1"
+	bluezAgent        = "org.bluez.Agent1"
+	pairingAgentPath  = dbus.ObjectPath("/elm327bridge/agent")
+)
+
+// PairingConfig задает сопряжение с адаптером при первом подключении без bluetoothctl
+type PairingConfig struct {
+	Enabled bool          `yaml:"enabled"` // Выполнять сопряжение, если адаптер еще не сопряжен
+	PINs    []string      `yaml:"pins"`    // PIN по порядку перебора (пусто - 1234, 0000)
+	Timeout time.Duration `yaml:"timeout"` // Таймаут одной попытки (0 - 30 с)
+}
+
+// Validate проверяет параметры сопряжения
+func (c PairingConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("invalid pairing timeout %v", c.Timeout)
+	}
+	for _, pin := range c.PINs {
+		if len(pin) == 0 || len(pin) > 16 {
+			return fmt.Errorf("invalid pairing PIN %q: expected 1-16 characters", pin)
+		}
+	}
+	return nil
+}
+
+// pins возвращает PIN для перебора с учетом значения по умолчанию
+func (c PairingConfig) pins() []string {
+	if len(c.PINs) == 0 {
+		return defaultPairingPINs
+	}
+	return c.PINs
+}
+
+// timeout возвращает таймаут попытки с учетом значения по умолчанию
+func (c PairingConfig) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return defaultPairingTimeout
+	}
+	return c.Timeout
+}
+
+// pairer выполняет сопряжение перед подключением и запоминает сопряженные адреса,
+// чтобы не обращаться к BlueZ при каждом переподключении
+type pairer struct {
+	config PairingConfig
+	pair   func(address string, config PairingConfig) error
+
+	mu     sync.Mutex
+	paired map[string]bool
+}
+
+// newPairer создает помощник сопряжения через BlueZ
+func newPairer(config PairingConfig) *pairer {
+	return &pairer{config: config, pair: pairDevice, paired: make(map[string]bool)}
+}
+
+// wrap добавляет сопряжение перед подключением к адресу
+func (p *pairer) wrap(dial func(address string) (io.ReadWriteCloser, error)) func(address string) (io.ReadWriteCloser, error) {
+	return func(address string) (io.ReadWriteCloser, error) {
+		if err := p.ensure(address); err != nil {
+			return nil, err
+		}
+		return dial(address)
+	}
+}
+
+// ensure выполняет сопряжение, если адрес еще не сопряжен
+func (p *pairer) ensure(address string) error {
+	address = strings.ToUpper(address)
+
+	p.mu.Lock()
+	done := p.paired[address]
+	p.mu.Unlock()
+	if done {
+		return nil
+	}
+
+	if err := p.pair(address, p.config); err != nil {
+		return fmt.Errorf("pairing with %s failed: %v", address, err)
+	}
+
+	p.mu.Lock()
+	p.paired[address] = true
+	p.mu.Unlock()
+	return nil
+}
+
+// pairDevice сопрягает устройство через BlueZ: регистрирует агента, отвечающего PIN из
+// конфигурации, и перебирает PIN, пока сопряжение не удастся. Устройство помечается
+// доверенным, чтобы BlueZ не запрашивал подтверждение при подключении
+func pairDevice(address string, config PairingConfig) error {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return fmt.Errorf("failed to connect to system D-Bus: %v", err)
+	}
+	defer conn.Close()
+
+	device := conn.Object(bluezService, bluezDevicePath(address))
+	if err := waitForDevice(conn, device, config.timeout()); err != nil {
+		return err
+	}
+
+	if paired, err := device.GetProperty(bluezDevice + ".Paired"); err == nil && paired.Value() == true {
+		return trustDevice(device)
+	}
+
+	agent := &pairingAgent{}
+	if err := conn.Export(agent, pairingAgentPath, bluezAgent); err != nil {
+		return fmt.Errorf("failed to export pairing agent: %v", err)
+	}
+	manager := conn.Object(bluezService, "/org/bluez")
+	if err := manager.Call(bluezAgentManager+".RegisterAgent", 0, pairingAgentPath, "KeyboardDisplay").Err; err != nil {
+		return fmt.Errorf("failed to register pairing agent: %v", err)
+	}
+	defer manager.Call(bluezAgentManager+".UnregisterAgent", 0, pairingAgentPath)
+
+	var lastErr error
+	for _, pin := range config.pins() {
+		agent.setPIN(pin)
+		logger.Printf("Pairing with %s using PIN %s", address, pin)
+
+		ctx, cancel := context.WithTimeout(context.Background(), config.timeout())
+		err := device.CallWithContext(ctx, bluezDevice+".Pair", 0).Err
+		cancel()
+
+		var dbusErr dbus.Error
+		if errors.As(err, &dbusErr) && dbusErr.Name == "org.bluez.Error.AlreadyExists" {
+			err = nil
+		}
+		if err == nil {
+			logger.Printf("Paired with %s", address)
+			return trustDevice(device)
+		}
+
+		if errors.Is(err, context.DeadlineExceeded) {
+			device.Call(bluezDevice+".CancelPairing", 0)
+		}
+		logger.Printf("Pairing with %s using PIN %s failed: %v", address, pin, err)
+		lastErr = err
+	}
+	return lastErr
+}
+
+// waitForDevice ждет появления устройства в BlueZ, при необходимости запуская поиск
+func waitForDevice(conn *dbus.Conn, device dbus.BusObject, timeout time.Duration) error {
+	if _, err := device.GetProperty(bluezDevice + ".Address"); err == nil {
+		return nil
+	}
+
+	adapter := conn.Object(bluezService, bluezAdapterPath)
+	if err := adapter.SetProperty(bluezAdapter+".Powered", dbus.MakeVariant(true)); err != nil {
+		return fmt.Errorf("failed to power on Bluetooth controller: %v", err)
+	}
+	if err := adapter.Call(bluezAdapter+".StartDiscovery", 0).Err; err != nil {
+		return fmt.Errorf("failed to start discovery: %v", err)
+	}
+	defer adapter.Call(bluezAdapter+".StopDiscovery", 0)
+
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		time.Sleep(500 * time.Millisecond)
+		if _, err := device.GetProperty(bluezDevice + ".Address"); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("device not found within %v", timeout)
+}
+
+// trustDevice помечает устройство доверенным
+func trustDevice(device dbus.BusObject) error {
+	if err := device.SetProperty(bluezDevice+".Trusted", dbus.MakeVariant(true)); err != nil {
+		return fmt.Errorf("failed to trust device: %v", err)
+	}
+	return nil
+}
+
+// bluezDevicePath возвращает путь объекта устройства: /org/bluez/hci0/dev_00_1D_A5_68_98_8B
+func bluezDevicePath(address string) dbus.ObjectPath {
+	return dbus.ObjectPath(fmt.Sprintf("%s/dev_%s", bluezAdapterPath, strings.ReplaceAll(strings.ToUpper(address), ":", "_")))
+}
+
+// pairingAgent - агент BlueZ (org.bluez.Agent1), отвечающий текущим PIN без участия
+// пользователя. Адаптеры ELM327 используют legacy pairing с PIN; запросы подтверждения
+// SSP принимаются автоматически
+type pairingAgent struct {
+	mu  sync.Mutex
+	pin string
+}
+
+// setPIN задает PIN для следующей попытки сопряжения
+func (a *pairingAgent) setPIN(pin string) {
+	a.mu.Lock()
+	a.pin = pin
+	a.mu.Unlock()
+}
+
+// currentPIN возвращает PIN текущей попытки
+func (a *pairingAgent) currentPIN() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.pin
+}
+
+// Release вызывается BlueZ при снятии агента с регистрации
+func (a *pairingAgent) Release() *dbus.Error {
+	return nil
+}
+
+// RequestPinCode возвращает PIN для legacy pairing
+func (a *pairingAgent) RequestPinCode(device dbus.ObjectPath) (string, *dbus.Error) {
+	return a.currentPIN(), nil
+}
+
+// DisplayPinCode вызывается, когда PIN нужно показать пользователю
+func (a *pairingAgent) DisplayPinCode(device dbus.ObjectPath, pincode string) *dbus.Error {
+	return nil
+}
+
+// RequestPasskey возвращает PIN как числовой ключ SSP
+func (a *pairingAgent) RequestPasskey(device dbus.ObjectPath) (uint32, *dbus.Error) {
+	passkey, err := strconv.ParseUint(a.currentPIN(), 10, 32)
+	if err != nil {
+		return 0, dbus.NewError("org.bluez.Error.Rejected", nil)
+	}
+	return uint32(passkey), nil
+}
+
+// DisplayPasskey вызывается, когда ключ нужно показать пользователю
+func (a *pairingAgent) DisplayPasskey(device dbus.ObjectPath, passkey uint32, entered uint16) *dbus.Error {
+	return nil
+}
+
+// RequestConfirmation подтверждает ключ SSP (Just Works / Numeric Comparison)
+func (a *pairingAgent) RequestConfirmation(device dbus.ObjectPath, passkey uint32) *dbus.Error {
+	return nil
+}
+
+// RequestAuthorization разрешает сопряжение без ключа
+func (a *pairingAgent) RequestAuthorization(device dbus.ObjectPath) *dbus.Error {
+	return nil
+}
+
+// AuthorizeService разрешает подключение к сервису
+func (a *pairingAgent) AuthorizeService(device dbus.ObjectPath, uuid string) *dbus.Error {
+	return nil
+}
+
+// Cancel вызывается при отмене сопряжения
+func (a *pairingAgent) Cancel() *dbus.Error {
+	return nil
+}
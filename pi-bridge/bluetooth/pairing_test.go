@@ -0,0 +1,95 @@
+package bluetooth
+
+import (
+	"errors"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestPairingValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"disabled", Config{DevicePath: "/dev/rfcomm0"}, false},
+		{"rfcomm", Config{Transport: "rfcomm", Address: "00:1D:A5:68:98:8B", Pairing: PairingConfig{Enabled: true}}, false},
+		{"rfcomm with discovery", Config{Transport: "rfcomm", Discovery: DiscoveryConfig{Enabled: true}, Pairing: PairingConfig{Enabled: true}}, false},
+		{"serial with address", Config{DevicePath: "/dev/rfcomm0", Address: "00:1D:A5:68:98:8B", Pairing: PairingConfig{Enabled: true}}, false},
+		{"serial without address", Config{DevicePath: "/dev/rfcomm0", Pairing: PairingConfig{Enabled: true}}, true},
+		{"tcp", Config{Transport: "tcp", Address: "192.168.0.10:35000", Pairing: PairingConfig{Enabled: true}}, true},
+		{"custom pins", Config{Transport: "rfcomm", Address: "00:1D:A5:68:98:8B", Pairing: PairingConfig{Enabled: true, PINs: []string{"6789"}}}, false},
+		{"empty pin", Config{Transport: "rfcomm", Address: "00:1D:A5:68:98:8B", Pairing: PairingConfig{Enabled: true, PINs: []string{""}}}, true},
+		{"long pin", Config{Transport: "rfcomm", Address: "00:1D:A5:68:98:8B", Pairing: PairingConfig{Enabled: true, PINs: []string{"12345678901234567"}}}, true},
+		{"negative timeout", Config{Transport: "rfcomm", Address: "00:1D:A5:68:98:8B", Pairing: PairingConfig{Enabled: true, Timeout: -time.Second}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.ValidateTransport(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTransport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPairerPairsOnce(t *testing.T) {
+	var paired []string
+	failures := 1
+	p := newPairer(PairingConfig{Enabled: true})
+	p.pair = func(address string, config PairingConfig) error {
+		paired = append(paired, address)
+		if failures > 0 {
+			failures--
+			return errors.New("authentication failed")
+		}
+		return nil
+	}
+
+	dials := 0
+	dial := p.wrap(func(address string) (io.ReadWriteCloser, error) {
+		dials++
+		return &MockReadWriteCloser{}, nil
+	})
+
+	// Неудачное сопряжение не доходит до подключения и повторяется при следующей попытке
+	if _, err := dial("00:1d:a5:68:98:8b"); err == nil {
+		t.Fatal("Expected pairing error")
+	}
+	if dials != 0 {
+		t.Errorf("Expected no dial after pairing failure, got %d", dials)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := dial("00:1D:A5:68:98:8B"); err != nil {
+			t.Fatalf("dial failed: %v", err)
+		}
+	}
+	if len(paired) != 2 || dials != 2 {
+		t.Errorf("Expected 2 pairing attempts and 2 dials, got %v and %d", paired, dials)
+	}
+}
+
+func TestPairingAgent(t *testing.T) {
+	agent := &pairingAgent{}
+	agent.setPIN("1234")
+
+	if pin, err := agent.RequestPinCode("/org/bluez/hci0/dev_00_1D_A5_68_98_8B"); err != nil || pin != "1234" {
+		t.Errorf("RequestPinCode = %q, %v", pin, err)
+	}
+	if passkey, err := agent.RequestPasskey("/org/bluez/hci0/dev_00_1D_A5_68_98_8B"); err != nil || passkey != 1234 {
+		t.Errorf("RequestPasskey = %d, %v", passkey, err)
+	}
+
+	agent.setPIN("ABCD")
+	if _, err := agent.RequestPasskey("/org/bluez/hci0/dev_00_1D_A5_68_98_8B"); err == nil {
+		t.Error("Expected non-numeric PIN to be rejected as passkey")
+	}
+}
+
+func TestBluezDevicePath(t *testing.T) {
+	if path := bluezDevicePath("00:1d:a5:68:98:8b"); path != "/org/bluez/hci0/dev_00_1D_A5_68_98_8B" {
+		t.Errorf("Unexpected device path %s", path)
+	}
+}
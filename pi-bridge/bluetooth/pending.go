@@ -0,0 +1,124 @@
+package bluetooth
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+// pendingCommand представляет команду, отправленную адаптеру и ожидающую ответа
+type pendingCommand struct {
+	command string
+	reply   chan string            // Канал для синхронного ожидания ответа (nil - ответ маршрутизируется)
+	request *common.PendingRequest // Запрос клиента MQTT, вызвавший команду (nil - команда моста)
+	retry   bool                   // После этой попытки команда будет повторена
+	sent    time.Time              // Время отправки (время ответа в статистике)
+}
+
+// pendingQueue хранит отправленные команды в порядке отправки: ELM327 отвечает
+// строго по очереди, поэтому каждый ответ относится к самой старой команде
+type pendingQueue struct {
+	mu    sync.Mutex
+	items []pendingCommand
+	last  string // Последняя непустая команда (пустая команда повторяет ее)
+}
+
+// push добавляет отправленную команду
+func (q *pendingQueue) push(command string, reply chan string, request *common.PendingRequest) {
+	q.add(pendingCommand{command: command, reply: reply, request: request})
+}
+
+// pushRetry добавляет отправленную команду с отметкой о последующем повторе
+func (q *pendingQueue) pushRetry(command string, request *common.PendingRequest, retry bool) {
+	q.add(pendingCommand{command: command, request: request, retry: retry})
+}
+
+// add добавляет команду в очередь
+func (q *pendingQueue) add(item pendingCommand) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	// Пустая команда ("\r") заставляет ELM327 повторить предыдущую
+	if item.command == "" {
+		item.command = q.last
+	} else {
+		q.last = item.command
+	}
+	item.sent = time.Now()
+	q.items = append(q.items, item)
+}
+
+// pop извлекает команду, к которой относится очередной ответ
+func (q *pendingQueue) pop() (pendingCommand, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.items) == 0 {
+		return pendingCommand{}, false
+	}
+	item := q.items[0]
+	q.items = q.items[1:]
+	return item, true
+}
+
+// remove удаляет ожидание ответа (по таймауту), чтобы не сдвинуть очередь
+func (q *pendingQueue) remove(reply chan string) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for i, item := range q.items {
+		if item.reply == reply {
+			q.items = append(q.items[:i], q.items[i+1:]...)
+			return
+		}
+	}
+}
+
+// len возвращает количество команд, ожидающих ответа
+func (q *pendingQueue) len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.items)
+}
+
+// drain извлекает все команды, оставшиеся без ответа (по таймауту)
+func (q *pendingQueue) drain() []pendingCommand {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	items := q.items
+	q.items = nil
+	return items
+}
+
+// drainRouted извлекает маршрутизируемые команды, оставшиеся без ответа (по таймауту).
+// Команды синхронных отправителей остаются в очереди до ответа или своего таймаута
+func (q *pendingQueue) drainRouted() []pendingCommand {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var dropped, kept []pendingCommand
+	for _, item := range q.items {
+		if item.reply != nil {
+			kept = append(kept, item)
+		} else {
+			dropped = append(dropped, item)
+		}
+	}
+	q.items = kept
+	return dropped
+}
+
+// reset очищает очередь при смене соединения
+func (q *pendingQueue) reset() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.items = nil
+	q.last = ""
+}
+
+// isATCommand проверяет, является ли команда командой самого адаптера (AT/ST), а не запросом OBD
+func isATCommand(command string) bool {
+	command = strings.ToUpper(strings.TrimSpace(command))
+	return strings.HasPrefix(command, "AT") || strings.HasPrefix(command, "ST")
+}
@@ -0,0 +1,64 @@
+package bluetooth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// protocolAuto - автоматический выбор протокола ELM327 (ATSP0)
+const protocolAuto = "0"
+
+// normalizeProtocol приводит номер протокола ELM327 к виду команды ATSP:
+// "auto" - автоматический выбор, номера 1-9 и A-C - конкретный протокол
+func normalizeProtocol(protocol string) string {
+	protocol = strings.ToUpper(strings.TrimSpace(protocol))
+	if protocol == "AUTO" {
+		return protocolAuto
+	}
+	return protocol
+}
+
+// isProtocolNumber проверяет номер протокола ELM327: 0-9 или A-C
+func isProtocolNumber(protocol string) bool {
+	return len(protocol) == 1 && strings.Contains("0123456789ABC", protocol)
+}
+
+// ValidateProtocol проверяет закрепленный протокол шины и запасные протоколы
+func (c Config) ValidateProtocol() error {
+	if c.Protocol != "" && !isProtocolNumber(normalizeProtocol(c.Protocol)) {
+		return fmt.Errorf("invalid protocol %q: expected auto, 1-9 or A-C", c.Protocol)
+	}
+	for _, protocol := range c.ProtocolFallback {
+		if !isProtocolNumber(normalizeProtocol(protocol)) {
+			return fmt.Errorf("invalid fallback protocol %q: expected auto, 1-9 or A-C", protocol)
+		}
+	}
+	if len(c.ProtocolFallback) > 0 && len(c.Protocols()) == 0 {
+		return fmt.Errorf("protocol_fallback requires protocol to be set")
+	}
+	return nil
+}
+
+// Protocols возвращает закрепленный протокол и запасные протоколы в порядке перебора
+// (nil - протокол выбирается адаптером автоматически)
+func (c Config) Protocols() []string {
+	protocol := normalizeProtocol(c.Protocol)
+	if protocol == "" || protocol == protocolAuto {
+		return nil
+	}
+	protocols := []string{protocol}
+	for _, fallback := range c.ProtocolFallback {
+		protocols = append(protocols, normalizeProtocol(fallback))
+	}
+	return protocols
+}
+
+// protocolCommands возвращает команду закрепления протокола, выполняемую после
+// init_commands: она заменяет ATSP0 из команд инициализации
+func protocolCommands(c Config) []string {
+	protocols := c.Protocols()
+	if len(protocols) == 0 {
+		return nil
+	}
+	return []string{"ATSP" + protocols[0]}
+}
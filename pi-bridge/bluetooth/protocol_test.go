@@ -0,0 +1,76 @@
+package bluetooth
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"elm327-bridge/simulator"
+)
+
+func TestProtocolCommands(t *testing.T) {
+	tests := []struct {
+		name      string
+		config    Config
+		protocols []string
+		commands  []string
+		wantErr   bool
+	}{
+		{"not configured", Config{}, nil, nil, false},
+		{"auto", Config{Protocol: "Auto"}, nil, nil, false},
+		{"zero is auto", Config{Protocol: "0"}, nil, nil, false},
+		{"CAN 11/500", Config{Protocol: "6"}, []string{"6"}, []string{"ATSP6"}, false},
+		{"with fallback", Config{Protocol: "6", ProtocolFallback: []string{"8", "b", "auto"}}, []string{"6", "8", "B", "0"}, []string{"ATSP6"}, false},
+		{"unknown protocol", Config{Protocol: "D"}, nil, nil, true},
+		{"unknown fallback", Config{Protocol: "6", ProtocolFallback: []string{"CAN"}}, nil, nil, true},
+		{"fallback without protocol", Config{ProtocolFallback: []string{"8"}}, nil, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.ValidateProtocol()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateProtocol() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := tt.config.Protocols(); !reflect.DeepEqual(got, tt.protocols) {
+				t.Errorf("Protocols() = %v, want %v", got, tt.protocols)
+			}
+			if got := protocolCommands(tt.config); !reflect.DeepEqual(got, tt.commands) {
+				t.Errorf("protocolCommands() = %v, want %v", got, tt.commands)
+			}
+		})
+	}
+}
+
+func TestInitPinsProtocol(t *testing.T) {
+	conn := &swallowingConn{ELM327: simulator.New(nil)}
+
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+	config.Protocol = "6"
+	adapter := NewAdapter(config, make(chan string, 10), make(chan string, 10))
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return conn, nil }))
+
+	adapter.wg.Add(1)
+	go adapter.readLoop()
+	defer func() {
+		conn.Close()
+		adapter.Stop()
+	}()
+
+	if err := adapter.connect(); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+
+	// Закрепление протокола выполняется после ATSP0 из команд инициализации
+	conn.mu.Lock()
+	last := conn.written[len(conn.written)-1]
+	conn.mu.Unlock()
+	if last != "ATSP6" {
+		t.Errorf("Expected ATSP6 at the end of init, got %q", last)
+	}
+}
@@ -0,0 +1,47 @@
+package bluetooth
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Ожидание сброса в режиме совместимости с клонами ELM327 v1.5: после ATZ клон
+// перезагружается дольше оригинала и пропускает команду, отправленную сразу после ответа
+const (
+	cloneResetTimeout = 5 * time.Second
+	cloneResetSettle  = 500 * time.Millisecond
+)
+
+// errInitRejected - адаптер ответил "?" на команду инициализации
+var errInitRejected = errors.New("adapter rejected init command")
+
+// resetTimeout возвращает ожидание ответа на команду инициализации command
+func (c Config) resetTimeout(command string) time.Duration {
+	if c.CloneQuirks && isResetCommand(command) && c.ReadTimeout < cloneResetTimeout {
+		return cloneResetTimeout
+	}
+	return c.ReadTimeout
+}
+
+// tolerateInitError проверяет, можно ли продолжить инициализацию после ошибки команды.
+// Клоны отвечают "?" на часть поддерживаемых оригиналом команд (чаще всего ATE0),
+// и без них адаптер работает; без ответа на сброс - нет
+func (c Config) tolerateInitError(command string, err error) bool {
+	return c.CloneQuirks && errors.Is(err, errInitRejected) && !isResetCommand(command)
+}
+
+// stripEcho удаляет из ответа повтор команды: клоны без поддержки ATE0 оставляют эхо
+// включенным, и первая строка ответа совпадает с отправленной командой
+func stripEcho(command, response string) string {
+	first, rest, _ := strings.Cut(response, "\r")
+	if compactCommand(first) != compactCommand(command) {
+		return response
+	}
+	return rest
+}
+
+// compactCommand приводит команду к верхнему регистру без пробелов
+func compactCommand(command string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(command), ""))
+}
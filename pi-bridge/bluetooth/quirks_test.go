@@ -0,0 +1,127 @@
+package bluetooth
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"elm327-bridge/simulator"
+)
+
+func TestStripEcho(t *testing.T) {
+	tests := []struct {
+		command  string
+		response string
+		want     string
+	}{
+		{"010C", "010C\r41 0C 1A F8", "41 0C 1A F8"},
+		{"01 0c", "010C\r41 0C 1A F8", "41 0C 1A F8"},
+		{"ATE0", "ATE0\r?", "?"},
+		{"ATRV", "ATRV", ""},
+		{"010C", "41 0C 1A F8", "41 0C 1A F8"},
+		{"0902", "0902\r49 02 01 31 44 34\r49 02 02 47 50 30", "49 02 01 31 44 34\r49 02 02 47 50 30"},
+		{"", "41 0C 1A F8", "41 0C 1A F8"},
+	}
+
+	for _, tt := range tests {
+		if got := stripEcho(tt.command, tt.response); got != tt.want {
+			t.Errorf("stripEcho(%q, %q) = %q, want %q", tt.command, tt.response, got, tt.want)
+		}
+	}
+}
+
+func TestTolerateInitError(t *testing.T) {
+	rejected := validateInitResponse("ATE0", "?")
+	tests := []struct {
+		name    string
+		quirks  bool
+		command string
+		err     error
+		want    bool
+	}{
+		{"rejected with quirks", true, "ATE0", rejected, true},
+		{"rejected without quirks", false, "ATE0", rejected, false},
+		{"reset rejected", true, "ATZ", validateInitResponse("ATZ", "?"), false},
+		{"unexpected response", true, "ATL0", validateInitResponse("ATL0", "NO DATA"), false},
+		{"timeout", true, "ATE0", fmt.Errorf("no response to ATE0 within 3s"), false},
+		{"no error", true, "ATE0", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Config{CloneQuirks: tt.quirks}
+			if got := config.tolerateInitError(tt.command, tt.err); got != tt.want {
+				t.Errorf("tolerateInitError() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestResetTimeout(t *testing.T) {
+	config := Config{ReadTimeout: time.Second}
+	if got := config.resetTimeout("ATZ"); got != time.Second {
+		t.Errorf("Expected read_timeout without quirks, got %v", got)
+	}
+
+	config.CloneQuirks = true
+	if got := config.resetTimeout("AT Z"); got != cloneResetTimeout {
+		t.Errorf("Expected %v for reset of a clone, got %v", cloneResetTimeout, got)
+	}
+	if got := config.resetTimeout("ATE0"); got != time.Second {
+		t.Errorf("Expected read_timeout for other commands, got %v", got)
+	}
+
+	config.ReadTimeout = 10 * time.Second
+	if got := config.resetTimeout("ATZ"); got != 10*time.Second {
+		t.Errorf("Expected longer read_timeout to be kept, got %v", got)
+	}
+}
+
+func TestAdapterCloneQuirks(t *testing.T) {
+	// Клон: эхо не выключается (ATE0 отвергается), приглашение дублируется, сброс медленный
+	elm := simulator.New(nil)
+	elm.Echo = true
+	elm.DoublePrompt = true
+	elm.ResetDelay = 300 * time.Millisecond
+	elm.SetResponse("ATE0", "?")
+
+	responsesChan := make(chan string, 10)
+	commandsChan := make(chan string, 10)
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+	config.ReadTimeout = 200 * time.Millisecond
+	config.HeartbeatInterval = 0
+	config.CloneQuirks = true
+	adapter := NewAdapter(config, responsesChan, commandsChan)
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return elm, nil }))
+
+	connected := make(chan struct{}, 1)
+	adapter.SetConnectHandler(func() { connected <- struct{}{} })
+	adapter.Start(context.Background())
+	defer func() {
+		elm.Close()
+		adapter.Stop()
+	}()
+
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Clone adapter initialization did not complete")
+	}
+
+	// Ответы без эха, каждый своей команде
+	commandsChan <- "010C"
+	commandsChan <- "010D"
+	for _, want := range []string{"7E8 04 41 0C 1A F8", "7E8 03 41 0D 3C"} {
+		select {
+		case response := <-responsesChan:
+			if response != want {
+				t.Errorf("Expected %q, got %q", want, response)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Expected response %q", want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package bluetooth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// defaultResetAfterErrors - ошибок подряд, после которых адаптер сбрасывается
+const defaultResetAfterErrors = 3
+
+// adapterErrorIndicators - ответы зависшего или перезагрузившегося адаптера. Ошибки шины
+// (CAN ERROR, BUS INIT ERROR) и NO DATA говорят о состоянии автомобиля и сброс не вызывают
+var adapterErrorIndicators = []string{
+	"?",           // Адаптер не понял запрос OBD: искаженный прием команды
+	"BUFFER FULL", // Переполнен буфер адаптера
+	"STOPPED",     // Запрос прерван, хотя мост ждал приглашения
+	"LV RESET",    // Адаптер перезагрузился из-за низкого напряжения и потерял настройки
+}
+
+// resetAfterErrors возвращает число ошибок подряд, после которых адаптер сбрасывается
+func (c Config) resetAfterErrors() int {
+	if c.ResetAfterErrors <= 0 {
+		return defaultResetAfterErrors
+	}
+	return c.ResetAfterErrors
+}
+
+// ValidateRecovery проверяет параметры восстановления адаптера
+func (c Config) ValidateRecovery() error {
+	if c.ResetAfterErrors < 0 {
+		return fmt.Errorf("invalid reset_after_errors %d", c.ResetAfterErrors)
+	}
+	return nil
+}
+
+// isAdapterError проверяет, что ответ на запрос OBD говорит о сбое самого адаптера
+func isAdapterError(response string) bool {
+	upper := strings.ToUpper(response)
+	for _, indicator := range adapterErrorIndicators {
+		if strings.Contains(upper, indicator) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordResult учитывает результат команды: успешный ответ обнуляет счетчик ошибок,
+// а reset_after_errors таймаутов или ошибок адаптера подряд требуют сброса ATZ
+// с повторной инициализацией. Многие зависания адаптера так снимаются без разрыва
+// соединения rfcomm, а если адаптер не ответит и на сброс, соединение переподключается
+func (a *Adapter) recordResult(failed bool) {
+	if !failed {
+		a.errorStreak.Store(0)
+		return
+	}
+	if streak := a.errorStreak.Add(1); int(streak) >= a.config.resetAfterErrors() {
+		a.errorStreak.Store(0)
+		logger.Printf("ELM327 failed %d consecutive commands, resetting adapter", streak)
+		a.resetNeeded.Store(true)
+	}
+}
+
+// takeReset проверяет, нужен ли сброс адаптера (вызывается из цикла записи).
+// Спящий после ATLP адаптер не сбрасывается: он не отвечает, пока его не разбудят
+func (a *Adapter) takeReset() bool {
+	if a.sleeping.Load() {
+		return false
+	}
+	return a.resetNeeded.Swap(false)
+}
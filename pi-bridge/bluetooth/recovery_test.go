@@ -0,0 +1,88 @@
+package bluetooth
+
+import (
+	"testing"
+	"time"
+
+	"elm327-bridge/simulator"
+)
+
+func TestIsAdapterError(t *testing.T) {
+	tests := []struct {
+		response string
+		want     bool
+	}{
+		{"?", true},
+		{"BUFFER FULL", true},
+		{"7E8 04 41 0C 1A F8\rSTOPPED", true},
+		{"LV RESET", true},
+		{"41 0C 1A F8", false},
+		{"NO DATA", false},
+		{"CAN ERROR", false},
+		{"SEARCHING...\rUNABLE TO CONNECT", false},
+	}
+
+	for _, tt := range tests {
+		if got := isAdapterError(tt.response); got != tt.want {
+			t.Errorf("isAdapterError(%q) = %v, want %v", tt.response, got, tt.want)
+		}
+	}
+}
+
+func TestValidateRecovery(t *testing.T) {
+	if err := (Config{ResetAfterErrors: 5}).ValidateRecovery(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := (Config{ResetAfterErrors: -1}).ValidateRecovery(); err == nil {
+		t.Error("Expected error for negative reset_after_errors")
+	}
+	if got := (Config{}).resetAfterErrors(); got != defaultResetAfterErrors {
+		t.Errorf("Expected default %d, got %d", defaultResetAfterErrors, got)
+	}
+}
+
+func TestAdapterResetsAfterConsecutiveErrors(t *testing.T) {
+	elm := simulator.New(nil)
+	elm.SetResponse("010D", "BUFFER FULL")
+	conn := &swallowingConn{ELM327: elm, swallow: "0105"}
+	_, responsesChan, commandsChan, _ := startRetryAdapter(t, conn, RetryConfig{})
+
+	// Успешный ответ обнуляет счетчик: две ошибки, ответ, две ошибки - сброса нет
+	for _, command := range []string{"0105", "010D", "010C", "0105", "010D", "010C"} {
+		commandsChan <- command
+	}
+	for i := 0; i < 4; i++ {
+		select {
+		case <-responsesChan:
+		case <-time.After(3 * time.Second):
+			t.Fatalf("Expected response %d", i+1)
+		}
+	}
+	if count := conn.count("ATZ"); count != 0 {
+		t.Fatalf("Expected no reset while errors are interleaved with answers, got %d", count)
+	}
+
+	// Третья ошибка подряд сбрасывает адаптер перед следующей командой
+	for _, command := range []string{"0105", "010D", "0105", "010C"} {
+		commandsChan <- command
+	}
+	select {
+	case response := <-responsesChan:
+		if response != "BUFFER FULL" {
+			t.Errorf("Unexpected response %q", response)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected BUFFER FULL response")
+	}
+	select {
+	case response := <-responsesChan:
+		if response != "7E8 04 41 0C 1A F8" {
+			t.Errorf("Expected answer after reinitialization, got %q", response)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected answer after reset")
+	}
+	if count := conn.count("ATZ"); count != 1 {
+		t.Errorf("Expected one reset after three consecutive errors, got %d", count)
+	}
+}
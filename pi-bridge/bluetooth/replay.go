@@ -0,0 +1,298 @@
+package bluetooth
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Направления событий записанного сеанса
+const (
+	sessionConnect = "connect" // Открыто новое соединение
+	sessionTx      = "tx"      // Байты, записанные мостом в адаптер
+	sessionRx      = "rx"      // Байты, полученные от адаптера
+)
+
+// defaultRecordMaxFiles - число прежних журналов, сохраняемых при ротации
+const defaultRecordMaxFiles = 3
+
+// sessionEvent - одна строка журнала сеанса (JSON Lines). Байты, не образующие строку
+// UTF-8 (помехи на линии, двоичный мусор клонов), записываются в hex, чтобы не исказиться
+type sessionEvent struct {
+	Time time.Time `json:"time"`
+	Dir  string    `json:"dir"`
+	Data string    `json:"data,omitempty"`
+	Hex  string    `json:"hex,omitempty"`
+}
+
+// sessionRotation - ротация журнала сеанса по размеру
+type sessionRotation struct {
+	maxSize  int64 // Размер журнала, после которого он переименовывается (0 - без ротации)
+	maxFiles int   // Число сохраняемых прежних журналов path.1 ... path.N
+}
+
+// sessionRotation возвращает параметры ротации журнала из конфигурации
+func (c Config) sessionRotation() sessionRotation {
+	rotation := sessionRotation{maxSize: int64(c.RecordMaxSize) << 20, maxFiles: c.RecordMaxFiles}
+	if rotation.maxFiles == 0 {
+		rotation.maxFiles = defaultRecordMaxFiles
+	}
+	return rotation
+}
+
+// sessionRecorder записывает сырой обмен с адаптером в журнал, чтобы проблему с конкретным
+// автомобилем можно было воспроизвести транспортом replay. Дедлайны передаются соединению
+type sessionRecorder struct {
+	conn     io.ReadWriteCloser
+	path     string
+	rotation sessionRotation
+
+	mu   sync.Mutex
+	file *os.File
+	size int64 // Текущий размер журнала
+}
+
+// recordSession оборачивает открытие соединения записью обмена в файл path (дописывается).
+// Журнал, выросший до rotation.maxSize, переименовывается в path.1, и запись продолжается в новый
+func recordSession(path string, rotation sessionRotation, open func() (io.ReadWriteCloser, error)) func() (io.ReadWriteCloser, error) {
+	return func() (io.ReadWriteCloser, error) {
+		conn, err := open()
+		if err != nil {
+			return nil, err
+		}
+
+		recorder := &sessionRecorder{conn: conn, path: path, rotation: rotation}
+		if err := recorder.openFile(); err != nil {
+			logger.Printf("Failed to open session record %s: %v, recording disabled", path, err)
+			return conn, nil
+		}
+		logger.Printf("Recording adapter session to %s", path)
+
+		recorder.record(sessionConnect, nil)
+		return recorder, nil
+	}
+}
+
+// openFile открывает журнал для дописывания (вызывается под мьютексом или до начала обмена)
+func (r *sessionRecorder) openFile() error {
+	file, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file, r.size = file, info.Size()
+	return nil
+}
+
+// rotate переименовывает журнал в path.1, сдвигая прежние (самый старый удаляется),
+// и открывает новый (вызывается под мьютексом)
+func (r *sessionRecorder) rotate() {
+	r.file.Close()
+	r.file = nil
+
+	for i := r.rotation.maxFiles - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", r.path, i), fmt.Sprintf("%s.%d", r.path, i+1))
+	}
+	if err := os.Rename(r.path, r.path+".1"); err != nil {
+		logger.Printf("Failed to rotate session record %s: %v", r.path, err)
+	}
+	if err := r.openFile(); err != nil {
+		logger.Printf("Failed to reopen session record %s: %v, recording disabled", r.path, err)
+		return
+	}
+	logger.Printf("Session record %s rotated", r.path)
+}
+
+// Read читает из соединения и записывает полученные байты
+func (r *sessionRecorder) Read(p []byte) (int, error) {
+	n, err := r.conn.Read(p)
+	if n > 0 {
+		r.record(sessionRx, p[:n])
+	}
+	return n, err
+}
+
+// Write записывает команду в соединение и в журнал
+func (r *sessionRecorder) Write(p []byte) (int, error) {
+	n, err := r.conn.Write(p)
+	if n > 0 {
+		r.record(sessionTx, p[:n])
+	}
+	return n, err
+}
+
+// Close закрывает соединение и журнал
+func (r *sessionRecorder) Close() error {
+	err := r.conn.Close()
+	r.mu.Lock()
+	if r.file != nil {
+		r.file.Close()
+		r.file = nil
+	}
+	r.mu.Unlock()
+	return err
+}
+
+// SetReadDeadline передает дедлайн чтения соединению, если оно его поддерживает
+func (r *sessionRecorder) SetReadDeadline(t time.Time) error {
+	if d, ok := r.conn.(readDeadliner); ok {
+		return d.SetReadDeadline(t)
+	}
+	return fmt.Errorf("read deadline is not supported")
+}
+
+// SetWriteDeadline передает дедлайн записи соединению, если оно его поддерживает
+func (r *sessionRecorder) SetWriteDeadline(t time.Time) error {
+	if d, ok := r.conn.(writeDeadliner); ok {
+		return d.SetWriteDeadline(t)
+	}
+	return fmt.Errorf("write deadline is not supported")
+}
+
+// record добавляет событие в журнал; ошибка записи журнала не прерывает обмен
+func (r *sessionRecorder) record(dir string, data []byte) {
+	event := sessionEvent{Time: time.Now(), Dir: dir}
+	if utf8.Valid(data) {
+		event.Data = string(data)
+	} else {
+		event.Hex = hex.EncodeToString(data)
+	}
+	line, err := json.Marshal(event)
+	if err != nil {
+		logger.Printf("Failed to encode session record: %v", err)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.file != nil && r.rotation.maxSize > 0 && r.size >= r.rotation.maxSize {
+		r.rotate()
+	}
+	if r.file == nil {
+		return
+	}
+	n, err := r.file.Write(append(line, '\n'))
+	r.size += int64(n)
+	if err != nil {
+		logger.Printf("Failed to write session record: %v", err)
+	}
+}
+
+// loadSession читает журнал сеанса
+func loadSession(path string) ([]sessionEvent, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open session record: %v", err)
+	}
+	defer file.Close()
+
+	var events []sessionEvent
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+		var event sessionEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("invalid session record %s line %d: %v", path, line, err)
+		}
+		if event.Hex != "" {
+			data, err := hex.DecodeString(event.Hex)
+			if err != nil {
+				return nil, fmt.Errorf("invalid session record %s line %d: %v", path, line, err)
+			}
+			event.Data, event.Hex = string(data), ""
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read session record: %v", err)
+	}
+	return events, nil
+}
+
+// dialReplay воспроизводит записанный сеанс вместо адаптера. Ответы адаптера выдаются
+// с исходными паузами, деленными на speed (0 - исходный темп), а каждая записанная команда
+// ждет команды моста: ответы не опережают запросы, даже если мост работает медленнее
+// записи. По окончании журнала соединение закрывается, и после переподключения
+// воспроизведение начинается сначала
+func dialReplay(path string, speed float64) (io.ReadWriteCloser, error) {
+	events, err := loadSession(path)
+	if err != nil {
+		return nil, err
+	}
+	if speed <= 0 {
+		speed = 1
+	}
+
+	client, server := net.Pipe()
+	writes := make(chan string, 16)
+	done := make(chan struct{})
+
+	// Команды моста читаются постоянно, чтобы запись не блокировалась во время пауз
+	go func() {
+		defer close(writes)
+		buf := make([]byte, 256)
+		for {
+			n, err := server.Read(buf)
+			if err != nil {
+				return
+			}
+			select {
+			case writes <- string(buf[:n]):
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer close(done)
+		defer server.Close()
+		logger.Printf("Replaying %d events from %s (speed x%g)", len(events), path, speed)
+
+		var last time.Time
+		for _, event := range events {
+			switch event.Dir {
+			case sessionConnect:
+				// Перерыв между соединениями записи не воспроизводится
+				last = time.Time{}
+				continue
+			case sessionTx:
+				sent, ok := <-writes
+				if !ok {
+					return
+				}
+				if strings.TrimSpace(sent) != strings.TrimSpace(event.Data) {
+					logger.Printf("Replay diverged: bridge sent %q, recording has %q", sent, event.Data)
+				}
+			case sessionRx:
+				if !last.IsZero() {
+					if pause := event.Time.Sub(last); pause > 0 {
+						time.Sleep(time.Duration(float64(pause) / speed))
+					}
+				}
+				if _, err := server.Write([]byte(event.Data)); err != nil {
+					return
+				}
+			}
+			last = event.Time
+		}
+		logger.Printf("Replay of %s finished", path)
+	}()
+
+	return client, nil
+}
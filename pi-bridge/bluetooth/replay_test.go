@@ -0,0 +1,232 @@
+package bluetooth
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"elm327-bridge/simulator"
+)
+
+func TestRecordSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	open := recordSession(path, sessionRotation{}, func() (io.ReadWriteCloser, error) { return simulator.New(nil), nil })
+
+	conn, err := open()
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	if _, err := conn.Write([]byte("ATRV\r")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	var received strings.Builder
+	buf := make([]byte, 64)
+	for !strings.Contains(received.String(), ">") {
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		received.Write(buf[:n])
+	}
+	conn.Close()
+
+	events, err := loadSession(path)
+	if err != nil {
+		t.Fatalf("loadSession failed: %v", err)
+	}
+	if len(events) < 3 || events[0].Dir != sessionConnect || events[1].Dir != sessionTx || events[1].Data != "ATRV\r" {
+		t.Fatalf("Unexpected recorded events: %+v", events)
+	}
+	var rx strings.Builder
+	for _, event := range events[2:] {
+		if event.Dir != sessionRx {
+			t.Errorf("Unexpected event after command: %+v", event)
+		}
+		rx.WriteString(event.Data)
+	}
+	if rx.String() != received.String() {
+		t.Errorf("Recorded %q, received %q", rx.String(), received.String())
+	}
+}
+
+func TestRecordSessionRotation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "session.jsonl")
+	rotation := sessionRotation{maxSize: 300, maxFiles: 2}
+
+	// Каждое событие занимает около 80 байт: журнал ротируется несколько раз
+	recorder := &sessionRecorder{conn: simulator.New(nil), path: path, rotation: rotation}
+	if err := recorder.openFile(); err != nil {
+		t.Fatalf("openFile failed: %v", err)
+	}
+	for i := 0; i < 20; i++ {
+		recorder.record(sessionTx, []byte("010C\r"))
+	}
+	recorder.Close()
+
+	for _, name := range []string{"session.jsonl", "session.jsonl.1", "session.jsonl.2"} {
+		info, err := os.Stat(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("Expected %s after rotation: %v", name, err)
+		}
+		if name != "session.jsonl" && info.Size() < rotation.maxSize {
+			t.Errorf("Rotated %s is smaller than the limit: %d bytes", name, info.Size())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "session.jsonl.3")); !os.IsNotExist(err) {
+		t.Errorf("Expected at most %d rotated records, got session.jsonl.3 (%v)", rotation.maxFiles, err)
+	}
+
+}
+
+func TestRecordSessionBinary(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	recorder := &sessionRecorder{conn: simulator.New(nil), path: path}
+	if err := recorder.openFile(); err != nil {
+		t.Fatalf("openFile failed: %v", err)
+	}
+	binary := []byte{'4', '1', 0xFF, 0xFE, '\r', '>'}
+	recorder.record(sessionRx, []byte("OK\r>"))
+	recorder.record(sessionRx, binary)
+	recorder.Close()
+
+	raw, _ := os.ReadFile(path)
+	if !strings.Contains(string(raw), `"hex":"3431fffe0d3e"`) {
+		t.Errorf("Expected binary data recorded as hex, got %s", raw)
+	}
+
+	events, err := loadSession(path)
+	if err != nil {
+		t.Fatalf("loadSession failed: %v", err)
+	}
+	if len(events) != 2 || events[0].Data != "OK\r>" || events[1].Data != string(binary) {
+		t.Errorf("Unexpected loaded events: %+v", events)
+	}
+}
+
+func TestLoadSessionErrors(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := loadSession(filepath.Join(dir, "missing.jsonl")); err == nil {
+		t.Error("Expected error for missing record")
+	}
+
+	path := filepath.Join(dir, "broken.jsonl")
+	os.WriteFile(path, []byte("{\"dir\":\"rx\"}\nnot json\n"), 0644)
+	if _, err := loadSession(path); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected error pointing to line 2, got %v", err)
+	}
+
+	os.WriteFile(path, []byte("{\"dir\":\"rx\",\"hex\":\"zz\"}\n"), 0644)
+	if _, err := loadSession(path); err == nil || !strings.Contains(err.Error(), "line 1") {
+		t.Errorf("Expected error for invalid hex data, got %v", err)
+	}
+}
+
+func TestReplayTiming(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	record := `{"time":"` + start.Format(time.RFC3339Nano) + `","dir":"connect"}
+{"time":"` + start.Add(10*time.Millisecond).Format(time.RFC3339Nano) + `","dir":"tx","data":"010C\r"}
+{"time":"` + start.Add(410*time.Millisecond).Format(time.RFC3339Nano) + `","dir":"rx","data":"41 0C 1A F8\r\r>"}
+`
+	os.WriteFile(path, []byte(record), 0644)
+
+	tests := []struct {
+		name  string
+		speed float64
+		min   time.Duration
+		max   time.Duration
+	}{
+		{"original timing", 0, 350 * time.Millisecond, 2 * time.Second},
+		{"accelerated", 100, 0, 200 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := dialReplay(path, tt.speed)
+			if err != nil {
+				t.Fatalf("dialReplay failed: %v", err)
+			}
+			defer conn.Close()
+
+			start := time.Now()
+			conn.Write([]byte("010C\r"))
+			data, err := io.ReadAll(conn)
+			elapsed := time.Since(start)
+			if err != nil {
+				t.Fatalf("Read failed: %v", err)
+			}
+			if string(data) != "41 0C 1A F8\r\r>" {
+				t.Errorf("Unexpected replayed data %q", data)
+			}
+			if elapsed < tt.min || elapsed > tt.max {
+				t.Errorf("Replay took %v, expected %v-%v", elapsed, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestAdapterReplaysRecordedSession(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+
+	// Запись: инициализация и запрос к симулятору
+	responses := runRecordedAdapter(t, NewStreamTransport(recordSession(path, sessionRotation{}, func() (io.ReadWriteCloser, error) {
+		elm := simulator.New(nil)
+		elm.SetResponse("0105", "41 05 7B")
+		return elm, nil
+	})))
+
+	// Воспроизведение: те же ответы приходят без симулятора
+	config := DefaultConfig()
+	config.Transport = TransportReplay
+	config.ReplayPath = path
+	config.ReplaySpeed = 10
+	config.DevicePath = ""
+	if err := config.ValidateTransport(); err != nil {
+		t.Fatalf("ValidateTransport failed: %v", err)
+	}
+	replayed := runRecordedAdapter(t, NewTransport(config))
+
+	if replayed != responses {
+		t.Errorf("Replayed response %q, recorded %q", replayed, responses)
+	}
+}
+
+// runRecordedAdapter подключает адаптер через transport, отправляет 0105 и возвращает ответ
+func runRecordedAdapter(t *testing.T, transport Transport) string {
+	t.Helper()
+	responsesChan := make(chan string, 10)
+	commandsChan := make(chan string, 10)
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+	config.HeartbeatInterval = 0
+	adapter := NewAdapter(config, responsesChan, commandsChan)
+	adapter.SetTransport(transport)
+
+	connected := make(chan struct{}, 1)
+	adapter.SetConnectHandler(func() { notify(connected) })
+	adapter.Start(context.Background())
+	defer func() {
+		transport.Close()
+		adapter.Stop()
+	}()
+
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Adapter initialization did not complete")
+	}
+
+	commandsChan <- "0105"
+	select {
+	case response := <-responsesChan:
+		return response
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected answer to 0105")
+	}
+	return ""
+}
@@ -0,0 +1,71 @@
+package bluetooth
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Ограничения политики повторов
+const (
+	maxRetryAttempts    = 10
+	defaultRetryBackoff = 250 * time.Millisecond
+	defaultMaxBackoff   = 2 * time.Second
+)
+
+// RetryConfig задает повтор запросов OBD, оставшихся без ответа или получивших NO DATA:
+// медленный ЭБУ или помеха на шине не приводят к потере запроса клиента. AT команды
+// отвечает сам адаптер, они не повторяются
+type RetryConfig struct {
+	MaxAttempts int           `yaml:"max_attempts"` // Попыток на запрос (0 или 1 - без повторов)
+	Backoff     time.Duration `yaml:"backoff"`      // Пауза перед первым повтором, удваивается (0 - 250 мс)
+	MaxBackoff  time.Duration `yaml:"max_backoff"`  // Наибольшая пауза между попытками (0 - 2 с)
+}
+
+// Validate проверяет параметры повторов
+func (c RetryConfig) Validate() error {
+	if c.MaxAttempts < 0 || c.MaxAttempts > maxRetryAttempts {
+		return fmt.Errorf("invalid retry max_attempts %d: expected 0-%d", c.MaxAttempts, maxRetryAttempts)
+	}
+	if c.Backoff < 0 {
+		return fmt.Errorf("invalid retry backoff %v", c.Backoff)
+	}
+	if c.MaxBackoff < 0 {
+		return fmt.Errorf("invalid retry max_backoff %v", c.MaxBackoff)
+	}
+	return nil
+}
+
+// attempts возвращает число попыток для команды
+func (c RetryConfig) attempts(command string) int {
+	if c.MaxAttempts <= 1 || isATCommand(command) || isMonitorCommand(command) {
+		return 1
+	}
+	return c.MaxAttempts
+}
+
+// delay возвращает паузу после неудачной попытки attempt (с 1): backoff, 2*backoff, ...
+func (c RetryConfig) delay(attempt int) time.Duration {
+	backoff := c.Backoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	limit := c.MaxBackoff
+	if limit <= 0 {
+		limit = defaultMaxBackoff
+	}
+
+	delay := backoff
+	for i := 1; i < attempt && delay < limit; i++ {
+		delay *= 2
+	}
+	if delay > limit {
+		delay = limit
+	}
+	return delay
+}
+
+// isNoDataResponse проверяет, что ЭБУ не ответил на запрос
+func isNoDataResponse(response string) bool {
+	return strings.Contains(strings.ToUpper(response), "NO DATA")
+}
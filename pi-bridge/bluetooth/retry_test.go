@@ -0,0 +1,170 @@
+package bluetooth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+	"elm327-bridge/simulator"
+)
+
+func TestRetryConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  RetryConfig
+		wantErr bool
+	}{
+		{"disabled", RetryConfig{}, false},
+		{"custom", RetryConfig{MaxAttempts: 3, Backoff: 100 * time.Millisecond, MaxBackoff: time.Second}, false},
+		{"negative attempts", RetryConfig{MaxAttempts: -1}, true},
+		{"too many attempts", RetryConfig{MaxAttempts: 11}, true},
+		{"negative backoff", RetryConfig{MaxAttempts: 3, Backoff: -time.Second}, true},
+		{"negative max backoff", RetryConfig{MaxAttempts: 3, MaxBackoff: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRetryAttempts(t *testing.T) {
+	config := RetryConfig{MaxAttempts: 3}
+	tests := []struct {
+		command  string
+		expected int
+	}{
+		{"010C", 3},
+		{"03", 3},
+		{"ATRV", 1},
+		{"STDI", 1},
+		{"ATMA", 1},
+	}
+
+	for _, tt := range tests {
+		if got := config.attempts(tt.command); got != tt.expected {
+			t.Errorf("attempts(%q) = %d, expected %d", tt.command, got, tt.expected)
+		}
+	}
+	if got := (RetryConfig{}).attempts("010C"); got != 1 {
+		t.Errorf("Expected a single attempt without retry policy, got %d", got)
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	config := RetryConfig{Backoff: 100 * time.Millisecond, MaxBackoff: 300 * time.Millisecond}
+	expected := []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 300 * time.Millisecond, 300 * time.Millisecond}
+	for i, want := range expected {
+		if got := config.delay(i + 1); got != want {
+			t.Errorf("delay(%d) = %v, expected %v", i+1, got, want)
+		}
+	}
+
+	if got := (RetryConfig{}).delay(1); got != defaultRetryBackoff {
+		t.Errorf("Expected default backoff %v, got %v", defaultRetryBackoff, got)
+	}
+}
+
+// startRetryAdapter запускает циклы чтения и записи адаптера с политикой повторов поверх conn
+func startRetryAdapter(t *testing.T, conn *swallowingConn, retry RetryConfig) (*Adapter, chan string, chan string, chan common.CommandResponse) {
+	t.Helper()
+	responsesChan := make(chan string, 10)
+	commandsChan := make(chan string, 10)
+	commandResponses := make(chan common.CommandResponse, 10)
+
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+	config.ReadTimeout = 100 * time.Millisecond
+	config.Retry = retry
+	adapter := NewAdapter(config, responsesChan, commandsChan)
+	adapter.SetPendingRequests(common.NewPendingRequests(5*time.Second, commandResponses))
+	useConnection(t, adapter, conn)
+
+	adapter.wg.Add(2)
+	go adapter.readLoop()
+	go adapter.writeLoop()
+	t.Cleanup(func() {
+		conn.Close()
+		adapter.Stop()
+	})
+	return adapter, responsesChan, commandsChan, commandResponses
+}
+
+func TestAdapterRetriesTimedOutCommand(t *testing.T) {
+	conn := &swallowingConn{ELM327: simulator.New(nil), swallow: "010C", limit: 1}
+	adapter, responsesChan, commandsChan, commandResponses := startRetryAdapter(t, conn, RetryConfig{MaxAttempts: 2, Backoff: 10 * time.Millisecond})
+
+	adapter.requests.Register("req-1", []string{"010C"})
+	commandsChan <- "010C"
+
+	// Один таймаут не сбрасывает адаптер: заголовки, выключенные до инициализации, не включаются
+	select {
+	case response := <-responsesChan:
+		if response != "41 0C 1A F8" {
+			t.Errorf("Unexpected response %q", response)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected answer to the retried command")
+	}
+	if conn.count("010C") != 2 {
+		t.Errorf("Expected 010C to be sent twice, got %d", conn.count("010C"))
+	}
+
+	// Таймаут первой попытки учитывается, но не завершает запрос клиента ошибкой
+	select {
+	case response := <-commandResponses:
+		t.Errorf("Unexpected command response before parsing: %+v", response)
+	default:
+	}
+}
+
+func TestAdapterReportsFinalNoData(t *testing.T) {
+	elm := simulator.New(nil)
+	elm.SetResponse("0105", "NO DATA")
+	conn := &swallowingConn{ELM327: elm}
+	_, responsesChan, commandsChan, _ := startRetryAdapter(t, conn, RetryConfig{MaxAttempts: 3, Backoff: 10 * time.Millisecond})
+
+	commandsChan <- "0105"
+
+	// Парсер получает только ответ последней попытки
+	select {
+	case response := <-responsesChan:
+		if response != "NO DATA" {
+			t.Errorf("Unexpected response %q", response)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected final NO DATA to reach the parser")
+	}
+	if conn.count("0105") != 3 {
+		t.Errorf("Expected 0105 to be sent 3 times, got %d", conn.count("0105"))
+	}
+	select {
+	case response := <-responsesChan:
+		t.Errorf("Unexpected extra response %q", response)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestAdapterReportsFinalTimeout(t *testing.T) {
+	conn := &swallowingConn{ELM327: simulator.New(nil), swallow: "010D"}
+	adapter, _, commandsChan, commandResponses := startRetryAdapter(t, conn, RetryConfig{MaxAttempts: 2, Backoff: 10 * time.Millisecond})
+
+	adapter.requests.Register("req-2", []string{"010D"})
+	commandsChan <- "010D"
+
+	select {
+	case response := <-commandResponses:
+		if response.CorrelationID != "req-2" || response.Status != "error" || !strings.Contains(response.Error, "no response to 010D") {
+			t.Errorf("Unexpected command response: %+v", response)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected final timeout to be reported to the client")
+	}
+	if conn.count("010D") != 2 {
+		t.Errorf("Expected 010D to be sent twice, got %d", conn.count("010D"))
+	}
+}
@@ -0,0 +1,126 @@
+package bluetooth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// rfcommMaxDevices - число устройств /dev/rfcommN, поддерживаемых ядром (RFCOMM_MAX_DEV)
+const rfcommMaxDevices = 256
+
+// rfcommNodeTimeout - время ожидания узла устройства после привязки: узел создает udev
+// асинхронно, уже после возврата из ioctl
+const rfcommNodeTimeout = 2 * time.Second
+
+// errRFCOMMBound возвращается при привязке устройства, уже привязанного ранее
+// (rfcomm bind или предыдущий запуск моста, завершившийся аварийно)
+var errRFCOMMBound = errors.New("device is already bound")
+
+// rfcommDeviceID возвращает номер устройства из пути вида /dev/rfcomm0
+func rfcommDeviceID(path string) (int, error) {
+	name := filepath.Base(path)
+	number, ok := strings.CutPrefix(name, "rfcomm")
+	if !ok {
+		return 0, fmt.Errorf("invalid RFCOMM device %q: expected /dev/rfcommN", path)
+	}
+	id, err := strconv.Atoi(number)
+	if err != nil || id < 0 || id >= rfcommMaxDevices || strconv.Itoa(id) != number {
+		return 0, fmt.Errorf("invalid RFCOMM device %q: expected /dev/rfcommN", path)
+	}
+	return id, nil
+}
+
+// validateRFCOMMBind проверяет параметры привязки устройства rfcomm_bind
+func (c Config) validateRFCOMMBind() error {
+	if !c.RFCOMMBind {
+		return nil
+	}
+	if transportType(c) != TransportSerial {
+		return fmt.Errorf("bluetooth.rfcomm_bind requires %s transport", TransportSerial)
+	}
+	if _, err := parseMAC(c.Address); err != nil {
+		return fmt.Errorf("bluetooth.rfcomm_bind requires adapter address: %v", err)
+	}
+	if _, err := rfcommDeviceID(c.DevicePath); err != nil {
+		return err
+	}
+	if c.Channel < 0 || c.Channel > 30 {
+		return fmt.Errorf("invalid RFCOMM channel %d: expected 1-30", c.Channel)
+	}
+	return nil
+}
+
+// bindRFCOMM привязывает device_path к адресу адаптера (аналог rfcomm bind), чтобы
+// устройство существовало до первой попытки подключения. Ошибка не останавливает мост:
+// без прав (CAP_NET_ADMIN) устройство может быть привязано внешним rfcomm bind.
+// Возвращается только ошибка ctx, отмененного во время ожидания узла устройства
+func (a *Adapter) bindRFCOMM(ctx context.Context) error {
+	if !a.config.RFCOMMBind {
+		return nil
+	}
+	path := a.config.DevicePath
+	id, err := rfcommDeviceID(path)
+	if err != nil {
+		logger.Printf("Warning: failed to bind %s: %v", path, err)
+		return nil
+	}
+	mac, err := parseMAC(a.config.Address)
+	if err != nil {
+		logger.Printf("Warning: failed to bind %s: %v", path, err)
+		return nil
+	}
+
+	channel := rfcommChannel(a.config)
+	err = createRFCOMMDevice(id, mac, channel)
+	switch {
+	case errors.Is(err, errRFCOMMBound):
+		// Чужую привязку мост не снимает при остановке
+		logger.Printf("%s is already bound, using existing binding", path)
+		return nil
+	case err != nil:
+		logger.Printf("Warning: failed to bind %s to %s: %v. Run 'sudo rfcomm bind' or start the bridge as root", path, a.config.Address, err)
+		return nil
+	}
+	a.rfcommBound = true
+	logger.Printf("Bound %s to %s (RFCOMM channel %d)", path, a.config.Address, channel)
+
+	// Без узла первая попытка подключения завершилась бы ошибкой до reconnect_interval
+	deadline := time.NewTimer(rfcommNodeTimeout)
+	defer deadline.Stop()
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		if _, err := os.Stat(path); err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-deadline.C:
+			logger.Printf("Warning: %s did not appear after binding", path)
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// releaseRFCOMM снимает привязку, созданную bindRFCOMM (аналог rfcomm release)
+func (a *Adapter) releaseRFCOMM() {
+	if !a.rfcommBound {
+		return
+	}
+	a.rfcommBound = false
+
+	id, _ := rfcommDeviceID(a.config.DevicePath)
+	if err := releaseRFCOMMDevice(id); err != nil {
+		logger.Printf("Warning: failed to release %s: %v", a.config.DevicePath, err)
+		return
+	}
+	logger.Printf("Released %s", a.config.DevicePath)
+}
@@ -0,0 +1,84 @@
+package bluetooth
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// ioctl управления устройствами /dev/rfcommN (include/net/bluetooth/rfcomm.h):
+// _IOW('R', 200, int), _IOW('R', 201, int) и _IOR('R', 211, int)
+const (
+	rfcommCreateDev  = 0x400452c8
+	rfcommReleaseDev = 0x400452c9
+	rfcommGetDevInfo = 0x800452d3
+)
+
+// rfcommDevReq повторяет struct rfcomm_dev_req ядра. Флаги не задаются, как у rfcomm bind:
+// с RFCOMM_RELEASE_ONHUP устройство исчезало бы при каждом разрыве связи
+type rfcommDevReq struct {
+	devID   int16
+	flags   uint32
+	src     [6]byte // Локальный адаптер (нули - любой)
+	dst     [6]byte // Адрес ELM327 в обратном порядке байтов
+	channel uint8
+}
+
+// rfcommDevInfo повторяет struct rfcomm_dev_info ядра
+type rfcommDevInfo struct {
+	id      int16
+	flags   uint32
+	state   uint16
+	src     [6]byte
+	dst     [6]byte // Адрес привязки в обратном порядке байтов
+	channel uint8
+}
+
+// newRFCOMMDevReq заполняет запрос привязки устройства id к адресу mac
+func newRFCOMMDevReq(id int, mac [6]byte, channel int) rfcommDevReq {
+	req := rfcommDevReq{devID: int16(id), channel: uint8(channel)}
+	for i := range mac {
+		req.dst[i] = mac[len(mac)-1-i]
+	}
+	return req
+}
+
+// createRFCOMMDevice создает устройство /dev/rfcommN, подключающееся к адресу при открытии
+func createRFCOMMDevice(id int, mac [6]byte, channel int) error {
+	req := newRFCOMMDevReq(id, mac, channel)
+	err := rfcommIoctl(rfcommCreateDev, unsafe.Pointer(&req))
+	if err == unix.EADDRINUSE {
+		return errRFCOMMBound
+	}
+	return err
+}
+
+// releaseRFCOMMDevice удаляет устройство /dev/rfcommN
+func releaseRFCOMMDevice(id int) error {
+	req := rfcommDevReq{devID: int16(id)}
+	return rfcommIoctl(rfcommReleaseDev, unsafe.Pointer(&req))
+}
+
+// rfcommDeviceAddress возвращает адрес, к которому привязано устройство /dev/rfcommN
+func rfcommDeviceAddress(id int) (string, error) {
+	info := rfcommDevInfo{id: int16(id)}
+	if err := rfcommIoctl(rfcommGetDevInfo, unsafe.Pointer(&info)); err != nil {
+		return "", err
+	}
+	return formatBDAddr(info.dst[:]), nil
+}
+
+// rfcommIoctl выполняет запрос через управляющий сокет RFCOMM
+func rfcommIoctl(request uintptr, req unsafe.Pointer) error {
+	fd, err := unix.Socket(unix.AF_BLUETOOTH, unix.SOCK_RAW|unix.SOCK_CLOEXEC, unix.BTPROTO_RFCOMM)
+	if err != nil {
+		return fmt.Errorf("failed to create RFCOMM control socket: %v", err)
+	}
+	defer unix.Close(fd)
+
+	if _, _, errno := unix.Syscall(unix.SYS_IOCTL, uintptr(fd), request, uintptr(req)); errno != 0 {
+		return errno
+	}
+	return nil
+}
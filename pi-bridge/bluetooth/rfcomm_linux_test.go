@@ -0,0 +1,53 @@
+package bluetooth
+
+import (
+	"testing"
+	"unsafe"
+)
+
+func TestRFCOMMDevReqLayout(t *testing.T) {
+	// Смещения struct rfcomm_dev_req: ядро копирует запрос из памяти процесса как есть
+	var req rfcommDevReq
+	if size := unsafe.Sizeof(req); size != 24 {
+		t.Errorf("Expected request size 24, got %d", size)
+	}
+	offsets := map[string][2]uintptr{
+		"flags":   {unsafe.Offsetof(req.flags), 4},
+		"src":     {unsafe.Offsetof(req.src), 8},
+		"dst":     {unsafe.Offsetof(req.dst), 14},
+		"channel": {unsafe.Offsetof(req.channel), 20},
+	}
+	for field, offset := range offsets {
+		if offset[0] != offset[1] {
+			t.Errorf("Expected %s at offset %d, got %d", field, offset[1], offset[0])
+		}
+	}
+
+	req = newRFCOMMDevReq(3, [6]byte{0x00, 0x1D, 0xA5, 0x68, 0x98, 0x8B}, 2)
+	if req.devID != 3 || req.channel != 2 || req.flags != 0 {
+		t.Errorf("Unexpected request %+v", req)
+	}
+	if req.dst != [6]byte{0x8B, 0x98, 0x68, 0xA5, 0x1D, 0x00} {
+		t.Errorf("Expected address in reversed byte order, got % X", req.dst)
+	}
+}
+
+func TestRFCOMMDevInfoLayout(t *testing.T) {
+	// Смещения struct rfcomm_dev_info: адрес читается из ответа ядра по смещению
+	var info rfcommDevInfo
+	if size := unsafe.Sizeof(info); size != 24 {
+		t.Errorf("Expected info size 24, got %d", size)
+	}
+	offsets := map[string][2]uintptr{
+		"flags":   {unsafe.Offsetof(info.flags), 4},
+		"state":   {unsafe.Offsetof(info.state), 8},
+		"src":     {unsafe.Offsetof(info.src), 10},
+		"dst":     {unsafe.Offsetof(info.dst), 16},
+		"channel": {unsafe.Offsetof(info.channel), 22},
+	}
+	for field, offset := range offsets {
+		if offset[0] != offset[1] {
+			t.Errorf("Expected %s at offset %d, got %d", field, offset[1], offset[0])
+		}
+	}
+}
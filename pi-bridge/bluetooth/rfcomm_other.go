@@ -0,0 +1,20 @@
+//go:build !linux
+
+package bluetooth
+
+import "fmt"
+
+// createRFCOMMDevice на платформах кроме Linux недоступен: устройства rfcomm есть только в Linux
+func createRFCOMMDevice(id int, mac [6]byte, channel int) error {
+	return fmt.Errorf("rfcomm_bind is only supported on Linux")
+}
+
+// releaseRFCOMMDevice на платформах кроме Linux недоступен
+func releaseRFCOMMDevice(id int) error {
+	return fmt.Errorf("rfcomm_bind is only supported on Linux")
+}
+
+// rfcommDeviceAddress на платформах кроме Linux недоступен
+func rfcommDeviceAddress(id int) (string, error) {
+	return "", fmt.Errorf("rfcomm devices are only supported on Linux")
+}
@@ -0,0 +1,32 @@
+package bluetooth
+
+import "testing"
+
+func TestRFCOMMDeviceID(t *testing.T) {
+	tests := []struct {
+		path    string
+		want    int
+		wantErr bool
+	}{
+		{"/dev/rfcomm0", 0, false},
+		{"/dev/rfcomm12", 12, false},
+		{"rfcomm255", 255, false},
+		{"/dev/rfcomm256", 0, true},
+		{"/dev/rfcomm", 0, true},
+		{"/dev/rfcomm01", 0, true},
+		{"/dev/rfcomm-1", 0, true},
+		{"/dev/ttyUSB0", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			got, err := rfcommDeviceID(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("rfcommDeviceID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("rfcommDeviceID() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package bluetooth
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// Параметры линии по умолчанию: 8 бит данных, без четности, 1 стоп-бит (8N1).
+// Так настроены ELM327 и большинство USB кабелей
+const (
+	defaultDataBits = 8
+	defaultStopBits = 1
+	parityNone      = "none"
+	parityEven      = "even"
+	parityOdd       = "odd"
+)
+
+// Управление потоком линии. По умолчанию отключено: большинство адаптеров ELM327
+// не подключают RTS/CTS, и включенное аппаратное управление блокирует запись
+const (
+	flowControlNone     = "none"
+	flowControlHardware = "rtscts"
+	flowControlSoftware = "xonxoff"
+)
+
+// ValidateSerial проверяет параметры линии USB/UART адаптера.
+// Поддержка скорости порта проверяется при открытии устройства
+func (c Config) ValidateSerial() error {
+	if c.BaudRate < 0 {
+		return fmt.Errorf("invalid baud rate %d", c.BaudRate)
+	}
+	if c.DataBits != 0 && (c.DataBits < 5 || c.DataBits > 8) {
+		return fmt.Errorf("invalid data bits %d: expected 5-8", c.DataBits)
+	}
+	switch serialParity(c) {
+	case parityNone, parityEven, parityOdd:
+	default:
+		return fmt.Errorf("invalid parity %q: expected none, even or odd", c.Parity)
+	}
+	if c.StopBits != 0 && c.StopBits != 1 && c.StopBits != 2 {
+		return fmt.Errorf("invalid stop bits %d: expected 1 or 2", c.StopBits)
+	}
+	switch serialFlowControl(c) {
+	case flowControlNone, flowControlHardware, flowControlSoftware:
+	default:
+		return fmt.Errorf("invalid flow control %q: expected none, rtscts or xonxoff", c.FlowControl)
+	}
+	return nil
+}
+
+// serialDataBits возвращает число бит данных с учетом значения по умолчанию
+func serialDataBits(c Config) int {
+	if c.DataBits == 0 {
+		return defaultDataBits
+	}
+	return c.DataBits
+}
+
+// serialParity возвращает четность в нижнем регистре с учетом значения по умолчанию
+func serialParity(c Config) string {
+	parity := strings.ToLower(strings.TrimSpace(c.Parity))
+	if parity == "" {
+		return parityNone
+	}
+	return parity
+}
+
+// serialStopBits возвращает число стоп-битов с учетом значения по умолчанию
+func serialStopBits(c Config) int {
+	if c.StopBits == 0 {
+		return defaultStopBits
+	}
+	return c.StopBits
+}
+
+// serialFlowControl возвращает управление потоком в нижнем регистре с учетом значения по умолчанию
+func serialFlowControl(c Config) string {
+	flowControl := strings.ToLower(strings.TrimSpace(c.FlowControl))
+	if flowControl == "" {
+		return flowControlNone
+	}
+	return flowControl
+}
+
+// openSerialDevice открывает и настраивает устройство из конфигурации
+func openSerialDevice(config Config) (io.ReadWriteCloser, error) {
+	logger.Printf("Attempting to connect to %s", config.DevicePath)
+
+	// Проверяем, существует ли устройство
+	if _, err := os.Stat(config.DevicePath); os.IsNotExist(err) {
+		if strings.Contains(config.DevicePath, "rfcomm") {
+			return nil, fmt.Errorf("device %s does not exist. Please run 'sudo rfcomm bind' first or enable rfcomm_bind", config.DevicePath)
+		}
+		return nil, fmt.Errorf("device %s does not exist. Check that the USB adapter is plugged in", config.DevicePath)
+	}
+
+	// Открываем устройство
+	file, err := os.OpenFile(config.DevicePath, os.O_RDWR|unix.O_NOCTTY|os.O_SYNC, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %v", config.DevicePath, err)
+	}
+
+	// Настраиваем линию явно: унаследованные настройки часто искажают ответы
+	if err := configureSerial(file, config); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to configure %s: %v", config.DevicePath, err)
+	}
+
+	return &serialPort{File: file}, nil
+}
+
+// serialPort оборачивает устройство с VMIN=0: чтение, завершившееся по таймауту VTIME
+// без данных, возвращает 0 байт, что os.File сообщает как io.EOF. Для линии это не
+// конец потока, поэтому такой результат возвращается как пустое чтение без ошибки
+type serialPort struct {
+	*os.File
+}
+
+// Read читает данные, не считая таймаут линии концом потока
+func (p *serialPort) Read(b []byte) (int, error) {
+	n, err := p.File.Read(b)
+	if n == 0 && err == io.EOF {
+		return 0, nil
+	}
+	return n, err
+}
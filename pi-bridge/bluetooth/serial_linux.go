@@ -0,0 +1,119 @@
+package bluetooth
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// baudRates сопоставляет скорость порта с константой termios
+var baudRates = map[int]uint32{
+	4800:   unix.B4800,
+	9600:   unix.B9600,
+	19200:  unix.B19200,
+	38400:  unix.B38400,
+	57600:  unix.B57600,
+	115200: unix.B115200,
+	230400: unix.B230400,
+	500000: unix.B500000,
+}
+
+// characterSizes сопоставляет число бит данных с константой termios
+var characterSizes = map[int]uint32{
+	5: unix.CS5,
+	6: unix.CS6,
+	7: unix.CS7,
+	8: unix.CS8,
+}
+
+// configureSerial переводит линию в "сырой" режим без эха и канонической обработки,
+// задавая VMIN/VTIME, формат кадра (биты данных, четность, стоп-биты), управление
+// потоком и скорость явно, вместо того чтобы полагаться на оставшиеся настройки линии.
+// BaudRate == 0 оставляет скорость без изменений (для rfcomm она не используется)
+func configureSerial(file *os.File, config Config) error {
+	if err := config.ValidateSerial(); err != nil {
+		return err
+	}
+	fd := int(file.Fd())
+
+	termios, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		if err == unix.ENOTTY {
+			// Не терминал (например, FIFO в тестовом окружении) - настраивать нечего
+			return nil
+		}
+		return fmt.Errorf("failed to get termios: %v", err)
+	}
+
+	// Аналог cfmakeraw: без преобразования символов, эха, сигналов и канонического режима
+	termios.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL
+	termios.Oflag &^= unix.OPOST
+	termios.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	setFrameFormat(termios, config)
+
+	// VMIN=0, VTIME=таймаут чтения: read возвращает данные по мере поступления
+	// или 0 байт по истечении таймаута (в десятых долях секунды, не более 25.5 с)
+	vtime := config.ReadTimeout / (100 * time.Millisecond)
+	if vtime < 1 {
+		vtime = 1
+	}
+	if vtime > 255 {
+		vtime = 255
+	}
+	termios.Cc[unix.VMIN] = 0
+	termios.Cc[unix.VTIME] = uint8(vtime)
+
+	if config.BaudRate != 0 {
+		speed, ok := baudRates[config.BaudRate]
+		if !ok {
+			return fmt.Errorf("unsupported baud rate %d", config.BaudRate)
+		}
+		termios.Cflag &^= unix.CBAUD
+		termios.Cflag |= speed
+		termios.Ispeed = speed
+		termios.Ospeed = speed
+	}
+
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, termios); err != nil {
+		return fmt.Errorf("failed to set termios: %v", err)
+	}
+
+	// Сбрасываем данные, накопленные в буферах линии до настройки
+	if err := unix.IoctlSetInt(fd, unix.TCFLSH, unix.TCIOFLUSH); err != nil {
+		logger.Printf("Warning: failed to flush serial buffers: %v", err)
+	}
+
+	return nil
+}
+
+// setFrameFormat задает формат кадра линии: биты данных, четность, стоп-биты
+// и управление потоком
+func setFrameFormat(termios *unix.Termios, config Config) {
+	termios.Iflag &^= unix.INPCK | unix.IXON | unix.IXOFF | unix.IXANY
+	termios.Cflag &^= unix.CSIZE | unix.PARENB | unix.PARODD | unix.CSTOPB | unix.CRTSCTS
+	termios.Cflag |= characterSizes[serialDataBits(config)] | unix.CREAD | unix.CLOCAL
+
+	// Четность проверяется на приеме, байты с ошибкой четности отбрасываются
+	switch serialParity(config) {
+	case parityEven:
+		termios.Cflag |= unix.PARENB
+		termios.Iflag |= unix.INPCK
+	case parityOdd:
+		termios.Cflag |= unix.PARENB | unix.PARODD
+		termios.Iflag |= unix.INPCK
+	}
+	if serialStopBits(config) == 2 {
+		termios.Cflag |= unix.CSTOPB
+	}
+
+	// XON/XOFF действует в обе стороны; IXANY не включается, чтобы байты ответа
+	// не возобновляли приостановленную передачу
+	switch serialFlowControl(config) {
+	case flowControlHardware:
+		termios.Cflag |= unix.CRTSCTS
+	case flowControlSoftware:
+		termios.Iflag |= unix.IXON | unix.IXOFF
+	}
+}
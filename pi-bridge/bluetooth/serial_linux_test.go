@@ -0,0 +1,167 @@
+package bluetooth
+
+import (
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY открывает псевдотерминал и возвращает его подчиненную сторону
+func openPTY(t *testing.T) *os.File {
+	t.Helper()
+
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("pseudo-terminals are not available: %v", err)
+	}
+	t.Cleanup(func() { master.Close() })
+
+	if err := unix.IoctlSetPointerInt(int(master.Fd()), unix.TIOCSPTLCK, 0); err != nil {
+		t.Skipf("failed to unlock pty: %v", err)
+	}
+	index, err := unix.IoctlGetInt(int(master.Fd()), unix.TIOCGPTN)
+	if err != nil {
+		t.Skipf("failed to get pty number: %v", err)
+	}
+
+	slave, err := os.OpenFile(fmt.Sprintf("/dev/pts/%d", index), os.O_RDWR|unix.O_NOCTTY, 0)
+	if err != nil {
+		t.Skipf("failed to open pty slave: %v", err)
+	}
+	t.Cleanup(func() { slave.Close() })
+	return slave
+}
+
+func TestConfigureSerialRawMode(t *testing.T) {
+	slave := openPTY(t)
+
+	if err := configureSerial(slave, Config{ReadTimeout: 3 * time.Second, BaudRate: 38400}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	termios, err := unix.IoctlGetTermios(int(slave.Fd()), unix.TCGETS)
+	if err != nil {
+		t.Fatalf("Failed to read termios: %v", err)
+	}
+	if termios.Lflag&(unix.ECHO|unix.ICANON) != 0 {
+		t.Errorf("Expected echo and canonical mode to be disabled, lflag=%#x", termios.Lflag)
+	}
+	if termios.Iflag&(unix.ICRNL|unix.IXON|unix.IXOFF) != 0 {
+		t.Errorf("Expected CR to NL translation and XON/XOFF to be disabled, iflag=%#x", termios.Iflag)
+	}
+	if termios.Cc[unix.VMIN] != 0 || termios.Cc[unix.VTIME] != 30 {
+		t.Errorf("Expected VMIN=0 VTIME=30, got VMIN=%d VTIME=%d", termios.Cc[unix.VMIN], termios.Cc[unix.VTIME])
+	}
+	if termios.Cflag&unix.CSIZE != unix.CS8 || termios.Cflag&(unix.PARENB|unix.CSTOPB) != 0 {
+		t.Errorf("Expected 8N1 by default, cflag=%#x", termios.Cflag)
+	}
+}
+
+func TestSetFrameFormat(t *testing.T) {
+	// Псевдотерминал всегда работает в 8N1, поэтому формат кадра проверяется без устройства
+	tests := []struct {
+		name   string
+		config Config
+		cflag  uint32
+		inpck  bool
+	}{
+		{"default 8N1", Config{}, unix.CS8, false},
+		{"7E1", Config{DataBits: 7, Parity: "even"}, unix.CS7 | unix.PARENB, true},
+		{"7O2", Config{DataBits: 7, Parity: "Odd", StopBits: 2}, unix.CS7 | unix.PARENB | unix.PARODD | unix.CSTOPB, true},
+		{"8N2", Config{StopBits: 2}, unix.CS8 | unix.CSTOPB, false},
+	}
+
+	const mask = unix.CSIZE | unix.PARENB | unix.PARODD | unix.CSTOPB
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Исходные флаги противоположны ожидаемым: все должны быть перезаписаны
+			termios := &unix.Termios{Cflag: mask | unix.CRTSCTS, Iflag: unix.INPCK}
+			setFrameFormat(termios, tt.config)
+
+			if termios.Cflag&mask != tt.cflag {
+				t.Errorf("Expected cflag %#x, got %#x", tt.cflag, termios.Cflag&mask)
+			}
+			if termios.Cflag&unix.CRTSCTS != 0 {
+				t.Error("Expected hardware flow control to be disabled")
+			}
+			if (termios.Iflag&unix.INPCK != 0) != tt.inpck {
+				t.Errorf("Expected parity check %v, iflag=%#x", tt.inpck, termios.Iflag)
+			}
+		})
+	}
+}
+
+func TestSetFlowControl(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   Config
+		hardware bool
+		software bool
+	}{
+		{"default none", Config{}, false, false},
+		{"rtscts", Config{FlowControl: "rtscts"}, true, false},
+		{"xonxoff", Config{FlowControl: "XonXoff"}, false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Оставшееся от предыдущей программы управление потоком должно сбрасываться
+			termios := &unix.Termios{Cflag: unix.CRTSCTS, Iflag: unix.IXON | unix.IXOFF | unix.IXANY}
+			setFrameFormat(termios, tt.config)
+
+			if (termios.Cflag&unix.CRTSCTS != 0) != tt.hardware {
+				t.Errorf("Expected hardware flow control %v, cflag=%#x", tt.hardware, termios.Cflag)
+			}
+			if (termios.Iflag&(unix.IXON|unix.IXOFF) == unix.IXON|unix.IXOFF) != tt.software {
+				t.Errorf("Expected software flow control %v, iflag=%#x", tt.software, termios.Iflag)
+			}
+			if !tt.software && termios.Iflag&(unix.IXON|unix.IXOFF) != 0 {
+				t.Errorf("Expected XON/XOFF to be disabled, iflag=%#x", termios.Iflag)
+			}
+			if termios.Iflag&unix.IXANY != 0 {
+				t.Errorf("Expected IXANY to be disabled, iflag=%#x", termios.Iflag)
+			}
+		})
+	}
+}
+
+func TestConfigureSerialErrors(t *testing.T) {
+	// Обычный файл не является терминалом и пропускается без ошибки
+	file, err := os.CreateTemp(t.TempDir(), "not-a-tty")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	if err := configureSerial(file, Config{ReadTimeout: time.Second}); err != nil {
+		t.Errorf("Expected non-tty to be skipped, got %v", err)
+	}
+
+	if err := configureSerial(openPTY(t), Config{ReadTimeout: time.Second, BaudRate: 12345}); err == nil {
+		t.Error("Expected error for unsupported baud rate")
+	}
+	if err := configureSerial(openPTY(t), Config{ReadTimeout: time.Second, Parity: "mark"}); err == nil {
+		t.Error("Expected error for unsupported parity")
+	}
+	if err := configureSerial(openPTY(t), Config{ReadTimeout: time.Second, FlowControl: "dtrdsr"}); err == nil {
+		t.Error("Expected error for unsupported flow control")
+	}
+}
+
+func TestSerialPortTimeoutIsNotEOF(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer.Close()
+
+	port := &serialPort{File: reader}
+	defer port.Close()
+
+	n, err := port.Read(make([]byte, 8))
+	if n != 0 || err != nil {
+		t.Errorf("Expected empty read without error, got n=%d err=%v", n, err)
+	}
+}
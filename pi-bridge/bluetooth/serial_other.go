@@ -0,0 +1,11 @@
+//go:build !linux
+
+package bluetooth
+
+import "os"
+
+// configureSerial на платформах кроме Linux оставляет настройки линии без изменений
+func configureSerial(file *os.File, config Config) error {
+	logger.Println("Serial line configuration is only supported on Linux, using existing settings")
+	return nil
+}
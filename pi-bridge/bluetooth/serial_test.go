@@ -0,0 +1,31 @@
+package bluetooth
+
+import "testing"
+
+func TestValidateSerial(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"defaults", Config{}, false},
+		{"8N1", Config{BaudRate: 38400, DataBits: 8, Parity: "none", StopBits: 1}, false},
+		{"7E2", Config{BaudRate: 9600, DataBits: 7, Parity: "EVEN", StopBits: 2}, false},
+		{"negative baud rate", Config{BaudRate: -1}, true},
+		{"too many data bits", Config{DataBits: 9}, true},
+		{"too few data bits", Config{DataBits: 4}, true},
+		{"unknown parity", Config{Parity: "space"}, true},
+		{"invalid stop bits", Config{StopBits: 3}, true},
+		{"hardware flow control", Config{FlowControl: "RTSCTS"}, false},
+		{"software flow control", Config{FlowControl: "xonxoff"}, false},
+		{"unknown flow control", Config{FlowControl: "dtrdsr"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.ValidateSerial(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateSerial() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,110 @@
+package bluetooth
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// attCID - фиксированный канал L2CAP протокола атрибутов (ATT) для BLE
+const attCID = 4
+
+// dialRFCOMM подключается к адаптеру сокетом RFCOMM напрямую, без rfcomm bind
+func dialRFCOMM(address string, channel int, timeout time.Duration) (io.ReadWriteCloser, error) {
+	logger.Printf("Attempting to connect to %s (RFCOMM channel %d)", address, channel)
+
+	mac, err := parseMAC(address)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := unix.Socket(unix.AF_BLUETOOTH, unix.SOCK_STREAM|unix.SOCK_CLOEXEC, unix.BTPROTO_RFCOMM)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create RFCOMM socket: %v", err)
+	}
+
+	// В sockaddr_rc адрес хранится в обратном порядке байтов
+	sa := &unix.SockaddrRFCOMM{Channel: uint8(channel)}
+	for i := range mac {
+		sa.Addr[i] = mac[len(mac)-1-i]
+	}
+
+	return connectSocket(fd, sa, timeout, "rfcomm:"+address)
+}
+
+// dialATT открывает канал ATT до устройства BLE (L2CAP, CID 4)
+func dialATT(address string, timeout time.Duration) (io.ReadWriteCloser, error) {
+	mac, err := parseMAC(address)
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := unix.Socket(unix.AF_BLUETOOTH, unix.SOCK_SEQPACKET|unix.SOCK_CLOEXEC, unix.BTPROTO_L2CAP)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create L2CAP socket: %v", err)
+	}
+
+	// Локальный адрес тоже должен быть LE, иначе ядро подключается через BR/EDR
+	local := &unix.SockaddrL2{CID: attCID, AddrType: unix.BDADDR_LE_PUBLIC}
+	if err := unix.Bind(fd, local); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to bind L2CAP socket: %v", err)
+	}
+
+	remote := &unix.SockaddrL2{CID: attCID, Addr: mac, AddrType: unix.BDADDR_LE_PUBLIC}
+	return connectSocket(fd, remote, timeout, "att:"+address)
+}
+
+// connectSocket подключает сокет с таймаутом и возвращает его как файл. Сокет остается
+// неблокирующим: файл обслуживается поллером Go, и Close прерывает ожидающий Read
+func connectSocket(fd int, sa unix.Sockaddr, timeout time.Duration, name string) (io.ReadWriteCloser, error) {
+	if err := unix.SetNonblock(fd, true); err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to set non-blocking mode: %v", err)
+	}
+
+	err := unix.Connect(fd, sa)
+	if err == unix.EINPROGRESS {
+		err = waitConnected(fd, connectDeadline(timeout))
+	}
+	if err != nil {
+		unix.Close(fd)
+		return nil, fmt.Errorf("failed to connect to %s: %v", name, err)
+	}
+
+	return os.NewFile(uintptr(fd), name), nil
+}
+
+// waitConnected ждет завершения неблокирующего connect до deadline
+func waitConnected(fd int, deadline time.Time) error {
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("connection timed out")
+		}
+
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLOUT}}
+		n, err := unix.Poll(fds, int(remaining/time.Millisecond)+1)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			continue
+		}
+
+		code, err := unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_ERROR)
+		if err != nil {
+			return err
+		}
+		if code != 0 {
+			return unix.Errno(code)
+		}
+		return nil
+	}
+}
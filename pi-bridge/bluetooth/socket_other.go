@@ -0,0 +1,19 @@
+//go:build !linux
+
+package bluetooth
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// dialRFCOMM на платформах кроме Linux недоступен: используйте serial транспорт
+func dialRFCOMM(address string, channel int, timeout time.Duration) (io.ReadWriteCloser, error) {
+	return nil, fmt.Errorf("%s transport is only supported on Linux", TransportRFCOMM)
+}
+
+// dialATT на платформах кроме Linux недоступен
+func dialATT(address string, timeout time.Duration) (io.ReadWriteCloser, error) {
+	return nil, fmt.Errorf("%s transport is only supported on Linux", TransportBLE)
+}
@@ -0,0 +1,107 @@
+package bluetooth
+
+import (
+	"sync/atomic"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+// Stats - счетчики обмена с адаптером с момента запуска моста
+type Stats struct {
+	ReadErrors       uint64  `json:"read_errors"`        // Ошибок чтения (каждая закрывает соединение)
+	Reconnects       uint64  `json:"reconnects"`         // Повторных подключений после первого
+	BytesIn          uint64  `json:"bytes_in"`           // Получено байт от адаптера
+	BytesOut         uint64  `json:"bytes_out"`          // Отправлено байт адаптеру
+	Responses        uint64  `json:"responses"`          // Ответов, завершенных приглашением
+	AvgRoundTripMs   float64 `json:"avg_round_trip_ms"`  // Среднее время от команды до приглашения
+	CommandsTimedOut uint64  `json:"commands_timed_out"` // Команд без ответа за read_timeout
+	PartialResponses uint64  `json:"partial_responses"`  // Ответов, завершенных паузой без приглашения
+}
+
+// adapterStats накапливает счетчики; обновляется из циклов чтения и записи без блокировок
+type adapterStats struct {
+	readErrors       atomic.Uint64
+	bytesIn          atomic.Uint64
+	bytesOut         atomic.Uint64
+	responses        atomic.Uint64
+	roundTrip        atomic.Int64 // Суммарное время ответов (нс)
+	timeouts         atomic.Uint64
+	partialResponses atomic.Uint64
+}
+
+// observeRoundTrip учитывает время ответа на команду
+func (s *adapterStats) observeRoundTrip(d time.Duration) {
+	s.responses.Add(1)
+	s.roundTrip.Add(int64(d))
+}
+
+// statsTransport считает байты и ошибки чтения транспорта адаптера
+type statsTransport struct {
+	Transport
+	stats *adapterStats
+}
+
+// Read читает данные, учитывая полученные байты и ошибки
+func (t *statsTransport) Read(p []byte) (int, error) {
+	n, err := t.Transport.Read(p)
+	t.stats.bytesIn.Add(uint64(n))
+	if err != nil {
+		t.stats.readErrors.Add(1)
+	}
+	return n, err
+}
+
+// Write записывает данные, учитывая отправленные байты
+func (t *statsTransport) Write(p []byte) (int, error) {
+	n, err := t.Transport.Write(p)
+	t.stats.bytesOut.Add(uint64(n))
+	return n, err
+}
+
+// Stats возвращает снимок счетчиков обмена с адаптером
+func (a *Adapter) Stats() Stats {
+	stats := Stats{
+		ReadErrors:       a.stats.readErrors.Load(),
+		BytesIn:          a.stats.bytesIn.Load(),
+		BytesOut:         a.stats.bytesOut.Load(),
+		Responses:        a.stats.responses.Load(),
+		CommandsTimedOut: a.stats.timeouts.Load(),
+		PartialResponses: a.stats.partialResponses.Load(),
+	}
+	if connections := a.connections.Load(); connections > 1 {
+		stats.Reconnects = connections - 1
+	}
+	if stats.Responses > 0 {
+		average := time.Duration(a.stats.roundTrip.Load() / int64(stats.Responses))
+		stats.AvgRoundTripMs = float64(average) / float64(time.Millisecond)
+	}
+	return stats
+}
+
+// statsLoop периодически публикует счетчики в канал служебных событий
+func (a *Adapter) statsLoop() {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(a.config.StatsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-a.ctx.Done():
+			return
+		case <-ticker.C:
+			event := common.StatusEvent{
+				Kind:      "adapter_stats",
+				Data:      a.Stats(),
+				Retained:  true,
+				Timestamp: time.Now(),
+			}
+			select {
+			case a.statusChan <- event:
+			default:
+				logger.Println("Warning: status channel is full, dropping adapter stats")
+			}
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package bluetooth
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+	"elm327-bridge/simulator"
+)
+
+func TestAdapterStats(t *testing.T) {
+	// Первое соединение проглатывает 0105, второе открывается после обрыва первого
+	var opened atomic.Int32
+	first := &swallowingConn{ELM327: simulator.New(nil), swallow: "0105"}
+	second := simulator.New(nil)
+	defer second.Close()
+
+	statusChan := make(chan common.StatusEvent, 100)
+	commandsChan := make(chan string, 10)
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+	config.ReadTimeout = 100 * time.Millisecond
+	config.HeartbeatInterval = 0
+	config.StatsInterval = 20 * time.Millisecond
+	adapter := NewAdapter(config, make(chan string, 10), commandsChan)
+	adapter.SetStatusChannel(statusChan)
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) {
+		if opened.Add(1) == 1 {
+			return first, nil
+		}
+		return second, nil
+	}))
+
+	connected := make(chan struct{}, 2)
+	adapter.SetConnectHandler(func() { notify(connected) })
+	adapter.Start(context.Background())
+	defer adapter.Stop()
+
+	select {
+	case <-connected:
+	case <-time.After(3 * time.Second):
+		t.Fatal("Adapter initialization did not complete")
+	}
+
+	// Команда без ответа учитывается как таймаут
+	commandsChan <- "0105"
+	deadline := time.Now().Add(3 * time.Second)
+	for adapter.Stats().CommandsTimedOut == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected timed out command to be counted")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Обрыв соединения - ошибка чтения и повторное подключение
+	first.Close()
+	select {
+	case <-connected:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Adapter did not reconnect")
+	}
+
+	stats := adapter.Stats()
+	if stats.ReadErrors != 1 || stats.Reconnects != 1 {
+		t.Errorf("Expected one read error and one reconnect, got %+v", stats)
+	}
+	if stats.BytesIn == 0 || stats.BytesOut == 0 {
+		t.Errorf("Expected traffic to be counted, got %+v", stats)
+	}
+	if stats.Responses == 0 || stats.AvgRoundTripMs <= 0 {
+		t.Errorf("Expected round trip time of init commands, got %+v", stats)
+	}
+
+	// Статистика публикуется периодически
+	deadline = time.Now().Add(time.Second)
+	for {
+		select {
+		case event := <-statusChan:
+			if event.Kind != "adapter_stats" {
+				continue
+			}
+			if _, ok := event.Data.(Stats); !ok || !event.Retained {
+				t.Errorf("Unexpected stats event %+v", event)
+			}
+			return
+		case <-time.After(time.Until(deadline)):
+			t.Fatal("Expected adapter stats event")
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package bluetooth
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Режимы адаптивной настройки таймаута ELM327 (ATAT0-2)
+var adaptiveTimingModes = map[string]string{
+	"off":        "ATAT0", // Таймаут ответа фиксирован значением ATST
+	"normal":     "ATAT1", // Значение ELM327 по умолчанию
+	"aggressive": "ATAT2", // Сокращает ожидание быстрых ЭБУ
+}
+
+// Единица и пределы таймаута ответа ATST: 1-255 единиц по 4,096 мс
+const (
+	responseTimeoutUnit = 4096 * time.Microsecond
+	maxResponseTimeout  = 255 * responseTimeoutUnit
+)
+
+// Единица и пределы периода сообщений поддержания сессии K-line ATSW: 1-255 единиц по 20 мс
+const (
+	keepAliveUnit        = 20 * time.Millisecond
+	maxKeepAliveInterval = 255 * keepAliveUnit
+	maxKeepAliveBytes    = 6 // ATWM принимает от 1 до 6 байт
+)
+
+// ValidateTiming проверяет параметры таймингов ELM327
+func (c Config) ValidateTiming() error {
+	if c.AdaptiveTiming != "" {
+		if _, ok := adaptiveTimingModes[strings.ToLower(c.AdaptiveTiming)]; !ok {
+			return fmt.Errorf("invalid adaptive timing %q: expected off, normal or aggressive", c.AdaptiveTiming)
+		}
+	}
+	if c.ResponseTimeout < 0 || c.ResponseTimeout > maxResponseTimeout {
+		return fmt.Errorf("invalid response timeout %v: expected up to %v", c.ResponseTimeout, maxResponseTimeout)
+	}
+	if c.KeepAliveInterval != 0 && (c.KeepAliveInterval < keepAliveUnit || c.KeepAliveInterval > maxKeepAliveInterval) {
+		return fmt.Errorf("invalid keep-alive interval %v: expected %v to %v", c.KeepAliveInterval, keepAliveUnit, maxKeepAliveInterval)
+	}
+	if _, err := keepAliveMessage(c.KeepAliveMessage); err != nil {
+		return err
+	}
+	return nil
+}
+
+// timingCommands возвращает команды настройки таймингов, выполняемые после init_commands
+// (сброс ATZ возвращает значения по умолчанию). Медленным ЭБУ, на которые адаптер отвечает
+// "NO DATA", помогает увеличенный таймаут ответа без адаптивного сокращения
+func timingCommands(c Config) []string {
+	var commands []string
+	if command, ok := adaptiveTimingModes[strings.ToLower(c.AdaptiveTiming)]; ok {
+		commands = append(commands, command)
+	}
+	if c.ResponseTimeout > 0 {
+		units := (c.ResponseTimeout + responseTimeoutUnit - 1) / responseTimeoutUnit
+		commands = append(commands, fmt.Sprintf("ATST%02X", int(units)))
+	}
+	// Период округляется вниз: сообщение не должно запаздывать относительно таймаута ЭБУ
+	if c.KeepAliveInterval > 0 {
+		commands = append(commands, fmt.Sprintf("ATSW%02X", int(c.KeepAliveInterval/keepAliveUnit)))
+	}
+	if message, err := keepAliveMessage(c.KeepAliveMessage); err == nil && message != "" {
+		commands = append(commands, "ATWM"+message)
+	}
+	return commands
+}
+
+// keepAliveMessage проверяет сообщение поддержания сессии (заголовок и данные, например
+// "68 6A F1 3E") и возвращает его байты без пробелов. Пустая строка - не менять
+func keepAliveMessage(message string) (string, error) {
+	compact := strings.ReplaceAll(strings.TrimSpace(message), " ", "")
+	if compact == "" {
+		return "", nil
+	}
+	data, err := hex.DecodeString(compact)
+	if err != nil || len(data) > maxKeepAliveBytes {
+		return "", fmt.Errorf("invalid keep-alive message %q: expected 1 to %d hex bytes", message, maxKeepAliveBytes)
+	}
+	return strings.ToUpper(hex.EncodeToString(data)), nil
+}
@@ -0,0 +1,85 @@
+package bluetooth
+
+import (
+	"io"
+	"reflect"
+	"testing"
+	"time"
+
+	"elm327-bridge/simulator"
+)
+
+func TestTimingCommands(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		want    []string
+		wantErr bool
+	}{
+		{"not configured", Config{}, nil, false},
+		{"adaptive off", Config{AdaptiveTiming: "off"}, []string{"ATAT0"}, false},
+		{"adaptive aggressive", Config{AdaptiveTiming: "Aggressive"}, []string{"ATAT2"}, false},
+		{"slow ECU", Config{AdaptiveTiming: "off", ResponseTimeout: 500 * time.Millisecond}, []string{"ATAT0", "ATST7B"}, false},
+		{"default timeout", Config{ResponseTimeout: 200 * time.Millisecond}, []string{"ATST31"}, false},
+		{"maximum timeout", Config{ResponseTimeout: maxResponseTimeout}, []string{"ATSTFF"}, false},
+		{"minimum timeout", Config{ResponseTimeout: time.Millisecond}, []string{"ATST01"}, false},
+		{"unknown mode", Config{AdaptiveTiming: "fast"}, nil, true},
+		{"timeout too long", Config{ResponseTimeout: 2 * time.Second}, nil, true},
+		{"negative timeout", Config{ResponseTimeout: -time.Millisecond}, nil, true},
+		{"keep-alive interval", Config{KeepAliveInterval: 2 * time.Second}, []string{"ATSW64"}, false},
+		{"keep-alive interval rounded down", Config{KeepAliveInterval: 4999 * time.Millisecond}, []string{"ATSWF9"}, false},
+		{"maximum keep-alive interval", Config{KeepAliveInterval: maxKeepAliveInterval}, []string{"ATSWFF"}, false},
+		{"keep-alive message", Config{KeepAliveMessage: "68 6a f1 3e"}, []string{"ATWM686AF13E"}, false},
+		{"K-line keep-alive", Config{ResponseTimeout: 200 * time.Millisecond, KeepAliveInterval: time.Second, KeepAliveMessage: "C133F13E"}, []string{"ATST31", "ATSW32", "ATWMC133F13E"}, false},
+		{"keep-alive interval too short", Config{KeepAliveInterval: 10 * time.Millisecond}, nil, true},
+		{"keep-alive interval too long", Config{KeepAliveInterval: 6 * time.Second}, nil, true},
+		{"keep-alive message not hex", Config{KeepAliveMessage: "68 6A XX"}, nil, true},
+		{"keep-alive message odd digits", Config{KeepAliveMessage: "686"}, nil, true},
+		{"keep-alive message too long", Config{KeepAliveMessage: "68 6A F1 01 00 00 00"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.ValidateTiming()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ValidateTiming() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := timingCommands(tt.config); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("timingCommands() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitAppliesTiming(t *testing.T) {
+	conn := &swallowingConn{ELM327: simulator.New(nil)}
+
+	config := DefaultConfig()
+	config.ReconnectInterval = 10 * time.Millisecond
+	config.AdaptiveTiming = "off"
+	config.ResponseTimeout = 500 * time.Millisecond
+	adapter := NewAdapter(config, make(chan string, 10), make(chan string, 10))
+	adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) { return conn, nil }))
+
+	adapter.wg.Add(1)
+	go adapter.readLoop()
+	defer func() {
+		conn.Close()
+		adapter.Stop()
+	}()
+
+	if err := adapter.connect(); err != nil {
+		t.Fatalf("connect failed: %v", err)
+	}
+
+	// Тайминги задаются после сброса и остальных команд инициализации
+	conn.mu.Lock()
+	written := append([]string(nil), conn.written[len(conn.written)-2:]...)
+	conn.mu.Unlock()
+	if !reflect.DeepEqual(written, []string{"ATAT0", "ATST7B"}) {
+		t.Errorf("Expected timing commands at the end of init, got %v", written)
+	}
+}
@@ -0,0 +1,370 @@
+package bluetooth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Типы транспорта до адаптера ELM327
+const (
+	TransportSerial = "serial" // Устройство /dev/rfcomm0 (rfcomm bind), /dev/ttyUSB0 или UART
+	TransportRFCOMM = "rfcomm" // Сокет RFCOMM по MAC адресу, без rfcomm bind
+	TransportTCP    = "tcp"    // Wi-Fi адаптеры, например 192.168.0.10:35000
+	TransportBLE    = "ble"    // Адаптеры Bluetooth Low Energy (GATT)
+	TransportReplay = "replay" // Воспроизведение записанного сеанса (record_path) без адаптера
+)
+
+// errNotConnected возвращается при обмене через неподключенный транспорт
+var errNotConnected = errors.New("transport is not connected")
+
+// Transport - канал связи с адаптером ELM327. Адаптер не зависит от способа подключения:
+// инициализация, очередь команд и сборка ответов одинаковы для всех транспортов.
+// Read и Write вызываются из разных горутин, Close может прервать блокирующий Read
+type Transport interface {
+	Connect(ctx context.Context) error // Открывает соединение, закрывая предыдущее; отмена ctx прерывает подключение
+	Read(p []byte) (int, error)        // Читает данные; 0 байт без ошибки - таймаут линии
+	Write(p []byte) (int, error)       // Записывает данные
+	Close() error                      // Закрывает соединение; повторный вызов допустим
+	Connected() bool                   // Соединение открыто
+}
+
+// readDeadliner - соединение, поддерживающее дедлайн чтения (сокеты, net.Conn)
+type readDeadliner interface {
+	SetReadDeadline(t time.Time) error
+}
+
+// writeDeadliner - соединение, поддерживающее дедлайн записи
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// streamTransport реализует Transport поверх потока, открываемого функцией open.
+// Все транспорты отличаются только способом открытия соединения
+type streamTransport struct {
+	open         func() (io.ReadWriteCloser, error)
+	readTimeout  time.Duration // Дедлайн одного чтения (0 - без дедлайна)
+	writeTimeout time.Duration // Дедлайн одной записи (0 - без дедлайна)
+	mu           sync.RWMutex
+	conn         io.ReadWriteCloser
+}
+
+// NewStreamTransport создает транспорт поверх произвольного потока, например симулятора
+// ELM327 в тестах. open вызывается при каждом подключении
+func NewStreamTransport(open func() (io.ReadWriteCloser, error)) Transport {
+	return &streamTransport{open: open}
+}
+
+// newStreamTransport создает транспорт, ограничивающий каждое чтение и запись таймаутом:
+// замолчавший или зависший адаптер, не закрывший соединение, не блокирует циклы навсегда
+func newStreamTransport(open func() (io.ReadWriteCloser, error), readTimeout, writeTimeout time.Duration) Transport {
+	return &streamTransport{open: open, readTimeout: readTimeout, writeTimeout: writeTimeout}
+}
+
+// Connect открывает соединение. Открытие не принимает ctx (сокет RFCOMM к выключенному
+// адаптеру подключается до connect_timeout), поэтому при отмене ctx Connect возвращается
+// сразу, а соединение, открытое позже, закрывается
+func (t *streamTransport) Connect(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	type result struct {
+		conn io.ReadWriteCloser
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		conn, err := t.open()
+		done <- result{conn, err}
+	}()
+
+	var r result
+	select {
+	case r = <-done:
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return ctx.Err()
+	}
+	if r.err != nil {
+		return r.err
+	}
+	conn := r.conn
+
+	t.mu.Lock()
+	previous := t.conn
+	t.conn = conn
+	t.mu.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+	return nil
+}
+
+// Read читает из текущего соединения. Истечение дедлайна - пустое чтение без ошибки,
+// как таймаут VTIME последовательного порта
+func (t *streamTransport) Read(p []byte) (int, error) {
+	conn := t.current()
+	if conn == nil {
+		return 0, errNotConnected
+	}
+
+	if d, ok := conn.(readDeadliner); ok && t.readTimeout > 0 {
+		d.SetReadDeadline(time.Now().Add(t.readTimeout))
+	}
+
+	n, err := conn.Read(p)
+	if errors.Is(err, os.ErrDeadlineExceeded) {
+		return n, nil
+	}
+	return n, err
+}
+
+// Write пишет в текущее соединение. Запись, не завершившаяся за writeTimeout, возвращает
+// ошибку: адаптер закрывает такое соединение и переподключается
+func (t *streamTransport) Write(p []byte) (int, error) {
+	conn := t.current()
+	if conn == nil {
+		return 0, errNotConnected
+	}
+	if t.writeTimeout <= 0 {
+		return conn.Write(p)
+	}
+
+	if d, ok := conn.(writeDeadliner); ok && d.SetWriteDeadline(time.Now().Add(t.writeTimeout)) == nil {
+		n, err := conn.Write(p)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return n, fmt.Errorf("write timed out after %v", t.writeTimeout)
+		}
+		return n, err
+	}
+
+	// Соединение без дедлайнов: зависшая запись завершится при закрытии соединения
+	type result struct {
+		n   int
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		n, err := conn.Write(p)
+		done <- result{n, err}
+	}()
+
+	timer := time.NewTimer(t.writeTimeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.n, r.err
+	case <-timer.C:
+		return 0, fmt.Errorf("write timed out after %v", t.writeTimeout)
+	}
+}
+
+// Close закрывает текущее соединение
+func (t *streamTransport) Close() error {
+	t.mu.Lock()
+	conn := t.conn
+	t.conn = nil
+	t.mu.Unlock()
+
+	if conn == nil {
+		return nil
+	}
+	return conn.Close()
+}
+
+// Connected сообщает, открыто ли соединение
+func (t *streamTransport) Connected() bool {
+	return t.current() != nil
+}
+
+// current возвращает текущее соединение
+func (t *streamTransport) current() io.ReadWriteCloser {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.conn
+}
+
+// NewTransport создает транспорт по конфигурации. Ошибки параметров проверяются
+// ValidateTransport при запуске, а ошибки подключения возвращает Connect.
+// При заданном record_path обмен каждого соединения записывается в журнал
+func NewTransport(config Config) Transport {
+	var open func() (io.ReadWriteCloser, error)
+
+	switch transportType(config) {
+	case TransportRFCOMM:
+		dial := func(address string) (io.ReadWriteCloser, error) {
+			return dialRFCOMM(address, rfcommChannel(config), config.ConnectTimeout)
+		}
+		if config.Pairing.Enabled {
+			dial = newPairer(config.Pairing).wrap(dial)
+		}
+		open = func() (io.ReadWriteCloser, error) {
+			return dial(config.Address)
+		}
+		if config.Discovery.Enabled {
+			open = newDiscoverer(config, dial).open
+		}
+	case TransportTCP:
+		open = func() (io.ReadWriteCloser, error) {
+			logger.Printf("Attempting to connect to %s", config.Address)
+			return net.DialTimeout("tcp", config.Address, config.ConnectTimeout)
+		}
+	case TransportBLE:
+		open = func() (io.ReadWriteCloser, error) {
+			return dialBLE(config)
+		}
+	case TransportReplay:
+		open = func() (io.ReadWriteCloser, error) {
+			return dialReplay(config.ReplayPath, config.ReplaySpeed)
+		}
+	default:
+		device := func(string) (io.ReadWriteCloser, error) {
+			return openSerialDevice(config)
+		}
+		// Устройство rfcomm bind подключается к адресу из конфигурации при открытии
+		if config.Pairing.Enabled {
+			device = newPairer(config.Pairing).wrap(device)
+		}
+		open = func() (io.ReadWriteCloser, error) {
+			return device(config.Address)
+		}
+	}
+
+	if config.RecordPath != "" {
+		open = recordSession(config.RecordPath, config.sessionRotation(), open)
+	}
+	return newStreamTransport(open, config.ReadTimeout, config.WriteTimeout)
+}
+
+// ValidateTransport проверяет параметры выбранного транспорта
+func (c Config) ValidateTransport() error {
+	if c.Discovery.Enabled && transportType(c) != TransportRFCOMM {
+		return fmt.Errorf("bluetooth.discovery requires %s transport", TransportRFCOMM)
+	}
+	if err := c.Discovery.Validate(); err != nil {
+		return err
+	}
+	if err := c.Pairing.Validate(); err != nil {
+		return err
+	}
+	if err := c.validateRFCOMMBind(); err != nil {
+		return err
+	}
+	if c.RecordMaxSize < 0 || c.RecordMaxFiles < 0 {
+		return fmt.Errorf("invalid session record rotation: record_max_size and record_max_files must not be negative")
+	}
+	if c.HotPlug && transportType(c) != TransportSerial {
+		return fmt.Errorf("bluetooth.hotplug requires %s transport", TransportSerial)
+	}
+	if c.Pairing.Enabled {
+		switch transportType(c) {
+		case TransportRFCOMM:
+		case TransportSerial:
+			if _, err := parseMAC(c.Address); err != nil {
+				return fmt.Errorf("bluetooth.pairing with %s transport requires adapter address: %v", TransportSerial, err)
+			}
+		default:
+			return fmt.Errorf("bluetooth.pairing requires %s or %s transport", TransportRFCOMM, TransportSerial)
+		}
+	}
+
+	switch transportType(c) {
+	case TransportSerial:
+		if c.DevicePath == "" {
+			return fmt.Errorf("bluetooth.device_path must be set for %s transport", TransportSerial)
+		}
+		return c.ValidateSerial()
+	case TransportRFCOMM:
+		// При поиске адрес необязателен: он используется, если адаптер не найден
+		if c.Address != "" || !c.Discovery.Enabled {
+			if _, err := parseMAC(c.Address); err != nil {
+				return err
+			}
+		}
+		if c.Channel < 0 || c.Channel > 30 {
+			return fmt.Errorf("invalid RFCOMM channel %d: expected 1-30", c.Channel)
+		}
+	case TransportTCP:
+		if _, _, err := net.SplitHostPort(c.Address); err != nil {
+			return fmt.Errorf("invalid TCP address %q: %v", c.Address, err)
+		}
+	case TransportBLE:
+		if _, err := parseMAC(c.Address); err != nil {
+			return err
+		}
+		for _, uuid := range []string{bleNotifyUUID(c), bleWriteUUID(c)} {
+			if _, err := parseUUID(uuid); err != nil {
+				return err
+			}
+		}
+	case TransportReplay:
+		if c.ReplayPath == "" {
+			return fmt.Errorf("bluetooth.replay_path must be set for %s transport", TransportReplay)
+		}
+		if c.ReplayPath == c.RecordPath {
+			return fmt.Errorf("bluetooth.record_path must differ from replay_path")
+		}
+		if c.ReplaySpeed < 0 {
+			return fmt.Errorf("invalid replay speed %v", c.ReplaySpeed)
+		}
+		if _, err := loadSession(c.ReplayPath); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown transport %q: expected %s, %s, %s, %s or %s", c.Transport, TransportSerial, TransportRFCOMM, TransportTCP, TransportBLE, TransportReplay)
+	}
+	return nil
+}
+
+// transportType возвращает тип транспорта в нижнем регистре (пусто - serial)
+func transportType(c Config) string {
+	transport := strings.ToLower(strings.TrimSpace(c.Transport))
+	if transport == "" {
+		return TransportSerial
+	}
+	return transport
+}
+
+// TransportType возвращает способ подключения к адаптеру (serial, если не задан)
+func (c Config) TransportType() string {
+	return transportType(c)
+}
+
+// rfcommChannel возвращает канал RFCOMM (ELM327 использует канал 1)
+func rfcommChannel(c Config) int {
+	if c.Channel == 0 {
+		return 1
+	}
+	return c.Channel
+}
+
+// parseMAC разбирает MAC адрес вида "00:1D:A5:68:98:8B"
+func parseMAC(address string) ([6]byte, error) {
+	var mac [6]byte
+	hw, err := net.ParseMAC(address)
+	if err != nil || len(hw) != len(mac) {
+		return mac, fmt.Errorf("invalid Bluetooth address %q: expected XX:XX:XX:XX:XX:XX", address)
+	}
+	copy(mac[:], hw)
+	return mac, nil
+}
+
+// connectDeadline возвращает момент, до которого должно установиться соединение
+func connectDeadline(timeout time.Duration) time.Time {
+	if timeout <= 0 {
+		timeout = DefaultConfig().ConnectTimeout
+	}
+	return time.Now().Add(timeout)
+}
@@ -0,0 +1,257 @@
+package bluetooth
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStreamTransport(t *testing.T) {
+	var opened []*MockReadWriteCloser
+	transport := NewStreamTransport(func() (io.ReadWriteCloser, error) {
+		conn := &MockReadWriteCloser{readData: []byte("OK\r>")}
+		opened = append(opened, conn)
+		return conn, nil
+	})
+
+	if transport.Connected() {
+		t.Fatal("Expected transport to be disconnected before Connect")
+	}
+	if _, err := transport.Write([]byte("ATZ\r")); !errors.Is(err, errNotConnected) {
+		t.Errorf("Expected errNotConnected, got %v", err)
+	}
+
+	if err := transport.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	if _, err := transport.Write([]byte("ATZ\r")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if string(opened[0].writeData) != "ATZ\r" {
+		t.Errorf("Expected command to be written, got %q", opened[0].writeData)
+	}
+
+	// Повторное подключение закрывает предыдущее соединение
+	if err := transport.Connect(context.Background()); err != nil {
+		t.Fatalf("Reconnect failed: %v", err)
+	}
+	if !opened[0].closed {
+		t.Error("Expected previous connection to be closed on reconnect")
+	}
+
+	buf := make([]byte, 16)
+	if n, err := transport.Read(buf); err != nil || string(buf[:n]) != "OK\r>" {
+		t.Errorf("Read = %q, %v", buf[:n], err)
+	}
+
+	if err := transport.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if !opened[1].closed || transport.Connected() {
+		t.Error("Expected connection to be closed")
+	}
+	if err := transport.Close(); err != nil {
+		t.Errorf("Expected repeated Close to succeed, got %v", err)
+	}
+}
+
+func TestStreamTransportConnectError(t *testing.T) {
+	transport := NewStreamTransport(func() (io.ReadWriteCloser, error) {
+		return nil, errors.New("device not found")
+	})
+
+	if err := transport.Connect(context.Background()); err == nil {
+		t.Fatal("Expected Connect to fail")
+	}
+	if transport.Connected() {
+		t.Error("Expected transport to stay disconnected")
+	}
+}
+
+// closeNotifier сообщает о закрытии соединения
+type closeNotifier struct {
+	io.ReadWriter
+	closed chan struct{}
+}
+
+func (c *closeNotifier) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestStreamTransportConnectCanceled(t *testing.T) {
+	release := make(chan struct{})
+	conn := &closeNotifier{ReadWriter: &MockReadWriteCloser{}, closed: make(chan struct{})}
+	transport := NewStreamTransport(func() (io.ReadWriteCloser, error) {
+		<-release
+		return conn, nil
+	})
+
+	// Отмена прерывает ожидание зависшего открытия
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := transport.Connect(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Connect returned after %v", elapsed)
+	}
+	if transport.Connected() {
+		t.Error("Expected transport to stay disconnected")
+	}
+
+	// Соединение, открытое после отмены, закрывается
+	close(release)
+	select {
+	case <-conn.closed:
+	case <-time.After(time.Second):
+		t.Error("Expected late connection to be closed")
+	}
+	if transport.Connected() {
+		t.Error("Expected late connection not to be used")
+	}
+}
+
+func TestValidateTransport(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"serial by default", Config{DevicePath: "/dev/rfcomm0"}, false},
+		{"serial without device", Config{Transport: "serial"}, true},
+		{"serial invalid line", Config{DevicePath: "/dev/ttyUSB0", DataBits: 9}, true},
+		{"rfcomm", Config{Transport: "RFCOMM", Address: "00:1D:A5:68:98:8B"}, false},
+		{"rfcomm with channel", Config{Transport: "rfcomm", Address: "00:1D:A5:68:98:8B", Channel: 2}, false},
+		{"rfcomm invalid address", Config{Transport: "rfcomm", Address: "00:1D:A5"}, true},
+		{"rfcomm invalid channel", Config{Transport: "rfcomm", Address: "00:1D:A5:68:98:8B", Channel: 31}, true},
+		{"tcp", Config{Transport: "tcp", Address: "192.168.0.10:35000"}, false},
+		{"tcp without port", Config{Transport: "tcp", Address: "192.168.0.10"}, true},
+		{"ble defaults", Config{Transport: "ble", Address: "00:1D:A5:68:98:8B"}, false},
+		{"ble full uuid", Config{Transport: "ble", Address: "00:1D:A5:68:98:8B", BLENotifyUUID: "0000fff1-0000-1000-8000-00805f9b34fb"}, false},
+		{"ble invalid uuid", Config{Transport: "ble", Address: "00:1D:A5:68:98:8B", BLEWriteUUID: "FFF"}, true},
+		{"serial hotplug", Config{DevicePath: "/dev/rfcomm0", HotPlug: true}, false},
+		{"tcp hotplug", Config{Transport: "tcp", Address: "192.168.0.10:35000", HotPlug: true}, true},
+		{"serial rfcomm bind", Config{DevicePath: "/dev/rfcomm0", Address: "00:1D:A5:68:98:8B", RFCOMMBind: true}, false},
+		{"rfcomm bind without address", Config{DevicePath: "/dev/rfcomm0", RFCOMMBind: true}, true},
+		{"rfcomm bind to tty", Config{DevicePath: "/dev/ttyUSB0", Address: "00:1D:A5:68:98:8B", RFCOMMBind: true}, true},
+		{"record rotation", Config{DevicePath: "/dev/rfcomm0", RecordPath: "/data/session.jsonl", RecordMaxSize: 50, RecordMaxFiles: 5}, false},
+		{"negative record size", Config{DevicePath: "/dev/rfcomm0", RecordMaxSize: -1}, true},
+		{"negative record files", Config{DevicePath: "/dev/rfcomm0", RecordMaxFiles: -1}, true},
+		{"rfcomm bind invalid channel", Config{DevicePath: "/dev/rfcomm0", Address: "00:1D:A5:68:98:8B", Channel: 31, RFCOMMBind: true}, true},
+		{"rfcomm bind with rfcomm transport", Config{Transport: "rfcomm", Address: "00:1D:A5:68:98:8B", RFCOMMBind: true}, true},
+		{"unknown", Config{Transport: "usb", DevicePath: "/dev/ttyUSB0"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.ValidateTransport(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateTransport() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseMAC(t *testing.T) {
+	mac, err := parseMAC("00:1d:A5:68:98:8B")
+	if err != nil {
+		t.Fatalf("parseMAC failed: %v", err)
+	}
+	if mac != [6]byte{0x00, 0x1D, 0xA5, 0x68, 0x98, 0x8B} {
+		t.Errorf("Unexpected MAC % X", mac)
+	}
+
+	// EUI-64 и пустой адрес не подходят для Bluetooth
+	for _, address := range []string{"", "00:1D:A5:68:98:8B:00:01", "OBDII"} {
+		if _, err := parseMAC(address); err == nil {
+			t.Errorf("Expected error for %q", address)
+		}
+	}
+}
+
+func TestStreamTransportReadTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	transport := newStreamTransport(func() (io.ReadWriteCloser, error) { return client, nil }, 50*time.Millisecond, 0)
+	if err := transport.Connect(context.Background()); err != nil {
+		t.Fatalf("Connect failed: %v", err)
+	}
+	defer transport.Close()
+
+	// Замолчавший адаптер не блокирует чтение: дедлайн дает пустое чтение без ошибки
+	buf := make([]byte, 16)
+	start := time.Now()
+	n, err := transport.Read(buf)
+	if n != 0 || err != nil {
+		t.Fatalf("Expected empty read on timeout, got %d, %v", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Expected read to return after timeout, returned after %v", elapsed)
+	}
+
+	go server.Write([]byte("OK\r>"))
+	if n, err := transport.Read(buf); err != nil || string(buf[:n]) != "OK\r>" {
+		t.Errorf("Read = %q, %v", buf[:n], err)
+	}
+
+	server.Close()
+	if _, err := transport.Read(buf); err == nil {
+		t.Error("Expected error after the other side closed")
+	}
+}
+
+// hangingConn - соединение без дедлайнов, запись в которое зависает до закрытия
+type hangingConn struct {
+	closed chan struct{}
+}
+
+func (c *hangingConn) Read(p []byte) (int, error) {
+	<-c.closed
+	return 0, io.EOF
+}
+
+func (c *hangingConn) Write(p []byte) (int, error) {
+	<-c.closed
+	return 0, io.ErrClosedPipe
+}
+
+func (c *hangingConn) Close() error {
+	close(c.closed)
+	return nil
+}
+
+func TestStreamTransportWriteTimeout(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+
+	tests := []struct {
+		name string
+		conn io.ReadWriteCloser
+	}{
+		{"deadline", client}, // Никто не читает: запись блокируется
+		{"without deadline", &hangingConn{closed: make(chan struct{})}}, // Запись завершается горутиной
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transport := newStreamTransport(func() (io.ReadWriteCloser, error) { return tt.conn, nil }, 0, 50*time.Millisecond)
+			if err := transport.Connect(context.Background()); err != nil {
+				t.Fatalf("Connect failed: %v", err)
+			}
+			defer transport.Close()
+
+			start := time.Now()
+			if _, err := transport.Write([]byte("010C\r")); err == nil {
+				t.Fatal("Expected write timeout")
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("Expected write to time out after 50ms, returned after %v", elapsed)
+			}
+		})
+	}
+}
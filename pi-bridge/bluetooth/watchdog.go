@@ -0,0 +1,47 @@
+package bluetooth
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultHeartbeatCommand - дешевая команда проверки связи: ответ приходит от самого
+// адаптера, без обращения к шине автомобиля
+const defaultHeartbeatCommand = "ATI"
+
+// touch отмечает получение данных от адаптера
+func (a *Adapter) touch() {
+	a.lastActivity.Store(time.Now().UnixNano())
+}
+
+// idle возвращает время с последних полученных данных
+func (a *Adapter) idle() time.Duration {
+	return time.Since(time.Unix(0, a.lastActivity.Load()))
+}
+
+// checkHeartbeat проверяет простаивающую связь: полуживое rfcomm соединение остается
+// "подключенным", хотя данные по нему не проходят, и телеметрия молча прекращается.
+// Если приглашение на команду проверки не пришло за read_timeout, соединение закрывается
+// и восстанавливается циклом переподключения (вызывается из цикла записи).
+// Спящий после ATLP адаптер не проверяется: команда разбудила бы его
+func (a *Adapter) checkHeartbeat() {
+	if !a.initialized.Load() || a.monitoring.Load() || a.sleeping.Load() || a.pending.len() > 0 {
+		return
+	}
+	if a.idle() < a.config.HeartbeatInterval {
+		return
+	}
+
+	command := a.config.HeartbeatCommand
+	if command == "" {
+		command = defaultHeartbeatCommand
+	}
+
+	response, err := a.sendAndWait(a.transport, command, a.config.ReadTimeout)
+	if err != nil {
+		logger.Printf("Heartbeat failed after %v idle: %v, reconnecting", a.idle().Round(time.Second), err)
+		a.closeConnection(fmt.Sprintf("heartbeat failed: %v", err))
+		return
+	}
+	logger.Printf("Heartbeat response: %q", response)
+}
@@ -0,0 +1,78 @@
+package bluetooth
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"elm327-bridge/simulator"
+)
+
+func TestHeartbeatReconnectsHalfDeadLink(t *testing.T) {
+	tests := []struct {
+		name      string
+		swallow   string
+		reconnect bool
+	}{
+		{"healthy link", "", false},
+		{"half-dead link", "ATI", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var mu sync.Mutex
+			var conns []*swallowingConn
+			opened := make(chan *swallowingConn, 10)
+
+			config := DefaultConfig()
+			config.ReconnectInterval = 10 * time.Millisecond
+			config.ReadTimeout = 100 * time.Millisecond
+			config.HeartbeatInterval = 50 * time.Millisecond
+			adapter := NewAdapter(config, make(chan string, 10), make(chan string, 10))
+			adapter.SetTransport(NewStreamTransport(func() (io.ReadWriteCloser, error) {
+				conn := &swallowingConn{ELM327: simulator.New(nil), swallow: tt.swallow}
+				mu.Lock()
+				conns = append(conns, conn)
+				mu.Unlock()
+				opened <- conn
+				return conn, nil
+			}))
+			adapter.Start(context.Background())
+			defer func() {
+				mu.Lock()
+				for _, conn := range conns {
+					conn.Close()
+				}
+				mu.Unlock()
+				adapter.Stop()
+			}()
+
+			conn := <-opened
+
+			// Связь простаивает после инициализации: адаптер проверяет ее командой ATI
+			deadline := time.Now().Add(3 * time.Second)
+			for conn.count("ATI") == 0 {
+				if time.Now().After(deadline) {
+					t.Fatal("Heartbeat was not sent")
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+			if conn.count("ATZ") != 1 {
+				t.Errorf("Expected heartbeat after initialization, ATZ sent %d times", conn.count("ATZ"))
+			}
+
+			select {
+			case <-opened:
+				if !tt.reconnect {
+					t.Error("Expected healthy link to stay connected")
+				}
+			case <-time.After(500 * time.Millisecond):
+				if tt.reconnect {
+					t.Error("Expected reconnection after failed heartbeat")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"elm327-bridge/api"
+	"elm327-bridge/bluetooth"
+	"elm327-bridge/common"
+	"elm327-bridge/mqtt"
+	"elm327-bridge/obd"
+)
+
+// bridge - экземпляр моста одного автомобиля: адаптер, парсер OBD, менеджер команд
+// и клиент MQTT со своими каналами. Общими остаются секции obd и api
+type bridge struct {
+	name       string
+	cancel     context.CancelFunc // Останавливает фоновые задачи моста
+	btAdapter  *bluetooth.Adapter
+	mqttClient *mqtt.Client
+}
+
+// startBridge создает и запускает мост автомобиля, история команд регистрируется в REST API.
+// ctx ограничивает запуск: отмена прерывает подключение к брокеру
+func startBridge(ctx context.Context, vehicle VehicleConfig, apiServer *api.Server) (*bridge, error) {
+	if vehicle.VIN != "" {
+		logger.Printf("Starting bridge for vehicle %s (VIN %s)", vehicle.label(), vehicle.VIN)
+	}
+
+	// Создаем каналы для связи между модулями
+	responsesChan := make(chan string, 50)                     // Сырые ответы от ELM327
+	commandsChan := make(chan string, 20)                      // Команды опроса для отправки в ELM327
+	clientCommandsChan := make(chan string, 20)                // Команды клиентов MQTT, выполняются раньше опроса
+	telemetryChan := make(chan common.Telemetry, 100)          // Декодированные данные телеметрии
+	commandResponsesChan := make(chan obd.CommandResponse, 50) // Ответы на команды
+	statusChan := make(chan common.StatusEvent, 20)            // Служебные события моста
+
+	// Клиент MQTT каждого автомобиля подключается к брокеру со своим ID
+	mqttConfig := config.MQTT
+	mqttConfig.ClientID = vehicle.clientID(config.MQTT.ClientID)
+
+	// Реестр команд клиентов MQTT, ожидающих ответа адаптера
+	if mqttConfig.CommandTimeout <= 0 {
+		mqttConfig.CommandTimeout = mqtt.DefaultConfig().CommandTimeout
+	}
+	pendingRequests := common.NewPendingRequests(mqttConfig.CommandTimeout, commandResponsesChan)
+	runCtx, cancel := context.WithCancel(context.Background())
+	go pendingRequests.Run(runCtx)
+
+	// Служебные команды моста отправляются адаптеру этого автомобиля
+	bridgeCommands := obd.NewBridgeCommands()
+
+	// Общий трекер состояния шины для парсера и менеджера команд
+	busHealth := obd.NewBusHealth()
+	// Перебор закрепленного и запасных протоколов при потере связи, nil - автоматический поиск
+	protocols := obd.NewProtocolFallback(vehicle.adapter.Protocols())
+	topology := obd.NewTopology()
+
+	// Потоковый режим высокочастотного опроса одного PID
+	streamer := obd.NewStreamer(commandsChan)
+	bridgeCommands.Register(obd.StreamCommand, streamer.HandleCommand)
+
+	// Проверка перед поездкой по команде PREDRIVE_CHECK
+	preDrive := obd.NewPreDriveCheck(config.PreDrive, statusChan)
+	bridgeCommands.Register(obd.PreDriveCommand, preDrive.HandleCommand)
+
+	// Напряжение батареи (ATRV) публикуется телеметрией, в том числе при выключенном зажигании
+	batteryMonitor := obd.NewBatteryMonitor(telemetryChan)
+
+	// Снимок неисправности (стоп-кадр и текущие значения) при появлении нового DTC
+	faultSnapshotter := obd.NewFaultSnapshotter(commandsChan, statusChan)
+
+	// Результаты бортовых тестов (сервис 06) по мониторам, также по команде TEST_RESULTS
+	testResults := obd.NewTestResultsScanner(commandsChan, statusChan)
+	bridgeCommands.Register(obd.TestResultsCommand, testResults.HandleCommand)
+	bridgeCommands.Register(obd.O2MonitorCommand, testResults.HandleO2Command)
+	// Формат ответов адаптера (с заголовками или без) определяется по данным и публикуется
+	responseFormat := obd.NewResponseFormatDetector(statusChan)
+
+	// Версия прошивки адаптера и выбранный протокол запрашиваются после подключения
+	adapterInfo := obd.NewAdapterInfoCollector(commandsChan, statusChan)
+	// Сообщение о запуске (birth): версия, адаптер, протокол, поддерживаемые PID и хеш конфигурации
+	birth := obd.NewBirthReporter(vehicle.adapter.TransportType(), configHash, adapterInfo, commandsChan, statusChan)
+	// Сон адаптера (ATLP) при заглушенном автомобиле, nil - режим выключен
+	lowPower := obd.NewLowPowerMonitor(config.OBD.LowPower, statusChan)
+	// Стоянка и запуск двигателя по напряжению: после запуска адаптер инициализируется заново.
+	// Адаптер создается позже, поэтому обработчик обращается к нему через замыкание
+	var btAdapter *bluetooth.Adapter
+	ignition := obd.NewIgnitionMonitor(config.OBD.Ignition, statusChan, func() { btAdapter.Reinitialize() })
+	observers := []obd.ResponseObserver{preDrive, faultSnapshotter, testResults, responseFormat, adapterInfo, birth, lowPower, ignition}
+
+	// Вычисляемые метрики (расход топлива по MAF и т.п.)
+	if config.OBD.Derived.Enabled {
+		derived, err := obd.NewDerivedMetrics(config.OBD.Derived, telemetryChan)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("failed to create derived metrics: %v", err)
+		}
+		observers = append(observers, derived)
+	}
+
+	// Создаем MQTT клиента до адаптера: он получает сырые ответы для устаревших топиков
+	mqttClient := mqtt.NewClient(mqttConfig, telemetryChan, clientCommandsChan, commandResponsesChan, statusChan)
+	mqttClient.SetPendingRequests(pendingRequests)
+	mqttClient.SetBridgeCommands(bridgeCommands)
+	mqttClient.SetBirthHandler(birth.Event)
+	if vehicle.VIN != "" {
+		mqttClient.SetVIN(vehicle.VIN)
+	}
+
+	// Прослушивание шины CAN по команде SNIFF: кадры публикуются без декодирования
+	sniffer := obd.NewSniffer(config.OBD.Sniffer, mqttClient.PublishCANFrame)
+	bridgeCommands.Register(obd.SniffCommand, sniffer.HandleCommand)
+
+	// Создаем и запускаем Bluetooth адаптер
+	btAdapter = bluetooth.NewAdapter(vehicle.adapter, responsesChan, commandsChan)
+	btAdapter.SetATResponseHandler(func(command, response string) {
+		preDrive.ObserveAT(command, response)
+		batteryMonitor.ObserveAT(command, response)
+		adapterInfo.ObserveAT(command, response)
+		birth.ObserveAT(command, response)
+		sniffer.ObserveAT(command, response)
+		lowPower.ObserveAT(command, response)
+		ignition.ObserveAT(command, response)
+	})
+	btAdapter.SetPriorityCommands(clientCommandsChan)
+	btAdapter.SetMonitorHandler(sniffer.ObserveFrame)
+	btAdapter.SetConnectHandler(func() {
+		protocols.OnConnect()
+		adapterInfo.OnConnect()
+		birth.OnConnect()
+	})
+	btAdapter.SetTimeoutHandler(busHealth.TimeoutHandler(statusChan))
+	btAdapter.SetPendingRequests(pendingRequests)
+	btAdapter.SetStatusChannel(statusChan)
+	btAdapter.SetRawResponseHandler(mqttClient.PublishRawResponse)
+	mqttClient.SetLeadershipHandler(btAdapter.SetActive)
+
+	// При резервировании мост подключается к адаптеру только после избрания лидером
+	if mqttConfig.Election.Enabled {
+		btAdapter.SetActive(false)
+	}
+
+	if err := btAdapter.Start(ctx); err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to start Bluetooth adapter: %v", err)
+	}
+
+	// Создаем и запускаем парсер OBD
+	go obd.StartParser(runCtx, responsesChan, telemetryChan, commandResponsesChan, busHealth, topology, streamer, pendingRequests, statusChan, observers...)
+
+	// Запускаем MQTT клиента
+	if err := mqttClient.Start(ctx); err != nil {
+		cancel()
+		btAdapter.Stop()
+		return nil, fmt.Errorf("failed to start MQTT client: %v", err)
+	}
+
+	// История команд одного автомобиля - по прежнему пути, нескольких - по VIN
+	historyPath := "/api/commands/history"
+	if vehicle.VIN != "" {
+		historyPath = fmt.Sprintf("/api/vehicles/%s/commands/history", vehicle.VIN)
+	}
+	apiServer.Handle(historyPath, mqttClient.History())
+
+	// Запускаем менеджер команд для периодического опроса PID
+	go obd.StartCommandManager(runCtx, commandsChan, busHealth, streamer, sniffer, lowPower, ignition, protocols, config.OBD.DTCScanInterval)
+
+	return &bridge{
+		name:       vehicle.label(),
+		cancel:     cancel,
+		btAdapter:  btAdapter,
+		mqttClient: mqttClient,
+	}, nil
+}
+
+// Stop останавливает мост автомобиля
+func (b *bridge) Stop() {
+	if b.name != "" {
+		logger.Printf("Stopping bridge for vehicle %s", b.name)
+	}
+	b.cancel()
+	b.btAdapter.Stop()
+	b.mqttClient.Stop()
+}
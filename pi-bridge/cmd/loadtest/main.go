@@ -0,0 +1,140 @@
+// Команда loadtest прогоняет конвейер моста (транспорт → парсер → JSON) на симуляторе ELM327
+// и сообщает максимальную устойчивую частоту отсчетов на текущем оборудовании.
+//
+//	go run ./cmd/loadtest -duration 10s -min-rate 500
+//
+// С флагом -min-rate команда завершается с ошибкой, если частота ниже порога,
+// что позволяет использовать ее как проверку регрессии производительности.
+//
+// Режим -soak предназначен для многочасовых прогонов: команда периодически снимает
+// количество горутин, размер кучи и заполнение каналов и завершается с ошибкой,
+// если какой-либо показатель монотонно растет.
+//
+//	go run ./cmd/loadtest -soak -duration 6h -sample-interval 1m -latency 20ms
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"elm327-bridge/bluetooth"
+	"elm327-bridge/obd"
+	"elm327-bridge/simulator"
+)
+
+var logger = log.New(os.Stderr, "[LoadTest] ", log.LstdFlags)
+
+func main() {
+	duration := flag.Duration("duration", 10*time.Second, "длительность прогона конвейера")
+	latency := flag.Duration("latency", 0, "задержка ответа симулятора (время обмена с автомобилем)")
+	pids := flag.String("pids", "0C,0D,05,04,11,0F", "опрашиваемые PID через запятую")
+	minRate := flag.Float64("min-rate", 0, "минимальная допустимая частота, отсчетов/с (0 - без проверки)")
+	soak := flag.Bool("soak", false, "длительный прогон с поиском утечек")
+	sampleInterval := flag.Duration("sample-interval", time.Minute, "период снятия показателей в режиме -soak")
+	warmup := flag.Duration("warmup", 5*time.Minute, "период прогрева, не учитываемый при поиске утечек")
+	verbose := flag.Bool("verbose", false, "выводить журналы модулей моста")
+	flag.Parse()
+
+	if !*verbose {
+		bluetooth.SetLogOutput(io.Discard)
+		obd.SetLogOutput(io.Discard)
+	}
+
+	var commands []string
+	for _, pid := range strings.Split(*pids, ",") {
+		commands = append(commands, "01"+strings.ToUpper(strings.TrimSpace(pid)))
+	}
+
+	if *soak {
+		os.Exit(soakMain(commands, *duration, *sampleInterval, *warmup, *latency))
+	}
+
+	// Предел вычислительной части: разбор и сериализация без транспорта
+	encode := testing.Benchmark(func(b *testing.B) {
+		benchmarkParseEncode(b, commands)
+	})
+
+	p, err := startPipeline(*latency)
+	if err != nil {
+		logger.Fatalf("Pipeline failed: %v", err)
+	}
+	res, err := p.run(commands, *duration, true)
+	p.stop()
+	if err != nil {
+		logger.Fatalf("Pipeline failed: %v", err)
+	}
+
+	fmt.Printf("Pipeline:        %d samples in %v, %d timeouts\n", res.samples, res.elapsed.Round(time.Millisecond), res.timeouts)
+	fmt.Printf("Round trip:      p50 %v, p99 %v\n", res.percentile(0.5), res.percentile(0.99))
+	fmt.Printf("Parse+encode:    %.0f samples/s ceiling (%d ns/op, %d allocs/op)\n",
+		1e9/float64(encode.NsPerOp()), encode.NsPerOp(), encode.AllocsPerOp())
+	fmt.Printf("Max sustainable: %.0f samples/s\n", res.rate())
+
+	if *minRate > 0 && res.rate() < *minRate {
+		fmt.Printf("FAIL: rate %.0f samples/s is below required %.0f samples/s\n", res.rate(), *minRate)
+		os.Exit(1)
+	}
+}
+
+// soakMain выполняет длительный прогон и возвращает код завершения
+func soakMain(commands []string, duration, interval, warmup, latency time.Duration) int {
+	fmt.Printf("Soak test for %v (sampling every %v, warm-up %v)\n", duration, interval, warmup)
+
+	samples, res, err := runSoak(commands, duration, interval, warmup, latency)
+	if err != nil {
+		logger.Printf("Soak test failed: %v", err)
+		return 1
+	}
+
+	fmt.Printf("Pipeline: %d samples in %v (%.0f samples/s), %d timeouts\n",
+		res.samples, res.elapsed.Round(time.Second), res.rate(), res.timeouts)
+
+	if len(samples) < soakMinSamples {
+		fmt.Printf("WARNING: only %d samples after warm-up, at least %d needed for leak detection\n", len(samples), soakMinSamples)
+		return 0
+	}
+
+	leaks := detectLeaks(samples)
+	if len(leaks) == 0 {
+		fmt.Printf("PASS: no monotonic growth across %d samples\n", len(samples))
+		return 0
+	}
+
+	fmt.Println("FAIL: monotonic growth detected")
+	for _, l := range leaks {
+		fmt.Printf("  LEAK SUSPECTED: %s grew from %.0f to %.0f\n", l.metric, l.first, l.last)
+	}
+	return 1
+}
+
+// benchmarkParseEncode измеряет разбор ответа и сериализацию сообщения телеметрии
+func benchmarkParseEncode(b *testing.B, commands []string) {
+	sim := simulator.DefaultResponses()
+	var responses []string
+	for _, command := range commands {
+		if response, ok := sim[command]; ok {
+			responses = append(responses, simulator.WithHeader(response))
+		}
+	}
+	if len(responses) == 0 {
+		b.Skip("no simulated responses for requested PIDs")
+	}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		telemetry, err := obd.ParseResponse(responses[i%len(responses)])
+		if err != nil {
+			b.Fatalf("parse failed: %v", err)
+		}
+		if _, err := json.Marshal(telemetryMessage(*telemetry)); err != nil {
+			b.Fatalf("encode failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"elm327-bridge/bluetooth"
+	"elm327-bridge/common"
+	"elm327-bridge/mqtt"
+	"elm327-bridge/obd"
+	"elm327-bridge/simulator"
+)
+
+// requestTimeout - время ожидания отсчета на один запрос
+const requestTimeout = time.Second
+
+// result содержит итоги прогона конвейера
+type result struct {
+	samples   int
+	timeouts  int
+	elapsed   time.Duration
+	latencies []time.Duration
+}
+
+// rate возвращает частоту отсчетов в секунду
+func (r result) rate() float64 {
+	if r.elapsed <= 0 {
+		return 0
+	}
+	return float64(r.samples) / r.elapsed.Seconds()
+}
+
+// percentile возвращает задержку запрос-отсчет для заданного перцентиля
+func (r result) percentile(p float64) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+	index := int(float64(len(r.latencies)-1) * p)
+	return r.latencies[index]
+}
+
+// pipeline объединяет симулятор, адаптер и парсер с теми же каналами, что и в мосте
+type pipeline struct {
+	sim     *simulator.ELM327
+	adapter *bluetooth.Adapter
+
+	responsesChan        chan string
+	commandsChan         chan string
+	telemetryChan        chan common.Telemetry
+	commandResponsesChan chan obd.CommandResponse
+	statusChan           chan common.StatusEvent
+	ctx                  context.Context // Отменяется при остановке конвейера
+	cancel               context.CancelFunc
+}
+
+// startPipeline запускает конвейер на симуляторе и ждет окончания инициализации адаптера
+func startPipeline(latency time.Duration) (*pipeline, error) {
+	p := &pipeline{
+		responsesChan:        make(chan string, 50),
+		commandsChan:         make(chan string, 20),
+		telemetryChan:        make(chan common.Telemetry, 100),
+		commandResponsesChan: make(chan obd.CommandResponse, 50),
+		statusChan:           make(chan common.StatusEvent, 20),
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+
+	p.sim = simulator.New(nil)
+	p.sim.Latency = latency
+
+	config := bluetooth.DefaultConfig()
+	config.ReconnectInterval = 100 * time.Millisecond
+	p.adapter = bluetooth.NewAdapter(config, p.responsesChan, p.commandsChan)
+	p.adapter.SetTransport(bluetooth.NewStreamTransport(func() (io.ReadWriteCloser, error) { return p.sim, nil }))
+	// Запуск и инициализация адаптера ограничены одним сроком
+	startCtx, cancelStart := context.WithTimeout(p.ctx, 10*time.Second)
+	defer cancelStart()
+	if err := p.adapter.Start(startCtx); err != nil {
+		p.cancel()
+		return nil, err
+	}
+
+	go obd.StartParser(p.ctx, p.responsesChan, p.telemetryChan, p.commandResponsesChan, obd.NewBusHealth(), obd.NewTopology(),
+		obd.NewStreamer(p.commandsChan), nil, p.statusChan)
+
+	// Служебные события и ответы на команды вычитываются, как это делает MQTT клиент
+	go p.drain()
+
+	for p.sim.Requests() < uint64(len(config.InitCommands)) {
+		select {
+		case <-startCtx.Done():
+			p.stop()
+			return nil, fmt.Errorf("adapter initialization did not complete: %w", startCtx.Err())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	return p, nil
+}
+
+// drain вычитывает каналы, которые в мосте обслуживает MQTT клиент
+func (p *pipeline) drain() {
+	for {
+		select {
+		case <-p.ctx.Done():
+			return
+		case <-p.statusChan:
+		case <-p.commandResponsesChan:
+		}
+	}
+}
+
+// stop останавливает конвейер
+func (p *pipeline) stop() {
+	p.cancel()
+	// Симулятор закрывается первым, чтобы освободить цикл чтения адаптера
+	p.sim.Close()
+	p.adapter.Stop()
+}
+
+// channelDepths возвращает текущее заполнение каналов конвейера
+func (p *pipeline) channelDepths() map[string]int {
+	return map[string]int{
+		"responses":         len(p.responsesChan),
+		"commands":          len(p.commandsChan),
+		"telemetry":         len(p.telemetryChan),
+		"command_responses": len(p.commandResponsesChan),
+		"status":            len(p.statusChan),
+	}
+}
+
+// run прогоняет запросы в замкнутом цикле: следующий запрос отправляется
+// после получения отсчета, как при опросе ELM327. Без recordLatencies задержки
+// не сохраняются, чтобы многочасовой прогон не рос в памяти сам по себе
+func (p *pipeline) run(commands []string, duration time.Duration, recordLatencies bool) (result, error) {
+	var res result
+	start := time.Now()
+	for i := 0; time.Since(start) < duration; i++ {
+		sent := time.Now()
+		p.commandsChan <- commands[i%len(commands)]
+
+		select {
+		case telemetry := <-p.telemetryChan:
+			if _, err := json.Marshal(telemetryMessage(telemetry)); err != nil {
+				return res, err
+			}
+			res.samples++
+			if recordLatencies {
+				res.latencies = append(res.latencies, time.Since(sent))
+			}
+		case <-time.After(requestTimeout):
+			res.timeouts++
+		}
+	}
+	res.elapsed = time.Since(start)
+
+	sort.Slice(res.latencies, func(i, j int) bool { return res.latencies[i] < res.latencies[j] })
+	return res, nil
+}
+
+// telemetryMessage формирует сообщение MQTT так же, как клиент перед публикацией
+func telemetryMessage(telemetry common.Telemetry) mqtt.TelemetryMessage {
+	return mqtt.TelemetryMessage{
+		VIN:       "LOADTEST",
+		PID:       telemetry.PID,
+		Metric:    telemetry.Metric,
+		Value:     telemetry.Value,
+		Unit:      telemetry.Unit,
+		Timestamp: time.Now(),
+		Raw:       telemetry.Raw,
+		ECU:       telemetry.ECU,
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Параметры определения утечек
+const (
+	soakMinSamples    = 8    // Минимум отсчетов после прогрева для вывода о росте
+	soakSegments      = 4    // Ряд делится на отрезки, средние которых должны расти
+	heapGrowthPercent = 0.10 // Рост кучи меньше 10% считается шумом сборщика мусора
+)
+
+// soakSample - снимок состояния процесса во время длительного прогона
+type soakSample struct {
+	at         time.Time
+	goroutines int
+	heapBytes  uint64
+	channels   map[string]int
+}
+
+// takeSoakSample снимает показатели процесса; сборка мусора перед снимком
+// оставляет в куче только живые объекты
+func takeSoakSample(p *pipeline) soakSample {
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	return soakSample{
+		at:         time.Now(),
+		goroutines: runtime.NumGoroutine(),
+		heapBytes:  stats.HeapAlloc,
+		channels:   p.channelDepths(),
+	}
+}
+
+// String форматирует снимок для журнала прогона
+func (s soakSample) String() string {
+	names := make([]string, 0, len(s.channels))
+	for name := range s.channels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	depths := make([]string, 0, len(names))
+	for _, name := range names {
+		depths = append(depths, fmt.Sprintf("%s=%d", name, s.channels[name]))
+	}
+	return fmt.Sprintf("goroutines=%d heap=%.1fMB %s", s.goroutines, float64(s.heapBytes)/(1<<20), strings.Join(depths, " "))
+}
+
+// leak описывает показатель с монотонным ростом
+type leak struct {
+	metric string
+	first  float64
+	last   float64
+}
+
+// monotonicGrowth проверяет, растет ли ряд монотонно: средние всех отрезков
+// строго возрастают, а общий прирост превышает допуск (доля от начального значения).
+// Сравнение средних отрезков устойчиво к шуму отдельных отсчетов
+func monotonicGrowth(values []float64, tolerance float64) bool {
+	if len(values) < soakMinSamples {
+		return false
+	}
+
+	size := len(values) / soakSegments
+	means := make([]float64, soakSegments)
+	for i := range means {
+		end := (i + 1) * size
+		if i == soakSegments-1 {
+			end = len(values)
+		}
+		var sum float64
+		for _, v := range values[i*size : end] {
+			sum += v
+		}
+		means[i] = sum / float64(end-i*size)
+	}
+
+	for i := 1; i < len(means); i++ {
+		if means[i] <= means[i-1] {
+			return false
+		}
+	}
+	return means[len(means)-1]-means[0] > means[0]*tolerance
+}
+
+// detectLeaks ищет монотонный рост горутин, кучи и заполнения каналов
+func detectLeaks(samples []soakSample) []leak {
+	series := map[string][]float64{}
+	tolerances := map[string]float64{"heap_bytes": heapGrowthPercent}
+
+	for _, s := range samples {
+		series["goroutines"] = append(series["goroutines"], float64(s.goroutines))
+		series["heap_bytes"] = append(series["heap_bytes"], float64(s.heapBytes))
+		for name, depth := range s.channels {
+			key := "channel_" + name
+			series[key] = append(series[key], float64(depth))
+		}
+	}
+
+	var leaks []leak
+	for metric, values := range series {
+		if monotonicGrowth(values, tolerances[metric]) {
+			leaks = append(leaks, leak{metric: metric, first: values[0], last: values[len(values)-1]})
+		}
+	}
+	sort.Slice(leaks, func(i, j int) bool { return leaks[i].metric < leaks[j].metric })
+	return leaks
+}
+
+// runSoak гоняет конвейер заданное время, периодически снимая показатели процесса.
+// Отсчеты периода прогрева в анализ не входят: пулы и буферы заполняются при старте
+func runSoak(commands []string, duration, interval, warmup, latency time.Duration) ([]soakSample, result, error) {
+	p, err := startPipeline(latency)
+	if err != nil {
+		return nil, result{}, err
+	}
+	defer p.stop()
+
+	var samples []soakSample
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	started := time.Now()
+
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				sample := takeSoakSample(p)
+				fmt.Printf("[%v] %s\n", sample.at.Sub(started).Round(time.Second), sample)
+				if sample.at.Sub(started) < warmup {
+					continue
+				}
+				samples = append(samples, sample)
+			}
+		}
+	}()
+
+	res, err := p.run(commands, duration, false)
+	close(done)
+	<-finished // После остановки сборщика отсчетов срез читается без гонки
+
+	return samples, res, err
+}
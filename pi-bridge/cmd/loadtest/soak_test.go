@@ -0,0 +1,67 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMonotonicGrowth(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    []float64
+		tolerance float64
+		expected  bool
+	}{
+		{"Steady growth", []float64{10, 11, 12, 13, 14, 15, 16, 17}, 0, true},
+		{"Noisy growth", []float64{100, 95, 110, 105, 120, 115, 130, 125, 140, 135, 150, 145}, 0.1, true},
+		{"Flat with noise", []float64{100, 104, 98, 101, 99, 103, 100, 102}, 0.1, false},
+		{"Growth below tolerance", []float64{100, 100, 101, 101, 102, 102, 103, 103}, 0.1, false},
+		{"Plateau after warm-up", []float64{10, 12, 14, 14, 14, 14, 14, 14}, 0, false},
+		{"Too few samples", []float64{1, 2, 3}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := monotonicGrowth(tt.values, tt.tolerance); got != tt.expected {
+				t.Errorf("Expected %v, got %v for %v", tt.expected, got, tt.values)
+			}
+		})
+	}
+}
+
+func TestDetectLeaks(t *testing.T) {
+	var samples []soakSample
+	for i := 0; i < 12; i++ {
+		samples = append(samples, soakSample{
+			at:         time.Unix(int64(i*60), 0),
+			goroutines: 20 + i,                     // Утечка горутин
+			heapBytes:  uint64(4<<20 + (i%2)*1024), // Куча стабильна
+			channels:   map[string]int{"responses": 0, "telemetry": i % 3},
+		})
+	}
+
+	leaks := detectLeaks(samples)
+	if len(leaks) != 1 || leaks[0].metric != "goroutines" {
+		t.Fatalf("Expected goroutine leak only, got %+v", leaks)
+	}
+	if leaks[0].first != 20 || leaks[0].last != 31 {
+		t.Errorf("Unexpected leak range: %+v", leaks[0])
+	}
+}
+
+func TestRunSoakShort(t *testing.T) {
+	if testing.Short() {
+		t.Skip("soak run skipped in short mode")
+	}
+
+	samples, res, err := runSoak([]string{"010C", "010D"}, 300*time.Millisecond, 50*time.Millisecond, 0, 0)
+	if err != nil {
+		t.Fatalf("Soak run failed: %v", err)
+	}
+	if res.samples == 0 {
+		t.Error("Expected telemetry samples during soak run")
+	}
+	if len(samples) == 0 {
+		t.Error("Expected process samples during soak run")
+	}
+}
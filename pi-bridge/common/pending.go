@@ -0,0 +1,320 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Состояния ожидающего запроса
+const (
+	requestQueued = iota // Зарегистрирован, еще не отправлен адаптеру
+	requestSent          // Записан в адаптер, ожидает ответа
+	requestBound         // Ответ получен и передан парсеру
+	requestDone          // Результат получен
+)
+
+// PendingRequest описывает команду удаленного клиента, ожидающую ответа адаптера
+type PendingRequest struct {
+	Command       string    `json:"command"`
+	PID           string    `json:"pid,omitempty"` // PID запроса OBD (пусто для AT команд)
+	CorrelationID string    `json:"correlation_id"`
+	Deadline      time.Time `json:"deadline"`
+
+	state    int
+	response string // Сырой ответ, переданный парсеру
+	result   interface{}
+	err      error
+}
+
+// CommandResult представляет результат одной команды из последовательности,
+// например раскрытой служебной команды моста
+type CommandResult struct {
+	Command string      `json:"command"`
+	Status  string      `json:"status"`
+	Result  interface{} `json:"result,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// PendingRequests связывает команды из MQTT с ответами адаптера. Клиент MQTT
+// регистрирует команды, адаптер отмечает отправку и получение ответа, а парсер
+// OBD сообщает результат разбора. Когда все команды с одним correlation ID
+// завершены или истек срок ожидания, в канал ответов отправляется CommandResponse.
+// Методы безопасно вызывать у nil реестра: сопоставление тогда выключено
+type PendingRequests struct {
+	mu        sync.Mutex
+	timeout   time.Duration
+	requests  []*PendingRequest // В порядке регистрации
+	responses chan<- CommandResponse
+	logger    *log.Logger
+}
+
+// NewPendingRequests создает реестр со сроком ожидания ответа timeout
+func NewPendingRequests(timeout time.Duration, responses chan<- CommandResponse) *PendingRequests {
+	return &PendingRequests{
+		timeout:   timeout,
+		responses: responses,
+		logger:    log.New(os.Stdout, "[Pending-Requests] ", log.LstdFlags|log.Lshortfile),
+	}
+}
+
+// Register регистрирует команды, отправляемые адаптеру по запросу с заданным correlation ID
+func (p *PendingRequests) Register(correlationID string, commands []string) {
+	if p == nil || correlationID == "" {
+		return
+	}
+
+	deadline := time.Now().Add(p.timeout)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, command := range commands {
+		p.requests = append(p.requests, &PendingRequest{
+			Command:       command,
+			PID:           commandPID(command),
+			CorrelationID: correlationID,
+			Deadline:      deadline,
+		})
+	}
+}
+
+// Claim отмечает отправку команды адаптеру и возвращает связанный с ней запрос
+// (nil, если команда отправлена не по запросу клиента, например при опросе PID)
+func (p *PendingRequests) Claim(command string) *PendingRequest {
+	if p == nil {
+		return nil
+	}
+
+	key := normalizeCommand(command)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, request := range p.requests {
+		if request.state == requestQueued && normalizeCommand(request.Command) == key {
+			request.state = requestSent
+			return request
+		}
+	}
+	return nil
+}
+
+// Bind связывает ответ адаптера с запросом перед передачей ответа парсеру
+func (p *PendingRequests) Bind(request *PendingRequest, response string) {
+	if p == nil || request == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if request.state == requestSent {
+		request.state = requestBound
+		request.response = response
+	}
+}
+
+// Resolve возвращает запрос, к которому относится полученный парсером ответ
+// (nil, если ответ получен на команду, не зарегистрированную клиентом)
+func (p *PendingRequests) Resolve(response string) *PendingRequest {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, request := range p.requests {
+		if request.state == requestBound && request.response == response {
+			return request
+		}
+	}
+	return nil
+}
+
+// Finish записывает результат запроса и публикует ответ, если завершена вся последовательность
+func (p *PendingRequests) Finish(request *PendingRequest, result interface{}, err error) {
+	if p == nil || request == nil {
+		return
+	}
+
+	p.mu.Lock()
+	if request.state == requestDone {
+		p.mu.Unlock()
+		return
+	}
+	request.state = requestDone
+	request.result = result
+	request.err = err
+	response, complete := p.collect(request.CorrelationID)
+	p.mu.Unlock()
+
+	if complete {
+		p.send(response)
+	}
+}
+
+// Cancel завершает все незавершенные запросы с заданным correlation ID без публикации ответа,
+// например когда клиент уже получил ошибку отправки
+func (p *PendingRequests) Cancel(correlationID string) {
+	if p == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	kept := p.requests[:0]
+	for _, request := range p.requests {
+		if request.CorrelationID != correlationID {
+			kept = append(kept, request)
+		}
+	}
+	p.requests = kept
+}
+
+// Expire завершает с ошибкой запросы, не получившие ответа до истечения срока
+func (p *PendingRequests) Expire(now time.Time) int {
+	if p == nil {
+		return 0
+	}
+
+	var responses []CommandResponse
+	expired := 0
+
+	p.mu.Lock()
+	// collect удаляет завершенные запросы, поэтому обходим копию списка
+	requests := append([]*PendingRequest(nil), p.requests...)
+	for _, request := range requests {
+		if request.state == requestDone || now.Before(request.Deadline) {
+			continue
+		}
+		request.state = requestDone
+		request.err = fmt.Errorf("no response to %s within %v", request.Command, p.timeout)
+		expired++
+		if response, complete := p.collect(request.CorrelationID); complete {
+			responses = append(responses, response)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, response := range responses {
+		p.send(response)
+	}
+	return expired
+}
+
+// Run периодически завершает просроченные запросы до отмены ctx
+func (p *PendingRequests) Run(ctx context.Context) {
+	if p == nil {
+		return
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			if expired := p.Expire(now); expired > 0 {
+				p.logger.Printf("%d command(s) timed out waiting for adapter response", expired)
+			}
+		}
+	}
+}
+
+// Pending возвращает копии незавершенных запросов
+func (p *PendingRequests) Pending() []PendingRequest {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pending := make([]PendingRequest, 0, len(p.requests))
+	for _, request := range p.requests {
+		if request.state != requestDone {
+			pending = append(pending, *request)
+		}
+	}
+	return pending
+}
+
+// collect формирует ответ, если все запросы с correlation ID завершены, и удаляет их
+// из реестра (вызывается под мьютексом)
+func (p *PendingRequests) collect(correlationID string) (CommandResponse, bool) {
+	var group []*PendingRequest
+	for _, request := range p.requests {
+		if request.CorrelationID != correlationID {
+			continue
+		}
+		if request.state != requestDone {
+			return CommandResponse{}, false
+		}
+		group = append(group, request)
+	}
+	if len(group) == 0 {
+		// Запросы уже отменены
+		return CommandResponse{}, false
+	}
+
+	kept := p.requests[:0]
+	for _, request := range p.requests {
+		if request.CorrelationID != correlationID {
+			kept = append(kept, request)
+		}
+	}
+	p.requests = kept
+
+	response := CommandResponse{
+		CorrelationID: correlationID,
+		Status:        "success",
+		Timestamp:     time.Now(),
+	}
+
+	// Одиночная команда публикует результат как есть, последовательность - списком
+	if len(group) == 1 {
+		response.Result = group[0].result
+		if group[0].err != nil {
+			response.Status = "error"
+			response.Error = group[0].err.Error()
+		}
+		return response, true
+	}
+
+	results := make([]CommandResult, 0, len(group))
+	for _, request := range group {
+		result := CommandResult{Command: request.Command, Status: "success", Result: request.result}
+		if request.err != nil {
+			result.Status = "error"
+			result.Error = request.err.Error()
+			if response.Error == "" {
+				response.Status = "error"
+				response.Error = fmt.Sprintf("%s: %v", request.Command, request.err)
+			}
+		}
+		results = append(results, result)
+	}
+	response.Result = results
+	return response, true
+}
+
+// send отправляет ответ в канал без блокировки
+func (p *PendingRequests) send(response CommandResponse) {
+	select {
+	case p.responses <- response:
+	default:
+		p.logger.Printf("Warning: command responses channel is full, dropping response for correlation_id: %s", response.CorrelationID)
+	}
+}
+
+// commandPID извлекает PID из запроса OBD вида "010C" (пусто для AT команд и сервисов без PID)
+func commandPID(command string) string {
+	cmd := normalizeCommand(command)
+	if len(cmd) < 4 || strings.HasPrefix(cmd, "AT") || strings.HasPrefix(cmd, "ST") {
+		return ""
+	}
+	return cmd[2:4]
+}
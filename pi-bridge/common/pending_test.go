@@ -0,0 +1,132 @@
+package common
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPendingRequestsSingleCommand(t *testing.T) {
+	responses := make(chan CommandResponse, 1)
+	requests := NewPendingRequests(time.Second, responses)
+
+	requests.Register("cmd-1", []string{"01 0c"})
+
+	pending := requests.Pending()
+	if len(pending) != 1 || pending[0].PID != "0C" || pending[0].CorrelationID != "cmd-1" {
+		t.Fatalf("Unexpected pending requests: %+v", pending)
+	}
+
+	// Команда опроса с другим PID не связывается с запросом
+	if request := requests.Claim("010D"); request != nil {
+		t.Errorf("Expected no request for 010D, got %+v", request)
+	}
+
+	request := requests.Claim("010C")
+	if request == nil {
+		t.Fatal("Expected request for 010C")
+	}
+	if requests.Claim("010C") != nil {
+		t.Error("Expected request to be claimed only once")
+	}
+
+	requests.Bind(request, "41 0C 1A F0")
+	if requests.Resolve("41 0D 32") != nil {
+		t.Error("Expected unrelated response not to resolve")
+	}
+	if requests.Resolve("41 0C 1A F0") != request {
+		t.Fatal("Expected bound response to resolve to the request")
+	}
+
+	requests.Finish(request, 1724.0, nil)
+
+	select {
+	case response := <-responses:
+		if response.CorrelationID != "cmd-1" || response.Status != "success" || response.Result != 1724.0 {
+			t.Errorf("Unexpected response: %+v", response)
+		}
+	default:
+		t.Fatal("Expected command response")
+	}
+
+	if len(requests.Pending()) != 0 {
+		t.Error("Expected registry to be empty after completion")
+	}
+}
+
+func TestPendingRequestsSequence(t *testing.T) {
+	responses := make(chan CommandResponse, 1)
+	requests := NewPendingRequests(time.Second, responses)
+
+	requests.Register("check", []string{"ATRV", "0105"})
+
+	voltage := requests.Claim("ATRV")
+	coolant := requests.Claim("0105")
+	requests.Finish(voltage, "12.6V", nil)
+
+	if len(responses) != 0 {
+		t.Fatal("Expected no response before the sequence completes")
+	}
+
+	requests.Finish(coolant, nil, errString("NO DATA"))
+
+	response := <-responses
+	results, ok := response.Result.([]CommandResult)
+	if !ok || len(results) != 2 {
+		t.Fatalf("Expected 2 command results, got %+v", response.Result)
+	}
+	if response.Status != "error" || results[0].Status != "success" || results[1].Error != "NO DATA" {
+		t.Errorf("Unexpected sequence response: %+v", response)
+	}
+}
+
+func TestPendingRequestsExpire(t *testing.T) {
+	responses := make(chan CommandResponse, 1)
+	requests := NewPendingRequests(time.Second, responses)
+
+	requests.Register("slow", []string{"0902"})
+	requests.Claim("0902")
+
+	if expired := requests.Expire(time.Now()); expired != 0 {
+		t.Errorf("Expected no expired requests before deadline, got %d", expired)
+	}
+	if expired := requests.Expire(time.Now().Add(2 * time.Second)); expired != 1 {
+		t.Fatalf("Expected 1 expired request, got %d", expired)
+	}
+
+	response := <-responses
+	if response.CorrelationID != "slow" || response.Status != "error" || response.Error == "" {
+		t.Errorf("Unexpected timeout response: %+v", response)
+	}
+}
+
+func TestPendingRequestsCancel(t *testing.T) {
+	responses := make(chan CommandResponse, 1)
+	requests := NewPendingRequests(time.Second, responses)
+
+	requests.Register("cancelled", []string{"010C", "010D"})
+	request := requests.Claim("010C")
+	requests.Cancel("cancelled")
+	requests.Finish(request, 1724.0, nil)
+
+	if len(responses) != 0 || len(requests.Pending()) != 0 {
+		t.Errorf("Expected cancelled requests to be dropped silently")
+	}
+}
+
+func TestPendingRequestsNil(t *testing.T) {
+	var requests *PendingRequests
+
+	requests.Register("cmd", []string{"010C"})
+	request := requests.Claim("010C")
+	requests.Bind(request, "41 0C 1A F0")
+	requests.Finish(requests.Resolve("41 0C 1A F0"), nil, nil)
+
+	if request != nil || requests.Expire(time.Now()) != 0 {
+		t.Error("Expected nil registry to disable correlation")
+	}
+}
+
+// errString - простая ошибка для тестов
+type errString string
+
+func (e errString) Error() string { return string(e) }
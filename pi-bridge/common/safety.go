@@ -0,0 +1,86 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+// actuationServices содержит сервисы OBD-II/UDS/KWP, способные изменить состояние автомобиля
+var actuationServices = map[string]string{
+	"04": "clear DTCs (mode 04)",
+	"08": "control on-board system (mode 08)",
+	"10": "diagnostic session control",
+	"11": "ECU reset",
+	"14": "clear diagnostic information",
+	"27": "security access",
+	"28": "communication control",
+	"2E": "write data by identifier",
+	"2F": "input/output control",
+	"31": "routine control",
+	"34": "request download",
+	"35": "request upload",
+	"36": "transfer data",
+	"37": "request transfer exit",
+	"3B": "write data by local identifier",
+	"3D": "write memory by address",
+	"85": "control DTC setting",
+}
+
+// adapterResetCommands содержит AT команды, сбрасывающие настройки адаптера
+var adapterResetCommands = map[string]bool{
+	"ATZ":  true, // Полный сброс
+	"ATWS": true, // Теплый старт
+	"ATD":  true, // Возврат к заводским настройкам
+	"ATD0": true,
+	"ATD1": true,
+	"ATLP": true, // Переход в режим пониженного энергопотребления
+}
+
+// adapterResetPrefixes содержит AT команды с параметрами, перепрограммирующие адаптер
+var adapterResetPrefixes = []string{
+	"ATPP",  // Программируемые параметры
+	"ATBRD", // Смена скорости порта
+	"ATBRT", // Таймаут смены скорости порта
+}
+
+// normalizeCommand приводит команду к виду без пробелов в верхнем регистре
+func normalizeCommand(command string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(command), ""))
+}
+
+// ActuationReason возвращает описание, если команда способна изменить состояние автомобиля
+func ActuationReason(command string) (string, bool) {
+	cmd := normalizeCommand(command)
+	if len(cmd) < 2 || strings.HasPrefix(cmd, "AT") || strings.HasPrefix(cmd, "ST") {
+		return "", false
+	}
+	reason, exists := actuationServices[cmd[:2]]
+	return reason, exists
+}
+
+// IsAdapterResetCommand проверяет, сбрасывает ли команда настройки адаптера
+func IsAdapterResetCommand(command string) bool {
+	cmd := normalizeCommand(command)
+	if adapterResetCommands[cmd] {
+		return true
+	}
+	for _, prefix := range adapterResetPrefixes {
+		if strings.HasPrefix(cmd, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckReadOnly возвращает ошибку, если команда запрещена в режиме только чтения.
+// remote указывает, что команда получена извне (MQTT): для таких команд
+// дополнительно запрещен сброс адаптера
+func CheckReadOnly(command string, remote bool) error {
+	if reason, ok := ActuationReason(command); ok {
+		return fmt.Errorf("command %q rejected in read-only mode: %s", command, reason)
+	}
+	if remote && IsAdapterResetCommand(command) {
+		return fmt.Errorf("command %q rejected in read-only mode: adapter reset from remote", command)
+	}
+	return nil
+}
@@ -0,0 +1,40 @@
+package common
+
+import (
+	"testing"
+)
+
+func TestCheckReadOnly(t *testing.T) {
+	tests := []struct {
+		command     string
+		remote      bool
+		expectError bool
+	}{
+		{"010C", true, false},    // Чтение PID
+		{"03", true, false},      // Чтение DTC
+		{"0902", true, false},    // Чтение VIN
+		{"04", true, true},       // Сброс DTC
+		{"04", false, true},      // Сброс DTC запрещен и локально
+		{"08 01 00", true, true}, // Управление системой
+		{"2E F1 90 00", true, true},
+		{"3101FF00", true, true},
+		{"1002", true, true},
+		{"ATZ", true, true},   // Сброс адаптера извне
+		{"ATZ", false, false}, // Сброс при инициализации разрешен
+		{"at z", true, true},  // Пробелы и регистр не обходят проверку
+		{"ATPP 0C SV 23", true, true},
+		{"ATDPN", true, false}, // Чтение протокола не является сбросом
+		{"ATRV", true, false},
+		{"", true, false}, // Повтор последней команды
+	}
+
+	for _, tt := range tests {
+		err := CheckReadOnly(tt.command, tt.remote)
+		if tt.expectError && err == nil {
+			t.Errorf("Expected %q (remote=%v) to be rejected", tt.command, tt.remote)
+		}
+		if !tt.expectError && err != nil {
+			t.Errorf("Expected %q (remote=%v) to be allowed, got %v", tt.command, tt.remote, err)
+		}
+	}
+}
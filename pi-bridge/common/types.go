@@ -4,20 +4,28 @@ import "time"
 
 // Telemetry представляет декодированные данные телеметрии
 type Telemetry struct {
-	PID       string  `json:"pid"`       // PID код (например, "0C")
-	Metric    string  `json:"metric"`    // Название метрики (например, "rpm")
-	Value     float64 `json:"value"`     // Декодированное значение
-	Unit      string  `json:"unit"`      // Единица измерения (например, "rpm")
-	Timestamp int64   `json:"timestamp"` // Unix timestamp
-	Raw       string  `json:"raw"`       // Сырые данные для отладки
+	PID         string  `json:"pid"`                    // PID код (например, "0C")
+	Metric      string  `json:"metric"`                 // Название метрики (например, "rpm")
+	Value       float64 `json:"value"`                  // Декодированное значение
+	Unit        string  `json:"unit"`                   // Единица измерения (например, "rpm")
+	Timestamp   int64   `json:"timestamp"`              // Unix timestamp
+	Raw         string  `json:"raw"`                    // Сырые данные для отладки
+	HighRate    bool    `json:"high_rate,omitempty"`    // Получено в потоковом (высокочастотном) режиме
+	ECU         string  `json:"ecu,omitempty"`          // Адрес ЭБУ-отправителя (при включенных заголовках)
+	FreezeFrame bool    `json:"freeze_frame,omitempty"` // Значение из стоп-кадра (сервис 02), а не текущее
+	Data        string  `json:"data,omitempty"`         // Байты данных неподдерживаемого PID в hex (передаются без декодирования)
+	Implausible bool    `json:"implausible,omitempty"`  // Значение вне допустимого диапазона метрики
+	Derived     bool    `json:"derived,omitempty"`      // Метрика вычислена мостом, а не получена от ЭБУ
 }
 
 // CommandMessage представляет входящую команду
 type CommandMessage struct {
-	Command       string `json:"command"`        // AT команда для отправки в ELM327
-	CorrelationID string `json:"correlation_id"` // ID для сопоставления запроса и ответа
-	Description   string `json:"description"`    // Описание команды
-	VIN           string `json:"vin"`            // VIN автомобиля
+	Command       string `json:"command"`             // AT команда для отправки в ELM327
+	CorrelationID string `json:"correlation_id"`      // ID для сопоставления запроса и ответа
+	Description   string `json:"description"`         // Описание команды
+	VIN           string `json:"vin"`                 // VIN автомобиля
+	Timestamp     int64  `json:"timestamp,omitempty"` // Время подписи (Unix секунды)
+	Signature     string `json:"signature,omitempty"` // Подпись HMAC-SHA256 в hex (при mqtt.auth.secret)
 }
 
 // CommandResponse представляет ответ на команду
@@ -28,3 +36,19 @@ type CommandResponse struct {
 	Error         string      `json:"error,omitempty"` // Описание ошибки если статус "error"
 	Timestamp     time.Time   `json:"timestamp"`
 }
+
+// StatusEvent представляет служебное событие моста (состояние шины, адаптера и т.п.)
+type StatusEvent struct {
+	Kind      string      `json:"kind"`      // Тип события, используется как подтопик (например, "bus_health")
+	Data      interface{} `json:"data"`      // Полезная нагрузка события
+	Retained  bool        `json:"-"`         // Публиковать как retained сообщение
+	Timestamp time.Time   `json:"timestamp"` // Время события
+}
+
+// CANFrame представляет кадр шины CAN, полученный в режиме мониторинга (ATMA)
+type CANFrame struct {
+	ID        string    `json:"id"`                // Идентификатор кадра: "3B4" или "18FEF100"
+	Data      string    `json:"data"`              // Байты данных в hex через пробел
+	Skipped   int       `json:"skipped,omitempty"` // Кадров с этим ID пропущено ограничением частоты с прошлой публикации
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -0,0 +1,5 @@
+package common
+
+// Version - версия моста в сообщении о запуске (birth). Задается при сборке:
+// go build -ldflags "-X elm327-bridge/common.Version=1.4.0"
+var Version = "dev"
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"elm327-bridge/bluetooth"
+)
+
+// printDevices выводит устройства, через которые можно подключиться к адаптеру
+// (режим --list-devices), с ключами секции bluetooth для config.yaml
+func printDevices(w io.Writer) error {
+	devices, err := bluetooth.ListDevices()
+	if err != nil {
+		// Без BlueZ остаются устройства rfcomm и USB
+		fmt.Fprintf(w, "Warning: %v\n\n", err)
+	}
+	if len(devices) == 0 {
+		fmt.Fprintln(w, "No devices found. Pair the adapter (bluetoothctl) or plug in the USB cable and try again")
+		return nil
+	}
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "KIND\tPATH\tADDRESS\tNAME\tELM327\tCONFIG")
+	for _, device := range devices {
+		likely := ""
+		if device.Likely {
+			likely = "likely"
+		}
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\t%s\n", device.Kind, dash(device.Path), dash(device.Address), dash(device.Name), dash(likely), dash(device.ConfigHint()))
+	}
+	return table.Flush()
+}
+
+// dash заменяет пустое значение прочерком, чтобы колонки таблицы не сливались
+func dash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -1,31 +1,53 @@
 package main
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
+	"elm327-bridge/api"
 	"elm327-bridge/bluetooth"
+	"elm327-bridge/common"
 	"elm327-bridge/mqtt"
 	"elm327-bridge/obd"
 
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/spf13/viper"
 )
 
 var logger = log.New(os.Stdout, "[ELM327-Bridge] ", log.LstdFlags|log.Lshortfile)
 
+// defaultStartupTimeout - срок запуска мостов и REST API по умолчанию
+const defaultStartupTimeout = 30 * time.Second
+
 type Config struct {
-	Bluetooth bluetooth.Config `mapstructure:"bluetooth"`
-	MQTT      mqtt.Config      `mapstructure:"mqtt"`
-	Logging   struct {
-		Level string `mapstructure:"level"`
-	} `mapstructure:"logging"`
+	ReadOnly       bool                 `yaml:"read_only"`       // Запрет любых команд, меняющих состояние автомобиля
+	Units          string               `yaml:"units"`           // Система единиц публикуемых значений: metric или imperial
+	StartupTimeout time.Duration        `yaml:"startup_timeout"` // Срок запуска мостов и REST API, включая подключение к брокеру (0 - 30s)
+	Bluetooth      bluetooth.Config     `yaml:"bluetooth"`
+	MQTT           mqtt.Config          `yaml:"mqtt"`
+	API            api.Config           `yaml:"api"`
+	OBD            obd.Config           `yaml:"obd"`
+	PreDrive       obd.PreDriveCriteria `yaml:"predrive"` // Пороги проверки перед поездкой
+	Vehicles       []VehicleConfig      `yaml:"vehicles"` // Несколько автомобилей в одном процессе (пусто - один адаптер из bluetooth)
+	Logging        struct {
+		Level string `yaml:"level"`
+	} `yaml:"logging"`
 }
 
 var config Config
 
+// configHash - хеш файла конфигурации в сообщении о запуске: по нему потребители
+// замечают, что мост перезапущен с другими настройками
+var configHash string
+
 // loadConfig загружает конфигурацию из файла config.yaml
 func loadConfig() error {
 	viper.SetConfigName("config")
@@ -36,7 +58,24 @@ func loadConfig() error {
 		return fmt.Errorf("error reading config file: %v", err)
 	}
 
-	if err := viper.Unmarshal(&config); err != nil {
+	hash, err := hashConfigFile(viper.ConfigFileUsed())
+	if err != nil {
+		return fmt.Errorf("error reading config file: %v", err)
+	}
+	configHash = hash
+
+	// Значения по умолчанию перекрываются заданными в файле
+	config.Bluetooth = bluetooth.DefaultConfig()
+	config.MQTT = mqtt.DefaultConfig()
+	config.API = api.DefaultConfig()
+	config.OBD = obd.DefaultConfig()
+	config.PreDrive = obd.DefaultPreDriveCriteria()
+
+	// Конфигурации модулей описаны yaml тегами
+	if err := viper.Unmarshal(&config, func(dc *mapstructure.DecoderConfig) {
+		dc.TagName = "yaml"
+		dc.ZeroFields = true
+	}); err != nil {
 		return fmt.Errorf("error unmarshaling config: %v", err)
 	}
 
@@ -48,67 +87,230 @@ func loadConfig() error {
 	return nil
 }
 
+// hashConfigFile возвращает первые 16 hex символов SHA-256 содержимого файла конфигурации
+func hashConfigFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16], nil
+}
+
 // validateConfig проверяет корректность конфигурации
 func validateConfig() error {
-	if config.Bluetooth.DevicePath == "" {
+	// Секция bluetooth без устройства и транспорта - значения по умолчанию (/dev/rfcomm0)
+	if config.Bluetooth.Transport == "" && config.Bluetooth.DevicePath == "" {
 		config.Bluetooth = bluetooth.DefaultConfig()
 		logger.Println("Using default Bluetooth configuration")
 	}
 
-	if config.MQTT.Broker == "" {
-		return fmt.Errorf("MQTT broker address must be set in config.yaml")
+	if err := validateBluetooth(config.Bluetooth); err != nil {
+		return err
 	}
 
-	return nil
+	if config.StartupTimeout < 0 {
+		return fmt.Errorf("invalid startup_timeout %v", config.StartupTimeout)
+	}
+	if config.StartupTimeout == 0 {
+		config.StartupTimeout = defaultStartupTimeout
+	}
+
+	if err := config.MQTT.ValidateBroker(); err != nil {
+		return err
+	}
+	if err := config.MQTT.ValidateQoS(); err != nil {
+		return err
+	}
+	if err := config.MQTT.ValidatePayloadFormat(); err != nil {
+		return err
+	}
+	if err := config.MQTT.Batch.Validate(); err != nil {
+		return err
+	}
+	if err := config.MQTT.Buffer.Validate(); err != nil {
+		return err
+	}
+	if err := config.MQTT.Dedup.Validate(); err != nil {
+		return err
+	}
+	if err := config.MQTT.CommandFilter.Validate(); err != nil {
+		return err
+	}
+	if err := config.MQTT.Auth.Validate(); err != nil {
+		return err
+	}
+	if err := config.MQTT.ValidateResponseTopics(); err != nil {
+		return err
+	}
+
+	if err := obd.SetUnitSystem(config.Units); err != nil {
+		return err
+	}
+
+	// Пользовательские PID компилируются при запуске, ошибки формул фатальны.
+	// PID из конфигурации загружаются после плагинов и имеют приоритет
+	if err := obd.LoadDecoderPlugins(config.OBD.PluginsDir); err != nil {
+		return err
+	}
+	if err := obd.RegisterCustomPIDs(config.OBD.CustomPIDs); err != nil {
+		return err
+	}
+	if err := obd.RegisterPollGroups(config.OBD.PollGroups); err != nil {
+		return err
+	}
+	if err := obd.RegisterO2MonitorSensors(config.OBD.O2MonitorSensors); err != nil {
+		return err
+	}
+	if err := obd.RegisterPlausibility(config.OBD.Plausibility); err != nil {
+		return err
+	}
+	obd.SetBatteryVoltageInterval(config.OBD.BatteryVoltageInterval)
+	if err := config.OBD.LowPower.Validate(); err != nil {
+		return err
+	}
+	if err := config.OBD.Ignition.Validate(); err != nil {
+		return err
+	}
+	// Оба режима приостанавливают опрос заглушенного автомобиля по-своему
+	if config.OBD.Ignition.Enabled && config.OBD.LowPower.Enabled {
+		return fmt.Errorf("obd.ignition and obd.low_power cannot be enabled together")
+	}
+	if err := obd.RegisterPrecision(config.OBD.Precision); err != nil {
+		return err
+	}
+
+	// Режим J1939 требует явного выбора протокола и заголовков в ответах адаптера
+	if err := obd.SetProtocol(config.OBD.Protocol, config.OBD.J1939PGNs); err != nil {
+		return err
+	}
+	// Без секции vehicles VIN неизвестен: профиль выбирается только ключом init_profile
+	if profile := config.Bluetooth.ApplyInitProfile(""); profile != "" {
+		logger.Printf("Using init profile %s", profile)
+	}
+	applyBluetoothSettings(&config.Bluetooth)
+
+	// Режим только чтения применяется ко всем модулям
+	config.MQTT.ReadOnly = config.ReadOnly
+	if config.ReadOnly {
+		logger.Println("Read-only mode: ENABLED (actuation commands are blocked)")
+	}
+
+	// Адаптеры автомобилей из секции vehicles наследуют общую секцию bluetooth
+	return resolveVehicles(viper.GetStringMap("bluetooth"))
+}
+
+// validateBluetooth проверяет параметры адаптера
+func validateBluetooth(adapter bluetooth.Config) error {
+	if err := adapter.ValidateTransport(); err != nil {
+		return err
+	}
+	if err := adapter.ValidateTiming(); err != nil {
+		return err
+	}
+	if err := adapter.ValidateFraming(); err != nil {
+		return err
+	}
+	if err := adapter.ValidateRecovery(); err != nil {
+		return err
+	}
+	if err := adapter.ValidateProtocol(); err != nil {
+		return err
+	}
+	if err := adapter.ValidateInitProfiles(); err != nil {
+		return err
+	}
+	// Режим J1939 сам выбирает протокол A, закрепленный протокол ему противоречит
+	if obd.J1939Enabled() && len(adapter.Protocols()) > 0 {
+		return fmt.Errorf("bluetooth protocol cannot be set in J1939 mode")
+	}
+	return adapter.Retry.Validate()
+}
+
+// applyBluetoothSettings переносит в конфигурацию адаптера общие настройки моста
+func applyBluetoothSettings(adapter *bluetooth.Config) {
+	// Режим J1939 требует заголовков в ответах адаптера
+	if obd.J1939Enabled() {
+		adapter.InitCommands = append(adapter.InitCommands, obd.J1939InitCommands...)
+	}
+	adapter.ReadOnly = config.ReadOnly
 }
 
 // main функция приложения
 func main() {
-	logger.Println("Starting ELM327 Bridge...")
+	listDevices := flag.Bool("list-devices", false, "вывести сопряженные устройства Bluetooth, /dev/rfcomm* и USB порты и завершить работу")
+	flag.Parse()
+
+	// Перечисление устройств для начальной настройки не требует config.yaml
+	if *listDevices {
+		if err := printDevices(os.Stdout); err != nil {
+			logger.Fatalf("Failed to list devices: %v", err)
+		}
+		return
+	}
+
+	logger.Printf("Starting ELM327 Bridge %s...", common.Version)
 
 	// Загружаем конфигурацию
 	if err := loadConfig(); err != nil {
 		logger.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Создаем каналы для связи между модулями
-	responsesChan := make(chan string, 50)                     // Сырые ответы от ELM327
-	commandsChan := make(chan string, 20)                      // Команды для отправки в ELM327
-	telemetryChan := make(chan interface{}, 100)               // Декодированные данные телеметрии
-	commandResponsesChan := make(chan obd.CommandResponse, 50) // Ответы на команды
+	// Сигнал завершения отменяет ctx, в том числе во время запуска
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
-	// Создаем и запускаем Bluetooth адаптер
-	btAdapter := bluetooth.NewAdapter(config.Bluetooth, responsesChan, commandsChan)
-	if err := btAdapter.Start(); err != nil {
-		logger.Fatalf("Failed to start Bluetooth adapter: %v", err)
-	}
-
-	// Создаем и запускаем парсер OBD
-	go obd.StartParser(responsesChan, telemetryChan, commandResponsesChan)
+	// REST API для клиентов, которым неудобно работать через MQTT
+	apiServer := api.NewServer(config.API)
 
-	// Создаем и запускаем MQTT клиента
-	mqttClient := mqtt.NewClient(config.MQTT, telemetryChan, commandsChan, commandResponsesChan)
-	if err := mqttClient.Start(); err != nil {
-		logger.Fatalf("Failed to start MQTT client: %v", err)
+	// Запуск всех мостов и REST API ограничен startup_timeout
+	bridges, err := start(ctx, apiServer)
+	if err != nil {
+		logger.Fatalf("Failed to start: %v", err)
 	}
 
-	// Запускаем менеджер команд для периодического опроса PID
-	go obd.StartCommandManager(commandsChan)
-
 	logger.Println("ELM327 Bridge started successfully")
 	logger.Println("Press Ctrl+C to stop")
 
 	// Ожидаем сигнал завершения
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-
-	<-sigChan
+	<-ctx.Done()
 
 	logger.Println("Shutting down...")
-
-	// Останавливаем все модули
-	btAdapter.Stop()
-	mqttClient.Stop()
+	shutdown(apiServer, bridges)
 
 	logger.Println("ELM327 Bridge stopped")
 }
+
+// start запускает мост каждого автомобиля и REST API. При ошибке или истечении
+// startup_timeout уже запущенные мосты останавливаются
+func start(ctx context.Context, apiServer *api.Server) ([]*bridge, error) {
+	ctx, cancel := context.WithTimeout(ctx, config.StartupTimeout)
+	defer cancel()
+
+	// Каждый автомобиль обслуживается своим экземпляром моста
+	bridges := make([]*bridge, 0, len(config.Vehicles))
+	for _, vehicle := range config.Vehicles {
+		b, err := startBridge(ctx, vehicle, apiServer)
+		if err != nil {
+			shutdown(nil, bridges)
+			return nil, fmt.Errorf("failed to start bridge: %w", err)
+		}
+		bridges = append(bridges, b)
+	}
+
+	if err := apiServer.Start(ctx); err != nil {
+		shutdown(nil, bridges)
+		return nil, fmt.Errorf("failed to start REST API: %w", err)
+	}
+	return bridges, nil
+}
+
+// shutdown останавливает REST API (nil - не запущен) и мосты
+func shutdown(apiServer *api.Server, bridges []*bridge) {
+	if apiServer != nil {
+		apiServer.Stop()
+	}
+	for _, b := range bridges {
+		b.Stop()
+	}
+}
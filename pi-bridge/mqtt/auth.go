@@ -0,0 +1,117 @@
+package mqtt
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultAuthMaxAge - допустимое по умолчанию расхождение времени подписи и часов моста
+const defaultAuthMaxAge = time.Minute
+
+// AuthConfig задает проверку подписи команд HMAC-SHA256 общим секретом. Подпись не зависит
+// от ACL брокера: команду в автомобиль может отправить только отправитель, знающий секрет
+type AuthConfig struct {
+	Secret string        `yaml:"secret"`  // Общий секрет (пусто - подпись не требуется)
+	MaxAge time.Duration `yaml:"max_age"` // Допустимое расхождение времени подписи (0 - 1m)
+}
+
+// Validate проверяет параметры подписи
+func (c AuthConfig) Validate() error {
+	if c.MaxAge < 0 {
+		return fmt.Errorf("mqtt auth max_age must not be negative, got %v", c.MaxAge)
+	}
+	return nil
+}
+
+// SignCommand возвращает подпись команды в hex. Подписываются VIN автомобиля, команда,
+// correlation ID и время подписи, поэтому подпись нельзя перенести на другой автомобиль,
+// другую команду или повторить после истечения max_age. Мост без VIN в конфигурации
+// проверяет подпись по VIN из топика, в который опубликована команда
+func SignCommand(secret, vin string, cmd CommandMessage) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(vin + "\n" + cmd.Command + "\n" + cmd.CorrelationID + "\n" + strconv.FormatInt(cmd.Timestamp, 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// verifyCommand проверяет подпись команды, полученной из топика topic; без секрета
+// в конфигурации принимает любые команды. Подпись принимается один раз: повтор
+// перехваченной команды в пределах max_age отклоняется
+func (c *Client) verifyCommand(cmd CommandMessage, topic string, now time.Time) error {
+	if c.config.Auth.Secret == "" {
+		return nil
+	}
+	if cmd.Signature == "" {
+		return fmt.Errorf("command %q rejected: missing signature", cmd.Command)
+	}
+
+	// Подписка без VIN принимает команды всех автомобилей: подпись привязывается
+	// к VIN из топика, а не к пустой строке
+	vin := c.vin
+	if vin == "" {
+		vin = c.commandTopicVIN(topic)
+	}
+	signature, err := hex.DecodeString(cmd.Signature)
+	expected, _ := hex.DecodeString(SignCommand(c.config.Auth.Secret, vin, cmd))
+	if err != nil || vin == "" || !hmac.Equal(signature, expected) {
+		return fmt.Errorf("command %q rejected: invalid signature", cmd.Command)
+	}
+
+	maxAge := c.config.Auth.MaxAge
+	if maxAge == 0 {
+		maxAge = defaultAuthMaxAge
+	}
+	age := now.Sub(time.Unix(cmd.Timestamp, 0))
+	if age > maxAge || age < -maxAge {
+		return fmt.Errorf("command %q rejected: signature timestamp is %v off", cmd.Command, age.Round(time.Second))
+	}
+
+	// Подпись в hex не зависит от регистра, поэтому запоминается в каноническом виде
+	key := cmd.CorrelationID + "\n" + hex.EncodeToString(signature)
+	if !c.replays.add(key, time.Unix(cmd.Timestamp, 0).Add(maxAge), now) {
+		return fmt.Errorf("command %q rejected: signature already used", cmd.Command)
+	}
+	return nil
+}
+
+// commandTopicVIN возвращает VIN из топика команд {command_topic}/{VIN}/request
+// (пусто, если топик другой)
+func (c *Client) commandTopicVIN(topic string) string {
+	rest, ok := strings.CutPrefix(topic, c.config.CommandTopic+"/")
+	vin, found := strings.CutSuffix(rest, "/request")
+	if !ok || !found || strings.Contains(vin, "/") {
+		return ""
+	}
+	return vin
+}
+
+// replayCache хранит принятые подписи команд, пока они не истекут по max_age
+type replayCache struct {
+	mu   sync.Mutex
+	seen map[string]time.Time // Correlation ID и подпись -> окончание срока действия подписи
+}
+
+// add запоминает подпись до expires. Возвращает false, если подпись уже принималась
+func (r *replayCache) add(key string, expires, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for seen, until := range r.seen {
+		if now.After(until) {
+			delete(r.seen, seen)
+		}
+	}
+	if _, ok := r.seen[key]; ok {
+		return false
+	}
+	if r.seen == nil {
+		r.seen = make(map[string]time.Time)
+	}
+	r.seen[key] = expires
+	return true
+}
@@ -0,0 +1,149 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+func TestVerifyCommand(t *testing.T) {
+	now := time.Unix(1759883336, 0)
+	config := DefaultConfig()
+	config.Auth.Secret = "s3cret"
+	client := NewClient(config, nil, nil, nil, nil)
+	client.SetVIN("WF0XXXTTGXAB12345")
+
+	signed := func(cmd CommandMessage, secret, vin string) CommandMessage {
+		cmd.Signature = SignCommand(secret, vin, cmd)
+		return cmd
+	}
+	valid := CommandMessage{Command: "010C", CorrelationID: "rpm", Timestamp: now.Unix()}
+	replayed := signed(valid, "s3cret", "WF0XXXTTGXAB12345")
+	replayed.Signature = strings.ToUpper(replayed.Signature)
+	tampered := signed(valid, "s3cret", "WF0XXXTTGXAB12345")
+	tampered.Command = "04"
+
+	tests := []struct {
+		name    string
+		cmd     CommandMessage
+		wantErr bool
+	}{
+		{"valid", signed(valid, "s3cret", "WF0XXXTTGXAB12345"), false},
+		{"replayed", signed(valid, "s3cret", "WF0XXXTTGXAB12345"), true},
+		{"replayed in upper case", replayed, true},
+		{"other correlation ID", signed(CommandMessage{Command: "010C", CorrelationID: "rpm-2", Timestamp: now.Unix()}, "s3cret", "WF0XXXTTGXAB12345"), false},
+		{"clock skew within max age", signed(CommandMessage{Command: "010C", Timestamp: now.Unix() + 30}, "s3cret", "WF0XXXTTGXAB12345"), false},
+		{"missing signature", valid, true},
+		{"not hex", CommandMessage{Command: "010C", Timestamp: now.Unix(), Signature: "zz"}, true},
+		{"wrong secret", signed(valid, "guess", "WF0XXXTTGXAB12345"), true},
+		{"other vehicle", signed(valid, "s3cret", "WVWZZZ1JZXW000001"), true},
+		{"tampered command", tampered, true},
+		{"expired", signed(CommandMessage{Command: "010C", Timestamp: now.Add(-2 * time.Minute).Unix()}, "s3cret", "WF0XXXTTGXAB12345"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := client.verifyCommand(tt.cmd, "car/command/WF0XXXTTGXAB12345/request", now); (err != nil) != tt.wantErr {
+				t.Errorf("verifyCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	// Без секрета подпись не проверяется
+	unsigned := NewClient(DefaultConfig(), nil, nil, nil, nil)
+	if err := unsigned.verifyCommand(valid, "car/command/WF0XXXTTGXAB12345/request", now); err != nil {
+		t.Errorf("Unexpected error without secret: %v", err)
+	}
+
+	// Подпись, принятая однажды, снова действительна только после истечения max_age
+	later := now.Add(2 * time.Minute)
+	fresh := signed(CommandMessage{Command: "010C", CorrelationID: "rpm", Timestamp: later.Unix()}, "s3cret", "WF0XXXTTGXAB12345")
+	if err := client.verifyCommand(fresh, "car/command/WF0XXXTTGXAB12345/request", later); err != nil {
+		t.Errorf("Unexpected error for new signature: %v", err)
+	}
+	if len(client.replays.seen) != 1 {
+		t.Errorf("Expected expired signatures to be forgotten, got %d", len(client.replays.seen))
+	}
+}
+
+func TestVerifyCommandWithoutVIN(t *testing.T) {
+	now := time.Unix(1759883336, 0)
+	config := DefaultConfig()
+	config.Auth.Secret = "s3cret"
+	client := NewClient(config, nil, nil, nil, nil)
+
+	cmd := CommandMessage{Command: "010C", CorrelationID: "rpm", Timestamp: now.Unix()}
+	cmd.Signature = SignCommand("s3cret", "WF0XXXTTGXAB12345", cmd)
+	unbound := cmd
+	unbound.Signature = SignCommand("s3cret", "", unbound)
+
+	tests := []struct {
+		name    string
+		cmd     CommandMessage
+		topic   string
+		wantErr bool
+	}{
+		{"signed for other vehicle", cmd, "car/command/WVWZZZ1JZXW000001/request", true},
+		{"signed without VIN", unbound, "car/command/WF0XXXTTGXAB12345/request", true},
+		{"unexpected topic", cmd, "car/command/request", true},
+		{"signed for topic vehicle", cmd, "car/command/WF0XXXTTGXAB12345/request", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := client.verifyCommand(tt.cmd, tt.topic, now); (err != nil) != tt.wantErr {
+				t.Errorf("verifyCommand() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOnCommandReceivedSigned(t *testing.T) {
+	config := DefaultConfig()
+	config.Auth.Secret = "s3cret"
+	commandsChan := make(chan string, 10)
+	responsesChan := make(chan CommandResponse, 10)
+	client := NewClient(config, make(chan common.Telemetry), commandsChan, responsesChan, make(chan common.StatusEvent))
+	client.SetVIN("TEST123")
+
+	cmd := CommandMessage{Command: "ATRV", CorrelationID: "voltage", Timestamp: time.Now().Unix()}
+	payload, _ := json.Marshal(cmd)
+	client.onCommandReceived(nil, &testMessage{topic: "car/command/TEST123/request", payload: payload})
+	select {
+	case cmd := <-commandsChan:
+		t.Errorf("Expected unsigned command to be rejected, got %s forwarded", cmd)
+	case response := <-responsesChan:
+		if response.CorrelationID != "voltage" || response.Status != "error" {
+			t.Errorf("Expected rejection response, got %+v", response)
+		}
+	}
+	if len(client.History().Entries()) != 0 {
+		t.Errorf("Expected rejected command to stay out of history")
+	}
+
+	cmd.Signature = SignCommand("s3cret", "TEST123", cmd)
+	payload, _ = json.Marshal(cmd)
+	client.onCommandReceived(nil, &testMessage{topic: "car/command/TEST123/request", payload: payload})
+	select {
+	case sent := <-commandsChan:
+		if sent != "ATRV" {
+			t.Errorf("Expected ATRV to be forwarded, got %s", sent)
+		}
+	default:
+		t.Error("Expected signed command to be forwarded")
+	}
+
+	// Повтор перехваченной команды отклоняется
+	client.onCommandReceived(nil, &testMessage{topic: "car/command/TEST123/request", payload: payload})
+	select {
+	case sent := <-commandsChan:
+		t.Errorf("Expected replayed command to be rejected, got %s forwarded", sent)
+	case response := <-responsesChan:
+		if response.Status != "error" || !strings.Contains(response.Error, "already used") {
+			t.Errorf("Expected replay rejection response, got %+v", response)
+		}
+	}
+}
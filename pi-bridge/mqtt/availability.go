@@ -0,0 +1,42 @@
+package mqtt
+
+import "fmt"
+
+// Состояния моста в топике доступности (значения по умолчанию Home Assistant)
+const (
+	availabilityOnline  = "online"
+	availabilityOffline = "offline"
+)
+
+// availabilityTopic возвращает топик доступности моста: car/bridge/{VIN}/availability.
+// При резервировании у каждого узла свой топик с идентификатором узла, иначе завещание
+// остановившегося резервного моста пометило бы недоступным автомобиль, который
+// обслуживает лидер
+func (c *Client) availabilityTopic() string {
+	topic := c.config.StatusTopic + "/availability"
+	if c.vin != "" {
+		topic = c.statusTopic("availability")
+	}
+	if c.election != nil {
+		topic += "/" + c.election.nodeID
+	}
+	return topic
+}
+
+// publishAvailability публикует состояние моста retained сообщением. Состояние offline
+// при потере связи публикует брокер по завещанию (LWT), заданному при подключении
+func (c *Client) publishAvailability(state string) error {
+	if c.mqttClient == nil || !c.mqttClient.IsConnected() {
+		return fmt.Errorf("MQTT client not connected")
+	}
+
+	topic := c.availabilityTopic()
+	token := c.mqttClient.Publish(topic, c.config.QoS, true, state)
+	token.Wait()
+	if token.Error() != nil {
+		return fmt.Errorf("failed to publish availability to topic %s: %v", topic, token.Error())
+	}
+
+	c.logger.Printf("Published availability %s to %s", state, topic)
+	return nil
+}
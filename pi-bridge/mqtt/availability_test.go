@@ -0,0 +1,109 @@
+package mqtt
+
+import (
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"elm327-bridge/common"
+
+	mqttLib "github.com/eclipse/paho.mqtt.golang"
+)
+
+// recordingClient - клиент paho, запоминающий публикации (по умолчанию подключен)
+type recordingClient struct {
+	mqttLib.Client
+	published []publishedMessage
+	offline   atomic.Bool // Связь с брокером потеряна
+}
+
+type publishedMessage struct {
+	topic    string
+	qos      byte
+	retained bool
+	payload  interface{}
+}
+
+func (r *recordingClient) IsConnected() bool { return !r.offline.Load() }
+
+func (r *recordingClient) Disconnect(quiesce uint) {}
+
+func (r *recordingClient) Publish(topic string, qos byte, retained bool, payload interface{}) mqttLib.Token {
+	r.published = append(r.published, publishedMessage{topic, qos, retained, payload})
+	return &mqttLib.DummyToken{}
+}
+
+func TestAvailabilityTopic(t *testing.T) {
+	tests := []struct {
+		name     string
+		vin      string
+		election *Election
+		want     string
+	}{
+		{"single vehicle", "", nil, "car/bridge/availability"},
+		{"vehicle", "WF0XXXTTGXAB12345", nil, "car/bridge/WF0XXXTTGXAB12345/availability"},
+		{"redundant node", "WF0XXXTTGXAB12345", NewElection("pi-2", 0, nil), "car/bridge/WF0XXXTTGXAB12345/availability/pi-2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &Client{config: DefaultConfig(), vin: tt.vin, election: tt.election}
+			if got := client.availabilityTopic(); got != tt.want {
+				t.Errorf("availabilityTopic() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublishAvailability(t *testing.T) {
+	recorder := &recordingClient{}
+	client := NewClient(DefaultConfig(), nil, nil, nil, nil)
+	client.SetVIN("WF0XXXTTGXAB12345")
+	client.mqttClient = recorder
+
+	if err := client.publishAvailability(availabilityOnline); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(recorder.published) != 1 {
+		t.Fatalf("Expected one message, got %d", len(recorder.published))
+	}
+	message := recorder.published[0]
+	if message.topic != "car/bridge/WF0XXXTTGXAB12345/availability" || !message.retained || message.payload != "online" {
+		t.Errorf("Unexpected availability message %+v", message)
+	}
+
+	// Штатная остановка публикует offline: завещание при отключении не срабатывает
+	recorder.published = nil
+	client.Stop()
+	if len(recorder.published) != 1 || recorder.published[0].payload != "offline" || !recorder.published[0].retained {
+		t.Errorf("Expected retained offline on stop, got %+v", recorder.published)
+	}
+}
+
+func (r *recordingClient) Subscribe(topic string, qos byte, callback mqttLib.MessageHandler) mqttLib.Token {
+	return &mqttLib.DummyToken{}
+}
+
+func TestOnConnectPublishesBirth(t *testing.T) {
+	recorder := &recordingClient{}
+	client := NewClient(DefaultConfig(), nil, nil, nil, nil)
+	client.SetVIN("WF0XXXTTGXAB12345")
+	client.mqttClient = recorder
+	client.SetBirthHandler(func() common.StatusEvent {
+		return common.StatusEvent{Kind: "birth", Data: map[string]string{"version": "1.4.0"}, Retained: true}
+	})
+
+	client.onConnectHandler(recorder)
+	client.Stop()
+
+	for _, message := range recorder.published {
+		if message.topic != "car/bridge/WF0XXXTTGXAB12345/birth" {
+			continue
+		}
+		if !message.retained || !strings.Contains(string(message.payload.([]byte)), `"version":"1.4.0"`) {
+			t.Errorf("Unexpected birth message %+v", message)
+		}
+		return
+	}
+	t.Errorf("Expected birth message on connect, got %+v", recorder.published)
+}
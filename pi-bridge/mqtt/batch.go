@@ -0,0 +1,175 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// defaultBatchWindow - наибольшее время накопления по умолчанию, равное интервалу опроса
+const defaultBatchWindow = 5 * time.Second
+
+// BatchConfig задает объединение метрик в один документ car/telemetry/{VIN}/state.
+// Документ публикуется, когда метрика приходит повторно (начался следующий цикл опроса)
+// или истекло окно накопления, вместо десятка сообщений на каждый цикл
+type BatchConfig struct {
+	Enabled bool          `yaml:"enabled"` // Публиковать метрики одним документом state
+	Window  time.Duration `yaml:"window"`  // Наибольшее время накопления (0 - 5s)
+}
+
+// Validate проверяет окно накопления
+func (c BatchConfig) Validate() error {
+	if c.Window < 0 {
+		return fmt.Errorf("mqtt batch window must not be negative, got %v", c.Window)
+	}
+	return nil
+}
+
+// StateMessage - документ со всеми метриками одного цикла опроса
+type StateMessage struct {
+	VIN       string                 `json:"vin"`
+	Timestamp time.Time              `json:"timestamp"` // Время последней метрики
+	Metrics   map[string]StateMetric `json:"metrics"`   // По названию метрики или "метрика/ЭБУ" при включенных заголовках
+}
+
+// StateMetric - значение метрики в документе state
+type StateMetric struct {
+	PID         string    `json:"pid"`
+	Value       float64   `json:"value"`
+	Unit        string    `json:"unit"`
+	Timestamp   time.Time `json:"timestamp"`
+	ECU         string    `json:"ecu,omitempty"`
+	Implausible bool      `json:"implausible,omitempty"`
+	Derived     bool      `json:"derived,omitempty"`
+}
+
+// telemetryBatch накапливает метрики одного цикла опроса. Используется только
+// горутиной публикации телеметрии
+type telemetryBatch struct {
+	window   time.Duration
+	messages []*TelemetryMessage
+	keys     map[string]bool
+}
+
+// newTelemetryBatch создает накопитель; при выключенном объединении возвращает nil
+func newTelemetryBatch(config BatchConfig) *telemetryBatch {
+	if !config.Enabled {
+		return nil
+	}
+	window := config.Window
+	if window == 0 {
+		window = defaultBatchWindow
+	}
+	return &telemetryBatch{window: window, keys: make(map[string]bool)}
+}
+
+// batched проверяет, что сообщение попадает в документ. Отсчеты потокового режима
+// и сырые данные PID без декодера публикуются в свои топики
+func (b *telemetryBatch) batched(msg *TelemetryMessage) bool {
+	return b != nil && !msg.HighRate && msg.Data == ""
+}
+
+// Add добавляет метрику. Повтор метрики означает начало следующего цикла: накопленные
+// сообщения возвращаются для публикации, а метрика начинает новый документ.
+// started - метрика первая в документе (нужно запустить окно накопления)
+func (b *telemetryBatch) Add(msg *TelemetryMessage) (complete []*TelemetryMessage, started bool) {
+	key := stateKey(msg)
+	if b.keys[key] {
+		complete = b.Take()
+	}
+	b.messages = append(b.messages, msg)
+	b.keys[key] = true
+	return complete, len(b.messages) == 1
+}
+
+// Take возвращает накопленные сообщения и начинает новый документ
+func (b *telemetryBatch) Take() []*TelemetryMessage {
+	messages := b.messages
+	b.messages = nil
+	b.keys = make(map[string]bool)
+	return messages
+}
+
+// stateKey возвращает ключ метрики в документе state
+func stateKey(msg *TelemetryMessage) string {
+	if msg.ECU != "" {
+		return msg.Metric + "/" + msg.ECU
+	}
+	return msg.Metric
+}
+
+// newStateMessage формирует документ state из сообщений одного цикла
+func newStateMessage(messages []*TelemetryMessage) StateMessage {
+	state := StateMessage{Metrics: make(map[string]StateMetric, len(messages))}
+	for _, msg := range messages {
+		state.VIN = msg.VIN
+		if msg.Timestamp.After(state.Timestamp) {
+			state.Timestamp = msg.Timestamp
+		}
+		state.Metrics[stateKey(msg)] = StateMetric{
+			PID:         msg.PID,
+			Value:       msg.Value,
+			Unit:        msg.Unit,
+			Timestamp:   msg.Timestamp,
+			ECU:         msg.ECU,
+			Implausible: msg.Implausible,
+			Derived:     msg.Derived,
+		}
+	}
+	return state
+}
+
+// publishState публикует документ state retained сообщением: новый подписчик сразу
+// получает последнее состояние автомобиля
+func (c *Client) publishState(messages []*TelemetryMessage) error {
+	if c.mqttClient == nil || !c.mqttClient.IsConnected() {
+		return fmt.Errorf("MQTT client not connected")
+	}
+
+	payload, err := json.Marshal(newStateMessage(messages))
+	if err != nil {
+		return fmt.Errorf("failed to marshal state message: %v", err)
+	}
+
+	topic := fmt.Sprintf("%s/%s/state", c.config.DataTopic, c.vin)
+	token := c.mqttClient.Publish(topic, c.config.ClassQoS.Telemetry, true, payload)
+	token.Wait()
+	if token.Error() != nil {
+		return fmt.Errorf("failed to publish to topic %s: %v", topic, token.Error())
+	}
+
+	c.logger.Printf("Published state with %d metrics to %s", len(messages), topic)
+	return nil
+}
+
+// deliverState публикует документ state. Без связи с брокером метрики документа
+// сохраняются в очередь и после переподключения публикуются по отдельности
+func (c *Client) deliverState(messages []*TelemetryMessage) {
+	if len(messages) == 0 {
+		return
+	}
+
+	if c.pendingTelemetry() && !c.flushBuffer() {
+		c.bufferState(messages)
+		return
+	}
+
+	if err := c.publishState(messages); err != nil {
+		if c.buffer == nil {
+			c.logger.Printf("Failed to publish state: %v", err)
+			return
+		}
+		c.bufferState(messages)
+		return
+	}
+	for _, msg := range messages {
+		c.dedup.Published(msg, msg.Timestamp)
+	}
+}
+
+// bufferState сохраняет метрики неопубликованного документа в очередь
+func (c *Client) bufferState(messages []*TelemetryMessage) {
+	for _, msg := range messages {
+		c.bufferTelemetry(msg)
+	}
+}
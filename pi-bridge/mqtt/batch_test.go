@@ -0,0 +1,108 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+func TestTelemetryBatch(t *testing.T) {
+	if batch := newTelemetryBatch(BatchConfig{}); batch != nil || batch.batched(&TelemetryMessage{Metric: "engine_rpm"}) {
+		t.Fatal("Expected disabled batch")
+	}
+
+	batch := newTelemetryBatch(BatchConfig{Enabled: true})
+	if batch.window != defaultBatchWindow {
+		t.Errorf("Expected default window %v, got %v", defaultBatchWindow, batch.window)
+	}
+	if batch.batched(&TelemetryMessage{Metric: "engine_rpm", HighRate: true}) || batch.batched(&TelemetryMessage{Metric: "unknown_FF", Data: "12"}) {
+		t.Error("Expected stream samples and raw data to be published separately")
+	}
+
+	steps := []struct {
+		msg      TelemetryMessage
+		complete int
+		started  bool
+	}{
+		{TelemetryMessage{Metric: "engine_rpm", ECU: "7E8"}, 0, true},
+		{TelemetryMessage{Metric: "engine_rpm", ECU: "7E9"}, 0, false},
+		{TelemetryMessage{Metric: "vehicle_speed"}, 0, false},
+		// Повтор метрики - начало следующего цикла опроса
+		{TelemetryMessage{Metric: "engine_rpm", ECU: "7E8"}, 3, true},
+		{TelemetryMessage{Metric: "vehicle_speed"}, 0, false},
+	}
+	for i, step := range steps {
+		complete, started := batch.Add(&step.msg)
+		if len(complete) != step.complete || started != step.started {
+			t.Errorf("Step %d: expected %d complete (started %v), got %d (started %v)", i, step.complete, step.started, len(complete), started)
+		}
+	}
+	if rest := batch.Take(); len(rest) != 2 {
+		t.Errorf("Expected 2 pending messages, got %d", len(rest))
+	}
+}
+
+func TestNewStateMessage(t *testing.T) {
+	start := time.Date(2025, 10, 8, 0, 28, 56, 0, time.UTC)
+	state := newStateMessage([]*TelemetryMessage{
+		{VIN: "TEST123", PID: "0C", Metric: "engine_rpm", Value: 1724, Unit: "rpm", Timestamp: start, ECU: "7E8"},
+		{VIN: "TEST123", PID: "0D", Metric: "vehicle_speed", Value: 60, Unit: "km/h", Timestamp: start.Add(time.Second), Implausible: true},
+	})
+
+	if state.VIN != "TEST123" || !state.Timestamp.Equal(start.Add(time.Second)) || len(state.Metrics) != 2 {
+		t.Fatalf("Unexpected state %+v", state)
+	}
+	if rpm := state.Metrics["engine_rpm/7E8"]; rpm.Value != 1724 || rpm.ECU != "7E8" || rpm.PID != "0C" {
+		t.Errorf("Unexpected engine_rpm %+v", rpm)
+	}
+	if speed := state.Metrics["vehicle_speed"]; speed.Value != 60 || !speed.Implausible || speed.Unit != "km/h" {
+		t.Errorf("Unexpected vehicle_speed %+v", speed)
+	}
+}
+
+func TestPublishTelemetryBatched(t *testing.T) {
+	telemetryChan := make(chan common.Telemetry)
+	config := DefaultConfig()
+	config.Batch = BatchConfig{Enabled: true, Window: 50 * time.Millisecond}
+	recorder := &recordingClient{}
+	client := NewClient(config, telemetryChan, nil, nil, nil)
+	client.SetVIN("TEST123")
+	client.mqttClient = recorder
+
+	client.wg.Add(1)
+	go client.publishTelemetryLoop()
+
+	// Повтор оборотов завершает первый документ, второй публикуется по окну накопления
+	telemetryChan <- common.Telemetry{PID: "0C", Metric: "engine_rpm", Value: 1724, Unit: "rpm"}
+	telemetryChan <- common.Telemetry{PID: "0D", Metric: "vehicle_speed", Value: 60, Unit: "km/h"}
+	telemetryChan <- common.Telemetry{PID: "0C", Metric: "engine_rpm", Value: 1800, Unit: "rpm"}
+	telemetryChan <- common.Telemetry{PID: "0C", Metric: "engine_rpm", Value: 900, Unit: "rpm", HighRate: true}
+	time.Sleep(200 * time.Millisecond)
+
+	client.cancel()
+	client.wg.Wait()
+
+	var states []StateMessage
+	for _, message := range recorder.published {
+		if message.topic == "car/telemetry/TEST123/stream/engine_rpm" {
+			continue
+		}
+		var state StateMessage
+		if message.topic != "car/telemetry/TEST123/state" || !message.retained || json.Unmarshal(message.payload.([]byte), &state) != nil {
+			t.Fatalf("Unexpected message %s %s", message.topic, message.payload)
+		}
+		states = append(states, state)
+	}
+
+	if len(states) != 2 {
+		t.Fatalf("Expected 2 state documents, got %d", len(states))
+	}
+	if len(states[0].Metrics) != 2 || states[0].Metrics["engine_rpm"].Value != 1724 || states[0].Metrics["vehicle_speed"].Value != 60 {
+		t.Errorf("Unexpected first state %+v", states[0])
+	}
+	if len(states[1].Metrics) != 1 || states[1].Metrics["engine_rpm"].Value != 1800 || states[1].VIN != "TEST123" {
+		t.Errorf("Unexpected second state %+v", states[1])
+	}
+}
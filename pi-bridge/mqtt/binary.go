@@ -0,0 +1,167 @@
+package mqtt
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// Двоичные форматы телеметрии сокращают мобильный трафик постоянно подключенных
+// автомобилей: сообщение занимает примерно вдвое меньше JSON. Оба формата описаны схемой
+// proto/telemetry.proto; кодировщики написаны без зависимостей
+
+// Типы полей protobuf (wire type)
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+)
+
+// protobufTelemetry кодирует сообщение по схеме proto/telemetry.proto
+func protobufTelemetry(msg *TelemetryMessage) []byte {
+	buf := make([]byte, 0, 96)
+	buf = appendProtoString(buf, 1, msg.VIN)
+	buf = appendProtoString(buf, 2, msg.PID)
+	buf = appendProtoString(buf, 3, msg.Metric)
+	if msg.Value != 0 {
+		buf = appendProtoTag(buf, 4, protoFixed64)
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(msg.Value))
+	}
+	buf = appendProtoString(buf, 5, msg.Unit)
+	if !msg.Timestamp.IsZero() {
+		buf = appendProtoTag(buf, 6, protoVarint)
+		buf = binary.AppendUvarint(buf, uint64(msg.Timestamp.UnixNano()))
+	}
+	buf = appendProtoString(buf, 7, msg.Raw)
+	buf = appendProtoBool(buf, 8, msg.HighRate)
+	buf = appendProtoString(buf, 9, msg.ECU)
+	buf = appendProtoString(buf, 10, msg.Data)
+	buf = appendProtoBool(buf, 11, msg.Implausible)
+	buf = appendProtoBool(buf, 12, msg.Derived)
+	return buf
+}
+
+// appendProtoTag добавляет ключ поля: номер и тип
+func appendProtoTag(buf []byte, field int, wireType byte) []byte {
+	return binary.AppendUvarint(buf, uint64(field)<<3|uint64(wireType))
+}
+
+// appendProtoString добавляет непустую строку
+func appendProtoString(buf []byte, field int, value string) []byte {
+	if value == "" {
+		return buf
+	}
+	buf = appendProtoTag(buf, field, protoBytes)
+	buf = binary.AppendUvarint(buf, uint64(len(value)))
+	return append(buf, value...)
+}
+
+// appendProtoBool добавляет поле true (false в proto3 не передается)
+func appendProtoBool(buf []byte, field int, value bool) []byte {
+	if !value {
+		return buf
+	}
+	buf = appendProtoTag(buf, field, protoVarint)
+	return append(buf, 1)
+}
+
+// Основные типы CBOR (RFC 8949)
+const (
+	cborUnsigned = 0 << 5
+	cborText     = 3 << 5
+	cborMap      = 5 << 5
+	cborTag      = 6 << 5
+	cborSimple   = 7 << 5
+)
+
+// cborTelemetry кодирует сообщение картой CBOR. Ключи - номера полей из
+// proto/telemetry.proto, пустые значения не передаются. Время передается тегом 1
+// (секунды Unix дробным числом), числа - кратчайшим точным представлением
+func cborTelemetry(msg *TelemetryMessage) []byte {
+	type entry struct {
+		key    uint64
+		encode func([]byte) []byte
+	}
+	text := func(value string) func([]byte) []byte {
+		return func(buf []byte) []byte { return appendCBORText(buf, value) }
+	}
+	boolean := func(buf []byte) []byte { return append(buf, cborSimple|21) }
+
+	var entries []entry
+	for _, field := range []struct {
+		key   uint64
+		value string
+	}{{1, msg.VIN}, {2, msg.PID}, {3, msg.Metric}} {
+		if field.value != "" {
+			entries = append(entries, entry{field.key, text(field.value)})
+		}
+	}
+	if msg.Value != 0 {
+		entries = append(entries, entry{4, func(buf []byte) []byte { return appendCBORFloat(buf, msg.Value) }})
+	}
+	if msg.Unit != "" {
+		entries = append(entries, entry{5, text(msg.Unit)})
+	}
+	if !msg.Timestamp.IsZero() {
+		entries = append(entries, entry{6, func(buf []byte) []byte {
+			buf = appendCBORHead(buf, cborTag, 1)
+			return appendCBORFloat(buf, float64(msg.Timestamp.UnixNano())/1e9)
+		}})
+	}
+	if msg.Raw != "" {
+		entries = append(entries, entry{7, text(msg.Raw)})
+	}
+	if msg.HighRate {
+		entries = append(entries, entry{8, boolean})
+	}
+	if msg.ECU != "" {
+		entries = append(entries, entry{9, text(msg.ECU)})
+	}
+	if msg.Data != "" {
+		entries = append(entries, entry{10, text(msg.Data)})
+	}
+	if msg.Implausible {
+		entries = append(entries, entry{11, boolean})
+	}
+	if msg.Derived {
+		entries = append(entries, entry{12, boolean})
+	}
+
+	buf := make([]byte, 0, 96)
+	buf = appendCBORHead(buf, cborMap, uint64(len(entries)))
+	for _, e := range entries {
+		buf = appendCBORHead(buf, cborUnsigned, e.key)
+		buf = e.encode(buf)
+	}
+	return buf
+}
+
+// appendCBORHead добавляет заголовок элемента: основной тип и аргумент
+func appendCBORHead(buf []byte, major byte, argument uint64) []byte {
+	switch {
+	case argument < 24:
+		return append(buf, major|byte(argument))
+	case argument <= math.MaxUint8:
+		return append(buf, major|24, byte(argument))
+	case argument <= math.MaxUint16:
+		return binary.BigEndian.AppendUint16(append(buf, major|25), uint16(argument))
+	case argument <= math.MaxUint32:
+		return binary.BigEndian.AppendUint32(append(buf, major|26), uint32(argument))
+	default:
+		return binary.BigEndian.AppendUint64(append(buf, major|27), argument)
+	}
+}
+
+// appendCBORText добавляет строку UTF-8
+func appendCBORText(buf []byte, value string) []byte {
+	buf = appendCBORHead(buf, cborText, uint64(len(value)))
+	return append(buf, value...)
+}
+
+// appendCBORFloat добавляет число с плавающей точкой: float32, если оно представимо
+// без потери точности (обороты, скорость, проценты), иначе float64
+func appendCBORFloat(buf []byte, value float64) []byte {
+	if single := float32(value); float64(single) == value || math.IsNaN(value) {
+		return binary.BigEndian.AppendUint32(append(buf, cborSimple|26), math.Float32bits(single))
+	}
+	return binary.BigEndian.AppendUint64(append(buf, cborSimple|27), math.Float64bits(value))
+}
@@ -0,0 +1,96 @@
+package mqtt
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestProtobufTelemetry(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  TelemetryMessage
+		want string
+	}{
+		{
+			name: "metric",
+			msg:  TelemetryMessage{VIN: "V", PID: "0C", Metric: "x", Value: 1.5, Timestamp: time.Unix(0, 300), Derived: true},
+			want: "0a0156" + "12023043" + "1a0178" + "21000000000000f83f" + "30ac02" + "6001",
+		},
+		{
+			// Нулевые значения в proto3 не передаются
+			name: "zero value",
+			msg:  TelemetryMessage{Metric: "x", Unit: "%", Implausible: true},
+			want: "1a0178" + "2a0125" + "5801",
+		},
+		{
+			name: "raw data",
+			msg:  TelemetryMessage{Metric: "x", Raw: "41 A5", HighRate: true, ECU: "7E8", Data: "A5"},
+			want: "1a0178" + "3a053431204135" + "4001" + "4a03374538" + "52024135",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hex.EncodeToString(protobufTelemetry(&tt.msg)); got != tt.want {
+				t.Errorf("protobufTelemetry() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCBORTelemetry(t *testing.T) {
+	tests := []struct {
+		name string
+		msg  TelemetryMessage
+		want string
+	}{
+		{
+			name: "metric",
+			msg:  TelemetryMessage{VIN: "V", PID: "0C", Metric: "x", Value: 1.5, Unit: "%", Timestamp: time.Unix(2, 0)},
+			want: "a6" + "016156" + "02623043" + "036178" + "04fa3fc00000" + "056125" + "06c1fa40000000",
+		},
+		{
+			// Значение без точного float32 передается float64, флаги - простым значением true
+			name: "double and flags",
+			msg:  TelemetryMessage{Metric: "x", Value: 0.1, HighRate: true, ECU: "7E8", Implausible: true, Derived: true},
+			want: "a6" + "036178" + "04fb3fb999999999999a" + "08f5" + "0963374538" + "0bf5" + "0cf5",
+		},
+		{
+			name: "raw data",
+			msg:  TelemetryMessage{Metric: "x", Raw: "41 A5", Data: "A5"},
+			want: "a3" + "036178" + "07653431204135" + "0a624135",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hex.EncodeToString(cborTelemetry(&tt.msg)); got != tt.want {
+				t.Errorf("cborTelemetry() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBinaryPayloadSize(t *testing.T) {
+	msg := &TelemetryMessage{
+		VIN:       "WF0XXXTTGXAB12345",
+		PID:       "0C",
+		Metric:    "engine_rpm",
+		Value:     1724,
+		Unit:      "rpm",
+		Timestamp: time.Date(2025, 10, 8, 0, 28, 56, 123456789, time.UTC),
+		Raw:       "41 0C 1A F0",
+	}
+	jsonPayload, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for name, payload := range map[string][]byte{"protobuf": protobufTelemetry(msg), "cbor": cborTelemetry(msg)} {
+		if len(payload)*2 > len(jsonPayload) {
+			t.Errorf("%s payload of %d bytes is not much smaller than JSON of %d bytes", name, len(payload), len(jsonPayload))
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package mqtt
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// brokerSchemes - схемы адреса брокера, поддерживаемые клиентом paho
+var brokerSchemes = map[string]bool{
+	"tcp":   true,
+	"mqtt":  true,
+	"ssl":   true,
+	"tls":   true,
+	"mqtts": true,
+	"ws":    true, // MQTT поверх WebSocket
+	"wss":   true, // MQTT поверх WebSocket с TLS, обычно порт 443
+}
+
+// ValidateBroker проверяет адрес брокера и заголовки подключения через WebSocket
+func (c Config) ValidateBroker() error {
+	if c.Broker == "" {
+		return fmt.Errorf("MQTT broker address must be set in config.yaml")
+	}
+	broker, err := url.Parse(c.Broker)
+	if err != nil {
+		return fmt.Errorf("invalid MQTT broker %q: %v", c.Broker, err)
+	}
+	if !brokerSchemes[broker.Scheme] || broker.Host == "" {
+		return fmt.Errorf("invalid MQTT broker %q: expected tcp://, ssl://, ws:// or wss:// with host", c.Broker)
+	}
+
+	if len(c.Headers) > 0 && !c.webSocket() {
+		return fmt.Errorf("mqtt.headers require ws:// or wss:// broker, got %q", c.Broker)
+	}
+	for name, value := range c.Headers {
+		if name == "" || strings.ContainsAny(name, " :\r\n") {
+			return fmt.Errorf("invalid MQTT header name %q", name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("invalid value of MQTT header %s", name)
+		}
+	}
+	return nil
+}
+
+// webSocket проверяет, что брокер доступен через WebSocket
+func (c Config) webSocket() bool {
+	broker, err := url.Parse(c.Broker)
+	return err == nil && (broker.Scheme == "ws" || broker.Scheme == "wss")
+}
+
+// httpHeaders возвращает заголовки запроса WebSocket (nil - без дополнительных заголовков).
+// Имена приводятся к каноническому виду: viper переводит ключи конфигурации в нижний регистр
+func (c Config) httpHeaders() http.Header {
+	if len(c.Headers) == 0 {
+		return nil
+	}
+	headers := make(http.Header, len(c.Headers))
+	for name, value := range c.Headers {
+		headers.Set(name, value)
+	}
+	return headers
+}
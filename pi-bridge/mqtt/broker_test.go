@@ -0,0 +1,86 @@
+package mqtt
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+func TestValidateBroker(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"tcp", Config{Broker: "tcp://localhost:1883"}, false},
+		{"tls", Config{Broker: "ssl://broker.example.com:8883"}, false},
+		{"websocket", Config{Broker: "ws://broker.example.com:80/mqtt"}, false},
+		{"secure websocket", Config{Broker: "wss://broker.example.com:443/mqtt", Headers: map[string]string{"authorization": "Bearer token"}}, false},
+		{"websocket without path", Config{Broker: "wss://broker.example.com"}, false},
+		{"empty", Config{}, true},
+		{"no scheme", Config{Broker: "localhost:1883"}, true},
+		{"unsupported scheme", Config{Broker: "http://broker.example.com"}, true},
+		{"no host", Config{Broker: "wss:///mqtt"}, true},
+		{"headers without websocket", Config{Broker: "tcp://localhost:1883", Headers: map[string]string{"x-token": "1"}}, true},
+		{"invalid header name", Config{Broker: "wss://broker.example.com", Headers: map[string]string{"x token": "1"}}, true},
+		{"invalid header value", Config{Broker: "wss://broker.example.com", Headers: map[string]string{"x-token": "1\r\nx-other: 2"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.ValidateBroker(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateBroker() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClientWebSocketHeaders(t *testing.T) {
+	// Брокер отклоняет подключение, но запрос WebSocket уже содержит путь и заголовки
+	type upgrade struct {
+		path          string
+		authorization string
+		protocol      string
+	}
+	requests := make(chan upgrade, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case requests <- upgrade{r.URL.Path, r.Header.Get("Authorization"), r.Header.Get("Sec-WebSocket-Protocol")}:
+		default:
+		}
+		http.Error(w, "forbidden", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	config := DefaultConfig()
+	config.Broker = "ws://" + strings.TrimPrefix(server.URL, "http://") + "/mqtt"
+	config.Headers = map[string]string{"authorization": "Bearer token"}
+	config.ConnectTimeout = 2 * time.Second
+	config.AutoReconnect = false
+	client := NewClient(config, make(chan common.Telemetry), make(chan string), make(chan common.CommandResponse), make(chan common.StatusEvent))
+
+	if err := client.Start(context.Background()); err == nil {
+		client.Stop()
+		t.Fatal("Expected connection to be rejected")
+	}
+
+	select {
+	case request := <-requests:
+		if request.path != "/mqtt" {
+			t.Errorf("Expected path /mqtt, got %q", request.path)
+		}
+		if request.authorization != "Bearer token" {
+			t.Errorf("Expected Authorization header, got %q", request.authorization)
+		}
+		if request.protocol != "mqtt" {
+			t.Errorf("Expected mqtt subprotocol, got %q", request.protocol)
+		}
+	default:
+		t.Fatal("Expected WebSocket upgrade request")
+	}
+}
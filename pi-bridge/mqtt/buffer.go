@@ -0,0 +1,216 @@
+package mqtt
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+)
+
+// BufferConfig задает очередь телеметрии на время потери связи с брокером. Сообщения
+// хранятся в памяти и публикуются после переподключения в порядке получения, поэтому
+// короткие обрывы мобильной сети в поездке не приводят к потере данных. С каталогом path
+// очередь хранится на диске и переживает долгое отсутствие связи и перезапуск моста
+type BufferConfig struct {
+	Size      int    `yaml:"size"`        // Количество сообщений в памяти (0 - не сохранять); при переполнении отбрасываются самые старые
+	Path      string `yaml:"path"`        // Каталог очереди на диске (пусто - очередь в памяти)
+	MaxSizeMB int    `yaml:"max_size_mb"` // Размер очереди на диске в МБ (0 - 100)
+}
+
+// Validate проверяет размер очереди
+func (c BufferConfig) Validate() error {
+	if c.Size < 0 {
+		return fmt.Errorf("mqtt buffer size must not be negative, got %d", c.Size)
+	}
+	if c.MaxSizeMB < 0 {
+		return fmt.Errorf("mqtt buffer max_size_mb must not be negative, got %d", c.MaxSizeMB)
+	}
+	return nil
+}
+
+// telemetryQueue - очередь FIFO неопубликованной телеметрии в памяти или на диске
+type telemetryQueue interface {
+	Push(msg *TelemetryMessage) bool // Добавить в конец, false - сообщение не сохранено
+	Peek() *TelemetryMessage         // Самое старое сообщение (nil - очередь пуста)
+	Pop()                            // Удалить самое старое сообщение после публикации
+	Len() int
+	TakeDropped() int // Отброшено при переполнении с прошлого вызова
+	Close() error
+}
+
+// telemetryBuffer - кольцевая очередь FIFO неопубликованных сообщений телеметрии
+type telemetryBuffer struct {
+	mu       sync.Mutex
+	messages []*TelemetryMessage
+	head     int // Индекс самого старого сообщения
+	count    int
+	dropped  int // Отброшено при переполнении с последнего опустошения очереди
+}
+
+// newTelemetryBuffer создает очередь; при нулевом размере возвращает nil (очередь выключена)
+func newTelemetryBuffer(config BufferConfig) *telemetryBuffer {
+	if config.Size <= 0 {
+		return nil
+	}
+	return &telemetryBuffer{messages: make([]*TelemetryMessage, config.Size)}
+}
+
+// Push добавляет сообщение в конец очереди, вытесняя самое старое при переполнении.
+// Возвращает false, если очередь выключена
+func (b *telemetryBuffer) Push(msg *TelemetryMessage) bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.count == len(b.messages) {
+		b.head = (b.head + 1) % len(b.messages)
+		b.count--
+		b.dropped++
+	}
+	b.messages[(b.head+b.count)%len(b.messages)] = msg
+	b.count++
+	return true
+}
+
+// Peek возвращает самое старое сообщение, не удаляя его (nil - очередь пуста)
+func (b *telemetryBuffer) Peek() *TelemetryMessage {
+	if b == nil {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.count == 0 {
+		return nil
+	}
+	return b.messages[b.head]
+}
+
+// Pop удаляет самое старое сообщение после успешной публикации
+func (b *telemetryBuffer) Pop() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.count == 0 {
+		return
+	}
+	b.messages[b.head] = nil
+	b.head = (b.head + 1) % len(b.messages)
+	b.count--
+}
+
+// Len возвращает количество сообщений в очереди
+func (b *telemetryBuffer) Len() int {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.count
+}
+
+// TakeDropped возвращает количество отброшенных при переполнении сообщений и сбрасывает счетчик
+func (b *telemetryBuffer) TakeDropped() int {
+	if b == nil {
+		return 0
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	dropped := b.dropped
+	b.dropped = 0
+	return dropped
+}
+
+// Close ничего не делает: очередь в памяти не переживает перезапуск
+func (b *telemetryBuffer) Close() error {
+	return nil
+}
+
+// openBuffer открывает очередь на диске, если задан каталог. Очереди автомобилей
+// хранятся в подкаталогах по VIN
+func (c *Client) openBuffer() error {
+	if c.config.Buffer.Path == "" {
+		return nil
+	}
+
+	dir := c.config.Buffer.Path
+	if c.vin != "" {
+		dir = filepath.Join(dir, c.vin)
+	}
+	buffer, err := openDiskBuffer(dir, c.config.Buffer.MaxSizeMB, c.logger)
+	if err != nil {
+		return fmt.Errorf("failed to open telemetry buffer %s: %w", dir, err)
+	}
+
+	c.buffer = buffer
+	if pending := buffer.Len(); pending > 0 {
+		c.logger.Printf("Telemetry buffer %s holds %d messages to replay", dir, pending)
+	}
+	return nil
+}
+
+// closeBuffer закрывает очередь на диске, сохраняя позицию чтения: неопубликованные
+// сообщения будут опубликованы после следующего запуска
+func (c *Client) closeBuffer() {
+	if c.buffer == nil {
+		return
+	}
+	if err := c.buffer.Close(); err != nil {
+		c.logger.Printf("Failed to close telemetry buffer: %v", err)
+	}
+}
+
+// bufferTelemetry сохраняет неопубликованное сообщение до переподключения к брокеру
+func (c *Client) bufferTelemetry(msg *TelemetryMessage) bool {
+	if c.buffer == nil || !c.buffer.Push(msg) {
+		return false
+	}
+	if c.buffer.Len() == 1 {
+		c.logger.Println("Broker unreachable, buffering telemetry")
+	}
+	return true
+}
+
+// pendingTelemetry проверяет, что в очереди есть неопубликованные сообщения
+func (c *Client) pendingTelemetry() bool {
+	return c.buffer != nil && c.buffer.Len() > 0
+}
+
+// flushBuffer публикует сохраненные сообщения в порядке получения. Возвращает false,
+// если часть сообщений осталась в очереди (связь с брокером снова потеряна)
+func (c *Client) flushBuffer() bool {
+	c.flushMu.Lock()
+	defer c.flushMu.Unlock()
+
+	if !c.pendingTelemetry() {
+		return true
+	}
+	if !c.IsConnected() {
+		return false
+	}
+
+	replayed := 0
+	for msg := c.buffer.Peek(); msg != nil; msg = c.buffer.Peek() {
+		if err := c.publishTelemetry(msg); err != nil {
+			c.logger.Printf("Failed to replay buffered telemetry (%d left): %v", c.buffer.Len(), err)
+			return false
+		}
+		c.buffer.Pop()
+		replayed++
+	}
+
+	if replayed > 0 {
+		c.logger.Printf("Replayed %d buffered telemetry messages (%d dropped while broker was unreachable)", replayed, c.buffer.TakeDropped())
+	}
+	return true
+}
@@ -0,0 +1,121 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"elm327-bridge/common"
+)
+
+func TestBufferConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  BufferConfig
+		wantErr bool
+	}{
+		{"disabled", BufferConfig{}, false},
+		{"valid", BufferConfig{Size: 1000}, false},
+		{"negative size", BufferConfig{Size: -1}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestTelemetryBuffer(t *testing.T) {
+	if buffer := newTelemetryBuffer(BufferConfig{}); buffer != nil || buffer.Push(&TelemetryMessage{}) || buffer.Len() != 0 {
+		t.Fatal("Expected disabled buffer to keep nothing")
+	}
+
+	buffer := newTelemetryBuffer(BufferConfig{Size: 3})
+	for i := 1; i <= 5; i++ {
+		buffer.Push(&TelemetryMessage{Value: float64(i)})
+	}
+	if buffer.Len() != 3 {
+		t.Fatalf("Expected 3 messages, got %d", buffer.Len())
+	}
+
+	// При переполнении вытесняются самые старые сообщения
+	for _, expected := range []float64{3, 4, 5} {
+		msg := buffer.Peek()
+		if msg == nil || msg.Value != expected {
+			t.Fatalf("Expected message %v, got %+v", expected, msg)
+		}
+		buffer.Pop()
+	}
+	if buffer.Peek() != nil || buffer.Len() != 0 {
+		t.Error("Expected empty buffer")
+	}
+	if dropped := buffer.TakeDropped(); dropped != 2 {
+		t.Errorf("Expected 2 dropped messages, got %d", dropped)
+	}
+	if dropped := buffer.TakeDropped(); dropped != 0 {
+		t.Errorf("Expected dropped counter to be reset, got %d", dropped)
+	}
+}
+
+func TestTelemetryReplayedInOrder(t *testing.T) {
+	telemetryChan := make(chan common.Telemetry)
+	recorder := &recordingClient{}
+	recorder.offline.Store(true)
+	client := NewClient(DefaultConfig(), telemetryChan, nil, nil, nil)
+	client.SetVIN("TEST123")
+	client.mqttClient = recorder
+
+	client.wg.Add(1)
+	go client.publishTelemetryLoop()
+
+	// Без связи с брокером сообщения сохраняются, после переподключения публикуются
+	// раньше новых в порядке получения
+	for i := 1; i <= 3; i++ {
+		telemetryChan <- common.Telemetry{Metric: "vehicle_speed", Value: float64(i), Unit: "km/h"}
+	}
+	recorder.offline.Store(false)
+	telemetryChan <- common.Telemetry{Metric: "vehicle_speed", Value: 4, Unit: "km/h"}
+
+	client.cancel()
+	client.wg.Wait()
+
+	var values []float64
+	for _, message := range recorder.published {
+		var msg TelemetryMessage
+		if err := json.Unmarshal(message.payload.([]byte), &msg); err != nil {
+			t.Fatalf("Unexpected payload: %v", err)
+		}
+		values = append(values, msg.Value)
+	}
+	if len(values) != 4 || values[0] != 1 || values[1] != 2 || values[2] != 3 || values[3] != 4 {
+		t.Errorf("Expected values 1 2 3 4 in order, got %v", values)
+	}
+	if client.buffer.Len() != 0 {
+		t.Errorf("Expected empty buffer after replay, got %d", client.buffer.Len())
+	}
+}
+
+func TestOpenDiskBufferPerVehicle(t *testing.T) {
+	config := DefaultConfig()
+	config.Buffer.Path = t.TempDir()
+	client := NewClient(config, nil, nil, nil, nil)
+	if client.buffer != nil {
+		t.Fatal("Expected disk buffer to be opened on start")
+	}
+	client.SetVIN("TEST123")
+
+	if err := client.openBuffer(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer client.closeBuffer()
+	if _, ok := client.buffer.(*diskBuffer); !ok {
+		t.Fatalf("Expected disk buffer, got %T", client.buffer)
+	}
+	if _, err := os.Stat(filepath.Join(config.Buffer.Path, "TEST123")); err != nil {
+		t.Errorf("Expected buffer directory of vehicle: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"elm327-bridge/common"
+)
+
+// canQueueSize - количество кадров CAN, ожидающих публикации. При прослушивании
+// загруженной шины очередь переполняется, и лишние кадры отбрасываются
+const canQueueSize = 500
+
+// PublishCANFrame ставит кадр CAN в очередь публикации в топик <can_topic>/<VIN>/<ID>.
+// Вызывается из цикла чтения адаптера, поэтому не блокируется
+func (c *Client) PublishCANFrame(frame common.CANFrame) {
+	select {
+	case c.canChan <- frame:
+	default:
+		c.logger.Printf("Warning: CAN queue is full, dropping frame %s", frame.ID)
+	}
+}
+
+// publishCANLoop публикует кадры CAN, полученные при прослушивании шины
+func (c *Client) publishCANLoop() {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case frame := <-c.canChan:
+			if err := c.publishCANFrame(frame); err != nil {
+				c.logger.Printf("Failed to publish CAN frame: %v", err)
+			}
+		}
+	}
+}
+
+// publishCANFrame публикует один кадр CAN
+func (c *Client) publishCANFrame(frame common.CANFrame) error {
+	if c.mqttClient == nil || !c.mqttClient.IsConnected() {
+		return fmt.Errorf("MQTT client not connected")
+	}
+
+	payload, err := json.Marshal(frame)
+	if err != nil {
+		return fmt.Errorf("failed to marshal CAN frame: %v", err)
+	}
+
+	topic := c.canTopic(frame.ID)
+	token := c.mqttClient.Publish(topic, c.config.QoS, false, payload)
+	token.Wait()
+
+	if token.Error() != nil {
+		return fmt.Errorf("failed to publish CAN frame to topic %s: %v", topic, token.Error())
+	}
+	return nil
+}
+
+// canTopic возвращает топик кадров CAN с заданным идентификатором
+func (c *Client) canTopic(id string) string {
+	return fmt.Sprintf("%s/%s/%s", c.config.CANTopic, c.vin, id)
+}
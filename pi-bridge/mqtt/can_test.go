@@ -0,0 +1,42 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+func TestCANTopic(t *testing.T) {
+	client := &Client{
+		config: DefaultConfig(),
+		vin:    "TEST123",
+	}
+
+	if topic := client.canTopic("3B4"); topic != "car/can/TEST123/3B4" {
+		t.Errorf("Expected topic 'car/can/TEST123/3B4', got %s", topic)
+	}
+}
+
+func TestPublishCANFrame(t *testing.T) {
+	client := NewClient(DefaultConfig(), make(chan common.Telemetry), make(chan string), make(chan CommandResponse), make(chan common.StatusEvent))
+
+	frame := common.CANFrame{ID: "3B4", Data: "01 02 03", Timestamp: time.Now()}
+	client.PublishCANFrame(frame)
+	select {
+	case queued := <-client.canChan:
+		if queued != frame {
+			t.Errorf("Expected %+v, got %+v", frame, queued)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected CAN frame to be queued")
+	}
+
+	// Переполненная очередь не блокирует цикл чтения адаптера
+	for i := 0; i < canQueueSize+1; i++ {
+		client.PublishCANFrame(frame)
+	}
+	if len(client.canChan) != canQueueSize {
+		t.Errorf("Expected full queue of %d frames, got %d", canQueueSize, len(client.canChan))
+	}
+}
@@ -1,8 +1,10 @@
 package mqtt
 
 import (
+	"context"
 	"crypto/rand"
 	"elm327-bridge/common"
+	"elm327-bridge/obd"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
@@ -16,16 +18,35 @@ import (
 
 // Config представляет конфигурацию MQTT клиента
 type Config struct {
-	Broker         string        `yaml:"broker"`          // Адрес брокера, например "tcp://localhost:1883"
-	Username       string        `yaml:"username"`        // Имя пользователя (опционально)
-	Password       string        `yaml:"password"`        // Пароль (опционально)
-	ClientID       string        `yaml:"client_id"`       // ID клиента (опционально, генерируется если пустой)
-	DataTopic      string        `yaml:"data_topic"`      // Базовый топик для данных телеметрии
-	CommandTopic   string        `yaml:"command_topic"`   // Базовый топик для команд
-	QoS            byte          `yaml:"qos"`             // Quality of Service (0, 1, 2)
-	KeepAlive      int           `yaml:"keep_alive"`      // Интервал keep alive в секундах
-	ConnectTimeout time.Duration `yaml:"connect_timeout"` // Таймаут подключения
-	AutoReconnect  bool          `yaml:"auto_reconnect"`  // Автоматическое переподключение
+	Broker          string              `yaml:"broker"`           // Адрес брокера, например "tcp://localhost:1883" или "wss://broker.example.com:443/mqtt"
+	Headers         map[string]string   `yaml:"headers"`          // Заголовки HTTP запроса WebSocket (только ws:// и wss://)
+	Username        string              `yaml:"username"`         // Имя пользователя (опционально)
+	Password        string              `yaml:"password"`         // Пароль (опционально)
+	ClientID        string              `yaml:"client_id"`        // ID клиента (опционально, генерируется если пустой)
+	DataTopic       string              `yaml:"data_topic"`       // Базовый топик для данных телеметрии
+	CommandTopic    string              `yaml:"command_topic"`    // Базовый топик для команд
+	StatusTopic     string              `yaml:"status_topic"`     // Базовый топик для служебных событий моста
+	CANTopic        string              `yaml:"can_topic"`        // Базовый топик для кадров CAN при прослушивании шины
+	QoS             byte                `yaml:"qos"`              // Quality of Service (0, 1, 2)
+	ClassQoS        QoSConfig           `yaml:"class_qos"`        // QoS телеметрии, ответов на команды и неисправностей
+	KeepAlive       int                 `yaml:"keep_alive"`       // Интервал keep alive в секундах
+	ConnectTimeout  time.Duration       `yaml:"connect_timeout"`  // Таймаут подключения
+	AutoReconnect   bool                `yaml:"auto_reconnect"`   // Автоматическое переподключение
+	Availability    bool                `yaml:"availability"`     // Топик доступности online/offline с завещанием (LWT)
+	HistorySize     int                 `yaml:"history_size"`     // Количество команд в истории выполнения
+	CommandTimeout  time.Duration       `yaml:"command_timeout"`  // Время ожидания ответа адаптера на команду
+	Election        ElectionConfig      `yaml:"election"`         // Резервирование: выбор активного моста
+	Legacy          LegacyConfig        `yaml:"legacy"`           // Совместимость с устаревшими base64 топиками
+	Dedup           DedupConfig         `yaml:"dedup"`            // Публикация только изменившихся значений
+	RetainedMetrics []string            `yaml:"retained_metrics"` // Метрики, публикуемые retained сообщениями
+	PayloadFormat   string              `yaml:"payload_format"`   // Формат телеметрии: json, influx, protobuf, cbor или plain (пусто - json)
+	PlainAttributes bool                `yaml:"plain_attributes"` // При формате plain публиковать JSON сообщение в {топик}/attributes
+	Buffer          BufferConfig        `yaml:"buffer"`           // Очередь телеметрии на время потери связи с брокером
+	Batch           BatchConfig         `yaml:"batch"`            // Метрики цикла опроса одним документом state
+	CommandFilter   CommandFilterConfig `yaml:"command_filter"`   // Разрешенные и запрещенные команды из топика команд
+	Auth            AuthConfig          `yaml:"auth"`             // Подпись команд общим секретом
+	ResponseTopics  string              `yaml:"response_topics"`  // Топики ответов на команды: shared, correlation или both (пусто - shared)
+	ReadOnly        bool                `yaml:"-"`                // Режим только чтения (задается глобальным read_only)
 }
 
 // generateClientID генерирует случайный ID клиента
@@ -38,26 +59,62 @@ func generateClientID() string {
 // DefaultConfig возвращает конфигурацию по умолчанию
 func DefaultConfig() Config {
 	return Config{
-		Broker:         "tcp://localhost:1883",
-		ClientID:       generateClientID(),
-		DataTopic:      "car/telemetry",
-		CommandTopic:   "car/command",
-		QoS:            1,
+		Broker:       "tcp://localhost:1883",
+		ClientID:     generateClientID(),
+		DataTopic:    "car/telemetry",
+		CommandTopic: "car/command",
+		StatusTopic:  "car/bridge",
+		CANTopic:     "car/can",
+		QoS:          1,
+		ClassQoS: QoSConfig{
+			Telemetry: 0,
+			Responses: 1,
+			Alerts:    2,
+		},
 		KeepAlive:      60,
 		ConnectTimeout: 10 * time.Second,
 		AutoReconnect:  true,
+		Availability:   true,
+		HistorySize:    20,
+		CommandTimeout: 10 * time.Second,
+		Election: ElectionConfig{
+			Lease: 15 * time.Second,
+		},
+		Legacy:          DefaultLegacyConfig(),
+		RetainedMetrics: []string{"odometer", "distance_travelled"},
+		CommandFilter: CommandFilterConfig{
+			Deny: defaultDeniedCommands,
+		},
+		Buffer: BufferConfig{
+			Size: 1000,
+		},
 	}
 }
 
+// retainedMetrics возвращает множество метрик, публикуемых как retained сообщения,
+// чтобы новые подписчики сразу получали последнее известное значение, не дожидаясь опроса
+func retainedMetrics(metrics []string) map[string]bool {
+	retained := make(map[string]bool, len(metrics))
+	for _, metric := range metrics {
+		retained[metric] = true
+	}
+	return retained
+}
+
 // TelemetryMessage представляет сообщение с данными телеметрии для MQTT
 type TelemetryMessage struct {
-	VIN       string    `json:"vin"`
-	PID       string    `json:"pid"`
-	Metric    string    `json:"metric"`
-	Value     float64   `json:"value"`
-	Unit      string    `json:"unit"`
-	Timestamp time.Time `json:"timestamp"`
-	Raw       string    `json:"raw,omitempty"`
+	VIN         string    `json:"vin"`
+	PID         string    `json:"pid"`
+	Metric      string    `json:"metric"`
+	Value       float64   `json:"value"`
+	Unit        string    `json:"unit"`
+	Timestamp   time.Time `json:"timestamp"`
+	Raw         string    `json:"raw,omitempty"`
+	HighRate    bool      `json:"high_rate,omitempty"`
+	ECU         string    `json:"ecu,omitempty"`
+	Data        string    `json:"data,omitempty"`        // Байты данных неподдерживаемого PID в hex
+	Implausible bool      `json:"implausible,omitempty"` // Значение вне допустимого диапазона
+	Derived     bool      `json:"derived,omitempty"`     // Метрика вычислена мостом
 }
 
 // CommandMessage представляет входящую команду (используем общий тип)
@@ -70,34 +127,132 @@ type CommandResponse = common.CommandResponse
 type Client struct {
 	config           Config
 	mqttClient       mqttLib.Client
-	telemetryChan    <-chan interface{}          // Канал для получения данных телеметрии
+	telemetryChan    <-chan common.Telemetry     // Канал для получения данных телеметрии
 	commandsChan     chan<- string               // Канал для отправки команд в Bluetooth
 	commandResponses chan common.CommandResponse // Канал для ответов на команды (двунаправленный)
-	stopChan         chan struct{}
+	statusChan       <-chan common.StatusEvent   // Канал для служебных событий моста
+	ctx              context.Context             // Время работы клиента: отменяется при остановке
+	cancel           context.CancelFunc          // Отменяет ctx
 	wg               sync.WaitGroup
 	logger           *log.Logger
 	vin              string // VIN автомобиля (определяется динамически)
+
+	history           *CommandHistory           // История выполненных удаленных команд
+	requests          *common.PendingRequests   // Ожидающие ответа команды (nil - ответы не сопоставляются)
+	bridgeCommands    *obd.BridgeCommands       // Служебные команды моста (nil - общий реестр)
+	legacyChan        chan string               // Сырые ответы для устаревшего топика данных
+	canChan           chan common.CANFrame      // Кадры CAN, полученные при прослушивании шины
+	dedup             *dedupFilter              // Фильтр неизменившихся значений (nil - выключен)
+	commandFilter     *commandFilter            // Фильтр команд из MQTT (nil - выключен)
+	replays           replayCache               // Принятые подписи команд (защита от повтора)
+	retained          map[string]bool           // Метрики, публикуемые retained сообщениями
+	buffer            telemetryQueue            // Телеметрия, не опубликованная без связи с брокером (nil - выключено)
+	loopsOnce         sync.Once                 // Горутины публикации запускаются один раз
+	flushMu           sync.Mutex                // Очередь публикуется одной горутиной, чтобы сохранить порядок
+	election          *Election                 // Выбор активного моста (nil, если резервирование выключено)
+	leadershipHandler func(leader bool)         // Вызывается при смене роли моста
+	birthHandler      func() common.StatusEvent // Сообщение о запуске моста (nil - не публикуется)
 }
 
 // NewClient создает нового MQTT клиента
-func NewClient(config Config, telemetryChan <-chan interface{}, commandsChan chan<- string, commandResponses chan common.CommandResponse) *Client {
-	return &Client{
+func NewClient(config Config, telemetryChan <-chan common.Telemetry, commandsChan chan<- string, commandResponses chan common.CommandResponse, statusChan <-chan common.StatusEvent) *Client {
+	if config.HistorySize <= 0 {
+		config.HistorySize = DefaultConfig().HistorySize
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := &Client{
 		config:           config,
 		telemetryChan:    telemetryChan,
 		commandsChan:     commandsChan,
 		commandResponses: commandResponses,
-		stopChan:         make(chan struct{}),
+		statusChan:       statusChan,
+		history:          NewCommandHistory(config.HistorySize),
+		legacyChan:       make(chan string, legacyQueueSize),
+		canChan:          make(chan common.CANFrame, canQueueSize),
+		dedup:            newDedupFilter(config.Dedup),
+		retained:         retainedMetrics(config.RetainedMetrics),
+		ctx:              ctx,
+		cancel:           cancel,
 		logger:           log.New(os.Stdout, "[MQTT-Client] ", log.LstdFlags|log.Lshortfile),
 	}
+	// Выражения фильтра проверяются при загрузке конфигурации (CommandFilterConfig.Validate).
+	// Неверный фильтр не открывает доступ: команды запрещаются, а Start возвращает ошибку
+	filter, err := newCommandFilter(config.CommandFilter)
+	if err != nil {
+		client.logger.Printf("Warning: %v, rejecting all commands", err)
+		filter = denyAllCommands()
+	}
+	client.commandFilter = filter
+	// Очередь на диске открывается в Start, когда известен VIN
+	if buffer := newTelemetryBuffer(config.Buffer); buffer != nil && config.Buffer.Path == "" {
+		client.buffer = buffer
+	}
+	return client
 }
 
-// Start запускает MQTT клиента
-func (c *Client) Start() error {
+// SetPendingRequests задает реестр, через который ответы адаптера сопоставляются
+// с полученными командами (вызывать до Start)
+func (c *Client) SetPendingRequests(requests *common.PendingRequests) {
+	c.requests = requests
+}
+
+// SetBridgeCommands задает реестр служебных команд моста этого автомобиля (вызывать до Start)
+func (c *Client) SetBridgeCommands(commands *obd.BridgeCommands) {
+	c.bridgeCommands = commands
+}
+
+// SetLeadershipHandler задает обработчик смены роли моста (вызывать до Start)
+func (c *Client) SetLeadershipHandler(handler func(leader bool)) {
+	c.leadershipHandler = handler
+}
+
+// SetBirthHandler задает источник сообщения о запуске моста (birth), которое публикуется
+// при каждом подключении к брокеру (вызывать до Start)
+func (c *Client) SetBirthHandler(handler func() common.StatusEvent) {
+	c.birthHandler = handler
+}
+
+// IsLeader возвращает true, если мост активен (всегда true без резервирования)
+func (c *Client) IsLeader() bool {
+	return c.election == nil || c.election.IsLeader()
+}
+
+// Start подключается к брокеру и запускает MQTT клиента. Отмена ctx прерывает
+// ожидание подключения; после успешного запуска клиент работает до Stop
+func (c *Client) Start(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
 	c.logger.Printf("Starting MQTT client, broker: %s", c.config.Broker)
 
+	if err := c.config.CommandFilter.Validate(); err != nil {
+		return err
+	}
+	if err := c.openBuffer(); err != nil {
+		return err
+	}
+
+	if c.config.Election.Enabled {
+		nodeID := c.config.Election.NodeID
+		if nodeID == "" {
+			nodeID = c.config.ClientID
+		}
+		if c.config.Election.Lease <= 0 {
+			c.config.Election.Lease = DefaultConfig().Election.Lease
+		}
+		c.election = NewElection(nodeID, c.config.Election.Lease, c.leadershipHandler)
+		c.logger.Printf("Leader election: ENABLED (node %s, lease %v)", nodeID, c.config.Election.Lease)
+	}
+
 	// Создаем опции подключения
 	opts := mqttLib.NewClientOptions()
 	opts.AddBroker(c.config.Broker)
+	// Через WebSocket брокер доступен там, где открыт только порт 443: paho подключается
+	// по пути из адреса, учитывая прокси из HTTPS_PROXY
+	if headers := c.config.httpHeaders(); headers != nil {
+		opts.SetHTTPHeaders(headers)
+	}
 	opts.SetClientID(c.config.ClientID)
 	opts.SetKeepAlive(time.Duration(c.config.KeepAlive) * time.Second)
 	opts.SetConnectTimeout(c.config.ConnectTimeout)
@@ -112,6 +267,11 @@ func (c *Client) Start() error {
 		c.logger.Println("MQTT authentication: DISABLED (anonymous mode)")
 	}
 
+	// Завещание: брокер пометит мост недоступным, если Raspberry Pi потеряет сеть или питание
+	if c.config.Availability {
+		opts.SetWill(c.availabilityTopic(), availabilityOffline, c.config.QoS, true)
+	}
+
 	// Обработчики событий
 	opts.SetOnConnectHandler(c.onConnectHandler)
 	opts.SetConnectionLostHandler(c.onConnectionLostHandler)
@@ -121,10 +281,47 @@ func (c *Client) Start() error {
 	c.mqttClient = mqttLib.NewClient(opts)
 
 	// Подключаемся
-	if token := c.mqttClient.Connect(); token.Wait() && token.Error() != nil {
+	token := c.mqttClient.Connect()
+	select {
+	case <-token.Done():
+	case <-ctx.Done():
+		// Незавершенное подключение прерывается, чтобы клиент не подключился после отказа
+		c.mqttClient.Disconnect(0)
+		c.closeBuffer()
+		return fmt.Errorf("failed to connect to MQTT broker: %w", ctx.Err())
+	}
+	if token.Error() != nil {
+		c.closeBuffer()
 		return fmt.Errorf("failed to connect to MQTT broker: %v", token.Error())
 	}
 
+	if c.election != nil {
+		c.wg.Add(1)
+		go c.electionLoop()
+	}
+
+	c.wg.Add(1)
+	go c.publishHistoryLoop()
+
+	if c.config.CANTopic == "" {
+		c.config.CANTopic = DefaultConfig().CANTopic
+	}
+	c.wg.Add(1)
+	go c.publishCANLoop()
+
+	if c.config.Legacy.Enabled {
+		defaults := DefaultLegacyConfig()
+		if c.config.Legacy.DataTopic == "" {
+			c.config.Legacy.DataTopic = defaults.DataTopic
+		}
+		if c.config.Legacy.CommandTopic == "" {
+			c.config.Legacy.CommandTopic = defaults.CommandTopic
+		}
+		c.logger.Printf("Legacy base64 topics: ENABLED (data %s, commands %s)", c.config.Legacy.DataTopic, c.config.Legacy.CommandTopic)
+		c.wg.Add(1)
+		go c.publishLegacyLoop()
+	}
+
 	c.logger.Println("MQTT client started successfully")
 	return nil
 }
@@ -133,8 +330,22 @@ func (c *Client) Start() error {
 func (c *Client) Stop() error {
 	c.logger.Println("Stopping MQTT client...")
 
-	close(c.stopChan)
+	c.cancel()
 	c.wg.Wait()
+	c.closeBuffer()
+
+	// Освобождаем лидерство сразу, чтобы резервный мост не ждал истечения заявки
+	if c.election != nil && c.election.IsLeader() && c.IsConnected() {
+		c.publishLeaderClaim(nil)
+		c.election.StepDown()
+	}
+
+	// При штатном отключении брокер не публикует завещание
+	if c.config.Availability && c.IsConnected() {
+		if err := c.publishAvailability(availabilityOffline); err != nil {
+			c.logger.Printf("Failed to publish availability: %v", err)
+		}
+	}
 
 	if c.mqttClient != nil && c.mqttClient.IsConnected() {
 		c.mqttClient.Disconnect(1000)
@@ -149,25 +360,83 @@ func (c *Client) onConnectHandler(client mqttLib.Client) {
 	c.logger.Println("Connected to MQTT broker")
 
 	// Подписываемся на топики команд
-	commandTopic := fmt.Sprintf("%s/+/request", c.config.CommandTopic)
+	commandTopic := c.commandRequestTopic()
 	if token := client.Subscribe(commandTopic, c.config.QoS, c.onCommandReceived); token.Wait() && token.Error() != nil {
 		c.logger.Printf("Failed to subscribe to command topic %s: %v", commandTopic, token.Error())
 		return
 	}
 	c.logger.Printf("Subscribed to command topic: %s", commandTopic)
 
-	// Запускаем горутину для публикации телеметрии
-	c.wg.Add(1)
-	go c.publishTelemetryLoop()
+	if c.config.Legacy.Enabled {
+		if token := client.Subscribe(c.config.Legacy.CommandTopic, c.config.QoS, c.onLegacyCommand); token.Wait() && token.Error() != nil {
+			c.logger.Printf("Failed to subscribe to legacy command topic %s: %v", c.config.Legacy.CommandTopic, token.Error())
+		}
+	}
 
-	// Запускаем горутину для публикации ответов на команды
-	c.wg.Add(1)
-	go c.publishResponsesLoop()
+	if c.election != nil {
+		if token := client.Subscribe(c.electionTopic(), c.config.QoS, c.onLeaderClaim); token.Wait() && token.Error() != nil {
+			c.logger.Printf("Failed to subscribe to election topic: %v", token.Error())
+		}
+	}
+
+	// Retained online заменяет offline, опубликованный по завещанию или при остановке
+	if c.config.Availability {
+		if err := c.publishAvailability(availabilityOnline); err != nil {
+			c.logger.Printf("Failed to publish availability: %v", err)
+		}
+	}
+
+	// После переподключения брокер мог потерять сессии подписчиков: первое значение
+	// каждой метрики публикуется без фильтра
+	c.dedup.Reset()
+
+	// Телеметрия, сохраненная без связи с брокером, публикуется в порядке получения
+	if c.pendingTelemetry() {
+		c.wg.Add(1)
+		go func() {
+			defer c.wg.Done()
+			c.flushBuffer()
+		}()
+	}
+
+	// Каталог метрик публикуется при каждом подключении, чтобы дашборды получили актуальный список
+	if err := c.publishStatus(catalogEvent()); err != nil {
+		c.logger.Printf("Failed to publish metric catalog: %v", err)
+	}
+
+	// Сообщение о запуске: версия, адаптер, протокол и поддерживаемые PID для потребителей
+	if c.birthHandler != nil {
+		if err := c.publishStatus(c.birthHandler()); err != nil {
+			c.logger.Printf("Failed to publish birth message: %v", err)
+		}
+	}
+
+	// Горутины публикации запускаются при первом подключении и переживают переподключения:
+	// вторая горутина телеметрии делила бы с первой метрики одного документа state
+	c.loopsOnce.Do(func() {
+		// Запускаем горутину для публикации телеметрии
+		c.wg.Add(1)
+		go c.publishTelemetryLoop()
+
+		// Запускаем горутину для публикации ответов на команды
+		c.wg.Add(1)
+		go c.publishResponsesLoop()
+
+		// Запускаем горутину для публикации служебных событий
+		c.wg.Add(1)
+		go c.publishStatusLoop()
+	})
 }
 
 // onConnectionLostHandler вызывается при потере соединения
 func (c *Client) onConnectionLostHandler(client mqttLib.Client, err error) {
 	c.logger.Printf("Connection lost: %v", err)
+
+	// Без связи с брокером лидер не может продлевать заявку: освобождаем адаптер,
+	// чтобы резервный мост не конкурировал с нами за подключение
+	if c.election != nil {
+		c.election.StepDown()
+	}
 }
 
 // onReconnectingHandler вызывается при попытке переподключения
@@ -185,15 +454,63 @@ func (c *Client) onCommandReceived(client mqttLib.Client, msg mqttLib.Message) {
 		return
 	}
 
+	// Команды выполняет только активный мост
+	if !c.IsLeader() {
+		c.logger.Printf("Standby mode, ignoring command %s", cmd.Command)
+		return
+	}
+
+	// Команда без верной подписи не попадает ни в историю, ни в адаптер
+	if err := c.verifyCommand(cmd, msg.Topic(), time.Now()); err != nil {
+		c.logger.Printf("Rejected command: %v", err)
+		c.PublishCommandResponse(cmd.CorrelationID, "error", nil, err)
+		return
+	}
+
 	c.logger.Printf("Processing command: %s (correlation_id: %s)", cmd.Command, cmd.CorrelationID)
+	c.history.Record(cmd.CorrelationID, cmd.Command)
 
-	// Отправляем команду в канал для Bluetooth модуля
-	select {
-	case c.commandsChan <- cmd.Command:
-		c.logger.Printf("Command sent to Bluetooth: %s", cmd.Command)
-	case <-time.After(5 * time.Second):
-		c.logger.Printf("Timeout sending command to Bluetooth: %s", cmd.Command)
+	// Служебные команды моста раскрываются в последовательность команд ELM327
+	commands, start, err := c.expandCommand(cmd.Command)
+	if err != nil {
+		c.logger.Printf("Invalid bridge command %q: %v", cmd.Command, err)
+		c.PublishCommandResponse(cmd.CorrelationID, "error", nil, err)
+		return
+	}
+
+	// Отклоняем всю последовательность, если хоть одна команда запрещена
+	// режимом только чтения или фильтром команд
+	for _, command := range commands {
+		if err := c.checkCommand(command); err != nil {
+			c.logger.Printf("Rejected command: %v", err)
+			c.PublishCommandResponse(cmd.CorrelationID, "error", nil, err)
+			return
+		}
+	}
+
+	// Режим служебной команды (SNIFF, STREAM, PREDRIVE_CHECK) запускается только после
+	// проверки: отклоненная команда не должна менять состояние моста
+	if start != nil {
+		start()
+	}
+
+	// Регистрируем команды до отправки, чтобы адаптер связал с ними ответы
+	c.requests.Register(cmd.CorrelationID, commands)
+
+	for _, command := range commands {
+		// Отправляем команду в канал для Bluetooth модуля
+		select {
+		case c.commandsChan <- command:
+			c.logger.Printf("Command sent to Bluetooth: %s", command)
+		case <-time.After(5 * time.Second):
+			c.logger.Printf("Timeout sending command to Bluetooth: %s", command)
+			c.requests.Cancel(cmd.CorrelationID)
+			c.PublishCommandResponse(cmd.CorrelationID, "error", nil, fmt.Errorf("timeout sending command %s to adapter", command))
+			return
+		}
 	}
+
+	c.history.SetStatus(cmd.CorrelationID, HistoryStatusSent)
 }
 
 // publishTelemetryLoop публикует данные телеметрии
@@ -201,30 +518,63 @@ func (c *Client) publishTelemetryLoop() {
 	defer c.wg.Done()
 	c.logger.Println("Starting telemetry publish loop")
 
+	// Окно накопления документа state запускается первой метрикой документа
+	batch := newTelemetryBatch(c.config.Batch)
+	batchTimer := time.NewTimer(0)
+	batchTimer.Stop()
+	defer batchTimer.Stop()
+
 	for {
 		select {
-		case <-c.stopChan:
+		case <-c.ctx.Done():
+			// Накопленный документ публикуется до отключения от брокера
+			if batch != nil {
+				c.deliverState(batch.Take())
+			}
 			c.logger.Println("Telemetry publish loop stopped")
 			return
-		case telemetryData, ok := <-c.telemetryChan:
+		case <-batchTimer.C:
+			c.deliverState(batch.Take())
+		case telemetry, ok := <-c.telemetryChan:
 			if !ok {
 				c.logger.Println("Telemetry channel closed")
 				return
 			}
 
-			// Конвертируем данные в TelemetryMessage
-			msg, err := c.convertToTelemetryMessage(telemetryData)
-			if err != nil {
-				c.logger.Printf("Failed to convert telemetry data: %v", err)
+			// Неизменившиеся значения отфильтрованных метрик не публикуются
+			msg := c.newTelemetryMessage(telemetry)
+			if !c.dedup.Allow(msg, msg.Timestamp) {
 				continue
 			}
 
-			// Публикуем в MQTT
-			if err := c.publishTelemetry(msg); err != nil {
-				c.logger.Printf("Failed to publish telemetry: %v", err)
+			if batch.batched(msg) {
+				complete, started := batch.Add(msg)
+				c.deliverState(complete)
+				if started {
+					batchTimer.Reset(batch.window)
+				}
+				continue
 			}
+			c.deliverTelemetry(msg)
+		}
+	}
+}
+
+// deliverTelemetry публикует сообщение телеметрии, без связи с брокером сохраняет в очередь
+func (c *Client) deliverTelemetry(msg *TelemetryMessage) {
+	// Пока очередь не опубликована, новые сообщения встают за ней, чтобы сохранить порядок
+	if c.pendingTelemetry() && !c.flushBuffer() {
+		c.bufferTelemetry(msg)
+		return
+	}
+
+	if err := c.publishTelemetry(msg); err != nil {
+		if !c.bufferTelemetry(msg) {
+			c.logger.Printf("Failed to publish telemetry: %v", err)
 		}
+		return
 	}
+	c.dedup.Published(msg, msg.Timestamp)
 }
 
 // publishResponsesLoop публикует ответы на команды
@@ -234,7 +584,7 @@ func (c *Client) publishResponsesLoop() {
 
 	for {
 		select {
-		case <-c.stopChan:
+		case <-c.ctx.Done():
 			c.logger.Println("Responses publish loop stopped")
 			return
 		case response, ok := <-c.commandResponses:
@@ -243,6 +593,8 @@ func (c *Client) publishResponsesLoop() {
 				return
 			}
 
+			c.history.Complete(response)
+
 			// Публикуем ответ в MQTT
 			if err := c.publishCommandResponse(response); err != nil {
 				c.logger.Printf("Failed to publish command response: %v", err)
@@ -251,22 +603,45 @@ func (c *Client) publishResponsesLoop() {
 	}
 }
 
-// convertToTelemetryMessage конвертирует данные телеметрии в MQTT сообщение
-func (c *Client) convertToTelemetryMessage(data interface{}) (*TelemetryMessage, error) {
-	// Пытаемся привести к типу common.Telemetry
-	if telemetry, ok := data.(common.Telemetry); ok {
-		return &TelemetryMessage{
-			VIN:       c.vin, // TODO: Получить реальный VIN
-			PID:       telemetry.PID,
-			Metric:    telemetry.Metric,
-			Value:     telemetry.Value,
-			Unit:      telemetry.Unit,
-			Timestamp: time.Now(),
-			Raw:       telemetry.Raw,
-		}, nil
+// publishStatusLoop публикует служебные события моста
+func (c *Client) publishStatusLoop() {
+	defer c.wg.Done()
+	c.logger.Println("Starting status publish loop")
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			c.logger.Println("Status publish loop stopped")
+			return
+		case event, ok := <-c.statusChan:
+			if !ok {
+				c.logger.Println("Status channel closed")
+				return
+			}
+
+			if err := c.publishStatus(event); err != nil {
+				c.logger.Printf("Failed to publish status event: %v", err)
+			}
+		}
 	}
+}
 
-	return nil, fmt.Errorf("unsupported telemetry data type: %T", data)
+// newTelemetryMessage создает MQTT сообщение из данных телеметрии
+func (c *Client) newTelemetryMessage(telemetry common.Telemetry) *TelemetryMessage {
+	return &TelemetryMessage{
+		VIN:         c.vin, // TODO: Получить реальный VIN
+		PID:         telemetry.PID,
+		Metric:      telemetry.Metric,
+		Value:       telemetry.Value,
+		Unit:        telemetry.Unit,
+		Timestamp:   time.Now(),
+		Raw:         telemetry.Raw,
+		HighRate:    telemetry.HighRate,
+		ECU:         telemetry.ECU,
+		Data:        telemetry.Data,
+		Implausible: telemetry.Implausible,
+		Derived:     telemetry.Derived,
+	}
 }
 
 // publishTelemetry публикует данные телеметрии в MQTT
@@ -275,17 +650,31 @@ func (c *Client) publishTelemetry(msg *TelemetryMessage) error {
 		return fmt.Errorf("MQTT client not connected")
 	}
 
-	// Создаем JSON payload
-	payload, err := json.Marshal(msg)
+	// Создаем payload в формате payload_format
+	payload, err := c.encodeTelemetry(msg)
 	if err != nil {
 		return fmt.Errorf("failed to marshal telemetry message: %v", err)
 	}
 
 	// Создаем топик
-	topic := fmt.Sprintf("%s/%s/%s", c.config.DataTopic, c.vin, msg.Metric)
+	topic := c.telemetryTopic(msg)
+	retained := c.retained[msg.Metric]
+
+	// Атрибуты публикуются раньше значения: получив значение, подписчик видит актуальные атрибуты
+	if c.config.PlainAttributes {
+		attributes, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("failed to marshal telemetry attributes: %v", err)
+		}
+		token := c.mqttClient.Publish(topic+attributesSuffix, c.config.ClassQoS.Telemetry, retained, attributes)
+		token.Wait()
+		if token.Error() != nil {
+			return fmt.Errorf("failed to publish to topic %s: %v", topic+attributesSuffix, token.Error())
+		}
+	}
 
 	// Публикуем
-	token := c.mqttClient.Publish(topic, c.config.QoS, false, payload)
+	token := c.mqttClient.Publish(topic, c.config.ClassQoS.Telemetry, retained, payload)
 	token.Wait()
 
 	if token.Error() != nil {
@@ -296,6 +685,19 @@ func (c *Client) publishTelemetry(msg *TelemetryMessage) error {
 	return nil
 }
 
+// telemetryTopic возвращает топик для сообщения телеметрии.
+// Отсчеты потокового режима публикуются в отдельный высокочастотный топик,
+// сырые данные неподдерживаемых PID - в топик raw/<PID>
+func (c *Client) telemetryTopic(msg *TelemetryMessage) string {
+	if msg.Data != "" {
+		return fmt.Sprintf("%s/%s/raw/%s", c.config.DataTopic, c.vin, msg.PID)
+	}
+	if msg.HighRate {
+		return fmt.Sprintf("%s/%s/stream/%s", c.config.DataTopic, c.vin, msg.Metric)
+	}
+	return fmt.Sprintf("%s/%s/%s", c.config.DataTopic, c.vin, msg.Metric)
+}
+
 // publishCommandResponse публикует ответ на команду в MQTT
 func (c *Client) publishCommandResponse(response CommandResponse) error {
 	if c.mqttClient == nil || !c.mqttClient.IsConnected() {
@@ -308,24 +710,129 @@ func (c *Client) publishCommandResponse(response CommandResponse) error {
 		return fmt.Errorf("failed to marshal command response: %v", err)
 	}
 
-	// Создаем топик для ответа
-	topic := fmt.Sprintf("%s/%s/response", c.config.CommandTopic, c.vin)
+	// Публикуем в общий топик и/или топик запроса
+	for _, topic := range c.responseTopics(response.CorrelationID) {
+		token := c.mqttClient.Publish(topic, c.config.ClassQoS.Responses, false, payload)
+		token.Wait()
 
-	// Публикуем
-	token := c.mqttClient.Publish(topic, c.config.QoS, false, payload)
+		if token.Error() != nil {
+			return fmt.Errorf("failed to publish response to topic %s: %v", topic, token.Error())
+		}
+
+		c.logger.Printf("Published command response to %s: %s", topic, response.Status)
+	}
+	return nil
+}
+
+// publishStatus публикует служебное событие в MQTT
+func (c *Client) publishStatus(event common.StatusEvent) error {
+	if c.mqttClient == nil || !c.mqttClient.IsConnected() {
+		return fmt.Errorf("MQTT client not connected")
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal status event: %v", err)
+	}
+
+	topic := c.statusTopic(event.Kind)
+
+	token := c.mqttClient.Publish(topic, c.statusQoS(event.Kind), event.Retained, payload)
 	token.Wait()
 
 	if token.Error() != nil {
-		return fmt.Errorf("failed to publish response to topic %s: %v", topic, token.Error())
+		return fmt.Errorf("failed to publish status to topic %s: %v", topic, token.Error())
+	}
+
+	c.logger.Printf("Published status event to %s", topic)
+	return nil
+}
+
+// catalogEvent формирует служебное событие с каталогом метрик
+func catalogEvent() common.StatusEvent {
+	return common.StatusEvent{
+		Kind:      "catalog",
+		Data:      obd.Catalog(),
+		Retained:  true,
+		Timestamp: time.Now(),
+	}
+}
+
+// statusTopic возвращает топик для служебного события заданного типа
+func (c *Client) statusTopic(kind string) string {
+	return fmt.Sprintf("%s/%s/%s", c.config.StatusTopic, c.vin, kind)
+}
+
+// History возвращает историю выполненных команд (также доступна через REST API)
+func (c *Client) History() *CommandHistory {
+	return c.history
+}
+
+// publishHistoryLoop публикует историю команд при каждом ее изменении
+func (c *Client) publishHistoryLoop() {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-c.history.Changed():
+			if err := c.publishHistory(); err != nil {
+				c.logger.Printf("Failed to publish command history: %v", err)
+			}
+		}
 	}
+}
+
+// publishHistory публикует историю команд как retained сообщение
+func (c *Client) publishHistory() error {
+	if c.mqttClient == nil || !c.mqttClient.IsConnected() {
+		return fmt.Errorf("MQTT client not connected")
+	}
+
+	payload, err := json.Marshal(c.history.Entries())
+	if err != nil {
+		return fmt.Errorf("failed to marshal command history: %v", err)
+	}
+
+	topic := c.historyTopic()
+	token := c.mqttClient.Publish(topic, c.config.QoS, true, payload)
+	token.Wait()
 
-	c.logger.Printf("Published command response to %s: %s", topic, response.Status)
+	if token.Error() != nil {
+		return fmt.Errorf("failed to publish history to topic %s: %v", topic, token.Error())
+	}
 	return nil
 }
 
+// historyTopic возвращает топик истории команд
+func (c *Client) historyTopic() string {
+	return fmt.Sprintf("%s/%s/history", c.config.CommandTopic, c.vin)
+}
+
+// commandRequestTopic возвращает подписку на входящие команды: при заданном VIN только
+// команды этого автомобиля, иначе команды с любым VIN
+func (c *Client) commandRequestTopic() string {
+	vin := c.vin
+	if vin == "" {
+		vin = "+"
+	}
+	return fmt.Sprintf("%s/%s/request", c.config.CommandTopic, vin)
+}
+
+// expandCommand раскрывает служебные команды моста по реестру автомобиля
+// и возвращает запуск режима команды (nil - без режима)
+func (c *Client) expandCommand(command string) ([]string, func(), error) {
+	if c.bridgeCommands == nil {
+		return obd.ExpandCommand(command)
+	}
+	return c.bridgeCommands.Expand(command)
+}
+
 // SetVIN устанавливает VIN автомобиля
 func (c *Client) SetVIN(vin string) {
 	c.vin = vin
+	c.dedup.Reset()
 	c.logger.Printf("VIN set to: %s", vin)
 }
 
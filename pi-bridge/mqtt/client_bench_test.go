@@ -0,0 +1,75 @@
+package mqtt
+
+import (
+	"io"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"elm327-bridge/obd"
+
+	mqttLib "github.com/eclipse/paho.mqtt.golang"
+)
+
+// completedToken - завершенный без ошибки токен публикации
+type completedToken struct{}
+
+var closedDone = func() chan struct{} {
+	done := make(chan struct{})
+	close(done)
+	return done
+}()
+
+func (completedToken) Wait() bool                     { return true }
+func (completedToken) WaitTimeout(time.Duration) bool { return true }
+func (completedToken) Done() <-chan struct{}          { return closedDone }
+func (completedToken) Error() error                   { return nil }
+
+// discardBroker подменяет клиент paho: публикации считаются и отбрасываются
+type discardBroker struct {
+	mqttLib.Client
+	published int
+	bytes     int
+}
+
+func (d *discardBroker) IsConnected() bool { return true }
+
+func (d *discardBroker) Publish(topic string, qos byte, retained bool, payload interface{}) mqttLib.Token {
+	d.published++
+	d.bytes += len(payload.([]byte))
+	return completedToken{}
+}
+
+// BenchmarkParseEncodePublish измеряет путь от сырого ответа ELM327 до публикации:
+// разбор, преобразование в сообщение, сериализацию JSON и вызов Publish
+func BenchmarkParseEncodePublish(b *testing.B) {
+	obd.SetLogOutput(io.Discard)
+	defer obd.SetLogOutput(os.Stdout)
+
+	broker := &discardBroker{}
+	client := &Client{
+		config:     DefaultConfig(),
+		mqttClient: broker,
+		vin:        "BENCH123",
+		logger:     log.New(io.Discard, "", 0),
+	}
+	responses := []string{"7E8 04 41 0C 1A F8", "7E8 03 41 0D 3C", "7E8 03 41 05 82"}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		telemetry, err := obd.ParseResponse(responses[i%len(responses)])
+		if err != nil {
+			b.Fatalf("ParseResponse failed: %v", err)
+		}
+		if err := client.publishTelemetry(client.newTelemetryMessage(*telemetry)); err != nil {
+			b.Fatalf("Publish failed: %v", err)
+		}
+	}
+	b.StopTimer()
+
+	if broker.published > 0 {
+		b.ReportMetric(float64(broker.bytes)/float64(broker.published), "B/msg")
+	}
+}
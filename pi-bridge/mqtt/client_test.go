@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"elm327-bridge/common"
 	"elm327-bridge/obd"
 )
 
@@ -50,7 +51,7 @@ func TestGenerateClientID(t *testing.T) {
 	}
 }
 
-func TestConvertToTelemetryMessage(t *testing.T) {
+func TestNewTelemetryMessage(t *testing.T) {
 	logger := log.New(os.Stdout, "[Test] ", log.LstdFlags)
 	client := &Client{
 		vin:    "TEST123",
@@ -67,10 +68,7 @@ func TestConvertToTelemetryMessage(t *testing.T) {
 		Raw:       "41 0C 1A F0",
 	}
 
-	msg, err := client.convertToTelemetryMessage(telemetry)
-	if err != nil {
-		t.Fatalf("Failed to convert telemetry: %v", err)
-	}
+	msg := client.newTelemetryMessage(telemetry)
 
 	if msg.VIN != "TEST123" {
 		t.Errorf("Expected VIN 'TEST123', got %s", msg.VIN)
@@ -89,16 +87,53 @@ func TestConvertToTelemetryMessage(t *testing.T) {
 	}
 }
 
-func TestConvertToTelemetryMessageUnsupportedType(t *testing.T) {
-	logger := log.New(os.Stdout, "[Test] ", log.LstdFlags)
-	client := &Client{
-		vin:    "TEST123",
-		logger: logger,
+func TestNewTelemetryMessageRetained(t *testing.T) {
+	client := NewClient(DefaultConfig(), nil, nil, nil, nil)
+	client.SetVIN("TEST123")
+
+	msg := client.newTelemetryMessage(obd.Telemetry{
+		PID:    "A6",
+		Metric: "odometer",
+		Value:  12345.6,
+		Unit:   "km",
+	})
+
+	if msg.Metric != "odometer" || msg.Value != 12345.6 {
+		t.Errorf("Expected odometer 12345.6, got %s %.1f", msg.Metric, msg.Value)
+	}
+
+	if !client.retained[msg.Metric] {
+		t.Error("Expected odometer to be published as retained")
 	}
+}
 
-	_, err := client.convertToTelemetryMessage("unsupported string")
-	if err == nil {
-		t.Error("Expected error for unsupported type")
+func TestPublishTelemetryRetainedMetrics(t *testing.T) {
+	config := DefaultConfig()
+	config.RetainedMetrics = []string{"fuel_level", "odometer", "coolant_temperature"}
+	recorder := &recordingClient{}
+	client := NewClient(config, nil, nil, nil, nil)
+	client.SetVIN("TEST123")
+	client.mqttClient = recorder
+
+	tests := []struct {
+		metric   string
+		retained bool
+	}{
+		{"fuel_level", true},
+		{"odometer", true},
+		{"coolant_temperature", true},
+		{"engine_rpm", false},
+		// Список из конфигурации заменяет список по умолчанию
+		{"distance_travelled", false},
+	}
+	for _, tt := range tests {
+		recorder.published = nil
+		if err := client.publishTelemetry(&TelemetryMessage{VIN: "TEST123", Metric: tt.metric}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(recorder.published) != 1 || recorder.published[0].retained != tt.retained {
+			t.Errorf("%s: expected retained %v, got %+v", tt.metric, tt.retained, recorder.published)
+		}
 	}
 }
 
@@ -162,12 +197,12 @@ func TestTelemetryMessageStructure(t *testing.T) {
 
 func TestClientCreation(t *testing.T) {
 	config := DefaultConfig()
-	telemetryChan := make(chan interface{}, 10)
+	telemetryChan := make(chan common.Telemetry, 10)
 	commandsChan := make(chan string, 10)
 	responsesChan := make(chan CommandResponse, 10)
+	statusChan := make(chan common.StatusEvent, 10)
 
-	logger := log.New(os.Stdout, "[Test] ", log.LstdFlags)
-	client := NewClient(config, telemetryChan, commandsChan, responsesChan)
+	client := NewClient(config, telemetryChan, commandsChan, responsesChan, statusChan)
 
 	if client == nil {
 		t.Fatal("NewClient returned nil")
@@ -208,3 +243,164 @@ func TestIsConnected(t *testing.T) {
 		t.Error("Expected IsConnected to return false for nil client")
 	}
 }
+
+func TestStatusTopic(t *testing.T) {
+	client := &Client{
+		config: DefaultConfig(),
+		vin:    "TEST123",
+	}
+
+	if topic := client.statusTopic("bus_health"); topic != "car/bridge/TEST123/bus_health" {
+		t.Errorf("Expected topic 'car/bridge/TEST123/bus_health', got %s", topic)
+	}
+}
+
+func TestTelemetryTopic(t *testing.T) {
+	client := &Client{
+		config: DefaultConfig(),
+		vin:    "TEST123",
+	}
+
+	msg := &TelemetryMessage{Metric: "engine_rpm"}
+	if topic := client.telemetryTopic(msg); topic != "car/telemetry/TEST123/engine_rpm" {
+		t.Errorf("Expected regular telemetry topic, got %s", topic)
+	}
+
+	msg.HighRate = true
+	if topic := client.telemetryTopic(msg); topic != "car/telemetry/TEST123/stream/engine_rpm" {
+		t.Errorf("Expected high-rate stream topic, got %s", topic)
+	}
+
+	raw := &TelemetryMessage{PID: "FF", Metric: "unknown_FF", Data: "12 34"}
+	if topic := client.telemetryTopic(raw); topic != "car/telemetry/TEST123/raw/FF" {
+		t.Errorf("Expected raw passthrough topic, got %s", topic)
+	}
+}
+
+// testMessage реализует mqttLib.Message для тестов обработчика команд
+type testMessage struct {
+	topic   string
+	payload []byte
+}
+
+func (m *testMessage) Duplicate() bool   { return false }
+func (m *testMessage) Qos() byte         { return 1 }
+func (m *testMessage) Retained() bool    { return false }
+func (m *testMessage) Topic() string     { return m.topic }
+func (m *testMessage) MessageID() uint16 { return 0 }
+func (m *testMessage) Payload() []byte   { return m.payload }
+func (m *testMessage) Ack()              {}
+
+func TestOnCommandReceivedReadOnly(t *testing.T) {
+	config := DefaultConfig()
+	config.ReadOnly = true
+
+	commandsChan := make(chan string, 10)
+	responsesChan := make(chan CommandResponse, 10)
+	client := NewClient(config, make(chan common.Telemetry), commandsChan, responsesChan, make(chan common.StatusEvent))
+
+	tests := []struct {
+		payload string
+		allowed bool
+	}{
+		{`{"command":"010C","correlation_id":"read"}`, true},
+		{`{"command":"04","correlation_id":"clear"}`, false},
+		{`{"command":"ATZ","correlation_id":"reset"}`, false},
+	}
+
+	for _, tt := range tests {
+		client.onCommandReceived(nil, &testMessage{topic: "car/command/TEST123/request", payload: []byte(tt.payload)})
+
+		if tt.allowed {
+			select {
+			case cmd := <-commandsChan:
+				if cmd != "010C" {
+					t.Errorf("Expected 010C to be forwarded, got %s", cmd)
+				}
+			default:
+				t.Errorf("Expected command %s to be forwarded", tt.payload)
+			}
+			continue
+		}
+
+		select {
+		case cmd := <-commandsChan:
+			t.Errorf("Expected command to be rejected, got %s forwarded", cmd)
+		default:
+		}
+
+		select {
+		case response := <-responsesChan:
+			if response.Status != "error" || response.Error == "" {
+				t.Errorf("Expected error response, got %+v", response)
+			}
+		default:
+			t.Errorf("Expected rejection response for %s", tt.payload)
+		}
+	}
+}
+
+func TestOnCommandReceivedRegistersRequest(t *testing.T) {
+	commandsChan := make(chan string, 10)
+	responsesChan := make(chan CommandResponse, 10)
+	client := NewClient(DefaultConfig(), make(chan common.Telemetry), commandsChan, responsesChan, make(chan common.StatusEvent))
+
+	requests := common.NewPendingRequests(time.Second, responsesChan)
+	client.SetPendingRequests(requests)
+
+	client.onCommandReceived(nil, &testMessage{topic: "car/command/TEST123/request", payload: []byte(`{"command":"010C","correlation_id":"cmd-1"}`)})
+
+	pending := requests.Pending()
+	if len(pending) != 1 || pending[0].CorrelationID != "cmd-1" || pending[0].Command != "010C" {
+		t.Errorf("Expected command to be registered, got %+v", pending)
+	}
+	if command := <-commandsChan; command != "010C" {
+		t.Errorf("Expected 010C to be forwarded, got %s", command)
+	}
+}
+
+func TestCommandRequestTopic(t *testing.T) {
+	client := &Client{config: DefaultConfig()}
+	if topic := client.commandRequestTopic(); topic != "car/command/+/request" {
+		t.Errorf("Expected wildcard subscription without VIN, got %s", topic)
+	}
+	if topic := client.electionTopic(); topic != "car/bridge/election" {
+		t.Errorf("Expected shared election topic without VIN, got %s", topic)
+	}
+
+	// У автомобиля из списка vehicles свои команды и свой лидер
+	client.vin = "TEST123"
+	if topic := client.commandRequestTopic(); topic != "car/command/TEST123/request" {
+		t.Errorf("Expected per-vehicle subscription, got %s", topic)
+	}
+	if topic := client.electionTopic(); topic != "car/bridge/TEST123/election" {
+		t.Errorf("Expected per-vehicle election topic, got %s", topic)
+	}
+}
+
+func TestOnCommandReceivedUsesVehicleCommands(t *testing.T) {
+	commandsChan := make(chan string, 10)
+	responsesChan := make(chan CommandResponse, 10)
+	client := NewClient(DefaultConfig(), make(chan common.Telemetry), commandsChan, responsesChan, make(chan common.StatusEvent))
+
+	commands := obd.NewBridgeCommands()
+	commands.Register("TEST_VEHICLE", func(args []string) ([]string, func(), error) {
+		return []string{"ATRV"}, nil, nil
+	})
+	client.SetBridgeCommands(commands)
+
+	client.onCommandReceived(nil, &testMessage{topic: "car/command/TEST123/request", payload: []byte(`{"command":"TEST_VEHICLE","correlation_id":"cmd-1"}`)})
+	if command := <-commandsChan; command != "ATRV" {
+		t.Errorf("Expected vehicle registry expansion ATRV, got %s", command)
+	}
+}
+
+func TestCatalogEvent(t *testing.T) {
+	event := catalogEvent()
+	if event.Kind != "catalog" || !event.Retained {
+		t.Errorf("Expected retained catalog event, got %+v", event)
+	}
+	if catalog, ok := event.Data.([]obd.MetricInfo); !ok || len(catalog) == 0 {
+		t.Errorf("Expected non-empty metric catalog, got %T", event.Data)
+	}
+}
@@ -0,0 +1,118 @@
+package mqtt
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// DedupConfig задает фильтр неизменившихся значений: метрика публикуется, только если
+// значение изменилось больше чем на delta с последней публикации. Медленно меняющиеся
+// значения (уровень топлива, атмосферное давление) опрашиваются каждый цикл, но
+// подписчикам нужны только изменения
+type DedupConfig struct {
+	Metrics   map[string]float64 `yaml:"metrics"`   // Порог изменения по названию метрики (0 - любое изменение)
+	Heartbeat time.Duration      `yaml:"heartbeat"` // Публиковать неизменившееся значение не реже этого периода (0 - никогда)
+}
+
+// Validate проверяет пороги фильтра
+func (c DedupConfig) Validate() error {
+	for metric, delta := range c.Metrics {
+		if delta < 0 || math.IsNaN(delta) {
+			return fmt.Errorf("dedup delta of %s must not be negative, got %v", metric, delta)
+		}
+	}
+	if c.Heartbeat < 0 {
+		return fmt.Errorf("dedup heartbeat must not be negative, got %v", c.Heartbeat)
+	}
+	return nil
+}
+
+// dedupEntry - последнее опубликованное значение метрики
+type dedupEntry struct {
+	value       float64
+	implausible bool
+	publishedAt time.Time
+}
+
+// dedupFilter отбрасывает сообщения телеметрии, значение которых не изменилось.
+// Метрика разных ЭБУ сравнивается отдельно
+type dedupFilter struct {
+	mu        sync.Mutex
+	deltas    map[string]float64
+	heartbeat time.Duration
+	last      map[string]dedupEntry
+}
+
+// newDedupFilter создает фильтр; без метрик в конфигурации возвращает nil (фильтр выключен)
+func newDedupFilter(config DedupConfig) *dedupFilter {
+	if len(config.Metrics) == 0 {
+		return nil
+	}
+	return &dedupFilter{
+		deltas:    config.Metrics,
+		heartbeat: config.Heartbeat,
+		last:      make(map[string]dedupEntry),
+	}
+}
+
+// dedupKey возвращает ключ метрики в фильтре
+func dedupKey(msg *TelemetryMessage) string {
+	return msg.ECU + "/" + msg.Metric
+}
+
+// Allow проверяет, нужно ли публиковать сообщение. Отсчеты потокового режима и сырые
+// данные неподдерживаемых PID не фильтруются
+func (f *dedupFilter) Allow(msg *TelemetryMessage, now time.Time) bool {
+	if f == nil || msg.HighRate || msg.Data != "" {
+		return true
+	}
+	delta, filtered := f.deltas[msg.Metric]
+	if !filtered {
+		return true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	last, published := f.last[dedupKey(msg)]
+	switch {
+	case !published:
+		return true
+	case last.implausible != msg.Implausible:
+		return true
+	case f.heartbeat > 0 && now.Sub(last.publishedAt) >= f.heartbeat:
+		return true
+	case delta == 0:
+		return msg.Value != last.value
+	default:
+		return math.Abs(msg.Value-last.value) > delta
+	}
+}
+
+// Published запоминает опубликованное значение. Вызывается только после успешной
+// публикации, чтобы значение, не дошедшее до брокера, не подавляло следующие
+func (f *dedupFilter) Published(msg *TelemetryMessage, now time.Time) {
+	if f == nil {
+		return
+	}
+	if _, filtered := f.deltas[msg.Metric]; !filtered {
+		return
+	}
+
+	f.mu.Lock()
+	f.last[dedupKey(msg)] = dedupEntry{value: msg.Value, implausible: msg.Implausible, publishedAt: now}
+	f.mu.Unlock()
+}
+
+// Reset забывает опубликованные значения: после переподключения к брокеру или смены
+// VIN первое значение каждой метрики публикуется заново
+func (f *dedupFilter) Reset() {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	f.last = make(map[string]dedupEntry)
+	f.mu.Unlock()
+}
@@ -0,0 +1,85 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDedupConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  DedupConfig
+		wantErr bool
+	}{
+		{"empty", DedupConfig{}, false},
+		{"valid", DedupConfig{Metrics: map[string]float64{"fuel_level": 1, "barometric_pressure": 0}, Heartbeat: time.Minute}, false},
+		{"negative delta", DedupConfig{Metrics: map[string]float64{"fuel_level": -1}}, true},
+		{"negative heartbeat", DedupConfig{Heartbeat: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDedupFilter(t *testing.T) {
+	filter := newDedupFilter(DedupConfig{
+		Metrics:   map[string]float64{"fuel_level": 1, "barometric_pressure": 0},
+		Heartbeat: time.Minute,
+	})
+	start := time.Now()
+
+	steps := []struct {
+		name     string
+		msg      TelemetryMessage
+		offset   time.Duration
+		expected bool
+	}{
+		{"first value", TelemetryMessage{Metric: "fuel_level", Value: 50}, 0, true},
+		{"within delta", TelemetryMessage{Metric: "fuel_level", Value: 50.8}, time.Second, false},
+		{"beyond delta", TelemetryMessage{Metric: "fuel_level", Value: 48.5}, 2 * time.Second, true},
+		{"other ECU", TelemetryMessage{Metric: "fuel_level", Value: 48.5, ECU: "7E9"}, 3 * time.Second, true},
+		{"implausible flag changed", TelemetryMessage{Metric: "fuel_level", Value: 48.5, Implausible: true}, 4 * time.Second, true},
+		{"heartbeat", TelemetryMessage{Metric: "fuel_level", Value: 48.5, Implausible: true}, 2 * time.Minute, true},
+		{"zero delta same value", TelemetryMessage{Metric: "barometric_pressure", Value: 101}, 0, true},
+		{"zero delta unchanged", TelemetryMessage{Metric: "barometric_pressure", Value: 101}, time.Second, false},
+		{"zero delta changed", TelemetryMessage{Metric: "barometric_pressure", Value: 100}, 2 * time.Second, true},
+		{"unfiltered metric", TelemetryMessage{Metric: "engine_rpm", Value: 800}, 0, true},
+		{"unfiltered metric repeated", TelemetryMessage{Metric: "engine_rpm", Value: 800}, time.Second, true},
+		{"stream sample", TelemetryMessage{Metric: "barometric_pressure", Value: 100, HighRate: true}, 3 * time.Second, true},
+	}
+
+	for _, step := range steps {
+		msg := step.msg
+		now := start.Add(step.offset)
+		if allowed := filter.Allow(&msg, now); allowed != step.expected {
+			t.Errorf("%s: Allow() = %v, expected %v", step.name, allowed, step.expected)
+		}
+		if step.expected {
+			filter.Published(&msg, now)
+		}
+	}
+
+	filter.Reset()
+	if !filter.Allow(&TelemetryMessage{Metric: "barometric_pressure", Value: 100}, start.Add(4*time.Second)) {
+		t.Error("Expected value to be published after reset")
+	}
+}
+
+func TestDedupFilterDisabled(t *testing.T) {
+	filter := newDedupFilter(DedupConfig{})
+	if filter != nil {
+		t.Fatal("Expected filter to be disabled without metrics")
+	}
+
+	msg := &TelemetryMessage{Metric: "fuel_level", Value: 50}
+	filter.Published(msg, time.Now())
+	if !filter.Allow(msg, time.Now()) {
+		t.Error("Disabled filter must allow every message")
+	}
+	filter.Reset()
+}
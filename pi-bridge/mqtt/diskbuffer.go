@@ -0,0 +1,404 @@
+package mqtt
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Очередь на диске - каталог с файлами-сегментами JSON Lines (по сообщению телеметрии
+// в строке) и файлом позиции чтения. Сообщения дописываются в последний сегмент,
+// прочитанные сегменты удаляются целиком
+const (
+	segmentExt         = ".jsonl"
+	cursorFile         = "cursor"
+	segmentsPerBuffer  = 16          // Сегментов в очереди: при переполнении отбрасывается один сегмент
+	minSegmentBytes    = 64 * 1024   // Минимальный размер сегмента
+	cursorSaveInterval = 100         // Позиция чтения сохраняется каждые 100 публикаций и при остановке
+	defaultDiskSizeMB  = 100         // Размер очереди на диске по умолчанию
+	bytesPerMB         = 1024 * 1024 // Байт в мегабайте
+)
+
+// diskSegment - файл очереди на диске
+type diskSegment struct {
+	seq   uint64 // Номер сегмента, задает порядок файлов
+	size  int64  // Размер файла в байтах
+	count int    // Непрочитанных сообщений
+}
+
+// diskBuffer - очередь FIFO телеметрии на диске. Переживает перезапуск моста, поэтому
+// данные, собранные за долгое время без связи (подземная парковка, нет покрытия),
+// публикуются после появления связи в порядке получения и с исходным временем.
+// После аварийного завершения часть сообщений может быть опубликована повторно:
+// позиция чтения сохраняется не после каждой публикации
+type diskBuffer struct {
+	mu           sync.Mutex
+	dir          string
+	maxBytes     int64
+	segmentBytes int64
+	segments     []*diskSegment // От старого к новому; последний открыт на запись
+	writer       *os.File
+	offset       int64             // Позиция чтения в первом сегменте
+	head         *TelemetryMessage // Прочитанное, но еще не опубликованное сообщение
+	headSize     int64
+	count        int
+	dropped      int
+	popped       int // Публикаций с последнего сохранения позиции
+	logger       *log.Logger
+}
+
+// openDiskBuffer открывает очередь в каталоге dir, создавая его при необходимости.
+// Сообщения, сохраненные до перезапуска, остаются в очереди
+func openDiskBuffer(dir string, maxSizeMB int, logger *log.Logger) (*diskBuffer, error) {
+	if maxSizeMB <= 0 {
+		maxSizeMB = defaultDiskSizeMB
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create buffer directory: %w", err)
+	}
+
+	b := &diskBuffer{
+		dir:      dir,
+		maxBytes: int64(maxSizeMB) * bytesPerMB,
+		logger:   logger,
+	}
+	b.segmentBytes = max(b.maxBytes/segmentsPerBuffer, minSegmentBytes)
+
+	if err := b.load(); err != nil {
+		return nil, err
+	}
+	if err := b.openWriter(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// load находит сегменты, восстанавливает позицию чтения и считает непрочитанные сообщения
+func (b *diskBuffer) load() error {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read buffer directory: %w", err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, segmentExt) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		b.segments = append(b.segments, &diskSegment{seq: seq})
+	}
+	sort.Slice(b.segments, func(i, j int) bool { return b.segments[i].seq < b.segments[j].seq })
+
+	cursorSeq, cursorOffset := b.readCursor()
+	for len(b.segments) > 0 && b.segments[0].seq < cursorSeq {
+		// Сегмент прочитан, но не удален до остановки
+		os.Remove(b.segmentPath(b.segments[0].seq))
+		b.segments = b.segments[1:]
+	}
+
+	for i, segment := range b.segments {
+		from := int64(0)
+		if i == 0 && segment.seq == cursorSeq {
+			from = cursorOffset
+			b.offset = cursorOffset
+		}
+		if err := b.scan(segment, from); err != nil {
+			return err
+		}
+		b.count += segment.count
+	}
+
+	// Номера сегментов продолжаются после прочитанных, иначе новые сообщения
+	// при следующем запуске посчитались бы прочитанными
+	if len(b.segments) == 0 {
+		b.segments = append(b.segments, &diskSegment{seq: max(cursorSeq, 1)})
+	}
+	return nil
+}
+
+// scan определяет размер сегмента и количество сообщений после позиции from
+func (b *diskBuffer) scan(segment *diskSegment, from int64) error {
+	data, err := os.ReadFile(b.segmentPath(segment.seq))
+	if err != nil {
+		return fmt.Errorf("failed to read buffer segment: %w", err)
+	}
+	// Строка без перевода строки в конце - запись оборвалась при отключении питания
+	if complete := int64(bytes.LastIndexByte(data, '\n') + 1); complete < int64(len(data)) {
+		if err := os.Truncate(b.segmentPath(segment.seq), complete); err != nil {
+			return fmt.Errorf("failed to repair buffer segment: %w", err)
+		}
+		data = data[:complete]
+	}
+	segment.size = int64(len(data))
+	if from < segment.size {
+		segment.count = bytes.Count(data[from:], []byte{'\n'})
+	}
+	return nil
+}
+
+// openWriter открывает последний сегмент на запись
+func (b *diskBuffer) openWriter() error {
+	last := b.segments[len(b.segments)-1]
+	writer, err := os.OpenFile(b.segmentPath(last.seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open buffer segment: %w", err)
+	}
+	b.writer = writer
+	return nil
+}
+
+// rotate закрывает заполненный сегмент и начинает следующий
+func (b *diskBuffer) rotate() error {
+	b.writer.Sync()
+	b.writer.Close()
+	last := b.segments[len(b.segments)-1]
+	b.segments = append(b.segments, &diskSegment{seq: last.seq + 1})
+	return b.openWriter()
+}
+
+// Push дописывает сообщение в конец очереди. При превышении размера отбрасывается
+// самый старый сегмент
+func (b *diskBuffer) Push(msg *TelemetryMessage) bool {
+	line, err := json.Marshal(msg)
+	if err != nil {
+		b.logger.Printf("Failed to marshal buffered telemetry: %v", err)
+		return false
+	}
+	line = append(line, '\n')
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	last := b.segments[len(b.segments)-1]
+	if last.size > 0 && last.size+int64(len(line)) > b.segmentBytes {
+		if err := b.rotate(); err != nil {
+			b.logger.Printf("Failed to rotate telemetry buffer: %v", err)
+			return false
+		}
+		last = b.segments[len(b.segments)-1]
+	}
+
+	if _, err := b.writer.Write(line); err != nil {
+		b.logger.Printf("Failed to write telemetry buffer: %v", err)
+		return false
+	}
+	last.size += int64(len(line))
+	last.count++
+	b.count++
+
+	b.trim()
+	return true
+}
+
+// trim отбрасывает самые старые сегменты, пока очередь превышает допустимый размер
+func (b *diskBuffer) trim() {
+	for len(b.segments) > 1 && b.size() > b.maxBytes {
+		oldest := b.segments[0]
+		b.dropped += oldest.count
+		b.count -= oldest.count
+		os.Remove(b.segmentPath(oldest.seq))
+		b.segments = b.segments[1:]
+		b.offset = 0
+		b.head = nil
+		b.saveCursor()
+	}
+}
+
+// size возвращает размер сегментов на диске
+func (b *diskBuffer) size() int64 {
+	var total int64
+	for _, segment := range b.segments {
+		total += segment.size
+	}
+	return total
+}
+
+// Peek возвращает самое старое сообщение, не удаляя его (nil - очередь пуста).
+// Поврежденные строки (обрыв записи при отключении питания) пропускаются
+func (b *diskBuffer) Peek() *TelemetryMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for b.head == nil && b.count > 0 {
+		head := b.segments[0]
+		if b.offset >= head.size {
+			// Счетчик сегмента расходится с файлом: переходим к следующему
+			b.count -= head.count
+			head.count = 0
+			if !b.advance() {
+				b.count = 0
+				return nil
+			}
+			continue
+		}
+
+		line, err := b.readLine(head.seq, b.offset)
+		if err != nil {
+			b.logger.Printf("Failed to read telemetry buffer: %v", err)
+			return nil
+		}
+
+		var msg TelemetryMessage
+		if err := json.Unmarshal(bytes.TrimSpace(line), &msg); err != nil {
+			b.logger.Printf("Skipping corrupted buffered telemetry at %s:%d", b.segmentPath(head.seq), b.offset)
+			b.consume(int64(len(line)))
+			continue
+		}
+		b.head = &msg
+		b.headSize = int64(len(line))
+	}
+	return b.head
+}
+
+// readLine читает строку сегмента seq, начиная с позиции offset
+func (b *diskBuffer) readLine(seq uint64, offset int64) ([]byte, error) {
+	file, err := os.Open(b.segmentPath(seq))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var line []byte
+	chunk := make([]byte, 512)
+	for {
+		n, err := file.ReadAt(chunk, offset+int64(len(line)))
+		if i := bytes.IndexByte(chunk[:n], '\n'); i >= 0 {
+			return append(line, chunk[:i+1]...), nil
+		}
+		line = append(line, chunk[:n]...)
+		if err == io.EOF {
+			// Строка без перевода строки - запись оборвалась
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Pop удаляет самое старое сообщение после успешной публикации
+func (b *diskBuffer) Pop() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.head == nil {
+		return
+	}
+	b.head = nil
+	b.consume(b.headSize)
+
+	b.popped++
+	if b.popped >= cursorSaveInterval {
+		b.saveCursor()
+	}
+}
+
+// consume сдвигает позицию чтения за прочитанную строку
+func (b *diskBuffer) consume(size int64) {
+	head := b.segments[0]
+	b.offset += size
+	if head.count > 0 {
+		head.count--
+		b.count--
+	}
+	if b.offset >= head.size {
+		b.advance()
+	}
+}
+
+// advance удаляет прочитанный первый сегмент. Последний сегмент открыт на запись,
+// поэтому вместо его удаления запись начинается в новом. Возвращает false, если
+// удалять нечего
+func (b *diskBuffer) advance() bool {
+	head := b.segments[0]
+	if len(b.segments) == 1 {
+		if head.size == 0 || b.offset < head.size {
+			return false
+		}
+		if err := b.rotate(); err != nil {
+			b.logger.Printf("Failed to rotate telemetry buffer: %v", err)
+			return false
+		}
+	}
+
+	os.Remove(b.segmentPath(head.seq))
+	b.segments = b.segments[1:]
+	b.offset = 0
+	b.saveCursor()
+	return true
+}
+
+// Len возвращает количество сообщений в очереди
+func (b *diskBuffer) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.count
+}
+
+// TakeDropped возвращает количество отброшенных при переполнении сообщений и сбрасывает счетчик
+func (b *diskBuffer) TakeDropped() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	dropped := b.dropped
+	b.dropped = 0
+	return dropped
+}
+
+// Close сохраняет позицию чтения и закрывает сегмент, открытый на запись
+func (b *diskBuffer) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.saveCursor()
+	if err := b.writer.Sync(); err != nil {
+		b.writer.Close()
+		return err
+	}
+	return b.writer.Close()
+}
+
+// readCursor читает сохраненную позицию чтения: номер сегмента и смещение в нем
+func (b *diskBuffer) readCursor() (uint64, int64) {
+	data, err := os.ReadFile(filepath.Join(b.dir, cursorFile))
+	if err != nil {
+		return 0, 0
+	}
+	var seq uint64
+	var offset int64
+	if _, err := fmt.Sscanf(string(data), "%d %d", &seq, &offset); err != nil || offset < 0 {
+		return 0, 0
+	}
+	return seq, offset
+}
+
+// saveCursor сохраняет позицию чтения через временный файл, чтобы при отключении
+// питания не остался обрезанный файл позиции
+func (b *diskBuffer) saveCursor() {
+	b.popped = 0
+	path := filepath.Join(b.dir, cursorFile)
+	cursor := fmt.Sprintf("%d %d\n", b.segments[0].seq, b.offset)
+	if err := os.WriteFile(path+".tmp", []byte(cursor), 0o644); err != nil {
+		b.logger.Printf("Failed to save telemetry buffer position: %v", err)
+		return
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		b.logger.Printf("Failed to save telemetry buffer position: %v", err)
+	}
+}
+
+// segmentPath возвращает путь к файлу сегмента
+func (b *diskBuffer) segmentPath(seq uint64) string {
+	return filepath.Join(b.dir, fmt.Sprintf("%016d%s", seq, segmentExt))
+}
@@ -0,0 +1,131 @@
+package mqtt
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestDiskBuffer(t *testing.T, dir string, maxSizeMB int) *diskBuffer {
+	t.Helper()
+	buffer, err := openDiskBuffer(dir, maxSizeMB, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("Failed to open disk buffer: %v", err)
+	}
+	return buffer
+}
+
+// drain публикует все сообщения очереди и возвращает их значения
+func drain(buffer *diskBuffer) []float64 {
+	var values []float64
+	for msg := buffer.Peek(); msg != nil; msg = buffer.Peek() {
+		values = append(values, msg.Value)
+		buffer.Pop()
+	}
+	return values
+}
+
+func TestDiskBufferSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2025, 10, 8, 0, 28, 41, 0, time.UTC)
+
+	buffer := openTestDiskBuffer(t, dir, 1)
+	for i := 1; i <= 5; i++ {
+		buffer.Push(&TelemetryMessage{VIN: "TEST123", Metric: "vehicle_speed", Value: float64(i), Timestamp: start.Add(time.Duration(i) * time.Second)})
+	}
+	msg := buffer.Peek()
+	if msg == nil || msg.Value != 1 || !msg.Timestamp.Equal(start.Add(time.Second)) {
+		t.Fatalf("Expected first message with original timestamp, got %+v", msg)
+	}
+	buffer.Pop()
+	buffer.Peek()
+	buffer.Pop()
+	if err := buffer.Close(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// После перезапуска опубликованные сообщения не повторяются
+	buffer = openTestDiskBuffer(t, dir, 1)
+	if buffer.Len() != 3 {
+		t.Fatalf("Expected 3 messages after restart, got %d", buffer.Len())
+	}
+	buffer.Push(&TelemetryMessage{Value: 6})
+	if values := drain(buffer); len(values) != 4 || values[0] != 3 || values[3] != 6 {
+		t.Errorf("Expected values 3..6, got %v", values)
+	}
+	buffer.Close()
+
+	// Опустошенная очередь не считает новые сообщения прочитанными
+	buffer = openTestDiskBuffer(t, dir, 1)
+	if buffer.Len() != 0 {
+		t.Fatalf("Expected empty buffer, got %d", buffer.Len())
+	}
+	buffer.Push(&TelemetryMessage{Value: 7})
+	buffer.Close()
+
+	buffer = openTestDiskBuffer(t, dir, 1)
+	defer buffer.Close()
+	if values := drain(buffer); len(values) != 1 || values[0] != 7 {
+		t.Errorf("Expected value 7, got %v", values)
+	}
+}
+
+func TestDiskBufferOverflow(t *testing.T) {
+	dir := t.TempDir()
+	buffer := openTestDiskBuffer(t, dir, 1)
+	defer buffer.Close()
+
+	// Около 2 МБ сообщений при ограничении 1 МБ
+	raw := strings.Repeat("41 0C 1A F0 ", 85)
+	for i := 1; i <= 2000; i++ {
+		buffer.Push(&TelemetryMessage{Metric: "engine_rpm", Value: float64(i), Raw: raw})
+	}
+
+	if size := buffer.size(); size > buffer.maxBytes {
+		t.Errorf("Expected buffer within %d bytes, got %d", buffer.maxBytes, size)
+	}
+	dropped := buffer.TakeDropped()
+	if dropped == 0 || dropped+buffer.Len() != 2000 {
+		t.Fatalf("Expected dropped and kept messages to add up to 2000, got %d + %d", dropped, buffer.Len())
+	}
+
+	// Отбрасываются самые старые сообщения, порядок остальных сохраняется
+	values := drain(buffer)
+	if len(values) != 2000-dropped || values[0] != float64(dropped+1) || values[len(values)-1] != 2000 {
+		t.Errorf("Expected values %d..2000, got %d values from %v", dropped+1, len(values), values[0])
+	}
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if info, _ := entry.Info(); strings.HasSuffix(entry.Name(), segmentExt) && info.Size() > 0 {
+			t.Errorf("Expected published segments to be removed, found %s", entry.Name())
+		}
+	}
+}
+
+func TestDiskBufferRepairsTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	buffer := openTestDiskBuffer(t, dir, 1)
+	buffer.Push(&TelemetryMessage{Value: 1})
+	buffer.Push(&TelemetryMessage{Value: 2})
+	buffer.Close()
+
+	// Отключение питания во время записи оставляет строку без перевода строки
+	segment, err := os.OpenFile(filepath.Join(dir, "0000000000000001.jsonl"), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatalf("Failed to open segment: %v", err)
+	}
+	segment.WriteString(`{"vin":"TEST123","val`)
+	segment.Close()
+
+	buffer = openTestDiskBuffer(t, dir, 1)
+	defer buffer.Close()
+	buffer.Push(&TelemetryMessage{Value: 3})
+	if values := drain(buffer); len(values) != 3 || values[0] != 1 || values[2] != 3 {
+		t.Errorf("Expected values 1 2 3, got %v", values)
+	}
+}
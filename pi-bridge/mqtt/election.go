@@ -0,0 +1,192 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	mqttLib "github.com/eclipse/paho.mqtt.golang"
+)
+
+// ElectionConfig представляет настройки выбора активного моста при резервировании
+type ElectionConfig struct {
+	Enabled bool          `yaml:"enabled"` // Включить выбор лидера между несколькими мостами
+	NodeID  string        `yaml:"node_id"` // Идентификатор узла (по умолчанию client_id)
+	Lease   time.Duration `yaml:"lease"`   // Срок действия заявки лидера
+}
+
+// LeaderClaim представляет заявку лидера, публикуемую как retained сообщение
+type LeaderClaim struct {
+	Node      string    `json:"node"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Election реализует выбор активного моста: лидер периодически продлевает заявку,
+// резервный узел занимает адаптер, только когда заявка лидера истекла.
+// При одновременных заявках побеждает узел с меньшим идентификатором
+type Election struct {
+	mu            sync.Mutex
+	nodeID        string
+	lease         time.Duration
+	leader        string    // Текущий лидер среди других узлов
+	leaderExpires time.Time // Срок действия его заявки
+	isLeader      bool
+	onChange      func(leader bool)
+	logger        *log.Logger
+}
+
+// NewElection создает участника выбора лидера
+func NewElection(nodeID string, lease time.Duration, onChange func(leader bool)) *Election {
+	return &Election{
+		nodeID:   nodeID,
+		lease:    lease,
+		onChange: onChange,
+		logger:   log.New(os.Stdout, "[MQTT-Election] ", log.LstdFlags|log.Lshortfile),
+	}
+}
+
+// IsLeader возвращает true, если этот узел владеет адаптером
+func (e *Election) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.isLeader
+}
+
+// HandleClaim обрабатывает заявку, полученную из топика выбора лидера
+func (e *Election) HandleClaim(claim LeaderClaim, now time.Time) {
+	e.mu.Lock()
+
+	// Собственные заявки (в том числе оставшиеся от прошлого запуска) игнорируем
+	if claim.Node == e.nodeID {
+		e.mu.Unlock()
+		return
+	}
+
+	// Пустое сообщение или истекшая заявка означают, что лидера нет
+	if claim.Node == "" || !now.Before(claim.ExpiresAt) {
+		e.leader = ""
+		e.leaderExpires = time.Time{}
+		e.mu.Unlock()
+		return
+	}
+
+	e.leader = claim.Node
+	e.leaderExpires = claim.ExpiresAt
+
+	// Конфликт заявок разрешается в пользу меньшего идентификатора
+	stepDown := e.isLeader && claim.Node < e.nodeID
+	if stepDown {
+		e.isLeader = false
+		e.logger.Printf("Node %s has priority, stepping down", claim.Node)
+	}
+	e.mu.Unlock()
+
+	if stepDown {
+		e.notify(false)
+	}
+}
+
+// Tick вызывается периодически и возвращает заявку для публикации (nil в резерве)
+func (e *Election) Tick(now time.Time) *LeaderClaim {
+	e.mu.Lock()
+
+	if !e.isLeader {
+		if e.leader != "" && now.Before(e.leaderExpires) {
+			e.mu.Unlock()
+			return nil
+		}
+		e.isLeader = true
+		e.leader = ""
+		e.logger.Printf("No active leader, node %s takes over", e.nodeID)
+		e.mu.Unlock()
+		e.notify(true)
+		e.mu.Lock()
+	}
+
+	claim := &LeaderClaim{Node: e.nodeID, ExpiresAt: now.Add(e.lease)}
+	e.mu.Unlock()
+	return claim
+}
+
+// StepDown освобождает лидерство (при потере связи с брокером или остановке)
+func (e *Election) StepDown() {
+	e.mu.Lock()
+	wasLeader := e.isLeader
+	e.isLeader = false
+	e.mu.Unlock()
+
+	if wasLeader {
+		e.logger.Printf("Node %s steps down", e.nodeID)
+		e.notify(false)
+	}
+}
+
+// notify сообщает об изменении роли (вызывается без мьютекса)
+func (e *Election) notify(leader bool) {
+	if e.onChange != nil {
+		e.onChange(leader)
+	}
+}
+
+// electionTopic возвращает топик заявок лидера
+func (c *Client) electionTopic() string {
+	// У каждого автомобиля свои резервные мосты и свой лидер
+	if c.vin != "" {
+		return c.statusTopic("election")
+	}
+	return c.config.StatusTopic + "/election"
+}
+
+// onLeaderClaim обрабатывает сообщения в топике выбора лидера
+func (c *Client) onLeaderClaim(client mqttLib.Client, msg mqttLib.Message) {
+	var claim LeaderClaim
+	if len(msg.Payload()) > 0 {
+		if err := json.Unmarshal(msg.Payload(), &claim); err != nil {
+			c.logger.Printf("Invalid leader claim: %v", err)
+			return
+		}
+	}
+	c.election.HandleClaim(claim, time.Now())
+}
+
+// electionLoop продлевает заявку лидера или проверяет, не пора ли занять адаптер
+func (c *Client) electionLoop() {
+	defer c.wg.Done()
+
+	ticker := time.NewTicker(c.config.Election.Lease / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case now := <-ticker.C:
+			if !c.IsConnected() {
+				continue
+			}
+			if claim := c.election.Tick(now); claim != nil {
+				c.publishLeaderClaim(claim)
+			}
+		}
+	}
+}
+
+// publishLeaderClaim публикует заявку лидера (nil - отказ от лидерства)
+func (c *Client) publishLeaderClaim(claim *LeaderClaim) {
+	var payload []byte
+	if claim != nil {
+		var err error
+		if payload, err = json.Marshal(claim); err != nil {
+			c.logger.Printf("Failed to marshal leader claim: %v", err)
+			return
+		}
+	}
+
+	token := c.mqttClient.Publish(c.electionTopic(), c.config.QoS, true, payload)
+	token.Wait()
+	if token.Error() != nil {
+		c.logger.Printf("Failed to publish leader claim: %v", token.Error())
+	}
+}
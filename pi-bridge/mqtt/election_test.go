@@ -0,0 +1,85 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+)
+
+func TestElectionTakeoverWithoutLeader(t *testing.T) {
+	var changes []bool
+	election := NewElection("bridge-b", 15*time.Second, func(leader bool) { changes = append(changes, leader) })
+	now := time.Now()
+
+	claim := election.Tick(now)
+	if claim == nil || claim.Node != "bridge-b" || !claim.ExpiresAt.Equal(now.Add(15*time.Second)) {
+		t.Fatalf("Expected claim from bridge-b, got %+v", claim)
+	}
+	if !election.IsLeader() || len(changes) != 1 || !changes[0] {
+		t.Errorf("Expected node to become leader, changes: %v", changes)
+	}
+
+	// Лидер продлевает заявку на каждом тике без повторного уведомления
+	if claim := election.Tick(now.Add(5 * time.Second)); claim == nil {
+		t.Error("Expected leader to renew claim")
+	}
+	if len(changes) != 1 {
+		t.Errorf("Expected single leadership change, got %v", changes)
+	}
+}
+
+func TestElectionStandbyWhileLeaderAlive(t *testing.T) {
+	election := NewElection("bridge-b", 15*time.Second, nil)
+	now := time.Now()
+
+	election.HandleClaim(LeaderClaim{Node: "bridge-a", ExpiresAt: now.Add(15 * time.Second)}, now)
+	if claim := election.Tick(now.Add(5 * time.Second)); claim != nil {
+		t.Errorf("Expected standby while leader claim is valid, got %+v", claim)
+	}
+
+	// Заявка лидера истекла - резервный узел занимает адаптер
+	if claim := election.Tick(now.Add(16 * time.Second)); claim == nil || !election.IsLeader() {
+		t.Error("Expected failover after leader claim expired")
+	}
+}
+
+func TestElectionConflictResolution(t *testing.T) {
+	now := time.Now()
+
+	// Узел с большим идентификатором уступает
+	var changes []bool
+	b := NewElection("bridge-b", 15*time.Second, func(leader bool) { changes = append(changes, leader) })
+	b.Tick(now)
+	b.HandleClaim(LeaderClaim{Node: "bridge-a", ExpiresAt: now.Add(15 * time.Second)}, now)
+	if b.IsLeader() {
+		t.Error("Expected bridge-b to step down in favour of bridge-a")
+	}
+	if len(changes) != 2 || changes[1] {
+		t.Errorf("Expected leader then standby notifications, got %v", changes)
+	}
+
+	// Узел с меньшим идентификатором сохраняет лидерство
+	a := NewElection("bridge-a", 15*time.Second, nil)
+	a.Tick(now)
+	a.HandleClaim(LeaderClaim{Node: "bridge-b", ExpiresAt: now.Add(15 * time.Second)}, now)
+	if !a.IsLeader() {
+		t.Error("Expected bridge-a to keep leadership")
+	}
+}
+
+func TestElectionReleasedClaim(t *testing.T) {
+	election := NewElection("bridge-b", 15*time.Second, nil)
+	now := time.Now()
+
+	election.HandleClaim(LeaderClaim{Node: "bridge-a", ExpiresAt: now.Add(15 * time.Second)}, now)
+	// Пустое retained сообщение - лидер освободил адаптер
+	election.HandleClaim(LeaderClaim{}, now)
+
+	if claim := election.Tick(now.Add(time.Second)); claim == nil {
+		t.Error("Expected immediate takeover after leader released claim")
+	}
+
+	election.StepDown()
+	if election.IsLeader() {
+		t.Error("Expected node to be standby after StepDown")
+	}
+}
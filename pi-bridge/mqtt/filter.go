@@ -0,0 +1,100 @@
+package mqtt
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"elm327-bridge/common"
+)
+
+// CommandFilterConfig задает списки команд, принимаемых из топика команд. Элементы списков -
+// регулярные выражения, которые сравниваются с командой ELM327 целиком (без пробелов, без учета
+// регистра), поэтому точная команда вроде "ATRV" тоже является допустимым элементом.
+// Проверяются команды после раскрытия служебных команд моста
+type CommandFilterConfig struct {
+	Allow []string `yaml:"allow"` // Разрешенные команды (пусто - любые, кроме запрещенных)
+	Deny  []string `yaml:"deny"`  // Запрещенные команды, проверяются раньше разрешенных
+}
+
+// defaultDeniedCommands - команды, запрещенные по умолчанию: перепрограммирование
+// адаптера и стирание кодов неисправностей (Mode 04)
+var defaultDeniedCommands = []string{"ATPP.*", "04.*"}
+
+// Validate проверяет регулярные выражения списков
+func (c CommandFilterConfig) Validate() error {
+	_, err := newCommandFilter(c)
+	return err
+}
+
+// commandFilter - скомпилированные списки разрешенных и запрещенных команд
+type commandFilter struct {
+	allow []*regexp.Regexp
+	deny  []*regexp.Regexp
+}
+
+// newCommandFilter компилирует списки; при пустых списках возвращает nil (фильтр выключен)
+func newCommandFilter(config CommandFilterConfig) (*commandFilter, error) {
+	if len(config.Allow) == 0 && len(config.Deny) == 0 {
+		return nil, nil
+	}
+	allow, err := compileCommandPatterns("allow", config.Allow)
+	if err != nil {
+		return nil, err
+	}
+	deny, err := compileCommandPatterns("deny", config.Deny)
+	if err != nil {
+		return nil, err
+	}
+	return &commandFilter{allow: allow, deny: deny}, nil
+}
+
+// denyAllCommands возвращает фильтр, запрещающий любые команды
+func denyAllCommands() *commandFilter {
+	return &commandFilter{deny: []*regexp.Regexp{regexp.MustCompile(`.*`)}}
+}
+
+// compileCommandPatterns компилирует выражения так, чтобы они совпадали с командой целиком
+func compileCommandPatterns(list string, patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(`(?i)^(?:` + pattern + `)$`)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mqtt command_filter %s pattern %q: %v", list, pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+	return compiled, nil
+}
+
+// Check возвращает ошибку, если команда запрещена. Nil-фильтр разрешает любые команды
+func (f *commandFilter) Check(command string) error {
+	if f == nil {
+		return nil
+	}
+	cmd := strings.ToUpper(strings.Join(strings.Fields(command), ""))
+	for _, re := range f.deny {
+		if re.MatchString(cmd) {
+			return fmt.Errorf("command %q rejected: denied by command filter", command)
+		}
+	}
+	if len(f.allow) == 0 {
+		return nil
+	}
+	for _, re := range f.allow {
+		if re.MatchString(cmd) {
+			return nil
+		}
+	}
+	return fmt.Errorf("command %q rejected: not in command filter allow list", command)
+}
+
+// checkCommand проверяет команду из MQTT режимом только чтения и фильтром команд
+func (c *Client) checkCommand(command string) error {
+	if c.config.ReadOnly {
+		if err := common.CheckReadOnly(command, true); err != nil {
+			return err
+		}
+	}
+	return c.commandFilter.Check(command)
+}
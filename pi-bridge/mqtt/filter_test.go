@@ -0,0 +1,160 @@
+package mqtt
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"elm327-bridge/common"
+	"elm327-bridge/obd"
+)
+
+func TestCommandFilterCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  CommandFilterConfig
+		command string
+		allowed bool
+	}{
+		{"disabled", CommandFilterConfig{}, "04", true},
+		{"default read", DefaultConfig().CommandFilter, "010C", true},
+		{"default clear DTCs", DefaultConfig().CommandFilter, "04", false},
+		{"default programming", DefaultConfig().CommandFilter, "AT PP 0C SV 23", false},
+		{"default other AT", DefaultConfig().CommandFilter, "ATRV", true},
+		{"explicit allow", CommandFilterConfig{Allow: []string{"ATRV", "01[0-9A-F]{2}"}}, "atrv", true},
+		{"allow pattern", CommandFilterConfig{Allow: []string{"ATRV", "01[0-9A-F]{2}"}}, "01 0C", true},
+		{"allow matches whole command", CommandFilterConfig{Allow: []string{"ATRV", "01[0-9A-F]{2}"}}, "ATRV1", false},
+		{"not allowed", CommandFilterConfig{Allow: []string{"ATRV"}}, "0902", false},
+		{"deny wins", CommandFilterConfig{Allow: []string{".*"}, Deny: []string{"04"}}, "04", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filter, err := newCommandFilter(tt.config)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if err := filter.Check(tt.command); (err == nil) != tt.allowed {
+				t.Errorf("Check(%q) error = %v, allowed %v", tt.command, err, tt.allowed)
+			}
+		})
+	}
+}
+
+func TestCommandFilterValidate(t *testing.T) {
+	if err := DefaultConfig().CommandFilter.Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+	if err := (CommandFilterConfig{Deny: []string{"AT("}}).Validate(); err == nil {
+		t.Error("Expected error for invalid pattern")
+	}
+}
+
+func TestInvalidCommandFilterDeniesCommands(t *testing.T) {
+	config := DefaultConfig()
+	config.CommandFilter = CommandFilterConfig{Allow: []string{"AT("}}
+
+	commandsChan := make(chan string, 10)
+	responsesChan := make(chan CommandResponse, 10)
+	client := NewClient(config, make(chan common.Telemetry), commandsChan, responsesChan, make(chan common.StatusEvent))
+
+	client.onCommandReceived(nil, &testMessage{topic: "car/command/TEST123/request", payload: []byte(`{"command":"ATRV","correlation_id":"voltage"}`)})
+	if len(commandsChan) != 0 {
+		t.Errorf("Expected command to be rejected by invalid filter, got %s forwarded", <-commandsChan)
+	}
+	select {
+	case response := <-responsesChan:
+		if response.Status != "error" {
+			t.Errorf("Expected rejection response, got %+v", response)
+		}
+	default:
+		t.Error("Expected rejection response")
+	}
+
+	if err := client.Start(context.Background()); err == nil || !strings.Contains(err.Error(), "command_filter") {
+		t.Errorf("Expected Start to fail on invalid command filter, got %v", err)
+	}
+}
+
+func TestOnCommandReceivedFiltered(t *testing.T) {
+	config := DefaultConfig()
+	config.CommandFilter = CommandFilterConfig{Allow: []string{"ATRV", "01[0-9A-F]{2}"}}
+
+	commandsChan := make(chan string, 10)
+	responsesChan := make(chan CommandResponse, 10)
+	client := NewClient(config, make(chan common.Telemetry), commandsChan, responsesChan, make(chan common.StatusEvent))
+
+	client.onCommandReceived(nil, &testMessage{topic: "car/command/TEST123/request", payload: []byte(`{"command":"ATSP6","correlation_id":"protocol"}`)})
+	select {
+	case cmd := <-commandsChan:
+		t.Errorf("Expected command to be rejected, got %s forwarded", cmd)
+	default:
+	}
+	select {
+	case response := <-responsesChan:
+		if response.CorrelationID != "protocol" || response.Status != "error" || response.Error == "" {
+			t.Errorf("Expected rejection response, got %+v", response)
+		}
+	default:
+		t.Error("Expected rejection response")
+	}
+
+	client.onCommandReceived(nil, &testMessage{topic: "car/command/TEST123/request", payload: []byte(`{"command":"ATRV","correlation_id":"voltage"}`)})
+	select {
+	case cmd := <-commandsChan:
+		if cmd != "ATRV" {
+			t.Errorf("Expected ATRV to be forwarded, got %s", cmd)
+		}
+	default:
+		t.Error("Expected ATRV to be forwarded")
+	}
+}
+
+func TestRejectedBridgeCommandHasNoEffect(t *testing.T) {
+	config := DefaultConfig()
+	config.CommandFilter = CommandFilterConfig{Allow: []string{"ATRV"}}
+
+	commandsChan := make(chan string, 10)
+	responsesChan := make(chan CommandResponse, 10)
+	client := NewClient(config, make(chan common.Telemetry), commandsChan, responsesChan, make(chan common.StatusEvent))
+
+	sniffer := obd.NewSniffer(obd.SnifferConfig{}, func(common.CANFrame) {})
+	streamer := obd.NewStreamer(commandsChan)
+	commands := obd.NewBridgeCommands()
+	commands.Register(obd.SniffCommand, sniffer.HandleCommand)
+	commands.Register(obd.StreamCommand, streamer.HandleCommand)
+	client.SetBridgeCommands(commands)
+
+	for _, command := range []string{"SNIFF 600", "STREAM 0C 60"} {
+		payload := []byte(`{"command":"` + command + `","correlation_id":"rejected"}`)
+		client.onCommandReceived(nil, &testMessage{topic: "car/command/TEST123/request", payload: payload})
+
+		select {
+		case response := <-responsesChan:
+			if response.Status != "error" {
+				t.Errorf("%s: expected rejection response, got %+v", command, response)
+			}
+		default:
+			t.Errorf("%s: expected rejection response", command)
+		}
+	}
+
+	if len(commandsChan) != 0 {
+		t.Errorf("Expected no commands sent to adapter, got %d", len(commandsChan))
+	}
+	if sniffer.Active() {
+		t.Error("Expected rejected SNIFF to leave sniffer inactive")
+	}
+	if _, active := streamer.Active(); active {
+		t.Error("Expected rejected STREAM to leave streamer inactive")
+	}
+
+	// Разрешенная команда запускает режим
+	config.CommandFilter = CommandFilterConfig{}
+	client = NewClient(config, make(chan common.Telemetry), commandsChan, responsesChan, make(chan common.StatusEvent))
+	client.SetBridgeCommands(commands)
+	client.onCommandReceived(nil, &testMessage{topic: "car/command/TEST123/request", payload: []byte(`{"command":"STREAM 0C 60","correlation_id":"stream"}`)})
+	if pid, active := streamer.Active(); !active || pid != "0C" {
+		t.Errorf("Expected accepted STREAM to start streaming 0C, got %q (%v)", pid, active)
+	}
+}
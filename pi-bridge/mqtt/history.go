@@ -0,0 +1,132 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Статусы записей истории команд, дополняющие статусы ответов success/error
+const (
+	HistoryStatusPending = "pending" // Команда получена
+	HistoryStatusSent    = "sent"    // Команда передана адаптеру
+)
+
+// CommandHistoryEntry представляет выполненную удаленную команду и ее результат
+type CommandHistoryEntry struct {
+	CorrelationID string      `json:"correlation_id,omitempty"`
+	Command       string      `json:"command"`
+	Status        string      `json:"status"`
+	Result        interface{} `json:"result,omitempty"`
+	Error         string      `json:"error,omitempty"`
+	ReceivedAt    time.Time   `json:"received_at"`
+	CompletedAt   *time.Time  `json:"completed_at,omitempty"`
+}
+
+// CommandHistory хранит последние N команд для клиентов, переподключившихся после перерыва
+type CommandHistory struct {
+	mu      sync.RWMutex
+	size    int
+	entries []CommandHistoryEntry // От старых к новым
+	changed chan struct{}         // Сигнал об изменении для публикации (не блокирует запись)
+}
+
+// NewCommandHistory создает историю на size записей
+func NewCommandHistory(size int) *CommandHistory {
+	return &CommandHistory{
+		size:    size,
+		changed: make(chan struct{}, 1),
+	}
+}
+
+// Record добавляет полученную команду, вытесняя самую старую запись
+func (h *CommandHistory) Record(correlationID, command string) {
+	h.mu.Lock()
+	h.entries = append(h.entries, CommandHistoryEntry{
+		CorrelationID: correlationID,
+		Command:       command,
+		Status:        HistoryStatusPending,
+		ReceivedAt:    time.Now(),
+	})
+	if len(h.entries) > h.size {
+		h.entries = h.entries[len(h.entries)-h.size:]
+	}
+	h.mu.Unlock()
+	h.notify()
+}
+
+// SetStatus обновляет статус последней записи с заданным correlation ID
+func (h *CommandHistory) SetStatus(correlationID, status string) {
+	h.update(correlationID, func(entry *CommandHistoryEntry) {
+		entry.Status = status
+	})
+}
+
+// Complete записывает итоговый ответ на команду
+func (h *CommandHistory) Complete(response CommandResponse) {
+	h.update(response.CorrelationID, func(entry *CommandHistoryEntry) {
+		completedAt := response.Timestamp
+		entry.Status = response.Status
+		entry.Result = response.Result
+		entry.Error = response.Error
+		entry.CompletedAt = &completedAt
+	})
+}
+
+// update применяет изменение к последней записи с заданным correlation ID
+func (h *CommandHistory) update(correlationID string, apply func(entry *CommandHistoryEntry)) {
+	if correlationID == "" {
+		return
+	}
+
+	h.mu.Lock()
+	found := false
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		if h.entries[i].CorrelationID == correlationID {
+			apply(&h.entries[i])
+			found = true
+			break
+		}
+	}
+	h.mu.Unlock()
+
+	if found {
+		h.notify()
+	}
+}
+
+// Entries возвращает копию истории от старых записей к новым
+func (h *CommandHistory) Entries() []CommandHistoryEntry {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	entries := make([]CommandHistoryEntry, len(h.entries))
+	copy(entries, h.entries)
+	return entries
+}
+
+// Changed возвращает канал сигналов об изменении истории
+func (h *CommandHistory) Changed() <-chan struct{} {
+	return h.changed
+}
+
+// notify сигнализирует об изменении; несколько изменений подряд сливаются в одно
+func (h *CommandHistory) notify() {
+	select {
+	case h.changed <- struct{}{}:
+	default:
+	}
+}
+
+// ServeHTTP отдает историю команд в формате JSON (GET /api/commands/history)
+func (h *CommandHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(h.Entries()); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,77 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCommandHistoryRecordAndComplete(t *testing.T) {
+	history := NewCommandHistory(2)
+
+	history.Record("req-1", "010C")
+	history.SetStatus("req-1", HistoryStatusSent)
+	history.Complete(CommandResponse{CorrelationID: "req-1", Status: "success", Result: "41 0C 1A F0", Timestamp: time.Now()})
+
+	entries := history.Entries()
+	if len(entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(entries))
+	}
+	if entries[0].Status != "success" || entries[0].Result != "41 0C 1A F0" || entries[0].CompletedAt == nil {
+		t.Errorf("Unexpected entry: %+v", entries[0])
+	}
+
+	select {
+	case <-history.Changed():
+	default:
+		t.Error("Expected change notification")
+	}
+}
+
+func TestCommandHistoryEviction(t *testing.T) {
+	history := NewCommandHistory(2)
+	history.Record("req-1", "010C")
+	history.Record("req-2", "010D")
+	history.Record("req-3", "0105")
+
+	entries := history.Entries()
+	if len(entries) != 2 || entries[0].CorrelationID != "req-2" || entries[1].CorrelationID != "req-3" {
+		t.Errorf("Expected the two newest entries, got %+v", entries)
+	}
+
+	// Ответ на вытесненную команду игнорируется
+	history.Complete(CommandResponse{CorrelationID: "req-1", Status: "success"})
+	for _, entry := range history.Entries() {
+		if entry.Status != HistoryStatusPending {
+			t.Errorf("Unexpected status change: %+v", entry)
+		}
+	}
+}
+
+func TestCommandHistoryServeHTTP(t *testing.T) {
+	history := NewCommandHistory(5)
+	history.Record("req-1", "ATRV")
+
+	recorder := httptest.NewRecorder()
+	history.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/api/commands/history", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", recorder.Code)
+	}
+
+	var entries []CommandHistoryEntry
+	if err := json.Unmarshal(recorder.Body.Bytes(), &entries); err != nil {
+		t.Fatalf("Invalid JSON: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Command != "ATRV" {
+		t.Errorf("Unexpected entries: %+v", entries)
+	}
+
+	recorder = httptest.NewRecorder()
+	history.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/api/commands/history", nil))
+	if recorder.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for POST, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,135 @@
+package mqtt
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+
+	mqttLib "github.com/eclipse/paho.mqtt.golang"
+)
+
+// legacyQueueSize - количество сырых ответов, ожидающих публикации в устаревшем формате
+const legacyQueueSize = 100
+
+// LegacyConfig представляет режим совместимости с устаревшим форматом топиков:
+// первые версии моста обменивались сырыми кадрами ELM327 в base64 без VIN в топике.
+// Режим работает параллельно с JSON форматом, чтобы потребители переходили постепенно
+type LegacyConfig struct {
+	Enabled      bool   `yaml:"enabled"`       // Включить устаревшие топики
+	DataTopic    string `yaml:"data_topic"`    // Топик сырых ответов адаптера (base64)
+	CommandTopic string `yaml:"command_topic"` // Топик сырых команд адаптеру (base64)
+}
+
+// DefaultLegacyConfig возвращает топики, которые использовали первые версии моста
+func DefaultLegacyConfig() LegacyConfig {
+	return LegacyConfig{
+		DataTopic:    "elm327/data",
+		CommandTopic: "elm327/command",
+	}
+}
+
+// EncodeLegacyFrame кодирует ответ адаптера в устаревший формат (base64 сырых байт)
+func EncodeLegacyFrame(response string) string {
+	return base64.StdEncoding.EncodeToString([]byte(response))
+}
+
+// DecodeLegacyCommands декодирует base64 кадр команды в команды ELM327.
+// Кадр может содержать несколько команд, разделенных "\r" или "\n"
+func DecodeLegacyCommands(payload []byte) ([]string, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(payload)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64 command: %v", err)
+	}
+
+	var commands []string
+	for _, line := range strings.FieldsFunc(string(decoded), func(r rune) bool { return r == '\r' || r == '\n' }) {
+		if command := strings.TrimSpace(line); command != "" {
+			commands = append(commands, command)
+		}
+	}
+	if len(commands) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return commands, nil
+}
+
+// PublishRawResponse ставит сырой ответ адаптера в очередь публикации в устаревшем формате.
+// Вызывается из цикла чтения адаптера, поэтому не блокируется
+func (c *Client) PublishRawResponse(command, response string) {
+	if !c.config.Legacy.Enabled {
+		return
+	}
+
+	select {
+	case c.legacyChan <- response:
+	default:
+		c.logger.Printf("Warning: legacy queue is full, dropping raw response to %s", command)
+	}
+}
+
+// onLegacyCommand обрабатывает команду в устаревшем формате (base64 без correlation ID)
+func (c *Client) onLegacyCommand(client mqttLib.Client, msg mqttLib.Message) {
+	if !c.IsLeader() {
+		return
+	}
+
+	// Устаревший формат не содержит подписи
+	if c.config.Auth.Secret != "" {
+		c.logger.Printf("Rejected legacy command: signature required")
+		return
+	}
+
+	commands, err := DecodeLegacyCommands(msg.Payload())
+	if err != nil {
+		c.logger.Printf("Failed to decode legacy command: %v", err)
+		return
+	}
+
+	for _, command := range commands {
+		if err := c.checkCommand(command); err != nil {
+			c.logger.Printf("Rejected legacy command: %v", err)
+			return
+		}
+
+		select {
+		case c.commandsChan <- command:
+			c.logger.Printf("Legacy command sent to Bluetooth: %s", command)
+		case <-time.After(5 * time.Second):
+			c.logger.Printf("Timeout sending legacy command to Bluetooth: %s", command)
+			return
+		}
+	}
+}
+
+// publishLegacyLoop публикует сырые ответы адаптера в устаревший топик данных
+func (c *Client) publishLegacyLoop() {
+	defer c.wg.Done()
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case response := <-c.legacyChan:
+			if err := c.publishLegacyFrame(response); err != nil {
+				c.logger.Printf("Failed to publish legacy frame: %v", err)
+			}
+		}
+	}
+}
+
+// publishLegacyFrame публикует один ответ адаптера в base64
+func (c *Client) publishLegacyFrame(response string) error {
+	if c.mqttClient == nil || !c.mqttClient.IsConnected() {
+		return fmt.Errorf("MQTT client not connected")
+	}
+
+	topic := c.config.Legacy.DataTopic
+	token := c.mqttClient.Publish(topic, c.config.QoS, false, EncodeLegacyFrame(response))
+	token.Wait()
+
+	if token.Error() != nil {
+		return fmt.Errorf("failed to publish legacy frame to topic %s: %v", topic, token.Error())
+	}
+	return nil
+}
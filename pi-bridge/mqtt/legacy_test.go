@@ -0,0 +1,90 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+// Кадры из тестов первых версий моста, обменивавшихся сырыми данными в base64
+func TestEncodeLegacyFrame(t *testing.T) {
+	if encoded := EncodeLegacyFrame("0100>41 00 BE 7F"); encoded != "MDEwMD40MSAwMCBCRSA3Rg==" {
+		t.Errorf("Unexpected legacy frame: %s", encoded)
+	}
+}
+
+func TestDecodeLegacyCommands(t *testing.T) {
+	tests := []struct {
+		payload     string
+		expected    []string
+		expectError bool
+	}{
+		{"QVRa", []string{"ATZ"}, false},                      // "ATZ"
+		{"QVRaDQ==", []string{"ATZ"}, false},                  // "ATZ\r"
+		{"QVRFMA0wMTBDDQ==", []string{"ATE0", "010C"}, false}, // "ATE0\r010C\r"
+		{"invalid_base64", nil, true},
+		{"DQ==", nil, true}, // Только "\r"
+	}
+
+	for _, tt := range tests {
+		commands, err := DecodeLegacyCommands([]byte(tt.payload))
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("%s: expected error, got %v", tt.payload, commands)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tt.payload, err)
+			continue
+		}
+		if len(commands) != len(tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.payload, tt.expected, commands)
+			continue
+		}
+		for i := range commands {
+			if commands[i] != tt.expected[i] {
+				t.Errorf("%s: expected %v, got %v", tt.payload, tt.expected, commands)
+			}
+		}
+	}
+}
+
+func TestOnLegacyCommand(t *testing.T) {
+	config := DefaultConfig()
+	config.ReadOnly = true
+	config.Legacy.Enabled = true
+
+	commandsChan := make(chan string, 10)
+	client := NewClient(config, make(chan common.Telemetry), commandsChan, make(chan CommandResponse, 1), make(chan common.StatusEvent))
+
+	client.onLegacyCommand(nil, &testMessage{topic: "elm327/command", payload: []byte("MDEwQw==")}) // "010C"
+	client.onLegacyCommand(nil, &testMessage{topic: "elm327/command", payload: []byte("QVRa")})     // "ATZ" запрещен
+
+	if len(commandsChan) != 1 || <-commandsChan != "010C" {
+		t.Errorf("Expected only 010C to be forwarded")
+	}
+}
+
+func TestPublishRawResponse(t *testing.T) {
+	config := DefaultConfig()
+	client := NewClient(config, make(chan common.Telemetry), make(chan string), make(chan CommandResponse), make(chan common.StatusEvent))
+
+	// Без режима совместимости ответы не ставятся в очередь
+	client.PublishRawResponse("0100", "41 00 BE 7F")
+	if len(client.legacyChan) != 0 {
+		t.Error("Expected raw responses to be ignored when legacy mode is disabled")
+	}
+
+	client.config.Legacy.Enabled = true
+	client.PublishRawResponse("0100", "41 00 BE 7F")
+	select {
+	case response := <-client.legacyChan:
+		if response != "41 00 BE 7F" {
+			t.Errorf("Unexpected queued response: %q", response)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Error("Expected raw response to be queued")
+	}
+}
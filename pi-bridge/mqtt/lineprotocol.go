@@ -0,0 +1,61 @@
+package mqtt
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// lineProtocol формирует строку InfluxDB line protocol: измерение - название метрики,
+// теги vin, pid, ecu и unit, поле value и время в наносекундах, например
+// engine_rpm,vin=WF0XXXTTGXAB12345,pid=0C,unit=rpm value=1726 1759883321000000000.
+// Сырые данные неподдерживаемых PID публикуются строковым полем data
+func lineProtocol(msg *TelemetryMessage) ([]byte, error) {
+	if msg.Metric == "" {
+		return nil, fmt.Errorf("telemetry without metric name")
+	}
+
+	var line strings.Builder
+	line.WriteString(escapeLine(msg.Metric, ", "))
+	for _, tag := range [][2]string{{"vin", msg.VIN}, {"pid", msg.PID}, {"ecu", msg.ECU}, {"unit", msg.Unit}} {
+		if tag[1] == "" {
+			continue
+		}
+		line.WriteString("," + tag[0] + "=" + escapeLine(tag[1], ",= "))
+	}
+
+	line.WriteByte(' ')
+	if msg.Data != "" {
+		line.WriteString(`data="` + escapeLine(msg.Data, `"\`) + `"`)
+	} else {
+		if math.IsNaN(msg.Value) || math.IsInf(msg.Value, 0) {
+			return nil, fmt.Errorf("value of %s is not finite: %v", msg.Metric, msg.Value)
+		}
+		line.WriteString("value=" + strconv.FormatFloat(msg.Value, 'f', -1, 64))
+	}
+	if msg.Implausible {
+		line.WriteString(",implausible=true")
+	}
+	if msg.Derived {
+		line.WriteString(",derived=true")
+	}
+
+	line.WriteString(" " + strconv.FormatInt(msg.Timestamp.UnixNano(), 10))
+	return []byte(line.String()), nil
+}
+
+// escapeLine экранирует обратной косой чертой специальные символы line protocol
+func escapeLine(value, special string) string {
+	if !strings.ContainsAny(value, special) {
+		return value
+	}
+	var escaped strings.Builder
+	for _, r := range value {
+		if strings.ContainsRune(special, r) {
+			escaped.WriteByte('\\')
+		}
+		escaped.WriteRune(r)
+	}
+	return escaped.String()
+}
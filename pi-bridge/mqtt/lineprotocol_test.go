@@ -0,0 +1,79 @@
+package mqtt
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestLineProtocol(t *testing.T) {
+	timestamp := time.Unix(1759883321, 500)
+
+	tests := []struct {
+		name    string
+		msg     TelemetryMessage
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "metric",
+			msg:  TelemetryMessage{VIN: "WF0XXXTTGXAB12345", PID: "0C", Metric: "engine_rpm", Value: 1726, Unit: "rpm", Timestamp: timestamp},
+			want: "engine_rpm,vin=WF0XXXTTGXAB12345,pid=0C,unit=rpm value=1726 1759883321000000500",
+		},
+		{
+			name: "ecu and flags",
+			msg:  TelemetryMessage{PID: "05", Metric: "coolant_temperature", Value: -40.5, Unit: "°C", ECU: "7E8", Implausible: true, Timestamp: timestamp},
+			want: "coolant_temperature,pid=05,ecu=7E8,unit=°C value=-40.5,implausible=true 1759883321000000500",
+		},
+		{
+			name: "derived",
+			msg:  TelemetryMessage{VIN: "TEST123", Metric: "fuel_rate", Value: 0.000125, Unit: "L/h", Derived: true, Timestamp: timestamp},
+			want: "fuel_rate,vin=TEST123,unit=L/h value=0.000125,derived=true 1759883321000000500",
+		},
+		{
+			name: "escaped tags",
+			msg:  TelemetryMessage{VIN: "TEST 1,2=3", Metric: "custom metric,x", Value: 1, Timestamp: timestamp},
+			want: `custom\ metric\,x,vin=TEST\ 1\,2\=3 value=1 1759883321000000500`,
+		},
+		{
+			name: "raw data",
+			msg:  TelemetryMessage{VIN: "TEST123", PID: "A5", Metric: "unknown_A5", Data: "41 A5 01 02", Timestamp: timestamp},
+			want: `unknown_A5,vin=TEST123,pid=A5 data="41 A5 01 02" 1759883321000000500`,
+		},
+		{name: "not finite", msg: TelemetryMessage{Metric: "engine_rpm", Value: math.NaN()}, wantErr: true},
+		{name: "no metric", msg: TelemetryMessage{Value: 1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lineProtocol(&tt.msg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lineProtocol() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if string(got) != tt.want {
+				t.Errorf("lineProtocol() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublishTelemetryInflux(t *testing.T) {
+	config := DefaultConfig()
+	config.PayloadFormat = PayloadInflux
+	recorder := &recordingClient{}
+	client := NewClient(config, nil, nil, nil, nil)
+	client.SetVIN("TEST123")
+	client.mqttClient = recorder
+
+	msg := &TelemetryMessage{VIN: "TEST123", PID: "0D", Metric: "vehicle_speed", Value: 60, Unit: "km/h", Timestamp: time.Unix(0, 42)}
+	if err := client.publishTelemetry(msg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(recorder.published) != 1 {
+		t.Fatalf("Expected one message, got %d", len(recorder.published))
+	}
+	message := recorder.published[0]
+	if message.topic != "car/telemetry/TEST123/vehicle_speed" || string(message.payload.([]byte)) != "vehicle_speed,vin=TEST123,pid=0D,unit=km/h value=60 42" {
+		t.Errorf("Unexpected message %s %s", message.topic, message.payload)
+	}
+}
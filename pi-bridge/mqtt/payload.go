@@ -0,0 +1,58 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// Форматы сообщений телеметрии
+const (
+	PayloadJSON     = "json"     // JSON сообщение TelemetryMessage
+	PayloadInflux   = "influx"   // Строка InfluxDB line protocol для mqtt_consumer Telegraf
+	PayloadProtobuf = "protobuf" // Protobuf по схеме proto/telemetry.proto
+	PayloadCBOR     = "cbor"     // Карта CBOR с номерами полей proto/telemetry.proto
+	PayloadPlain    = "plain"    // Только значение текстом, например "1724"
+)
+
+// attributesSuffix - подтопик с JSON сообщением при формате plain
+const attributesSuffix = "/attributes"
+
+// ValidatePayloadFormat проверяет формат сообщений телеметрии
+func (c Config) ValidatePayloadFormat() error {
+	switch c.PayloadFormat {
+	case "", PayloadJSON, PayloadInflux, PayloadProtobuf, PayloadCBOR, PayloadPlain:
+	default:
+		return fmt.Errorf("unknown mqtt.payload_format %q: expected %s, %s, %s, %s or %s",
+			c.PayloadFormat, PayloadJSON, PayloadInflux, PayloadProtobuf, PayloadCBOR, PayloadPlain)
+	}
+	if c.PlainAttributes && c.PayloadFormat != PayloadPlain {
+		return fmt.Errorf("mqtt.plain_attributes requires payload_format %s", PayloadPlain)
+	}
+	return nil
+}
+
+// encodeTelemetry формирует тело сообщения телеметрии в заданном формате
+func (c *Client) encodeTelemetry(msg *TelemetryMessage) ([]byte, error) {
+	switch c.config.PayloadFormat {
+	case PayloadInflux:
+		return lineProtocol(msg)
+	case PayloadProtobuf:
+		return protobufTelemetry(msg), nil
+	case PayloadCBOR:
+		return cborTelemetry(msg), nil
+	case PayloadPlain:
+		return plainValue(msg), nil
+	default:
+		return json.Marshal(msg)
+	}
+}
+
+// plainValue возвращает значение без JSON конверта для элементов OpenHAB и индикаторов
+// Node-RED. Для PID без декодера публикуются байты данных в hex
+func plainValue(msg *TelemetryMessage) []byte {
+	if msg.Data != "" {
+		return []byte(msg.Data)
+	}
+	return strconv.AppendFloat(nil, msg.Value, 'f', -1, 64)
+}
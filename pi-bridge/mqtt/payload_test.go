@@ -0,0 +1,78 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestValidatePayloadFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"default", Config{}, false},
+		{"json", Config{PayloadFormat: "json"}, false},
+		{"influx", Config{PayloadFormat: "influx"}, false},
+		{"protobuf", Config{PayloadFormat: "protobuf"}, false},
+		{"cbor", Config{PayloadFormat: "cbor"}, false},
+		{"plain with attributes", Config{PayloadFormat: "plain", PlainAttributes: true}, false},
+		{"unknown", Config{PayloadFormat: "csv"}, true},
+		{"attributes without plain", Config{PlainAttributes: true}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.ValidatePayloadFormat(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidatePayloadFormat() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlainValue(t *testing.T) {
+	tests := []struct {
+		msg  TelemetryMessage
+		want string
+	}{
+		{TelemetryMessage{Metric: "engine_rpm", Value: 1724}, "1724"},
+		{TelemetryMessage{Metric: "coolant_temperature", Value: -7.5}, "-7.5"},
+		{TelemetryMessage{Metric: "fuel_rate", Value: 0.000125}, "0.000125"},
+		{TelemetryMessage{Metric: "unknown_FF", Data: "12 34 AB"}, "12 34 AB"},
+	}
+
+	for _, tt := range tests {
+		if got := string(plainValue(&tt.msg)); got != tt.want {
+			t.Errorf("%s: plainValue() = %q, want %q", tt.msg.Metric, got, tt.want)
+		}
+	}
+}
+
+func TestPublishTelemetryPlainAttributes(t *testing.T) {
+	config := DefaultConfig()
+	config.PayloadFormat = PayloadPlain
+	config.PlainAttributes = true
+	recorder := &recordingClient{}
+	client := NewClient(config, nil, nil, nil, nil)
+	client.SetVIN("TEST123")
+	client.mqttClient = recorder
+
+	msg := &TelemetryMessage{VIN: "TEST123", PID: "A6", Metric: "odometer", Value: 12345.6, Unit: "km", Timestamp: time.Unix(1759883336, 0)}
+	if err := client.publishTelemetry(msg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(recorder.published) != 2 {
+		t.Fatalf("Expected attributes and value, got %+v", recorder.published)
+	}
+
+	attributes, value := recorder.published[0], recorder.published[1]
+	var decoded TelemetryMessage
+	if attributes.topic != "car/telemetry/TEST123/odometer/attributes" || !attributes.retained ||
+		json.Unmarshal(attributes.payload.([]byte), &decoded) != nil || decoded.Unit != "km" {
+		t.Errorf("Unexpected attributes message %s %s", attributes.topic, attributes.payload)
+	}
+	if value.topic != "car/telemetry/TEST123/odometer" || !value.retained || string(value.payload.([]byte)) != "12345.6" {
+		t.Errorf("Unexpected value message %s %s", value.topic, value.payload)
+	}
+}
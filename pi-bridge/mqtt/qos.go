@@ -0,0 +1,46 @@
+package mqtt
+
+import "fmt"
+
+// QoSConfig задает QoS по классам сообщений: частая телеметрия публикуется без
+// подтверждения, а ответы на команды и неисправности доставляются гарантированно.
+// Остальные сообщения (служебные события, завещание, подписки) используют общий qos
+type QoSConfig struct {
+	Telemetry byte `yaml:"telemetry"` // Телеметрия и документ state
+	Responses byte `yaml:"responses"` // Ответы на команды
+	Alerts    byte `yaml:"alerts"`    // Коды неисправностей и стоп-кадры
+}
+
+// alertKinds - служебные события о неисправностях, публикуемые с QoS alerts
+var alertKinds = map[string]bool{
+	"dtc":            true,
+	"permanent_dtc":  true,
+	"fault_snapshot": true,
+}
+
+// ValidateQoS проверяет общий QoS и QoS классов сообщений
+func (c Config) ValidateQoS() error {
+	levels := []struct {
+		name  string
+		value byte
+	}{
+		{"qos", c.QoS},
+		{"class_qos.telemetry", c.ClassQoS.Telemetry},
+		{"class_qos.responses", c.ClassQoS.Responses},
+		{"class_qos.alerts", c.ClassQoS.Alerts},
+	}
+	for _, level := range levels {
+		if level.value > 2 {
+			return fmt.Errorf("mqtt %s must be 0, 1 or 2, got %d", level.name, level.value)
+		}
+	}
+	return nil
+}
+
+// statusQoS возвращает QoS служебного события
+func (c *Client) statusQoS(kind string) byte {
+	if alertKinds[kind] {
+		return c.config.ClassQoS.Alerts
+	}
+	return c.config.QoS
+}
@@ -0,0 +1,66 @@
+package mqtt
+
+import (
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+func TestValidateQoS(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"default", DefaultConfig(), false},
+		{"all at most once", Config{}, false},
+		{"global", Config{QoS: 3}, true},
+		{"telemetry", Config{ClassQoS: QoSConfig{Telemetry: 3}}, true},
+		{"alerts", Config{ClassQoS: QoSConfig{Alerts: 4}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.ValidateQoS(); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateQoS() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPublishQoSPerClass(t *testing.T) {
+	recorder := &recordingClient{}
+	client := NewClient(DefaultConfig(), nil, nil, nil, nil)
+	client.SetVIN("TEST123")
+	client.mqttClient = recorder
+
+	now := time.Unix(1759883336, 0)
+	if err := client.publishTelemetry(&TelemetryMessage{VIN: "TEST123", PID: "0C", Metric: "engine_rpm", Value: 1724, Unit: "rpm", Timestamp: now}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := client.publishCommandResponse(CommandResponse{Status: "success"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, kind := range []string{"dtc", "fault_snapshot", "link_quality"} {
+		if err := client.publishStatus(common.StatusEvent{Kind: kind, Timestamp: now}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	want := map[string]byte{
+		"car/telemetry/TEST123/engine_rpm":  0,
+		"car/command/TEST123/response":      1,
+		"car/bridge/TEST123/dtc":            2,
+		"car/bridge/TEST123/fault_snapshot": 2,
+		"car/bridge/TEST123/link_quality":   1,
+	}
+	if len(recorder.published) != len(want) {
+		t.Fatalf("Expected %d messages, got %+v", len(want), recorder.published)
+	}
+	for _, msg := range recorder.published {
+		if qos, ok := want[msg.topic]; !ok || msg.qos != qos {
+			t.Errorf("%s: QoS = %d, want %d", msg.topic, msg.qos, qos)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package mqtt
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Топики ответов на команды
+const (
+	ResponseShared      = "shared"      // Общий топик car/command/{VIN}/response
+	ResponseCorrelation = "correlation" // Топик запроса car/command/{VIN}/response/{correlation_id}
+	ResponseBoth        = "both"        // Оба топика
+)
+
+// ValidateResponseTopics проверяет режим топиков ответов
+func (c Config) ValidateResponseTopics() error {
+	switch c.ResponseTopics {
+	case "", ResponseShared, ResponseCorrelation, ResponseBoth:
+		return nil
+	}
+	return fmt.Errorf("unknown mqtt.response_topics %q: expected %s, %s or %s",
+		c.ResponseTopics, ResponseShared, ResponseCorrelation, ResponseBoth)
+}
+
+// responseTopics возвращает топики ответа на команду. Ответ без correlation ID или с ID,
+// недопустимым в уровне топика (пустой, с "/", "+" или "#"), публикуется в общий топик,
+// чтобы не потеряться
+func (c *Client) responseTopics(correlationID string) []string {
+	shared := fmt.Sprintf("%s/%s/response", c.config.CommandTopic, c.vin)
+	if c.config.ResponseTopics == "" || c.config.ResponseTopics == ResponseShared ||
+		correlationID == "" || strings.ContainsAny(correlationID, "/+#") {
+		return []string{shared}
+	}
+
+	own := shared + "/" + correlationID
+	if c.config.ResponseTopics == ResponseCorrelation {
+		return []string{own}
+	}
+	return []string{shared, own}
+}
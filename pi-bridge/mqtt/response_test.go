@@ -0,0 +1,55 @@
+package mqtt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateResponseTopics(t *testing.T) {
+	for _, mode := range []string{"", ResponseShared, ResponseCorrelation, ResponseBoth} {
+		if err := (Config{ResponseTopics: mode}).ValidateResponseTopics(); err != nil {
+			t.Errorf("%q: unexpected error: %v", mode, err)
+		}
+	}
+	if err := (Config{ResponseTopics: "private"}).ValidateResponseTopics(); err == nil {
+		t.Error("Expected error for unknown mode")
+	}
+}
+
+func TestPublishCommandResponseTopics(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          string
+		correlationID string
+		want          []string
+	}{
+		{"default", "", "cmd-123", []string{"car/command/TEST123/response"}},
+		{"shared", ResponseShared, "cmd-123", []string{"car/command/TEST123/response"}},
+		{"correlation", ResponseCorrelation, "cmd-123", []string{"car/command/TEST123/response/cmd-123"}},
+		{"both", ResponseBoth, "cmd-123", []string{"car/command/TEST123/response", "car/command/TEST123/response/cmd-123"}},
+		{"without correlation id", ResponseCorrelation, "", []string{"car/command/TEST123/response"}},
+		{"wildcard in correlation id", ResponseCorrelation, "cmd/+", []string{"car/command/TEST123/response"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.ResponseTopics = tt.mode
+			recorder := &recordingClient{}
+			client := NewClient(config, nil, nil, nil, nil)
+			client.SetVIN("TEST123")
+			client.mqttClient = recorder
+
+			if err := client.publishCommandResponse(CommandResponse{CorrelationID: tt.correlationID, Status: "success"}); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var topics []string
+			for _, msg := range recorder.published {
+				topics = append(topics, msg.topic)
+			}
+			if !reflect.DeepEqual(topics, tt.want) {
+				t.Errorf("Published to %v, want %v", topics, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package obd
+
+import (
+	"log"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+// Запросы идентификации адаптера и номера текущего протокола
+const (
+	IdentifyCommand         = "ATI"
+	DescribeProtocolCommand = "ATDPN"
+)
+
+// identificationPattern распознает ответ на ATI, например "ELM327 v1.5"
+var identificationPattern = regexp.MustCompile(`(?i)^(\S+)\s+v?(\d+(?:\.\d+)*\w*)$`)
+
+// protocolNames содержит названия протоколов ELM327 по номеру (ответ на ATDPN)
+var protocolNames = map[string]string{
+	"0": "Automatic",
+	"1": "SAE J1850 PWM (41.6 kbaud)",
+	"2": "SAE J1850 VPW (10.4 kbaud)",
+	"3": "ISO 9141-2 (5 baud init)",
+	"4": "ISO 14230-4 KWP (5 baud init)",
+	"5": "ISO 14230-4 KWP (fast init)",
+	"6": "ISO 15765-4 CAN (11 bit ID, 500 kbaud)",
+	"7": "ISO 15765-4 CAN (29 bit ID, 500 kbaud)",
+	"8": "ISO 15765-4 CAN (11 bit ID, 250 kbaud)",
+	"9": "ISO 15765-4 CAN (29 bit ID, 250 kbaud)",
+	"A": "SAE J1939 CAN (29 bit ID, 250 kbaud)",
+	"B": "USER1 CAN (11 bit ID, 125 kbaud)",
+	"C": "USER2 CAN (11 bit ID, 50 kbaud)",
+}
+
+// AdapterInfo описывает подключенный адаптер и выбранный протокол шины
+type AdapterInfo struct {
+	Identification string    `json:"identification,omitempty"`  // Ответ на ATI, например "ELM327 v1.5"
+	Device         string    `json:"device,omitempty"`          // Тип адаптера: "ELM327"
+	Firmware       string    `json:"firmware,omitempty"`        // Версия прошивки: "1.5"
+	ProtocolNumber string    `json:"protocol_number,omitempty"` // Номер протокола ELM327: "6"
+	Protocol       string    `json:"protocol,omitempty"`        // Название протокола
+	AutoProtocol   bool      `json:"auto_protocol"`             // Протокол определен автоматически (ATSP0)
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// AdapterInfoCollector запрашивает идентификацию адаптера и номер протокола после
+// подключения и публикует их в топике bridge_info. Пока адаптер не обратился к шине,
+// протокол при автоматическом выборе неизвестен (ATDPN отвечает "A0"), поэтому номер
+// протокола запрашивается повторно после первого ответа ЭБУ с данными
+type AdapterInfoCollector struct {
+	mu           sync.Mutex
+	info         AdapterInfo
+	needProtocol bool // Протокол еще не определен, ждем ответа ЭБУ
+	commandsChan chan<- string
+	statusChan   chan<- common.StatusEvent
+	logger       *log.Logger
+}
+
+// NewAdapterInfoCollector создает сборщик сведений об адаптере
+func NewAdapterInfoCollector(commandsChan chan<- string, statusChan chan<- common.StatusEvent) *AdapterInfoCollector {
+	return &AdapterInfoCollector{
+		commandsChan: commandsChan,
+		statusChan:   statusChan,
+		logger:       log.New(os.Stdout, "[OBD-AdapterInfo] ", log.LstdFlags|log.Lshortfile),
+	}
+}
+
+// OnConnect запрашивает идентификацию и протокол после (пере)подключения к адаптеру:
+// после переподключения может оказаться другой адаптер или автомобиль
+func (c *AdapterInfoCollector) OnConnect() {
+	c.mu.Lock()
+	c.info = AdapterInfo{}
+	c.needProtocol = false
+	c.mu.Unlock()
+
+	c.send(IdentifyCommand, DescribeProtocolCommand)
+}
+
+// Observe повторяет запрос протокола после первого ответа ЭБУ, если при подключении
+// адаптер его еще не определил (ResponseObserver)
+func (c *AdapterInfoCollector) Observe(response string, telemetry *Telemetry) {
+	if telemetry == nil {
+		return
+	}
+
+	c.mu.Lock()
+	need := c.needProtocol
+	c.needProtocol = false
+	c.mu.Unlock()
+
+	if need {
+		c.send(DescribeProtocolCommand)
+	}
+}
+
+// ObserveAT разбирает ответы на ATI и ATDPN (обработчик ответов на AT команды)
+func (c *AdapterInfoCollector) ObserveAT(command, response string) {
+	response = strings.TrimSpace(response)
+	command = strings.ToUpper(strings.ReplaceAll(command, " ", ""))
+
+	c.mu.Lock()
+	switch command {
+	case IdentifyCommand:
+		c.info.Identification = response
+		if match := identificationPattern.FindStringSubmatch(response); match != nil {
+			c.info.Device, c.info.Firmware = match[1], match[2]
+		}
+	case DescribeProtocolCommand:
+		number, auto, ok := ParseProtocolNumber(response)
+		if !ok {
+			c.mu.Unlock()
+			return
+		}
+		c.info.AutoProtocol = auto
+		c.info.ProtocolNumber = number
+		c.info.Protocol = protocolNames[number]
+		// "A0" - автоматический выбор еще не выполнялся
+		c.needProtocol = number == "0"
+	default:
+		c.mu.Unlock()
+		return
+	}
+	c.info.UpdatedAt = time.Now()
+	info := c.info
+	c.mu.Unlock()
+
+	c.logger.Printf("Adapter: %q, protocol: %s (%s)", info.Identification, info.ProtocolNumber, info.Protocol)
+	sendStatus("bridge_info", info, c.statusChan, c.logger)
+}
+
+// Info возвращает последние сведения об адаптере
+func (c *AdapterInfoCollector) Info() AdapterInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.info
+}
+
+// ParseProtocolNumber разбирает ответ на ATDPN: "A6" - протокол 6 выбран автоматически
+func ParseProtocolNumber(response string) (string, bool, bool) {
+	response = strings.ToUpper(strings.TrimSpace(response))
+	auto := false
+	if len(response) == 2 && response[0] == 'A' {
+		auto = true
+		response = response[1:]
+	}
+	if _, known := protocolNames[response]; !known {
+		return "", false, false
+	}
+	return response, auto, true
+}
+
+// send отправляет запросы, не блокируя вызывающую горутину (парсер или переподключение)
+func (c *AdapterInfoCollector) send(commands ...string) {
+	go func() {
+		for _, command := range commands {
+			c.commandsChan <- command
+		}
+	}()
+}
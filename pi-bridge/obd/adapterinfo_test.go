@@ -0,0 +1,93 @@
+package obd
+
+import (
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+func TestParseProtocolNumber(t *testing.T) {
+	tests := []struct {
+		response string
+		number   string
+		auto     bool
+		ok       bool
+	}{
+		{"A6", "6", true, true},
+		{"6", "6", false, true},
+		{"A0", "0", true, true},
+		{"A", "A", false, true},
+		{"AA", "A", true, true},
+		{" a7\r", "7", true, true},
+		{"?", "", false, false},
+		{"AF", "", false, false},
+	}
+	for _, tt := range tests {
+		number, auto, ok := ParseProtocolNumber(tt.response)
+		if number != tt.number || auto != tt.auto || ok != tt.ok {
+			t.Errorf("%q: expected %s %v %v, got %s %v %v", tt.response, tt.number, tt.auto, tt.ok, number, auto, ok)
+		}
+	}
+}
+
+func TestAdapterInfoCollector(t *testing.T) {
+	commandsChan := make(chan string, 10)
+	statusChan := make(chan common.StatusEvent, 10)
+	collector := NewAdapterInfoCollector(commandsChan, statusChan)
+
+	collector.OnConnect()
+	for _, expected := range []string{"ATI", "ATDPN"} {
+		select {
+		case command := <-commandsChan:
+			if command != expected {
+				t.Errorf("Expected %s, got %s", expected, command)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected request %s", expected)
+		}
+	}
+
+	collector.ObserveAT("ATI", "ELM327 v1.5")
+	collector.ObserveAT("ATDPN", "A0")
+
+	// Протокол еще не выбран: запрос повторяется после первого ответа ЭБУ
+	collector.Observe("NO DATA", nil)
+	collector.Observe("41 0C 1A F0", &Telemetry{Metric: "engine_rpm"})
+	select {
+	case command := <-commandsChan:
+		if command != "ATDPN" {
+			t.Errorf("Expected ATDPN, got %s", command)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected protocol request after first ECU response")
+	}
+	collector.ObserveAT("ATDPN", "A6")
+	collector.Observe("41 0C 1A F0", &Telemetry{Metric: "engine_rpm"})
+
+	var last common.StatusEvent
+	for len(statusChan) > 0 {
+		last = <-statusChan
+	}
+	info, ok := last.Data.(AdapterInfo)
+	if !ok || last.Kind != "bridge_info" || !last.Retained {
+		t.Fatalf("Unexpected event: %+v", last)
+	}
+	expected := AdapterInfo{
+		Identification: "ELM327 v1.5",
+		Device:         "ELM327",
+		Firmware:       "1.5",
+		ProtocolNumber: "6",
+		Protocol:       "ISO 15765-4 CAN (11 bit ID, 500 kbaud)",
+		AutoProtocol:   true,
+	}
+	info.UpdatedAt = time.Time{}
+	if info != expected {
+		t.Errorf("Expected %+v, got %+v", expected, info)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	if len(commandsChan) != 0 {
+		t.Errorf("Expected no repeated protocol request, got %d commands", len(commandsChan))
+	}
+}
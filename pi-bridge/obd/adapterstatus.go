@@ -0,0 +1,68 @@
+package obd
+
+import "strings"
+
+// Текстовые статусы ELM327, которые адаптер возвращает вместо данных
+const (
+	AdapterStatusNoData          = "NO DATA"           // ЭБУ не ответил (PID не поддерживается или зажигание выключено)
+	AdapterStatusStopped         = "STOPPED"           // Запрос прерван новым символом до завершения ответа
+	AdapterStatusUnableToConnect = "UNABLE TO CONNECT" // Не удалось определить протокол шины
+	AdapterStatusBusInitError    = "BUS INIT ERROR"    // Не удалась медленная инициализация ISO 9141/KWP
+)
+
+// Реакции менеджера команд на статус адаптера
+const (
+	StatusActionNone    = "none"    // Разовый статус, опрос продолжается
+	StatusActionBackoff = "backoff" // Статусы идут подряд, интервал опроса увеличен
+	StatusActionReinit  = "reinit"  // Соединение с шиной потеряно, протокол будет определен заново
+)
+
+// reinitCommands закрывают текущий протокол и возвращают автоматический поиск
+var reinitCommands = []string{"ATPC", "ATSP0"}
+
+// adapterStatuses сопоставляет статусы ELM327 с источником проблемы
+var adapterStatuses = []struct {
+	status string
+	source string
+}{
+	{AdapterStatusUnableToConnect, ErrorSourceBus},
+	{AdapterStatusNoData, ErrorSourceBus},
+	{AdapterStatusStopped, ErrorSourceAdapter},
+}
+
+// AdapterStatusReport представляет статус адаптера для публикации
+type AdapterStatusReport struct {
+	Status string `json:"status"` // NO DATA, STOPPED, UNABLE TO CONNECT, BUS INIT ERROR
+	Source string `json:"source"` // bus или adapter
+	Action string `json:"action"` // none, backoff или reinit
+	Count  int    `json:"count"`  // Сколько раз статус получен с момента запуска
+}
+
+// DetectAdapterStatus проверяет, является ли ответ текстовым статусом ELM327.
+// Строка "SEARCHING..." перед статусом допускается
+func DetectAdapterStatus(response string) (status string, source string, ok bool) {
+	var lines []string
+	for _, line := range strings.FieldsFunc(strings.ToUpper(response), func(r rune) bool { return r == '\r' || r == '\n' }) {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "SEARCHING") {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) == 0 {
+		return "", "", false
+	}
+
+	// Статус приходит последней строкой: данные перед STOPPED неполные
+	last := lines[len(lines)-1]
+	for _, s := range adapterStatuses {
+		if strings.HasPrefix(last, s.status) {
+			return s.status, s.source, true
+		}
+	}
+
+	// Вывод инициализации содержит точки прогресса: "BUS INIT: ...ERROR"
+	if strings.HasPrefix(last, "BUS INIT") && strings.Contains(last, "ERROR") {
+		return AdapterStatusBusInitError, ErrorSourceBus, true
+	}
+	return "", "", false
+}
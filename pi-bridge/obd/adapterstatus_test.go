@@ -0,0 +1,65 @@
+package obd
+
+import "testing"
+
+func TestDetectAdapterStatus(t *testing.T) {
+	tests := []struct {
+		response       string
+		expectedStatus string
+		expectedSource string
+		expectStatus   bool
+	}{
+		{"NO DATA", AdapterStatusNoData, ErrorSourceBus, true},
+		{"SEARCHING...\rUNABLE TO CONNECT", AdapterStatusUnableToConnect, ErrorSourceBus, true},
+		{"7E8 10 14 49 02 01 31 44 34\rSTOPPED", AdapterStatusStopped, ErrorSourceAdapter, true},
+		{"stopped", AdapterStatusStopped, ErrorSourceAdapter, true},
+		{"BUS INIT: ...ERROR", AdapterStatusBusInitError, ErrorSourceBus, true},
+		{"SEARCHING...\rBUS INIT: ...ERROR", AdapterStatusBusInitError, ErrorSourceBus, true},
+		{"41 0C 1A F0", "", "", false},
+		{"SEARCHING...", "", "", false},
+		{"", "", "", false},
+	}
+
+	for _, tt := range tests {
+		status, source, ok := DetectAdapterStatus(tt.response)
+		if ok != tt.expectStatus {
+			t.Errorf("Expected detection %v for %q, got %v", tt.expectStatus, tt.response, ok)
+			continue
+		}
+		if status != tt.expectedStatus || source != tt.expectedSource {
+			t.Errorf("Expected %s/%s for %q, got %s/%s", tt.expectedStatus, tt.expectedSource, tt.response, status, source)
+		}
+	}
+}
+
+func TestBusHealthRecordStatus(t *testing.T) {
+	health := NewBusHealth()
+
+	report := health.RecordStatus(AdapterStatusNoData, ErrorSourceBus)
+	if report.Action != StatusActionNone || report.Count != 1 {
+		t.Errorf("Expected single NO DATA without action, got %+v", report)
+	}
+	if health.TakeReinit() {
+		t.Error("Expected no re-init after NO DATA")
+	}
+
+	// Статусы подряд приводят к отступу
+	for i := 0; i < busErrorThreshold; i++ {
+		report = health.RecordStatus(AdapterStatusStopped, ErrorSourceAdapter)
+	}
+	if report.Action != StatusActionBackoff || report.Count != busErrorThreshold {
+		t.Errorf("Expected backoff after repeated STOPPED, got %+v", report)
+	}
+
+	// Потеря связи с шиной запрашивает повторную инициализацию один раз
+	report = health.RecordStatus(AdapterStatusUnableToConnect, ErrorSourceBus)
+	if report.Action != StatusActionReinit {
+		t.Errorf("Expected re-init action, got %+v", report)
+	}
+	if !health.TakeReinit() {
+		t.Error("Expected pending re-init")
+	}
+	if health.TakeReinit() {
+		t.Error("Expected re-init to be taken only once")
+	}
+}
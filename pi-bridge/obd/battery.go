@@ -0,0 +1,80 @@
+package obd
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BatteryVoltageCommand - запрос напряжения на выводе 16 разъема OBD (батарея автомобиля)
+const BatteryVoltageCommand = "ATRV"
+
+// batteryVoltageMetric - метрика напряжения батареи в телеметрии
+const batteryVoltageMetric = "battery_voltage"
+
+// voltagePattern распознает ответ ELM327 на ATRV, например "12.6V"
+var voltagePattern = regexp.MustCompile(`^(\d{1,2}(?:\.\d+)?)\s*V$`)
+
+// batteryVoltageInterval - период запроса ATRV менеджером команд (0 - выключено)
+var batteryVoltageInterval = 30 * time.Second
+
+// SetBatteryVoltageInterval задает период опроса напряжения батареи.
+// Вызывается при запуске до старта менеджера команд
+func SetBatteryVoltageInterval(interval time.Duration) {
+	if interval < 0 {
+		interval = 0
+	}
+	batteryVoltageInterval = interval
+}
+
+// ParseBatteryVoltage извлекает напряжение из ответа на ATRV
+func ParseBatteryVoltage(command, response string) (float64, bool) {
+	if !strings.EqualFold(strings.TrimSpace(command), BatteryVoltageCommand) {
+		return 0, false
+	}
+
+	match := voltagePattern.FindStringSubmatch(strings.TrimSpace(response))
+	if match == nil {
+		return 0, false
+	}
+	voltage, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	return voltage, true
+}
+
+// BatteryMonitor публикует напряжение батареи из ответов на ATRV как телеметрию.
+// Напряжение измеряет сам адаптер, поэтому оно доступно и при выключенном зажигании,
+// когда ЭБУ не отвечают, - это основной сигнал для контроля стоящего автомобиля
+type BatteryMonitor struct {
+	telemetryChan chan<- Telemetry
+}
+
+// NewBatteryMonitor создает монитор, отправляющий показания в telemetryChan
+func NewBatteryMonitor(telemetryChan chan<- Telemetry) *BatteryMonitor {
+	return &BatteryMonitor{telemetryChan: telemetryChan}
+}
+
+// ObserveAT публикует напряжение из ответа на ATRV (обработчик ответов на AT команды)
+func (b *BatteryMonitor) ObserveAT(command, response string) {
+	voltage, ok := ParseBatteryVoltage(command, response)
+	if !ok {
+		return
+	}
+
+	telemetry := Telemetry{
+		Metric:    batteryVoltageMetric,
+		Value:     voltage,
+		Unit:      "V",
+		Timestamp: getCurrentTimestamp(),
+		Raw:       strings.TrimSpace(response),
+	}
+	ConvertTelemetry(&telemetry)
+	select {
+	case b.telemetryChan <- telemetry:
+	default:
+		logger.Printf("Warning: telemetry channel is full, dropping: %s", telemetry.Metric)
+	}
+}
@@ -0,0 +1,40 @@
+package obd
+
+import "testing"
+
+func TestParseBatteryVoltage(t *testing.T) {
+	tests := []struct {
+		command  string
+		response string
+		voltage  float64
+		ok       bool
+	}{
+		{"ATRV", "12.6V", 12.6, true},
+		{"atrv", " 14.1 V\r", 14.1, true},
+		{"ATRV", "9V", 9, true},
+		{"ATRV", "?", 0, false},
+		{"ATI", "12.6V", 0, false},
+	}
+	for _, tt := range tests {
+		voltage, ok := ParseBatteryVoltage(tt.command, tt.response)
+		if ok != tt.ok || voltage != tt.voltage {
+			t.Errorf("%s %q: expected %v (%v), got %v (%v)", tt.command, tt.response, tt.voltage, tt.ok, voltage, ok)
+		}
+	}
+}
+
+func TestBatteryMonitor(t *testing.T) {
+	telemetryChan := make(chan Telemetry, 2)
+	monitor := NewBatteryMonitor(telemetryChan)
+
+	monitor.ObserveAT("ATRV", "12.4V")
+	monitor.ObserveAT("ATDPN", "A6")
+
+	if len(telemetryChan) != 1 {
+		t.Fatalf("Expected 1 telemetry, got %d", len(telemetryChan))
+	}
+	telemetry := <-telemetryChan
+	if telemetry.Metric != "battery_voltage" || telemetry.Value != 12.4 || telemetry.Unit != "V" || telemetry.Timestamp == 0 {
+		t.Errorf("Unexpected telemetry: %+v", telemetry)
+	}
+}
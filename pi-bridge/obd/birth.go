@@ -0,0 +1,173 @@
+package obd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"elm327-bridge/common"
+	"elm327-bridge/obd/decoder"
+)
+
+// SupportedPIDsCommand запрашивает битовую карту PID 01-20 сервиса 01
+const SupportedPIDsCommand = "0100"
+
+// Birth - сведения о мосте для потребителей: версия, адаптер, протокол шины и набор PID.
+// По ним клиенты подстраиваются под мост без отдельного согласования
+type Birth struct {
+	Version        string    `json:"version"`                   // Версия моста
+	Transport      string    `json:"transport"`                 // Подключение к адаптеру: serial, rfcomm, tcp, ble, replay
+	Adapter        string    `json:"adapter,omitempty"`         // Ответ адаптера на ATI, например "ELM327 v1.5"
+	ProtocolNumber string    `json:"protocol_number,omitempty"` // Номер протокола ELM327
+	Protocol       string    `json:"protocol,omitempty"`        // Название протокола шины
+	SupportedPIDs  []string  `json:"supported_pids"`            // PID сервиса 01, поддерживаемые автомобилем
+	ConfigHash     string    `json:"config_hash"`               // Хеш файла конфигурации
+	StartedAt      time.Time `json:"started_at"`                // Время запуска моста
+}
+
+// BirthReporter собирает сообщение о запуске (birth). Оно публикуется при каждом подключении
+// к брокеру и повторно, когда адаптер назвал себя, определил протокол или автомобиль сообщил
+// поддерживаемые PID (запрос 0100 и следующих диапазонов после подключения к адаптеру)
+type BirthReporter struct {
+	mu           sync.Mutex
+	birth        Birth
+	pids         map[string]bool
+	adapterInfo  *AdapterInfoCollector
+	commandsChan chan<- string
+	statusChan   chan<- common.StatusEvent
+	logger       *log.Logger
+}
+
+// NewBirthReporter создает сборщик сообщения о запуске
+func NewBirthReporter(transport, configHash string, adapterInfo *AdapterInfoCollector, commandsChan chan<- string, statusChan chan<- common.StatusEvent) *BirthReporter {
+	return &BirthReporter{
+		birth: Birth{
+			Version:    common.Version,
+			Transport:  transport,
+			ConfigHash: configHash,
+			StartedAt:  time.Now(),
+		},
+		pids:         make(map[string]bool),
+		adapterInfo:  adapterInfo,
+		commandsChan: commandsChan,
+		statusChan:   statusChan,
+		logger:       log.New(os.Stdout, "[OBD-Birth] ", log.LstdFlags|log.Lshortfile),
+	}
+}
+
+// OnConnect запрашивает поддерживаемые PID после (пере)подключения к адаптеру:
+// после переподключения может оказаться другой автомобиль
+func (r *BirthReporter) OnConnect() {
+	r.mu.Lock()
+	r.pids = make(map[string]bool)
+	r.mu.Unlock()
+
+	go func() {
+		r.commandsChan <- SupportedPIDsCommand
+	}()
+}
+
+// Observe учитывает ответ с битовой картой поддерживаемых PID и запрашивает следующий
+// диапазон, если автомобиль его поддерживает (ResponseObserver)
+func (r *BirthReporter) Observe(response string, telemetry *Telemetry) {
+	pids, ok := SupportedPIDs(response)
+	if !ok {
+		return
+	}
+
+	r.mu.Lock()
+	for _, pid := range pids {
+		r.pids[pid] = true
+	}
+	r.mu.Unlock()
+
+	// Последний бит диапазона - поддержка следующего запроса (0120, 0140, ...)
+	for _, pid := range pids {
+		if next, err := strconv.ParseUint(pid, 16, 8); err == nil && next%0x20 == 0 && next < 0xE0 {
+			go func() {
+				r.commandsChan <- fmt.Sprintf("01%02X", next)
+			}()
+		}
+	}
+	r.Publish()
+}
+
+// ObserveAT публикует сообщение, когда адаптер назвал себя или определил протокол
+// (вызывать после AdapterInfoCollector.ObserveAT)
+func (r *BirthReporter) ObserveAT(command, response string) {
+	switch strings.ToUpper(strings.ReplaceAll(command, " ", "")) {
+	case IdentifyCommand, DescribeProtocolCommand:
+		r.Publish()
+	}
+}
+
+// Birth возвращает текущие сведения о мосте
+func (r *BirthReporter) Birth() Birth {
+	r.mu.Lock()
+	birth := r.birth
+	birth.SupportedPIDs = make([]string, 0, len(r.pids))
+	for pid := range r.pids {
+		birth.SupportedPIDs = append(birth.SupportedPIDs, pid)
+	}
+	r.mu.Unlock()
+	sort.Strings(birth.SupportedPIDs)
+
+	if r.adapterInfo != nil {
+		info := r.adapterInfo.Info()
+		birth.Adapter = info.Identification
+		birth.ProtocolNumber = info.ProtocolNumber
+		birth.Protocol = info.Protocol
+	}
+	return birth
+}
+
+// Event возвращает сообщение о запуске как служебное событие birth (retained)
+func (r *BirthReporter) Event() common.StatusEvent {
+	return common.StatusEvent{
+		Kind:      "birth",
+		Data:      r.Birth(),
+		Retained:  true,
+		Timestamp: time.Now(),
+	}
+}
+
+// Publish отправляет обновленное сообщение о запуске в канал служебных событий
+func (r *BirthReporter) Publish() {
+	sendStatus("birth", r.Birth(), r.statusChan, r.logger)
+}
+
+// SupportedPIDs распознает ответ на запрос поддерживаемых PID сервиса 01 ("41 00 BE 3F A8 13")
+// и возвращает PID из битовых карт всех ЭБУ, включая PID следующего диапазона (например, "20")
+func SupportedPIDs(response string) ([]string, bool) {
+	messages, err := decoder.ReassembleResponse(response)
+	if err != nil || len(messages) == 0 {
+		return nil, false
+	}
+
+	seen := make(map[int]bool)
+	var pids []string
+	for _, message := range messages {
+		payload := message.Payload
+		if len(payload) != 6 || payload[0] != 0x41 || payload[1]%0x20 != 0 || payload[1] > 0xE0 {
+			return nil, false
+		}
+
+		base := int(payload[1])
+		for i, b := range payload[2:] {
+			for bit := 0; bit < 8; bit++ {
+				pid := base + i*8 + bit + 1
+				if b&(0x80>>bit) == 0 || pid > 0xFF || seen[pid] {
+					continue
+				}
+				seen[pid] = true
+				pids = append(pids, fmt.Sprintf("%02X", pid))
+			}
+		}
+	}
+	return pids, true
+}
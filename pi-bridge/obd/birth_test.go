@@ -0,0 +1,94 @@
+package obd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+func TestSupportedPIDs(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		pids     []string
+		ok       bool
+	}{
+		{"range 01-20", "41 00 BE 1F A8 13", []string{"01", "03", "04", "05", "06", "07", "0C", "0D", "0E", "0F", "10", "11", "13", "15", "1C", "1F", "20"}, true},
+		{"range 21-40", "41 20 80 00 00 01", []string{"21", "40"}, true},
+		{"two ECUs", "7E8 06 41 00 80 00 00 01\r7E9 06 41 00 C0 00 00 00", []string{"01", "20", "02"}, true},
+		{"last range", "41 E0 00 00 00 01", nil, true},
+		{"other PID", "41 0C 1A F0", nil, false},
+		{"not a range", "41 05 00 00 00 01", nil, false},
+		{"no data", "NO DATA", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pids, ok := SupportedPIDs(tt.response)
+			if ok != tt.ok || len(pids) != len(tt.pids) || (len(pids) > 0 && !reflect.DeepEqual(pids, tt.pids)) {
+				t.Errorf("SupportedPIDs(%q) = %v, %v, want %v, %v", tt.response, pids, ok, tt.pids, tt.ok)
+			}
+		})
+	}
+}
+
+func TestBirthReporter(t *testing.T) {
+	commandsChan := make(chan string, 10)
+	statusChan := make(chan common.StatusEvent, 10)
+	adapterInfo := NewAdapterInfoCollector(make(chan string, 10), make(chan common.StatusEvent, 10))
+	reporter := NewBirthReporter("rfcomm", "0123456789abcdef", adapterInfo, commandsChan, statusChan)
+
+	expectCommand := func(expected string) {
+		t.Helper()
+		select {
+		case command := <-commandsChan:
+			if command != expected {
+				t.Errorf("Expected %s, got %s", expected, command)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected request %s", expected)
+		}
+	}
+
+	reporter.OnConnect()
+	expectCommand("0100")
+
+	// Поддержан следующий диапазон: мост запрашивает 0120
+	reporter.Observe("41 00 98 18 00 01", nil)
+	expectCommand("0120")
+	reporter.Observe("41 20 80 00 00 00", nil)
+	reporter.Observe("41 0C 1A F0", &Telemetry{Metric: "engine_rpm"})
+
+	adapterInfo.ObserveAT("ATI", "ELM327 v1.5")
+	adapterInfo.ObserveAT("ATDPN", "A6")
+	reporter.ObserveAT("ATDPN", "A6")
+
+	if len(statusChan) != 3 {
+		t.Fatalf("Expected 3 birth events, got %d", len(statusChan))
+	}
+
+	event := reporter.Event()
+	birth, ok := event.Data.(Birth)
+	if !ok || event.Kind != "birth" || !event.Retained {
+		t.Fatalf("Unexpected event: %+v", event)
+	}
+	if birth.Version != common.Version || birth.Transport != "rfcomm" || birth.ConfigHash != "0123456789abcdef" {
+		t.Errorf("Unexpected bridge metadata: %+v", birth)
+	}
+	if birth.Adapter != "ELM327 v1.5" || birth.ProtocolNumber != "6" || birth.Protocol != "ISO 15765-4 CAN (11 bit ID, 500 kbaud)" {
+		t.Errorf("Unexpected adapter metadata: %+v", birth)
+	}
+	want := []string{"01", "04", "05", "0C", "0D", "20", "21"}
+	if !reflect.DeepEqual(birth.SupportedPIDs, want) {
+		t.Errorf("Expected supported PIDs %v, got %v", want, birth.SupportedPIDs)
+	}
+
+	// После переподключения набор PID собирается заново
+	reporter.OnConnect()
+	expectCommand("0100")
+	if pids := reporter.Birth().SupportedPIDs; len(pids) != 0 {
+		t.Errorf("Expected PIDs to be reset on connect, got %v", pids)
+	}
+}
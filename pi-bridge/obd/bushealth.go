@@ -0,0 +1,254 @@
+package obd
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+// Источники ошибок: шина автомобиля или сам адаптер
+const (
+	ErrorSourceBus     = "bus"
+	ErrorSourceAdapter = "adapter"
+)
+
+// Параметры консервативного отступа при ошибках шины
+const (
+	busErrorThreshold = 3                // Количество ошибок подряд до начала отступа
+	busRecoveryCycles = 3                // Циклов опроса подряд без ошибок для снятия одного уровня отступа
+	maxBackoffLevel   = 3                // Максимальный множитель интервала опроса 2^3 = 8
+	busStateOK        = "ok"             // Ошибок нет
+	busStateDegraded  = "degraded"       // Есть ошибки, но отступ не применяется
+	busStateBackoff   = "backoff"        // Опрос замедлен из-за ошибок
+	busErrorDecay     = 30 * time.Second // Время без ошибок, после которого состояние считается восстановленным
+)
+
+// TimeoutIndicator - индикатор команды, на которую адаптер не ответил приглашением
+const TimeoutIndicator = "TIMEOUT"
+
+// busErrorIndicators сопоставляет индикаторы ошибок ELM327/STN с их источником
+var busErrorIndicators = []struct {
+	indicator string
+	source    string
+}{
+	{"CAN ERROR", ErrorSourceBus},       // Ошибка CAN (нет подтверждения кадров, неверная скорость)
+	{"BUS BUSY", ErrorSourceBus},        // Шина занята другим трафиком
+	{"BUS ERROR", ErrorSourceBus},       // Общая ошибка шины
+	{"FB ERROR", ErrorSourceBus},        // Ошибка обратной связи (проблема проводки/трансивера)
+	{"DATA ERROR", ErrorSourceBus},      // Ошибка контрольной суммы/кадра
+	{"RX ERROR", ErrorSourceBus},        // Ошибка приема CAN кадра
+	{"ERR9", ErrorSourceBus},            // Внутренние коды ошибок CAN (ERR94 и т.п.)
+	{"BUFFER FULL", ErrorSourceAdapter}, // Переполнен буфер адаптера
+	{"LV RESET", ErrorSourceAdapter},    // Адаптер перезагрузился из-за низкого напряжения
+}
+
+// DetectBusError проверяет ответ ELM327 на наличие индикатора ошибки шины или адаптера
+func DetectBusError(response string) (indicator string, source string, ok bool) {
+	upper := strings.ToUpper(response)
+	for _, e := range busErrorIndicators {
+		if strings.Contains(upper, e.indicator) {
+			return e.indicator, e.source, true
+		}
+	}
+	return "", "", false
+}
+
+// BusHealthReport представляет снимок состояния шины для публикации
+type BusHealthReport struct {
+	State             string         `json:"state"`              // ok, degraded, backoff
+	ErrorCounts       map[string]int `json:"error_counts"`       // Счетчики по индикаторам
+	BusErrors         int            `json:"bus_errors"`         // Всего ошибок шины автомобиля
+	AdapterErrors     int            `json:"adapter_errors"`     // Всего ошибок адаптера
+	ConsecutiveErrors int            `json:"consecutive_errors"` // Ошибок подряд
+	LastError         string         `json:"last_error,omitempty"`
+	LastErrorSource   string         `json:"last_error_source,omitempty"`
+	LastErrorAt       *time.Time     `json:"last_error_at,omitempty"`
+	BackoffFactor     int            `json:"backoff_factor"` // Текущий множитель интервала опроса
+}
+
+// BusHealth отслеживает ошибки шины и управляет отступом при опросе
+type BusHealth struct {
+	mu              sync.Mutex
+	counts          map[string]int
+	busErrors       int
+	adapterErrors   int
+	consecutive     int
+	backoffLevel    int
+	lastError       string
+	lastErrorSource string
+	lastErrorAt     time.Time
+	reinitPending   bool // Менеджер команд должен заново определить протокол
+	cycleSuccess    bool // В текущем цикле опроса были успешные ответы
+	cycleError      bool // В текущем цикле опроса были ошибки
+	cleanCycles     int  // Циклов опроса подряд без ошибок
+	levelChanged    bool // Отступ снят, изменение еще не опубликовано
+}
+
+// NewBusHealth создает новый трекер состояния шины
+func NewBusHealth() *BusHealth {
+	return &BusHealth{
+		counts: make(map[string]int),
+	}
+}
+
+// RecordError регистрирует ошибку шины или адаптера
+func (h *BusHealth) RecordError(indicator, source string) {
+	h.recordError(indicator, source, true)
+}
+
+// recordError регистрирует ошибку; cycleError отмечает текущий цикл опроса как неудачный
+func (h *BusHealth) recordError(indicator, source string, cycleError bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if cycleError {
+		h.cycleError = true
+		h.cleanCycles = 0
+	}
+
+	h.counts[indicator]++
+	if source == ErrorSourceAdapter {
+		h.adapterErrors++
+	} else {
+		h.busErrors++
+	}
+	h.consecutive++
+	h.lastError = indicator
+	h.lastErrorSource = source
+	h.lastErrorAt = time.Now()
+
+	// Увеличиваем отступ только после нескольких ошибок подряд
+	if h.consecutive >= busErrorThreshold && h.backoffLevel < maxBackoffLevel {
+		h.backoffLevel++
+	}
+}
+
+// RecordStatus регистрирует текстовый статус адаптера как ошибку и возвращает
+// реакцию менеджера команд: потеря связи с шиной запрашивает повторную инициализацию,
+// повторяющиеся статусы увеличивают интервал опроса
+func (h *BusHealth) RecordStatus(status, source string) AdapterStatusReport {
+	// NO DATA на неподдерживаемый PID повторяется каждый цикл и не должен мешать
+	// снятию отступа, но по-прежнему учитывается в ошибках подряд
+	h.recordError(status, source, status != AdapterStatusNoData)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	action := StatusActionNone
+	switch {
+	case status == AdapterStatusUnableToConnect || status == AdapterStatusBusInitError:
+		h.reinitPending = true
+		action = StatusActionReinit
+	case h.backoffLevel > 0:
+		action = StatusActionBackoff
+	}
+
+	return AdapterStatusReport{Status: status, Source: source, Action: action, Count: h.counts[status]}
+}
+
+// TimeoutHandler возвращает обработчик таймаутов команд адаптера: таймаут учитывается
+// как ошибка адаптера (повторяющиеся таймауты замедляют опрос) и публикуется в bus_health
+func (h *BusHealth) TimeoutHandler(statusChan chan<- common.StatusEvent) func(command string) {
+	return func(command string) {
+		h.RecordError(TimeoutIndicator, ErrorSourceAdapter)
+		logger.Printf("Command timed out: %s", command)
+		sendStatus("bus_health", h.Report(), statusChan, logger)
+	}
+}
+
+// TakeReinit возвращает true один раз после запроса повторной инициализации
+func (h *BusHealth) TakeReinit() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	pending := h.reinitPending
+	h.reinitPending = false
+	return pending
+}
+
+// RecordSuccess регистрирует успешный ответ. Отступ снимается не отдельными ответами,
+// а циклами опроса без ошибок (EndCycle).
+// Возвращает true, если состояние шины изменилось
+func (h *BusHealth) RecordSuccess() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	changed := h.consecutive > 0 || h.levelChanged
+	h.consecutive = 0
+	h.levelChanged = false
+	h.cycleSuccess = true
+	return changed
+}
+
+// EndCycle завершает цикл опроса (вызывается менеджером команд перед отправкой следующего).
+// Отступ снимается на один уровень после busRecoveryCycles циклов подряд с успешными ответами
+// и без ошибок. Цикл без ответов не прерывает и не продолжает серию
+func (h *BusHealth) EndCycle() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	switch {
+	case h.cycleError:
+		h.cleanCycles = 0
+	case h.cycleSuccess:
+		h.cleanCycles++
+	}
+	h.cycleError = false
+	h.cycleSuccess = false
+
+	if h.backoffLevel > 0 && h.cleanCycles >= busRecoveryCycles {
+		h.backoffLevel--
+		h.cleanCycles = 0
+		h.levelChanged = true
+	}
+}
+
+// PollInterval возвращает интервал опроса с учетом текущего отступа
+func (h *BusHealth) PollInterval(base time.Duration) time.Duration {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return base * time.Duration(1<<h.backoffLevel)
+}
+
+// Report возвращает снимок текущего состояния шины
+func (h *BusHealth) Report() BusHealthReport {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	counts := make(map[string]int, len(h.counts))
+	for k, v := range h.counts {
+		counts[k] = v
+	}
+
+	report := BusHealthReport{
+		State:             h.state(),
+		ErrorCounts:       counts,
+		BusErrors:         h.busErrors,
+		AdapterErrors:     h.adapterErrors,
+		ConsecutiveErrors: h.consecutive,
+		LastError:         h.lastError,
+		LastErrorSource:   h.lastErrorSource,
+		BackoffFactor:     1 << h.backoffLevel,
+	}
+	if !h.lastErrorAt.IsZero() {
+		at := h.lastErrorAt
+		report.LastErrorAt = &at
+	}
+	return report
+}
+
+// state вычисляет состояние шины (вызывается под мьютексом)
+func (h *BusHealth) state() string {
+	switch {
+	case h.backoffLevel > 0:
+		return busStateBackoff
+	case h.consecutive > 0:
+		return busStateDegraded
+	case !h.lastErrorAt.IsZero() && time.Since(h.lastErrorAt) < busErrorDecay:
+		return busStateDegraded
+	default:
+		return busStateOK
+	}
+}
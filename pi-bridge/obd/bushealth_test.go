@@ -0,0 +1,163 @@
+package obd
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDetectBusError(t *testing.T) {
+	tests := []struct {
+		response          string
+		expectedIndicator string
+		expectedSource    string
+		expectError       bool
+	}{
+		{"CAN ERROR", "CAN ERROR", ErrorSourceBus, true},
+		{"BUS BUSY\r", "BUS BUSY", ErrorSourceBus, true},
+		{"FB ERROR", "FB ERROR", ErrorSourceBus, true},
+		{"ERR94", "ERR9", ErrorSourceBus, true},
+		{"BUFFER FULL", "BUFFER FULL", ErrorSourceAdapter, true},
+		{"41 0C 1A F0", "", "", false},
+		{"OK", "", "", false},
+	}
+
+	for _, tt := range tests {
+		indicator, source, ok := DetectBusError(tt.response)
+		if ok != tt.expectError {
+			t.Errorf("Expected detection %v for %q, got %v", tt.expectError, tt.response, ok)
+			continue
+		}
+		if indicator != tt.expectedIndicator || source != tt.expectedSource {
+			t.Errorf("Expected %s/%s for %q, got %s/%s", tt.expectedIndicator, tt.expectedSource, tt.response, indicator, source)
+		}
+	}
+}
+
+func TestBusHealthBackoff(t *testing.T) {
+	health := NewBusHealth()
+	base := 5 * time.Second
+
+	if interval := health.PollInterval(base); interval != base {
+		t.Errorf("Expected base interval %v, got %v", base, interval)
+	}
+
+	// Отступ не применяется до достижения порога
+	for i := 0; i < busErrorThreshold-1; i++ {
+		health.RecordError("CAN ERROR", ErrorSourceBus)
+	}
+	if interval := health.PollInterval(base); interval != base {
+		t.Errorf("Expected no backoff below threshold, got %v", interval)
+	}
+
+	// Достигаем порога и проверяем ограничение множителя
+	for i := 0; i < 10; i++ {
+		health.RecordError("CAN ERROR", ErrorSourceBus)
+	}
+	if interval := health.PollInterval(base); interval != base*8 {
+		t.Errorf("Expected capped backoff %v, got %v", base*8, interval)
+	}
+
+	// Один удачный цикл с десятком ответов не снимает отступ
+	if !health.RecordSuccess() {
+		t.Error("Expected state change after first success")
+	}
+	for i := 0; i < 12; i++ {
+		health.RecordSuccess()
+	}
+	health.EndCycle()
+	if interval := health.PollInterval(base); interval != base*8 {
+		t.Errorf("Expected backoff %v after one clean cycle, got %v", base*8, interval)
+	}
+}
+
+func TestBusHealthRecovery(t *testing.T) {
+	base := 5 * time.Second
+
+	// cycle проводит цикл опроса с успешными ответами и, при необходимости, ошибкой
+	cycle := func(health *BusHealth, failure string) {
+		health.RecordSuccess()
+		switch failure {
+		case "bus":
+			health.RecordError("CAN ERROR", ErrorSourceBus)
+		case "no data":
+			health.RecordStatus(AdapterStatusNoData, ErrorSourceBus)
+		}
+		health.RecordSuccess()
+		health.EndCycle()
+	}
+	backedOff := func() *BusHealth {
+		health := NewBusHealth()
+		for i := 0; i < busErrorThreshold+1; i++ {
+			health.RecordError("CAN ERROR", ErrorSourceBus)
+		}
+		health.EndCycle()
+		return health
+	}
+
+	tests := []struct {
+		name     string
+		cycles   []string
+		expected time.Duration
+	}{
+		{"clean cycles below recovery", []string{"", ""}, base * 4},
+		{"recovery cycles", []string{"", "", ""}, base * 2},
+		{"error resets recovery", []string{"", "", "bus", "", ""}, base * 4},
+		{"empty cycles are not counted", []string{"", "", "empty", "empty", ""}, base * 2},
+		{"unsupported PID does not block recovery", []string{"no data", "no data", "no data"}, base * 2},
+		{"full recovery", []string{"", "", "", "", "", ""}, base},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			health := backedOff()
+			if interval := health.PollInterval(base); interval != base*4 {
+				t.Fatalf("Expected initial backoff %v, got %v", base*4, interval)
+			}
+			for _, failure := range tt.cycles {
+				if failure == "empty" {
+					health.EndCycle()
+					continue
+				}
+				cycle(health, failure)
+			}
+			if interval := health.PollInterval(base); interval != tt.expected {
+				t.Errorf("Expected interval %v, got %v", tt.expected, interval)
+			}
+		})
+	}
+
+	// Снятие уровня публикуется со следующим успешным ответом
+	health := backedOff()
+	for i := 0; i < busRecoveryCycles; i++ {
+		cycle(health, "")
+	}
+	if !health.RecordSuccess() {
+		t.Error("Expected state change after backoff level decreased")
+	}
+}
+
+func TestBusHealthReport(t *testing.T) {
+	health := NewBusHealth()
+
+	report := health.Report()
+	if report.State != busStateOK {
+		t.Errorf("Expected state %s, got %s", busStateOK, report.State)
+	}
+
+	health.RecordError("BUS BUSY", ErrorSourceBus)
+	health.RecordError("BUFFER FULL", ErrorSourceAdapter)
+
+	report = health.Report()
+	if report.State != busStateDegraded {
+		t.Errorf("Expected state %s, got %s", busStateDegraded, report.State)
+	}
+	if report.BusErrors != 1 || report.AdapterErrors != 1 {
+		t.Errorf("Expected 1 bus and 1 adapter error, got %d and %d", report.BusErrors, report.AdapterErrors)
+	}
+	if report.ErrorCounts["BUS BUSY"] != 1 {
+		t.Errorf("Expected BUS BUSY count 1, got %d", report.ErrorCounts["BUS BUSY"])
+	}
+	if report.LastError != "BUFFER FULL" || report.LastErrorAt == nil {
+		t.Errorf("Expected last error BUFFER FULL with timestamp, got %s", report.LastError)
+	}
+}
@@ -0,0 +1,136 @@
+package obd
+
+import (
+	"sort"
+	"time"
+)
+
+// pollInterval - базовый интервал периодического опроса PID
+const pollInterval = 5 * time.Second
+
+// Источники метрик в каталоге
+const (
+	MetricSourceBuiltin = "builtin"
+	MetricSourceConfig  = "config"
+	MetricSourcePlugin  = "plugin"
+	MetricSourceRuntime = "runtime"
+)
+
+// metricDetail содержит справочные сведения о метрике для каталога
+type metricDetail struct {
+	Min         *float64
+	Max         *float64
+	Description string
+}
+
+// limits создает описание метрики с диапазоном значений
+func limits(min, max float64, description string) metricDetail {
+	return metricDetail{Min: &min, Max: &max, Description: description}
+}
+
+// metricDetails содержит диапазоны значений по SAE J1979 и описания встроенных метрик
+var metricDetails = map[string]metricDetail{
+	"0C": limits(0, 16383.75, "Engine RPM"),
+	"0D": limits(0, 255, "Vehicle speed"),
+	"05": limits(-40, 215, "Engine coolant temperature"),
+	"0F": limits(-40, 215, "Intake air temperature"),
+	"11": limits(0, 100, "Throttle position"),
+	"04": limits(0, 100, "Calculated engine load"),
+	"2F": limits(0, 100, "Fuel tank level input"),
+	"0A": limits(0, 765, "Fuel pressure (gauge)"),
+	"06": limits(-100, 99.2, "Short term fuel trim, bank 1"),
+	"07": limits(-100, 99.2, "Long term fuel trim, bank 1"),
+	"10": limits(0, 655.35, "Mass air flow rate"),
+	"5E": limits(0, 3276.75, "Engine fuel rate"),
+	"0B": limits(0, 255, "Intake manifold absolute pressure"),
+	"33": limits(0, 255, "Absolute barometric pressure"),
+	"01": {Description: "Monitor status since DTCs cleared (raw bitmap)"},
+	"21": limits(0, 65535, "Distance traveled with MIL on"),
+	"A6": limits(0, 429496729.5, "Odometer"),
+	"45": limits(0, 100, "Relative throttle position"),
+	"47": limits(0, 100, "Absolute throttle position B"),
+	"48": limits(0, 100, "Absolute throttle position C"),
+	"49": limits(0, 100, "Accelerator pedal position D"),
+	"4A": limits(0, 100, "Accelerator pedal position E"),
+	"4B": limits(0, 100, "Accelerator pedal position F"),
+	"4C": limits(0, 100, "Commanded throttle actuator"),
+	"5B": limits(0, 100, "Hybrid battery pack remaining life"),
+	"51": limits(0, 23, "Fuel type (0x08 electric, 0x0F-0x17 hybrid)"),
+	"9A": limits(0, 1023.984375, "Hybrid/EV battery system voltage"),
+}
+
+// metricSources содержит источник метрик, добавленных не встроенными декодерами
+var metricSources = map[string]string{}
+
+// MetricInfo описывает метрику в каталоге для автоматической настройки дашбордов
+type MetricInfo struct {
+	Metric       string   `json:"metric"`
+	Unit         string   `json:"unit"`
+	PID          string   `json:"pid"`
+	Source       string   `json:"source"`                    // builtin, config, plugin или runtime
+	Header       string   `json:"header,omitempty"`          // Заголовок запроса группы опроса
+	PollInterval float64  `json:"poll_interval_s,omitempty"` // 0 - только по запросу
+	Min          *float64 `json:"min,omitempty"`
+	Max          *float64 `json:"max,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	Precision    *int     `json:"precision,omitempty"` // Знаков после запятой (не задано - без округления)
+}
+
+// Catalog возвращает каталог всех доступных метрик, отсортированный по PID
+// (в режиме J1939 - параметры групп J1939)
+func Catalog() []MetricInfo {
+	if j1939Mode {
+		return j1939Catalog()
+	}
+
+	polled := make(map[string]string) // PID -> заголовок запроса
+	for _, pid := range pollPIDs {
+		polled[pid] = ""
+	}
+	for _, group := range pollGroups {
+		for _, pid := range group.PIDs {
+			polled[pid] = group.Header
+		}
+	}
+
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	pids := pidParser.PIDs()
+	catalog := make([]MetricInfo, 0, len(pids))
+	for _, pid := range pids {
+		info := MetricInfo{
+			Metric: GetMetricName(pid),
+			Unit:   GetMetricUnit(pid),
+			PID:    pid,
+			Source: MetricSourceBuiltin,
+		}
+		if source, ok := metricSources[pid]; ok {
+			info.Source = source
+		}
+		if header, ok := polled[pid]; ok {
+			info.Header = header
+			info.PollInterval = pollInterval.Seconds()
+		}
+		if detail, ok := metricDetails[pid]; ok {
+			info.Min = convertLimit(detail.Min, info.Unit)
+			info.Max = convertLimit(detail.Max, info.Unit)
+			info.Description = detail.Description
+		}
+		_, info.Unit = ConvertValue(0, info.Unit)
+		info.Precision = catalogPrecision(info.Metric)
+		catalog = append(catalog, info)
+	}
+
+	sort.Slice(catalog, func(i, j int) bool { return catalog[i].PID < catalog[j].PID })
+	return catalog
+}
+
+// convertLimit переводит границу диапазона в выбранную систему единиц
+func convertLimit(limit *float64, unit string) *float64 {
+	if limit == nil {
+		return nil
+	}
+	value, _ := ConvertValue(*limit, unit)
+	return &value
+}
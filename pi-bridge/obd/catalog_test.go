@@ -0,0 +1,63 @@
+package obd
+
+import "testing"
+
+func TestCatalog(t *testing.T) {
+	catalog := Catalog()
+	if len(catalog) != len(GetSupportedPIDs()) {
+		t.Fatalf("Expected %d metrics, got %d", len(GetSupportedPIDs()), len(catalog))
+	}
+
+	byPID := make(map[string]MetricInfo)
+	for i, info := range catalog {
+		if i > 0 && catalog[i-1].PID >= info.PID {
+			t.Errorf("Catalog is not sorted by PID at %s", info.PID)
+		}
+		byPID[info.PID] = info
+	}
+
+	rpm := byPID["0C"]
+	if rpm.Metric != "engine_rpm" || rpm.Unit != "rpm" || rpm.Source != MetricSourceBuiltin {
+		t.Errorf("Unexpected RPM entry: %+v", rpm)
+	}
+	if rpm.PollInterval != pollInterval.Seconds() {
+		t.Errorf("Expected RPM to be polled every %v s, got %v", pollInterval.Seconds(), rpm.PollInterval)
+	}
+	if rpm.Min == nil || rpm.Max == nil || *rpm.Min != 0 || *rpm.Max != 16383.75 {
+		t.Errorf("Unexpected RPM limits: %+v", rpm)
+	}
+
+	// PID 21 доступен только по запросу
+	if byPID["21"].PollInterval != 0 {
+		t.Errorf("Expected PID 21 to be on-demand, got %v", byPID["21"].PollInterval)
+	}
+}
+
+func TestCatalogCustomPID(t *testing.T) {
+	max := 150.0
+	err := RegisterCustomPIDs([]CustomPID{
+		{PID: "5C", Name: "oil_temperature", Unit: "°C", Bytes: 1, Formula: "A-40", Description: "Engine oil temperature", Max: &max},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer func() {
+		pidParser.Unregister("5C")
+		delete(metricDetails, "5C")
+		delete(metricSources, "5C")
+	}()
+
+	for _, info := range Catalog() {
+		if info.PID != "5C" {
+			continue
+		}
+		if info.Source != MetricSourceConfig || info.Description != "Engine oil temperature" {
+			t.Errorf("Unexpected custom entry: %+v", info)
+		}
+		if info.Min != nil || info.Max == nil || *info.Max != 150 {
+			t.Errorf("Unexpected custom limits: %+v", info)
+		}
+		return
+	}
+	t.Error("Custom PID 5C not found in catalog")
+}
@@ -0,0 +1,65 @@
+package obd
+
+import (
+	"strings"
+	"sync"
+)
+
+// BridgeCommandHandler разбирает аргументы служебной команды моста и возвращает
+// последовательность команд ELM327 для отправки адаптеру и запуск режима команды
+// (nil - команда не меняет состояние моста). Разбор не должен иметь побочных эффектов:
+// режим запускается, только если все команды последовательности прошли проверки
+// (режим только чтения, фильтр команд)
+type BridgeCommandHandler func(args []string) ([]string, func(), error)
+
+// BridgeCommands - реестр обработчиков служебных команд моста. У каждого автомобиля
+// свой реестр: обработчики (STREAM, SNIFF и т.п.) отправляют команды своему адаптеру
+type BridgeCommands struct {
+	mu       sync.RWMutex
+	handlers map[string]BridgeCommandHandler
+}
+
+// NewBridgeCommands создает реестр со встроенными командами моста
+func NewBridgeCommands() *BridgeCommands {
+	return &BridgeCommands{handlers: map[string]BridgeCommandHandler{
+		TopologyProbeCommand: handleTopologyProbe,
+	}}
+}
+
+// Register регистрирует обработчик служебной команды моста
+func (b *BridgeCommands) Register(name string, handler BridgeCommandHandler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[strings.ToUpper(name)] = handler
+}
+
+// Expand раскрывает служебные команды моста в последовательность команд ELM327 и
+// возвращает запуск режима команды. Обычные команды возвращаются без изменений
+func (b *BridgeCommands) Expand(command string) ([]string, func(), error) {
+	fields := strings.Fields(strings.ToUpper(command))
+	if len(fields) == 0 {
+		return []string{command}, nil, nil
+	}
+
+	b.mu.RLock()
+	handler, exists := b.handlers[fields[0]]
+	b.mu.RUnlock()
+
+	if !exists {
+		return []string{command}, nil, nil
+	}
+	return handler(fields[1:])
+}
+
+// bridgeCommands - общий реестр для процесса с одним автомобилем
+var bridgeCommands = NewBridgeCommands()
+
+// RegisterBridgeCommand регистрирует обработчик служебной команды моста в общем реестре
+func RegisterBridgeCommand(name string, handler BridgeCommandHandler) {
+	bridgeCommands.Register(name, handler)
+}
+
+// ExpandCommand раскрывает служебные команды моста по общему реестру
+func ExpandCommand(command string) ([]string, func(), error) {
+	return bridgeCommands.Expand(command)
+}
@@ -0,0 +1,72 @@
+package obd
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestExpandCommand(t *testing.T) {
+	cmds, _, err := ExpandCommand("010C")
+	if err != nil || len(cmds) != 1 || cmds[0] != "010C" {
+		t.Errorf("Expected raw command to pass through, got %v (%v)", cmds, err)
+	}
+
+	cmds, _, err = ExpandCommand("probe_topology")
+	if err != nil || len(cmds) != 2 || cmds[0] != "ATH1" || cmds[1] != "0100" {
+		t.Errorf("Expected basic probe sequence, got %v (%v)", cmds, err)
+	}
+
+	cmds, _, err = ExpandCommand("PROBE_TOPOLOGY UDS")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// ATH1, 0100, 8 пар (ATSH + 3E00) и восстановление заголовка
+	if len(cmds) != 2+16+1 {
+		t.Fatalf("Expected 19 commands for UDS sweep, got %d", len(cmds))
+	}
+	if cmds[2] != "ATSH 7E0" || cmds[3] != "3E00" || cmds[len(cmds)-1] != "ATSH 7DF" {
+		t.Errorf("Unexpected UDS sweep sequence: %v", cmds)
+	}
+}
+
+func TestRegisterBridgeCommand(t *testing.T) {
+	RegisterBridgeCommand("test_echo", func(args []string) ([]string, func(), error) {
+		if len(args) == 0 {
+			return nil, nil, fmt.Errorf("no args")
+		}
+		return args, nil, nil
+	})
+
+	cmds, _, err := ExpandCommand("TEST_ECHO atrv")
+	if err != nil || len(cmds) != 1 || cmds[0] != "ATRV" {
+		t.Errorf("Expected registered handler output [ATRV], got %v (%v)", cmds, err)
+	}
+
+	if _, _, err := ExpandCommand("TEST_ECHO"); err == nil {
+		t.Error("Expected handler error to be returned")
+	}
+}
+
+func TestBridgeCommandsAreIsolated(t *testing.T) {
+	first := NewBridgeCommands()
+	second := NewBridgeCommands()
+	first.Register("test_local", func(args []string) ([]string, func(), error) {
+		return []string{"ATRV"}, nil, nil
+	})
+
+	cmds, _, err := first.Expand("test_local")
+	if err != nil || len(cmds) != 1 || cmds[0] != "ATRV" {
+		t.Errorf("Expected registered handler output [ATRV], got %v (%v)", cmds, err)
+	}
+
+	// Команда другого реестра передается адаптеру как есть
+	cmds, _, err = second.Expand("test_local")
+	if err != nil || len(cmds) != 1 || cmds[0] != "test_local" {
+		t.Errorf("Expected unregistered command to pass through, got %v (%v)", cmds, err)
+	}
+
+	// Встроенные команды есть в каждом реестре
+	if cmds, _, err := second.Expand("PROBE_TOPOLOGY"); err != nil || len(cmds) != 2 {
+		t.Errorf("Expected built-in probe in new registry, got %v (%v)", cmds, err)
+	}
+}
@@ -0,0 +1,103 @@
+package obd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Config представляет конфигурацию OBD парсера
+type Config struct {
+	CustomPIDs      []CustomPID        `yaml:"custom_pids"`       // PID, определенные в конфигурации
+	PollGroups      []PollGroup        `yaml:"poll_groups"`       // Группы опроса с отдельными заголовками
+	PluginsDir      string             `yaml:"plugins_dir"`       // Каталог наборов декодеров
+	DTCScanInterval time.Duration      `yaml:"dtc_scan_interval"` // Период опроса DTC и PID 01 (0 - выключен)
+	Plausibility    PlausibilityConfig `yaml:"plausibility"`      // Допустимые диапазоны показаний
+	Derived         DerivedConfig      `yaml:"derived"`           // Вычисляемые метрики
+	Precision       PrecisionConfig    `yaml:"precision"`         // Точность публикуемых значений
+	Sniffer         SnifferConfig      `yaml:"sniffer"`           // Прослушивание шины CAN (команда SNIFF)
+	LowPower        LowPowerConfig     `yaml:"low_power"`         // Сон адаптера при заглушенном автомобиле
+	Ignition        IgnitionConfig     `yaml:"ignition"`          // Стоянка и запуск двигателя по напряжению
+
+	BatteryVoltageInterval time.Duration `yaml:"battery_voltage_interval"` // Период опроса ATRV (0 - выключен)
+	O2MonitorSensors       []string      `yaml:"o2_monitor_sensors"`       // Датчики O2 для опроса сервиса 05 (без CAN)
+	Protocol               string        `yaml:"protocol"`                 // obd2 (по умолчанию) или j1939
+	J1939PGNs              []string      `yaml:"j1939_pgns"`               // Группы J1939 для опроса (пусто - все известные)
+}
+
+// DefaultConfig возвращает конфигурацию по умолчанию
+func DefaultConfig() Config {
+	return Config{
+		DTCScanInterval:        time.Minute,
+		BatteryVoltageInterval: 30 * time.Second,
+		Plausibility:           DefaultPlausibilityConfig(),
+		Derived:                DefaultDerivedConfig(),
+	}
+}
+
+// CustomPID описывает PID, декодируемый формулой из конфигурации
+type CustomPID struct {
+	PID     string `yaml:"pid"`     // Код PID сервиса 01, например "5C"
+	Name    string `yaml:"name"`    // Название метрики
+	Unit    string `yaml:"unit"`    // Единица измерения
+	Bytes   int    `yaml:"bytes"`   // Количество байт данных в ответе
+	Formula string `yaml:"formula"` // Выражение над байтами A, B, C, ...
+	Poll    bool   `yaml:"poll"`    // Включить PID в периодический опрос
+
+	Description string   `yaml:"description"` // Описание для каталога метрик
+	Min         *float64 `yaml:"min"`         // Минимальное значение (опционально)
+	Max         *float64 `yaml:"max"`         // Максимальное значение (опционально)
+}
+
+// pollPIDs содержит PID для периодического опроса
+var pollPIDs = []string{"0C", "0D", "05", "0F", "11", "04", "2F", "0A", "0B", "10", "33", "A6", "5B"}
+
+// RegisterCustomPIDs компилирует формулы из конфигурации и добавляет PID к встроенным.
+// Вызывается при запуске до старта парсера
+func RegisterCustomPIDs(defs []CustomPID) error {
+	return registerCustomPIDs(defs, MetricSourceConfig)
+}
+
+// registerCustomPIDs регистрирует PID с формулами, запоминая их источник для каталога
+func registerCustomPIDs(defs []CustomPID, source string) error {
+	for _, def := range defs {
+		pid := strings.ToUpper(def.PID)
+		if len(pid) != 2 {
+			return fmt.Errorf("custom PID %q: PID must be 2 hex digits", def.PID)
+		}
+		if def.Name == "" {
+			return fmt.Errorf("custom PID %s: name is required", pid)
+		}
+
+		decoder, err := CompileFormula(pid, def.Formula, def.Bytes)
+		if err != nil {
+			return fmt.Errorf("custom PID %s: invalid formula %q: %v", pid, def.Formula, err)
+		}
+
+		if pidParser.Supports(pid) {
+			logger.Printf("Custom PID %s overrides built-in decoder", pid)
+		}
+
+		detail := metricDetail{Min: def.Min, Max: def.Max, Description: def.Description}
+		if err := registerPID(pid, def.Name, def.Unit, decoder, source, &detail); err != nil {
+			return fmt.Errorf("custom PID %s: %v", pid, err)
+		}
+
+		if def.Poll && !containsPID(pollPIDs, pid) {
+			pollPIDs = append(pollPIDs, pid)
+		}
+
+		logger.Printf("Registered custom PID %s (%s, %s): %s", pid, def.Name, def.Unit, def.Formula)
+	}
+	return nil
+}
+
+// containsPID проверяет наличие PID в списке
+func containsPID(pids []string, pid string) bool {
+	for _, p := range pids {
+		if p == pid {
+			return true
+		}
+	}
+	return false
+}
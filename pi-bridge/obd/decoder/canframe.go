@@ -0,0 +1,108 @@
+package decoder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CANFrame представляет строку ответа ELM327 с включенными заголовками
+type CANFrame struct {
+	Header string // Адрес отправителя: "7E8" для 11-бит или "18DAF110" для 29-бит
+	Source string // Адрес ЭБУ-отправителя ("7E8" или "10")
+	Data   []byte // Байты данных (включая байт длины PCI)
+}
+
+// ParseCANFrame разбирает строку ответа с заголовком CAN, например "7E8 06 41 00 BE 3F A8 13"
+// или "18 DA F1 10 06 41 00 BE 3F A8 13"
+func ParseCANFrame(line string) (*CANFrame, error) {
+	parts := strings.Fields(strings.TrimSpace(line))
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("frame too short: %q", line)
+	}
+
+	frame := &CANFrame{}
+	var dataParts []string
+
+	switch {
+	case len(parts[0]) == 3:
+		// 11-битный заголовок: "7E8"
+		if _, err := strconv.ParseUint(parts[0], 16, 16); err != nil {
+			return nil, fmt.Errorf("invalid 11-bit header %s: %v", parts[0], err)
+		}
+		frame.Header = parts[0]
+		frame.Source = parts[0]
+		dataParts = parts[1:]
+	case len(parts) >= 5 && len(parts[0]) == 2 && strings.EqualFold(parts[1], "DA"):
+		// 29-битный заголовок: "18 DA F1 10"
+		frame.Header = strings.Join(parts[:4], "")
+		frame.Source = parts[3]
+		dataParts = parts[4:]
+	default:
+		return nil, fmt.Errorf("no CAN header in %q", line)
+	}
+
+	frame.Data = make([]byte, len(dataParts))
+	for i, part := range dataParts {
+		val, err := strconv.ParseUint(part, 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex data %s: %v", part, err)
+		}
+		frame.Data[i] = byte(val)
+	}
+
+	return frame, nil
+}
+
+// ParseMonitorFrame разбирает кадр, выведенный адаптером в режиме мониторинга шины (ATMA):
+// "3B4 01 02 03" (11 бит) или "18 FE F1 00 01 02 03" (29 бит). В отличие от ответов
+// на запросы, 29-битный идентификатор может быть любым, а не только диагностическим
+// "18 DA xx xx". Кадры с отметкой ошибки приема ("<RX ERROR", "<DATA ERROR") отклоняются
+func ParseMonitorFrame(line string) (*CANFrame, error) {
+	parts := strings.Fields(strings.TrimSpace(line))
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("empty frame")
+	}
+
+	frame := &CANFrame{}
+	var dataParts []string
+
+	switch {
+	case len(parts[0]) == 3:
+		if _, err := strconv.ParseUint(parts[0], 16, 16); err != nil {
+			return nil, fmt.Errorf("invalid 11-bit header %s: %v", parts[0], err)
+		}
+		frame.Header = strings.ToUpper(parts[0])
+		frame.Source = frame.Header
+		dataParts = parts[1:]
+	case len(parts) >= 4 && len(parts[0]) == 2:
+		// Старший байт 29-битного идентификатора занимает 5 бит
+		if first, err := strconv.ParseUint(parts[0], 16, 8); err != nil || first > 0x1F {
+			return nil, fmt.Errorf("invalid 29-bit header in %q", line)
+		}
+		for _, part := range parts[1:4] {
+			if _, err := strconv.ParseUint(part, 16, 8); err != nil || len(part) != 2 {
+				return nil, fmt.Errorf("invalid 29-bit header in %q", line)
+			}
+		}
+		frame.Header = strings.ToUpper(strings.Join(parts[:4], ""))
+		frame.Source = strings.ToUpper(parts[3])
+		dataParts = parts[4:]
+	default:
+		return nil, fmt.Errorf("no CAN header in %q", line)
+	}
+
+	if len(dataParts) > 8 {
+		return nil, fmt.Errorf("frame longer than 8 bytes: %q", line)
+	}
+	frame.Data = make([]byte, len(dataParts))
+	for i, part := range dataParts {
+		val, err := strconv.ParseUint(part, 16, 8)
+		if err != nil || len(part) != 2 {
+			return nil, fmt.Errorf("invalid hex data %s in %q", part, line)
+		}
+		frame.Data[i] = byte(val)
+	}
+
+	return frame, nil
+}
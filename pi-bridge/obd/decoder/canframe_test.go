@@ -0,0 +1,82 @@
+package decoder
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseCANFrame(t *testing.T) {
+	tests := []struct {
+		line           string
+		expectedHeader string
+		expectedSource string
+		expectedLen    int
+		expectError    bool
+	}{
+		{"7E8 06 41 00 BE 3F A8 13", "7E8", "7E8", 7, false},
+		{"18 DA F1 10 06 41 00 BE 3F A8 13", "18DAF110", "10", 7, false},
+		{"41 0C 1A F0", "", "", 0, true}, // Без заголовка
+		{"NO DATA", "", "", 0, true},
+		{"7E8 06 41 ZZ", "", "", 0, true}, // Неверные hex данные
+	}
+
+	for _, tt := range tests {
+		frame, err := ParseCANFrame(tt.line)
+
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("Expected error for line %q", tt.line)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error for line %q: %v", tt.line, err)
+			continue
+		}
+
+		if frame.Header != tt.expectedHeader || frame.Source != tt.expectedSource {
+			t.Errorf("Expected header %s/%s, got %s/%s", tt.expectedHeader, tt.expectedSource, frame.Header, frame.Source)
+		}
+
+		if len(frame.Data) != tt.expectedLen {
+			t.Errorf("Expected %d data bytes, got %d", tt.expectedLen, len(frame.Data))
+		}
+	}
+}
+
+func TestParseMonitorFrame(t *testing.T) {
+	tests := []struct {
+		line           string
+		expectedHeader string
+		expectedData   string
+		expectError    bool
+	}{
+		{"3B4 01 02 03", "3B4", "010203", false},
+		{"7e8 03 41 0D 00", "7E8", "03410D00", false},
+		{"18 FE F1 00 FF FF 1A 2B", "18FEF100", "FFFF1A2B", false},
+		{"0C F0 04 00 F0 7D 7D 00 00 00 F0 FF", "0CF00400", "F07D7D000000F0FF", false},
+		{"123", "123", "", false},                        // Кадр без данных (DLC 0)
+		{"7E8 01 02 <RX ERROR", "", "", true},            // Ошибка приема
+		{"BUFFER FULL", "", "", true},                    // Переполнение буфера адаптера
+		{"41 0C 1A F0", "", "", true},                    // Ответ без заголовка
+		{"3B4 01 02 03 04 05 06 07 08 09", "", "", true}, // Больше 8 байт
+	}
+
+	for _, tt := range tests {
+		frame, err := ParseMonitorFrame(tt.line)
+		if tt.expectError {
+			if err == nil {
+				t.Errorf("Expected error for line %q", tt.line)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Unexpected error for line %q: %v", tt.line, err)
+			continue
+		}
+		if frame.Header != tt.expectedHeader || fmt.Sprintf("%X", frame.Data) != tt.expectedData {
+			t.Errorf("%q: expected %s %s, got %s %X", tt.line, tt.expectedHeader, tt.expectedData, frame.Header, frame.Data)
+		}
+	}
+}
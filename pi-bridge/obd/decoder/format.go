@@ -0,0 +1,68 @@
+package decoder
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Форматы строк ответа ELM327. Формат зависит от ATH0/ATH1 и протокола шины
+const (
+	FormatHeaderless = "headerless" // ATH0: "41 0C 1A F0"
+	FormatCAN11      = "can_11bit"  // ATH1, CAN 11 бит: "7E8 04 41 0C 1A F0"
+	FormatCAN29      = "can_29bit"  // ATH1, CAN 29 бит: "18 DA F1 10 04 41 0C 1A F0" или J1939
+	FormatKLine      = "kline"      // ATH1, ISO 9141-2, KWP2000, J1850: "48 6B 10 41 0C 1A F0 1A"
+)
+
+// DetectFormat определяет формат ответа по первой строке с данными. Текстовые статусы
+// адаптера ("OK", "NO DATA", "SEARCHING...") формат не определяют, для них возвращается false
+func DetectFormat(response string) (string, bool) {
+	lines := strings.FieldsFunc(response, func(r rune) bool { return r == '\r' || r == '\n' })
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if format, ok := detectLineFormat(line); ok {
+			return format, true
+		}
+	}
+	return "", false
+}
+
+// detectLineFormat определяет формат одной строки ответа
+func detectLineFormat(line string) (string, bool) {
+	if frame, err := ParseCANFrame(line); err == nil {
+		if len(frame.Header) == 3 {
+			return FormatCAN11, true
+		}
+		return FormatCAN29, true
+	}
+	if isKLineLine(line) {
+		return FormatKLine, true
+	}
+	if isJ1939Line(line) {
+		return FormatCAN29, true
+	}
+	if headerlessLengthPattern.MatchString(line) || headerlessFramePattern.MatchString(line) {
+		return FormatHeaderless, true
+	}
+	if _, err := parseHeaderlessLine(line); err == nil {
+		return FormatHeaderless, true
+	}
+	return "", false
+}
+
+// isJ1939Line проверяет, начинается ли строка с 29-битного идентификатора J1939:
+// первый байт содержит только приоритет и биты DP (не больше 1F)
+func isJ1939Line(line string) bool {
+	parts := strings.Fields(line)
+	if len(parts) < 5 || len(parts[0]) != 2 {
+		return false
+	}
+	first, err := strconv.ParseUint(parts[0], 16, 8)
+	if err != nil || first > 0x1F {
+		return false
+	}
+	_, _, _, err = parseJ1939Line(line)
+	return err == nil
+}
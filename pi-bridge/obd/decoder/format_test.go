@@ -0,0 +1,31 @@
+package decoder
+
+import "testing"
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		response string
+		format   string
+	}{
+		{"41 0C 1A F0", FormatHeaderless},
+		{"014\r0: 49 02 01 31 44 34\r1: 47 50 30 30 52 35 35", FormatHeaderless},
+		{"7E8 04 41 0C 1A F0", FormatCAN11},
+		{"18 DA F1 10 04 41 0C 1A F0", FormatCAN29},
+		{"18 FE EE 00 8C FF FF FF FF FF FF FF", FormatCAN29},
+		{"48 6B 10 41 0C 1A F0 1A", FormatKLine},
+		{"84 F1 11 41 0C 1A F0 DD", FormatKLine},
+		{"SEARCHING...\r7E8 04 41 0C 1A F0", FormatCAN11},
+	}
+	for _, tt := range tests {
+		format, ok := DetectFormat(tt.response)
+		if !ok || format != tt.format {
+			t.Errorf("%q: expected %s, got %s (%v)", tt.response, tt.format, format, ok)
+		}
+	}
+
+	for _, response := range []string{"OK", "NO DATA", "SEARCHING...", "ELM327 v1.5", ""} {
+		if format, ok := DetectFormat(response); ok {
+			t.Errorf("Expected %q not to define format, got %s", response, format)
+		}
+	}
+}
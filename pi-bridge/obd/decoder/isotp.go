@@ -0,0 +1,214 @@
+package decoder
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Типы кадров ISO-TP (ISO 15765-2) по старшему полубайту байта PCI
+const (
+	isotpSingleFrame      = 0x0
+	isotpFirstFrame       = 0x1
+	isotpConsecutiveFrame = 0x2
+)
+
+// Форматы многокадрового ответа ELM327 без заголовков (ATH0):
+//
+//	014
+//	0: 49 02 01 31 44 34
+//	1: 47 50 30 30 52 35 35
+var (
+	headerlessLengthPattern = regexp.MustCompile(`^[0-9A-Fa-f]{3}$`)
+	headerlessFramePattern  = regexp.MustCompile(`^([0-9A-Fa-f]):\s*(.*)$`)
+)
+
+// ECUMessage представляет полностью собранный ответ одного ЭБУ
+type ECUMessage struct {
+	ECU     string // Адрес ЭБУ-отправителя ("" для ответов без заголовков)
+	Payload []byte // Данные, начиная с байта сервиса ответа (например, 0x49)
+	Raw     string // Строки ответа, из которых собрано сообщение
+}
+
+// isotpAssembly накапливает кадры многокадрового ответа одного ЭБУ
+type isotpAssembly struct {
+	total   int    // Ожидаемая длина данных
+	nextSeq byte   // Ожидаемый номер следующего кадра (0-F)
+	payload []byte // Собранные данные
+	raw     []string
+}
+
+// append добавляет данные кадра и возвращает true, когда сообщение собрано
+func (a *isotpAssembly) append(data []byte, line string) bool {
+	a.payload = append(a.payload, data...)
+	a.raw = append(a.raw, line)
+	a.nextSeq = (a.nextSeq + 1) & 0x0F
+	if len(a.payload) >= a.total {
+		a.payload = a.payload[:a.total] // Отбрасываем заполнение последнего кадра
+		return true
+	}
+	return false
+}
+
+// message возвращает собранное сообщение
+func (a *isotpAssembly) message(ecu string) ECUMessage {
+	return ECUMessage{ECU: ecu, Payload: a.payload, Raw: strings.Join(a.raw, "\r")}
+}
+
+// ReassembleResponse разбирает ответ ELM327 на сообщения по ЭБУ, собирая многокадровые
+// ответы ISO-TP (первый кадр 10 xx и последовательные 21, 22, ...), например VIN или
+// список DTC. Ответы разных ЭБУ могут чередоваться, они собираются независимо
+func ReassembleResponse(response string) ([]ECUMessage, error) {
+	var messages []ECUMessage
+	var firstErr error
+	fail := func(err error) {
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	assemblies := make(map[string]*isotpAssembly)
+
+	lines := strings.FieldsFunc(response, func(r rune) bool { return r == '\r' || r == '\n' })
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		// Ответ с заголовками CAN
+		if frame, err := ParseCANFrame(line); err == nil {
+			message, complete, err := assembleCANFrame(frame, line, assemblies)
+			if err != nil {
+				fail(err)
+			} else if complete {
+				messages = append(messages, message)
+			}
+			continue
+		}
+
+		// Ответ K-line с заголовками: заголовок и контрольная сумма отбрасываются,
+		// поврежденный кадр не разбирается как ответ без заголовков
+		if isKLineLine(line) {
+			frame, err := ParseKLineFrame(line)
+			if err != nil {
+				fail(err)
+			} else {
+				messages = append(messages, ECUMessage{ECU: frame.Source, Payload: frame.Data, Raw: line})
+			}
+			continue
+		}
+
+		// Многокадровый ответ без заголовков: строка длины и пронумерованные кадры
+		if headerlessLengthPattern.MatchString(line) {
+			total, _ := strconv.ParseUint(line, 16, 16)
+			assemblies[""] = &isotpAssembly{total: int(total), raw: []string{line}}
+			continue
+		}
+		if match := headerlessFramePattern.FindStringSubmatch(line); match != nil {
+			message, complete, err := assembleHeaderlessFrame(match[1], match[2], line, assemblies)
+			if err != nil {
+				fail(err)
+			} else if complete {
+				messages = append(messages, message)
+			}
+			continue
+		}
+
+		// Однострочный ответ без заголовков
+		payload, err := parseHeaderlessLine(line)
+		if err != nil {
+			fail(err)
+			continue
+		}
+		messages = append(messages, ECUMessage{Payload: payload, Raw: line})
+	}
+
+	for ecu := range assemblies {
+		fail(fmt.Errorf("incomplete multi-frame response from %q", ecu))
+	}
+
+	if len(messages) == 0 {
+		if firstErr == nil {
+			firstErr = fmt.Errorf("invalid response format: %s", strings.TrimSpace(response))
+		}
+		return nil, firstErr
+	}
+	return messages, nil
+}
+
+// assembleCANFrame обрабатывает кадр с заголовком CAN
+func assembleCANFrame(frame *CANFrame, line string, assemblies map[string]*isotpAssembly) (ECUMessage, bool, error) {
+	if len(frame.Data) < 2 {
+		return ECUMessage{}, false, fmt.Errorf("frame from %s too short", frame.Source)
+	}
+
+	pci := frame.Data[0]
+	switch pci >> 4 {
+	case isotpSingleFrame:
+		payload, err := singleFramePayload(frame)
+		if err != nil {
+			return ECUMessage{}, false, err
+		}
+		return ECUMessage{ECU: frame.Source, Payload: payload, Raw: line}, true, nil
+
+	case isotpFirstFrame:
+		total := int(pci&0x0F)<<8 | int(frame.Data[1])
+		assembly := &isotpAssembly{total: total}
+		assemblies[frame.Source] = assembly
+		if assembly.append(frame.Data[2:], line) {
+			delete(assemblies, frame.Source)
+			return assembly.message(frame.Source), true, nil
+		}
+		return ECUMessage{}, false, nil
+
+	case isotpConsecutiveFrame:
+		assembly, exists := assemblies[frame.Source]
+		if !exists {
+			return ECUMessage{}, false, fmt.Errorf("consecutive frame from %s without first frame", frame.Source)
+		}
+		if seq := pci & 0x0F; seq != assembly.nextSeq {
+			delete(assemblies, frame.Source)
+			return ECUMessage{}, false, fmt.Errorf("frame from %s out of sequence: expected %X, got %X", frame.Source, assembly.nextSeq, seq)
+		}
+		if assembly.append(frame.Data[1:], line) {
+			delete(assemblies, frame.Source)
+			return assembly.message(frame.Source), true, nil
+		}
+		return ECUMessage{}, false, nil
+	}
+
+	return ECUMessage{}, false, fmt.Errorf("unsupported frame type %02X from %s", pci, frame.Source)
+}
+
+// assembleHeaderlessFrame обрабатывает пронумерованный кадр ответа без заголовков
+func assembleHeaderlessFrame(seqText, dataText, line string, assemblies map[string]*isotpAssembly) (ECUMessage, bool, error) {
+	assembly, exists := assemblies[""]
+	if !exists {
+		return ECUMessage{}, false, fmt.Errorf("frame %q without length line", line)
+	}
+
+	seq, _ := strconv.ParseUint(seqText, 16, 8)
+	if byte(seq) != assembly.nextSeq {
+		delete(assemblies, "")
+		return ECUMessage{}, false, fmt.Errorf("frame out of sequence: expected %X, got %s", assembly.nextSeq, seqText)
+	}
+
+	parts := strings.Fields(dataText)
+	data := make([]byte, len(parts))
+	for i, part := range parts {
+		val, err := strconv.ParseUint(part, 16, 8)
+		if err != nil {
+			delete(assemblies, "")
+			return ECUMessage{}, false, fmt.Errorf("invalid hex data %s: %v", part, err)
+		}
+		data[i] = byte(val)
+	}
+
+	if assembly.append(data, line) {
+		delete(assemblies, "")
+		return assembly.message(""), true, nil
+	}
+	return ECUMessage{}, false, nil
+}
@@ -0,0 +1,99 @@
+package decoder
+
+import (
+	"bytes"
+	"testing"
+)
+
+// vinPayload - ответ 49 02 01 с VIN "1D4GP00R55B123456"
+var vinPayload = append([]byte{0x49, 0x02, 0x01}, []byte("1D4GP00R55B123456")...)
+
+func TestReassembleResponseMultiFrame(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		ecu      string
+	}{
+		{
+			name:     "CAN headers",
+			response: "7E8 10 14 49 02 01 31 44 34\r7E8 21 47 50 30 30 52 35 35\r7E8 22 42 31 32 33 34 35 36\r",
+			ecu:      "7E8",
+		},
+		{
+			name:     "29-bit headers",
+			response: "18 DA F1 10 10 14 49 02 01 31 44 34\r18 DA F1 10 21 47 50 30 30 52 35 35\r18 DA F1 10 22 42 31 32 33 34 35 36",
+			ecu:      "10",
+		},
+		{
+			name:     "Without headers",
+			response: "014\r0: 49 02 01 31 44 34\r1: 47 50 30 30 52 35 35\r2: 42 31 32 33 34 35 36\r",
+			ecu:      "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, err := ReassembleResponse(tt.response)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(messages) != 1 {
+				t.Fatalf("Expected 1 message, got %d", len(messages))
+			}
+			if messages[0].ECU != tt.ecu || !bytes.Equal(messages[0].Payload, vinPayload) {
+				t.Errorf("Unexpected message from %q: % X", messages[0].ECU, messages[0].Payload)
+			}
+		})
+	}
+}
+
+func TestReassembleResponseInterleavedECUs(t *testing.T) {
+	// Двигатель отвечает многокадровым списком DTC, коробка передач - одиночным кадром
+	response := "7E8 10 0A 43 04 01 33 01 71\r" +
+		"7E9 03 43 01 07\r" +
+		"7E8 21 02 20 03 00 55 55 55\r"
+
+	messages, err := ReassembleResponse(response)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+
+	if messages[0].ECU != "7E9" || !bytes.Equal(messages[0].Payload, []byte{0x43, 0x01, 0x07}) {
+		t.Errorf("Unexpected transmission message: %+v", messages[0])
+	}
+	expected := []byte{0x43, 0x04, 0x01, 0x33, 0x01, 0x71, 0x02, 0x20, 0x03, 0x00}
+	if messages[1].ECU != "7E8" || !bytes.Equal(messages[1].Payload, expected) {
+		t.Errorf("Unexpected engine message: % X", messages[1].Payload)
+	}
+}
+
+func TestReassembleResponseErrors(t *testing.T) {
+	responses := []string{
+		"7E8 10 14 49 02 01 31 44 34\r7E8 21 47 50 30 30 52 35 35", // Не хватает кадра
+		"7E8 10 14 49 02 01 31 44 34\r7E8 22 42 31 32 33 34 35 36", // Пропущен кадр 21
+		"7E8 21 47 50 30 30 52 35 35",                              // Нет первого кадра
+		"0: 49 02 01 31 44 34",                                     // Нет строки длины
+		"NO DATA",                                                  // Нет данных
+	}
+
+	for _, response := range responses {
+		if _, err := ReassembleResponse(response); err == nil {
+			t.Errorf("Expected error for response %q", response)
+		}
+	}
+}
+
+func BenchmarkReassembleMultiFrame(b *testing.B) {
+	response := "7E8 10 14 49 02 01 31 44 34\r7E8 21 47 50 30 30 52 35 35\r7E8 22 42 31 32 33 34 35 36"
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ReassembleResponse(response); err != nil {
+			b.Fatalf("ReassembleResponse failed: %v", err)
+		}
+	}
+}
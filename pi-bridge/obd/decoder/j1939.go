@@ -0,0 +1,195 @@
+package decoder
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// J1939SPN описывает параметр (SPN) в группе параметров (PGN) SAE J1939-71
+type J1939SPN struct {
+	PGN    uint32  // Группа параметров, например 0xFEEE
+	SPN    int     // Номер параметра
+	Name   string  // Название метрики
+	Unit   string  // Единица измерения
+	Start  int     // Смещение первого байта в данных (с нуля)
+	Length int     // Длина в байтах: 1, 2 или 4 (порядок байтов - little-endian)
+	Scale  float64 // Цена младшего разряда
+	Offset float64 // Смещение значения
+}
+
+// j1939SPNs содержит поддерживаемые параметры. Названия совпадают с метриками OBD-II,
+// чтобы одни и те же дашборды работали для обоих протоколов
+var j1939SPNs = []J1939SPN{
+	{PGN: 0xF003, SPN: 91, Name: "accelerator_pedal_position", Unit: "%", Start: 1, Length: 1, Scale: 0.4},
+	{PGN: 0xF003, SPN: 92, Name: "engine_load", Unit: "%", Start: 2, Length: 1, Scale: 1},
+	{PGN: 0xF004, SPN: 190, Name: "engine_rpm", Unit: "rpm", Start: 3, Length: 2, Scale: 0.125},
+	{PGN: 0xFE56, SPN: 1761, Name: "def_level", Unit: "%", Start: 0, Length: 1, Scale: 0.4},
+	{PGN: 0xFEE0, SPN: 245, Name: "odometer", Unit: "km", Start: 4, Length: 4, Scale: 0.125},
+	{PGN: 0xFEE5, SPN: 247, Name: "engine_hours", Unit: "h", Start: 0, Length: 4, Scale: 0.05},
+	{PGN: 0xFEEE, SPN: 110, Name: "coolant_temperature", Unit: "°C", Start: 0, Length: 1, Scale: 1, Offset: -40},
+	{PGN: 0xFEEF, SPN: 100, Name: "oil_pressure", Unit: "kPa", Start: 3, Length: 1, Scale: 4},
+	{PGN: 0xFEF1, SPN: 84, Name: "vehicle_speed", Unit: "km/h", Start: 1, Length: 2, Scale: 1.0 / 256},
+	{PGN: 0xFEF2, SPN: 183, Name: "fuel_rate", Unit: "L/h", Start: 0, Length: 2, Scale: 0.05},
+	{PGN: 0xFEF5, SPN: 108, Name: "barometric_pressure", Unit: "kPa", Start: 0, Length: 1, Scale: 0.5},
+	{PGN: 0xFEF6, SPN: 102, Name: "boost_pressure", Unit: "kPa", Start: 1, Length: 1, Scale: 2},
+	{PGN: 0xFEF7, SPN: 168, Name: "battery_voltage", Unit: "V", Start: 4, Length: 2, Scale: 0.05},
+	{PGN: 0xFEFC, SPN: 96, Name: "fuel_level", Unit: "%", Start: 1, Length: 1, Scale: 0.4},
+}
+
+// j1939ValidLimits - максимальные сырые значения с данными; большие значения
+// означают ошибку датчика или отсутствие параметра (0xFE.., 0xFF..)
+var j1939ValidLimits = map[int]uint32{1: 0xFA, 2: 0xFAFF, 4: 0xFAFFFFFF}
+
+// J1939SPNs возвращает поддерживаемые параметры, упорядоченные по PGN и SPN
+func J1939SPNs() []J1939SPN {
+	spns := make([]J1939SPN, len(j1939SPNs))
+	copy(spns, j1939SPNs)
+	sort.Slice(spns, func(i, j int) bool {
+		if spns[i].PGN != spns[j].PGN {
+			return spns[i].PGN < spns[j].PGN
+		}
+		return spns[i].SPN < spns[j].SPN
+	})
+	return spns
+}
+
+// J1939PGNs возвращает отсортированный список групп с поддерживаемыми параметрами
+func J1939PGNs() []uint32 {
+	var pgns []uint32
+	for _, spn := range J1939SPNs() {
+		if len(pgns) == 0 || pgns[len(pgns)-1] != spn.PGN {
+			pgns = append(pgns, spn.PGN)
+		}
+	}
+	return pgns
+}
+
+// J1939Request формирует запрос группы для ELM327 в протоколе J1939 ("00FEEE").
+// Адаптер в формате данных ELM (ATJE, по умолчанию) сам меняет порядок байтов
+func J1939Request(pgn uint32) string {
+	return fmt.Sprintf("%06X", pgn)
+}
+
+// DecodeJ1939 разбирает ответ ELM327 в протоколе J1939 и возвращает значения всех
+// известных параметров. Каждая строка должна содержать 29-битный заголовок (ATH1):
+// "18 FE EE 00 8C ..." при ATJHF0 или "6 0FEEE 00 8C ..." при форматировании заголовков.
+// В поле PID телеметрии записывается группа, в поле ECU - адрес отправителя
+func (p *Parser) DecodeJ1939(response string) ([]*Telemetry, error) {
+	var telemetries []*Telemetry
+	var firstErr error
+	lines := strings.FieldsFunc(response, func(r rune) bool { return r == '\r' || r == '\n' })
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		pgn, source, data, err := parseJ1939Line(line)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		decoded := false
+		for _, spn := range j1939SPNs {
+			if spn.PGN != pgn {
+				continue
+			}
+			decoded = true
+			value, ok := decodeJ1939SPN(spn, data)
+			if !ok {
+				continue
+			}
+			telemetries = append(telemetries, &Telemetry{
+				PID:       fmt.Sprintf("%04X", pgn),
+				Metric:    spn.Name,
+				Value:     value,
+				Unit:      spn.Unit,
+				Timestamp: p.now().Unix(),
+				Raw:       line,
+				ECU:       source,
+			})
+		}
+		if !decoded && firstErr == nil {
+			firstErr = fmt.Errorf("unsupported PGN: %04X", pgn)
+		}
+	}
+
+	if len(telemetries) == 0 {
+		if firstErr == nil {
+			firstErr = fmt.Errorf("no available J1939 parameters in %q", response)
+		}
+		return nil, firstErr
+	}
+	return telemetries, nil
+}
+
+// parseJ1939Line извлекает группу, адрес отправителя и данные из строки с заголовком
+func parseJ1939Line(line string) (uint32, string, []byte, error) {
+	parts := strings.Fields(line)
+
+	var pgn uint32
+	var source string
+	var dataParts []string
+	switch {
+	case len(parts) >= 4 && len(parts[0]) == 1 && len(parts[1]) == 5:
+		// Форматированный заголовок: приоритет, PGN и адрес отправителя
+		value, err := strconv.ParseUint(parts[1], 16, 32)
+		if err != nil {
+			return 0, "", nil, fmt.Errorf("invalid J1939 PGN %s: %v", parts[1], err)
+		}
+		pgn = uint32(value)
+		source = parts[2]
+		dataParts = parts[3:]
+	case len(parts) >= 5 && len(parts[0]) == 2:
+		// 29-битный идентификатор CAN: приоритет и DP, PF, PS, адрес отправителя
+		header := make([]byte, 4)
+		for i := range header {
+			value, err := strconv.ParseUint(parts[i], 16, 8)
+			if err != nil {
+				return 0, "", nil, fmt.Errorf("invalid J1939 header %s: %v", strings.Join(parts[:4], " "), err)
+			}
+			header[i] = byte(value)
+		}
+		pgn = uint32(header[0]&0x03)<<16 | uint32(header[1])<<8 | uint32(header[2])
+		source = parts[3]
+		dataParts = parts[4:]
+	default:
+		return 0, "", nil, fmt.Errorf("no J1939 header in %q", line)
+	}
+
+	// В группах PDU1 (PF < F0) байт PS - адрес получателя, а не часть номера группы
+	if (pgn>>8)&0xFF < 0xF0 {
+		pgn &^= 0xFF
+	}
+
+	data := make([]byte, len(dataParts))
+	for i, part := range dataParts {
+		value, err := strconv.ParseUint(part, 16, 8)
+		if err != nil {
+			return 0, "", nil, fmt.Errorf("invalid hex data %s: %v", part, err)
+		}
+		data[i] = byte(value)
+	}
+	return pgn, strings.ToUpper(source), data, nil
+}
+
+// decodeJ1939SPN декодирует параметр; false - параметра нет в данных или он недоступен
+func decodeJ1939SPN(spn J1939SPN, data []byte) (float64, bool) {
+	if spn.Start+spn.Length > len(data) {
+		return 0, false
+	}
+
+	var raw uint32
+	for i := spn.Length - 1; i >= 0; i-- {
+		raw = raw<<8 | uint32(data[spn.Start+i])
+	}
+	if raw > j1939ValidLimits[spn.Length] {
+		return 0, false
+	}
+	return float64(raw)*spn.Scale + spn.Offset, true
+}
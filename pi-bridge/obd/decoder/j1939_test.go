@@ -0,0 +1,114 @@
+package decoder
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDecodeJ1939(t *testing.T) {
+	parser := New()
+
+	tests := []struct {
+		name     string
+		response string
+		pid      string
+		ecu      string
+		values   map[string]float64
+		hasError bool
+	}{
+		{
+			name:     "EEC1 engine speed, priority 3",
+			response: "0C F0 04 00 F0 7D 7D 00 1A FF FF FF",
+			pid:      "F004",
+			ecu:      "00",
+			values:   map[string]float64{"engine_rpm": 832},
+		},
+		{
+			name:     "Engine temperature",
+			response: "18 FE EE 00 8C FF FF FF FF FF FF FF",
+			pid:      "FEEE",
+			ecu:      "00",
+			values:   map[string]float64{"coolant_temperature": 100},
+		},
+		{
+			name:     "Engine hours",
+			response: "18 FE E5 00 10 27 00 00 FF FF FF FF",
+			pid:      "FEE5",
+			ecu:      "00",
+			values:   map[string]float64{"engine_hours": 500},
+		},
+		{
+			name:     "DEF level from aftertreatment ECU",
+			response: "18 FE 56 3D 7D FF FF FF FF FF FF FF",
+			pid:      "FE56",
+			ecu:      "3D",
+			values:   map[string]float64{"def_level": 50},
+		},
+		{
+			name:     "EEC2 with two parameters",
+			response: "0C F0 03 00 FF 64 28 FF FF FF FF FF",
+			pid:      "F003",
+			ecu:      "00",
+			values:   map[string]float64{"accelerator_pedal_position": 40, "engine_load": 40},
+		},
+		{
+			name:     "Formatted header",
+			response: "6 0FEEE 00 8C FF FF FF FF FF FF FF",
+			pid:      "FEEE",
+			ecu:      "00",
+			values:   map[string]float64{"coolant_temperature": 100},
+		},
+		{name: "Not available", response: "18 FE EE 00 FF FF FF FF FF FF FF FF", hasError: true},
+		{name: "Unsupported PGN", response: "18 EA FF F9 EE FE 00", hasError: true},
+		{name: "Headerless", response: "8C FF FF FF FF FF FF FF", hasError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			telemetries, err := parser.DecodeJ1939(tt.response)
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Expected error for %q, got %+v", tt.response, telemetries)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(telemetries) != len(tt.values) {
+				t.Fatalf("Expected %d parameters, got %+v", len(tt.values), telemetries)
+			}
+			for _, telemetry := range telemetries {
+				expected, ok := tt.values[telemetry.Metric]
+				if !ok || math.Abs(telemetry.Value-expected) > 0.001 {
+					t.Errorf("Unexpected value %s = %v", telemetry.Metric, telemetry.Value)
+				}
+				if telemetry.PID != tt.pid || telemetry.ECU != tt.ecu || telemetry.Raw != tt.response {
+					t.Errorf("Unexpected telemetry: %+v", telemetry)
+				}
+			}
+		})
+	}
+}
+
+func TestDecodeJ1939MultipleLines(t *testing.T) {
+	telemetries, err := New().DecodeJ1939("18 FE EE 00 8C FF FF FF FF FF FF FF\r18 FE EE 01 FF FF FF FF FF FF FF FF\r18 FE EE 02 6E FF FF FF FF FF FF FF")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(telemetries) != 2 || telemetries[0].ECU != "00" || telemetries[1].ECU != "02" || telemetries[1].Value != 70 {
+		t.Errorf("Unexpected telemetries: %+v", telemetries)
+	}
+}
+
+func TestJ1939PGNs(t *testing.T) {
+	pgns := J1939PGNs()
+	for i := 1; i < len(pgns); i++ {
+		if pgns[i] <= pgns[i-1] {
+			t.Fatalf("Expected sorted unique PGNs, got %X", pgns)
+		}
+	}
+	if request := J1939Request(0xFEEE); request != "00FEEE" {
+		t.Errorf("Expected 00FEEE, got %s", request)
+	}
+}
@@ -0,0 +1,115 @@
+package decoder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Заголовок ответа ISO 9141-2 и J1850 VPW: приоритет/тип 48, получатель 6B, отправитель
+const (
+	iso9141ResponsePriority = 0x48
+	iso9141ResponseTarget   = 0x6B
+)
+
+// KLineFrame представляет строку ответа протоколов без CAN (ISO 9141-2, ISO 14230 KWP2000,
+// J1850 VPW) с включенными заголовками, например "48 6B 10 41 0C 1A F0 1A" или "84 F1 11 41 0C 1A F0 DD"
+type KLineFrame struct {
+	Header string // Байты заголовка: "486B10" или "84F111"
+	Source string // Адрес ЭБУ-отправителя ("10")
+	Data   []byte // Данные без заголовка и контрольной суммы, начиная с байта сервиса
+}
+
+// isKLineLine проверяет, начинается ли строка с заголовка ISO 9141-2 или KWP2000.
+// Ответы без заголовков начинаются с 4x или 7F; из них с заголовком совпадает только ответ
+// сервиса 08 с TID 6B, который стандартом не определен
+func isKLineLine(line string) bool {
+	parts := strings.Fields(line)
+	if len(parts) < 2 || len(parts[0]) != 2 || len(parts[1]) != 2 {
+		return false
+	}
+	first, err := strconv.ParseUint(parts[0], 16, 8)
+	if err != nil {
+		return false
+	}
+	return first&0x80 != 0 || strings.EqualFold(parts[0]+parts[1], "486B")
+}
+
+// ParseKLineFrame разбирает строку ответа с заголовком K-line, проверяет длину кадра
+// KWP2000 и контрольную сумму (сумма байтов по модулю 256; для J1850 VPW - CRC-8),
+// отбрасывая заголовок и контрольную сумму. Поврежденные кадры возвращают ошибку
+func ParseKLineFrame(line string) (*KLineFrame, error) {
+	parts := strings.Fields(strings.TrimSpace(line))
+	frame := make([]byte, len(parts))
+	for i, part := range parts {
+		val, err := strconv.ParseUint(part, 16, 8)
+		if err != nil || len(part) != 2 {
+			return nil, fmt.Errorf("invalid hex data %s in %q", part, line)
+		}
+		frame[i] = byte(val)
+	}
+	// Минимальный кадр: три байта заголовка, байт сервиса и контрольная сумма
+	if len(frame) < 5 {
+		return nil, fmt.Errorf("K-line frame too short: %q", line)
+	}
+
+	headerLength := 3
+	crcAllowed := false
+	switch {
+	case frame[0] == iso9141ResponsePriority && frame[1] == iso9141ResponseTarget:
+		// ISO 9141-2 и J1850 VPW используют одинаковый заголовок, различаются контрольной суммой
+		crcAllowed = true
+	case frame[0]&0x80 != 0:
+		// KWP2000: младшие 6 бит байта формата - длина данных, 0 - длина в отдельном байте
+		length := int(frame[0] & 0x3F)
+		if length == 0 {
+			length = int(frame[3])
+			headerLength = 4
+		}
+		if len(frame) != headerLength+length+1 {
+			return nil, fmt.Errorf("KWP frame length mismatch: header declares %d data bytes, got %d in %q",
+				length, len(frame)-headerLength-1, line)
+		}
+	default:
+		return nil, fmt.Errorf("no K-line header in %q", line)
+	}
+
+	body, checksum := frame[:len(frame)-1], frame[len(frame)-1]
+	if expected := kLineChecksum(body); checksum != expected && !(crcAllowed && checksum == j1850CRC(body)) {
+		return nil, fmt.Errorf("checksum mismatch in %q: expected %02X, got %02X", line, expected, checksum)
+	}
+	if headerLength >= len(body) {
+		return nil, fmt.Errorf("K-line frame without data: %q", line)
+	}
+
+	return &KLineFrame{
+		Header: fmt.Sprintf("%X", body[:headerLength]),
+		Source: fmt.Sprintf("%02X", body[2]),
+		Data:   body[headerLength:],
+	}, nil
+}
+
+// kLineChecksum вычисляет контрольную сумму ISO 9141-2 и KWP2000: сумма байтов по модулю 256
+func kLineChecksum(data []byte) byte {
+	var sum byte
+	for _, b := range data {
+		sum += b
+	}
+	return sum
+}
+
+// j1850CRC вычисляет CRC-8 SAE J1850 (полином 1D, начальное значение FF, инверсия результата)
+func j1850CRC(data []byte) byte {
+	crc := byte(0xFF)
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&0x80 != 0 {
+				crc = crc<<1 ^ 0x1D
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return ^crc
+}
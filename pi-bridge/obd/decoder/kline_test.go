@@ -0,0 +1,73 @@
+package decoder
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseKLineFrame(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		header string
+		source string
+		data   []byte
+	}{
+		{"ISO 9141-2", "48 6B 10 41 0C 1A F0 1A", "486B10", "10", []byte{0x41, 0x0C, 0x1A, 0xF0}},
+		{"J1850 VPW with CRC", "48 6B 10 41 0C 1A F0 5A", "486B10", "10", []byte{0x41, 0x0C, 0x1A, 0xF0}},
+		{"KWP2000 length in format byte", "84 F1 11 41 0C 1A F0 DD", "84F111", "11", []byte{0x41, 0x0C, 0x1A, 0xF0}},
+		{"KWP2000 separate length byte", "80 F1 11 04 41 0C 1A F0 DD", "80F11104", "11", []byte{0x41, 0x0C, 0x1A, 0xF0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := ParseKLineFrame(tt.line)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if frame.Header != tt.header || frame.Source != tt.source || !reflect.DeepEqual(frame.Data, tt.data) {
+				t.Errorf("Unexpected frame: %+v", frame)
+			}
+		})
+	}
+}
+
+func TestParseKLineFrameRejects(t *testing.T) {
+	lines := []string{
+		"48 6B 10 41 0C 1A F0 1B", // Неверная контрольная сумма
+		"84 F1 11 41 0C 1A F0 DE", // Неверная контрольная сумма KWP
+		"85 F1 11 41 0C 1A F0 DD", // Длина не совпадает с заголовком
+		"83 F1 11 41 0C 1A F0 DD", // Длина не совпадает с заголовком
+		"48 6B 10 1A",             // Слишком короткий кадр
+		"41 0C 1A F0",             // Ответ без заголовков
+		"48 6B 10 41 0C 1A F0 ZZ", // Не hex
+	}
+	for _, line := range lines {
+		if frame, err := ParseKLineFrame(line); err == nil {
+			t.Errorf("Expected %q to be rejected, got %+v", line, frame)
+		}
+	}
+}
+
+func TestDecodeKLineResponse(t *testing.T) {
+	parser := New()
+
+	telemetry, err := parser.Decode("48 6B 10 41 0C 1A F0 1A")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if telemetry.Metric != "engine_rpm" || telemetry.Value != 1724 || telemetry.ECU != "10" {
+		t.Errorf("Unexpected telemetry: %+v", telemetry)
+	}
+
+	// Поврежденный кадр не должен декодироваться как PID 6B сервиса 08
+	if telemetry, err := parser.Decode("48 6B 10 41 0C 1A F1 1A"); err == nil {
+		t.Errorf("Expected corrupted frame to be rejected, got %+v", telemetry)
+	}
+
+	// Ответы двух ЭБУ, один поврежден
+	telemetries, err := parser.DecodeAll("48 6B 10 41 0D 3C 4D\r48 6B 18 41 0D 3C 00")
+	if err != nil || len(telemetries) != 1 || telemetries[0].ECU != "10" || telemetries[0].Value != 60 {
+		t.Errorf("Unexpected telemetries: %+v (%v)", telemetries, err)
+	}
+}
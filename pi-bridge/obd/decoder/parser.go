@@ -0,0 +1,283 @@
+// Package decoder разбирает ответы ELM327 и декодирует PID OBD-II. Пакет не использует
+// каналы, горутины и журналы, поэтому может встраиваться в сторонние инструменты:
+//
+//	parser := decoder.New()
+//	telemetry, err := parser.Decode("7E8 04 41 0C 1A F0")
+package decoder
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+// Telemetry представляет декодированное значение PID (используем общий тип)
+type Telemetry = common.Telemetry
+
+// Байты ответа на сервисы текущих данных и стоп-кадра
+const (
+	currentDataService = 0x41
+	freezeFrameService = 0x42
+)
+
+// Parser декодирует ответы ELM327 по собственной таблице PID. Таблица заполняется
+// встроенными декодерами и может дополняться через Register, в том числе во время
+// разбора ответов из других горутин
+type Parser struct {
+	mu       sync.RWMutex
+	decoders map[string]PIDDecoder
+	names    map[string]string
+	units    map[string]string
+	now      func() time.Time
+}
+
+// New создает парсер со встроенными декодерами PID
+func New() *Parser {
+	p := &Parser{
+		decoders: make(map[string]PIDDecoder, len(builtinDecoders)),
+		names:    make(map[string]string, len(builtinNames)),
+		units:    make(map[string]string, len(builtinUnits)),
+		now:      time.Now,
+	}
+	for pid, decoder := range builtinDecoders {
+		p.decoders[pid] = decoder
+	}
+	for pid, name := range builtinNames {
+		p.names[pid] = name
+	}
+	for pid, unit := range builtinUnits {
+		p.units[pid] = unit
+	}
+	return p
+}
+
+// Register добавляет или заменяет декодер PID сервиса 01
+func (p *Parser) Register(pid, name, unit string, decoder PIDDecoder) {
+	pid = strings.ToUpper(pid)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.decoders[pid] = decoder
+	p.names[pid] = name
+	p.units[pid] = unit
+}
+
+// Unregister удаляет декодер PID
+func (p *Parser) Unregister(pid string) {
+	pid = strings.ToUpper(pid)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.decoders, pid)
+	delete(p.names, pid)
+	delete(p.units, pid)
+}
+
+// Supports проверяет, есть ли декодер для PID
+func (p *Parser) Supports(pid string) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	_, exists := p.decoders[strings.ToUpper(pid)]
+	return exists
+}
+
+// PIDs возвращает отсортированный список PID с декодерами
+func (p *Parser) PIDs() []string {
+	p.mu.RLock()
+	pids := make([]string, 0, len(p.decoders))
+	for pid := range p.decoders {
+		pids = append(pids, pid)
+	}
+	p.mu.RUnlock()
+	sort.Strings(pids)
+	return pids
+}
+
+// MetricName возвращает название метрики для PID
+func (p *Parser) MetricName(pid string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if name, exists := p.names[pid]; exists {
+		return name
+	}
+	return "unknown_" + pid
+}
+
+// MetricUnit возвращает единицу измерения для PID
+func (p *Parser) MetricUnit(pid string) string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if unit, exists := p.units[pid]; exists {
+		return unit
+	}
+	return "unknown"
+}
+
+// Decode разбирает ответ ELM327 и возвращает первое декодированное значение
+func (p *Parser) Decode(line string) (Telemetry, error) {
+	telemetries, err := p.DecodeAll(line)
+	if err != nil {
+		return Telemetry{}, err
+	}
+	return *telemetries[0], nil
+}
+
+// DecodeAll разбирает ответ ELM327, который может содержать строки от нескольких ЭБУ.
+// Поддерживаются строки без заголовков ("41 0C 1A F0") и с заголовками CAN при ATH1
+// ("7E8 04 41 0C 1A F0") или K-line ("48 6B 10 41 0C 1A F0 1A", кадры с неверной контрольной
+// суммой отбрасываются); для последних в телеметрию записывается адрес ЭБУ.
+// Многокадровые ответы предварительно собираются ReassembleResponse
+func (p *Parser) DecodeAll(response string) ([]*Telemetry, error) {
+	messages, err := ReassembleResponse(response)
+	if err != nil {
+		return nil, err
+	}
+
+	var telemetries []*Telemetry
+	var firstErr error
+	for _, message := range messages {
+		telemetry, err := p.decodePayload(message.Payload, message.ECU, message.Raw)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		telemetries = append(telemetries, telemetry)
+	}
+
+	if len(telemetries) == 0 {
+		return nil, firstErr
+	}
+	return telemetries, nil
+}
+
+// singleFramePayload извлекает данные одиночного кадра ISO-TP, отбрасывая байт длины и заполнение
+func singleFramePayload(frame *CANFrame) ([]byte, error) {
+	if len(frame.Data) < 2 {
+		return nil, fmt.Errorf("frame from %s too short", frame.Source)
+	}
+
+	pci := frame.Data[0]
+	if pci>>4 != 0 {
+		return nil, fmt.Errorf("multi-frame response from %s is not supported", frame.Source)
+	}
+
+	length := int(pci & 0x0F)
+	if length == 0 || length > len(frame.Data)-1 {
+		return nil, fmt.Errorf("invalid frame length %d from %s", length, frame.Source)
+	}
+	return frame.Data[1 : 1+length], nil
+}
+
+// parseHeaderlessLine разбирает строку без заголовков, формат "41 0C 1A F0"
+func parseHeaderlessLine(line string) ([]byte, error) {
+	// Проверяем формат ответа ELM327 (должен начинаться с 4x или 7F - отрицательный ответ)
+	negative := strings.HasPrefix(line, "7F")
+	if len(line) < 5 || (!strings.HasPrefix(line, "4") && !negative) {
+		return nil, fmt.Errorf("invalid response format: %s", line)
+	}
+
+	parts := strings.Fields(line)
+	// Ответ CAN на запрос DTC без кодов состоит из двух байтов: "43 00" (постоянные - "4A 00")
+	if len(parts) < 3 && !(len(parts) == 2 && (parts[0] == "43" || parts[0] == "4A")) {
+		return nil, fmt.Errorf("response too short: %s", line)
+	}
+
+	// Проверяем эхо (должен быть "4x" где x - сервис)
+	if len(parts[0]) != 2 || (parts[0][0] != '4' && !negative) {
+		return nil, fmt.Errorf("invalid echo format: %s", parts[0])
+	}
+
+	// Проверяем PID
+	if len(parts[1]) != 2 {
+		return nil, fmt.Errorf("invalid PID format: %s", parts[1])
+	}
+
+	// Конвертируем данные из hex в байты
+	payload := make([]byte, len(parts))
+	for i, part := range parts {
+		val, err := strconv.ParseUint(part, 16, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex data %s: %v", part, err)
+		}
+		payload[i] = byte(val)
+	}
+	return payload, nil
+}
+
+// decodePayload декодирует данные ответа: эхо сервиса, PID и байты значения
+func (p *Parser) decodePayload(payload []byte, ecu, raw string) (*Telemetry, error) {
+	if len(payload) < 3 {
+		return nil, fmt.Errorf("response too short: %s", raw)
+	}
+	if payload[0] == NegativeResponseService {
+		return nil, fmt.Errorf("negative response to service %02X: %s", payload[1], NRCDescription(payload[2]))
+	}
+	if payload[0]&0xF0 != 0x40 {
+		return nil, fmt.Errorf("invalid echo format: %02X", payload[0])
+	}
+
+	pid := fmt.Sprintf("%02X", payload[1])
+	data := payload[2:]
+
+	// Ответ на сервис 02 содержит номер стоп-кадра перед данными PID
+	freezeFrame := payload[0] == freezeFrameService
+	if freezeFrame {
+		if len(data) < 2 {
+			return nil, fmt.Errorf("freeze frame response too short: %s", raw)
+		}
+		data = data[1:]
+	}
+
+	// Декодируем данные
+	p.mu.RLock()
+	decoder, exists := p.decoders[pid]
+	p.mu.RUnlock()
+	if !exists {
+		// PID сервисов 01 и 02 без декодера (например, PID производителя) передаются
+		// сырыми байтами, чтобы потребители могли декодировать их сами
+		if payload[0] == currentDataService || freezeFrame {
+			return p.rawTelemetry(pid, data, ecu, raw, freezeFrame), nil
+		}
+		return nil, fmt.Errorf("unsupported PID: %s", pid)
+	}
+
+	value, err := decoder(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode PID %s: %v", pid, err)
+	}
+
+	return &Telemetry{
+		PID:         pid,
+		Metric:      p.MetricName(pid),
+		Value:       value,
+		Unit:        p.MetricUnit(pid),
+		Timestamp:   p.now().Unix(),
+		Raw:         raw,
+		ECU:         ecu,
+		FreezeFrame: freezeFrame,
+	}, nil
+}
+
+// rawTelemetry создает телеметрию неподдерживаемого PID с байтами данных в hex вместо значения
+func (p *Parser) rawTelemetry(pid string, data []byte, ecu, raw string, freezeFrame bool) *Telemetry {
+	hex := make([]string, len(data))
+	for i, b := range data {
+		hex[i] = fmt.Sprintf("%02X", b)
+	}
+
+	return &Telemetry{
+		PID:         pid,
+		Metric:      "unknown_" + pid,
+		Unit:        "raw",
+		Timestamp:   p.now().Unix(),
+		Raw:         raw,
+		ECU:         ecu,
+		FreezeFrame: freezeFrame,
+		Data:        strings.Join(hex, " "),
+	}
+}
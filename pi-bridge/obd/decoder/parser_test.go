@@ -0,0 +1,96 @@
+package decoder
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParserDecode(t *testing.T) {
+	parser := New()
+	parser.now = func() time.Time { return time.Unix(1700000000, 0) }
+
+	tests := []struct {
+		name     string
+		response string
+		metric   string
+		value    float64
+		ecu      string
+		data     string
+		hasError bool
+	}{
+		{name: "Headerless RPM", response: "41 0C 1A F0", metric: "engine_rpm", value: 1724},
+		{name: "CAN header", response: "7E8 03 41 0D 3C", metric: "vehicle_speed", value: 60, ecu: "7E8"},
+		{name: "Freeze frame", response: "42 05 00 5A", metric: "coolant_temperature", value: 50},
+		{name: "Unsupported PID passthrough", response: "41 FF 12 34", metric: "unknown_FF", data: "12 34"},
+		{name: "Negative response", response: "7F 01 12", hasError: true},
+		{name: "Garbage", response: "SEARCHING...", hasError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			telemetry, err := parser.Decode(tt.response)
+			if tt.hasError {
+				if err == nil {
+					t.Errorf("Expected error for %q, got %+v", tt.response, telemetry)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if telemetry.Metric != tt.metric || telemetry.Value != tt.value || telemetry.ECU != tt.ecu || telemetry.Data != tt.data {
+				t.Errorf("Unexpected telemetry: %+v", telemetry)
+			}
+			if telemetry.Timestamp != 1700000000 || telemetry.Raw == "" {
+				t.Errorf("Expected timestamp and raw line, got %+v", telemetry)
+			}
+		})
+	}
+}
+
+func TestParserDecodeAllMultipleECUs(t *testing.T) {
+	telemetries, err := New().DecodeAll("7E8 03 41 0D 3C\r7E9 03 41 0D 3D")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(telemetries) != 2 || telemetries[0].ECU != "7E8" || telemetries[1].Value != 61 {
+		t.Errorf("Unexpected telemetries: %+v", telemetries)
+	}
+}
+
+func TestParserRegister(t *testing.T) {
+	custom := New()
+	custom.Register("5c", "oil_temperature", "°C", func(data []byte) (float64, error) {
+		return float64(data[0]) - 40, nil
+	})
+
+	if !custom.Supports("5C") || custom.MetricName("5C") != "oil_temperature" || custom.MetricUnit("5C") != "°C" {
+		t.Fatalf("Expected PID 5C to be registered")
+	}
+	telemetry, err := custom.Decode("41 5C 82")
+	if err != nil || telemetry.Value != 90 {
+		t.Errorf("Unexpected result: %+v, %v", telemetry, err)
+	}
+
+	// Таблицы PID разных парсеров независимы
+	if New().Supports("5C") {
+		t.Error("Expected registration to be local to the parser")
+	}
+
+	custom.Unregister("0C")
+	if custom.Supports("0C") || !New().Supports("0C") {
+		t.Error("Expected Unregister to affect only its parser")
+	}
+}
+
+func TestParserPIDs(t *testing.T) {
+	pids := New().PIDs()
+	if len(pids) != len(builtinDecoders) {
+		t.Fatalf("Expected %d PIDs, got %d", len(builtinDecoders), len(pids))
+	}
+	for i := 1; i < len(pids); i++ {
+		if pids[i-1] >= pids[i] {
+			t.Errorf("PIDs are not sorted at %s", pids[i])
+		}
+	}
+}
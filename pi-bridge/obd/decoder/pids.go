@@ -0,0 +1,365 @@
+package decoder
+
+import "fmt"
+
+// PIDDecoder представляет функцию для декодирования конкретного PID
+type PIDDecoder func(data []byte) (float64, error)
+
+// builtinDecoders содержит встроенные декодеры PID сервиса 01
+var builtinDecoders = map[string]PIDDecoder{
+	// Двигатель и производительность
+	"0C": decodeRPM,          // Обороты двигателя (Engine RPM)
+	"0D": decodeVehicleSpeed, // Скорость автомобиля (Vehicle Speed)
+	"05": decodeCoolantTemp,  // Температура охлаждающей жидкости (Engine Coolant Temperature)
+	"0F": decodeIntakeTemp,   // Температура всасываемого воздуха (Intake Air Temperature)
+	"11": decodeThrottlePos,  // Положение дроссельной заслонки (Throttle Position)
+	"04": decodeEngineLoad,   // Нагрузка двигателя (Calculated Engine Load)
+
+	// Топливо и эффективность
+	"2F": decodeFuelLevel,          // Уровень топлива (Fuel Level Input)
+	"0A": decodeFuelPressure,       // Давление топлива (Fuel Pressure)
+	"06": decodeShortTermFuelTrim1, // Короткий срок корректировки топлива Bank 1
+	"07": decodeLongTermFuelTrim1,  // Длинный срок корректировки топлива Bank 1
+	"10": decodeMAF,                // Массовый расход воздуха (MAF Air Flow Rate)
+	"5E": decodeFuelRate,           // Расход топлива (Engine Fuel Rate)
+
+	// Давление и температура
+	"0B": decodeIntakePressure,     // Давление во впускном коллекторе (Intake Manifold Pressure)
+	"33": decodeBarometricPressure, // Барометрическое давление (Barometric Pressure)
+
+	// Диагностика
+	"01": decodeMonitorStatus,   // Статус мониторинга DTC
+	"21": decodeDistanceWithMIL, // Расстояние с включенным MIL
+	"A6": decodeOdometer,        // Одометр (Odometer)
+
+	// Дроссельная заслонка и педаль акселератора
+	"45": decodeRelativeThrottlePos,       // Относительное положение дросселя (Relative Throttle Position)
+	"47": decodeAbsoluteThrottlePosB,      // Абсолютное положение дросселя B (Absolute Throttle Position B)
+	"48": decodeAbsoluteThrottlePosC,      // Абсолютное положение дросселя C (Absolute Throttle Position C)
+	"49": decodeAcceleratorPedalPosD,      // Положение педали акселератора D (Accelerator Pedal Position D)
+	"4A": decodeAcceleratorPedalPosE,      // Положение педали акселератора E (Accelerator Pedal Position E)
+	"4B": decodeAcceleratorPedalPosF,      // Положение педали акселератора F (Accelerator Pedal Position F)
+	"4C": decodeCommandedThrottleActuator, // Заданное положение привода дросселя (Commanded Throttle Actuator)
+
+	// Гибридные и электрические автомобили
+	"5B": decodeHybridBatteryRemaining, // Остаточный заряд тяговой батареи (Hybrid Battery Pack Remaining Life)
+	"51": decodeFuelType,               // Тип топлива, в том числе гибрид и электро (Fuel Type)
+	"9A": decodeHybridBatteryVoltage,   // Напряжение тяговой батареи (Hybrid/EV Vehicle System Data, Battery, Voltage)
+}
+
+// builtinNames содержит человеко-читаемые названия метрик встроенных PID
+var builtinNames = map[string]string{
+	"0C": "engine_rpm",
+	"0D": "vehicle_speed",
+	"05": "coolant_temperature",
+	"0F": "intake_air_temperature",
+	"11": "throttle_position",
+	"04": "engine_load",
+	"2F": "fuel_level",
+	"0A": "fuel_pressure",
+	"06": "short_term_fuel_trim_1",
+	"07": "long_term_fuel_trim_1",
+	"10": "maf_rate",
+	"5E": "fuel_rate",
+	"0B": "intake_manifold_pressure",
+	"33": "barometric_pressure",
+	"01": "monitor_status",
+	"21": "distance_with_mil",
+	"A6": "odometer",
+	"45": "relative_throttle_position",
+	"47": "absolute_throttle_position_b",
+	"48": "absolute_throttle_position_c",
+	"49": "accelerator_pedal_position_d",
+	"4A": "accelerator_pedal_position_e",
+	"4B": "accelerator_pedal_position_f",
+	"4C": "commanded_throttle_actuator",
+	"5B": "hybrid_battery_soc",
+	"51": "fuel_type",
+	"9A": "hybrid_battery_voltage",
+}
+
+// builtinUnits содержит единицы измерения встроенных PID
+var builtinUnits = map[string]string{
+	"0C": "rpm",
+	"0D": "km/h",
+	"05": "°C",
+	"0F": "°C",
+	"11": "%",
+	"04": "%",
+	"2F": "%",
+	"0A": "kPa",
+	"06": "%",
+	"07": "%",
+	"10": "g/s",
+	"5E": "L/h",
+	"0B": "kPa",
+	"33": "kPa",
+	"01": "status",
+	"21": "km",
+	"A6": "km",
+	"45": "%",
+	"47": "%",
+	"48": "%",
+	"49": "%",
+	"4A": "%",
+	"4B": "%",
+	"4C": "%",
+	"5B": "%",
+	"51": "code",
+	"9A": "V",
+}
+
+// Декодеры для конкретных PID
+
+// decodeRPM декодирует обороты двигателя (PID 0C)
+// Формула: ((A * 256) + B) / 4
+func decodeRPM(data []byte) (float64, error) {
+	if len(data) != 2 {
+		return 0, fmt.Errorf("PID 0C: ожидалось 2 байта, получено %d", len(data))
+	}
+	A := float64(data[0])
+	B := float64(data[1])
+	return ((A * 256) + B) / 4, nil
+}
+
+// decodeVehicleSpeed декодирует скорость автомобиля (PID 0D)
+// Формула: A
+func decodeVehicleSpeed(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 0D: ожидался 1 байт, получено %d", len(data))
+	}
+	return float64(data[0]), nil
+}
+
+// decodeCoolantTemp декодирует температуру охлаждающей жидкости (PID 05)
+// Формула: A - 40
+func decodeCoolantTemp(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 05: ожидался 1 байт, получено %d", len(data))
+	}
+	return float64(data[0]) - 40, nil
+}
+
+// decodeIntakeTemp декодирует температуру всасываемого воздуха (PID 0F)
+// Формула: A - 40
+func decodeIntakeTemp(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 0F: ожидался 1 байт, получено %d", len(data))
+	}
+	return float64(data[0]) - 40, nil
+}
+
+// decodeThrottlePos декодирует положение дроссельной заслонки (PID 11)
+// Формула: (A * 100) / 255
+func decodeThrottlePos(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 11: ожидался 1 байт, получено %d", len(data))
+	}
+	return (float64(data[0]) * 100) / 255, nil
+}
+
+// decodeEngineLoad декодирует нагрузку двигателя (PID 04)
+// Формула: (A * 100) / 255
+func decodeEngineLoad(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 04: ожидался 1 байт, получено %d", len(data))
+	}
+	return (float64(data[0]) * 100) / 255, nil
+}
+
+// decodeFuelLevel декодирует уровень топлива (PID 2F)
+// Формула: (A * 100) / 255
+func decodeFuelLevel(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 2F: ожидался 1 байт, получено %d", len(data))
+	}
+	return (float64(data[0]) * 100) / 255, nil
+}
+
+// decodeFuelPressure декодирует давление топлива (PID 0A)
+// Формула: A * 3
+func decodeFuelPressure(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 0A: ожидался 1 байт, получено %d", len(data))
+	}
+	return float64(data[0]) * 3, nil
+}
+
+// decodeShortTermFuelTrim1 декодирует короткий срок корректировки топлива Bank 1 (PID 06)
+// Формула: (A - 128) * 100 / 128
+func decodeShortTermFuelTrim1(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 06: ожидался 1 байт, получено %d", len(data))
+	}
+	return (float64(data[0]) - 128) * 100 / 128, nil
+}
+
+// decodeLongTermFuelTrim1 декодирует длинный срок корректировки топлива Bank 1 (PID 07)
+// Формула: (A - 128) * 100 / 128
+func decodeLongTermFuelTrim1(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 07: ожидался 1 байт, получено %d", len(data))
+	}
+	return (float64(data[0]) - 128) * 100 / 128, nil
+}
+
+// decodeIntakePressure декодирует давление во впускном коллекторе (PID 0B)
+// Формула: A
+func decodeIntakePressure(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 0B: ожидался 1 байт, получено %d", len(data))
+	}
+	return float64(data[0]), nil
+}
+
+// decodeBarometricPressure декодирует барометрическое давление (PID 33)
+// Формула: A
+func decodeBarometricPressure(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 33: ожидался 1 байт, получено %d", len(data))
+	}
+	return float64(data[0]), nil
+}
+
+// decodeMonitorStatus декодирует статус мониторинга (PID 01)
+// Это битовая карта, возвращаем как сырое значение
+func decodeMonitorStatus(data []byte) (float64, error) {
+	if len(data) != 4 {
+		return 0, fmt.Errorf("PID 01: ожидалось 4 байта, получено %d", len(data))
+	}
+	// Конвертируем 4 байта в одно число для простоты
+	return float64(data[0])*256*256*256 + float64(data[1])*256*256 + float64(data[2])*256 + float64(data[3]), nil
+}
+
+// decodeDistanceWithMIL декодирует расстояние с включенным MIL (PID 21)
+// Формула: (A * 256) + B
+func decodeDistanceWithMIL(data []byte) (float64, error) {
+	if len(data) != 2 {
+		return 0, fmt.Errorf("PID 21: ожидалось 2 байта, получено %d", len(data))
+	}
+	return float64(data[0])*256 + float64(data[1]), nil
+}
+
+// decodeOdometer декодирует показания одометра (PID A6)
+// Формула: ((A << 24) + (B << 16) + (C << 8) + D) / 10
+func decodeOdometer(data []byte) (float64, error) {
+	if len(data) != 4 {
+		return 0, fmt.Errorf("PID A6: ожидалось 4 байта, получено %d", len(data))
+	}
+	raw := uint32(data[0])<<24 | uint32(data[1])<<16 | uint32(data[2])<<8 | uint32(data[3])
+	return float64(raw) / 10, nil
+}
+
+// decodeRelativeThrottlePos декодирует относительное положение дроссельной заслонки (PID 45)
+// Формула: (A * 100) / 255
+func decodeRelativeThrottlePos(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 45: ожидался 1 байт, получено %d", len(data))
+	}
+	return (float64(data[0]) * 100) / 255, nil
+}
+
+// decodeAbsoluteThrottlePosB декодирует абсолютное положение дроссельной заслонки B (PID 47)
+// Формула: (A * 100) / 255
+func decodeAbsoluteThrottlePosB(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 47: ожидался 1 байт, получено %d", len(data))
+	}
+	return (float64(data[0]) * 100) / 255, nil
+}
+
+// decodeAbsoluteThrottlePosC декодирует абсолютное положение дроссельной заслонки C (PID 48)
+// Формула: (A * 100) / 255
+func decodeAbsoluteThrottlePosC(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 48: ожидался 1 байт, получено %d", len(data))
+	}
+	return (float64(data[0]) * 100) / 255, nil
+}
+
+// decodeAcceleratorPedalPosD декодирует положение педали акселератора D (PID 49)
+// Формула: (A * 100) / 255
+func decodeAcceleratorPedalPosD(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 49: ожидался 1 байт, получено %d", len(data))
+	}
+	return (float64(data[0]) * 100) / 255, nil
+}
+
+// decodeAcceleratorPedalPosE декодирует положение педали акселератора E (PID 4A)
+// Формула: (A * 100) / 255
+func decodeAcceleratorPedalPosE(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 4A: ожидался 1 байт, получено %d", len(data))
+	}
+	return (float64(data[0]) * 100) / 255, nil
+}
+
+// decodeAcceleratorPedalPosF декодирует положение педали акселератора F (PID 4B)
+// Формула: (A * 100) / 255
+func decodeAcceleratorPedalPosF(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 4B: ожидался 1 байт, получено %d", len(data))
+	}
+	return (float64(data[0]) * 100) / 255, nil
+}
+
+// decodeCommandedThrottleActuator декодирует заданное положение привода дроссельной заслонки (PID 4C)
+// Формула: (A * 100) / 255
+func decodeCommandedThrottleActuator(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 4C: ожидался 1 байт, получено %d", len(data))
+	}
+	return (float64(data[0]) * 100) / 255, nil
+}
+
+// decodeMAF декодирует массовый расход воздуха (PID 10)
+// Формула: ((A * 256) + B) / 100
+func decodeMAF(data []byte) (float64, error) {
+	if len(data) != 2 {
+		return 0, fmt.Errorf("PID 10: ожидалось 2 байта, получено %d", len(data))
+	}
+	return (float64(data[0])*256 + float64(data[1])) / 100, nil
+}
+
+// decodeFuelRate декодирует расход топлива двигателем (PID 5E)
+// Формула: ((A * 256) + B) / 20
+func decodeFuelRate(data []byte) (float64, error) {
+	if len(data) != 2 {
+		return 0, fmt.Errorf("PID 5E: ожидалось 2 байта, получено %d", len(data))
+	}
+	return (float64(data[0])*256 + float64(data[1])) / 20, nil
+}
+
+// decodeHybridBatteryRemaining декодирует остаточный заряд тяговой батареи гибрида/электромобиля (PID 5B)
+// Формула: (A * 100) / 255
+func decodeHybridBatteryRemaining(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 5B: ожидался 1 байт, получено %d", len(data))
+	}
+	return (float64(data[0]) * 100) / 255, nil
+}
+
+// decodeFuelType декодирует тип топлива (PID 51). Коды по SAE J1979: 0x08 - электро,
+// 0x0F-0x17 - гибриды (например, 0x11 - гибрид на бензине, 0x14 - гибрид электро)
+// Формула: A
+func decodeFuelType(data []byte) (float64, error) {
+	if len(data) != 1 {
+		return 0, fmt.Errorf("PID 51: ожидался 1 байт, получено %d", len(data))
+	}
+	return float64(data[0]), nil
+}
+
+// decodeHybridBatteryVoltage декодирует напряжение тяговой батареи гибрида/электромобиля (PID 9A).
+// Байт A - поддерживаемые значения (бит 1 - напряжение), B - режим заряда, C-D - напряжение,
+// E-F - ток. Декодер возвращает одно значение на PID, поэтому публикуется только напряжение
+// Формула: ((C * 256) + D) / 64
+func decodeHybridBatteryVoltage(data []byte) (float64, error) {
+	if len(data) != 6 {
+		return 0, fmt.Errorf("PID 9A: ожидалось 6 байт, получено %d", len(data))
+	}
+	if data[0]&0x02 == 0 {
+		return 0, fmt.Errorf("PID 9A: напряжение батареи не поддерживается")
+	}
+	return (float64(data[2])*256 + float64(data[3])) / 64, nil
+}
@@ -0,0 +1,205 @@
+package decoder
+
+import "testing"
+
+func TestDecodeRPM(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected float64
+		hasError bool
+	}{
+		{[]byte{0x1A, 0xF0}, 1724, false},   // ((26 * 256) + 240) / 4 = 1724
+		{[]byte{0x0F, 0xA0}, 1000, false},   // ((15 * 256) + 160) / 4 = 1000
+		{[]byte{0x00, 0x00}, 0, false},      // 0 RPM
+		{[]byte{0x1A}, 0, true},             // Wrong length
+		{[]byte{0x1A, 0xF0, 0x00}, 0, true}, // Wrong length
+	}
+
+	for _, tt := range tests {
+		result, err := decodeRPM(tt.data)
+
+		if tt.hasError {
+			if err == nil {
+				t.Errorf("Expected error for data %v", tt.data)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error for data %v: %v", tt.data, err)
+			continue
+		}
+
+		if result != tt.expected {
+			t.Errorf("Expected %.2f, got %.2f for data %v", tt.expected, result, tt.data)
+		}
+	}
+}
+
+func TestDecodeVehicleSpeed(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected float64
+		hasError bool
+	}{
+		{[]byte{0x00}, 0, false},
+		{[]byte{0x32}, 50, false},
+		{[]byte{0xFF}, 255, false},
+		{[]byte{0x32, 0x00}, 0, true}, // Wrong length
+		{[]byte{}, 0, true},           // Empty data
+	}
+
+	for _, tt := range tests {
+		result, err := decodeVehicleSpeed(tt.data)
+
+		if tt.hasError {
+			if err == nil {
+				t.Errorf("Expected error for data %v", tt.data)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error for data %v: %v", tt.data, err)
+			continue
+		}
+
+		if result != tt.expected {
+			t.Errorf("Expected %.2f, got %.2f for data %v", tt.expected, result, tt.data)
+		}
+	}
+}
+
+func TestDecodeCoolantTemp(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected float64
+		hasError bool
+	}{
+		{[]byte{0x5A}, 50, false},     // 0x5A - 40 = 50
+		{[]byte{0x00}, -40, false},    // 0x00 - 40 = -40
+		{[]byte{0xFF}, 215, false},    // 0xFF - 40 = 215
+		{[]byte{0x32, 0x00}, 0, true}, // Wrong length
+	}
+
+	for _, tt := range tests {
+		result, err := decodeCoolantTemp(tt.data)
+
+		if tt.hasError {
+			if err == nil {
+				t.Errorf("Expected error for data %v", tt.data)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error for data %v: %v", tt.data, err)
+			continue
+		}
+
+		if result != tt.expected {
+			t.Errorf("Expected %.2f, got %.2f for data %v", tt.expected, result, tt.data)
+		}
+	}
+}
+
+func TestDecodeThrottlePos(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected float64
+		hasError bool
+	}{
+		{[]byte{0x00}, 0, false},      // 0%
+		{[]byte{0xFF}, 100, false},    // 100%
+		{[]byte{0x80}, 50.196, false}, // (0x80 * 100) / 255 ≈ 50.196%
+		{[]byte{0x32, 0x00}, 0, true}, // Wrong length
+	}
+
+	for _, tt := range tests {
+		result, err := decodeThrottlePos(tt.data)
+
+		if tt.hasError {
+			if err == nil {
+				t.Errorf("Expected error for data %v", tt.data)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error for data %v: %v", tt.data, err)
+			continue
+		}
+
+		// Используем небольшую дельту для сравнения float
+		delta := 0.01
+		if result < tt.expected-delta || result > tt.expected+delta {
+			t.Errorf("Expected %.3f, got %.3f for data %v", tt.expected, result, tt.data)
+		}
+	}
+}
+
+func TestDecodeOdometer(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected float64
+		hasError bool
+	}{
+		{[]byte{0x00, 0x01, 0xE2, 0x40}, 12345.6, false},     // 123456 / 10
+		{[]byte{0x00, 0x00, 0x00, 0x00}, 0, false},           // Новый автомобиль
+		{[]byte{0xFF, 0xFF, 0xFF, 0xFF}, 429496729.5, false}, // Максимальное значение
+		{[]byte{0x00, 0x01, 0xE2}, 0, true},                  // Wrong length
+	}
+
+	for _, tt := range tests {
+		result, err := decodeOdometer(tt.data)
+
+		if tt.hasError {
+			if err == nil {
+				t.Errorf("Expected error for data %v", tt.data)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error for data %v: %v", tt.data, err)
+			continue
+		}
+
+		if result != tt.expected {
+			t.Errorf("Expected %.1f, got %.1f for data %v", tt.expected, result, tt.data)
+		}
+	}
+}
+
+func TestDecodeHybridBatteryVoltage(t *testing.T) {
+	tests := []struct {
+		data     []byte
+		expected float64
+		hasError bool
+	}{
+		{[]byte{0x07, 0x00, 0x5D, 0xC0, 0xFF, 0x9C}, 375, false},  // 0x5DC0 / 64 = 375 В
+		{[]byte{0x02, 0x40, 0x12, 0x20, 0x00, 0x00}, 72.5, false}, // 0x1220 / 64 = 72.5 В
+		{[]byte{0x05, 0x00, 0x5D, 0xC0, 0xFF, 0x9C}, 0, true},     // Напряжение не поддерживается
+		{[]byte{0x07, 0x00, 0x5D, 0xC0}, 0, true},                 // Wrong length
+	}
+
+	for _, tt := range tests {
+		result, err := decodeHybridBatteryVoltage(tt.data)
+
+		if tt.hasError {
+			if err == nil {
+				t.Errorf("Expected error for data %v", tt.data)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("Unexpected error for data %v: %v", tt.data, err)
+			continue
+		}
+
+		if result != tt.expected {
+			t.Errorf("Expected %.2f, got %.2f for data %v", tt.expected, result, tt.data)
+		}
+	}
+}
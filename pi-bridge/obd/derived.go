@@ -0,0 +1,288 @@
+package obd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FuelProperties описывает топливо: стехиометрическое соотношение воздух/топливо и плотность
+type FuelProperties struct {
+	AirFuelRatio float64 // Масса воздуха на единицу массы топлива
+	Density      float64 // Плотность, г/л
+}
+
+// FlowFromMAF пересчитывает массовый расход воздуха (г/с) в расход топлива (л/ч)
+func (f FuelProperties) FlowFromMAF(maf float64) float64 {
+	return maf * 3600 / (f.AirFuelRatio * f.Density)
+}
+
+// fuelTypes содержит свойства поддерживаемых видов топлива
+var fuelTypes = map[string]FuelProperties{
+	"gasoline": {AirFuelRatio: 14.7, Density: 745},
+	"diesel":   {AirFuelRatio: 14.5, Density: 832},
+	"e85":      {AirFuelRatio: 9.8, Density: 785},
+	"lpg":      {AirFuelRatio: 15.5, Density: 540},
+}
+
+// DerivedConfig представляет настройки вычисляемых метрик
+type DerivedConfig struct {
+	Enabled  bool   `yaml:"enabled"`   // Публиковать вычисляемые метрики
+	FuelType string `yaml:"fuel_type"` // gasoline, diesel, e85 или lpg
+}
+
+// DefaultDerivedConfig возвращает настройки по умолчанию
+func DefaultDerivedConfig() DerivedConfig {
+	return DerivedConfig{
+		Enabled:  true,
+		FuelType: "gasoline",
+	}
+}
+
+// tripIdleTimeout - перерыв в показаниях, после которого поездка считается завершенной:
+// при выключенном зажигании ЭБУ не отвечают
+const tripIdleTimeout = 2 * time.Minute
+
+// Derivation вычисляет синтетические метрики по очередному показанию.
+// Значения передаются в метрических единицах, до перевода в выбранную систему
+type Derivation interface {
+	Derive(telemetry *Telemetry) []*Telemetry
+}
+
+// TripDerivation - вычисление с итогами поездки: EndTrip вызывается при выключении
+// зажигания и возвращает итоговые метрики, сбрасывая накопленное состояние
+type TripDerivation interface {
+	Derivation
+	EndTrip() []*Telemetry
+}
+
+// DerivedMetrics - движок вычисляемых метрик: получает показания как наблюдатель парсера
+// и публикует результаты вычислений в канал телеметрии наравне с декодированными PID
+type DerivedMetrics struct {
+	mu            sync.Mutex
+	telemetryChan chan<- Telemetry
+	derivations   []Derivation
+	idleTimer     *time.Timer // Завершает поездку при отсутствии показаний
+	tripActive    bool
+	logger        *log.Logger
+}
+
+// NewDerivedMetrics создает движок вычисляемых метрик
+func NewDerivedMetrics(config DerivedConfig, telemetryChan chan<- Telemetry) (*DerivedMetrics, error) {
+	fuelType := strings.ToLower(strings.TrimSpace(config.FuelType))
+	fuel, ok := fuelTypes[fuelType]
+	if !ok {
+		return nil, fmt.Errorf("derived metrics: unknown fuel type %q (expected one of %s)", config.FuelType, strings.Join(fuelTypeNames(), ", "))
+	}
+
+	return &DerivedMetrics{
+		telemetryChan: telemetryChan,
+		derivations:   []Derivation{&fuelFlow{fuel: fuel}, newFuelEconomy(fuel), newTripDistance(), newAcceleration()},
+		logger:        log.New(os.Stdout, "[OBD-Derived] ", log.LstdFlags|log.Lshortfile),
+	}, nil
+}
+
+// Observe передает показание вычислениям и публикует полученные метрики
+func (d *DerivedMetrics) Observe(response string, telemetry *Telemetry) {
+	// Стоп-кадр и недостоверные значения не описывают текущее состояние автомобиля
+	if telemetry == nil || telemetry.FreezeFrame || telemetry.Implausible || telemetry.Data != "" {
+		return
+	}
+
+	d.mu.Lock()
+	// Первое показание после перерыва означает включение зажигания
+	if !d.tripActive {
+		d.tripActive = true
+		d.logger.Println("Trip started")
+	}
+	if d.idleTimer == nil {
+		d.idleTimer = time.AfterFunc(tripIdleTimeout, d.EndTrip)
+	} else {
+		d.idleTimer.Reset(tripIdleTimeout)
+	}
+
+	var derived []*Telemetry
+	for _, derivation := range d.derivations {
+		derived = append(derived, derivation.Derive(telemetry)...)
+	}
+	d.mu.Unlock()
+
+	for _, metric := range derived {
+		d.publish(metric)
+	}
+}
+
+// EndTrip завершает поездку и публикует ее итоги. Вызывается по таймеру,
+// когда показания не поступают дольше tripIdleTimeout
+func (d *DerivedMetrics) EndTrip() {
+	d.mu.Lock()
+	if !d.tripActive {
+		d.mu.Unlock()
+		return
+	}
+	d.tripActive = false
+
+	var derived []*Telemetry
+	for _, derivation := range d.derivations {
+		if trip, ok := derivation.(TripDerivation); ok {
+			derived = append(derived, trip.EndTrip()...)
+		}
+	}
+	d.mu.Unlock()
+
+	d.logger.Println("Trip ended")
+	for _, metric := range derived {
+		d.publish(metric)
+	}
+}
+
+// publish отправляет вычисленную метрику в канал телеметрии (неблокирующе)
+func (d *DerivedMetrics) publish(telemetry *Telemetry) {
+	telemetry.Derived = true
+	ConvertTelemetry(telemetry)
+
+	select {
+	case d.telemetryChan <- *telemetry:
+	default:
+		d.logger.Printf("Warning: telemetry channel is full, dropping: %s", telemetry.Metric)
+	}
+}
+
+// fuelFlow вычисляет мгновенный расход топлива по массовому расходу воздуха (PID 10),
+// что позволяет получить расход на автомобилях без PID 5E
+type fuelFlow struct {
+	fuel FuelProperties
+}
+
+// Derive пересчитывает MAF (г/с) в расход топлива (л/ч)
+func (f *fuelFlow) Derive(telemetry *Telemetry) []*Telemetry {
+	if telemetry.PID != "10" {
+		return nil
+	}
+
+	return []*Telemetry{{
+		Metric:    "fuel_flow",
+		Value:     f.fuel.FlowFromMAF(telemetry.Value),
+		Unit:      "L/h",
+		Timestamp: telemetry.Timestamp,
+		ECU:       telemetry.ECU,
+	}}
+}
+
+// fuelTypeNames возвращает отсортированный список поддерживаемых видов топлива
+func fuelTypeNames() []string {
+	names := make([]string, 0, len(fuelTypes))
+	for name := range fuelTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// tripDistance интегрирует скорость (PID 0D) по времени и публикует пройденное
+// за поездку расстояние при ее завершении - для автомобилей без PID одометра
+type tripDistance struct {
+	now func() time.Time
+
+	distance float64 // км
+	speed    float64 // Последняя скорость, км/ч
+	lastAt   time.Time
+	hasSpeed bool
+}
+
+// newTripDistance создает вычисление пробега за поездку
+func newTripDistance() *tripDistance {
+	return &tripDistance{now: time.Now}
+}
+
+// Derive добавляет пробег с предыдущего показания скорости
+func (t *tripDistance) Derive(telemetry *Telemetry) []*Telemetry {
+	if telemetry.PID != "0D" {
+		return nil
+	}
+
+	now := t.now()
+	// Через перерыв дольше tripIdleTimeout скорость неизвестна и не интегрируется
+	if elapsed := now.Sub(t.lastAt); t.hasSpeed && elapsed <= tripIdleTimeout {
+		t.distance += t.speed * elapsed.Hours()
+	}
+	t.speed, t.lastAt, t.hasSpeed = telemetry.Value, now, true
+	return nil
+}
+
+// EndTrip возвращает пройденное расстояние и начинает новую поездку
+func (t *tripDistance) EndTrip() []*Telemetry {
+	if !t.hasSpeed {
+		return nil
+	}
+
+	telemetry := &Telemetry{
+		Metric:    "distance_travelled",
+		Value:     t.distance,
+		Unit:      "km",
+		Timestamp: getCurrentTimestamp(),
+	}
+	t.distance, t.speed, t.hasSpeed = 0, 0, false
+	return []*Telemetry{telemetry}
+}
+
+// accelerationMaxInterval - максимальный интервал между показаниями скорости для расчета
+// ускорения: на большем интервале разность скоростей не описывает мгновенное ускорение
+const accelerationMaxInterval = 15 * time.Second
+
+// accelerationMinInterval - минимальный интервал: показания, полученные почти одновременно
+// (например, от нескольких ЭБУ), дают шум вместо ускорения
+const accelerationMinInterval = 50 * time.Millisecond
+
+// acceleration вычисляет продольное ускорение по разности последовательных показаний
+// скорости с учетом времени их получения - для анализа резких разгонов и торможений
+type acceleration struct {
+	now func() time.Time
+
+	speed    float64 // Предыдущая скорость, км/ч
+	at       time.Time
+	hasSpeed bool
+}
+
+// newAcceleration создает вычисление ускорения
+func newAcceleration() *acceleration {
+	return &acceleration{now: time.Now}
+}
+
+// Derive возвращает ускорение в м/с² между предыдущим и текущим показанием скорости
+func (a *acceleration) Derive(telemetry *Telemetry) []*Telemetry {
+	if telemetry.PID != "0D" {
+		return nil
+	}
+
+	now := a.now()
+	elapsed := now.Sub(a.at)
+	previous, hasPrevious := a.speed, a.hasSpeed
+	if hasPrevious && elapsed < accelerationMinInterval {
+		// Значение почти одновременного показания не заменяет опорное
+		return nil
+	}
+	a.speed, a.at, a.hasSpeed = telemetry.Value, now, true
+
+	if !hasPrevious || elapsed > accelerationMaxInterval {
+		return nil
+	}
+
+	return []*Telemetry{{
+		Metric:    "acceleration",
+		Value:     (telemetry.Value - previous) / 3.6 / elapsed.Seconds(),
+		Unit:      "m/s²",
+		Timestamp: telemetry.Timestamp,
+		ECU:       telemetry.ECU,
+	}}
+}
+
+// EndTrip сбрасывает опорное показание: после перерыва ускорение считается заново
+func (a *acceleration) EndTrip() []*Telemetry {
+	a.hasSpeed = false
+	return nil
+}
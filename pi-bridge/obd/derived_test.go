@@ -0,0 +1,175 @@
+package obd
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestDecodeMAFAndFuelRate(t *testing.T) {
+	maf, err := ParseResponse("41 10 01 F4")
+	if err != nil || maf.Metric != "maf_rate" || maf.Value != 5 || maf.Unit != "g/s" {
+		t.Errorf("Unexpected MAF telemetry: %+v (%v)", maf, err)
+	}
+
+	rate, err := ParseResponse("41 5E 00 50")
+	if err != nil || rate.Metric != "fuel_rate" || rate.Value != 4 || rate.Unit != "L/h" {
+		t.Errorf("Unexpected fuel rate telemetry: %+v (%v)", rate, err)
+	}
+}
+
+func TestDerivedFuelFlow(t *testing.T) {
+	telemetryChan := make(chan Telemetry, 10)
+	derived, err := NewDerivedMetrics(DefaultDerivedConfig(), telemetryChan)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Показания других PID не порождают расход
+	derived.Observe("41 0C 1A F0", &Telemetry{PID: "0C", Metric: "engine_rpm", Value: 1724})
+	// Недостоверный MAF не используется
+	derived.Observe("41 10 FF FF", &Telemetry{PID: "10", Metric: "maf_rate", Value: 655.35, Implausible: true})
+	if len(telemetryChan) != 0 {
+		t.Fatalf("Expected no derived metrics, got %d", len(telemetryChan))
+	}
+
+	derived.Observe("41 10 01 F4", &Telemetry{PID: "10", Metric: "maf_rate", Value: 5, Unit: "g/s"})
+	telemetry := <-telemetryChan
+
+	// 5 г/с * 3600 / (14.7 * 745 г/л) = 1.644 л/ч
+	if telemetry.Metric != "fuel_flow" || telemetry.Unit != "L/h" || !telemetry.Derived || math.Abs(telemetry.Value-1.6436) > 0.001 {
+		t.Errorf("Unexpected fuel flow: %+v", telemetry)
+	}
+}
+
+func TestDerivedUnknownFuelType(t *testing.T) {
+	if _, err := NewDerivedMetrics(DerivedConfig{Enabled: true, FuelType: "hydrogen"}, nil); err == nil {
+		t.Error("Expected error for unknown fuel type")
+	}
+}
+
+func TestFuelEconomy(t *testing.T) {
+	now := time.Unix(1759883336, 0)
+	economy := newFuelEconomy(fuelTypes["gasoline"])
+	economy.now = func() time.Time { return now }
+
+	step := func(pid string, value float64) []*Telemetry {
+		return economy.Derive(&Telemetry{PID: pid, Value: value})
+	}
+
+	// 100 км/ч и 8 л/ч - 8 л/100 км
+	step("0D", 100)
+	step("5E", 8)
+	for i := 0; i < 10; i++ {
+		now = now.Add(5 * time.Second)
+		step("5E", 8)
+		now = now.Add(time.Second)
+		result := step("0D", 100)
+		if i == 0 {
+			continue
+		}
+		if len(result) != 2 || result[0].Metric != "fuel_economy" || result[1].Metric != "trip_fuel_economy" {
+			t.Fatalf("Expected rolling and trip economy, got %+v", result)
+		}
+		for _, telemetry := range result {
+			if math.Abs(telemetry.Value-8) > 0.01 || telemetry.Unit != "L/100km" {
+				t.Errorf("Unexpected %s: %v %s", telemetry.Metric, telemetry.Value, telemetry.Unit)
+			}
+		}
+	}
+
+	// Расход по MAF игнорируется, пока автомобиль сообщает PID 5E
+	if result := step("10", 50); result != nil || economy.flow != 8 {
+		t.Errorf("Expected MAF to be ignored while PID 5E is available, flow %v", economy.flow)
+	}
+
+	// После завершения поездки средний расход считается заново
+	economy.EndTrip()
+	now = now.Add(10 * time.Minute)
+	if result := step("0D", 50); len(result) != 0 || economy.tripDistance != 0 {
+		t.Errorf("Expected trip to reset after it ended, got %+v", result)
+	}
+}
+
+func TestTripDistance(t *testing.T) {
+	telemetryChan := make(chan Telemetry, 10)
+	derived, err := NewDerivedMetrics(DefaultDerivedConfig(), telemetryChan)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	now := time.Unix(1759883336, 0)
+	for _, derivation := range derived.derivations {
+		if trip, ok := derivation.(*tripDistance); ok {
+			trip.now = func() time.Time { return now }
+		}
+	}
+
+	// 60 км/ч в течение 2 минут, затем 90 км/ч в течение минуты - 3.5 км
+	for _, speed := range []float64{60, 60, 90, 90} {
+		derived.Observe("", &Telemetry{PID: "0D", Metric: "vehicle_speed", Value: speed})
+		now = now.Add(time.Minute)
+	}
+	derived.idleTimer.Stop()
+	derived.EndTrip()
+
+	telemetry := <-telemetryChan
+	if telemetry.Metric != "distance_travelled" || !telemetry.Derived || math.Abs(telemetry.Value-3.5) > 0.001 {
+		t.Errorf("Unexpected trip distance: %+v", telemetry)
+	}
+
+	// Повторное завершение без новых показаний ничего не публикует
+	derived.EndTrip()
+	if len(telemetryChan) != 0 {
+		t.Errorf("Expected no metrics after trip ended, got %d", len(telemetryChan))
+	}
+}
+
+func TestFuelEconomyImperial(t *testing.T) {
+	SetUnitSystem(UnitsImperial)
+	defer SetUnitSystem(UnitsMetric)
+
+	if value, unit := ConvertValue(8, "L/100km"); unit != "mpg" || math.Abs(value-29.4) > 0.01 {
+		t.Errorf("Expected 29.4 mpg, got %v %s", value, unit)
+	}
+}
+
+func TestAcceleration(t *testing.T) {
+	now := time.Unix(1759883336, 0)
+	acceleration := newAcceleration()
+	acceleration.now = func() time.Time { return now }
+
+	step := func(after time.Duration, speed float64) []*Telemetry {
+		now = now.Add(after)
+		return acceleration.Derive(&Telemetry{PID: "0D", Value: speed})
+	}
+
+	if result := step(0, 36); result != nil {
+		t.Fatalf("Expected no acceleration from the first sample, got %+v", result)
+	}
+
+	tests := []struct {
+		name     string
+		after    time.Duration
+		speed    float64
+		expected []float64
+	}{
+		{"Acceleration", 2 * time.Second, 72, []float64{5}}, // 10 -> 20 м/с за 2 с
+		{"Harsh braking", 500 * time.Millisecond, 54, []float64{-10}},
+		{"Simultaneous sample ignored", 10 * time.Millisecond, 54, nil},
+		{"Gap too long", 20 * time.Second, 54, nil},
+		{"Steady speed", 5 * time.Second, 54, []float64{0}},
+	}
+
+	for _, tt := range tests {
+		result := step(tt.after, tt.speed)
+		if len(result) != len(tt.expected) {
+			t.Fatalf("%s: expected %d values, got %+v", tt.name, len(tt.expected), result)
+		}
+		for i, value := range tt.expected {
+			if math.Abs(result[i].Value-value) > 0.001 || result[i].Unit != "m/s²" {
+				t.Errorf("%s: expected %v m/s², got %v %s", tt.name, value, result[i].Value, result[i].Unit)
+			}
+		}
+	}
+}
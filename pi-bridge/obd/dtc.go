@@ -0,0 +1,116 @@
+package obd
+
+import (
+	"fmt"
+	"sort"
+
+	"elm327-bridge/obd/decoder"
+)
+
+// ReadDTCCommand - запрос сохраненных кодов неисправностей (сервис 03)
+const ReadDTCCommand = "03"
+
+// ReadPermanentDTCCommand - запрос постоянных кодов неисправностей (сервис 0A)
+const ReadPermanentDTCCommand = "0A"
+
+// dtcResponseService - байт ответа на сервис 03
+const dtcResponseService = 0x43
+
+// permanentDTCResponseService - байт ответа на сервис 0A
+const permanentDTCResponseService = 0x4A
+
+// dtcLetters - первая буква кода по двум старшим битам: двигатель и трансмиссия,
+// шасси, кузов, сеть
+var dtcLetters = [4]byte{'P', 'C', 'B', 'U'}
+
+// DTCReport представляет коды неисправностей, полученные от одного ЭБУ
+type DTCReport struct {
+	ECU        string            `json:"ecu,omitempty"`
+	Codes      []string          `json:"codes"`
+	Severity   string            `json:"severity,omitempty"`   // Наибольший уровень серьезности кодов ЭБУ
+	Severities map[string]string `json:"severities,omitempty"` // Уровень серьезности по коду
+}
+
+// DecodeDTC преобразует два байта кода в строку вида "P0133"
+func DecodeDTC(a, b byte) string {
+	return fmt.Sprintf("%c%d%X%02X", dtcLetters[a>>6], (a>>4)&0x03, a&0x0F, b)
+}
+
+// DetectDTCResponse распознает ответ на запрос DTC (сервис 03) и возвращает коды по ЭБУ.
+// Ответ CAN содержит количество кодов после байта сервиса ("43 02 01 33 02 34"),
+// ответ K-line и J1850 - по три кода в строке без количества ("43 01 33 00 00 00 00")
+func DetectDTCResponse(response string) ([]DTCReport, bool) {
+	return detectDTCReports(response, dtcResponseService)
+}
+
+// DetectPermanentDTCResponse распознает ответ на запрос постоянных DTC (сервис 0A).
+// Постоянные коды не стираются сервисом 04: ЭБУ удаляет их сам, когда монитор
+// подтвердит исправность. Формат ответа совпадает с сервисом 03
+func DetectPermanentDTCResponse(response string) ([]DTCReport, bool) {
+	return detectDTCReports(response, permanentDTCResponseService)
+}
+
+// detectDTCReports собирает коды по ЭБУ из ответа с заданным байтом сервиса
+func detectDTCReports(response string, service byte) ([]DTCReport, bool) {
+	messages, err := decoder.ReassembleResponse(response)
+	if err != nil || len(messages) == 0 {
+		return nil, false
+	}
+
+	byECU := make(map[string]*DTCReport)
+	var order []string
+	for _, message := range messages {
+		payload := message.Payload
+		if len(payload) == 0 || payload[0] != service {
+			return nil, false
+		}
+
+		report, exists := byECU[message.ECU]
+		if !exists {
+			report = &DTCReport{ECU: message.ECU, Codes: []string{}}
+			byECU[message.ECU] = report
+			order = append(order, message.ECU)
+		}
+		report.Codes = append(report.Codes, decodeDTCPayload(payload[1:])...)
+	}
+
+	reports := make([]DTCReport, 0, len(order))
+	for _, ecu := range order {
+		classifyDTCReport(byECU[ecu])
+		reports = append(reports, *byECU[ecu])
+	}
+	return reports, true
+}
+
+// decodeDTCPayload декодирует пары байтов кодов, пропуская заполнение "00 00"
+func decodeDTCPayload(data []byte) []string {
+	// В ответе CAN первый байт - количество кодов
+	if len(data)%2 == 1 && int(data[0])*2 == len(data)-1 {
+		data = data[1:]
+	}
+
+	var codes []string
+	for i := 0; i+1 < len(data); i += 2 {
+		if data[i] == 0 && data[i+1] == 0 {
+			continue
+		}
+		codes = append(codes, DecodeDTC(data[i], data[i+1]))
+	}
+	return codes
+}
+
+// dtcCodes возвращает отсортированный список уникальных кодов всех ЭБУ
+func dtcCodes(reports []DTCReport) []string {
+	seen := make(map[string]bool)
+	var codes []string
+	for _, report := range reports {
+		for _, code := range report.Codes {
+			if !seen[code] {
+				seen[code] = true
+				codes = append(codes, code)
+			}
+		}
+	}
+	sort.Strings(codes)
+	return codes
+}
@@ -0,0 +1,92 @@
+package obd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecodeDTC(t *testing.T) {
+	tests := []struct {
+		a, b     byte
+		expected string
+	}{
+		{0x01, 0x33, "P0133"},
+		{0x04, 0x20, "P0420"},
+		{0x41, 0x23, "C0123"},
+		{0x9A, 0xBC, "B1ABC"},
+		{0xC1, 0x00, "U0100"},
+	}
+
+	for _, tt := range tests {
+		if code := DecodeDTC(tt.a, tt.b); code != tt.expected {
+			t.Errorf("DecodeDTC(%02X, %02X) = %s, expected %s", tt.a, tt.b, code, tt.expected)
+		}
+	}
+}
+
+func TestDetectDTCResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		expected []DTCReport
+	}{
+		{"CAN headerless", "43 02 01 33 04 20", []DTCReport{{Codes: []string{"P0133", "P0420"}, Severity: DTCSeverityEmissions, Severities: map[string]string{"P0133": DTCSeverityEmissions, "P0420": DTCSeverityEmissions}}}},
+		{"CAN no codes", "43 00", []DTCReport{{Codes: []string{}}}},
+		{"K-line with padding", "43 01 33 00 00 00 00", []DTCReport{{Codes: []string{"P0133"}, Severity: DTCSeverityEmissions, Severities: map[string]string{"P0133": DTCSeverityEmissions}}}},
+		{"CAN with headers", "7E8 04 43 01 01 33\r7E9 02 43 00", []DTCReport{
+			{ECU: "7E8", Codes: []string{"P0133"}, Severity: DTCSeverityEmissions, Severities: map[string]string{"P0133": DTCSeverityEmissions}},
+			{ECU: "7E9", Codes: []string{}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reports, ok := DetectDTCResponse(tt.response)
+			if !ok {
+				t.Fatalf("Expected DTC response to be detected")
+			}
+			if !reflect.DeepEqual(reports, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, reports)
+			}
+		})
+	}
+
+	if _, ok := DetectDTCResponse("41 0C 1A F0"); ok {
+		t.Error("PID response must not be detected as DTC response")
+	}
+}
+
+func TestDetectPermanentDTCResponse(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		expected []DTCReport
+	}{
+		{"CAN headerless", "4A 01 04 20", []DTCReport{{Codes: []string{"P0420"}, Severity: DTCSeverityEmissions, Severities: map[string]string{"P0420": DTCSeverityEmissions}}}},
+		{"CAN no codes", "4A 00", []DTCReport{{Codes: []string{}}}},
+		{"K-line with padding", "4A 04 20 00 00 00 00", []DTCReport{{Codes: []string{"P0420"}, Severity: DTCSeverityEmissions, Severities: map[string]string{"P0420": DTCSeverityEmissions}}}},
+		{"CAN with headers", "7E8 04 4A 01 04 20\r7E9 02 4A 00", []DTCReport{
+			{ECU: "7E8", Codes: []string{"P0420"}, Severity: DTCSeverityEmissions, Severities: map[string]string{"P0420": DTCSeverityEmissions}},
+			{ECU: "7E9", Codes: []string{}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reports, ok := DetectPermanentDTCResponse(tt.response)
+			if !ok {
+				t.Fatalf("Expected permanent DTC response to be detected")
+			}
+			if !reflect.DeepEqual(reports, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, reports)
+			}
+		})
+	}
+
+	if _, ok := DetectPermanentDTCResponse("43 01 04 20"); ok {
+		t.Error("Stored DTC response must not be detected as permanent")
+	}
+	if _, ok := DetectDTCResponse("4A 01 04 20"); ok {
+		t.Error("Permanent DTC response must not be detected as stored")
+	}
+}
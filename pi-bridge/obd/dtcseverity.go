@@ -0,0 +1,89 @@
+package obd
+
+// Уровни серьезности кодов неисправностей, от низшего к высшему
+const (
+	DTCSeverityInfo       = "info"       // Кузов и комфорт: не влияет на движение
+	DTCSeverityEmissions  = "emissions"  // Экология: катализатор, EVAP, датчики O2 - ехать можно
+	DTCSeverityDrivetrain = "drivetrain" // Двигатель и трансмиссия: нужна диагностика
+	DTCSeverityCritical   = "critical"   // Опасно продолжать движение: давление масла, перегрев, тормоза
+)
+
+// dtcSeverityRank задает порядок уровней для выбора наибольшего в отчете ЭБУ
+var dtcSeverityRank = map[string]int{
+	DTCSeverityInfo:       1,
+	DTCSeverityEmissions:  2,
+	DTCSeverityDrivetrain: 3,
+	DTCSeverityCritical:   4,
+}
+
+// dtcSeverityRange относит диапазон кодов (включительно) к уровню серьезности.
+// Коды одной длины и одной буквы сравниваются как строки: цифры шестнадцатеричные,
+// а '0'-'9' в ASCII идут раньше 'A'-'F'
+type dtcSeverityRange struct {
+	from, to string
+	severity string
+}
+
+// dtcSeverityRanges - встроенная таблица уровней по группам SAE J2012. Таблица
+// просматривается сверху вниз, поэтому отдельные коды стоят перед своей группой
+var dtcSeverityRanges = []dtcSeverityRange{
+	// Критичные коды внутри групп двигателя
+	{"P0217", "P0219", DTCSeverityCritical}, // Перегрев двигателя и КПП, превышение оборотов
+	{"P0520", "P0524", DTCSeverityCritical}, // Датчик и давление масла
+	{"P0600", "P0606", DTCSeverityCritical}, // Связь и процессор ЭБУ двигателя
+	{"P0335", "P0349", DTCSeverityCritical}, // Датчики коленчатого и распределительного валов
+
+	// Экологические коды внутри групп двигателя
+	{"P0128", "P0128", DTCSeverityEmissions}, // Термостат: ОЖ ниже рабочей температуры
+	{"P0130", "P0167", DTCSeverityEmissions}, // Датчики O2
+	{"P0170", "P0175", DTCSeverityEmissions}, // Коррекция топливоподачи
+
+	// Группы кодов двигателя и трансмиссии
+	{"P0100", "P02FF", DTCSeverityDrivetrain}, // Топливо и воздух
+	{"P0300", "P03FF", DTCSeverityDrivetrain}, // Зажигание и пропуски воспламенения
+	{"P0400", "P04FF", DTCSeverityEmissions},  // Вспомогательные системы снижения токсичности
+	{"P0500", "P09FF", DTCSeverityDrivetrain}, // Холостой ход, ЭБУ, трансмиссия
+	{"P0A00", "P0CFF", DTCSeverityDrivetrain}, // Гибридная силовая установка
+
+	// Шасси и кузов
+	{"C0000", "C3FFF", DTCSeverityCritical}, // Тормоза, ABS, рулевое управление
+	{"B0000", "B00FF", DTCSeverityCritical}, // Подушки и ремни безопасности
+	{"B0100", "B3FFF", DTCSeverityInfo},
+
+	// Потеря связи с ЭБУ двигателя и трансмиссии (U0100-U0102)
+	{"U0100", "U0102", DTCSeverityDrivetrain},
+	{"U0000", "U3FFF", DTCSeverityInfo},
+}
+
+// DTCSeverity возвращает уровень серьезности кода. Коды производителя (P1xxx, P3xxx)
+// и прочие коды двигателя без описания в таблице считаются "drivetrain"
+func DTCSeverity(code string) string {
+	if len(code) != 5 {
+		return DTCSeverityInfo
+	}
+	for _, r := range dtcSeverityRanges {
+		if code[0] == r.from[0] && code >= r.from && code <= r.to {
+			return r.severity
+		}
+	}
+	if code[0] == 'P' {
+		return DTCSeverityDrivetrain
+	}
+	return DTCSeverityInfo
+}
+
+// classifyDTCReport заполняет уровни серьезности кодов и наибольший уровень отчета
+func classifyDTCReport(report *DTCReport) {
+	report.Severity = ""
+	report.Severities = nil
+	for _, code := range report.Codes {
+		severity := DTCSeverity(code)
+		if report.Severities == nil {
+			report.Severities = make(map[string]string, len(report.Codes))
+		}
+		report.Severities[code] = severity
+		if dtcSeverityRank[severity] > dtcSeverityRank[report.Severity] {
+			report.Severity = severity
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package obd
+
+import "testing"
+
+func TestDTCSeverity(t *testing.T) {
+	tests := []struct {
+		code     string
+		expected string
+	}{
+		{"P0420", DTCSeverityEmissions},
+		{"P0133", DTCSeverityEmissions},
+		{"P0128", DTCSeverityEmissions},
+		{"P0455", DTCSeverityEmissions},
+		{"P0522", DTCSeverityCritical},
+		{"P0217", DTCSeverityCritical},
+		{"P0335", DTCSeverityCritical},
+		{"P0101", DTCSeverityDrivetrain},
+		{"P0301", DTCSeverityDrivetrain},
+		{"P0700", DTCSeverityDrivetrain},
+		{"P0A80", DTCSeverityDrivetrain},
+		{"P1ABC", DTCSeverityDrivetrain},
+		{"C0035", DTCSeverityCritical},
+		{"B0001", DTCSeverityCritical},
+		{"B1ABC", DTCSeverityInfo},
+		{"U0100", DTCSeverityDrivetrain},
+		{"U0140", DTCSeverityInfo},
+		{"bad", DTCSeverityInfo},
+	}
+
+	for _, tt := range tests {
+		if severity := DTCSeverity(tt.code); severity != tt.expected {
+			t.Errorf("DTCSeverity(%s) = %s, expected %s", tt.code, severity, tt.expected)
+		}
+	}
+}
+
+func TestDetectDTCResponseSeverity(t *testing.T) {
+	// P0420 (катализатор) и P0522 (давление масла) от одного ЭБУ: уровень отчета - наибольший
+	reports, ok := DetectDTCResponse("43 02 04 20 05 22")
+	if !ok || len(reports) != 1 {
+		t.Fatalf("Expected one DTC report, got %+v", reports)
+	}
+	report := reports[0]
+	if report.Severity != DTCSeverityCritical {
+		t.Errorf("Expected report severity %s, got %s", DTCSeverityCritical, report.Severity)
+	}
+	if report.Severities["P0420"] != DTCSeverityEmissions || report.Severities["P0522"] != DTCSeverityCritical {
+		t.Errorf("Unexpected severities: %v", report.Severities)
+	}
+}
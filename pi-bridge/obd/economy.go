@@ -0,0 +1,147 @@
+package obd
+
+import "time"
+
+// economyWindow - окно скользящего (мгновенного) расхода на 100 км
+const economyWindow = 30 * time.Second
+
+// economyMinDistance - минимальный пробег в окне для расчета расхода, км:
+// на месте и при трогании расход на 100 км не имеет смысла
+const economyMinDistance = 0.05
+
+// fuelRateMaxAge - время, в течение которого показание PID 5E предпочтительнее расчета по MAF
+const fuelRateMaxAge = 30 * time.Second
+
+// economySegment - пройденное расстояние и израсходованное топливо за интервал между показаниями
+type economySegment struct {
+	at       time.Time
+	distance float64 // км
+	fuel     float64 // л
+}
+
+// fuelEconomy вычисляет расход на 100 км: скользящий за economyWindow и средний за поездку.
+// Скорость (PID 0D) и расход топлива (PID 5E или MAF) интегрируются по времени между показаниями
+type fuelEconomy struct {
+	fuel FuelProperties
+	now  func() time.Time
+
+	speed      float64 // Последняя скорость, км/ч
+	flow       float64 // Последний расход, л/ч
+	hasSpeed   bool
+	hasFlow    bool
+	fuelRateAt time.Time // Время последнего показания PID 5E
+	lastUpdate time.Time
+
+	window       []economySegment
+	tripDistance float64
+	tripFuel     float64
+}
+
+// newFuelEconomy создает вычисление расхода на 100 км для заданного топлива
+func newFuelEconomy(fuel FuelProperties) *fuelEconomy {
+	return &fuelEconomy{fuel: fuel, now: time.Now}
+}
+
+// Derive учитывает показание скорости или расхода и по показанию скорости публикует
+// скользящий (fuel_economy) и средний за поездку (trip_fuel_economy) расход в л/100 км
+func (e *fuelEconomy) Derive(telemetry *Telemetry) []*Telemetry {
+	now := e.now()
+
+	switch telemetry.PID {
+	case "0D":
+		e.integrate(now)
+		e.speed, e.hasSpeed = telemetry.Value, true
+	case "5E":
+		e.integrate(now)
+		e.flow, e.hasFlow = telemetry.Value, true
+		e.fuelRateAt = now
+	case "10":
+		// Расход по MAF используется, только если автомобиль не сообщает PID 5E
+		if now.Sub(e.fuelRateAt) < fuelRateMaxAge {
+			return nil
+		}
+		e.integrate(now)
+		e.flow, e.hasFlow = e.fuel.FlowFromMAF(telemetry.Value), true
+	default:
+		return nil
+	}
+
+	if telemetry.PID != "0D" {
+		return nil
+	}
+
+	var result []*Telemetry
+	if economy, ok := e.windowEconomy(); ok {
+		result = append(result, economyTelemetry("fuel_economy", economy, telemetry))
+	}
+	if e.tripDistance >= economyMinDistance && e.tripFuel > 0 {
+		result = append(result, economyTelemetry("trip_fuel_economy", e.tripFuel/e.tripDistance*100, telemetry))
+	}
+	return result
+}
+
+// EndTrip сбрасывает средний расход поездки (итог поездки не публикуется:
+// последнее значение trip_fuel_economy уже отправлено)
+func (e *fuelEconomy) EndTrip() []*Telemetry {
+	e.window = nil
+	e.tripDistance, e.tripFuel = 0, 0
+	e.hasSpeed, e.hasFlow = false, false
+	return nil
+}
+
+// integrate добавляет пробег и расход с предыдущего показания до now
+func (e *fuelEconomy) integrate(now time.Time) {
+	last := e.lastUpdate
+	e.lastUpdate = now
+
+	if last.IsZero() {
+		return
+	}
+	elapsed := now.Sub(last)
+	if elapsed > tripIdleTimeout {
+		// Показания за перерыв неизвестны, интегрирование начинается заново
+		e.window = nil
+		e.hasSpeed, e.hasFlow = false, false
+		return
+	}
+	if !e.hasSpeed || !e.hasFlow {
+		return
+	}
+
+	hours := elapsed.Hours()
+	segment := economySegment{at: now, distance: e.speed * hours, fuel: e.flow * hours}
+	e.window = append(e.window, segment)
+	e.tripDistance += segment.distance
+	e.tripFuel += segment.fuel
+
+	// Отбрасываем интервалы старше окна
+	cutoff := now.Add(-economyWindow)
+	for len(e.window) > 0 && e.window[0].at.Before(cutoff) {
+		e.window = e.window[1:]
+	}
+}
+
+// windowEconomy возвращает скользящий расход на 100 км, если пробег в окне достаточен
+func (e *fuelEconomy) windowEconomy() (float64, bool) {
+	var distance, fuel float64
+	for _, segment := range e.window {
+		distance += segment.distance
+		fuel += segment.fuel
+	}
+	// При нулевом расходе (торможение двигателем) значение в mpg не определено
+	if distance < economyMinDistance || fuel <= 0 {
+		return 0, false
+	}
+	return fuel / distance * 100, true
+}
+
+// economyTelemetry создает метрику расхода на 100 км по показанию скорости
+func economyTelemetry(metric string, value float64, source *Telemetry) *Telemetry {
+	return &Telemetry{
+		Metric:    metric,
+		Value:     value,
+		Unit:      "L/100km",
+		Timestamp: source.Timestamp,
+		ECU:       source.ECU,
+	}
+}
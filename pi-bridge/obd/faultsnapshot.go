@@ -0,0 +1,221 @@
+package obd
+
+import (
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"elm327-bridge/common"
+	"elm327-bridge/obd/decoder"
+)
+
+// freezeFrameResponseService - байт ответа на сервис 02 (стоп-кадр)
+const freezeFrameResponseService = 0x42
+
+// freezeFrameDTCPID - PID стоп-кадра с кодом, вызвавшим его сохранение
+const freezeFrameDTCPID = "02"
+
+// snapshotTimeout - время сбора данных снимка неисправности
+const snapshotTimeout = 15 * time.Second
+
+// snapshotPIDs - PID, запрашиваемые из стоп-кадра и текущих данных при появлении DTC
+var snapshotPIDs = []string{"04", "05", "06", "07", "0B", "0C", "0D", "0F", "11"}
+
+// SnapshotValue представляет одно значение снимка неисправности
+type SnapshotValue struct {
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// FaultSnapshot представляет снимок неисправности: новые коды, стоп-кадр,
+// сохраненный ЭБУ в момент ошибки, и текущие значения тех же параметров
+type FaultSnapshot struct {
+	NewDTCs        []string                 `json:"new_dtcs"`
+	AllDTCs        []string                 `json:"all_dtcs"`
+	FreezeFrameDTC string                   `json:"freeze_frame_dtc,omitempty"` // Код, вызвавший сохранение стоп-кадра
+	FreezeFrame    map[string]SnapshotValue `json:"freeze_frame"`               // По названию метрики
+	Live           map[string]SnapshotValue `json:"live"`
+	Complete       bool                     `json:"complete"` // Получены все запрошенные значения
+	CapturedAt     time.Time                `json:"captured_at"`
+}
+
+// FaultSnapshotter отслеживает коды неисправностей из периодического опроса DTC и при
+// появлении нового кода запрашивает стоп-кадр и текущие значения, публикуя их одним снимком
+type FaultSnapshotter struct {
+	mu           sync.Mutex
+	commandsChan chan<- string
+	statusChan   chan<- common.StatusEvent
+	known        map[string]bool // Коды из предыдущего опроса
+	baseline     bool            // Первый опрос выполнен
+	snapshot     *FaultSnapshot  // Собираемый снимок (nil - сбор не идет)
+	generation   int
+	logger       *log.Logger
+}
+
+// NewFaultSnapshotter создает обработчик снимков неисправностей
+func NewFaultSnapshotter(commandsChan chan<- string, statusChan chan<- common.StatusEvent) *FaultSnapshotter {
+	return &FaultSnapshotter{
+		commandsChan: commandsChan,
+		statusChan:   statusChan,
+		known:        make(map[string]bool),
+		logger:       log.New(os.Stdout, "[OBD-FaultSnapshot] ", log.LstdFlags|log.Lshortfile),
+	}
+}
+
+// ObserveDTCs сравнивает коды из опроса DTC с предыдущими и запускает снимок при появлении
+// нового кода. Коды первого опроса после запуска считаются известными: их стоп-кадр
+// был сохранен до запуска моста
+func (f *FaultSnapshotter) ObserveDTCs(reports []DTCReport) []string {
+	codes := dtcCodes(reports)
+
+	f.mu.Lock()
+	var newCodes []string
+	for _, code := range codes {
+		if !f.known[code] {
+			newCodes = append(newCodes, code)
+		}
+	}
+
+	// Сброшенные коды забываются, чтобы их повторное появление снова вызвало снимок
+	f.known = make(map[string]bool, len(codes))
+	for _, code := range codes {
+		f.known[code] = true
+	}
+
+	if !f.baseline {
+		f.baseline = true
+		f.mu.Unlock()
+		return nil
+	}
+	if len(newCodes) == 0 || f.snapshot != nil {
+		f.mu.Unlock()
+		return newCodes
+	}
+
+	f.generation++
+	generation := f.generation
+	f.snapshot = &FaultSnapshot{
+		NewDTCs:     newCodes,
+		AllDTCs:     codes,
+		FreezeFrame: make(map[string]SnapshotValue),
+		Live:        make(map[string]SnapshotValue),
+		CapturedAt:  time.Now(),
+	}
+	f.mu.Unlock()
+
+	f.logger.Printf("New DTC(s) %v, capturing fault snapshot", newCodes)
+	time.AfterFunc(snapshotTimeout, func() { f.finish(generation) })
+
+	// Отправка не должна блокировать парсер, из которого вызывается метод
+	go func() {
+		for _, command := range snapshotCommands() {
+			f.commandsChan <- command
+		}
+	}()
+	return newCodes
+}
+
+// Observe собирает значения стоп-кадра и текущие значения во время сбора снимка
+func (f *FaultSnapshotter) Observe(response string, telemetry *Telemetry) {
+	f.mu.Lock()
+	if f.snapshot == nil {
+		f.mu.Unlock()
+		return
+	}
+
+	if telemetry == nil {
+		// Код стоп-кадра (PID 02 сервиса 02) не является значением и разбирается отдельно
+		if code, ok := freezeFrameDTC(response); ok {
+			f.snapshot.FreezeFrameDTC = code
+		}
+	} else if isSnapshotPID(telemetry.PID) {
+		var value SnapshotValue
+		value.Value, value.Unit = ConvertValue(telemetry.Value, telemetry.Unit)
+		if telemetry.FreezeFrame {
+			f.snapshot.FreezeFrame[telemetry.Metric] = value
+		} else {
+			f.snapshot.Live[telemetry.Metric] = value
+		}
+	}
+
+	complete := f.snapshot.FreezeFrameDTC != "" &&
+		len(f.snapshot.FreezeFrame) == len(snapshotPIDs) &&
+		len(f.snapshot.Live) == len(snapshotPIDs)
+	generation := f.generation
+	f.mu.Unlock()
+
+	if complete {
+		f.finish(generation)
+	}
+}
+
+// finish публикует собранный снимок (один раз на сбор)
+func (f *FaultSnapshotter) finish(generation int) {
+	f.mu.Lock()
+	if f.snapshot == nil || generation != f.generation {
+		f.mu.Unlock()
+		return
+	}
+	snapshot := f.snapshot
+	f.snapshot = nil
+	snapshot.Complete = snapshot.FreezeFrameDTC != "" &&
+		len(snapshot.FreezeFrame) == len(snapshotPIDs) &&
+		len(snapshot.Live) == len(snapshotPIDs)
+	f.mu.Unlock()
+
+	f.logger.Printf("Fault snapshot for %v captured (complete: %v)", snapshot.NewDTCs, snapshot.Complete)
+
+	event := common.StatusEvent{
+		Kind:      "fault_snapshot",
+		Data:      snapshot,
+		Retained:  true,
+		Timestamp: time.Now(),
+	}
+	select {
+	case f.statusChan <- event:
+	default:
+		f.logger.Println("Warning: status channel is full, dropping fault snapshot")
+	}
+}
+
+// snapshotCommands формирует запросы снимка: код стоп-кадра, значения стоп-кадра 0 и текущие значения
+func snapshotCommands() []string {
+	commands := []string{"02" + freezeFrameDTCPID + "00"}
+	for _, pid := range snapshotPIDs {
+		commands = append(commands, "02"+pid+"00")
+	}
+	for _, pid := range snapshotPIDs {
+		commands = append(commands, "01"+pid)
+	}
+	return commands
+}
+
+// isSnapshotPID проверяет, входит ли PID в снимок неисправности
+func isSnapshotPID(pid string) bool {
+	for _, p := range snapshotPIDs {
+		if p == pid {
+			return true
+		}
+	}
+	return false
+}
+
+// freezeFrameDTC извлекает код из ответа "42 02 00 01 33" (пустой код "00 00" - стоп-кадра нет)
+func freezeFrameDTC(response string) (string, bool) {
+	messages, err := decoder.ReassembleResponse(response)
+	if err != nil {
+		return "", false
+	}
+
+	for _, message := range messages {
+		payload := message.Payload
+		if len(payload) == 5 && payload[0] == freezeFrameResponseService && payload[1] == 0x02 {
+			if payload[3] == 0 && payload[4] == 0 {
+				return "", false
+			}
+			return DecodeDTC(payload[3], payload[4]), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,80 @@
+package obd
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+func TestParseFreezeFrameResponse(t *testing.T) {
+	telemetries, err := ParseResponses("42 0C 00 1A F0")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(telemetries) != 1 || !telemetries[0].FreezeFrame || telemetries[0].PID != "0C" || telemetries[0].Value != 1724 {
+		t.Errorf("Unexpected freeze frame telemetry: %+v", telemetries)
+	}
+}
+
+func TestFreezeFrameDTC(t *testing.T) {
+	if code, ok := freezeFrameDTC("42 02 00 01 33"); !ok || code != "P0133" {
+		t.Errorf("Expected P0133, got %q (%v)", code, ok)
+	}
+	if _, ok := freezeFrameDTC("42 02 00 00 00"); ok {
+		t.Error("Expected empty freeze frame DTC to be ignored")
+	}
+}
+
+func TestFaultSnapshotter(t *testing.T) {
+	commandsChan := make(chan string, 64)
+	statusChan := make(chan common.StatusEvent, 1)
+	snapshotter := NewFaultSnapshotter(commandsChan, statusChan)
+
+	// Коды первого опроса считаются известными
+	if newCodes := snapshotter.ObserveDTCs([]DTCReport{{Codes: []string{"P0420"}}}); len(newCodes) != 0 {
+		t.Fatalf("Expected baseline scan to report no new codes, got %v", newCodes)
+	}
+
+	newCodes := snapshotter.ObserveDTCs([]DTCReport{{Codes: []string{"P0133", "P0420"}}})
+	if len(newCodes) != 1 || newCodes[0] != "P0133" {
+		t.Fatalf("Expected new code P0133, got %v", newCodes)
+	}
+
+	expected := snapshotCommands()
+	for i, want := range expected {
+		select {
+		case command := <-commandsChan:
+			if command != want {
+				t.Fatalf("Command %d: expected %s, got %s", i, want, command)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected snapshot command %s", want)
+		}
+	}
+
+	snapshotter.Observe("42 02 00 01 33", nil)
+	for _, pid := range snapshotPIDs {
+		snapshotter.Observe("", &Telemetry{PID: pid, Metric: fmt.Sprintf("m%s", pid), Value: 1, FreezeFrame: true})
+		snapshotter.Observe("", &Telemetry{PID: pid, Metric: fmt.Sprintf("m%s", pid), Value: 2})
+	}
+
+	select {
+	case event := <-statusChan:
+		snapshot, ok := event.Data.(*FaultSnapshot)
+		if event.Kind != "fault_snapshot" || !event.Retained || !ok {
+			t.Fatalf("Unexpected status event: %+v", event)
+		}
+		if !snapshot.Complete || snapshot.FreezeFrameDTC != "P0133" || snapshot.Live["m0C"].Value != 2 || snapshot.FreezeFrame["m0C"].Value != 1 {
+			t.Errorf("Unexpected snapshot: %+v", snapshot)
+		}
+	default:
+		t.Fatal("Expected fault snapshot to be published")
+	}
+
+	// Известные коды не вызывают новый снимок
+	if newCodes := snapshotter.ObserveDTCs([]DTCReport{{Codes: []string{"P0133", "P0420"}}}); len(newCodes) != 0 {
+		t.Errorf("Expected no new codes, got %v", newCodes)
+	}
+}
@@ -0,0 +1,233 @@
+package obd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Формулы PID задаются выражениями над байтами ответа A, B, C, ... :
+//   (A*256+B)/4, A-40, ((A<<24)+(B<<16)+(C<<8)+D)/10, (A&0x80)>>7
+// Поддерживаются числа (включая 0x..), скобки, унарный минус и операторы
+// + - * / % << >> & | с приоритетом как в Go.
+
+// formulaNode - скомпилированный узел выражения
+type formulaNode func(data []byte) float64
+
+// formulaParser разбирает выражение методом рекурсивного спуска
+type formulaParser struct {
+	input   string
+	pos     int
+	maxByte int // Максимальный индекс байта, использованный в выражении
+}
+
+// CompileFormula компилирует выражение в декодер PID, ожидающий byteCount байт
+func CompileFormula(pid, expr string, byteCount int) (PIDDecoder, error) {
+	if byteCount <= 0 {
+		return nil, fmt.Errorf("byte count must be positive, got %d", byteCount)
+	}
+
+	p := &formulaParser{input: expr, maxByte: -1}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+
+	p.skipSpaces()
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.input[p.pos:], p.pos)
+	}
+
+	if p.maxByte >= byteCount {
+		return nil, fmt.Errorf("formula uses byte %c but only %d byte(s) expected", 'A'+rune(p.maxByte), byteCount)
+	}
+
+	return func(data []byte) (float64, error) {
+		if len(data) != byteCount {
+			return 0, fmt.Errorf("PID %s: ожидалось %d байт, получено %d", pid, byteCount, len(data))
+		}
+		return node(data), nil
+	}, nil
+}
+
+// Уровни приоритета: | → & → << >> → + - → * / % → унарные → первичные
+
+func (p *formulaParser) parseOr() (formulaNode, error) {
+	return p.parseBinary(p.parseAnd, map[string]func(a, b float64) float64{
+		"|": func(a, b float64) float64 { return float64(int64(a) | int64(b)) },
+	})
+}
+
+func (p *formulaParser) parseAnd() (formulaNode, error) {
+	return p.parseBinary(p.parseShift, map[string]func(a, b float64) float64{
+		"&": func(a, b float64) float64 { return float64(int64(a) & int64(b)) },
+	})
+}
+
+func (p *formulaParser) parseShift() (formulaNode, error) {
+	return p.parseBinary(p.parseAdditive, map[string]func(a, b float64) float64{
+		"<<": func(a, b float64) float64 { return float64(int64(a) << uint(b)) },
+		">>": func(a, b float64) float64 { return float64(int64(a) >> uint(b)) },
+	})
+}
+
+func (p *formulaParser) parseAdditive() (formulaNode, error) {
+	return p.parseBinary(p.parseMultiplicative, map[string]func(a, b float64) float64{
+		"+": func(a, b float64) float64 { return a + b },
+		"-": func(a, b float64) float64 { return a - b },
+	})
+}
+
+func (p *formulaParser) parseMultiplicative() (formulaNode, error) {
+	return p.parseBinary(p.parseUnary, map[string]func(a, b float64) float64{
+		"*": func(a, b float64) float64 { return a * b },
+		"/": func(a, b float64) float64 { return a / b },
+		"%": func(a, b float64) float64 { return float64(int64(a) % int64(b)) },
+	})
+}
+
+// parseBinary разбирает левоассоциативную цепочку операторов одного уровня
+func (p *formulaParser) parseBinary(next func() (formulaNode, error), ops map[string]func(a, b float64) float64) (formulaNode, error) {
+	left, err := next()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		p.skipSpaces()
+		op := p.matchOperator(ops)
+		if op == "" {
+			return left, nil
+		}
+
+		right, err := next()
+		if err != nil {
+			return nil, err
+		}
+
+		apply, l, r := ops[op], left, right
+		if op == "/" || op == "%" {
+			// Деление на ноль дает 0, чтобы мусорные данные не порождали Inf/NaN
+			left = func(data []byte) float64 {
+				divisor := r(data)
+				if divisor == 0 || (op == "%" && int64(divisor) == 0) {
+					return 0
+				}
+				return apply(l(data), divisor)
+			}
+			continue
+		}
+		left = func(data []byte) float64 { return apply(l(data), r(data)) }
+	}
+}
+
+// matchOperator ищет оператор текущего уровня в позиции разбора
+func (p *formulaParser) matchOperator(ops map[string]func(a, b float64) float64) string {
+	rest := p.input[p.pos:]
+	// Сначала проверяем двухсимвольные операторы сдвига
+	for _, size := range []int{2, 1} {
+		if len(rest) < size {
+			continue
+		}
+		candidate := rest[:size]
+		if _, ok := ops[candidate]; ok {
+			p.pos += size
+			return candidate
+		}
+	}
+	return ""
+}
+
+// parseUnary разбирает унарный минус
+func (p *formulaParser) parseUnary() (formulaNode, error) {
+	p.skipSpaces()
+	if p.pos < len(p.input) && p.input[p.pos] == '-' {
+		p.pos++
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return func(data []byte) float64 { return -operand(data) }, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary разбирает число, переменную байта или выражение в скобках
+func (p *formulaParser) parsePrimary() (formulaNode, error) {
+	p.skipSpaces()
+	if p.pos >= len(p.input) {
+		return nil, fmt.Errorf("unexpected end of formula")
+	}
+
+	ch := rune(p.input[p.pos])
+	switch {
+	case ch == '(':
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if p.pos >= len(p.input) || p.input[p.pos] != ')' {
+			return nil, fmt.Errorf("missing closing parenthesis at position %d", p.pos)
+		}
+		p.pos++
+		return node, nil
+
+	case ch >= 'A' && ch <= 'Z' && !p.isIdentifierAt(p.pos+1):
+		index := int(ch - 'A')
+		p.pos++
+		if index > p.maxByte {
+			p.maxByte = index
+		}
+		return func(data []byte) float64 { return float64(data[index]) }, nil
+
+	case unicode.IsDigit(ch) || ch == '.':
+		return p.parseNumber()
+	}
+
+	return nil, fmt.Errorf("unexpected %q at position %d", string(ch), p.pos)
+}
+
+// parseNumber разбирает десятичное или шестнадцатеричное число
+func (p *formulaParser) parseNumber() (formulaNode, error) {
+	start := p.pos
+	if strings.HasPrefix(p.input[p.pos:], "0x") || strings.HasPrefix(p.input[p.pos:], "0X") {
+		p.pos += 2
+		for p.pos < len(p.input) && strings.ContainsRune("0123456789abcdefABCDEF", rune(p.input[p.pos])) {
+			p.pos++
+		}
+		value, err := strconv.ParseUint(p.input[start+2:p.pos], 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex number %q: %v", p.input[start:p.pos], err)
+		}
+		v := float64(value)
+		return func([]byte) float64 { return v }, nil
+	}
+
+	for p.pos < len(p.input) && (unicode.IsDigit(rune(p.input[p.pos])) || p.input[p.pos] == '.') {
+		p.pos++
+	}
+	value, err := strconv.ParseFloat(p.input[start:p.pos], 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number %q: %v", p.input[start:p.pos], err)
+	}
+	return func([]byte) float64 { return value }, nil
+}
+
+// isIdentifierAt проверяет, продолжается ли идентификатор в позиции pos
+func (p *formulaParser) isIdentifierAt(pos int) bool {
+	if pos >= len(p.input) {
+		return false
+	}
+	ch := rune(p.input[pos])
+	return unicode.IsLetter(ch) || unicode.IsDigit(ch) || ch == '_'
+}
+
+// skipSpaces пропускает пробелы
+func (p *formulaParser) skipSpaces() {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+}
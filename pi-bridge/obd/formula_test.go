@@ -0,0 +1,108 @@
+package obd
+
+import "testing"
+
+func TestCompileFormula(t *testing.T) {
+	tests := []struct {
+		name     string
+		formula  string
+		bytes    int
+		data     []byte
+		expected float64
+	}{
+		{"RPM", "(A*256+B)/4", 2, []byte{0x1A, 0xF8}, 1726},
+		{"Temperature", "A-40", 1, []byte{0x7B}, 83},
+		{"Percent", "A*100/255", 1, []byte{0xFF}, 100},
+		{"Shifts", "((A<<8)|B)>>1", 2, []byte{0x01, 0x02}, 129},
+		{"Bit mask", "(A&0x80)>>7", 1, []byte{0x80}, 1},
+		{"Unary minus", "-A+10", 1, []byte{0x05}, 5},
+		{"Precedence", "A+B*2", 2, []byte{0x01, 0x02}, 5},
+		{"Division by zero", "A/B", 2, []byte{0x10, 0x00}, 0},
+		{"Decimal constant", "A*0.5", 1, []byte{0x0A}, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decoder, err := CompileFormula("5C", tt.formula, tt.bytes)
+			if err != nil {
+				t.Fatalf("Unexpected compile error: %v", err)
+			}
+			value, err := decoder(tt.data)
+			if err != nil {
+				t.Fatalf("Unexpected decode error: %v", err)
+			}
+			if value != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, value)
+			}
+		})
+	}
+}
+
+func TestCompileFormulaErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		formula string
+		bytes   int
+	}{
+		{"Byte out of range", "A*256+B", 1},
+		{"Unbalanced parenthesis", "(A+1", 1},
+		{"Unknown identifier", "RPM*2", 1},
+		{"Trailing garbage", "A 1", 1},
+		{"Empty", "", 1},
+		{"Zero bytes", "A", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CompileFormula("5C", tt.formula, tt.bytes); err == nil {
+				t.Errorf("Expected error for formula %q", tt.formula)
+			}
+		})
+	}
+}
+
+func TestRegisterCustomPIDs(t *testing.T) {
+	err := RegisterCustomPIDs([]CustomPID{
+		{PID: "5c", Name: "oil_temperature", Unit: "°C", Bytes: 1, Formula: "A-40", Poll: true},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer func() {
+		pidParser.Unregister("5C")
+		delete(metricDetails, "5C")
+		delete(metricSources, "5C")
+		pollPIDs = pollPIDs[:len(pollPIDs)-1]
+	}()
+
+	telemetry, err := ParseResponse("41 5C 82")
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+	if telemetry.Metric != "oil_temperature" || telemetry.Value != 90 || telemetry.Unit != "°C" {
+		t.Errorf("Unexpected telemetry: %+v", telemetry)
+	}
+	if !containsPID(pollPIDs, "5C") {
+		t.Error("Expected custom PID to be added to the poll list")
+	}
+
+	if err := RegisterCustomPIDs([]CustomPID{{PID: "5D", Name: "bad", Bytes: 1, Formula: "A+B"}}); err == nil {
+		t.Error("Expected error for formula referencing missing byte")
+	}
+}
+
+func BenchmarkCompiledFormula(b *testing.B) {
+	decoder, err := CompileFormula("A6", "((A<<24)+(B<<16)+(C<<8)+D)/10", 4)
+	if err != nil {
+		b.Fatalf("Unexpected compile error: %v", err)
+	}
+	data := []byte{0x00, 0x01, 0xE2, 0x40}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := decoder(data); err != nil {
+			b.Fatalf("Decoder failed: %v", err)
+		}
+	}
+}
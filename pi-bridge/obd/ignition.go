@@ -0,0 +1,203 @@
+package obd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+// Значения по умолчанию для определения запуска двигателя по напряжению
+const (
+	defaultWakeVoltage    = 13.2             // Выше - генератор работает (двигатель запущен)
+	defaultParkAfter      = 2 * time.Minute  // ЭБУ не отвечают - автомобиль на стоянке
+	defaultParkedInterval = 15 * time.Second // Период ATRV на стоянке
+)
+
+// Состояния автомобиля для определения запуска двигателя
+const (
+	ignitionRunning = "running" // Двигатель запущен или ЭБУ отвечают, опрос выполняется
+	ignitionParked  = "parked"  // Стоянка: опрос приостановлен, запрашивается только ATRV
+)
+
+// IgnitionConfig задает работу без участия пользователя для постоянно установленного
+// моста: на стоянке опрос приостанавливается, а запуск двигателя определяется по росту
+// напряжения бортовой сети
+type IgnitionConfig struct {
+	Enabled        bool          `yaml:"enabled"`         // Определять стоянку и запуск двигателя
+	WakeVoltage    float64       `yaml:"wake_voltage"`    // Напряжение ATRV, выше которого работает генератор (0 - 13.2 В)
+	ParkAfter      time.Duration `yaml:"park_after"`      // Время ответов NO DATA до перехода на стоянку (0 - 2 мин)
+	ParkedInterval time.Duration `yaml:"parked_interval"` // Период запроса ATRV на стоянке (0 - 15 с)
+}
+
+// Validate проверяет параметры определения запуска двигателя
+func (c IgnitionConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.WakeVoltage < 0 || c.WakeVoltage > 30 {
+		return fmt.Errorf("invalid ignition wake voltage %v: expected 0-30 V", c.WakeVoltage)
+	}
+	if c.ParkAfter < 0 {
+		return fmt.Errorf("invalid ignition park_after %v", c.ParkAfter)
+	}
+	if c.ParkedInterval < 0 {
+		return fmt.Errorf("invalid ignition parked_interval %v", c.ParkedInterval)
+	}
+	return nil
+}
+
+// IgnitionReport - состояние автомобиля для публикации
+type IgnitionReport struct {
+	State   string    `json:"state"`             // running или parked
+	Reason  string    `json:"reason,omitempty"`  // Причина перехода
+	Voltage float64   `json:"voltage,omitempty"` // Последнее напряжение ATRV (В)
+	Since   time.Time `json:"since"`             // Время перехода
+}
+
+// ignitionAction - действие менеджера команд в текущем цикле опроса
+type ignitionAction int
+
+const (
+	ignitionPoll  ignitionAction = iota // Обычный цикл опроса
+	ignitionCheck                       // Стоянка: запросить напряжение и пропустить цикл
+	ignitionSkip                        // Стоянка: цикл пропускается
+)
+
+// IgnitionMonitor переводит мост на стоянку, когда ЭБУ долго отвечают NO DATA (или молчат
+// при низком напряжении), и раз в parked_interval запрашивает напряжение ATRV. Когда
+// напряжение поднимается выше wake_voltage (генератор заработал), адаптер инициализируется
+// заново обработчиком onWake, и обычный опрос возобновляется
+type IgnitionMonitor struct {
+	mu         sync.Mutex
+	config     IgnitionConfig
+	state      string
+	since      time.Time
+	noDataFrom time.Time // Начало ответов NO DATA без данных (ноль - ЭБУ отвечают)
+	voltage    float64   // Последнее напряжение ATRV (0 - неизвестно)
+	lastCheck  time.Time // Последний запрос напряжения на стоянке
+	onWake     func()
+	statusChan chan<- common.StatusEvent
+	logger     *log.Logger
+}
+
+// NewIgnitionMonitor создает монитор запуска двигателя. onWake вызывается при запуске
+// двигателя и не должен блокироваться. Для выключенного режима возвращает nil: методы
+// nil монитора ничего не делают
+func NewIgnitionMonitor(config IgnitionConfig, statusChan chan<- common.StatusEvent, onWake func()) *IgnitionMonitor {
+	if !config.Enabled {
+		return nil
+	}
+	if config.WakeVoltage == 0 {
+		config.WakeVoltage = defaultWakeVoltage
+	}
+	if config.ParkAfter == 0 {
+		config.ParkAfter = defaultParkAfter
+	}
+	if config.ParkedInterval == 0 {
+		config.ParkedInterval = defaultParkedInterval
+	}
+	return &IgnitionMonitor{
+		config:     config,
+		state:      ignitionRunning,
+		since:      time.Now(),
+		onWake:     onWake,
+		statusChan: statusChan,
+		logger:     log.New(os.Stdout, "[OBD-Ignition] ", log.LstdFlags|log.Lshortfile),
+	}
+}
+
+// Observe отслеживает ответы ЭБУ: данные означают включенное зажигание, NO DATA -
+// начало отсчета park_after
+func (m *IgnitionMonitor) Observe(response string, telemetry *Telemetry) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if telemetry != nil {
+		m.noDataFrom = time.Time{}
+		// Ответ на запрос клиента со стоянки: адаптер уже работает с шиной
+		if m.state == ignitionParked {
+			m.transition(ignitionRunning, "ECU responded", time.Now())
+		}
+		return
+	}
+	if status, _, ok := DetectAdapterStatus(response); ok && status == AdapterStatusNoData && m.noDataFrom.IsZero() {
+		m.noDataFrom = time.Now()
+	}
+}
+
+// ObserveAT отслеживает напряжение из ответов на ATRV (обработчик ответов на AT команды).
+// Рост напряжения на стоянке означает запуск двигателя
+func (m *IgnitionMonitor) ObserveAT(command, response string) {
+	if m == nil {
+		return
+	}
+	voltage, ok := ParseBatteryVoltage(command, response)
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	m.voltage = voltage
+	wake := m.state == ignitionParked && voltage >= m.config.WakeVoltage
+	if wake {
+		m.noDataFrom = time.Time{}
+		m.transition(ignitionRunning, fmt.Sprintf("battery voltage %.1fV above %.1fV", voltage, m.config.WakeVoltage), time.Now())
+	}
+	m.mu.Unlock()
+
+	// Протокол шины после выключения зажигания потерян: адаптер инициализируется заново
+	if wake && m.onWake != nil {
+		m.onWake()
+	}
+}
+
+// next возвращает действие менеджера команд для цикла опроса в момент now
+func (m *IgnitionMonitor) next(now time.Time) ignitionAction {
+	if m == nil {
+		return ignitionPoll
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.state == ignitionParked {
+		if now.Sub(m.lastCheck) < m.config.ParkedInterval {
+			return ignitionSkip
+		}
+		m.lastCheck = now
+		return ignitionCheck
+	}
+
+	// Низкое напряжение при включенном зажигании и заглушенном двигателе - не стоянка,
+	// поэтому по напряжению стоянка определяется только при молчащих ЭБУ
+	switch {
+	case m.voltage > 0 && m.voltage < m.config.WakeVoltage && !m.noDataFrom.IsZero():
+		m.park(fmt.Sprintf("battery voltage %.1fV below %.1fV and no ECU response", m.voltage, m.config.WakeVoltage), now)
+	case !m.noDataFrom.IsZero() && now.Sub(m.noDataFrom) >= m.config.ParkAfter:
+		m.park(fmt.Sprintf("NO DATA for %v", now.Sub(m.noDataFrom).Round(time.Second)), now)
+	default:
+		return ignitionPoll
+	}
+	return ignitionSkip
+}
+
+// park переводит монитор на стоянку (вызывается под мьютексом). Первый запрос
+// напряжения выполняется через parked_interval
+func (m *IgnitionMonitor) park(reason string, now time.Time) {
+	m.lastCheck = now
+	m.transition(ignitionParked, reason, now)
+}
+
+// transition меняет состояние и публикует его (вызывается под мьютексом)
+func (m *IgnitionMonitor) transition(state, reason string, now time.Time) {
+	m.state = state
+	m.since = now
+	m.logger.Printf("Ignition state: %s (%s)", state, reason)
+	sendStatus("ignition", IgnitionReport{State: state, Reason: reason, Voltage: m.voltage, Since: now}, m.statusChan, m.logger)
+}
@@ -0,0 +1,135 @@
+package obd
+
+import (
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+func TestIgnitionConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  IgnitionConfig
+		wantErr bool
+	}{
+		{"disabled", IgnitionConfig{WakeVoltage: -1}, false},
+		{"defaults", IgnitionConfig{Enabled: true}, false},
+		{"custom", IgnitionConfig{Enabled: true, WakeVoltage: 13.5, ParkAfter: time.Minute, ParkedInterval: 30 * time.Second}, false},
+		{"negative voltage", IgnitionConfig{Enabled: true, WakeVoltage: -1}, true},
+		{"voltage too high", IgnitionConfig{Enabled: true, WakeVoltage: 48}, true},
+		{"negative park_after", IgnitionConfig{Enabled: true, ParkAfter: -time.Second}, true},
+		{"negative parked_interval", IgnitionConfig{Enabled: true, ParkedInterval: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIgnitionDisabled(t *testing.T) {
+	monitor := NewIgnitionMonitor(IgnitionConfig{}, make(chan common.StatusEvent, 10), func() {
+		t.Error("Unexpected wake handler call")
+	})
+	if monitor != nil {
+		t.Fatal("Expected nil monitor when ignition detection is disabled")
+	}
+
+	// Методы nil монитора безопасны и не влияют на опрос
+	monitor.Observe("NO DATA", nil)
+	monitor.ObserveAT(BatteryVoltageCommand, "14.2V")
+	if action := monitor.next(time.Now().Add(time.Hour)); action != ignitionPoll {
+		t.Errorf("Expected poll action, got %v", action)
+	}
+}
+
+func TestIgnitionParkAndWake(t *testing.T) {
+	statusChan := make(chan common.StatusEvent, 10)
+	wakes := 0
+	monitor := NewIgnitionMonitor(IgnitionConfig{Enabled: true, ParkAfter: time.Minute, ParkedInterval: 10 * time.Second}, statusChan, func() { wakes++ })
+	start := time.Now()
+
+	monitor.Observe("NO DATA", nil)
+	if action := monitor.next(start.Add(30 * time.Second)); action != ignitionPoll {
+		t.Fatalf("Expected poll before park_after, got %v", action)
+	}
+	if action := monitor.next(start.Add(2 * time.Minute)); action != ignitionSkip {
+		t.Fatalf("Expected parked after park_after, got %v", action)
+	}
+
+	event := <-statusChan
+	report, ok := event.Data.(IgnitionReport)
+	if event.Kind != "ignition" || !ok || report.State != ignitionParked {
+		t.Errorf("Unexpected status event: %+v", event)
+	}
+
+	// На стоянке напряжение запрашивается раз в parked_interval
+	parked := start.Add(2 * time.Minute)
+	if action := monitor.next(parked.Add(5 * time.Second)); action != ignitionSkip {
+		t.Errorf("Expected skipped cycle before parked_interval, got %v", action)
+	}
+	if action := monitor.next(parked.Add(10 * time.Second)); action != ignitionCheck {
+		t.Fatalf("Expected voltage check after parked_interval, got %v", action)
+	}
+	if action := monitor.next(parked.Add(11 * time.Second)); action != ignitionSkip {
+		t.Errorf("Expected skipped cycle after voltage check, got %v", action)
+	}
+
+	// Напряжение аккумулятора без генератора - стоянка продолжается
+	monitor.ObserveAT(BatteryVoltageCommand, "12.4V")
+	if wakes != 0 {
+		t.Fatalf("Expected no wake-up on battery voltage, got %d", wakes)
+	}
+
+	// Генератор заработал - адаптер инициализируется заново, опрос возобновляется
+	monitor.ObserveAT(BatteryVoltageCommand, "14.1V")
+	if wakes != 1 {
+		t.Fatalf("Expected one wake-up, got %d", wakes)
+	}
+	if action := monitor.next(parked.Add(12 * time.Second)); action != ignitionPoll {
+		t.Errorf("Expected poll after engine start, got %v", action)
+	}
+
+	event = <-statusChan
+	report, ok = event.Data.(IgnitionReport)
+	if !ok || report.State != ignitionRunning || report.Voltage != 14.1 {
+		t.Errorf("Unexpected status event: %+v", event)
+	}
+
+	// Повторные измерения при работающем двигателе не вызывают инициализацию
+	monitor.ObserveAT(BatteryVoltageCommand, "14.2V")
+	if wakes != 1 {
+		t.Errorf("Expected no repeated wake-up, got %d", wakes)
+	}
+}
+
+func TestIgnitionLowVoltage(t *testing.T) {
+	monitor := NewIgnitionMonitor(IgnitionConfig{Enabled: true}, make(chan common.StatusEvent, 10), nil)
+	now := time.Now()
+
+	// Зажигание включено, двигатель заглушен: ЭБУ отвечают, опрос продолжается
+	monitor.ObserveAT(BatteryVoltageCommand, "12.4V")
+	monitor.Observe("41 0C 00 00", &Telemetry{PID: "0C", Value: 0})
+	if action := monitor.next(now); action != ignitionPoll {
+		t.Fatalf("Expected poll while ECU responds, got %v", action)
+	}
+
+	// ЭБУ замолчали при низком напряжении - стоянка без ожидания park_after
+	monitor.Observe("NO DATA", nil)
+	if action := monitor.next(now); action != ignitionSkip {
+		t.Fatalf("Expected parked on low voltage without ECU data, got %v", action)
+	}
+
+	// Ответ ЭБУ на запрос клиента на стоянке возобновляет опрос
+	monitor.Observe("41 0D 00", &Telemetry{PID: "0D", Value: 0})
+	if action := monitor.next(now.Add(time.Second)); action != ignitionPoll {
+		t.Errorf("Expected poll after ECU response, got %v", action)
+	}
+	if monitor.state != ignitionRunning {
+		t.Errorf("Expected running state, got %s", monitor.state)
+	}
+}
@@ -0,0 +1,112 @@
+package obd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"elm327-bridge/obd/decoder"
+)
+
+// Протоколы разбора ответов (параметр obd.protocol)
+const (
+	ProtocolOBD2  = "obd2"  // PID OBD-II легковых автомобилей (по умолчанию)
+	ProtocolJ1939 = "j1939" // Группы параметров SAE J1939 грузовых автомобилей и техники
+)
+
+// J1939InitCommands переключают ELM327 на J1939 (CAN 29 бит, 250 кбит/с) с заголовками,
+// по которым определяется группа ответа. Добавляются после команд инициализации адаптера
+var J1939InitCommands = []string{
+	"ATSPA",  // Протокол SAE J1939
+	"ATH1",   // Заголовки содержат PGN и адрес отправителя
+	"ATJHF0", // Заголовок выводится 29-битным идентификатором без форматирования
+}
+
+// j1939ReinitCommands восстанавливают связь после ее потери: автоматический поиск
+// протокола J1939 не выбирает, поэтому протокол задается явно
+var j1939ReinitCommands = []string{"ATPC", "ATSPA"}
+
+// j1939Mode включает разбор ответов как групп J1939 вместо PID OBD-II
+var j1939Mode bool
+
+// j1939PollPGNs содержит группы для периодического опроса в режиме J1939
+var j1939PollPGNs []uint32
+
+// SetProtocol выбирает протокол разбора ответов. В режиме J1939 периодически
+// запрашиваются группы pgns (пусто - все группы с известными параметрами), а опрос
+// DTC и тестов OBD-II выключается. Вызывается при запуске до старта парсера
+func SetProtocol(protocol string, pgns []string) error {
+	switch strings.ToLower(protocol) {
+	case "", ProtocolOBD2:
+		j1939Mode = false
+		return nil
+	case ProtocolJ1939:
+	default:
+		return fmt.Errorf("unknown protocol %q (expected %s or %s)", protocol, ProtocolOBD2, ProtocolJ1939)
+	}
+
+	poll := decoder.J1939PGNs()
+	if len(pgns) > 0 {
+		poll = make([]uint32, 0, len(pgns))
+		for _, text := range pgns {
+			pgn, err := strconv.ParseUint(strings.TrimSpace(text), 16, 32)
+			if err != nil || pgn > 0x3FFFF {
+				return fmt.Errorf("invalid J1939 PGN %q: expected hex, e.g. FEEE", text)
+			}
+			poll = append(poll, uint32(pgn))
+		}
+	}
+
+	j1939Mode = true
+	j1939PollPGNs = poll
+	logger.Printf("J1939 mode enabled, polling %d parameter groups", len(poll))
+	return nil
+}
+
+// J1939Enabled сообщает, включен ли режим J1939
+func J1939Enabled() bool {
+	return j1939Mode
+}
+
+// protocolReinitCommands возвращает команды переинициализации для выбранного протокола
+func protocolReinitCommands() []string {
+	if j1939Mode {
+		return j1939ReinitCommands
+	}
+	return reinitCommands
+}
+
+// j1939PollCommands возвращает запросы групп для цикла опроса
+func j1939PollCommands() []string {
+	commands := make([]string, 0, len(j1939PollPGNs))
+	for _, pgn := range j1939PollPGNs {
+		commands = append(commands, decoder.J1939Request(pgn))
+	}
+	return commands
+}
+
+// j1939Catalog возвращает каталог параметров J1939
+func j1939Catalog() []MetricInfo {
+	polled := make(map[uint32]bool, len(j1939PollPGNs))
+	for _, pgn := range j1939PollPGNs {
+		polled[pgn] = true
+	}
+
+	spns := decoder.J1939SPNs()
+	catalog := make([]MetricInfo, 0, len(spns))
+	for _, spn := range spns {
+		info := MetricInfo{
+			Metric:      spn.Name,
+			PID:         fmt.Sprintf("%04X", spn.PGN),
+			Source:      MetricSourceBuiltin,
+			Description: fmt.Sprintf("SAE J1939 SPN %d", spn.SPN),
+		}
+		if polled[spn.PGN] {
+			info.PollInterval = pollInterval.Seconds()
+		}
+		_, info.Unit = ConvertValue(0, spn.Unit)
+		info.Precision = catalogPrecision(spn.Name)
+		catalog = append(catalog, info)
+	}
+	return catalog
+}
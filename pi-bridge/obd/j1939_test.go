@@ -0,0 +1,66 @@
+package obd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSetProtocolJ1939(t *testing.T) {
+	defer SetProtocol(ProtocolOBD2, nil)
+
+	if err := SetProtocol("j1939", []string{"feee", "FEE5"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !J1939Enabled() {
+		t.Fatal("Expected J1939 mode")
+	}
+
+	if commands := pollCycleCommands(); !reflect.DeepEqual(commands, []string{"00FEEE", "00FEE5"}) {
+		t.Errorf("Unexpected poll commands: %v", commands)
+	}
+	if commands := diagnosticScanCommands(); len(commands) != 0 {
+		t.Errorf("Expected no OBD-II diagnostic scan, got %v", commands)
+	}
+	if commands := protocolReinitCommands(); !reflect.DeepEqual(commands, []string{"ATPC", "ATSPA"}) {
+		t.Errorf("Unexpected reinit commands: %v", commands)
+	}
+
+	telemetries, err := ParseResponses("18 FE EE 00 8C FF FF FF FF FF FF FF")
+	if err != nil || len(telemetries) != 1 || telemetries[0].Metric != "coolant_temperature" {
+		t.Errorf("Unexpected J1939 telemetry: %+v (%v)", telemetries, err)
+	}
+
+	polled := 0
+	for _, info := range Catalog() {
+		if info.Metric == "engine_rpm" && info.PID != "F004" {
+			t.Errorf("Expected J1939 catalog entry, got %+v", info)
+		}
+		if info.PollInterval > 0 {
+			polled++
+		}
+	}
+	if polled != 2 {
+		t.Errorf("Expected 2 polled parameters in catalog, got %d", polled)
+	}
+
+	if err := SetProtocol(ProtocolOBD2, nil); err != nil || J1939Enabled() {
+		t.Fatalf("Expected OBD-II mode, got error %v", err)
+	}
+	if telemetries, err := ParseResponses("41 0C 1A F0"); err != nil || telemetries[0].Metric != "engine_rpm" {
+		t.Errorf("Unexpected OBD-II telemetry: %+v (%v)", telemetries, err)
+	}
+}
+
+func TestSetProtocolErrors(t *testing.T) {
+	defer SetProtocol(ProtocolOBD2, nil)
+
+	if err := SetProtocol("j1850", nil); err == nil {
+		t.Error("Expected error for unknown protocol")
+	}
+	if err := SetProtocol(ProtocolJ1939, []string{"XYZ"}); err == nil {
+		t.Error("Expected error for invalid PGN")
+	}
+	if J1939Enabled() {
+		t.Error("Expected invalid configuration not to enable J1939 mode")
+	}
+}
@@ -0,0 +1,214 @@
+package obd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+// LowPowerCommand переводит ELM327 в режим пониженного энергопотребления.
+// Адаптер просыпается от любого символа, полученного по последовательному порту
+const LowPowerCommand = "ATLP"
+
+// Значения по умолчанию для режима пониженного энергопотребления
+const (
+	defaultLowPowerVoltage = 13.0             // Ниже - генератор не работает (двигатель заглушен)
+	defaultNoDataTimeout   = 5 * time.Minute  // ЭБУ не отвечают - зажигание выключено
+	defaultWakeInterval    = 10 * time.Minute // Период пробуждения для проверки автомобиля
+	lowPowerWakeWindow     = 30 * time.Second // После пробуждения ЭБУ должны ответить за это время
+)
+
+// Состояния режима пониженного энергопотребления
+const (
+	lowPowerActive   = "active"   // Автомобиль работает, опрос выполняется как обычно
+	lowPowerSleeping = "sleeping" // Адаптер в режиме ATLP, опрос приостановлен
+	lowPowerWaking   = "waking"   // Адаптер разбужен для проверки автомобиля
+)
+
+// LowPowerConfig задает перевод адаптера в режим пониженного энергопотребления, когда
+// автомобиль заглушен: при долгой стоянке адаптер не разряжает батарею
+type LowPowerConfig struct {
+	Enabled          bool          `yaml:"enabled"`           // Усыплять адаптер при заглушенном автомобиле
+	VoltageThreshold float64       `yaml:"voltage_threshold"` // Напряжение ATRV, ниже которого автомобиль заглушен (0 - 13.0 В)
+	NoDataTimeout    time.Duration `yaml:"no_data_timeout"`   // Время ответов NO DATA до засыпания (0 - 5 мин)
+	WakeInterval     time.Duration `yaml:"wake_interval"`     // Период пробуждения для проверки (0 - 10 мин)
+}
+
+// Validate проверяет параметры режима пониженного энергопотребления
+func (c LowPowerConfig) Validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.VoltageThreshold < 0 || c.VoltageThreshold > 30 {
+		return fmt.Errorf("invalid low power voltage threshold %v: expected 0-30 V", c.VoltageThreshold)
+	}
+	if c.NoDataTimeout < 0 {
+		return fmt.Errorf("invalid low power no_data_timeout %v", c.NoDataTimeout)
+	}
+	if c.WakeInterval < 0 {
+		return fmt.Errorf("invalid low power wake_interval %v", c.WakeInterval)
+	}
+	return nil
+}
+
+// LowPowerReport - состояние режима пониженного энергопотребления для публикации
+type LowPowerReport struct {
+	State  string    `json:"state"`            // active, sleeping, waking
+	Reason string    `json:"reason,omitempty"` // Причина перехода
+	Since  time.Time `json:"since"`            // Время перехода
+}
+
+// lowPowerAction - действие менеджера команд в текущем цикле опроса
+type lowPowerAction int
+
+const (
+	lowPowerPoll  lowPowerAction = iota // Обычный цикл опроса
+	lowPowerEnter                       // Отправить ATLP и пропустить цикл
+	lowPowerSkip                        // Адаптер спит, цикл пропускается
+	lowPowerWake                        // Разбудить адаптер: цикл опроса с запросом напряжения
+)
+
+// LowPowerMonitor определяет заглушенный автомобиль по низкому напряжению батареи (ATRV)
+// или по затянувшимся ответам NO DATA и усыпляет адаптер командой ATLP. Спящий адаптер
+// раз в wake_interval будится обычным циклом опроса: если ЭБУ ответили или напряжение
+// выросло, опрос возобновляется, иначе адаптер снова засыпает
+type LowPowerMonitor struct {
+	mu         sync.Mutex
+	config     LowPowerConfig
+	state      string
+	since      time.Time
+	noDataFrom time.Time // Начало ответов NO DATA без данных (ноль - ЭБУ отвечают)
+	voltage    float64   // Последнее напряжение ATRV (0 - неизвестно)
+	statusChan chan<- common.StatusEvent
+	logger     *log.Logger
+}
+
+// NewLowPowerMonitor создает монитор режима пониженного энергопотребления.
+// Для выключенного режима возвращает nil: методы nil монитора ничего не делают
+func NewLowPowerMonitor(config LowPowerConfig, statusChan chan<- common.StatusEvent) *LowPowerMonitor {
+	if !config.Enabled {
+		return nil
+	}
+	if config.VoltageThreshold == 0 {
+		config.VoltageThreshold = defaultLowPowerVoltage
+	}
+	if config.NoDataTimeout == 0 {
+		config.NoDataTimeout = defaultNoDataTimeout
+	}
+	if config.WakeInterval == 0 {
+		config.WakeInterval = defaultWakeInterval
+	}
+	return &LowPowerMonitor{
+		config:     config,
+		state:      lowPowerActive,
+		since:      time.Now(),
+		statusChan: statusChan,
+		logger:     log.New(os.Stdout, "[OBD-LowPower] ", log.LstdFlags|log.Lshortfile),
+	}
+}
+
+// Observe отслеживает ответы ЭБУ: данные означают работающий автомобиль, NO DATA -
+// начало отсчета no_data_timeout
+func (m *LowPowerMonitor) Observe(response string, telemetry *Telemetry) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if telemetry != nil {
+		m.noDataFrom = time.Time{}
+		m.resume("ECU responded")
+		return
+	}
+	if status, _, ok := DetectAdapterStatus(response); ok && status == AdapterStatusNoData && m.noDataFrom.IsZero() {
+		m.noDataFrom = time.Now()
+	}
+}
+
+// ObserveAT отслеживает напряжение батареи из ответов на ATRV (обработчик ответов на AT команды)
+func (m *LowPowerMonitor) ObserveAT(command, response string) {
+	if m == nil {
+		return
+	}
+	voltage, ok := ParseBatteryVoltage(command, response)
+	if !ok {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.voltage = voltage
+	if voltage >= m.config.VoltageThreshold {
+		m.resume(fmt.Sprintf("battery voltage %.1fV", voltage))
+	}
+}
+
+// next возвращает действие менеджера команд для цикла опроса в момент now
+func (m *LowPowerMonitor) next(now time.Time) lowPowerAction {
+	if m == nil {
+		return lowPowerPoll
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	switch m.state {
+	case lowPowerSleeping:
+		if now.Sub(m.since) < m.config.WakeInterval {
+			return lowPowerSkip
+		}
+		// Напряжение и ответы ЭБУ после пробуждения решают, засыпать ли снова
+		m.voltage = 0
+		m.noDataFrom = time.Time{}
+		m.transition(lowPowerWaking, "scheduled vehicle check", now)
+		return lowPowerWake
+	case lowPowerWaking:
+		if m.voltage > 0 && m.voltage < m.config.VoltageThreshold {
+			m.transition(lowPowerSleeping, m.lowVoltageReason(), now)
+			return lowPowerEnter
+		}
+		if now.Sub(m.since) >= lowPowerWakeWindow {
+			m.transition(lowPowerSleeping, fmt.Sprintf("no ECU response within %v after wake-up", lowPowerWakeWindow), now)
+			return lowPowerEnter
+		}
+		return lowPowerPoll
+	}
+
+	// Низкое напряжение при включенном зажигании и заглушенном двигателе - не повод
+	// засыпать, поэтому по напряжению адаптер засыпает только при молчащих ЭБУ
+	switch {
+	case m.voltage > 0 && m.voltage < m.config.VoltageThreshold && !m.noDataFrom.IsZero():
+		m.transition(lowPowerSleeping, m.lowVoltageReason(), now)
+		return lowPowerEnter
+	case !m.noDataFrom.IsZero() && now.Sub(m.noDataFrom) >= m.config.NoDataTimeout:
+		m.transition(lowPowerSleeping, fmt.Sprintf("NO DATA for %v", now.Sub(m.noDataFrom).Round(time.Second)), now)
+		return lowPowerEnter
+	}
+	return lowPowerPoll
+}
+
+// lowVoltageReason описывает засыпание по напряжению (вызывается под мьютексом)
+func (m *LowPowerMonitor) lowVoltageReason() string {
+	return fmt.Sprintf("battery voltage %.1fV below %.1fV", m.voltage, m.config.VoltageThreshold)
+}
+
+// resume возвращает обычный опрос после признаков работающего автомобиля (вызывается под мьютексом)
+func (m *LowPowerMonitor) resume(reason string) {
+	if m.state == lowPowerActive {
+		return
+	}
+	m.noDataFrom = time.Time{}
+	m.transition(lowPowerActive, reason, time.Now())
+}
+
+// transition меняет состояние и публикует его (вызывается под мьютексом)
+func (m *LowPowerMonitor) transition(state, reason string, now time.Time) {
+	m.state = state
+	m.since = now
+	m.logger.Printf("Low power state: %s (%s)", state, reason)
+	sendStatus("low_power", LowPowerReport{State: state, Reason: reason, Since: now}, m.statusChan, m.logger)
+}
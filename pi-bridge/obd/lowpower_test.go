@@ -0,0 +1,125 @@
+package obd
+
+import (
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+func TestLowPowerConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  LowPowerConfig
+		wantErr bool
+	}{
+		{"disabled", LowPowerConfig{VoltageThreshold: -1}, false},
+		{"defaults", LowPowerConfig{Enabled: true}, false},
+		{"custom", LowPowerConfig{Enabled: true, VoltageThreshold: 12.8, NoDataTimeout: time.Minute, WakeInterval: time.Hour}, false},
+		{"negative voltage", LowPowerConfig{Enabled: true, VoltageThreshold: -1}, true},
+		{"voltage too high", LowPowerConfig{Enabled: true, VoltageThreshold: 48}, true},
+		{"negative no data timeout", LowPowerConfig{Enabled: true, NoDataTimeout: -time.Second}, true},
+		{"negative wake interval", LowPowerConfig{Enabled: true, WakeInterval: -time.Second}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLowPowerDisabled(t *testing.T) {
+	monitor := NewLowPowerMonitor(LowPowerConfig{}, make(chan common.StatusEvent, 10))
+	if monitor != nil {
+		t.Fatal("Expected nil monitor when low power mode is disabled")
+	}
+
+	// Методы nil монитора безопасны и не влияют на опрос
+	monitor.Observe("NO DATA", nil)
+	monitor.ObserveAT(BatteryVoltageCommand, "11.9V")
+	if action := monitor.next(time.Now().Add(time.Hour)); action != lowPowerPoll {
+		t.Errorf("Expected poll action, got %v", action)
+	}
+}
+
+func TestLowPowerNoDataTimeout(t *testing.T) {
+	statusChan := make(chan common.StatusEvent, 10)
+	monitor := NewLowPowerMonitor(LowPowerConfig{Enabled: true, NoDataTimeout: time.Minute, WakeInterval: 10 * time.Minute}, statusChan)
+	start := time.Now()
+
+	monitor.Observe("NO DATA", nil)
+	if action := monitor.next(start.Add(30 * time.Second)); action != lowPowerPoll {
+		t.Fatalf("Expected poll before no_data_timeout, got %v", action)
+	}
+	if action := monitor.next(start.Add(2 * time.Minute)); action != lowPowerEnter {
+		t.Fatalf("Expected low power after no_data_timeout, got %v", action)
+	}
+
+	event := <-statusChan
+	report, ok := event.Data.(LowPowerReport)
+	if event.Kind != "low_power" || !ok || report.State != lowPowerSleeping {
+		t.Errorf("Unexpected status event: %+v", event)
+	}
+
+	// Спящий адаптер будится только по расписанию
+	if action := monitor.next(start.Add(5 * time.Minute)); action != lowPowerSkip {
+		t.Errorf("Expected skipped cycle while sleeping, got %v", action)
+	}
+	if action := monitor.next(start.Add(13 * time.Minute)); action != lowPowerWake {
+		t.Fatalf("Expected wake-up after wake_interval, got %v", action)
+	}
+
+	// ЭБУ ответил после пробуждения - опрос возобновляется
+	monitor.Observe("41 0C 1A F8", &Telemetry{PID: "0C", Value: 1726})
+	if action := monitor.next(start.Add(14 * time.Minute)); action != lowPowerPoll {
+		t.Errorf("Expected poll after ECU response, got %v", action)
+	}
+	if monitor.state != lowPowerActive {
+		t.Errorf("Expected active state, got %s", monitor.state)
+	}
+}
+
+func TestLowPowerVoltage(t *testing.T) {
+	monitor := NewLowPowerMonitor(LowPowerConfig{Enabled: true, VoltageThreshold: 13.0}, make(chan common.StatusEvent, 10))
+	now := time.Now()
+
+	// Зажигание включено, двигатель заглушен: ЭБУ отвечают, адаптер не засыпает
+	monitor.ObserveAT(BatteryVoltageCommand, "12.4V")
+	if action := monitor.next(now); action != lowPowerPoll {
+		t.Fatalf("Expected poll while ECU responds, got %v", action)
+	}
+
+	monitor.Observe("NO DATA", nil)
+	if action := monitor.next(now); action != lowPowerEnter {
+		t.Fatalf("Expected low power on low voltage without ECU data, got %v", action)
+	}
+
+	// Пробуждение с прежним низким напряжением - адаптер снова засыпает
+	wake := now.Add(defaultWakeInterval)
+	if action := monitor.next(wake); action != lowPowerWake {
+		t.Fatalf("Expected wake-up, got %v", action)
+	}
+	if action := monitor.next(wake.Add(time.Second)); action != lowPowerPoll {
+		t.Fatalf("Expected poll while waiting for the vehicle check, got %v", action)
+	}
+	monitor.ObserveAT(BatteryVoltageCommand, "12.3V")
+	if action := monitor.next(wake.Add(5 * time.Second)); action != lowPowerEnter {
+		t.Fatalf("Expected low power after low voltage check, got %v", action)
+	}
+
+	// Нет ответа после пробуждения - адаптер засыпает по окончании окна проверки
+	wake = wake.Add(5*time.Second + defaultWakeInterval)
+	monitor.next(wake)
+	if action := monitor.next(wake.Add(lowPowerWakeWindow)); action != lowPowerEnter {
+		t.Fatalf("Expected low power after silent wake-up, got %v", action)
+	}
+
+	// Двигатель запущен: напряжение генератора возвращает опрос
+	monitor.ObserveAT(BatteryVoltageCommand, "14.1V")
+	if monitor.state != lowPowerActive {
+		t.Errorf("Expected active state after charging voltage, got %s", monitor.state)
+	}
+}
@@ -0,0 +1,144 @@
+package obd
+
+import "fmt"
+
+// MonitorStatusCommand - запрос статуса мониторов (PID 01)
+const MonitorStatusCommand = "0101"
+
+// Тип зажигания двигателя (бит 3 байта B PID 01)
+const (
+	IgnitionSpark       = "spark"
+	IgnitionCompression = "compression"
+)
+
+// MonitorReadiness представляет готовность одного монитора бортовой диагностики
+type MonitorReadiness struct {
+	Name      string `json:"name"`
+	Available bool   `json:"available"` // Монитор поддерживается автомобилем
+	Complete  bool   `json:"complete"`  // Тест завершен с момента сброса DTC
+}
+
+// MonitorStatus представляет расшифрованный PID 01: состояние MIL, количество DTC
+// и готовность мониторов (SAE J1979)
+type MonitorStatus struct {
+	MIL      bool               `json:"mil"`
+	DTCCount int                `json:"dtc_count"`
+	Ignition string             `json:"ignition"`
+	Monitors []MonitorReadiness `json:"monitors"`
+	ECU      string             `json:"ecu,omitempty"`
+}
+
+// commonMonitors - мониторы, общие для всех двигателей: бит доступности в байте B
+// и бит незавершенности на 4 позиции старше
+var commonMonitors = []string{"misfire", "fuel_system", "components"}
+
+// sparkMonitors - мониторы бензиновых двигателей (биты 0-7 байтов C и D)
+var sparkMonitors = []string{
+	"catalyst",
+	"heated_catalyst",
+	"evaporative_system",
+	"secondary_air_system",
+	"", // Бит 4 зарезервирован (ранее A/C refrigerant)
+	"oxygen_sensor",
+	"oxygen_sensor_heater",
+	"egr_vvt_system",
+}
+
+// compressionMonitors - мониторы дизельных двигателей (биты 0-7 байтов C и D)
+var compressionMonitors = []string{
+	"nmhc_catalyst",
+	"nox_scr_monitor",
+	"", // Бит 2 зарезервирован
+	"boost_pressure",
+	"", // Бит 4 зарезервирован
+	"exhaust_gas_sensor",
+	"pm_filter",
+	"egr_vvt_system",
+}
+
+// DecodeMonitorStatus расшифровывает 4 байта данных PID 01
+func DecodeMonitorStatus(data []byte) (*MonitorStatus, error) {
+	if len(data) != 4 {
+		return nil, fmt.Errorf("PID 01: ожидалось 4 байта, получено %d", len(data))
+	}
+	a, b, c, d := data[0], data[1], data[2], data[3]
+
+	status := &MonitorStatus{
+		MIL:      a&0x80 != 0,
+		DTCCount: int(a & 0x7F),
+		Ignition: IgnitionSpark,
+	}
+
+	for i, name := range commonMonitors {
+		status.Monitors = append(status.Monitors, MonitorReadiness{
+			Name:      name,
+			Available: b&(1<<i) != 0,
+			Complete:  b&(1<<(i+4)) == 0,
+		})
+	}
+
+	specific := sparkMonitors
+	if b&0x08 != 0 {
+		status.Ignition = IgnitionCompression
+		specific = compressionMonitors
+	}
+
+	for i, name := range specific {
+		if name == "" {
+			continue
+		}
+		status.Monitors = append(status.Monitors, MonitorReadiness{
+			Name:      name,
+			Available: c&(1<<i) != 0,
+			Complete:  d&(1<<i) == 0,
+		})
+	}
+
+	return status, nil
+}
+
+// MonitorStatusFromTelemetry восстанавливает расшифровку из телеметрии PID 01,
+// значение которой содержит 4 байта данных как одно число
+func MonitorStatusFromTelemetry(telemetry *Telemetry) (*MonitorStatus, error) {
+	if telemetry.PID != "01" {
+		return nil, fmt.Errorf("not a monitor status PID: %s", telemetry.PID)
+	}
+
+	raw := uint32(telemetry.Value)
+	status, err := DecodeMonitorStatus([]byte{byte(raw >> 24), byte(raw >> 16), byte(raw >> 8), byte(raw)})
+	if err != nil {
+		return nil, err
+	}
+	status.ECU = telemetry.ECU
+	return status, nil
+}
+
+// readinessMetricPrefix - префикс метрик готовности отдельных мониторов
+const readinessMetricPrefix = "readiness_"
+
+// ReadinessTelemetries разворачивает готовность мониторов в отдельные метрики
+// readiness_<монитор> со значением 1 (тест завершен) или 0 (не завершен).
+// Неподдерживаемые автомобилем мониторы не публикуются: они не влияют на техосмотр
+func ReadinessTelemetries(status *MonitorStatus, source *Telemetry) []*Telemetry {
+	var telemetries []*Telemetry
+	for _, monitor := range status.Monitors {
+		if !monitor.Available {
+			continue
+		}
+
+		value := 0.0
+		if monitor.Complete {
+			value = 1
+		}
+		telemetries = append(telemetries, &Telemetry{
+			PID:       source.PID,
+			Metric:    readinessMetricPrefix + monitor.Name,
+			Value:     value,
+			Unit:      "bool",
+			Timestamp: source.Timestamp,
+			Raw:       source.Raw,
+			ECU:       source.ECU,
+		})
+	}
+	return telemetries
+}
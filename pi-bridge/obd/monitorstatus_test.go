@@ -0,0 +1,123 @@
+package obd
+
+import "testing"
+
+// readiness возвращает готовность монитора по имени
+func readiness(status *MonitorStatus, name string) (MonitorReadiness, bool) {
+	for _, monitor := range status.Monitors {
+		if monitor.Name == name {
+			return monitor, true
+		}
+	}
+	return MonitorReadiness{}, false
+}
+
+func TestDecodeMonitorStatus(t *testing.T) {
+	// MIL включен, 2 DTC; бензиновый двигатель; общие мониторы доступны, components не завершен;
+	// доступны catalyst, EVAP, O2 и нагреватель O2, EVAP не завершен
+	status, err := DecodeMonitorStatus([]byte{0x82, 0x47, 0x65, 0x04})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !status.MIL || status.DTCCount != 2 || status.Ignition != IgnitionSpark {
+		t.Errorf("Unexpected header fields: %+v", status)
+	}
+
+	tests := []struct {
+		name      string
+		available bool
+		complete  bool
+	}{
+		{"misfire", true, true},
+		{"fuel_system", true, true},
+		{"components", true, false},
+		{"catalyst", true, true},
+		{"heated_catalyst", false, true},
+		{"evaporative_system", true, false},
+		{"oxygen_sensor", true, true},
+		{"oxygen_sensor_heater", true, true},
+		{"egr_vvt_system", false, true},
+	}
+	for _, tt := range tests {
+		monitor, ok := readiness(status, tt.name)
+		if !ok {
+			t.Errorf("Monitor %s not decoded", tt.name)
+			continue
+		}
+		if monitor.Available != tt.available || monitor.Complete != tt.complete {
+			t.Errorf("%s: expected available=%v complete=%v, got %+v", tt.name, tt.available, tt.complete, monitor)
+		}
+	}
+}
+
+func TestDecodeMonitorStatusCompression(t *testing.T) {
+	status, err := DecodeMonitorStatus([]byte{0x00, 0x0F, 0x40, 0x40})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if status.MIL || status.DTCCount != 0 || status.Ignition != IgnitionCompression {
+		t.Errorf("Unexpected header fields: %+v", status)
+	}
+	if monitor, ok := readiness(status, "pm_filter"); !ok || !monitor.Available || monitor.Complete {
+		t.Errorf("Expected incomplete PM filter monitor, got %+v", monitor)
+	}
+	if _, ok := readiness(status, "catalyst"); ok {
+		t.Error("Spark ignition monitors must not be reported for compression engines")
+	}
+}
+
+func TestMonitorStatusFromTelemetry(t *testing.T) {
+	telemetry, err := ParseResponse("7E8 06 41 01 81 07 E5 00")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	status, err := MonitorStatusFromTelemetry(telemetry)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !status.MIL || status.DTCCount != 1 || status.ECU != "7E8" {
+		t.Errorf("Unexpected monitor status: %+v", status)
+	}
+
+	if _, err := DecodeMonitorStatus([]byte{0x00}); err == nil {
+		t.Error("Expected error for short data")
+	}
+}
+
+func TestReadinessTelemetries(t *testing.T) {
+	telemetry, err := ParseResponse("7E8 06 41 01 82 47 65 04")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	status, err := MonitorStatusFromTelemetry(telemetry)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := map[string]float64{
+		"readiness_misfire":              1,
+		"readiness_fuel_system":          1,
+		"readiness_components":           0,
+		"readiness_catalyst":             1,
+		"readiness_evaporative_system":   0,
+		"readiness_oxygen_sensor":        1,
+		"readiness_oxygen_sensor_heater": 1,
+	}
+
+	telemetries := ReadinessTelemetries(status, telemetry)
+	if len(telemetries) != len(expected) {
+		t.Fatalf("Expected %d readiness metrics (unsupported monitors skipped), got %d", len(expected), len(telemetries))
+	}
+	for _, readiness := range telemetries {
+		value, ok := expected[readiness.Metric]
+		if !ok {
+			t.Errorf("Unexpected readiness metric %s", readiness.Metric)
+			continue
+		}
+		if readiness.Value != value || readiness.Unit != "bool" || readiness.PID != "01" || readiness.ECU != "7E8" {
+			t.Errorf("Unexpected readiness telemetry: %+v", readiness)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package obd
+
+import (
+	"fmt"
+
+	"elm327-bridge/obd/decoder"
+)
+
+// NegativeResponse представляет отрицательный ответ ЭБУ (7F <сервис> <NRC>)
+type NegativeResponse struct {
+	ECU     string `json:"ecu,omitempty"` // Адрес ЭБУ (при включенных заголовках)
+	Service string `json:"service"`       // Сервис запроса, например "22"
+	NRC     string `json:"nrc"`           // Код отрицательного ответа, например "31"
+	Reason  string `json:"reason"`        // Расшифровка кода
+}
+
+// Error возвращает описание отрицательного ответа
+func (n *NegativeResponse) Error() string {
+	if n.ECU != "" {
+		return fmt.Sprintf("ECU %s rejected service %s: %s (NRC %s)", n.ECU, n.Service, n.Reason, n.NRC)
+	}
+	return fmt.Sprintf("service %s rejected: %s (NRC %s)", n.Service, n.Reason, n.NRC)
+}
+
+// DetectNegativeResponses находит отрицательные ответы в ответе ELM327.
+// Промежуточные ответы "response pending" (NRC 78) пропускаются
+func DetectNegativeResponses(response string) []*NegativeResponse {
+	messages, err := decoder.ReassembleResponse(response)
+	if err != nil {
+		return nil
+	}
+
+	var negatives []*NegativeResponse
+	for _, message := range messages {
+		payload := message.Payload
+		if len(payload) < 3 || payload[0] != decoder.NegativeResponseService || payload[2] == decoder.NRCResponsePending {
+			continue
+		}
+		negatives = append(negatives, &NegativeResponse{
+			ECU:     message.ECU,
+			Service: fmt.Sprintf("%02X", payload[1]),
+			NRC:     fmt.Sprintf("%02X", payload[2]),
+			Reason:  decoder.NRCDescription(payload[2]),
+		})
+	}
+	return negatives
+}
@@ -0,0 +1,56 @@
+package obd
+
+import (
+	"log"
+	"os"
+	"testing"
+)
+
+func TestDetectNegativeResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		expected []NegativeResponse
+	}{
+		{"No headers", "7F 22 31", []NegativeResponse{{Service: "22", NRC: "31", Reason: "request out of range"}}},
+		{"With CAN header", "7E8 03 7F 01 12", []NegativeResponse{{ECU: "7E8", Service: "01", NRC: "12", Reason: "sub-function not supported"}}},
+		{"Manufacturer specific", "7F 2E F3", []NegativeResponse{{Service: "2E", NRC: "F3", Reason: "vehicle manufacturer specific condition"}}},
+		{"Response pending skipped", "7F 22 78", nil},
+		{"Positive response", "41 0C 1A F8", nil},
+		{"Mixed ECUs", "7E8 04 41 0D 3C 00\r7E9 03 7F 01 11", []NegativeResponse{{ECU: "7E9", Service: "01", NRC: "11", Reason: "service not supported"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			negatives := DetectNegativeResponses(tt.response)
+			if len(negatives) != len(tt.expected) {
+				t.Fatalf("Expected %d negative responses, got %d: %v", len(tt.expected), len(negatives), negatives)
+			}
+			for i, negative := range negatives {
+				if *negative != tt.expected[i] {
+					t.Errorf("Expected %+v, got %+v", tt.expected[i], *negative)
+				}
+			}
+		})
+	}
+}
+
+func TestSendNegativeResponse(t *testing.T) {
+	commandResponsesChan := make(chan CommandResponse, 1)
+	logger := log.New(os.Stdout, "", 0)
+
+	negative := &NegativeResponse{ECU: "7E8", Service: "22", NRC: "31", Reason: "request out of range"}
+	sendNegativeResponse(negative, commandResponsesChan, logger)
+
+	response := <-commandResponsesChan
+	if response.Status != "error" {
+		t.Errorf("Expected error status, got %s", response.Status)
+	}
+	if response.Error != "ECU 7E8 rejected service 22: request out of range (NRC 31)" {
+		t.Errorf("Unexpected error message: %s", response.Error)
+	}
+
+	// Переполненный канал не блокирует парсер
+	commandResponsesChan <- response
+	sendNegativeResponse(negative, commandResponsesChan, logger)
+}
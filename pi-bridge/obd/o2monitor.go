@@ -0,0 +1,126 @@
+package obd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"elm327-bridge/obd/decoder"
+)
+
+// O2MonitorCommand - служебная команда моста для чтения результатов мониторинга
+// кислородных датчиков (сервис 05). Формат: "O2_MONITOR [датчик ...]", например "O2_MONITOR 01 02"
+const O2MonitorCommand = "O2_MONITOR"
+
+// o2MonitorService - байт ответа на сервис 05
+const o2MonitorService = 0x45
+
+// defaultO2MonitorSensors - датчики по умолчанию: до и после катализатора первого ряда
+var defaultO2MonitorSensors = []string{"01", "02"}
+
+// o2MonitorTest описывает стандартный тест сервиса 05 (SAE J1979)
+type o2MonitorTest struct {
+	name  string
+	scale float64
+	unit  string
+}
+
+// o2MonitorTests содержит стандартные тесты датчиков O2 по TID.
+// Тесты производителя (TID 80 и выше) публикуются без пересчета
+var o2MonitorTests = map[byte]o2MonitorTest{
+	0x01: {"rich_to_lean_threshold_voltage", 0.005, "V"},
+	0x02: {"lean_to_rich_threshold_voltage", 0.005, "V"},
+	0x03: {"low_switch_voltage", 0.005, "V"},
+	0x04: {"high_switch_voltage", 0.005, "V"},
+	0x05: {"rich_to_lean_switch_time", 0.004, "s"},
+	0x06: {"lean_to_rich_switch_time", 0.004, "s"},
+	0x07: {"min_sensor_voltage", 0.005, "V"},
+	0x08: {"max_sensor_voltage", 0.005, "V"},
+	0x09: {"time_between_transitions", 0.04, "s"},
+	0x0A: {"sensor_period", 0.04, "s"},
+}
+
+// o2MonitorSensors - датчики, опрашиваемые вместе с DTC (пусто - опрос выключен)
+var o2MonitorSensors []string
+
+// RegisterO2MonitorSensors проверяет и задает датчики O2 для периодического опроса
+// сервиса 05. Сервис поддерживают только автомобили без CAN (ISO 9141, KWP2000, J1850),
+// поэтому опрос включается явно. Вызывается при запуске до старта менеджера команд
+func RegisterO2MonitorSensors(sensors []string) error {
+	normalized, err := normalizeO2Sensors(sensors)
+	if err != nil {
+		return err
+	}
+	o2MonitorSensors = normalized
+	if len(normalized) > 0 {
+		logger.Printf("O2 sensor monitoring (service 05) enabled for sensors %v", normalized)
+	}
+	return nil
+}
+
+// normalizeO2Sensors проверяет номера датчиков: 01-08 (ряд 1 - датчики 1-4, ряд 2 - 5-8)
+func normalizeO2Sensors(sensors []string) ([]string, error) {
+	normalized := make([]string, 0, len(sensors))
+	for _, sensor := range sensors {
+		sensor = strings.ToUpper(strings.TrimSpace(sensor))
+		number, err := strconv.ParseUint(sensor, 16, 8)
+		if err != nil || len(sensor) != 2 || number < 1 || number > 8 {
+			return nil, fmt.Errorf("invalid O2 sensor %q: expected 01-08", sensor)
+		}
+		normalized = append(normalized, sensor)
+	}
+	return normalized, nil
+}
+
+// O2MonitorCommands формирует запросы стандартных тестов сервиса 05 для датчиков
+func O2MonitorCommands(sensors []string) []string {
+	commands := make([]string, 0, len(sensors)*len(o2MonitorTests))
+	for _, sensor := range sensors {
+		for tid := byte(0x01); tid <= 0x0A; tid++ {
+			commands = append(commands, fmt.Sprintf("05%02X%s", tid, sensor))
+		}
+	}
+	return commands
+}
+
+// DetectO2MonitorResults распознает ответ на сервис 05: "45 <TID> <датчик> <значение>",
+// для части тестов с пределами "45 <TID> <датчик> <значение> <min> <max>"
+func DetectO2MonitorResults(response string) ([]MonitorTestResults, bool) {
+	messages, err := decoder.ReassembleResponse(response)
+	if err != nil || len(messages) == 0 {
+		return nil, false
+	}
+
+	var results []MonitorTestResults
+	for _, message := range messages {
+		payload := message.Payload
+		if (len(payload) != 4 && len(payload) != 6) || payload[0] != o2MonitorService {
+			return nil, false
+		}
+
+		tid, sensor := payload[1], payload[2]
+		test, ok := o2MonitorTests[tid]
+		if !ok {
+			test = o2MonitorTest{scale: 1}
+		}
+
+		result := TestResult{
+			TID:   fmt.Sprintf("%02X", tid),
+			Name:  test.name,
+			Value: float64(payload[3]) * test.scale,
+			Unit:  test.unit,
+		}
+		if len(payload) == 6 {
+			result = result.withLimits(float64(payload[4])*test.scale, float64(payload[5])*test.scale)
+		}
+
+		// Номера датчиков сервиса 05 совпадают с OBDMID мониторов датчиков O2 сервиса 06
+		results = append(results, MonitorTestResults{
+			ECU:     message.ECU,
+			MID:     fmt.Sprintf("%02X", sensor),
+			Monitor: TestMonitorName(sensor),
+			Tests:   []TestResult{result},
+		})
+	}
+	return results, true
+}
@@ -0,0 +1,105 @@
+package obd
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"elm327-bridge/common"
+)
+
+func TestDetectO2MonitorResults(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		monitor  string
+		tid      string
+		value    float64
+		unit     string
+		limits   bool
+		passed   bool
+	}{
+		{"Threshold voltage", "45 01 01 5A", "o2_sensor_b1s1", "01", 0.45, "V", false, false},
+		{"Switch time with limits", "45 05 02 19 00 32", "o2_sensor_b1s2", "05", 0.1, "s", true, true},
+		{"Sensor period out of limits", "45 0A 05 64 0A 32", "o2_sensor_b2s1", "0A", 4, "s", true, false},
+		{"Manufacturer test", "45 81 01 07", "o2_sensor_b1s1", "81", 7, "", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, ok := DetectO2MonitorResults(tt.response)
+			if !ok || len(results) != 1 || len(results[0].Tests) != 1 {
+				t.Fatalf("Expected one O2 test result, got %+v (%v)", results, ok)
+			}
+			monitor, test := results[0], results[0].Tests[0]
+			if monitor.Monitor != tt.monitor || test.TID != tt.tid || test.Unit != tt.unit || math.Abs(test.Value-tt.value) > 0.001 {
+				t.Errorf("Unexpected result: %+v %+v", monitor, test)
+			}
+			if (test.Passed != nil) != tt.limits {
+				t.Fatalf("Expected limits %v, got %+v", tt.limits, test)
+			}
+			if tt.limits && *test.Passed != tt.passed {
+				t.Errorf("Expected passed %v, got %+v", tt.passed, test)
+			}
+		})
+	}
+
+	for _, response := range []string{"46 21 91 0B 00 1E 00 00 00 64", "45 01 01", "41 0C 1A F0"} {
+		if _, ok := DetectO2MonitorResults(response); ok {
+			t.Errorf("Expected %q not to be an O2 monitor result", response)
+		}
+	}
+}
+
+func TestRegisterO2MonitorSensors(t *testing.T) {
+	defer RegisterO2MonitorSensors(nil)
+
+	if err := RegisterO2MonitorSensors([]string{"01", "0a"}); err == nil {
+		t.Error("Expected error for sensor out of range")
+	}
+	if err := RegisterO2MonitorSensors([]string{"1"}); err == nil {
+		t.Error("Expected error for malformed sensor")
+	}
+
+	if err := RegisterO2MonitorSensors([]string{"05"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	commands := diagnosticScanCommands()
+	if len(commands) != 14 || commands[4] != "050105" || commands[13] != "050A05" {
+		t.Errorf("Unexpected diagnostic scan: %v", commands)
+	}
+}
+
+func TestTestResultsScannerO2(t *testing.T) {
+	statusChan := make(chan common.StatusEvent, 10)
+	scanner := NewTestResultsScanner(make(chan string, 10), statusChan)
+
+	commands, _, err := scanner.HandleO2Command(nil)
+	if err != nil || len(commands) != 20 || commands[0] != "050101" || commands[19] != "050A02" {
+		t.Errorf("Unexpected default commands: %v (%v)", commands, err)
+	}
+	if _, _, err := scanner.HandleO2Command([]string{"09"}); err == nil {
+		t.Error("Expected error for invalid sensor")
+	}
+
+	// Результаты датчика накапливаются, повторный тест заменяет предыдущий
+	scanner.Observe("45 02 01 5A", nil)
+	scanner.Observe("45 01 01 5A", nil)
+	scanner.Observe("45 01 01 64", nil)
+
+	var last common.StatusEvent
+	for i := 0; i < 3; i++ {
+		last = <-statusChan
+	}
+	results, ok := last.Data.(MonitorTestResults)
+	if !ok || last.Kind != "test_results/o2_sensor_b1s1" || !last.Retained {
+		t.Fatalf("Unexpected event: %+v", last)
+	}
+	var tids []string
+	for _, test := range results.Tests {
+		tids = append(tids, test.TID)
+	}
+	if !reflect.DeepEqual(tids, []string{"01", "02"}) || math.Abs(results.Tests[0].Value-0.5) > 0.001 {
+		t.Errorf("Unexpected accumulated results: %+v", results)
+	}
+}
@@ -1,384 +1,539 @@
 package obd
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"strconv"
-	"strings"
 	"time"
 
 	"elm327-bridge/common"
+	"elm327-bridge/obd/decoder"
 )
 
 var logger = log.New(os.Stdout, "[OBD-Parser] ", log.LstdFlags|log.Lshortfile)
 
+// SetLogOutput перенаправляет журнал парсера, например в io.Discard при нагрузочных тестах
+func SetLogOutput(w io.Writer) {
+	logger.SetOutput(w)
+}
+
 // Telemetry представляет декодированные данные телеметрии (используем общий тип)
 type Telemetry = common.Telemetry
 
 // CommandResponse представляет ответ на команду (используем общий тип)
 type CommandResponse = common.CommandResponse
 
-// PIDDecoder представляет функцию для декодирования конкретного PID
-type PIDDecoder func(data []byte) (float64, error)
-
-// pidDecoders содержит декодеры для различных PID
-var pidDecoders = map[string]PIDDecoder{
-	// Двигатель и производительность
-	"0C": decodeRPM,          // Обороты двигателя (Engine RPM)
-	"0D": decodeVehicleSpeed, // Скорость автомобиля (Vehicle Speed)
-	"05": decodeCoolantTemp,  // Температура охлаждающей жидкости (Engine Coolant Temperature)
-	"0F": decodeIntakeTemp,   // Температура всасываемого воздуха (Intake Air Temperature)
-	"11": decodeThrottlePos,  // Положение дроссельной заслонки (Throttle Position)
-	"04": decodeEngineLoad,   // Нагрузка двигателя (Calculated Engine Load)
-
-	// Топливо и эффективность
-	"2F": decodeFuelLevel,          // Уровень топлива (Fuel Level Input)
-	"0A": decodeFuelPressure,       // Давление топлива (Fuel Pressure)
-	"06": decodeShortTermFuelTrim1, // Короткий срок корректировки топлива Bank 1
-	"07": decodeLongTermFuelTrim1,  // Длинный срок корректировки топлива Bank 1
-
-	// Давление и температура
-	"0B": decodeIntakePressure,     // Давление во впускном коллекторе (Intake Manifold Pressure)
-	"33": decodeBarometricPressure, // Барометрическое давление (Barometric Pressure)
-
-	// Диагностика
-	"01": decodeMonitorStatus,   // Статус мониторинга DTC
-	"21": decodeDistanceWithMIL, // Расстояние с включенным MIL
-}
-
-// metricNames содержит человеко-читаемые названия метрик
-var metricNames = map[string]string{
-	"0C": "engine_rpm",
-	"0D": "vehicle_speed",
-	"05": "coolant_temperature",
-	"0F": "intake_air_temperature",
-	"11": "throttle_position",
-	"04": "engine_load",
-	"2F": "fuel_level",
-	"0A": "fuel_pressure",
-	"06": "short_term_fuel_trim_1",
-	"07": "long_term_fuel_trim_1",
-	"0B": "intake_manifold_pressure",
-	"33": "barometric_pressure",
-	"01": "monitor_status",
-	"21": "distance_with_mil",
-}
-
-// metricUnits содержит единицы измерения
-var metricUnits = map[string]string{
-	"0C": "rpm",
-	"0D": "km/h",
-	"05": "°C",
-	"0F": "°C",
-	"11": "%",
-	"04": "%",
-	"2F": "%",
-	"0A": "kPa",
-	"06": "%",
-	"07": "%",
-	"0B": "kPa",
-	"33": "kPa",
-	"01": "status",
-	"21": "km",
-}
+// PIDDecoder представляет функцию для декодирования конкретного PID (используем тип библиотеки)
+type PIDDecoder = decoder.PIDDecoder
 
-// Декодеры для конкретных PID
+// pidParser декодирует PID; таблица дополняется пользовательскими PID и плагинами при запуске
+var pidParser = decoder.New()
 
-// decodeRPM декодирует обороты двигателя (PID 0C)
-// Формула: ((A * 256) + B) / 4
-func decodeRPM(data []byte) (float64, error) {
-	if len(data) != 2 {
-		return 0, fmt.Errorf("PID 0C: ожидалось 2 байта, получено %d", len(data))
+// ParseResponse разбирает сырой ответ от ELM327 и возвращает первое декодированное значение
+func ParseResponse(response string) (*Telemetry, error) {
+	telemetries, err := ParseResponses(response)
+	if err != nil {
+		return nil, err
 	}
-	A := float64(data[0])
-	B := float64(data[1])
-	return ((A * 256) + B) / 4, nil
+	return telemetries[0], nil
 }
 
-// decodeVehicleSpeed декодирует скорость автомобиля (PID 0D)
-// Формула: A
-func decodeVehicleSpeed(data []byte) (float64, error) {
-	if len(data) != 1 {
-		return 0, fmt.Errorf("PID 0D: ожидался 1 байт, получено %d", len(data))
+// ParseResponses разбирает ответ ELM327, который может содержать строки от нескольких ЭБУ
+// (см. decoder.Parser.DecodeAll, в режиме J1939 - decoder.Parser.DecodeJ1939),
+// и записывает декодированные значения в журнал
+func ParseResponses(response string) ([]*Telemetry, error) {
+	decode := pidParser.DecodeAll
+	if j1939Mode {
+		decode = pidParser.DecodeJ1939
 	}
-	return float64(data[0]), nil
-}
-
-// decodeCoolantTemp декодирует температуру охлаждающей жидкости (PID 05)
-// Формула: A - 40
-func decodeCoolantTemp(data []byte) (float64, error) {
-	if len(data) != 1 {
-		return 0, fmt.Errorf("PID 05: ожидался 1 байт, получено %d", len(data))
+	telemetries, err := decode(response)
+	if err != nil {
+		return nil, err
 	}
-	return float64(data[0]) - 40, nil
-}
 
-// decodeIntakeTemp декодирует температуру всасываемого воздуха (PID 0F)
-// Формула: A - 40
-func decodeIntakeTemp(data []byte) (float64, error) {
-	if len(data) != 1 {
-		return 0, fmt.Errorf("PID 0F: ожидался 1 байт, получено %d", len(data))
+	for _, telemetry := range telemetries {
+		if telemetry.Data != "" {
+			logger.Printf("Passing through unsupported PID %s: %s", telemetry.PID, telemetry.Data)
+			continue
+		}
+		logger.Printf("Parsed telemetry: %s = %.2f %s", telemetry.Metric, telemetry.Value, telemetry.Unit)
 	}
-	return float64(data[0]) - 40, nil
+	return telemetries, nil
 }
 
-// decodeThrottlePos декодирует положение дроссельной заслонки (PID 11)
-// Формула: (A * 100) / 255
-func decodeThrottlePos(data []byte) (float64, error) {
-	if len(data) != 1 {
-		return 0, fmt.Errorf("PID 11: ожидался 1 байт, получено %d", len(data))
-	}
-	return (float64(data[0]) * 100) / 255, nil
+// getCurrentTimestamp возвращает текущий Unix timestamp
+func getCurrentTimestamp() int64 {
+	return time.Now().Unix()
 }
 
-// decodeEngineLoad декодирует нагрузку двигателя (PID 04)
-// Формула: (A * 100) / 255
-func decodeEngineLoad(data []byte) (float64, error) {
-	if len(data) != 1 {
-		return 0, fmt.Errorf("PID 04: ожидался 1 байт, получено %d", len(data))
-	}
-	return (float64(data[0]) * 100) / 255, nil
+// GetSupportedPIDs возвращает список поддерживаемых PID
+func GetSupportedPIDs() []string {
+	return pidParser.PIDs()
 }
 
-// decodeFuelLevel декодирует уровень топлива (PID 2F)
-// Формула: (A * 100) / 255
-func decodeFuelLevel(data []byte) (float64, error) {
-	if len(data) != 1 {
-		return 0, fmt.Errorf("PID 2F: ожидался 1 байт, получено %d", len(data))
-	}
-	return (float64(data[0]) * 100) / 255, nil
+// GetMetricName возвращает название метрики для PID
+func GetMetricName(pid string) string {
+	return pidParser.MetricName(pid)
 }
 
-// decodeFuelPressure декодирует давление топлива (PID 0A)
-// Формула: A * 3
-func decodeFuelPressure(data []byte) (float64, error) {
-	if len(data) != 1 {
-		return 0, fmt.Errorf("PID 0A: ожидался 1 байт, получено %d", len(data))
-	}
-	return float64(data[0]) * 3, nil
+// GetMetricUnit возвращает единицу измерения для PID
+func GetMetricUnit(pid string) string {
+	return pidParser.MetricUnit(pid)
 }
 
-// decodeShortTermFuelTrim1 декодирует короткий срок корректировки топлива Bank 1 (PID 06)
-// Формула: (A - 128) * 100 / 128
-func decodeShortTermFuelTrim1(data []byte) (float64, error) {
-	if len(data) != 1 {
-		return 0, fmt.Errorf("PID 06: ожидался 1 байт, получено %d", len(data))
-	}
-	return (float64(data[0]) - 128) * 100 / 128, nil
+// ResponseObserver получает каждый ответ ELM327 после разбора.
+// telemetry равен nil, если ответ не удалось декодировать как PID
+type ResponseObserver interface {
+	Observe(response string, telemetry *Telemetry)
 }
 
-// decodeLongTermFuelTrim1 декодирует длинный срок корректировки топлива Bank 1 (PID 07)
-// Формула: (A - 128) * 100 / 128
-func decodeLongTermFuelTrim1(data []byte) (float64, error) {
-	if len(data) != 1 {
-		return 0, fmt.Errorf("PID 07: ожидался 1 байт, получено %d", len(data))
-	}
-	return (float64(data[0]) - 128) * 100 / 128, nil
+// DTCObserver получает коды неисправностей из ответов на запрос DTC (сервис 03)
+type DTCObserver interface {
+	ObserveDTCs(reports []DTCReport) []string
 }
 
-// decodeIntakePressure декодирует давление во впускном коллекторе (PID 0B)
-// Формула: A
-func decodeIntakePressure(data []byte) (float64, error) {
-	if len(data) != 1 {
-		return 0, fmt.Errorf("PID 0B: ожидался 1 байт, получено %d", len(data))
-	}
-	return float64(data[0]), nil
-}
+// StartParser запускает горутину для парсинга ответов от ELM327 и работает до отмены ctx.
+// Ответы на команды клиентов MQTT сопоставляются через реестр requests (может быть nil)
+func StartParser(ctx context.Context, responsesChan <-chan string, telemetryChan chan<- Telemetry, commandResponsesChan chan CommandResponse, health *BusHealth, topology *Topology, streamer *Streamer, requests *common.PendingRequests, statusChan chan<- common.StatusEvent, observers ...ResponseObserver) {
+	logger.Println("Starting OBD parser")
 
-// decodeBarometricPressure декодирует барометрическое давление (PID 33)
-// Формула: A
-func decodeBarometricPressure(data []byte) (float64, error) {
-	if len(data) != 1 {
-		return 0, fmt.Errorf("PID 33: ожидался 1 байт, получено %d", len(data))
-	}
-	return float64(data[0]), nil
-}
+	for {
+		select {
+		case <-ctx.Done():
+			logger.Println("OBD parser stopped")
+			return
+		case response, ok := <-responsesChan:
+			if !ok {
+				logger.Println("Responses channel closed")
+				return
+			}
 
-// decodeMonitorStatus декодирует статус мониторинга (PID 01)
-// Это битовая карта, возвращаем как сырое значение
-func decodeMonitorStatus(data []byte) (float64, error) {
-	if len(data) != 4 {
-		return 0, fmt.Errorf("PID 01: ожидалось 4 байта, получено %d", len(data))
-	}
-	// Конвертируем 4 байта в одно число для простоты
-	return float64(data[0])*256*256*256 + float64(data[1])*256*256 + float64(data[2])*256 + float64(data[3]), nil
-}
+			// Запрос клиента, на который пришел ответ (nil для опроса PID)
+			request := requests.Resolve(response)
 
-// decodeDistanceWithMIL декодирует расстояние с включенным MIL (PID 21)
-// Формула: (A * 256) + B
-func decodeDistanceWithMIL(data []byte) (float64, error) {
-	if len(data) != 2 {
-		return 0, fmt.Errorf("PID 21: ожидалось 2 байта, получено %d", len(data))
-	}
-	return float64(data[0])*256 + float64(data[1]), nil
-}
+			// Проверяем индикаторы ошибок шины до разбора данных
+			if indicator, source, isErr := DetectBusError(response); isErr {
+				health.RecordError(indicator, source)
+				logger.Printf("Bus error detected: %s (source: %s)", indicator, source)
+				sendStatus("bus_health", health.Report(), statusChan, logger)
+				requests.Finish(request, response, fmt.Errorf("bus error: %s", indicator))
+				continue
+			}
 
-// ParseResponse разбирает сырой ответ от ELM327
-func ParseResponse(response string) (*Telemetry, error) {
-	// Очищаем ответ от лишних символов
-	response = strings.TrimSpace(response)
+			// Текстовые статусы адаптера (NO DATA, STOPPED, UNABLE TO CONNECT) не являются
+			// ошибками разбора: публикуем их и передаем менеджеру команд через состояние шины
+			if status, source, isStatus := DetectAdapterStatus(response); isStatus {
+				report := health.RecordStatus(status, source)
+				logger.Printf("Adapter status: %s (action: %s)", status, report.Action)
+				sendStatus("adapter_status", report, statusChan, logger)
+				sendStatus("bus_health", health.Report(), statusChan, logger)
+				for _, observer := range observers {
+					observer.Observe(response, nil)
+				}
+				requests.Finish(request, report, fmt.Errorf("adapter replied %s", status))
+				streamer.StopOnFailure(request, response)
+				continue
+			}
 
-	// Проверяем формат ответа ELM327 (должен начинаться с 4x)
-	if len(response) < 5 || !strings.HasPrefix(response, "4") {
-		return nil, fmt.Errorf("invalid response format: %s", response)
-	}
+			// Регистрируем ответившие модули (ответы с заголовками CAN)
+			if topology.Observe(response) {
+				logger.Println("Vehicle network topology changed")
+				sendStatus("topology", topology.Modules(), statusChan, logger)
+			}
 
-	// Разбираем ответ: формат "41 0C 1A F0"
-	parts := strings.Fields(response)
-	if len(parts) < 3 {
-		return nil, fmt.Errorf("response too short: %s", response)
-	}
+			// Отрицательные ответы ЭБУ публикуются как ошибки команд, а не ошибки разбора
+			if negatives := DetectNegativeResponses(response); len(negatives) > 0 {
+				for _, negative := range negatives {
+					logger.Printf("Negative response: %v", negative)
+				}
+				if request != nil {
+					requests.Finish(request, negativeResult(negatives), negatives[0])
+				} else {
+					for _, negative := range negatives {
+						sendNegativeResponse(negative, commandResponsesChan, logger)
+					}
+				}
+				streamer.StopOnFailure(request, response)
+				continue
+			}
 
-	// Извлекаем компоненты
-	echo := parts[0]       // "41" (эхо сервиса)
-	pid := parts[1]        // "0C" (PID)
-	dataParts := parts[2:] // Данные
+			// Коды неисправностей публикуются списком и передаются наблюдателям DTC
+			if reports, isDTC := DetectDTCResponse(response); isDTC {
+				logger.Printf("DTC response: %v", dtcCodes(reports))
+				sendStatus("dtc", reports, statusChan, logger)
+				requests.Finish(request, reports, nil)
+				for _, observer := range observers {
+					if dtcObserver, ok := observer.(DTCObserver); ok {
+						dtcObserver.ObserveDTCs(reports)
+					}
+					observer.Observe(response, nil)
+				}
+				continue
+			}
 
-	// Проверяем эхо (должен быть "4x" где x - сервис)
-	if len(echo) != 2 || echo[0] != '4' {
-		return nil, fmt.Errorf("invalid echo format: %s", echo)
-	}
+			// Постоянные коды публикуются отдельно: они не стираются сервисом 04 и не
+			// вызывают снимок неисправности, поэтому наблюдателям DTC не передаются
+			if reports, isPermanent := DetectPermanentDTCResponse(response); isPermanent {
+				logger.Printf("Permanent DTC response: %v", dtcCodes(reports))
+				sendStatus("permanent_dtc", reports, statusChan, logger)
+				requests.Finish(request, reports, nil)
+				for _, observer := range observers {
+					observer.Observe(response, nil)
+				}
+				continue
+			}
 
-	// Проверяем PID
-	if len(pid) != 2 {
-		return nil, fmt.Errorf("invalid PID format: %s", pid)
-	}
+			// Список поддерживаемых мониторов бортовых тестов (сервис 06) нужен только
+			// обработчику опроса, который запрашивает результаты каждого монитора
+			if mids, isSupported := SupportedTestMIDs(response); isSupported {
+				logger.Printf("Supported test monitors: %v", mids)
+				requests.Finish(request, mids, nil)
+				for _, observer := range observers {
+					observer.Observe(response, nil)
+				}
+				continue
+			}
 
-	// Конвертируем данные из hex в байты
-	data := make([]byte, len(dataParts))
-	for i, part := range dataParts {
-		val, err := strconv.ParseUint(part, 16, 8)
-		if err != nil {
-			return nil, fmt.Errorf("invalid hex data %s: %v", part, err)
-		}
-		data[i] = byte(val)
-	}
+			// Результаты бортовых тестов публикуются по мониторам и отдельными метриками
+			if results, isTest := DetectTestResults(response); isTest {
+				for _, monitor := range results {
+					logger.Printf("Test results for %s: %d tests", monitor.Monitor, len(monitor.Tests))
+					sendStatus("test_results/"+monitor.Monitor, monitor, statusChan, logger)
+					publishTestResultTelemetries(monitor, telemetryChan)
+				}
+				requests.Finish(request, results, nil)
+				for _, observer := range observers {
+					observer.Observe(response, nil)
+				}
+				continue
+			}
 
-	// Декодируем данные
-	decoder, exists := pidDecoders[pid]
-	if !exists {
-		return nil, fmt.Errorf("unsupported PID: %s", pid)
-	}
+			// Результаты мониторинга датчиков O2 (сервис 05) публикуются метриками, а полный
+			// набор тестов датчика собирает и публикует обработчик опроса бортовых тестов
+			if results, isO2 := DetectO2MonitorResults(response); isO2 {
+				for _, monitor := range results {
+					publishTestResultTelemetries(monitor, telemetryChan)
+				}
+				requests.Finish(request, results, nil)
+				for _, observer := range observers {
+					observer.Observe(response, nil)
+				}
+				continue
+			}
 
-	value, err := decoder(data)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode PID %s: %v", pid, err)
-	}
+			// Парсим ответ: при включенных заголовках он может содержать строки от нескольких ЭБУ
+			telemetries, err := ParseResponses(response)
 
-	// Получаем метаданные
-	metric, exists := metricNames[pid]
-	if !exists {
-		metric = "unknown_" + pid
+			// Показания вне допустимого диапазона помечаются или отбрасываются до наблюдателей
+			telemetries, dropped := CheckPlausibility(telemetries)
+			for _, telemetry := range dropped {
+				logger.Printf("Dropped implausible value: %s = %.2f %s", telemetry.Metric, telemetry.Value, telemetry.Unit)
+			}
+			if len(dropped) > 0 && len(telemetries) == 0 {
+				requests.Finish(request, telemetryResult(dropped), fmt.Errorf("implausible value of %s", dropped[0].Metric))
+				continue
+			}
+
+			if len(telemetries) == 0 {
+				for _, observer := range observers {
+					observer.Observe(response, nil)
+				}
+			}
+			for _, telemetry := range telemetries {
+				for _, observer := range observers {
+					observer.Observe(response, telemetry)
+				}
+			}
+
+			// Наблюдатели получают метрические значения, публикуются - в выбранной системе единиц
+			for _, telemetry := range telemetries {
+				ConvertTelemetry(telemetry)
+			}
+
+			if err != nil {
+				logger.Printf("Failed to parse response %q: %v", response, err)
+				requests.Finish(request, response, err)
+				streamer.StopOnFailure(request, response)
+				continue
+			}
+
+			requests.Finish(request, telemetryResult(telemetries), nil)
+
+			if health.RecordSuccess() {
+				sendStatus("bus_health", health.Report(), statusChan, logger)
+			}
+
+			streamed := false
+			for _, telemetry := range telemetries {
+				if pid, streaming := streamer.Active(); streaming && pid == telemetry.PID {
+					telemetry.HighRate = true
+					streamed = true
+				}
+
+				// Значения стоп-кадра нужны только наблюдателям и не являются текущей телеметрией
+				if telemetry.FreezeFrame {
+					continue
+				}
+
+				// Отправляем в канал телеметрии
+				select {
+				case telemetryChan <- *telemetry:
+					logger.Printf("Telemetry sent: %s = %.2f %s", telemetry.Metric, telemetry.Value, telemetry.Unit)
+				default:
+					logger.Printf("Warning: telemetry channel is full, dropping: %s", telemetry.Metric)
+				}
+
+				// Битовая карта PID 01 дополнительно публикуется в расшифрованном виде
+				// и отдельными метриками готовности мониторов
+				if telemetry.PID == "01" {
+					if status, err := MonitorStatusFromTelemetry(telemetry); err == nil {
+						sendStatus("monitor_status", status, statusChan, logger)
+						for _, readiness := range ReadinessTelemetries(status, telemetry) {
+							select {
+							case telemetryChan <- *readiness:
+							default:
+								logger.Printf("Warning: telemetry channel is full, dropping: %s", readiness.Metric)
+							}
+						}
+					}
+				}
+			}
+
+			// В потоковом режиме сразу запрашиваем следующий отсчет (один раз на ответ)
+			if streamed {
+				streamer.OnSample()
+			}
+		}
 	}
+}
 
-	unit, exists := metricUnits[pid]
-	if !exists {
-		unit = "unknown"
+// sendStatus отправляет retained служебное событие в канал статусов (неблокирующе)
+func sendStatus(kind string, data interface{}, statusChan chan<- common.StatusEvent, logger *log.Logger) {
+	event := common.StatusEvent{
+		Kind:      kind,
+		Data:      data,
+		Retained:  true,
+		Timestamp: time.Now(),
 	}
 
-	// Создаем структуру телеметрии
-	telemetry := &Telemetry{
-		PID:       pid,
-		Metric:    metric,
-		Value:     value,
-		Unit:      unit,
-		Timestamp: getCurrentTimestamp(),
-		Raw:       response,
+	select {
+	case statusChan <- event:
+	default:
+		logger.Printf("Warning: status channel is full, dropping %s event", kind)
 	}
+}
 
-	logger.Printf("Parsed telemetry: %s = %.2f %s", metric, value, unit)
-	return telemetry, nil
+// publishTestResultTelemetries отправляет значения бортовых тестов монитора в канал телеметрии
+func publishTestResultTelemetries(results MonitorTestResults, telemetryChan chan<- Telemetry) {
+	for _, telemetry := range TestResultTelemetries(results) {
+		ConvertTelemetry(telemetry)
+		select {
+		case telemetryChan <- *telemetry:
+		default:
+			logger.Printf("Warning: telemetry channel is full, dropping: %s", telemetry.Metric)
+		}
+	}
 }
 
-// getCurrentTimestamp возвращает текущий Unix timestamp
-func getCurrentTimestamp() int64 {
-	return time.Now().Unix()
+// telemetryResult возвращает результат команды: значение одного ЭБУ или список ответов нескольких
+func telemetryResult(telemetries []*Telemetry) interface{} {
+	if len(telemetries) == 1 {
+		return telemetries[0]
+	}
+	return telemetries
 }
 
-// GetSupportedPIDs возвращает список поддерживаемых PID
-func GetSupportedPIDs() []string {
-	pids := make([]string, 0, len(pidDecoders))
-	for pid := range pidDecoders {
-		pids = append(pids, pid)
+// negativeResult возвращает отрицательный ответ одного ЭБУ или список ответов нескольких
+func negativeResult(negatives []*NegativeResponse) interface{} {
+	if len(negatives) == 1 {
+		return negatives[0]
 	}
-	return pids
+	return negatives
 }
 
-// GetMetricName возвращает название метрики для PID
-func GetMetricName(pid string) string {
-	if name, exists := metricNames[pid]; exists {
-		return name
+// sendNegativeResponse отправляет отрицательный ответ ЭБУ как ошибку команды (без блокировки)
+func sendNegativeResponse(negative *NegativeResponse, commandResponsesChan chan<- CommandResponse, logger *log.Logger) {
+	response := CommandResponse{
+		Status:    "error",
+		Result:    negative,
+		Error:     negative.Error(),
+		Timestamp: time.Now(),
+	}
+
+	select {
+	case commandResponsesChan <- response:
+	default:
+		logger.Println("Warning: command responses channel is full, dropping negative response")
 	}
-	return "unknown_" + pid
 }
 
-// GetMetricUnit возвращает единицу измерения для PID
-func GetMetricUnit(pid string) string {
-	if unit, exists := metricUnits[pid]; exists {
-		return unit
+// diagnosticScanCommands возвращает запросы периодического опроса диагностики.
+// Сервисы OBD-II не поддерживаются в J1939, поэтому в этом режиме опрос пуст
+func diagnosticScanCommands() []string {
+	if j1939Mode {
+		return nil
 	}
-	return "unknown"
+	commands := []string{ReadDTCCommand, ReadPermanentDTCCommand, MonitorStatusCommand, SupportedTestMIDsCommand}
+	return append(commands, O2MonitorCommands(o2MonitorSensors)...)
 }
 
-// StartParser запускает горутину для парсинга ответов от ELM327
-func StartParser(responsesChan <-chan string, telemetryChan chan<- interface{}, commandResponsesChan chan CommandResponse) {
-	logger := log.New(os.Stdout, "[OBD-Parser] ", log.LstdFlags|log.Lshortfile)
-	logger.Println("Starting OBD parser")
+// StartCommandManager запускает менеджер команд для периодического опроса PID.
+// Коды неисправностей (сохраненные и постоянные), статус мониторов и результаты
+// бортовых тестов запрашиваются раз в dtcScanInterval (0 - выключено).
+// Пока автомобиль заглушен, lowPower (может быть nil) усыпляет адаптер и приостанавливает опрос.
+// После потери связи с шиной protocols (может быть nil) выбирает следующий протокол.
+// Менеджер работает до отмены ctx
+func StartCommandManager(ctx context.Context, commandsChan chan<- string, health *BusHealth, streamer *Streamer, sniffer *Sniffer, lowPower *LowPowerMonitor, ignition *IgnitionMonitor, protocols *ProtocolFallback, dtcScanInterval time.Duration) {
+	logger := log.New(os.Stdout, "[OBD-CommandManager] ", log.LstdFlags|log.Lshortfile)
+	logger.Println("Starting command manager")
+
+	var lastDTCScan, lastVoltageRead time.Time
 
 	for {
-		select {
-		case response, ok := <-responsesChan:
-			if !ok {
-				logger.Println("Responses channel closed")
-				return
-			}
+		// Интервал увеличивается при повторяющихся ошибках шины
+		interval := health.PollInterval(pollInterval)
+		if interval != pollInterval {
+			logger.Printf("Bus errors detected, polling backed off to %v", interval)
+		}
+		if !sleep(ctx, interval) {
+			logger.Println("Command manager stopped")
+			return
+		}
 
-			// Парсим ответ
-			telemetry, err := ParseResponse(response)
-			if err != nil {
-				logger.Printf("Failed to parse response %q: %v", response, err)
-				continue
+		// Ответы предыдущего цикла получены за время паузы: цикл без ошибок
+		// приближает снятие отступа
+		health.EndCycle()
+
+		// После потери связи с шиной протокол определяется заново
+		if health.TakeReinit() {
+			logger.Println("Bus connection lost, re-initializing protocol")
+			for _, command := range protocols.ReinitCommands() {
+				if !sendCommand(ctx, commandsChan, command) {
+					return
+				}
 			}
+		}
+
+		// Потоковый режим занимает адаптер целиком, пропускаем цикл опроса
+		if pid, streaming := streamer.Active(); streaming {
+			logger.Printf("Streaming PID %s, skipping poll cycle", pid)
+			continue
+		}
+
+		// Любая команда прерывает режим мониторинга, поэтому опрос ждет окончания прослушивания
+		if sniffer.Active() {
+			continue
+		}
 
-			// Отправляем в канал телеметрии
+		// Стоянка: вместо опроса PID редко запрашивается напряжение, по росту которого
+		// определяется запуск двигателя
+		switch ignition.next(time.Now()) {
+		case ignitionCheck:
+			lastVoltageRead = time.Now()
 			select {
-			case telemetryChan <- telemetry:
-				logger.Printf("Telemetry sent: %s = %.2f %s", telemetry.Metric, telemetry.Value, telemetry.Unit)
+			case commandsChan <- BatteryVoltageCommand:
 			default:
-				logger.Printf("Warning: telemetry channel is full, dropping: %s", telemetry.Metric)
+				logger.Printf("Warning: commands channel is full, skipping: %s", BatteryVoltageCommand)
 			}
+			continue
+		case ignitionSkip:
+			continue
 		}
-	}
-}
-
-// StartCommandManager запускает менеджер команд для периодического опроса PID
-func StartCommandManager(commandsChan chan<- string) {
-	logger := log.New(os.Stdout, "[OBD-CommandManager] ", log.LstdFlags|log.Lshortfile)
-	logger.Println("Starting command manager")
-
-	// Список PID для периодического опроса
-	pids := []string{"0C", "0D", "05", "0F", "11", "04", "2F", "0A", "0B", "33"}
 
-	ticker := time.NewTicker(5 * time.Second) // Опрос каждые 5 секунд
-	defer ticker.Stop()
+		// Заглушенный автомобиль: адаптер засыпает и будится по расписанию для проверки.
+		// Команда ATLP не пропускается, иначе опрос остановится при бодрствующем адаптере
+		switch lowPower.next(time.Now()) {
+		case lowPowerEnter:
+			if !sendCommand(ctx, commandsChan, LowPowerCommand) {
+				return
+			}
+			logger.Println("Vehicle is off, adapter switched to low power mode")
+			continue
+		case lowPowerSkip:
+			continue
+		case lowPowerWake:
+			// Первая команда цикла будит адаптер, напряжение запрашивается сразу
+			logger.Println("Waking adapter to check the vehicle")
+			lastVoltageRead = time.Now()
+			if !sendCommand(ctx, commandsChan, BatteryVoltageCommand) {
+				return
+			}
+		}
 
-	for {
-		select {
-		case <-ticker.C:
-			// Отправляем команды для опроса PID
-			for _, pid := range pids {
-				command := fmt.Sprintf("01%s", pid) // Сервис 01 + PID
+		// Напряжение батареи измеряет адаптер, оно доступно и без ответа ЭБУ
+		if batteryVoltageInterval > 0 && time.Since(lastVoltageRead) >= batteryVoltageInterval {
+			lastVoltageRead = time.Now()
+			select {
+			case commandsChan <- BatteryVoltageCommand:
+				logger.Printf("Sent command: %s", BatteryVoltageCommand)
+			default:
+				logger.Printf("Warning: commands channel is full, skipping: %s", BatteryVoltageCommand)
+			}
+		}
 
+		// Периодический опрос DTC: новые коды вызывают снимок неисправности.
+		// Готовность мониторов и результаты бортовых тестов меняются так же редко
+		// и запрашиваются вместе с ним
+		if dtcScanInterval > 0 && time.Since(lastDTCScan) >= dtcScanInterval {
+			lastDTCScan = time.Now()
+			for _, command := range diagnosticScanCommands() {
 				select {
 				case commandsChan <- command:
-					logger.Printf("Sent command: %s", command)
+					logger.Printf("Sent diagnostic scan: %s", command)
 				default:
 					logger.Printf("Warning: commands channel is full, skipping: %s", command)
 				}
+			}
+		}
 
-				time.Sleep(100 * time.Millisecond) // Пауза между командами
+		// Отправляем команды для опроса PID
+		for _, command := range pollCycleCommands() {
+			if isHeaderCommand(command) {
+				// Смену заголовка нельзя пропустить, иначе следующие запросы
+				// уйдут не тому блоку управления
+				if !sendCommand(ctx, commandsChan, command) {
+					return
+				}
+				logger.Printf("Switched header: %s", command)
+				continue
+			}
+
+			select {
+			case commandsChan <- command:
+				logger.Printf("Sent command: %s", command)
+			default:
+				logger.Printf("Warning: commands channel is full, skipping: %s", command)
+			}
+
+			// Пауза между командами
+			if !sleep(ctx, 100*time.Millisecond) {
+				return
 			}
 		}
 	}
 }
+
+// sendCommand передает команду, которую нельзя пропустить, дожидаясь места в канале.
+// Возвращает false, если ctx отменен раньше
+func sendCommand(ctx context.Context, commandsChan chan<- string, command string) bool {
+	select {
+	case commandsChan <- command:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// sleep выдерживает паузу d. Возвращает false, если ctx отменен раньше
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
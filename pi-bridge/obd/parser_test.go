@@ -1,7 +1,13 @@
 package obd
 
 import (
+	"context"
+	"io"
+	"os"
 	"testing"
+	"time"
+
+	"elm327-bridge/common"
 )
 
 func TestParseResponse(t *testing.T) {
@@ -44,8 +50,8 @@ func TestParseResponse(t *testing.T) {
 			expectError: true,
 		},
 		{
-			name:        "Unsupported PID",
-			response:    "41 FF 12 34",
+			name:        "Unsupported PID of other service",
+			response:    "49 FF 12 34",
 			expectError: true,
 		},
 	}
@@ -81,139 +87,17 @@ func TestParseResponse(t *testing.T) {
 	}
 }
 
-func TestDecodeRPM(t *testing.T) {
-	tests := []struct {
-		data     []byte
-		expected float64
-		hasError bool
-	}{
-		{[]byte{0x1A, 0xF0}, 1724, false},   // ((26 * 256) + 240) / 4 = 1724
-		{[]byte{0x0F, 0xA0}, 1000, false},   // ((15 * 256) + 160) / 4 = 1000
-		{[]byte{0x00, 0x00}, 0, false},      // 0 RPM
-		{[]byte{0x1A}, 0, true},             // Wrong length
-		{[]byte{0x1A, 0xF0, 0x00}, 0, true}, // Wrong length
-	}
-
-	for _, tt := range tests {
-		result, err := decodeRPM(tt.data)
-
-		if tt.hasError {
-			if err == nil {
-				t.Errorf("Expected error for data %v", tt.data)
-			}
-			continue
-		}
-
-		if err != nil {
-			t.Errorf("Unexpected error for data %v: %v", tt.data, err)
-			continue
-		}
-
-		if result != tt.expected {
-			t.Errorf("Expected %.2f, got %.2f for data %v", tt.expected, result, tt.data)
-		}
-	}
-}
-
-func TestDecodeVehicleSpeed(t *testing.T) {
-	tests := []struct {
-		data     []byte
-		expected float64
-		hasError bool
-	}{
-		{[]byte{0x00}, 0, false},
-		{[]byte{0x32}, 50, false},
-		{[]byte{0xFF}, 255, false},
-		{[]byte{0x32, 0x00}, 0, true}, // Wrong length
-		{[]byte{}, 0, true},           // Empty data
-	}
-
-	for _, tt := range tests {
-		result, err := decodeVehicleSpeed(tt.data)
-
-		if tt.hasError {
-			if err == nil {
-				t.Errorf("Expected error for data %v", tt.data)
-			}
-			continue
-		}
-
-		if err != nil {
-			t.Errorf("Unexpected error for data %v: %v", tt.data, err)
-			continue
-		}
-
-		if result != tt.expected {
-			t.Errorf("Expected %.2f, got %.2f for data %v", tt.expected, result, tt.data)
-		}
-	}
-}
-
-func TestDecodeCoolantTemp(t *testing.T) {
-	tests := []struct {
-		data     []byte
-		expected float64
-		hasError bool
-	}{
-		{[]byte{0x5A}, 50, false},     // 0x5A - 40 = 50
-		{[]byte{0x00}, -40, false},    // 0x00 - 40 = -40
-		{[]byte{0xFF}, 215, false},    // 0xFF - 40 = 215
-		{[]byte{0x32, 0x00}, 0, true}, // Wrong length
+func TestParseResponseUnsupportedPID(t *testing.T) {
+	telemetry, err := ParseResponse("7E8 05 41 FF 12 34 AB")
+	if err != nil {
+		t.Fatalf("Expected unsupported PID to be passed through, got error: %v", err)
 	}
 
-	for _, tt := range tests {
-		result, err := decodeCoolantTemp(tt.data)
-
-		if tt.hasError {
-			if err == nil {
-				t.Errorf("Expected error for data %v", tt.data)
-			}
-			continue
-		}
-
-		if err != nil {
-			t.Errorf("Unexpected error for data %v: %v", tt.data, err)
-			continue
-		}
-
-		if result != tt.expected {
-			t.Errorf("Expected %.2f, got %.2f for data %v", tt.expected, result, tt.data)
-		}
+	if telemetry.PID != "FF" || telemetry.Metric != "unknown_FF" || telemetry.Unit != "raw" {
+		t.Errorf("Unexpected passthrough telemetry: %+v", telemetry)
 	}
-}
-
-func TestDecodeThrottlePos(t *testing.T) {
-	tests := []struct {
-		data     []byte
-		expected float64
-		hasError bool
-	}{
-		{[]byte{0x00}, 0, false},      // 0%
-		{[]byte{0xFF}, 100, false},    // 100%
-		{[]byte{0x80}, 50.196, false}, // (0x80 * 100) / 255 ≈ 50.196%
-		{[]byte{0x32, 0x00}, 0, true}, // Wrong length
-	}
-
-	for _, tt := range tests {
-		result, err := decodeThrottlePos(tt.data)
-
-		if tt.hasError {
-			if err == nil {
-				t.Errorf("Expected error for data %v", tt.data)
-			}
-			continue
-		}
-
-		if err != nil {
-			t.Errorf("Unexpected error for data %v: %v", tt.data, err)
-			continue
-		}
-
-		// Используем небольшую дельту для сравнения float
-		delta := 0.01
-		if result < tt.expected-delta || result > tt.expected+delta {
-			t.Errorf("Expected %.3f, got %.3f for data %v", tt.expected, result, tt.data)
-		}
+	if telemetry.Data != "12 34 AB" || telemetry.ECU != "7E8" || telemetry.Value != 0 {
+		t.Errorf("Expected raw data bytes, got %+v", telemetry)
 	}
 }
 
@@ -302,10 +186,18 @@ func TestTelemetryStructure(t *testing.T) {
 	}
 }
 
+// discardLogs отключает журнал парсера на время бенчмарка, чтобы измерять разбор, а не вывод
+func discardLogs(b *testing.B) {
+	SetLogOutput(io.Discard)
+	b.Cleanup(func() { SetLogOutput(os.Stdout) })
+}
+
 // Бенчмарк для тестирования производительности парсера
 func BenchmarkParseResponse(b *testing.B) {
+	discardLogs(b)
 	response := "41 0C 1A F0"
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		_, err := ParseResponse(response)
@@ -315,6 +207,19 @@ func BenchmarkParseResponse(b *testing.B) {
 	}
 }
 
+func BenchmarkParseResponsesWithHeaders(b *testing.B) {
+	discardLogs(b)
+	response := "7E8 03 41 0D 3C\r7E9 03 41 0D 3D"
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if telemetries, err := ParseResponses(response); err != nil || len(telemetries) != 2 {
+			b.Fatalf("ParseResponses failed: %v", err)
+		}
+	}
+}
+
 // Тест для проверки корректности всех декодеров
 func TestAllDecoders(t *testing.T) {
 	testCases := []struct {
@@ -322,17 +227,28 @@ func TestAllDecoders(t *testing.T) {
 		response string
 		expected float64
 	}{
-		{"0C", "41 0C 1A F0", 1724},   // RPM
-		{"0D", "41 0D 32", 50},        // Speed
-		{"05", "41 05 5A", 50},        // Coolant temp (0x5A - 40 = 50)
-		{"0F", "41 0F 00", -40},       // Intake temp (0x00 - 40 = -40)
-		{"11", "41 11 80", 50.196078}, // Throttle position (0x80 * 100 / 255 ≈ 50.196078)
-		{"04", "41 04 33", 20},        // Engine load (0x33 * 100 / 255 ≈ 20)
-		{"2F", "41 2F 66", 40},        // Fuel level (0x66 * 100 / 255 ≈ 40)
-		{"0A", "41 0A 1F", 93},        // Fuel pressure (0x1F * 3 = 93)
-		{"0B", "41 0B 64", 100},       // Intake pressure
-		{"33", "41 33 61", 97},        // Barometric pressure
-		{"21", "41 21 00 FA", 250},    // Distance with MIL (0x00FA = 250)
+		{"0C", "41 0C 1A F0", 1724},            // RPM
+		{"0D", "41 0D 32", 50},                 // Speed
+		{"05", "41 05 5A", 50},                 // Coolant temp (0x5A - 40 = 50)
+		{"0F", "41 0F 00", -40},                // Intake temp (0x00 - 40 = -40)
+		{"11", "41 11 80", 50.196078},          // Throttle position (0x80 * 100 / 255 ≈ 50.196078)
+		{"04", "41 04 33", 20},                 // Engine load (0x33 * 100 / 255 ≈ 20)
+		{"2F", "41 2F 66", 40},                 // Fuel level (0x66 * 100 / 255 ≈ 40)
+		{"0A", "41 0A 1F", 93},                 // Fuel pressure (0x1F * 3 = 93)
+		{"0B", "41 0B 64", 100},                // Intake pressure
+		{"33", "41 33 61", 97},                 // Barometric pressure
+		{"21", "41 21 00 FA", 250},             // Distance with MIL (0x00FA = 250)
+		{"A6", "41 A6 00 01 E2 40", 12345.6},   // Odometer (0x0001E240 / 10 = 12345.6)
+		{"45", "41 45 33", 20},                 // Relative throttle position
+		{"47", "41 47 FF", 100},                // Absolute throttle position B
+		{"48", "41 48 00", 0},                  // Absolute throttle position C
+		{"49", "41 49 80", 50.196078},          // Accelerator pedal position D
+		{"4A", "41 4A 66", 40},                 // Accelerator pedal position E
+		{"4B", "41 4B 33", 20},                 // Accelerator pedal position F
+		{"4C", "41 4C 1A", 10.196078},          // Commanded throttle actuator
+		{"5B", "41 5B CC", 80},                 // Hybrid battery state of charge (0xCC * 100 / 255 = 80)
+		{"51", "41 51 11", 17},                 // Fuel type: hybrid gasoline
+		{"9A", "41 9A 07 00 5D C0 FF 9C", 375}, // Hybrid battery voltage (0x5DC0 / 64 = 375)
 	}
 
 	for _, tc := range testCases {
@@ -350,3 +266,165 @@ func TestAllDecoders(t *testing.T) {
 		})
 	}
 }
+
+func TestParseResponsesWithHeaders(t *testing.T) {
+	type ecuValue struct {
+		ecu   string
+		value float64
+	}
+
+	tests := []struct {
+		name     string
+		response string
+		expected []ecuValue
+	}{
+		{
+			name:     "Single ECU 11-bit",
+			response: "7E8 04 41 0C 1A F0",
+			expected: []ecuValue{{"7E8", 1724}},
+		},
+		{
+			name:     "Engine and transmission",
+			response: "7E8 03 41 0D 32\r7E9 03 41 0D 33\r",
+			expected: []ecuValue{{"7E8", 50}, {"7E9", 51}},
+		},
+		{
+			name:     "29-bit header with padding",
+			response: "18 DA F1 10 03 41 05 5A 55 55 55 55",
+			expected: []ecuValue{{"10", 50}},
+		},
+		{
+			name:     "Searching line is skipped",
+			response: "SEARCHING...\r7E8 03 41 0D 32",
+			expected: []ecuValue{{"7E8", 50}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			telemetries, err := ParseResponses(tt.response)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(telemetries) != len(tt.expected) {
+				t.Fatalf("Expected %d values, got %d", len(tt.expected), len(telemetries))
+			}
+			for i, exp := range tt.expected {
+				if telemetries[i].ECU != exp.ecu || telemetries[i].Value != exp.value {
+					t.Errorf("Value %d: expected %s=%v, got %s=%v", i, exp.ecu, exp.value, telemetries[i].ECU, telemetries[i].Value)
+				}
+			}
+		})
+	}
+}
+
+func TestParseResponsesHeaderErrors(t *testing.T) {
+	responses := []string{
+		"7E8 10 14 49 02 01 31 47 31", // Первый кадр многокадрового ответа
+		"7E8 07 41 0D 32",             // Длина больше числа байт
+		"7E8 03 7F 01 12",             // Отрицательный ответ
+	}
+
+	for _, response := range responses {
+		if _, err := ParseResponses(response); err == nil {
+			t.Errorf("Expected error for response %q", response)
+		}
+	}
+}
+
+func TestStartParserCorrelation(t *testing.T) {
+	responsesChan := make(chan string, 10)
+	telemetryChan := make(chan Telemetry, 10)
+	commandResponses := make(chan CommandResponse, 10)
+	statusChan := make(chan common.StatusEvent, 10)
+	requests := common.NewPendingRequests(time.Second, commandResponses)
+
+	go StartParser(context.Background(), responsesChan, telemetryChan, commandResponses, NewBusHealth(), NewTopology(),
+		NewStreamer(make(chan string, 1)), requests, statusChan)
+	defer close(responsesChan)
+
+	tests := []struct {
+		correlationID string
+		command       string
+		response      string
+		status        string
+	}{
+		{"rpm", "010C", "7E8 04 41 0C 1A F0", "success"},
+		{"did", "22F190", "7E8 03 7F 22 31", "error"},
+		{"nodata", "0142", "NO DATA", "error"},
+	}
+
+	for _, tt := range tests {
+		requests.Register(tt.correlationID, []string{tt.command})
+		requests.Bind(requests.Claim(tt.command), tt.response)
+		responsesChan <- tt.response
+
+		select {
+		case response := <-commandResponses:
+			if response.CorrelationID != tt.correlationID || response.Status != tt.status {
+				t.Errorf("%s: unexpected response %+v", tt.command, response)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s: expected correlated command response", tt.command)
+		}
+	}
+
+	// Ответ на опрос PID без запроса клиента не порождает ответ на команду
+	responsesChan <- "7E8 03 41 0D 32"
+	<-telemetryChan
+	<-telemetryChan // Телеметрия запроса rpm
+	select {
+	case response := <-commandResponses:
+		t.Errorf("Unexpected command response for polled PID: %+v", response)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestStartParserStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		StartParser(ctx, make(chan string), make(chan Telemetry), make(chan CommandResponse), NewBusHealth(), NewTopology(),
+			NewStreamer(make(chan string, 1)), nil, nil)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Parser kept running after cancel")
+	}
+}
+
+func TestStartCommandManagerStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		// Канал команд никто не читает: остановка не должна зависеть от адаптера
+		commandsChan := make(chan string)
+		StartCommandManager(ctx, commandsChan, NewBusHealth(), NewStreamer(commandsChan), nil, nil, nil, nil, 0)
+		close(done)
+	}()
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Command manager kept running after cancel")
+	}
+}
+
+func TestSendCommandStops(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Смена заголовка ждет места в канале, но не после остановки
+	if sendCommand(ctx, make(chan string), "ATSH7E0") {
+		t.Error("Expected blocked send to be abandoned after cancel")
+	}
+	commandsChan := make(chan string, 1)
+	if !sendCommand(context.Background(), commandsChan, "ATSH7E0") || <-commandsChan != "ATSH7E0" {
+		t.Error("Expected command to be sent")
+	}
+}
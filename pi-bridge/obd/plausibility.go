@@ -0,0 +1,85 @@
+package obd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Действия с показаниями вне допустимого диапазона
+const (
+	PlausibilityFlag = "flag" // Публиковать с признаком implausible
+	PlausibilityDrop = "drop" // Не публиковать
+)
+
+// PlausibilityRange задает допустимый диапазон значений метрики (в метрических единицах)
+type PlausibilityRange struct {
+	Min float64 `yaml:"min"`
+	Max float64 `yaml:"max"`
+}
+
+// PlausibilityConfig описывает проверку правдоподобности показаний. Дешевые клоны ELM327
+// иногда возвращают мусорные байты, которые портят графики на дашбордах
+type PlausibilityConfig struct {
+	Action string                       `yaml:"action"` // flag или drop
+	Ranges map[string]PlausibilityRange `yaml:"ranges"` // Диапазоны по названию метрики
+}
+
+// DefaultPlausibilityConfig возвращает диапазоны по умолчанию для основных метрик
+func DefaultPlausibilityConfig() PlausibilityConfig {
+	return PlausibilityConfig{
+		Action: PlausibilityFlag,
+		Ranges: map[string]PlausibilityRange{
+			"engine_rpm":             {Min: 0, Max: 10000},
+			"vehicle_speed":          {Min: 0, Max: 250},
+			"coolant_temperature":    {Min: -45, Max: 150},
+			"intake_air_temperature": {Min: -45, Max: 120},
+		},
+	}
+}
+
+// plausibility - действующая проверка правдоподобности (nil диапазонов - проверка выключена)
+var plausibility PlausibilityConfig
+
+// RegisterPlausibility проверяет и применяет диапазоны из конфигурации.
+// Вызывается при запуске до старта парсера
+func RegisterPlausibility(config PlausibilityConfig) error {
+	action := strings.ToLower(strings.TrimSpace(config.Action))
+	switch action {
+	case "":
+		action = PlausibilityFlag
+	case PlausibilityFlag, PlausibilityDrop:
+	default:
+		return fmt.Errorf("plausibility: unknown action %q (expected %s or %s)", config.Action, PlausibilityFlag, PlausibilityDrop)
+	}
+
+	for metric, r := range config.Ranges {
+		if r.Min >= r.Max {
+			return fmt.Errorf("plausibility: invalid range for %s: min %v must be less than max %v", metric, r.Min, r.Max)
+		}
+	}
+
+	plausibility = PlausibilityConfig{Action: action, Ranges: config.Ranges}
+	logger.Printf("Plausibility check: %d ranges, action %s", len(config.Ranges), action)
+	return nil
+}
+
+// CheckPlausibility проверяет показания по диапазонам: в режиме flag помечает
+// недостоверные значения, в режиме drop убирает их и возвращает отдельно
+func CheckPlausibility(telemetries []*Telemetry) (accepted, dropped []*Telemetry) {
+	accepted = make([]*Telemetry, 0, len(telemetries))
+	for _, telemetry := range telemetries {
+		r, ok := plausibility.Ranges[telemetry.Metric]
+		if !ok || telemetry.Data != "" || (telemetry.Value >= r.Min && telemetry.Value <= r.Max) {
+			accepted = append(accepted, telemetry)
+			continue
+		}
+
+		if plausibility.Action == PlausibilityDrop {
+			dropped = append(dropped, telemetry)
+			continue
+		}
+		telemetry.Implausible = true
+		accepted = append(accepted, telemetry)
+	}
+	return accepted, dropped
+}
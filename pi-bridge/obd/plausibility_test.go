@@ -0,0 +1,59 @@
+package obd
+
+import "testing"
+
+func TestCheckPlausibility(t *testing.T) {
+	defer func() { plausibility = PlausibilityConfig{} }()
+
+	tests := []struct {
+		action      string
+		accepted    int
+		dropped     int
+		implausible bool
+	}{
+		{PlausibilityFlag, 3, 0, true},
+		{PlausibilityDrop, 2, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.action, func(t *testing.T) {
+			config := DefaultPlausibilityConfig()
+			config.Action = tt.action
+			if err := RegisterPlausibility(config); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			garbage := &Telemetry{Metric: "coolant_temperature", Value: 215}
+			telemetries := []*Telemetry{
+				{Metric: "engine_rpm", Value: 1724},
+				garbage,
+				{Metric: "fuel_level", Value: 50}, // Диапазон не задан
+			}
+
+			accepted, dropped := CheckPlausibility(telemetries)
+			if len(accepted) != tt.accepted || len(dropped) != tt.dropped {
+				t.Fatalf("Expected %d accepted and %d dropped, got %d and %d", tt.accepted, tt.dropped, len(accepted), len(dropped))
+			}
+			if garbage.Implausible != tt.implausible {
+				t.Errorf("Expected implausible=%v, got %v", tt.implausible, garbage.Implausible)
+			}
+			if accepted[0].Implausible {
+				t.Error("Plausible value must not be flagged")
+			}
+		})
+	}
+}
+
+func TestRegisterPlausibilityErrors(t *testing.T) {
+	defer func() { plausibility = PlausibilityConfig{} }()
+
+	configs := []PlausibilityConfig{
+		{Action: "ignore"},
+		{Ranges: map[string]PlausibilityRange{"engine_rpm": {Min: 100, Max: 0}}},
+	}
+	for _, config := range configs {
+		if err := RegisterPlausibility(config); err == nil {
+			t.Errorf("Expected error for %+v", config)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package obd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"plugin"
+	"sort"
+	"strings"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// pluginRegisterSymbol - имя функции, которую должен экспортировать Go плагин (.so)
+const pluginRegisterSymbol = "RegisterDecoders"
+
+// DecoderRegistry предоставляет плагинам регистрацию декодеров PID
+type DecoderRegistry interface {
+	RegisterPID(pid, name, unit string, decoder PIDDecoder) error
+}
+
+// DecoderPack представляет набор декодеров в виде YAML файла с формулами
+type DecoderPack struct {
+	Name       string      `yaml:"name"`
+	CustomPIDs []CustomPID `yaml:"custom_pids"`
+}
+
+// pluginRegistry регистрирует декодеры плагина в таблицах парсера
+type pluginRegistry struct {
+	source string // Имя файла плагина для логов
+}
+
+// RegisterPID добавляет декодер PID сервиса 01
+func (r *pluginRegistry) RegisterPID(pid, name, unit string, decoder PIDDecoder) error {
+	pid = strings.ToUpper(pid)
+	overrides := pidParser.Supports(pid)
+	if err := registerPID(pid, name, unit, decoder, MetricSourcePlugin, nil); err != nil {
+		return err
+	}
+
+	if overrides {
+		logger.Printf("Plugin %s overrides decoder for PID %s", r.source, pid)
+	}
+	logger.Printf("Plugin %s registered PID %s (%s, %s)", r.source, pid, name, unit)
+	return nil
+}
+
+// LoadDecoderPlugins загружает наборы декодеров из каталога при запуске.
+// Поддерживаются YAML файлы с формулами (*.yaml, *.yml) и Go плагины (*.so),
+// экспортирующие функцию RegisterDecoders(obd.DecoderRegistry) error
+func LoadDecoderPlugins(dir string) error {
+	if dir == "" {
+		return nil
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			logger.Printf("Plugins directory %s not found, skipping", dir)
+			return nil
+		}
+		return fmt.Errorf("failed to read plugins directory %s: %v", dir, err)
+	}
+
+	// Порядок загрузки детерминирован, чтобы переопределения PID были предсказуемы
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		switch strings.ToLower(filepath.Ext(name)) {
+		case ".yaml", ".yml":
+			err = loadDecoderPack(path)
+		case ".so":
+			err = loadGoPlugin(path)
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("plugin %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// loadDecoderPack загружает YAML набор декодеров с формулами
+func loadDecoderPack(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var pack DecoderPack
+	if err := yaml.Unmarshal(content, &pack); err != nil {
+		return fmt.Errorf("invalid decoder pack: %v", err)
+	}
+
+	logger.Printf("Loading decoder pack %q from %s (%d PIDs)", pack.Name, path, len(pack.CustomPIDs))
+	return registerCustomPIDs(pack.CustomPIDs, MetricSourcePlugin)
+}
+
+// loadGoPlugin загружает Go плагин и вызывает его функцию регистрации.
+// Плагин должен быть собран той же версией Go и с теми же зависимостями, что и мост
+func loadGoPlugin(path string) error {
+	p, err := plugin.Open(path)
+	if err != nil {
+		return err
+	}
+
+	symbol, err := p.Lookup(pluginRegisterSymbol)
+	if err != nil {
+		return err
+	}
+
+	register, ok := symbol.(func(DecoderRegistry) error)
+	if !ok {
+		return fmt.Errorf("%s has unexpected signature %T", pluginRegisterSymbol, symbol)
+	}
+
+	logger.Printf("Loading Go plugin %s", path)
+	return register(&pluginRegistry{source: filepath.Base(path)})
+}
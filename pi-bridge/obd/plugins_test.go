@@ -0,0 +1,85 @@
+package obd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadDecoderPluginsYAMLPack(t *testing.T) {
+	dir := t.TempDir()
+	pack := `name: "test pack"
+custom_pids:
+  - pid: "5D"
+    name: "fuel_injection_timing"
+    unit: "°"
+    bytes: 2
+    formula: "(A*256+B)/128-210"
+`
+	if err := os.WriteFile(filepath.Join(dir, "fuel.yaml"), []byte(pack), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// Файлы с другими расширениями игнорируются
+	if err := os.WriteFile(filepath.Join(dir, "README.txt"), []byte("ignored"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		pidParser.Unregister("5D")
+		delete(metricDetails, "5D")
+		delete(metricSources, "5D")
+	}()
+
+	if err := LoadDecoderPlugins(dir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	telemetry, err := ParseResponse("41 5D 6E 00")
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+	if telemetry.Metric != "fuel_injection_timing" || telemetry.Value != 10 {
+		t.Errorf("Unexpected telemetry: %+v", telemetry)
+	}
+}
+
+func TestLoadDecoderPluginsErrors(t *testing.T) {
+	if err := LoadDecoderPlugins(""); err != nil {
+		t.Errorf("Expected no error for empty directory setting, got %v", err)
+	}
+	if err := LoadDecoderPlugins(filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Errorf("Expected missing directory to be skipped, got %v", err)
+	}
+
+	dir := t.TempDir()
+	bad := "custom_pids:\n  - pid: \"5E\"\n    name: \"bad\"\n    bytes: 1\n    formula: \"A+B\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "bad.yml"), []byte(bad), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadDecoderPlugins(dir); err == nil {
+		t.Error("Expected error for invalid decoder pack")
+	}
+}
+
+func TestPluginRegistryRegisterPID(t *testing.T) {
+	registry := &pluginRegistry{source: "test.so"}
+	defer func() {
+		pidParser.Unregister("5F")
+		delete(metricDetails, "5F")
+		delete(metricSources, "5F")
+	}()
+
+	decoder := func(data []byte) (float64, error) { return float64(data[0]), nil }
+	if err := registry.RegisterPID("5f", "emission_requirements", "", decoder); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if GetMetricName("5F") != "emission_requirements" {
+		t.Errorf("Expected PID 5F to be registered")
+	}
+
+	if err := registry.RegisterPID("5F0", "bad", "", decoder); err == nil {
+		t.Error("Expected error for invalid PID")
+	}
+	if err := registry.RegisterPID("60", "", "", nil); err == nil {
+		t.Error("Expected error for missing decoder")
+	}
+}
@@ -0,0 +1,79 @@
+package obd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// headerPattern проверяет заголовок для ATSH: 11-бит (3 hex), 29-бит (6 или 8 hex)
+var headerPattern = regexp.MustCompile(`^([0-9A-F]{3}|[0-9A-F]{6}|[0-9A-F]{8})$`)
+
+// PollGroup описывает группу PID, опрашиваемых с отдельным заголовком запроса,
+// например для блока управления гибридной батареей
+type PollGroup struct {
+	Name   string   `yaml:"name"`   // Название группы для логов
+	Header string   `yaml:"header"` // Заголовок запроса для ATSH, например "7E2"
+	PIDs   []string `yaml:"pids"`   // PID сервиса 01
+}
+
+// pollGroups содержит группы опроса с переключением заголовка
+var pollGroups []PollGroup
+
+// RegisterPollGroups проверяет и добавляет группы опроса из конфигурации.
+// Вызывается после RegisterCustomPIDs, чтобы группы могли ссылаться на пользовательские PID
+func RegisterPollGroups(groups []PollGroup) error {
+	for _, group := range groups {
+		header := strings.ToUpper(strings.ReplaceAll(group.Header, " ", ""))
+		if !headerPattern.MatchString(header) {
+			return fmt.Errorf("poll group %q: invalid header %q", group.Name, group.Header)
+		}
+		if len(group.PIDs) == 0 {
+			return fmt.Errorf("poll group %q: no PIDs", group.Name)
+		}
+
+		pids := make([]string, 0, len(group.PIDs))
+		for _, pid := range group.PIDs {
+			pid = strings.ToUpper(pid)
+			if !pidParser.Supports(pid) {
+				return fmt.Errorf("poll group %q: unsupported PID %s", group.Name, pid)
+			}
+			pids = append(pids, pid)
+		}
+
+		pollGroups = append(pollGroups, PollGroup{Name: group.Name, Header: header, PIDs: pids})
+		logger.Printf("Registered poll group %q: header %s, PIDs %v", group.Name, header, pids)
+	}
+	return nil
+}
+
+// pollCycleCommands формирует последовательность команд одного цикла опроса:
+// сначала общие PID с функциональным заголовком, затем группы со своими заголовками
+// и в конце возврат к функциональному заголовку. В режиме J1939 - запросы групп параметров
+func pollCycleCommands() []string {
+	if j1939Mode {
+		return j1939PollCommands()
+	}
+
+	commands := make([]string, 0, len(pollPIDs))
+	for _, pid := range pollPIDs {
+		commands = append(commands, "01"+pid)
+	}
+
+	if len(pollGroups) == 0 {
+		return commands
+	}
+
+	for _, group := range pollGroups {
+		commands = append(commands, "ATSH "+group.Header)
+		for _, pid := range group.PIDs {
+			commands = append(commands, "01"+pid)
+		}
+	}
+	return append(commands, "ATSH "+functionalRequestHeader)
+}
+
+// isHeaderCommand проверяет, является ли команда сменой заголовка запроса
+func isHeaderCommand(command string) bool {
+	return strings.HasPrefix(command, "ATSH")
+}
@@ -0,0 +1,59 @@
+package obd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPollCycleCommandsWithGroups(t *testing.T) {
+	savedPIDs, savedGroups := pollPIDs, pollGroups
+	defer func() { pollPIDs, pollGroups = savedPIDs, savedGroups }()
+
+	pollPIDs = []string{"0C", "0D"}
+	pollGroups = nil
+
+	if err := RegisterPollGroups([]PollGroup{{Name: "hybrid", Header: "7e2", PIDs: []string{"5b"}}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"010C", "010D", "ATSH 7E2", "015B", "ATSH 7DF"}
+	if commands := pollCycleCommands(); !reflect.DeepEqual(commands, expected) {
+		t.Errorf("Expected %v, got %v", expected, commands)
+	}
+}
+
+func TestPollCycleCommandsWithoutGroups(t *testing.T) {
+	savedPIDs, savedGroups := pollPIDs, pollGroups
+	defer func() { pollPIDs, pollGroups = savedPIDs, savedGroups }()
+
+	pollPIDs = []string{"0C"}
+	pollGroups = nil
+
+	expected := []string{"010C"}
+	if commands := pollCycleCommands(); !reflect.DeepEqual(commands, expected) {
+		t.Errorf("Expected %v, got %v", expected, commands)
+	}
+}
+
+func TestRegisterPollGroupsErrors(t *testing.T) {
+	savedGroups := pollGroups
+	defer func() { pollGroups = savedGroups }()
+
+	tests := []struct {
+		name  string
+		group PollGroup
+	}{
+		{"Invalid header", PollGroup{Name: "bad", Header: "7E", PIDs: []string{"0C"}}},
+		{"Non-hex header", PollGroup{Name: "bad", Header: "XYZ", PIDs: []string{"0C"}}},
+		{"No PIDs", PollGroup{Name: "empty", Header: "7E0"}},
+		{"Unsupported PID", PollGroup{Name: "unknown", Header: "7E0", PIDs: []string{"FF"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := RegisterPollGroups([]PollGroup{tt.group}); err == nil {
+				t.Errorf("Expected error for group %+v", tt.group)
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package obd
+
+import (
+	"fmt"
+	"math"
+)
+
+// maxPrecision - наибольшее число знаков после запятой; точнее float64 не передает
+// показания датчиков, а лишние знаки только увеличивают сообщения
+const maxPrecision = 6
+
+// PrecisionConfig задает число знаков после запятой публикуемых значений
+type PrecisionConfig struct {
+	Default *int           `yaml:"default"` // Для метрик без своей точности (не задано - без округления)
+	Metrics map[string]int `yaml:"metrics"` // По названию метрики, например vehicle_speed: 0
+}
+
+// precisionDefault и metricPrecision задаются при запуске (nil/пусто - без округления)
+var (
+	precisionDefault *int
+	metricPrecision  = map[string]int{}
+)
+
+// RegisterPrecision проверяет и задает точность публикуемых значений.
+// Вызывается при запуске до старта парсера
+func RegisterPrecision(config PrecisionConfig) error {
+	if config.Default != nil {
+		if err := validatePrecision("default", *config.Default); err != nil {
+			return err
+		}
+	}
+
+	metrics := make(map[string]int, len(config.Metrics))
+	for metric, digits := range config.Metrics {
+		if err := validatePrecision(metric, digits); err != nil {
+			return err
+		}
+		metrics[metric] = digits
+	}
+
+	precisionDefault = config.Default
+	metricPrecision = metrics
+	return nil
+}
+
+// validatePrecision проверяет число знаков после запятой
+func validatePrecision(name string, digits int) error {
+	if digits < 0 || digits > maxPrecision {
+		return fmt.Errorf("precision of %s: expected 0-%d digits, got %d", name, maxPrecision, digits)
+	}
+	return nil
+}
+
+// metricDigits возвращает точность метрики; false - значение не округляется
+func metricDigits(metric string) (int, bool) {
+	if digits, ok := metricPrecision[metric]; ok {
+		return digits, true
+	}
+	if precisionDefault != nil {
+		return *precisionDefault, true
+	}
+	return 0, false
+}
+
+// catalogPrecision возвращает точность метрики для каталога (nil - без округления)
+func catalogPrecision(metric string) *int {
+	if digits, ok := metricDigits(metric); ok {
+		return &digits
+	}
+	return nil
+}
+
+// RoundValue округляет значение метрики до заданного числа знаков после запятой.
+// Округление убирает шум float (12.600000000000001) и позволяет фильтрам
+// изменений не публиковать значения, отличающиеся только в последних разрядах
+func RoundValue(metric string, value float64) float64 {
+	digits, ok := metricDigits(metric)
+	if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+		return value
+	}
+
+	scale := math.Pow10(digits)
+	rounded := math.Round(value*scale) / scale
+	if rounded == 0 {
+		return 0 // Без "-0" в JSON
+	}
+	return rounded
+}
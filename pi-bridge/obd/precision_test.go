@@ -0,0 +1,78 @@
+package obd
+
+import "testing"
+
+func TestRoundValue(t *testing.T) {
+	defer RegisterPrecision(PrecisionConfig{})
+
+	two := 2
+	if err := RegisterPrecision(PrecisionConfig{
+		Default: &two,
+		Metrics: map[string]int{"vehicle_speed": 0, "battery_voltage": 1},
+	}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		metric   string
+		value    float64
+		expected float64
+	}{
+		{"vehicle_speed", 59.6, 60},
+		{"battery_voltage", 12.600000000000001, 12.6},
+		{"short_term_fuel_trim_1", -3.90625, -3.91},
+		{"short_term_fuel_trim_1", -0.001, 0},
+	}
+	for _, tt := range tests {
+		if value := RoundValue(tt.metric, tt.value); value != tt.expected {
+			t.Errorf("%s %v: expected %v, got %v", tt.metric, tt.value, tt.expected, value)
+		}
+	}
+
+	// Перевод единиц выполняется до округления
+	SetUnitSystem(UnitsImperial)
+	defer SetUnitSystem(UnitsMetric)
+	telemetry := &Telemetry{Metric: "vehicle_speed", Value: 100, Unit: "km/h"}
+	ConvertTelemetry(telemetry)
+	if telemetry.Value != 62 || telemetry.Unit != "mph" {
+		t.Errorf("Unexpected converted telemetry: %+v", telemetry)
+	}
+}
+
+func TestRoundValueDisabled(t *testing.T) {
+	if value := RoundValue("vehicle_speed", 59.6); value != 59.6 {
+		t.Errorf("Expected no rounding by default, got %v", value)
+	}
+}
+
+func TestRegisterPrecisionErrors(t *testing.T) {
+	defer RegisterPrecision(PrecisionConfig{})
+
+	negative, large := -1, 7
+	configs := []PrecisionConfig{
+		{Default: &negative},
+		{Default: &large},
+		{Metrics: map[string]int{"engine_rpm": 10}},
+	}
+	for _, config := range configs {
+		if err := RegisterPrecision(config); err == nil {
+			t.Errorf("Expected error for %+v", config)
+		}
+	}
+}
+
+func TestCatalogPrecision(t *testing.T) {
+	defer RegisterPrecision(PrecisionConfig{})
+
+	if err := RegisterPrecision(PrecisionConfig{Metrics: map[string]int{"engine_rpm": 0}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, info := range Catalog() {
+		switch {
+		case info.Metric == "engine_rpm" && (info.Precision == nil || *info.Precision != 0):
+			t.Errorf("Expected precision 0 for engine_rpm, got %+v", info)
+		case info.Metric != "engine_rpm" && info.Precision != nil:
+			t.Errorf("Expected no precision for %s", info.Metric)
+		}
+	}
+}
@@ -0,0 +1,271 @@
+package obd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+// PreDriveCommand - служебная команда моста для проверки автомобиля перед поездкой
+const PreDriveCommand = "PREDRIVE_CHECK"
+
+// Итоговые оценки проверки
+const (
+	PreDriveGreen = "green"
+	PreDriveAmber = "amber"
+	PreDriveRed   = "red"
+)
+
+// preDriveTimeout - время ожидания ответов на все запросы проверки
+const preDriveTimeout = 10 * time.Second
+
+// PreDriveCriteria задает пороги оценки проверки перед поездкой
+type PreDriveCriteria struct {
+	MinBatteryVoltage      float64 `yaml:"min_battery_voltage"`      // Ниже - amber
+	CriticalBatteryVoltage float64 `yaml:"critical_battery_voltage"` // Ниже - red
+	MaxCoolantTemp         float64 `yaml:"max_coolant_temp"`         // Выше - red (°C)
+	MinFuelLevel           float64 `yaml:"min_fuel_level"`           // Ниже - amber (%)
+	CriticalFuelLevel      float64 `yaml:"critical_fuel_level"`      // Ниже - red (%)
+}
+
+// DefaultPreDriveCriteria возвращает пороги по умолчанию
+func DefaultPreDriveCriteria() PreDriveCriteria {
+	return PreDriveCriteria{
+		MinBatteryVoltage:      12.2,
+		CriticalBatteryVoltage: 11.8,
+		MaxCoolantTemp:         110,
+		MinFuelLevel:           15,
+		CriticalFuelLevel:      5,
+	}
+}
+
+// PreDriveItem представляет результат одного пункта проверки
+type PreDriveItem struct {
+	Value   *float64 `json:"value,omitempty"` // Значение (nil, если не получено)
+	Unit    string   `json:"unit,omitempty"`
+	Result  string   `json:"result"` // green, amber, red или unknown
+	Message string   `json:"message,omitempty"`
+}
+
+// PreDriveReport представляет итог проверки перед поездкой
+type PreDriveReport struct {
+	Result string                  `json:"result"` // Итоговая оценка: худший из пунктов
+	Items  map[string]PreDriveItem `json:"items"`
+}
+
+// PreDriveCheck собирает данные для проверки перед поездкой и публикует итог
+type PreDriveCheck struct {
+	mu         sync.Mutex
+	criteria   PreDriveCriteria
+	statusChan chan<- common.StatusEvent
+	active     bool
+	generation int
+	values     map[string]float64
+	logger     *log.Logger
+}
+
+// preDriveItems перечисляет пункты проверки и PID, из которых они берутся
+var preDriveItems = []struct {
+	name string
+	pid  string
+	unit string
+}{
+	{"battery_voltage", "", "V"},
+	{"dtc", "01", ""},
+	{"coolant_temperature", "05", "°C"},
+	{"fuel_level", "2F", "%"},
+}
+
+// NewPreDriveCheck создает проверку перед поездкой с заданными порогами
+func NewPreDriveCheck(criteria PreDriveCriteria, statusChan chan<- common.StatusEvent) *PreDriveCheck {
+	return &PreDriveCheck{
+		criteria:   criteria,
+		statusChan: statusChan,
+		logger:     log.New(os.Stdout, "[OBD-PreDrive] ", log.LstdFlags|log.Lshortfile),
+	}
+}
+
+// HandleCommand возвращает запросы для сбора данных и запуск проверки
+func (p *PreDriveCheck) HandleCommand(args []string) ([]string, func(), error) {
+	commands := []string{BatteryVoltageCommand}
+	for _, item := range preDriveItems {
+		if item.pid != "" {
+			commands = append(commands, "01"+item.pid)
+		}
+	}
+	return commands, p.start, nil
+}
+
+// start начинает сбор значений проверки
+func (p *PreDriveCheck) start() {
+	p.mu.Lock()
+	p.active = true
+	p.generation++
+	generation := p.generation
+	p.values = make(map[string]float64)
+	p.mu.Unlock()
+
+	// По истечении таймаута публикуем результат с тем, что успели собрать
+	time.AfterFunc(preDriveTimeout, func() { p.finish(generation) })
+
+	p.logger.Println("Pre-drive check started")
+}
+
+// Observe собирает значения из ответов адаптера во время проверки
+func (p *PreDriveCheck) Observe(response string, telemetry *Telemetry) {
+	p.mu.Lock()
+	if !p.active {
+		p.mu.Unlock()
+		return
+	}
+
+	if telemetry != nil {
+		for _, item := range preDriveItems {
+			if item.pid == telemetry.PID {
+				p.values[item.name] = telemetry.Value
+			}
+		}
+	}
+
+	p.checkComplete()
+}
+
+// ObserveAT собирает напряжение батареи из ответа на ATRV, например "12.6V".
+// Ответы на AT команды маршрутизируются адаптером мимо парсера OBD
+func (p *PreDriveCheck) ObserveAT(command, response string) {
+	voltage, ok := ParseBatteryVoltage(command, response)
+	if !ok {
+		return
+	}
+
+	p.mu.Lock()
+	if !p.active {
+		p.mu.Unlock()
+		return
+	}
+	p.values["battery_voltage"] = voltage
+	p.checkComplete()
+}
+
+// checkComplete завершает проверку, если собраны все значения (вызывается под мьютексом, освобождает его)
+func (p *PreDriveCheck) checkComplete() {
+	complete := len(p.values) == len(preDriveItems)
+	generation := p.generation
+	p.mu.Unlock()
+
+	if complete {
+		p.finish(generation)
+	}
+}
+
+// finish вычисляет итог и публикует его (один раз на запуск проверки)
+func (p *PreDriveCheck) finish(generation int) {
+	p.mu.Lock()
+	if !p.active || generation != p.generation {
+		p.mu.Unlock()
+		return
+	}
+	p.active = false
+	report := p.evaluate()
+	p.mu.Unlock()
+
+	p.logger.Printf("Pre-drive check result: %s", report.Result)
+
+	event := common.StatusEvent{
+		Kind:      "predrive_check",
+		Data:      report,
+		Retained:  true,
+		Timestamp: time.Now(),
+	}
+	select {
+	case p.statusChan <- event:
+	default:
+		p.logger.Println("Warning: status channel is full, dropping pre-drive check result")
+	}
+}
+
+// evaluate оценивает собранные значения (вызывается под мьютексом)
+func (p *PreDriveCheck) evaluate() PreDriveReport {
+	report := PreDriveReport{Result: PreDriveGreen, Items: make(map[string]PreDriveItem)}
+
+	for _, item := range preDriveItems {
+		value, ok := p.values[item.name]
+		if !ok {
+			// Без ответа (зажигание выключено, таймаут) пункт не проверен, и итог
+			// не может быть green
+			report.Items[item.name] = PreDriveItem{Result: "unknown", Message: "no response"}
+			report.Result = worseResult(report.Result, PreDriveAmber)
+			continue
+		}
+
+		result, message := p.evaluateItem(item.name, value)
+		v := value
+		unit := item.unit
+		if item.name == "dtc" {
+			// Для DTC публикуем количество кодов, а не сырую битовую карту
+			v = float64(monitorStatusDTCCount(value))
+		}
+		// Пороги заданы в метрических единицах, значение публикуется в выбранной системе
+		v, unit = ConvertValue(v, unit)
+		report.Items[item.name] = PreDriveItem{Value: &v, Unit: unit, Result: result, Message: message}
+		report.Result = worseResult(report.Result, result)
+	}
+
+	return report
+}
+
+// evaluateItem оценивает значение одного пункта по порогам
+func (p *PreDriveCheck) evaluateItem(name string, value float64) (string, string) {
+	c := p.criteria
+	switch name {
+	case "battery_voltage":
+		if value < c.CriticalBatteryVoltage {
+			return PreDriveRed, fmt.Sprintf("battery voltage below %.1f V", c.CriticalBatteryVoltage)
+		}
+		if value < c.MinBatteryVoltage {
+			return PreDriveAmber, fmt.Sprintf("battery voltage below %.1f V", c.MinBatteryVoltage)
+		}
+	case "dtc":
+		if monitorStatusMIL(value) {
+			return PreDriveRed, "MIL is on"
+		}
+		if monitorStatusDTCCount(value) > 0 {
+			return PreDriveAmber, "stored DTCs present"
+		}
+	case "coolant_temperature":
+		if value > c.MaxCoolantTemp {
+			return PreDriveRed, fmt.Sprintf("coolant above %.0f °C", c.MaxCoolantTemp)
+		}
+	case "fuel_level":
+		if value < c.CriticalFuelLevel {
+			return PreDriveRed, fmt.Sprintf("fuel below %.0f%%", c.CriticalFuelLevel)
+		}
+		if value < c.MinFuelLevel {
+			return PreDriveAmber, fmt.Sprintf("fuel below %.0f%%", c.MinFuelLevel)
+		}
+	}
+	return PreDriveGreen, ""
+}
+
+// monitorStatusMIL извлекает состояние MIL из значения PID 01 (бит 7 байта A)
+func monitorStatusMIL(value float64) bool {
+	return (uint32(value)>>24)&0x80 != 0
+}
+
+// monitorStatusDTCCount извлекает количество DTC из значения PID 01 (биты 0-6 байта A)
+func monitorStatusDTCCount(value float64) int {
+	return int((uint32(value) >> 24) & 0x7F)
+}
+
+// worseResult возвращает худшую из двух оценок
+func worseResult(a, b string) string {
+	rank := map[string]int{PreDriveGreen: 0, PreDriveAmber: 1, PreDriveRed: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
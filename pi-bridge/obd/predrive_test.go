@@ -0,0 +1,133 @@
+package obd
+
+import (
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+// observeAll передает ответы в проверку так же, как это делают адаптер и парсер:
+// первый ответ - на ATRV, остальные - ответы OBD
+func observeAll(check *PreDriveCheck, voltage string, responses ...string) {
+	check.ObserveAT("ATRV", voltage)
+	for _, response := range responses {
+		telemetry, _ := ParseResponse(response)
+		check.Observe(response, telemetry)
+	}
+}
+
+func TestPreDriveCheckGreen(t *testing.T) {
+	statusChan := make(chan common.StatusEvent, 1)
+	check := NewPreDriveCheck(DefaultPreDriveCriteria(), statusChan)
+
+	cmds, start, err := check.HandleCommand(nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	start()
+	if len(cmds) != 4 || cmds[0] != "ATRV" {
+		t.Errorf("Unexpected pre-drive command sequence: %v", cmds)
+	}
+
+	// 12.6 В, MIL выключен и нет DTC, 90 °C, топливо 40%
+	observeAll(check, "12.6V", "41 01 00 07 E5 00", "41 05 82", "41 2F 66")
+
+	select {
+	case event := <-statusChan:
+		report := event.Data.(PreDriveReport)
+		if event.Kind != "predrive_check" || report.Result != PreDriveGreen {
+			t.Errorf("Expected green result, got %+v", report)
+		}
+		if *report.Items["battery_voltage"].Value != 12.6 {
+			t.Errorf("Expected battery voltage 12.6, got %v", *report.Items["battery_voltage"].Value)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected pre-drive report to be published")
+	}
+}
+
+func TestPreDriveCheckRedAndAmber(t *testing.T) {
+	statusChan := make(chan common.StatusEvent, 1)
+	check := NewPreDriveCheck(DefaultPreDriveCriteria(), statusChan)
+	_, start, _ := check.HandleCommand(nil)
+	start()
+
+	// Низкий заряд батареи (amber), MIL включен и 2 DTC (red), топливо 10% (amber)
+	observeAll(check, "12.0V", "41 01 82 07 E5 00", "41 05 82", "41 2F 1A")
+
+	event := <-statusChan
+	report := event.Data.(PreDriveReport)
+
+	if report.Result != PreDriveRed {
+		t.Errorf("Expected red result, got %s", report.Result)
+	}
+	if report.Items["battery_voltage"].Result != PreDriveAmber {
+		t.Errorf("Expected amber battery, got %s", report.Items["battery_voltage"].Result)
+	}
+	if report.Items["dtc"].Result != PreDriveRed || *report.Items["dtc"].Value != 2 {
+		t.Errorf("Expected red DTC item with 2 codes, got %+v", report.Items["dtc"])
+	}
+	if report.Items["fuel_level"].Result != PreDriveAmber {
+		t.Errorf("Expected amber fuel level, got %s", report.Items["fuel_level"].Result)
+	}
+}
+
+func TestPreDriveCheckIgnoredWhenInactive(t *testing.T) {
+	statusChan := make(chan common.StatusEvent, 1)
+	check := NewPreDriveCheck(DefaultPreDriveCriteria(), statusChan)
+
+	observeAll(check, "12.6V", "41 01 00 07 E5 00", "41 05 82", "41 2F 66")
+
+	select {
+	case event := <-statusChan:
+		t.Errorf("Unexpected report without active check: %+v", event)
+	default:
+	}
+}
+
+// finishByTimeout завершает проверку так же, как это делает таймаут ожидания ответов
+func finishByTimeout(check *PreDriveCheck) {
+	check.mu.Lock()
+	generation := check.generation
+	check.mu.Unlock()
+	check.finish(generation)
+}
+
+func TestPreDriveCheckMissingResponses(t *testing.T) {
+	tests := []struct {
+		name      string
+		voltage   string
+		responses []string
+		want      string
+		unknown   []string
+	}{
+		{"no response", "", nil, PreDriveAmber, []string{"battery_voltage", "dtc", "coolant_temperature", "fuel_level"}},
+		{"partial response", "12.6V", []string{"41 05 82"}, PreDriveAmber, []string{"dtc", "fuel_level"}},
+		{"partial response with red item", "12.6V", []string{"41 01 82 07 E5 00"}, PreDriveRed, []string{"coolant_temperature", "fuel_level"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			statusChan := make(chan common.StatusEvent, 1)
+			check := NewPreDriveCheck(DefaultPreDriveCriteria(), statusChan)
+			_, start, _ := check.HandleCommand(nil)
+			start()
+
+			if tt.voltage != "" {
+				observeAll(check, tt.voltage, tt.responses...)
+			}
+			finishByTimeout(check)
+
+			report := (<-statusChan).Data.(PreDriveReport)
+			if report.Result != tt.want {
+				t.Errorf("Expected %s result, got %s", tt.want, report.Result)
+			}
+			for _, name := range tt.unknown {
+				if item := report.Items[name]; item.Result != "unknown" || item.Value != nil {
+					t.Errorf("Expected unknown %s, got %+v", name, item)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package obd
+
+import "sync"
+
+// ProtocolFallback перебирает протоколы шины при потере связи, если протокол закреплен
+// в конфигурации адаптера. Автоматический поиск (ATSP0) на некоторых автомобилях
+// выбирает неверный протокол, поэтому вместо него закрепленный протокол и запасные
+// пробуются по очереди командой ATTP, не сохраняющей выбор в адаптере
+type ProtocolFallback struct {
+	mu        sync.Mutex
+	protocols []string // Закрепленный протокол и запасные в порядке перебора
+	current   int      // Индекс протокола, выбранного последним
+}
+
+// NewProtocolFallback создает перебор протоколов. Возвращает nil, если протокол не
+// закреплен: тогда связь восстанавливается автоматическим поиском
+func NewProtocolFallback(protocols []string) *ProtocolFallback {
+	if len(protocols) == 0 {
+		return nil
+	}
+	return &ProtocolFallback{protocols: append([]string(nil), protocols...)}
+}
+
+// OnConnect отмечает возврат к закрепленному протоколу: инициализация адаптера
+// после подключения снова выполняет ATSP (обработчик подключения адаптера)
+func (f *ProtocolFallback) OnConnect() {
+	if f == nil {
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.current = 0
+}
+
+// ReinitCommands возвращает команды повторной инициализации протокола: следующий
+// протокол по кругу, а без закрепленного протокола - автоматический поиск
+func (f *ProtocolFallback) ReinitCommands() []string {
+	if f == nil {
+		return protocolReinitCommands()
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.current = (f.current + 1) % len(f.protocols)
+	protocol := f.protocols[f.current]
+	logger.Printf("Trying bus protocol %s (%s)", protocol, protocolNames[protocol])
+
+	// Автоматический поиск в списке запасных задается как обычно
+	if protocol == "0" {
+		return reinitCommands
+	}
+	return []string{"ATPC", "ATTP" + protocol}
+}
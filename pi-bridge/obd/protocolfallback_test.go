@@ -0,0 +1,49 @@
+package obd
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestProtocolFallback(t *testing.T) {
+	fallback := NewProtocolFallback([]string{"6", "8", "0"})
+
+	expected := [][]string{
+		{"ATPC", "ATTP8"},
+		{"ATPC", "ATSP0"},
+		{"ATPC", "ATTP6"},
+		{"ATPC", "ATTP8"},
+	}
+	for i, want := range expected {
+		if got := fallback.ReinitCommands(); !reflect.DeepEqual(got, want) {
+			t.Errorf("Attempt %d: expected %v, got %v", i+1, want, got)
+		}
+	}
+
+	// Инициализация после подключения снова закрепляет основной протокол
+	fallback.OnConnect()
+	if got := fallback.ReinitCommands(); !reflect.DeepEqual(got, []string{"ATPC", "ATTP8"}) {
+		t.Errorf("Expected fallback to restart after connect, got %v", got)
+	}
+}
+
+func TestProtocolFallbackPinned(t *testing.T) {
+	// Без запасных протоколов связь восстанавливается на закрепленном, а не поиском
+	fallback := NewProtocolFallback([]string{"6"})
+	for i := 0; i < 2; i++ {
+		if got := fallback.ReinitCommands(); !reflect.DeepEqual(got, []string{"ATPC", "ATTP6"}) {
+			t.Errorf("Expected pinned protocol, got %v", got)
+		}
+	}
+}
+
+func TestProtocolFallbackAuto(t *testing.T) {
+	fallback := NewProtocolFallback(nil)
+	if fallback != nil {
+		t.Fatal("Expected no fallback without pinned protocol")
+	}
+	fallback.OnConnect()
+	if got := fallback.ReinitCommands(); !reflect.DeepEqual(got, []string{"ATPC", "ATSP0"}) {
+		t.Errorf("Expected automatic search, got %v", got)
+	}
+}
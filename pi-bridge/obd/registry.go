@@ -0,0 +1,49 @@
+package obd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// registryMu защищает справочные сведения о метриках (metricDetails, metricSources):
+// PID могут регистрироваться во время работы, пока каталог читается из других горутин.
+// Таблица декодеров защищена собственной блокировкой парсера
+var registryMu sync.RWMutex
+
+// RegisterPID добавляет или заменяет декодер PID сервиса 01 во время работы моста,
+// например по определению, полученному через MQTT. Безопасна для вызова параллельно
+// с разбором ответов; новый PID не включается в периодический опрос
+func RegisterPID(pid, name, unit string, decoder PIDDecoder) error {
+	pid = strings.ToUpper(pid)
+	if err := registerPID(pid, name, unit, decoder, MetricSourceRuntime, nil); err != nil {
+		return err
+	}
+	logger.Printf("Registered PID %s at runtime (%s, %s)", pid, name, unit)
+	return nil
+}
+
+// registerPID проверяет и регистрирует декодер PID вместе со сведениями для каталога.
+// detail равен nil, если диапазон и описание метрики неизвестны
+func registerPID(pid, name, unit string, decoder PIDDecoder, source string, detail *metricDetail) error {
+	pid = strings.ToUpper(pid)
+	if _, err := strconv.ParseUint(pid, 16, 8); err != nil || len(pid) != 2 {
+		return fmt.Errorf("PID must be 2 hex digits, got %q", pid)
+	}
+	if name == "" || decoder == nil {
+		return fmt.Errorf("PID %s: name and decoder are required", pid)
+	}
+
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	pidParser.Register(pid, name, unit, decoder)
+	if detail != nil {
+		metricDetails[pid] = *detail
+	} else {
+		delete(metricDetails, pid)
+	}
+	metricSources[pid] = source
+	return nil
+}
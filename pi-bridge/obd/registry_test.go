@@ -0,0 +1,99 @@
+package obd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestRegisterPID(t *testing.T) {
+	defer func() {
+		pidParser.Unregister("5D")
+		delete(metricSources, "5D")
+	}()
+
+	decoder := func(data []byte) (float64, error) {
+		if len(data) != 2 {
+			return 0, fmt.Errorf("PID 5D: ожидалось 2 байта, получено %d", len(data))
+		}
+		return float64(int(data[0])*256+int(data[1]))/128 - 210, nil
+	}
+	if err := RegisterPID("5d", "fuel_injection_timing", "°", decoder); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	telemetry, err := ParseResponse("41 5D 6E 00")
+	if err != nil {
+		t.Fatalf("Unexpected parse error: %v", err)
+	}
+	if telemetry.Metric != "fuel_injection_timing" || telemetry.Value != 10 {
+		t.Errorf("Unexpected telemetry: %+v", telemetry)
+	}
+
+	for _, info := range Catalog() {
+		if info.PID == "5D" && info.Source != MetricSourceRuntime {
+			t.Errorf("Expected runtime source, got %+v", info)
+		}
+	}
+}
+
+func TestRegisterPIDErrors(t *testing.T) {
+	decoder := func(data []byte) (float64, error) { return 0, nil }
+
+	tests := []struct {
+		name    string
+		pid     string
+		metric  string
+		decoder PIDDecoder
+	}{
+		{"Long PID", "5DD", "metric", decoder},
+		{"Not hex", "ZZ", "metric", decoder},
+		{"No name", "5D", "", decoder},
+		{"No decoder", "5D", "metric", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := RegisterPID(tt.pid, tt.metric, "", tt.decoder); err == nil {
+				t.Error("Expected error")
+			}
+		})
+	}
+	if pidParser.Supports("5D") {
+		t.Error("Expected invalid definitions not to be registered")
+	}
+}
+
+func TestRegisterPIDConcurrentWithParsing(t *testing.T) {
+	defer func() {
+		pidParser.Unregister("5D")
+		delete(metricSources, "5D")
+	}()
+	SetLogOutput(io.Discard)
+	defer SetLogOutput(os.Stdout)
+
+	decoder := func(data []byte) (float64, error) { return float64(data[0]), nil }
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			if err := RegisterPID("5D", fmt.Sprintf("metric_%d", i%3), "", decoder); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+			Catalog()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 200; i++ {
+			ParseResponses("41 5D 01 00")
+			ParseResponses("41 0C 1A F0")
+		}
+	}()
+	wg.Wait()
+}
@@ -0,0 +1,67 @@
+package obd
+
+import (
+	"sync"
+	"time"
+
+	"elm327-bridge/common"
+	"elm327-bridge/obd/decoder"
+)
+
+// ResponseFormatReport описывает текущий формат ответов адаптера
+type ResponseFormatReport struct {
+	Format     string    `json:"format"`      // headerless, can_11bit, can_29bit или kline
+	Headers    bool      `json:"headers"`     // Адаптер выводит заголовки (ATH1)
+	DetectedAt time.Time `json:"detected_at"` // Время последней смены формата
+}
+
+// ResponseFormatDetector определяет формат ответов адаптера (с заголовками или без) по
+// приходящим данным. Парсер разбирает оба формата, поэтому формат не настраивается, а
+// только публикуется: по нему видно, применились ли команды инициализации (ATH1) и
+// какой протокол выбрал адаптер
+type ResponseFormatDetector struct {
+	mu         sync.Mutex
+	report     ResponseFormatReport
+	statusChan chan<- common.StatusEvent
+}
+
+// NewResponseFormatDetector создает детектор формата, публикующий изменения в statusChan
+func NewResponseFormatDetector(statusChan chan<- common.StatusEvent) *ResponseFormatDetector {
+	return &ResponseFormatDetector{statusChan: statusChan}
+}
+
+// Observe определяет формат ответа и публикует его при изменении (ResponseObserver)
+func (d *ResponseFormatDetector) Observe(response string, telemetry *Telemetry) {
+	format, ok := decoder.DetectFormat(response)
+	if !ok {
+		return
+	}
+
+	d.mu.Lock()
+	if format == d.report.Format {
+		d.mu.Unlock()
+		return
+	}
+	previous := d.report.Format
+	d.report = ResponseFormatReport{
+		Format:     format,
+		Headers:    format != decoder.FormatHeaderless,
+		DetectedAt: time.Now(),
+	}
+	report := d.report
+	d.mu.Unlock()
+
+	if previous == "" {
+		logger.Printf("Response format detected: %s", format)
+	} else {
+		logger.Printf("Response format changed: %s -> %s", previous, format)
+	}
+	sendStatus("response_format", report, d.statusChan, logger)
+}
+
+// Report возвращает текущий формат ответов (пустой до первого ответа с данными)
+func (d *ResponseFormatDetector) Report() ResponseFormatReport {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.report
+}
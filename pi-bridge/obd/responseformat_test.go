@@ -0,0 +1,39 @@
+package obd
+
+import (
+	"testing"
+
+	"elm327-bridge/common"
+)
+
+func TestResponseFormatDetector(t *testing.T) {
+	statusChan := make(chan common.StatusEvent, 10)
+	detector := NewResponseFormatDetector(statusChan)
+
+	detector.Observe("OK", nil)
+	detector.Observe("7E8 04 41 0C 1A F0", nil)
+	detector.Observe("7E9 04 41 0C 1A F0", nil)
+	detector.Observe("41 0C 1A F0", nil)
+
+	expected := []ResponseFormatReport{
+		{Format: "can_11bit", Headers: true},
+		{Format: "headerless", Headers: false},
+	}
+	if len(statusChan) != len(expected) {
+		t.Fatalf("Expected %d format events, got %d", len(expected), len(statusChan))
+	}
+	for _, want := range expected {
+		event := <-statusChan
+		report, ok := event.Data.(ResponseFormatReport)
+		if !ok || event.Kind != "response_format" || !event.Retained {
+			t.Fatalf("Unexpected event: %+v", event)
+		}
+		if report.Format != want.Format || report.Headers != want.Headers || report.DetectedAt.IsZero() {
+			t.Errorf("Expected %+v, got %+v", want, report)
+		}
+	}
+
+	if report := detector.Report(); report.Format != "headerless" {
+		t.Errorf("Unexpected current format: %+v", report)
+	}
+}
@@ -0,0 +1,177 @@
+package obd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"elm327-bridge/common"
+	"elm327-bridge/obd/decoder"
+)
+
+// SniffCommand - служебная команда моста для прослушивания шины CAN.
+// Формат: "SNIFF [секунды]", например "SNIFF 60"
+const SniffCommand = "SNIFF"
+
+// MonitorAllCommand переводит ELM327 в режим мониторинга: адаптер выводит все кадры шины,
+// пока не получит любой символ
+const MonitorAllCommand = "ATMA"
+
+// Ограничения длительности прослушивания шины
+const (
+	defaultSniffDuration = 30 * time.Second
+	maxSniffDuration     = 10 * time.Minute
+)
+
+// defaultSniffInterval - минимальный интервал публикации кадров одного ID по умолчанию
+const defaultSniffInterval = 100 * time.Millisecond
+
+// SnifferConfig задает ограничение частоты публикации кадров при прослушивании шины
+type SnifferConfig struct {
+	MinInterval time.Duration `yaml:"min_interval"` // Минимальный интервал между кадрами одного ID (0 - 100 мс)
+}
+
+// sniffedID - состояние ограничения частоты для одного идентификатора кадра
+type sniffedID struct {
+	publishedAt time.Time
+	skipped     int
+}
+
+// Sniffer реализует прослушивание шины: адаптер переводится в режим ATMA, а кадры
+// публикуются без декодирования для изучения закрытых протоколов производителя.
+// Частые кадры (десятки в секунду на ID) прореживаются, чтобы не перегружать брокер.
+// Режим занимает адаптер целиком, опрос PID на это время приостанавливается
+type Sniffer struct {
+	mu          sync.Mutex
+	publish     func(common.CANFrame)
+	minInterval time.Duration
+	until       time.Time
+	active      bool
+	ids         map[string]*sniffedID
+	frames      int
+	published   int
+	logger      *log.Logger
+}
+
+// NewSniffer создает обработчик прослушивания шины, передающий кадры в publish.
+// publish вызывается из цикла чтения адаптера и не должен блокироваться
+func NewSniffer(config SnifferConfig, publish func(common.CANFrame)) *Sniffer {
+	if config.MinInterval <= 0 {
+		config.MinInterval = defaultSniffInterval
+	}
+	return &Sniffer{
+		publish:     publish,
+		minInterval: config.MinInterval,
+		logger:      log.New(os.Stdout, "[OBD-Sniffer] ", log.LstdFlags|log.Lshortfile),
+	}
+}
+
+// HandleCommand обрабатывает команду SNIFF и возвращает команды перевода адаптера в режим
+// мониторинга. Заголовки (ATH1) нужны, чтобы в кадрах были идентификаторы
+func (s *Sniffer) HandleCommand(args []string) ([]string, func(), error) {
+	duration := defaultSniffDuration
+	if len(args) > 0 {
+		seconds, err := strconv.Atoi(args[0])
+		if err != nil || seconds <= 0 {
+			return nil, nil, fmt.Errorf("invalid sniff duration: %s", args[0])
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+	if duration > maxSniffDuration {
+		duration = maxSniffDuration
+	}
+
+	start := func() {
+		s.mu.Lock()
+		s.active = true
+		s.until = time.Now().Add(duration)
+		s.ids = make(map[string]*sniffedID)
+		s.frames = 0
+		s.published = 0
+		s.mu.Unlock()
+
+		s.logger.Printf("Sniffing CAN bus for %v (min interval per ID %v)", duration, s.minInterval)
+	}
+	return []string{"ATH1", MonitorAllCommand}, start, nil
+}
+
+// Active сообщает, что прослушивание шины еще не истекло. По истечении срока менеджер
+// команд возобновляет опрос, и первая же команда прерывает режим мониторинга адаптера
+func (s *Sniffer) Active() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.active && time.Now().Before(s.until)
+}
+
+// ObserveFrame обрабатывает строку, выведенную адаптером в режиме мониторинга
+// (обработчик режима мониторинга адаптера)
+func (s *Sniffer) ObserveFrame(line string) {
+	frame, err := decoder.ParseMonitorFrame(line)
+	if err != nil {
+		// Служебные строки адаптера: "BUFFER FULL", "CAN ERROR", "STOPPED"
+		s.logger.Printf("Monitor: %q", line)
+		return
+	}
+
+	now := time.Now()
+	s.mu.Lock()
+	if !s.active {
+		s.mu.Unlock()
+		return
+	}
+	s.frames++
+	state, seen := s.ids[frame.Header]
+	if !seen {
+		state = &sniffedID{}
+		s.ids[frame.Header] = state
+	}
+	if seen && now.Sub(state.publishedAt) < s.minInterval {
+		state.skipped++
+		s.mu.Unlock()
+		return
+	}
+	skipped := state.skipped
+	state.publishedAt = now
+	state.skipped = 0
+	s.published++
+	s.mu.Unlock()
+
+	s.publish(common.CANFrame{
+		ID:        frame.Header,
+		Data:      formatBytes(frame.Data),
+		Skipped:   skipped,
+		Timestamp: now,
+	})
+}
+
+// ObserveAT завершает прослушивание, когда адаптер вышел из режима мониторинга:
+// ответ на ATMA приходит только после его прерывания (обработчик ответов на AT команды)
+func (s *Sniffer) ObserveAT(command, response string) {
+	if strings.ToUpper(strings.ReplaceAll(command, " ", "")) != MonitorAllCommand {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.active {
+		return
+	}
+	s.active = false
+	s.logger.Printf("Sniffing finished: %d frames from %d IDs, %d published", s.frames, len(s.ids), s.published)
+}
+
+// formatBytes форматирует байты в hex через пробел: "01 02 03"
+func formatBytes(data []byte) string {
+	parts := make([]string, len(data))
+	for i, b := range data {
+		parts[i] = fmt.Sprintf("%02X", b)
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,88 @@
+package obd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+func TestSnifferHandleCommand(t *testing.T) {
+	sniffer := NewSniffer(SnifferConfig{}, func(common.CANFrame) {})
+	if sniffer.Active() {
+		t.Fatal("Sniffer must be inactive before SNIFF")
+	}
+
+	commands, start, err := sniffer.HandleCommand([]string{"60"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(commands, []string{"ATH1", MonitorAllCommand}) {
+		t.Errorf("Unexpected commands: %v", commands)
+	}
+	// Разбор команды не запускает прослушивание
+	if sniffer.Active() {
+		t.Error("Expected sniffer to stay inactive until started")
+	}
+	start()
+	if !sniffer.Active() {
+		t.Error("Expected sniffer to be active")
+	}
+
+	// Ответ на ATMA приходит после прерывания мониторинга
+	sniffer.ObserveAT("AT MA", "")
+	if sniffer.Active() {
+		t.Error("Expected sniffer to stop when monitor mode ends")
+	}
+
+	for _, args := range [][]string{{"0"}, {"abc"}} {
+		if _, _, err := sniffer.HandleCommand(args); err == nil {
+			t.Errorf("Expected error for %v", args)
+		}
+	}
+
+	if (*Sniffer)(nil).Active() {
+		t.Error("Nil sniffer must be inactive")
+	}
+}
+
+func TestSnifferRateLimit(t *testing.T) {
+	var published []common.CANFrame
+	sniffer := NewSniffer(SnifferConfig{MinInterval: time.Hour}, func(frame common.CANFrame) {
+		published = append(published, frame)
+	})
+
+	// До команды SNIFF кадры не публикуются
+	sniffer.ObserveFrame("3B4 01 02 03")
+	if len(published) != 0 {
+		t.Fatalf("Expected no frames before SNIFF, got %v", published)
+	}
+
+	_, start, _ := sniffer.HandleCommand(nil)
+	start()
+	sniffer.ObserveFrame("3B4 01 02 03")
+	sniffer.ObserveFrame("3B4 01 02 04")
+	sniffer.ObserveFrame("3B4 01 02 05")
+	sniffer.ObserveFrame("18 FE F1 00 FF FF")
+	sniffer.ObserveFrame("BUFFER FULL")
+
+	if len(published) != 2 {
+		t.Fatalf("Expected one frame per ID, got %v", published)
+	}
+	if published[0].ID != "3B4" || published[0].Data != "01 02 03" || published[0].Skipped != 0 {
+		t.Errorf("Unexpected first frame: %+v", published[0])
+	}
+	if published[1].ID != "18FEF100" || published[1].Data != "FF FF" {
+		t.Errorf("Unexpected 29-bit frame: %+v", published[1])
+	}
+
+	// После интервала публикуется следующий кадр с количеством пропущенных
+	sniffer.mu.Lock()
+	sniffer.ids["3B4"].publishedAt = time.Now().Add(-2 * time.Hour)
+	sniffer.mu.Unlock()
+	sniffer.ObserveFrame("3B4 01 02 06")
+	if len(published) != 3 || published[2].Data != "01 02 06" || published[2].Skipped != 2 {
+		t.Errorf("Expected frame with 2 skipped, got %+v", published[len(published)-1])
+	}
+}
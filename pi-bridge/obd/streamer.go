@@ -0,0 +1,182 @@
+package obd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"elm327-bridge/common"
+	"elm327-bridge/obd/decoder"
+)
+
+// StreamCommand - служебная команда моста для высокочастотного опроса одного PID.
+// Формат: "STREAM <pid> [секунды]", например "STREAM 0C 10"
+const StreamCommand = "STREAM"
+
+// Ограничения длительности потокового режима
+const (
+	defaultStreamDuration = 10 * time.Second
+	maxStreamDuration     = 60 * time.Second
+)
+
+// repeatCommand - пустая команда: адаптер получает только "\r" и повторяет предыдущий запрос
+const repeatCommand = ""
+
+// Streamer реализует потоковый режим: один PID запрашивается повторно сразу после
+// получения ответа, используя повтор последней команды ELM327 одиночным "\r"
+type Streamer struct {
+	mu           sync.Mutex
+	commandsChan chan<- string
+	pid          string
+	until        time.Time
+	startedAt    time.Time
+	samples      int
+	logger       *log.Logger
+}
+
+// NewStreamer создает обработчик потокового режима
+func NewStreamer(commandsChan chan<- string) *Streamer {
+	return &Streamer{
+		commandsChan: commandsChan,
+		logger:       log.New(os.Stdout, "[OBD-Streamer] ", log.LstdFlags|log.Lshortfile),
+	}
+}
+
+// HandleCommand обрабатывает команду STREAM и возвращает первый запрос серии
+func (s *Streamer) HandleCommand(args []string) ([]string, func(), error) {
+	if len(args) == 0 {
+		return nil, nil, fmt.Errorf("usage: %s <pid> [seconds]", StreamCommand)
+	}
+
+	pid := args[0]
+	if !pidParser.Supports(pid) {
+		return nil, nil, fmt.Errorf("unsupported PID for streaming: %s", pid)
+	}
+
+	duration := defaultStreamDuration
+	if len(args) > 1 {
+		seconds, err := strconv.Atoi(args[1])
+		if err != nil || seconds <= 0 {
+			return nil, nil, fmt.Errorf("invalid stream duration: %s", args[1])
+		}
+		duration = time.Duration(seconds) * time.Second
+	}
+	if duration > maxStreamDuration {
+		duration = maxStreamDuration
+	}
+
+	start := func() {
+		s.mu.Lock()
+		s.pid = pid
+		s.startedAt = time.Now()
+		s.until = s.startedAt.Add(duration)
+		s.samples = 0
+		s.mu.Unlock()
+
+		s.logger.Printf("Streaming PID %s for %v", pid, duration)
+	}
+
+	// Суффикс "1" сообщает ELM327, что ожидается один ответ, и ускоряет возврат приглашения
+	return []string{fmt.Sprintf("01%s1", pid)}, start, nil
+}
+
+// Active возвращает PID потокового режима, если режим активен
+func (s *Streamer) Active() (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pid == "" || time.Now().After(s.until) {
+		return "", false
+	}
+	return s.pid, true
+}
+
+// OnSample вызывается парсером после декодирования ответа на потоковый PID
+// и отправляет следующий повтор запроса, пока режим активен
+func (s *Streamer) OnSample() {
+	s.mu.Lock()
+	if s.pid == "" {
+		s.mu.Unlock()
+		return
+	}
+	s.samples++
+	if time.Now().After(s.until) {
+		s.finish()
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+
+	select {
+	case s.commandsChan <- repeatCommand:
+	default:
+		s.logger.Println("Warning: commands channel is full, stopping stream")
+		s.Stop()
+	}
+}
+
+// Stop досрочно завершает потоковый режим
+func (s *Streamer) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pid != "" {
+		s.finish()
+	}
+}
+
+// StopOnFailure досрочно завершает потоковый режим, если неудачный ответ (NO DATA,
+// отрицательный ответ, ошибка разбора) относится к потоковому PID. Ответ на команду
+// клиента с другим PID или ответ другого сервиса поток не прерывает. Ответ, который
+// не удается отнести ни к одной команде (например, NO DATA на повтор), завершает поток
+func (s *Streamer) StopOnFailure(request *common.PendingRequest, response string) {
+	pid, active := s.Active()
+	if !active {
+		return
+	}
+	if request != nil {
+		if request.PID != pid {
+			return
+		}
+	} else if service, responsePID, ok := failedRequest(response); ok && (service != "01" || (responsePID != "" && responsePID != pid)) {
+		return
+	}
+
+	s.logger.Printf("Stream of PID %s failed: %q", pid, response)
+	s.Stop()
+}
+
+// failedRequest определяет по неудачному ответу сервис и PID запроса, если ответ их содержит:
+// отрицательный ответ "7F 01 12" - только сервис, поврежденный ответ "41 0C 1A" - сервис и PID
+func failedRequest(response string) (string, string, bool) {
+	messages, err := decoder.ReassembleResponse(response)
+	if err != nil || len(messages) == 0 || len(messages[0].Payload) == 0 {
+		return "", "", false
+	}
+
+	payload := messages[0].Payload
+	if payload[0] == decoder.NegativeResponseService {
+		if len(payload) < 2 {
+			return "", "", false
+		}
+		return fmt.Sprintf("%02X", payload[1]), "", true
+	}
+	if payload[0] < 0x40 {
+		return "", "", false
+	}
+	service := fmt.Sprintf("%02X", payload[0]-0x40)
+	if len(payload) < 2 {
+		return service, "", true
+	}
+	return service, fmt.Sprintf("%02X", payload[1]), true
+}
+
+// finish завершает серию и выводит достигнутую частоту (вызывается под мьютексом)
+func (s *Streamer) finish() {
+	elapsed := time.Since(s.startedAt).Seconds()
+	if elapsed > 0 {
+		s.logger.Printf("Stream of PID %s finished: %d samples, %.1f samples/s", s.pid, s.samples, float64(s.samples)/elapsed)
+	}
+	s.pid = ""
+}
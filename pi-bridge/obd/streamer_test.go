@@ -0,0 +1,112 @@
+package obd
+
+import (
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+func TestStreamerHandleCommand(t *testing.T) {
+	commandsChan := make(chan string, 10)
+	streamer := NewStreamer(commandsChan)
+
+	if _, _, err := streamer.HandleCommand(nil); err == nil {
+		t.Error("Expected error without PID")
+	}
+
+	if _, _, err := streamer.HandleCommand([]string{"FF"}); err == nil {
+		t.Error("Expected error for unsupported PID")
+	}
+
+	if _, _, err := streamer.HandleCommand([]string{"0C", "abc"}); err == nil {
+		t.Error("Expected error for invalid duration")
+	}
+
+	cmds, start, err := streamer.HandleCommand([]string{"0C", "5"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(cmds) != 1 || cmds[0] != "010C1" {
+		t.Errorf("Expected initial request 010C1, got %v", cmds)
+	}
+	// Разбор команды не запускает поток
+	if _, active := streamer.Active(); active {
+		t.Error("Expected stream to stay inactive until started")
+	}
+	start()
+
+	pid, active := streamer.Active()
+	if !active || pid != "0C" {
+		t.Errorf("Expected active stream for 0C, got %s (%v)", pid, active)
+	}
+}
+
+func TestStreamerOnSample(t *testing.T) {
+	commandsChan := make(chan string, 10)
+	streamer := NewStreamer(commandsChan)
+
+	_, start, err := streamer.HandleCommand([]string{"0C"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	start()
+
+	// Каждый отсчет порождает повтор запроса одиночным "\r"
+	streamer.OnSample()
+	select {
+	case cmd := <-commandsChan:
+		if cmd != repeatCommand {
+			t.Errorf("Expected repeat command, got %q", cmd)
+		}
+	case <-time.After(100 * time.Millisecond):
+		t.Fatal("Expected repeat command to be sent")
+	}
+
+	streamer.Stop()
+	if _, active := streamer.Active(); active {
+		t.Error("Expected stream to be stopped")
+	}
+
+	// После завершения повторы не отправляются
+	streamer.OnSample()
+	select {
+	case cmd := <-commandsChan:
+		t.Errorf("Unexpected command after stop: %q", cmd)
+	default:
+	}
+}
+
+func TestStreamerStopOnFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  *common.PendingRequest
+		response string
+		stopped  bool
+	}{
+		{"client command with other PID", &common.PendingRequest{Command: "010D", PID: "0D"}, "NO DATA", false},
+		{"client AT command", &common.PendingRequest{Command: "ATRV"}, "?", false},
+		{"stream request", &common.PendingRequest{Command: "010C1", PID: "0C"}, "NO DATA", true},
+		{"repeat without data", nil, "NO DATA", true},
+		{"negative response of other service", nil, "7F 22 31", false},
+		{"negative response of service 01", nil, "7F 01 12", true},
+		{"malformed response of other PID", nil, "41 A6 00 01", false},
+		{"malformed response of stream PID", nil, "41 0C 1A", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			streamer := NewStreamer(make(chan string, 10))
+			_, start, err := streamer.HandleCommand([]string{"0C"})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			start()
+
+			streamer.StopOnFailure(tt.request, tt.response)
+			if _, active := streamer.Active(); active == tt.stopped {
+				t.Errorf("Expected stopped %v, got active %v", tt.stopped, active)
+			}
+		})
+	}
+}
@@ -0,0 +1,441 @@
+package obd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"elm327-bridge/common"
+	"elm327-bridge/obd/decoder"
+)
+
+// SupportedTestMIDsCommand - запрос поддерживаемых мониторов бортовых тестов (сервис 06, OBDMID 00)
+const SupportedTestMIDsCommand = "0600"
+
+// TestResultsCommand - служебная команда моста для чтения результатов бортовых тестов.
+// Формат: "TEST_RESULTS [MID ...]"; без аргументов опрашиваются все поддерживаемые мониторы
+const TestResultsCommand = "TEST_RESULTS"
+
+// testResultsService - байт ответа на сервис 06
+const testResultsService = 0x46
+
+// testRecordLength - длина записи теста в ответе CAN: OBDMID, TID, UASID, значение, минимум и максимум
+const testRecordLength = 9
+
+// testResultMetricPrefix - префикс метрик телеметрии со значениями бортовых тестов
+const testResultMetricPrefix = "test_"
+
+// unitScaling описывает пересчет значения теста по идентификатору единицы и масштаба (UASID)
+type unitScaling struct {
+	scale  float64
+	offset float64
+	unit   string
+	signed bool
+}
+
+// unitScalings содержит распространенные UASID (SAE J1979, приложение E).
+// Значения с неизвестным UASID публикуются без пересчета
+var unitScalings = map[byte]unitScaling{
+	0x01: {scale: 1},
+	0x02: {scale: 0.1},
+	0x03: {scale: 0.01},
+	0x04: {scale: 0.001},
+	0x05: {scale: 0.0000305},
+	0x06: {scale: 0.000305},
+	0x07: {scale: 0.25, unit: "rpm"},
+	0x08: {scale: 0.01, unit: "km/h"},
+	0x09: {scale: 1, unit: "km/h"},
+	0x0A: {scale: 0.122, unit: "mV"},
+	0x0B: {scale: 0.001, unit: "V"},
+	0x0C: {scale: 0.01, unit: "V"},
+	0x0D: {scale: 0.00390625, unit: "mA"},
+	0x0E: {scale: 0.001, unit: "A"},
+	0x0F: {scale: 0.01, unit: "A"},
+	0x10: {scale: 1, unit: "ms"},
+	0x11: {scale: 100, unit: "ms"},
+	0x12: {scale: 1, unit: "s"},
+	0x13: {scale: 1, unit: "mΩ"},
+	0x14: {scale: 1, unit: "Ω"},
+	0x15: {scale: 1, unit: "kΩ"},
+	0x16: {scale: 0.1, offset: -40, unit: "°C"},
+	0x17: {scale: 0.01, unit: "kPa"},
+	0x18: {scale: 0.0117, unit: "kPa"},
+	0x19: {scale: 0.079, unit: "kPa"},
+	0x1A: {scale: 1, unit: "kPa"},
+	0x1B: {scale: 10, unit: "kPa"},
+	0x1C: {scale: 0.01, unit: "°"},
+	0x1D: {scale: 0.5, unit: "°"},
+	0x1E: {scale: 0.0000305, unit: "lambda"},
+	0x1F: {scale: 0.05, unit: "ratio"},
+	0x20: {scale: 0.0039062, unit: "ratio"},
+	0x21: {scale: 1, unit: "mHz"},
+	0x22: {scale: 1, unit: "Hz"},
+	0x23: {scale: 1, unit: "kHz"},
+	0x24: {scale: 1, unit: "count"},
+	0x25: {scale: 1, unit: "km"},
+	0x26: {scale: 0.1, unit: "mV/ms"},
+	0x27: {scale: 0.01, unit: "g/s"},
+	0x28: {scale: 1, unit: "g/s"},
+	0x29: {scale: 0.25, unit: "Pa/s"},
+	0x2A: {scale: 0.001, unit: "kg/h"},
+	0x2B: {scale: 1, unit: "count"},
+	0x2C: {scale: 0.01, unit: "g/cyl"},
+	0x2D: {scale: 0.01, unit: "mg/stroke"},
+	0x2F: {scale: 0.01, unit: "%"},
+	0x30: {scale: 0.001526, unit: "%"},
+	0x31: {scale: 0.001, unit: "L"},
+	0x34: {scale: 1, unit: "min"},
+	0x35: {scale: 10, unit: "ms"},
+	0x36: {scale: 0.01, unit: "g"},
+	0x37: {scale: 0.1, unit: "g"},
+	0x38: {scale: 1, unit: "g"},
+	0x39: {scale: 0.01, offset: -327.68, unit: "%"},
+
+	// Значения со знаком
+	0x81: {scale: 1, signed: true},
+	0x82: {scale: 0.1, signed: true},
+	0x83: {scale: 0.01, signed: true},
+	0x84: {scale: 0.001, signed: true},
+	0x85: {scale: 0.0000305, signed: true},
+	0x86: {scale: 0.000305, signed: true},
+	0x8A: {scale: 0.122, unit: "mV", signed: true},
+	0x8B: {scale: 0.001, unit: "V", signed: true},
+	0x8C: {scale: 0.01, unit: "V", signed: true},
+	0x8D: {scale: 0.00390625, unit: "mA", signed: true},
+	0x8E: {scale: 0.001, unit: "A", signed: true},
+	0x90: {scale: 1, unit: "ms", signed: true},
+	0x96: {scale: 0.1, unit: "°C", signed: true},
+	0x9C: {scale: 0.01, unit: "°", signed: true},
+	0x9D: {scale: 0.5, unit: "°", signed: true},
+	0xA8: {scale: 1, unit: "g/s", signed: true},
+	0xA9: {scale: 0.25, unit: "Pa/s", signed: true},
+	0xAD: {scale: 0.01, unit: "mg/stroke", signed: true},
+	0xAF: {scale: 0.01, unit: "%", signed: true},
+	0xB0: {scale: 0.003052, unit: "%", signed: true},
+	0xB1: {scale: 2, unit: "mV/s", signed: true},
+	0xFC: {scale: 0.01, unit: "kPa", signed: true},
+	0xFD: {scale: 0.001, unit: "kPa", signed: true},
+	0xFE: {scale: 0.25, unit: "Pa", signed: true},
+}
+
+// convert пересчитывает двухбайтовое значение теста
+func (s unitScaling) convert(hi, lo byte) float64 {
+	raw := float64(uint16(hi)<<8 | uint16(lo))
+	if s.signed {
+		raw = float64(int16(uint16(hi)<<8 | uint16(lo)))
+	}
+	return raw*s.scale + s.offset
+}
+
+// TestResult представляет результат одного бортового теста монитора. Пределы и признак
+// прохождения отсутствуют, если ЭБУ не сообщает пределы (сервис 05 без min/max)
+type TestResult struct {
+	TID    string   `json:"tid"`            // Идентификатор теста
+	Name   string   `json:"name,omitempty"` // Назначение стандартного теста (сервис 05)
+	Value  float64  `json:"value"`
+	Min    *float64 `json:"min,omitempty"`
+	Max    *float64 `json:"max,omitempty"`
+	Unit   string   `json:"unit"`
+	Passed *bool    `json:"passed,omitempty"` // Значение в пределах [min, max]
+}
+
+// withLimits добавляет к результату пределы и признак прохождения теста
+func (r TestResult) withLimits(min, max float64) TestResult {
+	passed := r.Value >= min && r.Value <= max
+	r.Min, r.Max, r.Passed = &min, &max, &passed
+	return r
+}
+
+// MonitorTestResults представляет результаты тестов одного монитора (OBDMID) одного ЭБУ
+type MonitorTestResults struct {
+	ECU     string       `json:"ecu,omitempty"`
+	MID     string       `json:"mid"`
+	Monitor string       `json:"monitor"`
+	Tests   []TestResult `json:"tests"`
+}
+
+// TestMonitorName возвращает название монитора по OBDMID (SAE J1979, приложение D)
+func TestMonitorName(mid byte) string {
+	switch {
+	case mid >= 0x01 && mid <= 0x10:
+		return fmt.Sprintf("o2_sensor_b%ds%d", (mid-0x01)/4+1, (mid-0x01)%4+1)
+	case mid >= 0x21 && mid <= 0x24:
+		return fmt.Sprintf("catalyst_b%d", mid-0x20)
+	case mid >= 0x31 && mid <= 0x34:
+		return fmt.Sprintf("egr_b%d", mid-0x30)
+	case mid >= 0x35 && mid <= 0x38:
+		return fmt.Sprintf("vvt_b%d", mid-0x34)
+	case mid == 0x39:
+		return "evap_0150"
+	case mid == 0x3A:
+		return "evap_0090"
+	case mid == 0x3B:
+		return "evap_0040"
+	case mid == 0x3C:
+		return "evap_0020"
+	case mid == 0x3D:
+		return "purge_flow"
+	case mid >= 0x41 && mid <= 0x50:
+		return fmt.Sprintf("o2_heater_b%ds%d", (mid-0x41)/4+1, (mid-0x41)%4+1)
+	case mid >= 0x61 && mid <= 0x64:
+		return fmt.Sprintf("heated_catalyst_b%d", mid-0x60)
+	case mid >= 0x71 && mid <= 0x74:
+		return fmt.Sprintf("secondary_air_%d", mid-0x70)
+	case mid >= 0x81 && mid <= 0x84:
+		return fmt.Sprintf("fuel_system_b%d", mid-0x80)
+	case mid >= 0x85 && mid <= 0x88:
+		return fmt.Sprintf("boost_pressure_b%d", mid-0x84)
+	case mid >= 0x90 && mid <= 0x91:
+		return fmt.Sprintf("nox_adsorber_b%d", mid-0x8F)
+	case mid >= 0x98 && mid <= 0x99:
+		return fmt.Sprintf("nox_catalyst_b%d", mid-0x97)
+	case mid == 0xA1:
+		return "misfire"
+	case mid >= 0xA2 && mid <= 0xAD:
+		return fmt.Sprintf("misfire_cylinder_%d", mid-0xA1)
+	case mid >= 0xB0 && mid <= 0xB1:
+		return fmt.Sprintf("pm_filter_b%d", mid-0xAF)
+	}
+	return fmt.Sprintf("monitor_%02X", mid)
+}
+
+// isTestMIDRange проверяет, является ли OBDMID запросом поддерживаемых мониторов (00, 20, ..., E0)
+func isTestMIDRange(mid byte) bool {
+	return mid%0x20 == 0
+}
+
+// DetectTestResults распознает ответ CAN на сервис 06 с результатами тестов
+// ("46 21 91 0B 00 1E 00 00 00 64") и возвращает их по мониторам и ЭБУ.
+// Ответы на запрос поддерживаемых мониторов разбирает SupportedTestMIDs
+func DetectTestResults(response string) ([]MonitorTestResults, bool) {
+	messages, err := decoder.ReassembleResponse(response)
+	if err != nil || len(messages) == 0 {
+		return nil, false
+	}
+
+	var results []MonitorTestResults
+	for _, message := range messages {
+		payload := message.Payload
+		if len(payload) < 1+testRecordLength || payload[0] != testResultsService ||
+			isTestMIDRange(payload[1]) || (len(payload)-1)%testRecordLength != 0 {
+			return nil, false
+		}
+
+		for offset := 1; offset < len(payload); offset += testRecordLength {
+			record := payload[offset : offset+testRecordLength]
+			mid := fmt.Sprintf("%02X", record[0])
+
+			// Записи одного монитора идут подряд и объединяются
+			if len(results) == 0 || results[len(results)-1].MID != mid || results[len(results)-1].ECU != message.ECU {
+				results = append(results, MonitorTestResults{
+					ECU:     message.ECU,
+					MID:     mid,
+					Monitor: TestMonitorName(record[0]),
+				})
+			}
+			current := &results[len(results)-1]
+			current.Tests = append(current.Tests, decodeTestRecord(record))
+		}
+	}
+	return results, true
+}
+
+// decodeTestRecord декодирует запись теста: OBDMID, TID, UASID и три двухбайтовых значения
+func decodeTestRecord(record []byte) TestResult {
+	scaling, ok := unitScalings[record[2]]
+	if !ok {
+		scaling = unitScaling{scale: 1}
+	}
+
+	result := TestResult{
+		TID:   fmt.Sprintf("%02X", record[1]),
+		Value: scaling.convert(record[3], record[4]),
+		Unit:  scaling.unit,
+	}
+	return result.withLimits(scaling.convert(record[5], record[6]), scaling.convert(record[7], record[8]))
+}
+
+// SupportedTestMIDs распознает ответ на запрос поддерживаемых мониторов ("46 00 C0 00 00 01")
+// и возвращает OBDMID из битовой карты всех ЭБУ, включая следующий диапазон (например, "20")
+func SupportedTestMIDs(response string) ([]string, bool) {
+	messages, err := decoder.ReassembleResponse(response)
+	if err != nil || len(messages) == 0 {
+		return nil, false
+	}
+
+	seen := make(map[byte]bool)
+	var mids []string
+	for _, message := range messages {
+		payload := message.Payload
+		if len(payload) != 6 || payload[0] != testResultsService || !isTestMIDRange(payload[1]) {
+			return nil, false
+		}
+
+		base := int(payload[1])
+		for i, b := range payload[2:] {
+			for bit := 0; bit < 8; bit++ {
+				// Диапазона после E0 не существует
+				mid := base + i*8 + bit + 1
+				if b&(0x80>>bit) == 0 || mid > 0xFF {
+					continue
+				}
+				if !seen[byte(mid)] {
+					seen[byte(mid)] = true
+					mids = append(mids, fmt.Sprintf("%02X", mid))
+				}
+			}
+		}
+	}
+	return mids, true
+}
+
+// TestResultTelemetries создает метрики телеметрии со значениями тестов монитора
+// (test_<монитор>_<TID>), чтобы изменение запаса до порога отслеживалось на дашборде
+func TestResultTelemetries(results MonitorTestResults) []*Telemetry {
+	telemetries := make([]*Telemetry, 0, len(results.Tests))
+	for _, test := range results.Tests {
+		telemetries = append(telemetries, &Telemetry{
+			Metric:    testResultMetricPrefix + results.Monitor + "_" + test.TID,
+			Value:     test.Value,
+			Unit:      test.Unit,
+			Timestamp: getCurrentTimestamp(),
+			ECU:       results.ECU,
+		})
+	}
+	return telemetries
+}
+
+// TestResultsScanner опрашивает результаты бортовых тестов. Для CAN (сервис 06) по ответу
+// на запрос поддерживаемых мониторов запрашивает каждый поддерживаемый монитор. Для
+// автомобилей без CAN (сервис 05) каждый ответ содержит один тест, поэтому результаты
+// датчика O2 накапливаются и публикуются целиком
+type TestResultsScanner struct {
+	mu           sync.Mutex
+	commandsChan chan<- string
+	statusChan   chan<- common.StatusEvent
+	o2Results    map[string]*MonitorTestResults // По ЭБУ и датчику
+	logger       *log.Logger
+}
+
+// NewTestResultsScanner создает обработчик опроса результатов бортовых тестов
+func NewTestResultsScanner(commandsChan chan<- string, statusChan chan<- common.StatusEvent) *TestResultsScanner {
+	return &TestResultsScanner{
+		commandsChan: commandsChan,
+		statusChan:   statusChan,
+		o2Results:    make(map[string]*MonitorTestResults),
+		logger:       log.New(os.Stdout, "[OBD-TestResults] ", log.LstdFlags|log.Lshortfile),
+	}
+}
+
+// HandleCommand обрабатывает команду TEST_RESULTS: без аргументов запрашивает поддерживаемые
+// мониторы (результаты остальных придут по мере ответов), иначе - указанные OBDMID
+func (s *TestResultsScanner) HandleCommand(args []string) ([]string, func(), error) {
+	if len(args) == 0 {
+		return []string{SupportedTestMIDsCommand}, nil, nil
+	}
+
+	commands := make([]string, 0, len(args))
+	for _, mid := range args {
+		if len(mid) != 2 || strings.Trim(mid, "0123456789ABCDEFabcdef") != "" {
+			return nil, nil, fmt.Errorf("invalid monitor ID %q: expected 2 hex digits", mid)
+		}
+		commands = append(commands, "06"+strings.ToUpper(mid))
+	}
+	return commands, nil, nil
+}
+
+// HandleO2Command обрабатывает команду O2_MONITOR: запрашивает стандартные тесты сервиса 05
+// для указанных датчиков, без аргументов - для настроенных или датчиков по умолчанию
+func (s *TestResultsScanner) HandleO2Command(args []string) ([]string, func(), error) {
+	sensors, err := normalizeO2Sensors(args)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(sensors) == 0 {
+		sensors = o2MonitorSensors
+	}
+	if len(sensors) == 0 {
+		sensors = defaultO2MonitorSensors
+	}
+	return O2MonitorCommands(sensors), nil, nil
+}
+
+// Observe запрашивает мониторы из ответа на запрос поддерживаемых мониторов
+// и накапливает результаты тестов датчиков O2
+func (s *TestResultsScanner) Observe(response string, telemetry *Telemetry) {
+	if telemetry != nil {
+		return
+	}
+	if results, ok := DetectO2MonitorResults(response); ok {
+		s.observeO2(results)
+		return
+	}
+
+	mids, ok := SupportedTestMIDs(response)
+	if !ok || len(mids) == 0 {
+		return
+	}
+
+	// Следующий диапазон (20, 40, ...) запрашивается так же, его ответ снова придет сюда
+	commands := make([]string, 0, len(mids))
+	for _, mid := range mids {
+		commands = append(commands, "06"+mid)
+	}
+	s.logger.Printf("Requesting on-board test results for monitors %v", mids)
+
+	// Отправка не должна блокировать парсер, из которого вызывается метод
+	go func() {
+		for _, command := range commands {
+			s.commandsChan <- command
+		}
+	}()
+}
+
+// observeO2 обновляет накопленные результаты датчиков O2 и публикует их
+func (s *TestResultsScanner) observeO2(results []MonitorTestResults) {
+	for _, result := range results {
+		key := result.ECU + "/" + result.MID
+
+		s.mu.Lock()
+		accumulated, exists := s.o2Results[key]
+		if !exists {
+			accumulated = &MonitorTestResults{ECU: result.ECU, MID: result.MID, Monitor: result.Monitor}
+			s.o2Results[key] = accumulated
+		}
+		for _, test := range result.Tests {
+			accumulated.Tests = mergeTestResult(accumulated.Tests, test)
+		}
+		snapshot := *accumulated
+		snapshot.Tests = append([]TestResult(nil), accumulated.Tests...)
+		s.mu.Unlock()
+
+		event := common.StatusEvent{
+			Kind:      "test_results/" + snapshot.Monitor,
+			Data:      snapshot,
+			Retained:  true,
+			Timestamp: time.Now(),
+		}
+		select {
+		case s.statusChan <- event:
+		default:
+			s.logger.Printf("Warning: status channel is full, dropping %s results", snapshot.Monitor)
+		}
+	}
+}
+
+// mergeTestResult заменяет результат теста с тем же TID или добавляет новый, сохраняя порядок по TID
+func mergeTestResult(tests []TestResult, test TestResult) []TestResult {
+	for i := range tests {
+		if tests[i].TID == test.TID {
+			tests[i] = test
+			return tests
+		}
+	}
+	tests = append(tests, test)
+	sort.Slice(tests, func(i, j int) bool { return tests[i].TID < tests[j].TID })
+	return tests
+}
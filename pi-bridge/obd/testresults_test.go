@@ -0,0 +1,169 @@
+package obd
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"elm327-bridge/common"
+)
+
+func TestDetectTestResults(t *testing.T) {
+	tests := []struct {
+		name     string
+		response string
+		expected []MonitorTestResults
+	}{
+		{
+			name:     "Catalyst bank 1, headerless",
+			response: "46 21 91 0B 00 1E 00 00 00 64",
+			expected: []MonitorTestResults{{MID: "21", Monitor: "catalyst_b1", Tests: []TestResult{
+				TestResult{TID: "91", Value: 0.03, Unit: "V"}.withLimits(0, 0.1),
+			}}},
+		},
+		{
+			name: "O2 sensor, two tests in multi-frame response",
+			response: "7E8 10 13 46 01 01 0A 0B B8 00 00\r" +
+				"7E8 21 0F A0 01 02 16 02 58 03\r" +
+				"7E8 22 20 FF FF 00 00 00 00 00",
+			expected: []MonitorTestResults{{ECU: "7E8", MID: "01", Monitor: "o2_sensor_b1s1", Tests: []TestResult{
+				TestResult{TID: "01", Value: 366, Unit: "mV"}.withLimits(0, 488),
+				TestResult{TID: "02", Value: 20, Unit: "°C"}.withLimits(40, 6513.5),
+			}}},
+		},
+		{
+			name:     "Signed misfire counts",
+			response: "46 A2 0B 81 FF FE FF F6 00 0A",
+			expected: []MonitorTestResults{{MID: "A2", Monitor: "misfire_cylinder_1", Tests: []TestResult{
+				TestResult{TID: "0B", Value: -2}.withLimits(-10, 10),
+			}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, ok := DetectTestResults(tt.response)
+			if !ok {
+				t.Fatalf("Expected test results in %q", tt.response)
+			}
+			if len(results) != len(tt.expected) {
+				t.Fatalf("Expected %d monitors, got %+v", len(tt.expected), results)
+			}
+			for i, monitor := range results {
+				expected := tt.expected[i]
+				if monitor.ECU != expected.ECU || monitor.MID != expected.MID || monitor.Monitor != expected.Monitor ||
+					len(monitor.Tests) != len(expected.Tests) {
+					t.Fatalf("Expected %+v, got %+v", expected, monitor)
+				}
+				for j, test := range monitor.Tests {
+					want := expected.Tests[j]
+					if test.Min == nil || test.Max == nil || test.Passed == nil {
+						t.Fatalf("Test %d: expected limits, got %+v", j, test)
+					}
+					if test.TID != want.TID || test.Unit != want.Unit || *test.Passed != *want.Passed ||
+						math.Abs(test.Value-want.Value) > 0.01 || math.Abs(*test.Min-*want.Min) > 0.01 || math.Abs(*test.Max-*want.Max) > 0.01 {
+						t.Errorf("Test %d: expected %v %v-%v, got %v %v-%v", j, want.Value, *want.Min, *want.Max, test.Value, *test.Min, *test.Max)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestDetectTestResultsRejects(t *testing.T) {
+	responses := []string{
+		"41 0C 1A F0",          // Текущие данные
+		"46 00 C0 00 00 01",    // Поддерживаемые мониторы
+		"46 01 01 0A 0B B8 00", // Запись не кратна 9 байтам (формат не CAN)
+		"43 01 33 00 00 00 00", // DTC
+	}
+	for _, response := range responses {
+		if results, ok := DetectTestResults(response); ok {
+			t.Errorf("Expected %q not to be test results, got %+v", response, results)
+		}
+	}
+}
+
+func TestSupportedTestMIDs(t *testing.T) {
+	tests := []struct {
+		response string
+		expected []string
+	}{
+		{"46 00 C0 00 00 01", []string{"01", "02", "20"}},
+		{"7E8 06 46 20 80 00 00 00\r7E9 06 46 20 80 00 00 00", []string{"21"}},
+		{"46 E0 00 00 00 01", nil}, // Диапазона после E0 нет
+	}
+	for _, tt := range tests {
+		mids, ok := SupportedTestMIDs(tt.response)
+		if !ok {
+			t.Errorf("Expected supported monitors in %q", tt.response)
+			continue
+		}
+		if !reflect.DeepEqual(mids, tt.expected) {
+			t.Errorf("%q: expected %v, got %v", tt.response, tt.expected, mids)
+		}
+	}
+
+	if _, ok := SupportedTestMIDs("46 21 91 0B 00 1E 00 00 00 64"); ok {
+		t.Error("Expected test results not to be a support bitmap")
+	}
+}
+
+func TestTestMonitorName(t *testing.T) {
+	tests := map[byte]string{
+		0x01: "o2_sensor_b1s1",
+		0x06: "o2_sensor_b2s2",
+		0x22: "catalyst_b2",
+		0x3C: "evap_0020",
+		0x42: "o2_heater_b1s2",
+		0xA1: "misfire",
+		0xAD: "misfire_cylinder_12",
+		0xF1: "monitor_F1",
+	}
+	for mid, expected := range tests {
+		if name := TestMonitorName(mid); name != expected {
+			t.Errorf("MID %02X: expected %s, got %s", mid, expected, name)
+		}
+	}
+}
+
+func TestTestResultTelemetries(t *testing.T) {
+	results, _ := DetectTestResults("46 21 91 0B 00 1E 00 00 00 64")
+	telemetries := TestResultTelemetries(results[0])
+	if len(telemetries) != 1 {
+		t.Fatalf("Expected 1 telemetry, got %d", len(telemetries))
+	}
+	if telemetries[0].Metric != "test_catalyst_b1_91" || telemetries[0].Unit != "V" {
+		t.Errorf("Unexpected telemetry: %+v", telemetries[0])
+	}
+}
+
+func TestTestResultsScanner(t *testing.T) {
+	commandsChan := make(chan string, 10)
+	scanner := NewTestResultsScanner(commandsChan, make(chan common.StatusEvent, 1))
+
+	commands, _, err := scanner.HandleCommand(nil)
+	if err != nil || !reflect.DeepEqual(commands, []string{SupportedTestMIDsCommand}) {
+		t.Errorf("Unexpected commands: %v (%v)", commands, err)
+	}
+	commands, _, err = scanner.HandleCommand([]string{"21", "a2"})
+	if err != nil || !reflect.DeepEqual(commands, []string{"0621", "06A2"}) {
+		t.Errorf("Unexpected commands: %v (%v)", commands, err)
+	}
+	if _, _, err := scanner.HandleCommand([]string{"XYZ"}); err == nil {
+		t.Error("Expected error for invalid monitor ID")
+	}
+
+	scanner.Observe("46 00 80 00 00 01", nil)
+	for _, expected := range []string{"0601", "0620"} {
+		select {
+		case command := <-commandsChan:
+			if command != expected {
+				t.Errorf("Expected %s, got %s", expected, command)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Expected request %s", expected)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package obd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"elm327-bridge/obd/decoder"
+)
+
+// TopologyProbeCommand - служебная команда моста для опроса топологии сети автомобиля.
+// Вариант "PROBE_TOPOLOGY UDS" дополнительно выполняет перебор адресов UDS Tester Present
+const TopologyProbeCommand = "PROBE_TOPOLOGY"
+
+// Диапазон физических адресов запросов ЭБУ для 11-битного CAN (ISO 15765-4)
+const (
+	firstPhysicalRequestAddr = 0x7E0
+	lastPhysicalRequestAddr  = 0x7E7
+	functionalRequestHeader  = "7DF"
+)
+
+// knownModuleNames содержит общепринятые названия модулей по адресу ответа
+var knownModuleNames = map[string]string{
+	"7E8": "engine",
+	"7E9": "transmission",
+}
+
+// ECUModule представляет обнаруженный модуль (ЭБУ) в сети автомобиля
+type ECUModule struct {
+	Address      string    `json:"address"`                  // Адрес ответа ЭБУ (например, "7E8")
+	Name         string    `json:"name"`                     // Название модуля
+	DiscoveredBy []string  `json:"discovered_by"`            // Способы обнаружения: "obd", "uds"
+	SupportedPID string    `json:"supported_pids,omitempty"` // Битовая карта PID 01-20 (ответ на 0100)
+	LastSeen     time.Time `json:"last_seen"`
+}
+
+// Topology накапливает сведения об обнаруженных модулях сети автомобиля
+type Topology struct {
+	mu      sync.Mutex
+	modules map[string]*ECUModule
+}
+
+// NewTopology создает пустую карту модулей
+func NewTopology() *Topology {
+	return &Topology{
+		modules: make(map[string]*ECUModule),
+	}
+}
+
+// Observe анализирует ответ ELM327 и регистрирует ответившие модули.
+// Возвращает true, если был обнаружен новый модуль или способ его обнаружения
+func (t *Topology) Observe(response string) bool {
+	changed := false
+
+	for _, line := range strings.Split(response, "\r") {
+		frame, err := decoder.ParseCANFrame(line)
+		if err != nil || len(frame.Data) < 2 {
+			continue
+		}
+
+		// Первый байт - длина (PCI), второй - сервис ответа
+		service := frame.Data[1]
+		var method, supported string
+		switch {
+		case service == 0x41 && len(frame.Data) >= 7 && frame.Data[2] == 0x00:
+			method = "obd"
+			supported = fmt.Sprintf("%02X%02X%02X%02X", frame.Data[3], frame.Data[4], frame.Data[5], frame.Data[6])
+		case service == 0x7E, service == 0x7F && len(frame.Data) >= 3 && frame.Data[2] == 0x3E:
+			method = "uds"
+		default:
+			continue
+		}
+
+		if t.record(frame.Source, method, supported) {
+			changed = true
+		}
+	}
+
+	return changed
+}
+
+// record добавляет или обновляет модуль
+func (t *Topology) record(address, method, supported string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	module, exists := t.modules[address]
+	if !exists {
+		name, known := knownModuleNames[address]
+		if !known {
+			name = "ecu_" + address
+		}
+		module = &ECUModule{Address: address, Name: name}
+		t.modules[address] = module
+	}
+	module.LastSeen = time.Now()
+	if supported != "" {
+		module.SupportedPID = supported
+	}
+
+	for _, m := range module.DiscoveredBy {
+		if m == method {
+			return !exists
+		}
+	}
+	module.DiscoveredBy = append(module.DiscoveredBy, method)
+	return true
+}
+
+// Modules возвращает список обнаруженных модулей, отсортированный по адресу
+func (t *Topology) Modules() []ECUModule {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	modules := make([]ECUModule, 0, len(t.modules))
+	for _, m := range t.modules {
+		copied := *m
+		copied.DiscoveredBy = append([]string(nil), m.DiscoveredBy...)
+		modules = append(modules, copied)
+	}
+	sort.Slice(modules, func(i, j int) bool { return modules[i].Address < modules[j].Address })
+	return modules
+}
+
+// TopologyProbeCommands возвращает последовательность команд ELM327 для опроса топологии
+func TopologyProbeCommands(udsSweep bool) []string {
+	commands := []string{"ATH1", "0100"}
+	if udsSweep {
+		for addr := firstPhysicalRequestAddr; addr <= lastPhysicalRequestAddr; addr++ {
+			commands = append(commands, fmt.Sprintf("ATSH %03X", addr), "3E00")
+		}
+		// Возвращаем функциональный (широковещательный) заголовок
+		commands = append(commands, "ATSH "+functionalRequestHeader)
+	}
+	return commands
+}
+
+// handleTopologyProbe раскрывает команду PROBE_TOPOLOGY [UDS]
+func handleTopologyProbe(args []string) ([]string, func(), error) {
+	return TopologyProbeCommands(len(args) > 0 && args[0] == "UDS"), nil, nil
+}
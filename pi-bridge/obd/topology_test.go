@@ -0,0 +1,42 @@
+package obd
+
+import (
+	"testing"
+)
+
+func TestTopologyObserve(t *testing.T) {
+	topology := NewTopology()
+
+	// Широковещательный 0100: отвечают двигатель и коробка передач
+	response := "7E8 06 41 00 BE 3F A8 13\r7E9 06 41 00 98 18 80 01\r"
+	if !topology.Observe(response) {
+		t.Fatal("Expected topology change after first response")
+	}
+
+	// Повторный ответ не меняет карту
+	if topology.Observe(response) {
+		t.Error("Expected no change for repeated response")
+	}
+
+	// UDS Tester Present от дополнительного модуля
+	if !topology.Observe("7EA 02 7E 00\r") {
+		t.Error("Expected change for new UDS module")
+	}
+
+	modules := topology.Modules()
+	if len(modules) != 3 {
+		t.Fatalf("Expected 3 modules, got %d", len(modules))
+	}
+
+	if modules[0].Address != "7E8" || modules[0].Name != "engine" || modules[0].SupportedPID != "BE3FA813" {
+		t.Errorf("Unexpected engine module: %+v", modules[0])
+	}
+
+	if modules[1].Name != "transmission" {
+		t.Errorf("Expected transmission module, got %s", modules[1].Name)
+	}
+
+	if modules[2].Name != "ecu_7EA" || modules[2].DiscoveredBy[0] != "uds" {
+		t.Errorf("Unexpected UDS module: %+v", modules[2])
+	}
+}
@@ -0,0 +1,75 @@
+package obd
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Системы единиц измерения публикуемых значений
+const (
+	UnitsMetric   = "metric"
+	UnitsImperial = "imperial"
+)
+
+// unitConversion описывает перевод метрической единицы в имперскую
+type unitConversion struct {
+	unit    string
+	convert func(value float64) float64
+}
+
+// imperialUnits содержит переводы по метрической единице измерения.
+// Единицы, которых нет в таблице (%, rpm, V и т.п.), публикуются без изменений
+var imperialUnits = map[string]unitConversion{
+	"km/h": {"mph", func(v float64) float64 { return v * 0.621371 }},
+	"km":   {"mi", func(v float64) float64 { return v * 0.621371 }},
+	"°C":   {"°F", func(v float64) float64 { return v*9/5 + 32 }},
+	"kPa":  {"psi", func(v float64) float64 { return v * 0.145038 }},
+	"L":    {"gal", func(v float64) float64 { return v * 0.264172 }},
+	"L/h":  {"gal/h", func(v float64) float64 { return v * 0.264172 }},
+	"g/s":  {"lb/min", func(v float64) float64 { return v * 0.132277 }},
+	"m/s²": {"ft/s²", func(v float64) float64 { return v * 3.28084 }},
+	// Расход на 100 км обратно пропорционален пробегу на галлон (US)
+	"L/100km": {"mpg", func(v float64) float64 {
+		if v <= 0 {
+			return 0
+		}
+		return 235.215 / v
+	}},
+}
+
+// unitSystem - система единиц публикуемых значений, задается при запуске
+var unitSystem = UnitsMetric
+
+// SetUnitSystem задает систему единиц: metric (по умолчанию) или imperial.
+// Вызывается при запуске до старта парсера
+func SetUnitSystem(system string) error {
+	switch strings.ToLower(strings.TrimSpace(system)) {
+	case "", UnitsMetric:
+		unitSystem = UnitsMetric
+	case UnitsImperial:
+		unitSystem = UnitsImperial
+	default:
+		return fmt.Errorf("unknown unit system %q (expected %s or %s)", system, UnitsMetric, UnitsImperial)
+	}
+	return nil
+}
+
+// ConvertValue переводит значение в выбранную систему единиц и возвращает новую единицу
+func ConvertValue(value float64, unit string) (float64, string) {
+	if unitSystem != UnitsImperial {
+		return value, unit
+	}
+	conversion, ok := imperialUnits[unit]
+	if !ok {
+		return value, unit
+	}
+	return conversion.convert(value), conversion.unit
+}
+
+// ConvertTelemetry переводит значение и единицу телеметрии в выбранную систему единиц
+// и округляет значение до заданной точности. Декодеры и пороги проверок работают
+// в метрических единицах без округления, поэтому перевод выполняется только перед публикацией
+func ConvertTelemetry(telemetry *Telemetry) {
+	telemetry.Value, telemetry.Unit = ConvertValue(telemetry.Value, telemetry.Unit)
+	telemetry.Value = RoundValue(telemetry.Metric, telemetry.Value)
+}
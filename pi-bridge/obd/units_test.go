@@ -0,0 +1,62 @@
+package obd
+
+import (
+	"math"
+	"testing"
+)
+
+func TestConvertValue(t *testing.T) {
+	if err := SetUnitSystem(UnitsImperial); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer SetUnitSystem(UnitsMetric)
+
+	tests := []struct {
+		value    float64
+		unit     string
+		expected float64
+		newUnit  string
+	}{
+		{100, "km/h", 62.1371, "mph"},
+		{100, "°C", 212, "°F"},
+		{-40, "°C", -40, "°F"},
+		{101, "kPa", 14.6488, "psi"},
+		{1724, "rpm", 1724, "rpm"},
+		{50, "%", 50, "%"},
+	}
+
+	for _, tt := range tests {
+		value, unit := ConvertValue(tt.value, tt.unit)
+		if math.Abs(value-tt.expected) > 0.001 || unit != tt.newUnit {
+			t.Errorf("ConvertValue(%v, %s) = %v %s, expected %v %s", tt.value, tt.unit, value, unit, tt.expected, tt.newUnit)
+		}
+	}
+}
+
+func TestConvertValueMetric(t *testing.T) {
+	if err := SetUnitSystem(""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if value, unit := ConvertValue(100, "km/h"); value != 100 || unit != "km/h" {
+		t.Errorf("Expected metric values to be unchanged, got %v %s", value, unit)
+	}
+	if err := SetUnitSystem("furlongs"); err == nil {
+		t.Error("Expected error for unknown unit system")
+	}
+}
+
+func TestCatalogImperial(t *testing.T) {
+	SetUnitSystem(UnitsImperial)
+	defer SetUnitSystem(UnitsMetric)
+
+	for _, info := range Catalog() {
+		if info.PID != "05" {
+			continue
+		}
+		if info.Unit != "°F" || *info.Min != -40 || *info.Max != 419 {
+			t.Errorf("Unexpected imperial coolant entry: %+v", info)
+		}
+		return
+	}
+	t.Error("PID 05 not found in catalog")
+}
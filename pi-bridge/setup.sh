@@ -61,7 +61,6 @@ cat > /etc/systemd/system/elm327-bridge.service << 'EOF'
 Description=ELM327 Bridge Service
 After=bluetooth.service
 Wants=bluetooth.service
-Requires=/dev/rfcomm0
 
 [Service]
 Type=simple
@@ -77,7 +76,7 @@ NoNewPrivileges=true
 PrivateTmp=true
 ProtectSystem=strict
 ProtectHome=true
-ReadWritePaths=/home/pi/elm327-bridge /dev/rfcomm0
+ReadWritePaths=/home/pi/elm327-bridge -/dev/rfcomm0
 
 [Install]
 WantedBy=multi-user.target
@@ -116,10 +115,9 @@ trust $mac_address
 exit
 BT_EOF
 
-echo "🔌 Создание RFCOMM соединения..."
-rfcomm bind rfcomm0 $mac_address 1
-
 echo "✅ Настройка завершена!"
+echo "Укажите в config.yaml: bluetooth.address: \"$mac_address\" и bluetooth.rfcomm_bind: true"
+echo "Мост сам создаст /dev/rfcomm0 при запуске"
 echo "Теперь можно запустить сервис: sudo systemctl start elm327-bridge"
 EOF
 
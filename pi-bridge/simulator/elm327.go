@@ -0,0 +1,384 @@
+// Package simulator эмулирует адаптер ELM327 для тестов и нагрузочных прогонов без автомобиля
+package simulator
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultECU - адрес ответа ЭБУ двигателя при включенных заголовках
+const DefaultECU = "7E8"
+
+// DefaultResponses возвращает ответы на стандартные запросы (данные без заголовков)
+func DefaultResponses() map[string]string {
+	return map[string]string{
+		"0100": "41 00 BE 3F A8 13",
+		"0101": "41 01 00 07 E5 00",
+		"0104": "41 04 4C",
+		"0105": "41 05 82",
+		"010B": "41 0B 65",
+		"010C": "41 0C 1A F8",
+		"010D": "41 0D 3C",
+		"010F": "41 0F 46",
+		"0110": "41 10 01 F4",
+		"0111": "41 11 33",
+		"012F": "41 2F 66",
+		"0142": "41 42 31 3A",
+	}
+}
+
+// ELM327 эмулирует адаптер: принимает команды через Write и возвращает ответы
+// с приглашением ">" через Read. Эхо выключено, если не задано Echo
+type ELM327 struct {
+	// Latency - задержка перед ответом, имитирующая обмен с автомобилем (задавать до первой команды)
+	Latency time.Duration
+	// SearchDelay - длительность определения протокола перед первым запросом к шине
+	// после сброса или ATSP/ATPC (0 - протокол известен сразу). Во время поиска адаптер
+	// выводит "SEARCHING...", а любая новая команда прерывает запрос ответом "STOPPED"
+	SearchDelay time.Duration
+	// MonitorFrames - кадры шины, которые адаптер выводит по кругу в режиме мониторинга (ATMA),
+	// пока не получит любой символ (задавать до первой команды)
+	MonitorFrames []string
+	// MonitorInterval - пауза между кадрами режима мониторинга (0 - 10 мс)
+	MonitorInterval time.Duration
+	// Terminator - символ, завершающий команды и строки ответа (0 - "\r"). Другие символы
+	// перевода строки в командах игнорируются (задавать до первой команды)
+	Terminator byte
+	// Prompt - символ приглашения после ответа (0 - ">"), NoPrompt - приглашение не выводится
+	// (задавать до первой команды)
+	Prompt   byte
+	NoPrompt bool
+	// Особенности клонов ELM327 v1.5 (задавать до первой команды): Echo - каждая команда
+	// повторяется перед ответом независимо от ATE0, DoublePrompt - после ответа выводится
+	// лишнее приглашение, ResetDelay - задержка ответа на сброс
+	Echo         bool
+	DoublePrompt bool
+	ResetDelay   time.Duration
+
+	mu          sync.Mutex
+	responses   map[string]string
+	headers     bool
+	protocol    bool // Протокол шины уже определен
+	lastCommand string
+	input       []byte
+
+	commands chan string
+	activity chan struct{} // Сигнал о любых полученных байтах (прерывает мониторинг)
+	output   chan []byte
+	leftover []byte
+	done     chan struct{}
+	closed   sync.Once
+	requests atomic.Uint64
+}
+
+// New создает симулятор с заданной таблицей ответов (nil - ответы по умолчанию)
+func New(responses map[string]string) *ELM327 {
+	if responses == nil {
+		responses = DefaultResponses()
+	}
+
+	e := &ELM327{
+		responses: make(map[string]string, len(responses)),
+		commands:  make(chan string, 64),
+		activity:  make(chan struct{}, 1),
+		output:    make(chan []byte, 64),
+		done:      make(chan struct{}),
+	}
+	for command, response := range responses {
+		e.responses[normalize(command)] = response
+	}
+
+	go e.serve()
+	return e
+}
+
+// SetResponse задает ответ на команду
+func (e *ELM327) SetResponse(command, response string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.responses[normalize(command)] = response
+}
+
+// Requests возвращает количество обработанных команд
+func (e *ELM327) Requests() uint64 {
+	return e.requests.Load()
+}
+
+// Write принимает команды, завершенные Terminator ("\r")
+func (e *ELM327) Write(p []byte) (int, error) {
+	select {
+	case <-e.done:
+		return 0, io.ErrClosedPipe
+	default:
+	}
+
+	e.mu.Lock()
+	e.input = append(e.input, p...)
+	var commands []string
+	for {
+		end := bytes.IndexByte(e.input, e.terminator())
+		if end < 0 {
+			break
+		}
+		commands = append(commands, strings.NewReplacer("\r", "", "\n", "").Replace(string(e.input[:end])))
+		e.input = e.input[end+1:]
+	}
+	e.mu.Unlock()
+
+	select {
+	case e.activity <- struct{}{}:
+	default:
+	}
+
+	for _, command := range commands {
+		select {
+		case e.commands <- command:
+		case <-e.done:
+			return 0, io.ErrClosedPipe
+		}
+	}
+	return len(p), nil
+}
+
+// Read возвращает ответы адаптера; блокируется до появления данных или закрытия
+func (e *ELM327) Read(p []byte) (int, error) {
+	if len(e.leftover) == 0 {
+		select {
+		case data := <-e.output:
+			e.leftover = data
+		case <-e.done:
+			return 0, io.EOF
+		}
+	}
+
+	n := copy(p, e.leftover)
+	e.leftover = e.leftover[n:]
+	return n, nil
+}
+
+// Close останавливает симулятор
+func (e *ELM327) Close() error {
+	e.closed.Do(func() { close(e.done) })
+	return nil
+}
+
+// serve последовательно обрабатывает команды, как настоящий адаптер
+func (e *ELM327) serve() {
+	for {
+		select {
+		case <-e.done:
+			return
+		case command := <-e.commands:
+			if !e.handle(command) {
+				return
+			}
+		}
+	}
+}
+
+// handle отвечает на одну команду; возвращает false после закрытия симулятора
+func (e *ELM327) handle(command string) bool {
+	if e.Echo && !e.emit(command+"\r") {
+		return false
+	}
+	if e.ResetDelay > 0 && (normalize(command) == "ATZ" || normalize(command) == "ATWS") {
+		time.Sleep(e.ResetDelay)
+	}
+
+	if e.needsSearch(command) {
+		if !e.emit("SEARCHING...\r") {
+			return false
+		}
+		select {
+		case <-time.After(e.SearchDelay):
+			e.mu.Lock()
+			e.protocol = true
+			e.mu.Unlock()
+		case next := <-e.commands:
+			// Любой символ от хоста прерывает поиск
+			e.requests.Add(1)
+			if !e.emit("STOPPED" + e.end()) {
+				return false
+			}
+			return e.handle(next)
+		case <-e.done:
+			return false
+		}
+	}
+
+	if normalize(command) == "ATMA" {
+		e.requests.Add(1)
+		return e.monitor()
+	}
+
+	if e.Latency > 0 {
+		time.Sleep(e.Latency)
+	}
+
+	reply := e.reply(command) + e.end()
+	e.requests.Add(1)
+	return e.emit(reply)
+}
+
+// monitor выводит кадры MonitorFrames по кругу до получения любого символа
+func (e *ELM327) monitor() bool {
+	interval := e.MonitorInterval
+	if interval <= 0 {
+		interval = 10 * time.Millisecond
+	}
+
+	// Сигнал от записи самой команды ATMA
+	select {
+	case <-e.activity:
+	default:
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for i := 0; ; i++ {
+		select {
+		case <-ticker.C:
+			if len(e.MonitorFrames) == 0 {
+				continue
+			}
+			if !e.emit(e.MonitorFrames[i%len(e.MonitorFrames)] + "\r") {
+				return false
+			}
+		case <-e.activity:
+			return e.emit("STOPPED" + e.end())
+		case next := <-e.commands:
+			// Команда пришла целиком раньше, чем был замечен ее первый символ
+			if !e.emit("STOPPED" + e.end()) {
+				return false
+			}
+			return e.handle(next)
+		case <-e.done:
+			return false
+		}
+	}
+}
+
+// needsSearch проверяет, запустит ли команда определение протокола
+func (e *ELM327) needsSearch(command string) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	cmd := normalize(command)
+	if cmd == "" {
+		cmd = e.lastCommand
+	}
+	return e.SearchDelay > 0 && !e.protocol && cmd != "" && !strings.HasPrefix(cmd, "AT")
+}
+
+// terminator возвращает символ завершения команд и строк
+func (e *ELM327) terminator() byte {
+	if e.Terminator == 0 {
+		return '\r'
+	}
+	return e.Terminator
+}
+
+// end возвращает окончание ответа: пустая строка и приглашение
+func (e *ELM327) end() string {
+	if e.NoPrompt {
+		return "\r\r"
+	}
+	prompt := ">"
+	if e.Prompt != 0 {
+		prompt = string(e.Prompt)
+	}
+	if e.DoublePrompt {
+		prompt += prompt
+	}
+	return "\r\r" + prompt
+}
+
+// emit передает данные читателю, заменяя завершение строк на Terminator
+func (e *ELM327) emit(data string) bool {
+	if e.terminator() != '\r' {
+		data = strings.ReplaceAll(data, "\r", string(e.terminator()))
+	}
+	select {
+	case e.output <- []byte(data):
+		return true
+	case <-e.done:
+		return false
+	}
+}
+
+// reply формирует ответ на команду
+func (e *ELM327) reply(command string) string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	cmd := normalize(command)
+	if cmd == "" {
+		// Пустая строка повторяет предыдущую команду
+		cmd = e.lastCommand
+	}
+	e.lastCommand = cmd
+
+	if strings.HasPrefix(cmd, "AT") {
+		return e.replyAT(cmd)
+	}
+
+	response, ok := e.responses[cmd]
+	if !ok && len(cmd) == 5 {
+		// Суффикс количества ожидаемых ответов, например "010C1"
+		response, ok = e.responses[cmd[:4]]
+	}
+	if !ok {
+		return "NO DATA"
+	}
+	if e.headers && !strings.Contains(response, "\r") {
+		return WithHeader(response)
+	}
+	return response
+}
+
+// replyAT обрабатывает AT команды (вызывается под мьютексом)
+func (e *ELM327) replyAT(cmd string) string {
+	if response, ok := e.responses[cmd]; ok {
+		return response
+	}
+
+	if strings.HasPrefix(cmd, "ATSP") || strings.HasPrefix(cmd, "ATTP") || cmd == "ATPC" {
+		e.protocol = false
+	}
+
+	switch cmd {
+	case "ATZ", "ATWS":
+		e.headers = false
+		e.protocol = false
+		return "ELM327 v1.5"
+	case "ATI":
+		return "ELM327 v1.5"
+	case "ATH1":
+		e.headers = true
+	case "ATH0":
+		e.headers = false
+	case "ATRV":
+		return "12.6V"
+	case "ATDPN":
+		return "A6"
+	}
+	return "OK"
+}
+
+// WithHeader добавляет к однокадровому ответу заголовок CAN и байт длины.
+// Многострочные ответы из таблицы возвращаются как есть
+func WithHeader(response string) string {
+	fields := strings.Fields(response)
+	return fmt.Sprintf("%s %02X %s", DefaultECU, len(fields), response)
+}
+
+// normalize приводит команду к виду без пробелов в верхнем регистре
+func normalize(command string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(command), ""))
+}
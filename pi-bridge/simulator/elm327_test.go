@@ -0,0 +1,168 @@
+package simulator
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// exchange отправляет команду и читает ответ до приглашения
+func exchange(t *testing.T, e *ELM327, command string) string {
+	t.Helper()
+	if _, err := e.Write([]byte(command + "\r")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var reply strings.Builder
+	buf := make([]byte, 8)
+	for !strings.HasSuffix(reply.String(), ">") {
+		n, err := e.Read(buf)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		reply.Write(buf[:n])
+	}
+	return strings.TrimSuffix(reply.String(), "\r\r>")
+}
+
+func TestELM327Replies(t *testing.T) {
+	e := New(nil)
+	defer e.Close()
+
+	tests := []struct {
+		command  string
+		expected string
+	}{
+		{"ATZ", "ELM327 v1.5"},
+		{"ATE0", "OK"},
+		{"010C", "41 0C 1A F8"},
+		{"01 0d", "41 0D 3C"},
+		{"0105 1", "41 05 82"},
+		{"01FF", "NO DATA"},
+		{"ATH1", "OK"},
+		{"010C", "7E8 04 41 0C 1A F8"},
+		{"", "7E8 04 41 0C 1A F8"},
+		{"ATRV", "12.6V"},
+	}
+
+	for _, tt := range tests {
+		if reply := exchange(t, e, tt.command); reply != tt.expected {
+			t.Errorf("Command %q: expected %q, got %q", tt.command, tt.expected, reply)
+		}
+	}
+
+	if e.Requests() != uint64(len(tests)) {
+		t.Errorf("Expected %d requests, got %d", len(tests), e.Requests())
+	}
+}
+
+func TestELM327SetResponse(t *testing.T) {
+	e := New(map[string]string{})
+	defer e.Close()
+
+	e.SetResponse("22 F1 90", "7F 22 31")
+	if reply := exchange(t, e, "22F190"); reply != "7F 22 31" {
+		t.Errorf("Expected custom response, got %q", reply)
+	}
+}
+
+func TestELM327Close(t *testing.T) {
+	e := New(nil)
+	e.Close()
+
+	if _, err := e.Read(make([]byte, 8)); err != io.EOF {
+		t.Errorf("Expected EOF after close, got %v", err)
+	}
+	if _, err := e.Write([]byte("010C\r")); err == nil {
+		t.Error("Expected write error after close")
+	}
+}
+
+func TestELM327CloneQuirks(t *testing.T) {
+	e := New(nil)
+	e.Echo = true
+	e.DoublePrompt = true
+	defer e.Close()
+
+	e.Write([]byte("010C\r"))
+	var reply strings.Builder
+	buf := make([]byte, 8)
+	for !strings.HasSuffix(reply.String(), ">>") {
+		n, err := e.Read(buf)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		reply.Write(buf[:n])
+	}
+	if reply.String() != "010C\r41 0C 1A F8\r\r>>" {
+		t.Errorf("Expected echo and duplicated prompt, got %q", reply.String())
+	}
+}
+
+func TestELM327ProtocolSearch(t *testing.T) {
+	e := New(nil)
+	e.SearchDelay = 20 * time.Millisecond
+	defer e.Close()
+
+	if reply := exchange(t, e, "010C"); reply != "SEARCHING...\r41 0C 1A F8" {
+		t.Errorf("Expected search before first answer, got %q", reply)
+	}
+	if reply := exchange(t, e, "010D"); reply != "41 0D 3C" {
+		t.Errorf("Expected answer without search, got %q", reply)
+	}
+
+	// Новая команда во время поиска прерывает запрос
+	exchange(t, e, "ATSP0")
+	e.Write([]byte("010C\r010D\r"))
+	var reply strings.Builder
+	buf := make([]byte, 64)
+	for strings.Count(reply.String(), ">") < 2 {
+		n, err := e.Read(buf)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		reply.Write(buf[:n])
+	}
+	if !strings.HasPrefix(reply.String(), "SEARCHING...\rSTOPPED\r\r>") {
+		t.Errorf("Expected interrupted search, got %q", reply.String())
+	}
+}
+
+func TestELM327Monitor(t *testing.T) {
+	e := New(nil)
+	e.MonitorFrames = []string{"3B4 01 02", "18 FE F1 00 FF"}
+	e.MonitorInterval = time.Millisecond
+	defer e.Close()
+
+	if _, err := e.Write([]byte("ATMA\r")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	var output strings.Builder
+	buf := make([]byte, 64)
+	for strings.Count(output.String(), "\r") < 3 {
+		n, err := e.Read(buf)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		output.Write(buf[:n])
+	}
+	if !strings.HasPrefix(output.String(), "3B4 01 02\r18 FE F1 00 FF\r3B4 01 02\r") {
+		t.Errorf("Expected frames in a loop, got %q", output.String())
+	}
+
+	// Любой символ прерывает мониторинг, следующая команда выполняется как обычно
+	e.Write([]byte(" "))
+	output.Reset()
+	for !strings.HasSuffix(output.String(), "STOPPED\r\r>") {
+		n, err := e.Read(buf)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		output.Write(buf[:n])
+	}
+	if reply := exchange(t, e, "ATRV"); reply != "12.6V" {
+		t.Errorf("Expected answer after monitoring, got %q", reply)
+	}
+}
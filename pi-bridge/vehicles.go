@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"elm327-bridge/bluetooth"
+
+	"github.com/go-viper/mapstructure/v2"
+)
+
+// VehicleConfig описывает один автомобиль в процессе, обслуживающем несколько адаптеров.
+// Параметры адаптера берутся из общей секции bluetooth с переопределениями автомобиля
+type VehicleConfig struct {
+	Name      string                 `yaml:"name"`      // Имя автомобиля в журналах (пусто - VIN)
+	VIN       string                 `yaml:"vin"`       // VIN в топиках MQTT
+	ClientID  string                 `yaml:"client_id"` // ID клиента MQTT (пусто - общий ID с суффиксом VIN)
+	Bluetooth map[string]interface{} `yaml:"bluetooth"` // Переопределения параметров адаптера
+
+	adapter bluetooth.Config // Итоговая конфигурация адаптера
+}
+
+// label возвращает имя автомобиля для журналов
+func (v VehicleConfig) label() string {
+	if v.Name != "" {
+		return v.Name
+	}
+	return v.VIN
+}
+
+// resolveVehicles готовит список автомобилей. Без секции vehicles мост обслуживает один
+// адаптер из секции bluetooth, как и раньше; VIN в топиках при этом не задан
+func resolveVehicles(base map[string]interface{}) error {
+	if len(config.Vehicles) == 0 {
+		config.Vehicles = []VehicleConfig{{adapter: config.Bluetooth}}
+		return nil
+	}
+
+	if config.MQTT.Legacy.Enabled && len(config.Vehicles) > 1 {
+		return fmt.Errorf("legacy MQTT topics have no VIN and cannot be used with several vehicles")
+	}
+
+	vins := make(map[string]bool)
+	devices := make(map[string]string)
+	for i := range config.Vehicles {
+		vehicle := &config.Vehicles[i]
+		if vehicle.VIN == "" {
+			return fmt.Errorf("vehicle %d: vin must be set", i+1)
+		}
+		if strings.ContainsAny(vehicle.VIN, "/+#") {
+			return fmt.Errorf("vehicle %s: invalid VIN %q for MQTT topic", vehicle.VIN, vehicle.VIN)
+		}
+		if vins[vehicle.VIN] {
+			return fmt.Errorf("duplicate vehicle VIN %s", vehicle.VIN)
+		}
+		vins[vehicle.VIN] = true
+
+		adapter, err := vehicleAdapterConfig(base, vehicle.Bluetooth)
+		if err != nil {
+			return fmt.Errorf("vehicle %s: %v", vehicle.label(), err)
+		}
+		if err := validateBluetooth(adapter); err != nil {
+			return fmt.Errorf("vehicle %s: %v", vehicle.label(), err)
+		}
+		if profile := adapter.ApplyInitProfile(vehicle.VIN); profile != "" {
+			logger.Printf("Vehicle %s: using init profile %s", vehicle.label(), profile)
+		}
+		applyBluetoothSettings(&adapter)
+
+		// Два экземпляра моста не могут делить один адаптер
+		if device := adapterEndpoint(adapter); device != "" {
+			if other, exists := devices[device]; exists {
+				return fmt.Errorf("vehicles %s and %s use the same adapter %s", other, vehicle.label(), device)
+			}
+			devices[device] = vehicle.label()
+		}
+
+		vehicle.adapter = adapter
+	}
+	return nil
+}
+
+// vehicleAdapterConfig накладывает переопределения автомобиля на общую секцию bluetooth и
+// декодирует результат так же, как основную конфигурацию
+func vehicleAdapterConfig(base, overrides map[string]interface{}) (bluetooth.Config, error) {
+	adapter := bluetooth.DefaultConfig()
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		TagName:          "yaml",
+		ZeroFields:       true,
+		WeaklyTypedInput: true,
+		DecodeHook: mapstructure.ComposeDecodeHookFunc(
+			mapstructure.StringToTimeDurationHookFunc(),
+			mapstructure.StringToSliceHookFunc(","),
+		),
+		Result: &adapter,
+	})
+	if err != nil {
+		return adapter, err
+	}
+	if err := decoder.Decode(mergeSections(base, overrides)); err != nil {
+		return adapter, fmt.Errorf("invalid bluetooth overrides: %v", err)
+	}
+	return adapter, nil
+}
+
+// mergeSections накладывает значения overrides на base; вложенные секции объединяются
+// по ключам, списки и значения заменяются целиком
+func mergeSections(base, overrides map[string]interface{}) map[string]interface{} {
+	merged := make(map[string]interface{}, len(base)+len(overrides))
+	for key, value := range base {
+		merged[strings.ToLower(key)] = value
+	}
+	for key, value := range overrides {
+		key = strings.ToLower(key)
+		baseSection, baseIsMap := merged[key].(map[string]interface{})
+		section, isMap := value.(map[string]interface{})
+		if baseIsMap && isMap {
+			merged[key] = mergeSections(baseSection, section)
+			continue
+		}
+		merged[key] = value
+	}
+	return merged
+}
+
+// adapterEndpoint возвращает адрес адаптера для поиска повторов среди автомобилей.
+// Пустая строка - адрес неизвестен до подключения (поиск по имени) или адаптера нет (replay)
+func adapterEndpoint(adapter bluetooth.Config) string {
+	switch adapter.Transport {
+	case "", bluetooth.TransportSerial:
+		return bluetooth.TransportSerial + ":" + adapter.DevicePath
+	case bluetooth.TransportReplay:
+		return ""
+	}
+	if adapter.Address == "" {
+		return ""
+	}
+	return adapter.Transport + ":" + strings.ToUpper(adapter.Address)
+}
+
+// clientID возвращает ID клиента MQTT автомобиля: у каждого экземпляра моста свое
+// подключение к брокеру, одинаковые ID вытесняли бы друг друга
+func (v VehicleConfig) clientID(shared string) string {
+	if v.ClientID != "" {
+		return v.ClientID
+	}
+	if v.VIN == "" {
+		return shared
+	}
+	return shared + "-" + v.VIN
+}
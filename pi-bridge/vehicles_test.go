@@ -0,0 +1,219 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"elm327-bridge/bluetooth"
+	"elm327-bridge/mqtt"
+	"elm327-bridge/obd"
+)
+
+func TestVehicleAdapterConfig(t *testing.T) {
+	base := map[string]interface{}{
+		"device_path":   "/dev/rfcomm0",
+		"read_timeout":  "5s",
+		"init_commands": []interface{}{"ATZ", "ATE0", "ATL0", "ATSP0"},
+		"retry":         map[string]interface{}{"max_attempts": 3, "backoff": "500ms"},
+	}
+	overrides := map[string]interface{}{
+		"device_path":   "/dev/rfcomm1",
+		"init_commands": []interface{}{"ATZ"},
+		"retry":         map[string]interface{}{"max_attempts": 1},
+	}
+
+	adapter, err := vehicleAdapterConfig(base, overrides)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if adapter.DevicePath != "/dev/rfcomm1" {
+		t.Errorf("Expected overridden device path, got %s", adapter.DevicePath)
+	}
+	if adapter.ReadTimeout != 5*time.Second {
+		t.Errorf("Expected inherited read timeout 5s, got %v", adapter.ReadTimeout)
+	}
+	if adapter.WriteTimeout != bluetooth.DefaultConfig().WriteTimeout {
+		t.Errorf("Expected default write timeout, got %v", adapter.WriteTimeout)
+	}
+	// Списки заменяются целиком, вложенные секции объединяются по ключам
+	if len(adapter.InitCommands) != 1 || adapter.InitCommands[0] != "ATZ" {
+		t.Errorf("Expected init commands to be replaced, got %v", adapter.InitCommands)
+	}
+	if adapter.Retry.MaxAttempts != 1 || adapter.Retry.Backoff != 500*time.Millisecond {
+		t.Errorf("Expected merged retry section, got %+v", adapter.Retry)
+	}
+
+	if _, err := vehicleAdapterConfig(base, map[string]interface{}{"read_timeout": "soon"}); err == nil {
+		t.Error("Expected error for invalid override")
+	}
+}
+
+func TestResolveVehicles(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+
+	base := map[string]interface{}{"device_path": "/dev/rfcomm0"}
+	tests := []struct {
+		name     string
+		vehicles []VehicleConfig
+		legacy   bool
+		wantErr  string
+	}{
+		{
+			name: "two adapters",
+			vehicles: []VehicleConfig{
+				{VIN: "VIN1"},
+				{VIN: "VIN2", Bluetooth: map[string]interface{}{"device_path": "/dev/rfcomm1"}},
+			},
+		},
+		{
+			name:     "missing VIN",
+			vehicles: []VehicleConfig{{Name: "van"}},
+			wantErr:  "vin must be set",
+		},
+		{
+			name:     "wildcard in VIN",
+			vehicles: []VehicleConfig{{VIN: "VIN/1"}},
+			wantErr:  "invalid VIN",
+		},
+		{
+			name:     "duplicate VIN",
+			vehicles: []VehicleConfig{{VIN: "VIN1"}, {VIN: "VIN1", Bluetooth: map[string]interface{}{"device_path": "/dev/rfcomm1"}}},
+			wantErr:  "duplicate vehicle VIN",
+		},
+		{
+			name:     "shared adapter",
+			vehicles: []VehicleConfig{{VIN: "VIN1"}, {VIN: "VIN2"}},
+			wantErr:  "use the same adapter",
+		},
+		{
+			name: "shared TCP adapter",
+			vehicles: []VehicleConfig{
+				{VIN: "VIN1", Bluetooth: map[string]interface{}{"transport": "tcp", "address": "127.0.0.1:35000"}},
+				{VIN: "VIN2", Bluetooth: map[string]interface{}{"transport": "tcp", "address": "127.0.0.1:35000"}},
+			},
+			wantErr: "use the same adapter",
+		},
+		{
+			name:     "invalid override",
+			vehicles: []VehicleConfig{{VIN: "VIN1", Bluetooth: map[string]interface{}{"transport": "tcp"}}},
+			wantErr:  "vehicle VIN1",
+		},
+		{
+			name:     "legacy topics",
+			vehicles: []VehicleConfig{{VIN: "VIN1"}, {VIN: "VIN2", Bluetooth: map[string]interface{}{"device_path": "/dev/rfcomm1"}}},
+			legacy:   true,
+			wantErr:  "legacy MQTT topics",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config = Config{Bluetooth: bluetooth.DefaultConfig(), MQTT: mqtt.DefaultConfig(), ReadOnly: true}
+			config.MQTT.Legacy.Enabled = tt.legacy
+			config.Vehicles = tt.vehicles
+
+			err := resolveVehicles(base)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, vehicle := range config.Vehicles {
+				if !vehicle.adapter.ReadOnly {
+					t.Errorf("Expected read-only mode to apply to vehicle %s", vehicle.VIN)
+				}
+			}
+			if config.Vehicles[1].adapter.DevicePath != "/dev/rfcomm1" {
+				t.Errorf("Expected second vehicle on /dev/rfcomm1, got %s", config.Vehicles[1].adapter.DevicePath)
+			}
+		})
+	}
+}
+
+func TestResolveSingleVehicle(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+
+	config = Config{Bluetooth: bluetooth.DefaultConfig(), MQTT: mqtt.DefaultConfig()}
+	if err := resolveVehicles(nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(config.Vehicles) != 1 || config.Vehicles[0].VIN != "" || config.Vehicles[0].adapter.DevicePath != "/dev/rfcomm0" {
+		t.Errorf("Expected implicit vehicle from bluetooth section, got %+v", config.Vehicles)
+	}
+	if id := config.Vehicles[0].clientID("bridge"); id != "bridge" {
+		t.Errorf("Expected shared client ID for single vehicle, got %s", id)
+	}
+}
+
+func TestResolveVehicleInitProfiles(t *testing.T) {
+	saved := config
+	defer func() { config = saved }()
+
+	// Профили задаются в общей секции, выбор - по VIN или ключом автомобиля
+	base := map[string]interface{}{
+		"device_path": "/dev/rfcomm0",
+		"init_profiles": map[string]interface{}{
+			"toyota_kline": map[string]interface{}{
+				"vin_prefixes":  []interface{}{"JT"},
+				"init_commands": []interface{}{"ATZ", "ATSP5"},
+			},
+			"vag_can": map[string]interface{}{
+				"vin_prefixes":  []interface{}{"WVW"},
+				"init_commands": []interface{}{"ATZ", "ATSP6"},
+			},
+		},
+	}
+	config = Config{Bluetooth: bluetooth.DefaultConfig(), MQTT: mqtt.DefaultConfig()}
+	config.Vehicles = []VehicleConfig{
+		{VIN: "JTDKB20U093000001"},
+		{VIN: "1FTFW1ET5DFC10312", Bluetooth: map[string]interface{}{"device_path": "/dev/rfcomm1", "init_profile": "vag_can"}},
+		{VIN: "KMHCT41D0BU000001", Bluetooth: map[string]interface{}{"device_path": "/dev/rfcomm2"}},
+	}
+	if err := resolveVehicles(base); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	want := [][]string{{"ATZ", "ATSP5"}, {"ATZ", "ATSP6"}, bluetooth.DefaultConfig().InitCommands}
+	for i, vehicle := range config.Vehicles {
+		if got := vehicle.adapter.InitCommands; strings.Join(got, " ") != strings.Join(want[i], " ") {
+			t.Errorf("Vehicle %s: expected init commands %v, got %v", vehicle.VIN, want[i], got)
+		}
+	}
+
+	config.Vehicles = []VehicleConfig{{VIN: "VIN1", Bluetooth: map[string]interface{}{"init_profile": "bmw"}}}
+	if err := resolveVehicles(base); err == nil || !strings.Contains(err.Error(), "unknown init profile") {
+		t.Errorf("Expected unknown profile error, got %v", err)
+	}
+}
+
+func TestVehicleClientID(t *testing.T) {
+	if id := (VehicleConfig{VIN: "VIN1"}).clientID("bridge"); id != "bridge-VIN1" {
+		t.Errorf("Expected VIN suffix, got %s", id)
+	}
+	if id := (VehicleConfig{VIN: "VIN1", ClientID: "van"}).clientID("bridge"); id != "van" {
+		t.Errorf("Expected explicit client ID, got %s", id)
+	}
+}
+
+func TestValidateBluetoothProtocolInJ1939Mode(t *testing.T) {
+	if err := obd.SetProtocol(obd.ProtocolJ1939, nil); err != nil {
+		t.Fatal(err)
+	}
+	defer obd.SetProtocol(obd.ProtocolOBD2, nil)
+
+	adapter := bluetooth.DefaultConfig()
+	if err := validateBluetooth(adapter); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	adapter.Protocol = "6"
+	if err := validateBluetooth(adapter); err == nil {
+		t.Error("Expected pinned protocol to be rejected in J1939 mode")
+	}
+}